@@ -0,0 +1,96 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// default colors of routes (see RouteColors)
+const (
+	defaultRouteColor     = "FFFFFF"
+	defaultRouteTextColor = "000000"
+)
+
+// RouteColors returns the color of the route and the color of text drawn
+// against it (as hexadecimal numbers, e.g. "008D4F"), defaulting to white and
+// black (as specified by GTFS) if not given.
+func (r Route) RouteColors() (color, textColor string) {
+	color, textColor = r.Color, r.TextColor
+	if color == "" {
+		color = defaultRouteColor
+	}
+	if textColor == "" {
+		textColor = defaultRouteTextColor
+	}
+	return color, textColor
+}
+
+// ListAgencies returns all agencies (ordered by ID).
+func ListAgencies(db *gorm.DB) ([]Agency, error) {
+	var agencies []Agency
+	if tx := db.Order("id").Find(&agencies); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return agencies, nil
+}
+
+// GetAgency returns the agency with the given ID. If there is no such agency,
+// gorm.ErrRecordNotFound is returned.
+func GetAgency(db *gorm.DB, agencyID string) (*Agency, error) {
+	var agency Agency
+	tx := db.Limit(1).Find(&agency, "id = ?", agencyID)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &agency, nil
+}
+
+// FeedTimezone returns the timezone of the agencies (which GTFS requires to be
+// the same for all agencies of a feed), i.e. the timezone GTFS times are
+// given in. If no agency specifies a timezone, UTC is returned.
+func FeedTimezone(db *gorm.DB) (*time.Location, error) {
+	var timezones []string
+	if tx := db.Model(&Agency{}).Where("timezone <> ''").Order("id").Limit(1).Pluck("timezone", &timezones); tx.Error != nil {
+		return nil, tx.Error
+	}
+	if len(timezones) == 0 {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(timezones[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to load timezone '%s': %w", timezones[0], err)
+	}
+	return loc, nil
+}
+
+// ListRoutes returns the routes of the agency with the given ID (all routes, if
+// agencyID is empty) ordered by ID.
+func ListRoutes(db *gorm.DB, agencyID string) ([]Route, error) {
+	var routes []Route
+	tx := db.Order("id")
+	if agencyID != "" {
+		tx = tx.Where("agency_id = ?", agencyID)
+	}
+	if tx = tx.Find(&routes); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return routes, nil
+}
+
+// GetRoute returns the route with the given ID. If there is no such route,
+// gorm.ErrRecordNotFound is returned.
+func GetRoute(db *gorm.DB, routeID string) (*Route, error) {
+	var route Route
+	tx := db.Limit(1).Find(&route, "id = ?", routeID)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &route, nil
+}
@@ -0,0 +1,93 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestAgencies(t *testing.T) {
+	db := newFixtureDB(t)
+
+	agencies, err := gtfs.ListAgencies(db)
+	if err != nil {
+		t.Fatalf("ListAgencies() error = %v", err)
+	}
+	if len(agencies) != 2 || agencies[0].ID != "1" || agencies[1].ID != "2" {
+		t.Fatalf("ListAgencies() = %v, want agencies 1 and 2", agencies)
+	}
+	if a := agencies[0]; a.Timezone != "Europe/Berlin" || a.Lang != "de" || a.FareURL != "https://sbahn.berlin/tickets/" {
+		t.Errorf("ListAgencies()[0] = %+v, want timezone, lang and fare URL", a)
+	}
+
+	agency, err := gtfs.GetAgency(db, "2")
+	if err != nil {
+		t.Fatalf("GetAgency() error = %v", err)
+	}
+	if agency.Name != "BVG" || agency.Phone != "030 19449" {
+		t.Errorf("GetAgency() = %+v, want BVG", agency)
+	}
+	if _, err = gtfs.GetAgency(db, "X"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetAgency() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestFeedTimezone(t *testing.T) {
+	loc, err := gtfs.FeedTimezone(newTestDB(t))
+	if err != nil || loc != time.UTC {
+		t.Errorf("FeedTimezone() = %v, %v, want UTC", loc, err)
+	}
+	loc, err = gtfs.FeedTimezone(newFixtureDB(t))
+	if err != nil || loc.String() != "Europe/Berlin" {
+		t.Errorf("FeedTimezone() = %v, %v, want Europe/Berlin", loc, err)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	db := newFixtureDB(t)
+
+	tests := []struct {
+		agencyID string
+		want     []string
+	}{
+		{"", []string{"R1", "R2"}},
+		{"1", []string{"R1"}},
+		{"X", nil},
+	}
+	for _, tt := range tests {
+		routes, err := gtfs.ListRoutes(db, tt.agencyID)
+		if err != nil {
+			t.Fatalf("ListRoutes(%q) error = %v", tt.agencyID, err)
+		}
+		var got []string
+		for _, r := range routes {
+			got = append(got, r.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("ListRoutes(%q) = %v, want %v", tt.agencyID, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("ListRoutes(%q) = %v, want %v", tt.agencyID, got, tt.want)
+			}
+		}
+	}
+
+	// colors default to white and black
+	colors := map[string][2]string{"R1": {"008D4F", "FFFFFF"}, "R2": {"FFFFFF", "000000"}}
+	for routeID, want := range colors {
+		route, err := gtfs.GetRoute(db, routeID)
+		if err != nil {
+			t.Fatalf("GetRoute(%q) error = %v", routeID, err)
+		}
+		if color, textColor := route.RouteColors(); color != want[0] || textColor != want[1] {
+			t.Errorf("RouteColors() of %s = %s, %s, want %s, %s", routeID, color, textColor, want[0], want[1])
+		}
+	}
+	if _, err := gtfs.GetRoute(db, "X"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetRoute() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
@@ -0,0 +1,113 @@
+package gtfs
+
+import (
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"gorm.io/gorm"
+	"io"
+)
+
+// StopAmenity model (external, user-provided amenities of a stop, e.g. as
+// surveyed by the operator). Amenities are 1 (present), 2 (absent) or 0
+// (unknown).
+type StopAmenity struct {
+	StopID          string `csv:"stop_id" gorm:"primaryKey"`
+	Shelter         int    `csv:"shelter"`
+	Bench           int    `csv:"bench"`
+	Lighting        int    `csv:"lighting"`
+	RealtimeDisplay int    `csv:"realtime_display"`
+}
+
+// TableName returns the name of the table holding StopAmenity items.
+func (StopAmenity) TableName() string {
+	return "stop_amenities"
+}
+
+// ImportStopAmenities imports the amenities of stops from CSV (with the
+// columns stop_id, shelter, bench, lighting and realtime_display), replacing
+// any previously imported amenities. It returns the number of imported rows.
+func ImportStopAmenities(db *gorm.DB, r io.Reader) (int64, error) {
+	var items []*StopAmenity
+	if err := gocsv.Unmarshal(r, &items); err != nil {
+		return 0, fmt.Errorf("failed to parse stop amenities: %w", err)
+	}
+	for _, item := range items {
+		for _, v := range []int{item.Shelter, item.Bench, item.Lighting, item.RealtimeDisplay} {
+			if v < 0 || v > 2 {
+				return 0, fmt.Errorf("invalid amenity %d of stop '%s' (expected 0, 1 or 2)", v, item.StopID)
+			}
+		}
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM stop_amenities").Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(items, 1000).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(items)), nil
+}
+
+// GetStopAmenities returns the amenities of the stop with the given ID. If
+// none were imported for the stop, gorm.ErrRecordNotFound is returned.
+func GetStopAmenities(db *gorm.DB, stopID string) (*StopAmenity, error) {
+	if !db.Migrator().HasTable(&StopAmenity{}) {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var amenity StopAmenity
+	tx := db.Limit(1).Find(&amenity, "stop_id = ?", stopID)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &amenity, nil
+}
+
+// CopyStopAmenities copies the amenities of stops from one DB (e.g. the
+// previous version of a feed) to another (e.g. the version replacing it),
+// replacing the amenities of the other DB, such that amenities survive
+// re-importing a feed. Amenities of stops missing in the other DB are
+// dropped. CopyStopAmenities returns the number of amenities copied.
+func CopyStopAmenities(from, to *gorm.DB) (int, error) {
+	if !from.Migrator().HasTable(&StopAmenity{}) {
+		return 0, nil
+	}
+	var amenities []StopAmenity
+	if tx := from.Order("stop_id").Find(&amenities); tx.Error != nil {
+		return 0, tx.Error
+	}
+	var stopIDs []string
+	if tx := to.Model(&Stop{}).Pluck("id", &stopIDs); tx.Error != nil {
+		return 0, tx.Error
+	}
+	stops := make(map[string]bool, len(stopIDs))
+	for _, id := range stopIDs {
+		stops[id] = true
+	}
+	var kept []*StopAmenity
+	for i := range amenities {
+		if stops[amenities[i].StopID] {
+			kept = append(kept, &amenities[i])
+		}
+	}
+	err := to.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM stop_amenities").Error; err != nil {
+			return err
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(kept, 1000).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(kept), nil
+}
@@ -0,0 +1,50 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestImportStopAmenities(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// not imported
+	if _, err := gtfs.GetStopAmenities(db, "S1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetStopAmenities() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	csv := "stop_id,shelter,bench,lighting,realtime_display\nS1,1,1,1,2\nS4,2,1,0,0\n"
+	for i := 0; i < 2; i++ {
+		if n, err := gtfs.ImportStopAmenities(db, strings.NewReader(csv)); err != nil || n != 2 {
+			t.Fatalf("ImportStopAmenities() = %d, %v, want 2", n, err)
+		}
+	}
+	a, err := gtfs.GetStopAmenities(db, "S1")
+	if err != nil {
+		t.Fatalf("GetStopAmenities() error = %v", err)
+	}
+	if want := (gtfs.StopAmenity{StopID: "S1", Shelter: 1, Bench: 1, Lighting: 1, RealtimeDisplay: 2}); *a != want {
+		t.Errorf("GetStopAmenities() = %+v, want %+v", *a, want)
+	}
+	if _, err = gtfs.GetStopAmenities(db, "S2"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetStopAmenities() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+
+	// invalid amenities
+	if _, err = gtfs.ImportStopAmenities(db, strings.NewReader("stop_id,shelter\nS1,3\n")); err == nil {
+		t.Errorf("ImportStopAmenities() error = nil, want error")
+	}
+
+	// amenities of stops missing in the other DB are dropped
+	to := newFixtureDB(t)
+	to.Exec("DELETE FROM stops WHERE id = 'S4'")
+	if n, err := gtfs.CopyStopAmenities(db, to); err != nil || n != 1 {
+		t.Fatalf("CopyStopAmenities() = %d, %v, want 1", n, err)
+	}
+	if _, err = gtfs.GetStopAmenities(to, "S1"); err != nil {
+		t.Errorf("GetStopAmenities() error = %v", err)
+	}
+}
@@ -0,0 +1,191 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// AnalyzeFeed computes statistics of the feed within the directory (or zip
+// archive, see ImportZip) gtfsBase directly from its CSV files, i.e. without
+// creating a DB, for a quick triage of feeds (e.g. in CI pipelines). The
+// counts are those of the rows of the files. Along with the statistics,
+// orphaned records (see OrphanedRecords) are reported as issues (stop times
+// and frequencies are identified by their row, i.e. the ID they would get when
+// imported). Route patterns, ridership and checks requiring the whole feed
+// (e.g. ImplausibleSpeeds) are not covered, as these require importing the
+// feed into a DB (see Stats and Validate).
+func AnalyzeFeed(gtfsBase string) (*FeedStats, error) {
+	fsys, dir, closeFeed, err := openFeed(gtfsBase)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFeed()
+
+	stats := &FeedStats{Counts: map[ItemType]int64{}, Issues: []Issue{}}
+	for itemType := range itemModels {
+		stats.Counts[itemType] = 0
+	}
+
+	// the IDs known (and referred to) so far
+	agencyIDs := map[string]bool{}
+	routeIDs := map[string]bool{}
+	tripIDs := map[string]bool{}
+	stopIDs := map[string]bool{}
+	usedShapeIDs := map[string]bool{}
+	orphans := map[ItemType]map[string]bool{}
+	orphan := func(itemType ItemType, id string) {
+		if orphans[itemType] == nil {
+			orphans[itemType] = map[string]bool{}
+		}
+		orphans[itemType][id] = true
+	}
+
+	// the first and last day of service (formatted as GTFS dates)
+	var firstDay, lastDay string
+	serviceDay := func(day string) {
+		if firstDay == "" || day < firstDay {
+			firstDay = day
+		}
+		if lastDay == "" || day > lastDay {
+			lastDay = day
+		}
+	}
+
+	// scan the files in the order of importing them (i.e. referred to items first)
+	for _, source := range importSources {
+		name := path.Join(dir, itemFiles[source.itemType])
+		if _, err := fs.Stat(fsys, name); source.optional && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		var count int64
+		switch source.itemType {
+		case Agencies:
+			count, err = scanItems(fsys, name, func(_ int64, a *Agency) {
+				agencyIDs[a.ID] = true
+			})
+		case Routes:
+			count, err = scanItems(fsys, name, func(_ int64, r *Route) {
+				routeIDs[r.ID] = true
+				if r.AgencyID != "" && !agencyIDs[r.AgencyID] {
+					orphan(Routes, r.ID)
+				}
+			})
+		case Trips:
+			count, err = scanItems(fsys, name, func(_ int64, t *Trip) {
+				tripIDs[t.ID] = true
+				usedShapeIDs[t.ShapeID] = true
+				if !routeIDs[t.RouteID] {
+					orphan(Trips, t.ID)
+				}
+			})
+		case Stops:
+			count, err = scanItems(fsys, name, func(_ int64, s *Stop) {
+				stopIDs[s.ID] = true
+			})
+		case StopTimes:
+			count, err = scanItems(fsys, name, func(row int64, st *StopTime) {
+				if !tripIDs[st.TripID] || !stopIDs[st.StopID] {
+					orphan(StopTimes, strconv.FormatInt(row, 10))
+				}
+			})
+		case Shapes:
+			count, err = scanItems(fsys, name, func(_ int64, s *Shape) {
+				if !usedShapeIDs[s.ShapeID] {
+					orphan(Shapes, s.ShapeID)
+				}
+			})
+		case Calendars:
+			count, err = scanItems(fsys, name, func(_ int64, c *Calendar) {
+				serviceDay(c.StartDate)
+				serviceDay(c.EndDate)
+			})
+		case CalendarDates:
+			count, err = scanItems(fsys, name, func(_ int64, cd *CalendarDate) {
+				if cd.ExceptionType == 1 {
+					serviceDay(cd.Date)
+				}
+			})
+		case Frequencies:
+			count, err = scanItems(fsys, name, func(row int64, f *Frequency) {
+				if !tripIDs[f.TripID] {
+					orphan(Frequencies, strconv.FormatInt(row, 10))
+				}
+			})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze '%s': %w", path.Join(gtfsBase, name), err)
+		}
+		stats.Counts[source.itemType] = count
+	}
+
+	// the service period
+	if firstDay != "" {
+		if stats.FirstDay, err = time.ParseInLocation(DateLayout, firstDay, time.UTC); err != nil {
+			return nil, fmt.Errorf("cannot parse GTFS date from '%s': %w", firstDay, err)
+		}
+		if stats.LastDay, err = time.ParseInLocation(DateLayout, lastDay, time.UTC); err != nil {
+			return nil, fmt.Errorf("cannot parse GTFS date from '%s': %w", lastDay, err)
+		}
+	}
+
+	// the orphaned records (like OrphanedRecords reports them)
+	for _, itemType := range []ItemType{Routes, Trips, StopTimes, Frequencies, Shapes} {
+		ids := make([]string, 0, len(orphans[itemType]))
+		for id := range orphans[itemType] {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		message := "refers to a missing item"
+		if itemType == Shapes {
+			message = "is not used by any trip"
+		}
+		for _, id := range ids {
+			stats.Issues = append(stats.Issues, Issue{Rule: "orphaned_records", Severity: Warning, ItemType: itemType, ItemID: id, Message: message})
+		}
+	}
+
+	// the feed info (if any)
+	info, err := readFeedInfo(fsys, path.Join(dir, feedInfoFile), path.Join(gtfsBase, dir, feedInfoFile))
+	if err != nil {
+		return nil, err
+	}
+	if info.PublisherName != "" || info.PublisherURL != "" || info.Version != "" {
+		stats.Meta = info
+	}
+
+	return stats, nil
+}
+
+// scanItems decodes the items (of type T) of the CSV file named name within
+// fsys (see FastDecoder), calling fn with each item along with its row
+// (starting at 1), and returns the number of items.
+func scanItems[T any](fsys fs.FS, name string, fn func(row int64, item *T)) (int64, error) {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	r, done := normalizeCSV(newTextReader(file, EncodingUTF8), reflect.TypeOf((*T)(nil)).Elem(), 0, false, false)
+	defer done()
+
+	// decode the file (the decoder closes the channel)
+	items := make(chan *T)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- FastDecoder{}.Decode(r, items)
+	}()
+	var rows int64
+	for item := range items {
+		rows++
+		fn(rows, item)
+	}
+	return rows, <-errChan
+}
@@ -0,0 +1,98 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+// writeFeed writes the fixture feed to a temporary directory (appending the
+// given rows to files) and returns the directory.
+func writeFeed(t *testing.T, rows map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir(fixtureFeed)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(path.Join(fixtureFeed, e.Name()))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		b = append(b, rows[e.Name()]...)
+		if err = os.WriteFile(path.Join(dir, e.Name()), b, 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestAnalyzeFeed(t *testing.T) {
+	tests := []struct {
+		name       string
+		rows       map[string]string
+		wantIssues int
+	}{
+		{
+			name: "fixture",
+		},
+		{
+			name: "orphans",
+			rows: map[string]string{
+				"routes.txt":     "R9,99,X,Orphan,3,,\n",
+				"trips.txt":      "R8,WD,T9,Nowhere,,0,SH1\n",
+				"stop_times.txt": "T9,09:00:00,09:00:00,S1,1\nT1,09:00:00,09:00:00,S99,9\n",
+				"shapes.txt":     "SH9,52.0,13.0,1\nSH9,52.1,13.1,2\n",
+			},
+			wantIssues: 4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := writeFeed(t, tt.rows)
+			got, err := gtfs.AnalyzeFeed(feed)
+			if err != nil {
+				t.Fatalf("AnalyzeFeed() error = %v", err)
+			}
+
+			// compare with the stats and orphans of the imported feed
+			db := newTestDB(t)
+			gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{})
+			want, err := gtfs.Stats(db)
+			if err != nil {
+				t.Fatalf("Stats() error = %v", err)
+			}
+			if !reflect.DeepEqual(got.Counts, want.Counts) {
+				t.Errorf("AnalyzeFeed() counts = %v, want %v", got.Counts, want.Counts)
+			}
+			if !got.FirstDay.Equal(want.FirstDay) || !got.LastDay.Equal(want.LastDay) {
+				t.Errorf("AnalyzeFeed() service = %v - %v, want %v - %v", got.FirstDay, got.LastDay, want.FirstDay, want.LastDay)
+			}
+			wantIssues, err := gtfs.Validate(db, gtfs.Rule{Name: "orphaned_records", Check: gtfs.OrphanedRecords})
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if len(got.Issues) != tt.wantIssues || (tt.wantIssues > 0 && !reflect.DeepEqual(got.Issues, wantIssues)) {
+				t.Errorf("AnalyzeFeed() issues = %v, want %v", got.Issues, wantIssues)
+			}
+			if got.Meta == nil || got.Meta.Version != "2022-01" {
+				t.Errorf("AnalyzeFeed() meta = %v, want version 2022-01", got.Meta)
+			}
+		})
+	}
+}
+
+func TestAnalyzeFeed_Zip(t *testing.T) {
+	zipPath := path.Join(t.TempDir(), "feed.zip")
+	writeZip(t, zipPath, []string{"gtfs"}, nil)
+	got, err := gtfs.AnalyzeFeed(zipPath)
+	if err != nil {
+		t.Fatalf("AnalyzeFeed() error = %v", err)
+	}
+	if got.Counts[gtfs.StopTimes] != 15 || len(got.Issues) != 0 {
+		t.Errorf("AnalyzeFeed() = %v", got)
+	}
+}
@@ -0,0 +1,51 @@
+package gtfs
+
+import (
+	"github.com/gocarina/gocsv"
+	"gorm.io/gorm"
+	"io"
+)
+
+// actions recorded for rows skipped or modified when importing
+const (
+	RejectSkipped    = "skipped"    // a duplicate skipped (see ConflictSkip)
+	RejectReplaced   = "replaced"   // a duplicate replacing a previous row (see ConflictReplace)
+	RejectRejected   = "rejected"   // a row failed to insert (see ImportOptions.SkipFailedRows)
+	RejectNormalized = "normalized" // a row modified by a profile (see ImportOptions.Profile)
+	RejectMalformed  = "malformed"  // a row failed to parse (see ImportLenient)
+)
+
+// ImportReject model (a row skipped or modified when importing, see
+// ImportOptions.RecordRejects).
+type ImportReject struct {
+	ID     uint   `gorm:"primaryKey,autoIncrement" csv:"-"`
+	File   string `csv:"file"`
+	Line   int64  `csv:"line"`
+	Action string `csv:"action"`
+	Reason string `csv:"reason"`
+}
+
+// TableName returns the name of the table holding ImportReject items.
+func (ImportReject) TableName() string {
+	return "import_rejects"
+}
+
+// ImportRejects returns all rows recorded as skipped or modified when
+// importing (in the order recorded).
+func ImportRejects(db *gorm.DB) ([]ImportReject, error) {
+	var rejects []ImportReject
+	if err := db.Order("id").Find(&rejects).Error; err != nil {
+		return nil, err
+	}
+	return rejects, nil
+}
+
+// WriteImportRejects writes all rows recorded as skipped or modified when
+// importing (see ImportRejects) as CSV to w.
+func WriteImportRejects(db *gorm.DB, w io.Writer) error {
+	rejects, err := ImportRejects(db)
+	if err != nil {
+		return err
+	}
+	return gocsv.Marshal(rejects, w)
+}
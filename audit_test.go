@@ -0,0 +1,73 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestImportWithOptions_RecordRejects(t *testing.T) {
+
+	// a feed repeating a stop (in line 3), with a prefixed stop ID (in line 4)
+	// and an agency conflicting with an existing one (in line 2)
+	feed := t.TempDir()
+	files := map[string]string{
+		"agency.txt":         "agency_id,agency_name,agency_url\n1,BVG,https://www.bvg.de/\n",
+		"routes.txt":         "route_id,agency_id,route_short_name,route_long_name,route_type\n",
+		"trips.txt":          "route_id,service_id,trip_id\n",
+		"stops.txt":          "stop_id,stop_name,stop_lat,stop_lon\nS1,Zoo,52.5,13.3\nS1,Zoo,52.5,13.3\nX:S2,Alex,52.5,13.4\n",
+		"stop_times.txt":     "trip_id,arrival_time,departure_time,stop_id,stop_sequence\n",
+		"shapes.txt":         "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n",
+		"calendar.txt":       "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(feed, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+
+	db := newTestDB(t)
+	db.Create(&gtfs.Agency{ID: "1", Name: "Existing"})
+	gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+		Conflicts:      map[gtfs.ItemType]gtfs.ConflictStrategy{gtfs.Stops: gtfs.ConflictSkip},
+		SkipFailedRows: true,
+		Profile:        &gtfs.ImportProfile{StripIDPrefixes: []string{"X:"}},
+		RecordRejects:  true,
+		OnProgress: func(e gtfs.ImportEvent) {
+			if e.Result.Error != nil {
+				t.Errorf("ImportWithOptions() error = %v", e.Result.Error)
+			}
+		},
+	})
+
+	rejects, err := gtfs.ImportRejects(db)
+	if err != nil {
+		t.Fatalf("ImportRejects() error = %v", err)
+	}
+	want := []gtfs.ImportReject{
+		{File: "agency.txt", Line: 2, Action: gtfs.RejectRejected},
+		{File: "stops.txt", Line: 3, Action: gtfs.RejectSkipped, Reason: "duplicate ID 'S1' (first in line 2)"},
+		{File: "stops.txt", Line: 4, Action: gtfs.RejectNormalized, Reason: "stop_id 'X:S2' -> 'S2'"},
+	}
+	if len(rejects) != len(want) {
+		t.Fatalf("ImportRejects() = %+v, want %d rejects", rejects, len(want))
+	}
+	for i, w := range want {
+		got := rejects[i]
+		if got.File != w.File || got.Line != w.Line || got.Action != w.Action || (w.Reason != "" && got.Reason != w.Reason) {
+			t.Errorf("ImportRejects()[%d] = %+v, want %+v", i, got, w)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err = gtfs.WriteImportRejects(db, &buf); err != nil {
+		t.Fatalf("WriteImportRejects() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "file,line,action,reason\n") || strings.Count(buf.String(), "\n") != 4 {
+		t.Errorf("WriteImportRejects() = %q", buf.String())
+	}
+}
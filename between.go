@@ -0,0 +1,134 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// statement to select the trips serving a stop and (later on) another stop
+const tripsBetweenStmt = `
+SELECT
+	trips.id AS trip_id,
+	trips.route_id,
+	routes.short_name AS route_name,
+	trips.direction_id,
+	trips.headsign,
+	trips.service_id,
+	a.departure,
+	b.arrival
+FROM
+	stop_times a
+	JOIN stop_times b ON b.trip_id = a.trip_id AND b.stop_seq > a.stop_seq
+	JOIN trips ON trips.id = a.trip_id
+	JOIN routes ON routes.id = trips.route_id
+WHERE
+	a.stop_id = ? AND
+	b.stop_id = ?
+ORDER BY
+	a.departure,
+	trips.id;
+`
+
+// TripBetween describes a trip serving a stop and (later on) another stop
+// (see TripsBetween).
+type TripBetween struct {
+	TripID      string
+	RouteID     string
+	RouteName   string
+	DirectionID string
+	Headsign    string
+	ServiceID   string
+	Departure   DateTime // the departure at the first stop
+	Arrival     DateTime // the arrival at the second stop
+}
+
+// TravelTime returns the time traveling between the two stops takes.
+func (tb TripBetween) TravelTime() time.Duration {
+	return time.Duration(tb.Arrival.Int32-tb.Departure.Int32) * time.Second
+}
+
+// TripsBetween returns the trips (ordered by departure) directly connecting
+// the stop fromStopID to the stop toStopID, i.e. serving the latter after the
+// former (regardless of the days the trips run on). Trips serving the stops
+// several times are returned once per pair of stop times.
+func TripsBetween(db *gorm.DB, fromStopID, toStopID string) ([]TripBetween, error) {
+	trips := []TripBetween{}
+	if tx := db.Raw(tripsBetweenStmt, fromStopID, toStopID).Scan(&trips); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return trips, nil
+}
+
+// RouteBetween describes the trips of a route (in a direction) directly
+// connecting two stops (see RoutesBetween).
+type RouteBetween struct {
+	RouteID     string
+	RouteName   string
+	DirectionID string
+	Headsign    string        // the most common headsign of the trips
+	Trips       int           // the number of trips
+	TravelTime  time.Duration // the typical (i.e. median) travel time
+}
+
+// String returns a human-readable representation of RouteBetween (e.g. "S1 to
+// S Rathaus Steglitz: 2 trips, 8m0s").
+func (rb RouteBetween) String() string {
+	return fmt.Sprintf("%s to %s: %d trips, %s", rb.RouteName, rb.Headsign, rb.Trips, rb.TravelTime)
+}
+
+// RoutesBetween aggregates the trips directly connecting the stop fromStopID
+// to the stop toStopID (see TripsBetween) per route and direction, ordered by
+// travel time (and route ID).
+func RoutesBetween(db *gorm.DB, fromStopID, toStopID string) ([]RouteBetween, error) {
+	trips, err := TripsBetween(db, fromStopID, toStopID)
+	if err != nil {
+		return nil, err
+	}
+
+	// group the trips by route and direction
+	type group struct {
+		route       RouteBetween
+		travelTimes []time.Duration
+		headsigns   map[string]int
+	}
+	var groups []*group
+	byKey := map[[2]string]*group{}
+	for _, t := range trips {
+		key := [2]string{t.RouteID, t.DirectionID}
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{
+				route:     RouteBetween{RouteID: t.RouteID, RouteName: t.RouteName, DirectionID: t.DirectionID},
+				headsigns: map[string]int{},
+			}
+			byKey[key] = g
+			groups = append(groups, g)
+		}
+		g.route.Trips++
+		g.travelTimes = append(g.travelTimes, t.TravelTime())
+		g.headsigns[t.Headsign]++
+	}
+
+	routes := make([]RouteBetween, len(groups))
+	for i, g := range groups {
+		sort.Slice(g.travelTimes, func(i, j int) bool {
+			return g.travelTimes[i] < g.travelTimes[j]
+		})
+		g.route.TravelTime = g.travelTimes[len(g.travelTimes)/2]
+		for headsign, n := range g.headsigns {
+			if n > g.headsigns[g.route.Headsign] || (n == g.headsigns[g.route.Headsign] && headsign < g.route.Headsign) {
+				g.route.Headsign = headsign
+			}
+		}
+		routes[i] = g.route
+	}
+	sort.SliceStable(routes, func(i, j int) bool {
+		if routes[i].TravelTime != routes[j].TravelTime {
+			return routes[i].TravelTime < routes[j].TravelTime
+		}
+		return routes[i].RouteID < routes[j].RouteID
+	})
+	return routes, nil
+}
@@ -0,0 +1,65 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+)
+
+func TestTripsBetween(t *testing.T) {
+	db := newFixtureDB(t)
+	tests := []struct {
+		name      string
+		from, to  string
+		wantTrips []string
+	}{
+		{"outbound", "S1", "S3", []string{"T1", "T2"}},
+		{"inbound", "S3", "S1", []string{"T3"}},
+		{"unconnected", "S1", "B1", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trips, err := gtfs.TripsBetween(db, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("TripsBetween() error = %v", err)
+			}
+			got := make([]string, len(trips))
+			for i, trip := range trips {
+				got[i] = trip.TripID
+			}
+			if len(got) != len(tt.wantTrips) {
+				t.Fatalf("TripsBetween() = %v, want %v", got, tt.wantTrips)
+			}
+			for i := range got {
+				if got[i] != tt.wantTrips[i] {
+					t.Errorf("TripsBetween() = %v, want %v", got, tt.wantTrips)
+				}
+			}
+		})
+	}
+}
+
+func TestRoutesBetween(t *testing.T) {
+	db := newFixtureDB(t)
+	copyTrip(t, db, "T1", "T5", 3600)
+	db.Model(&gtfs.StopTime{}).Where("trip_id = ? AND stop_id = ?", "T5", "S3").Update("arrival", gtfs.DateTime{Int32: 9*3600 + 20*60})
+
+	routes, err := gtfs.RoutesBetween(db, "S1", "S3")
+	if err != nil {
+		t.Fatalf("RoutesBetween() error = %v", err)
+	}
+	want := gtfs.RouteBetween{
+		RouteID:     "R1",
+		RouteName:   "S1",
+		DirectionID: "0",
+		Headsign:    "S Rathaus Steglitz",
+		Trips:       3,
+		TravelTime:  8 * time.Minute,
+	}
+	if len(routes) != 1 || routes[0] != want {
+		t.Errorf("RoutesBetween() = %v, want [%v]", routes, want)
+	}
+	if got, wantString := routes[0].String(), "S1 to S Rathaus Steglitz: 3 trips, 8m0s"; got != wantString {
+		t.Errorf("String() = %q, want %q", got, wantString)
+	}
+}
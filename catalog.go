@@ -0,0 +1,113 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+	"sort"
+)
+
+// Catalog holds all agencies, routes and stops of a feed (along with the
+// overrides of stops and the capacities of vehicles) in memory, allowing for
+// O(1) lookups by ID (e.g. when listing departures, see
+// DeparturesOptions.Catalog). A Catalog is immutable and thus safe for
+// concurrent use. As it reflects the DB at the time of loading, it should be
+// loaded once per feed version (i.e. again after importing or reloading a
+// feed, or after setting stop overrides).
+type Catalog struct {
+	agencies   map[string]Agency
+	routes     map[string]Route
+	stops      map[string]Stop
+	overrides  stopOverrideMap
+	capacities *vehicleCapacities
+}
+
+// LoadCatalog loads all agencies, routes and stops (by their display names,
+// see SetStopOverride) from the given DB, along with the overrides of stops
+// and the capacities of vehicles (see ImportVehicleCapacities).
+func LoadCatalog(db *gorm.DB) (*Catalog, error) {
+
+	var agencies []Agency
+	if tx := db.Find(&agencies); tx.Error != nil {
+		return nil, tx.Error
+	}
+	var routes []Route
+	if tx := db.Find(&routes); tx.Error != nil {
+		return nil, tx.Error
+	}
+	var stops []Stop
+	if tx := db.Find(&stops); tx.Error != nil {
+		return nil, tx.Error
+	}
+	overrides, err := stopOverrides(db)
+	if err != nil {
+		return nil, err
+	}
+	capacities, err := loadVehicleCapacities(db)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Catalog{
+		agencies:   make(map[string]Agency, len(agencies)),
+		routes:     make(map[string]Route, len(routes)),
+		stops:      make(map[string]Stop, len(stops)),
+		overrides:  overrides,
+		capacities: capacities,
+	}
+	for _, a := range agencies {
+		c.agencies[a.ID] = a
+	}
+	for _, r := range routes {
+		r.Agency = c.agencies[r.AgencyID]
+		c.routes[r.ID] = r
+	}
+	for _, s := range stops {
+		s.Name = overrides.name(s)
+		c.stops[s.ID] = s
+	}
+	return c, nil
+}
+
+// Agency returns the agency with the given ID.
+func (c *Catalog) Agency(id string) (Agency, bool) {
+	a, ok := c.agencies[id]
+	return a, ok
+}
+
+// Agencies returns all agencies (ordered by ID).
+func (c *Catalog) Agencies() []Agency {
+	agencies := make([]Agency, 0, len(c.agencies))
+	for _, a := range c.agencies {
+		agencies = append(agencies, a)
+	}
+	sort.Slice(agencies, func(i, j int) bool {
+		return agencies[i].ID < agencies[j].ID
+	})
+	return agencies
+}
+
+// Routes returns all routes (ordered by ID) of the agency with the given ID
+// (of all agencies, if agencyID is empty).
+func (c *Catalog) Routes(agencyID string) []Route {
+	routes := make([]Route, 0, len(c.routes))
+	for _, r := range c.routes {
+		if agencyID == "" || r.AgencyID == agencyID {
+			routes = append(routes, r)
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].ID < routes[j].ID
+	})
+	return routes
+}
+
+// Route returns the route (including its agency) with the given ID.
+func (c *Catalog) Route(id string) (Route, bool) {
+	r, ok := c.routes[id]
+	return r, ok
+}
+
+// Stop returns the stop with the given ID.
+func (c *Catalog) Stop(id string) (Stop, bool) {
+	s, ok := c.stops[id]
+	return s, ok
+}
@@ -0,0 +1,56 @@
+package gtfs_test
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestLoadCatalog(t *testing.T) {
+	db := newTestDB(t)
+	db.Create(&gtfs.Agency{ID: "1", Name: "S-Bahn Berlin GmbH"})
+	db.Create(&gtfs.Route{ID: "10", AgencyID: "1", ShortName: "S1"})
+	db.Create(&gtfs.Stop{ID: "100", Name: "S Wannsee"})
+
+	c, err := gtfs.LoadCatalog(db)
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if a, ok := c.Agency("1"); !ok || a.Name != "S-Bahn Berlin GmbH" {
+		t.Errorf("Agency() = %v, %v", a, ok)
+	}
+	if r, ok := c.Route("10"); !ok || r.ShortName != "S1" || r.Agency.ID != "1" {
+		t.Errorf("Route() = %v, %v", r, ok)
+	}
+	if s, ok := c.Stop("100"); !ok || s.Name != "S Wannsee" {
+		t.Errorf("Stop() = %v, %v", s, ok)
+	}
+	if _, ok := c.Stop("unknown"); ok {
+		t.Errorf("Stop() found unknown stop")
+	}
+
+	db.Create(&gtfs.Route{ID: "09", AgencyID: "2", ShortName: "M1"})
+	if c, err = gtfs.LoadCatalog(db); err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if agencies := c.Agencies(); len(agencies) != 1 || agencies[0].ID != "1" {
+		t.Errorf("Agencies() = %v, want agency 1", agencies)
+	}
+	tests := []struct {
+		agencyID string
+		want     []string
+	}{
+		{"", []string{"09", "10"}},
+		{"1", []string{"10"}},
+		{"3", nil},
+	}
+	for _, tt := range tests {
+		var ids []string
+		for _, r := range c.Routes(tt.agencyID) {
+			ids = append(ids, r.ID)
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(tt.want) {
+			t.Errorf("Routes(%q) = %v, want %v", tt.agencyID, ids, tt.want)
+		}
+	}
+}
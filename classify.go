@@ -0,0 +1,186 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ServicePattern enumerates patterns of service a route may follow.
+type ServicePattern uint32
+
+const (
+
+	// AllDay the pattern of routes served throughout the day.
+	AllDay ServicePattern = iota
+
+	// PeakOnly the pattern of routes only served during (weekday) rush hours.
+	PeakOnly
+
+	// WeekendOnly the pattern of routes only served on weekends.
+	WeekendOnly
+
+	// Night the pattern of routes only served at night.
+	Night
+)
+
+var txServicePattern = map[ServicePattern]string{
+	AllDay:      "all-day",
+	PeakOnly:    "peak-only",
+	WeekendOnly: "weekend-only",
+	Night:       "night",
+}
+
+// String returns a human-readable representation of ServicePattern.
+func (sp ServicePattern) String() string {
+	if s := txServicePattern[sp]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ServicePattern (%d)", uint32(sp))
+}
+
+// peak hours (seconds since midnight) and night hours
+const (
+	morningPeakStart = 6 * 3600
+	morningPeakEnd   = 9 * 3600
+	eveningPeakStart = 15 * 3600
+	eveningPeakEnd   = 19 * 3600
+	nightEnd         = 5 * 3600
+	nightStart       = 22 * 3600
+)
+
+// statement to select the route, service and start time of all trips
+const tripStartsStmt = `
+SELECT
+	trips.route_id,
+	trips.service_id,
+	MIN(stop_times.departure)
+FROM
+	trips
+	JOIN stop_times ON stop_times.trip_id = trips.id
+GROUP BY
+	trips.id,
+	trips.route_id,
+	trips.service_id;
+`
+
+// serviceDays tells whether a service runs on weekdays and/or on weekends.
+type serviceDays struct {
+	weekdays bool
+	weekends bool
+}
+
+// loadServiceDays determines for all services whether they regularly run on
+// weekdays and/or on weekends.
+func loadServiceDays(db *gorm.DB) (map[string]*serviceDays, error) {
+	days := map[string]*serviceDays{}
+	get := func(serviceID string) *serviceDays {
+		d, ok := days[serviceID]
+		if !ok {
+			d = &serviceDays{}
+			days[serviceID] = d
+		}
+		return d
+	}
+
+	dateOnly := map[string]bool{}
+	var calendars []Calendar
+	if tx := db.Find(&calendars); tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, c := range calendars {
+		d := get(c.ServiceID)
+		d.weekdays = d.weekdays || c.Monday+c.Tuesday+c.Wednesday+c.Thursday+c.Friday > 0
+		d.weekends = d.weekends || c.Saturday+c.Sunday > 0
+	}
+
+	// consider calendar dates only for services without calendar (i.e. don't
+	// let a service added on a holiday turn a weekend service into a weekday one)
+	var dates []CalendarDate
+	if tx := db.Where("exception_type = ?", 1).Find(&dates); tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, cd := range dates {
+		if _, ok := days[cd.ServiceID]; ok && !dateOnly[cd.ServiceID] {
+			continue
+		}
+		date, err := time.Parse(DateLayout, cd.Date)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse GTFS date from '%s': %w", cd.Date, err)
+		}
+		dateOnly[cd.ServiceID] = true
+		d := get(cd.ServiceID)
+		if wd := date.Weekday(); wd == time.Saturday || wd == time.Sunday {
+			d.weekends = true
+		} else {
+			d.weekdays = true
+		}
+	}
+
+	return days, nil
+}
+
+// ClassifyRoutes classifies the pattern of service of each route based on the
+// start times of its trips and the days the trips run on. Routes only served
+// on weekends are WeekendOnly, routes only served between 22:00 and 05:00 are
+// Night and routes only served between 06:00 and 09:00 or 15:00 and 19:00 are
+// PeakOnly. All other routes are AllDay.
+func ClassifyRoutes(db *gorm.DB) (map[string]ServicePattern, error) {
+
+	days, err := loadServiceDays(db)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := db.Raw(tripStartsStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// track per route, whether all trips are weekend / night / peak trips
+	type flags struct{ weekend, night, peak bool }
+	routes := map[string]*flags{}
+	for rows.Next() {
+		var routeID, serviceID string
+		var start DateTime
+		if err = rows.Scan(&routeID, &serviceID, &start); err != nil {
+			return nil, err
+		}
+		f, ok := routes[routeID]
+		if !ok {
+			f = &flags{weekend: true, night: true, peak: true}
+			routes[routeID] = f
+		}
+		if d, ok := days[serviceID]; !ok || d.weekdays || !d.weekends {
+			f.weekend = false
+		}
+		s := start.Int32 % (24 * 3600)
+		if s >= nightEnd && s < nightStart {
+			f.night = false
+		}
+		if !(s >= morningPeakStart && s < morningPeakEnd) && !(s >= eveningPeakStart && s < eveningPeakEnd) {
+			f.peak = false
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	patterns := make(map[string]ServicePattern, len(routes))
+	for routeID, f := range routes {
+		switch {
+		case f.weekend:
+			patterns[routeID] = WeekendOnly
+		case f.night:
+			patterns[routeID] = Night
+		case f.peak:
+			patterns[routeID] = PeakOnly
+		default:
+			patterns[routeID] = AllDay
+		}
+	}
+	return patterns, nil
+}
@@ -0,0 +1,50 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestClassifyRoutes(t *testing.T) {
+	db := newFixtureDB(t)
+
+	tests := []struct {
+		name   string
+		update string
+		r1     gtfs.ServicePattern
+	}{
+		{
+			name: "R1 runs from 08:00 to 09:14",
+			r1:   gtfs.AllDay,
+		},
+		{
+			name:   "R1 runs during morning peak",
+			update: "UPDATE stop_times SET departure = 8 * 3600 + 40 * 60 WHERE trip_id = 'T3' AND stop_seq = 1",
+			r1:     gtfs.PeakOnly,
+		},
+		{
+			name:   "R1 runs past midnight",
+			update: "UPDATE stop_times SET departure = departure + 16 * 3600 WHERE trip_id IN ('T1', 'T2', 'T3')",
+			r1:     gtfs.Night,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.update != "" {
+				db.Exec(tt.update)
+			}
+			got, err := gtfs.ClassifyRoutes(db)
+			if err != nil {
+				t.Fatalf("ClassifyRoutes() error = %v", err)
+			}
+			if got["R1"] != tt.r1 {
+				t.Errorf("ClassifyRoutes() R1 = %v, want %v", got["R1"], tt.r1)
+			}
+
+			// R2 only runs on weekends (and a holiday)
+			if got["R2"] != gtfs.WeekendOnly {
+				t.Errorf("ClassifyRoutes() R2 = %v, want %v", got["R2"], gtfs.WeekendOnly)
+			}
+		})
+	}
+}
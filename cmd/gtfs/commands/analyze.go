@@ -0,0 +1,150 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+func gtfsAnalyzeDirections(cmd *cobra.Command, args []string) error {
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	directions, err := gtfs.AnalyzeDirections(db)
+	if err != nil {
+		return fmt.Errorf("failed to analyze directions: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ROUTE\tDIRECTION\tLABEL\tTRIPS")
+	for _, d := range directions {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", d.RouteID, d.DirectionID, d.Label, d.Trips)
+	}
+	return w.Flush()
+}
+
+func gtfsAnalyzeHeadways(cmd *cobra.Command, args []string) error {
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	patterns, err := gtfs.DetectHeadways(db)
+	if err != nil {
+		return fmt.Errorf("failed to detect headways: %w", err)
+	}
+	tf, err := newTimeFormatter(cmd, db)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ROUTE\tSERVICE\tSTART\tEND\tHEADWAY\tTRIPS")
+	for _, p := range patterns {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%ds\t%d\n", p.RouteID, p.ServiceID, tf.formatOffset(p.StartTime), tf.formatOffset(p.EndTime), p.HeadwaySecs, len(p.TripIDs))
+	}
+	return w.Flush()
+}
+
+func gtfsAnalyzeFrequencies(cmd *cobra.Command, args []string) error {
+	bucket, err := cmd.Flags().GetDuration("bucket")
+	if err != nil {
+		return err
+	}
+	refresh, err := cmd.Flags().GetBool("refresh")
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	if refresh {
+		dates, err := gtfs.RefreshFrequencyBuckets(db)
+		if err != nil {
+			return err
+		}
+		log.Printf("refreshed the frequency buckets of %d days", len(dates))
+		return nil
+	}
+	if err = gtfs.BuildFrequencyBuckets(db, bucket); err != nil {
+		return fmt.Errorf("failed to build frequency buckets: %w", err)
+	}
+	log.Printf("built frequency buckets of %s", bucket)
+	return nil
+}
+
+func gtfsAnalyzeDemand(cmd *cobra.Command, args []string) error {
+	var opts gtfs.DemandOptions
+	var err error
+	if opts.Radius, err = cmd.Flags().GetFloat64("radius"); err != nil {
+		return err
+	}
+	if opts.Decay, err = cmd.Flags().GetFloat64("decay"); err != nil {
+		return err
+	}
+	if opts.Total, err = cmd.Flags().GetFloat64("total"); err != nil {
+		return err
+	}
+	out, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	dm, err := gtfs.GenerateDemand(db, opts)
+	if err != nil {
+		return fmt.Errorf("failed to generate demand: %w", err)
+	}
+
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	if err = dm.WriteCSV(file); err != nil {
+		return err
+	}
+	if err = file.Close(); err != nil {
+		return err
+	}
+	log.Printf("wrote demand between %d stops to '%s'", len(dm.Stops), out)
+	return nil
+}
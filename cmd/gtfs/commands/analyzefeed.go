@@ -0,0 +1,24 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+)
+
+func gtfsAnalyzeFeed(cmd *cobra.Command, args []string) error {
+	gate, err := validationGate(cmd)
+	if err != nil {
+		return err
+	}
+
+	fs, err := gtfs.AnalyzeFeed(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to analyze feed: %w", err)
+	}
+	fmt.Print(fs.String())
+	for _, i := range fs.Issues {
+		fmt.Println(i.String())
+	}
+	return gate.Check(fs.Issues)
+}
@@ -0,0 +1,164 @@
+package commands
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"net/http"
+	"strings"
+)
+
+// agencyResponse is the type used to describe an agency (and how to contact
+// it) in API responses.
+type agencyResponse struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Timezone string `json:"timezone,omitempty"`
+	Lang     string `json:"lang,omitempty"`
+	Phone    string `json:"phone,omitempty"`
+	FareURL  string `json:"fare_url,omitempty"`
+	Email    string `json:"email,omitempty"`
+}
+
+// newAgencyResponse converts an agency into its API representation (with its
+// name translated by t).
+func newAgencyResponse(a gtfs.Agency, t translator) agencyResponse {
+	return agencyResponse{
+		ID:       a.ID,
+		Name:     t.translate("agency", "agency_name", a.ID, a.Name),
+		URL:      a.URL,
+		Timezone: a.Timezone,
+		Lang:     a.Lang,
+		Phone:    a.Phone,
+		FareURL:  a.FareURL,
+		Email:    a.Email,
+	}
+}
+
+// routeResponse is the type used to describe a route (along with its
+// branding) in API responses.
+type routeResponse struct {
+	ID        string `json:"id"`
+	AgencyID  string `json:"agency_id"`
+	ShortName string `json:"short_name"`
+	LongName  string `json:"long_name"`
+	Type      int    `json:"type"`
+	Color     string `json:"color"`
+	TextColor string `json:"text_color"`
+}
+
+// newRouteResponse converts a route into its API representation (defaulting
+// colors as specified by GTFS and with its names translated by t).
+func newRouteResponse(r gtfs.Route, t translator) routeResponse {
+	color, textColor := r.RouteColors()
+	return routeResponse{
+		ID:        r.ID,
+		AgencyID:  r.AgencyID,
+		ShortName: t.translate("routes", "route_short_name", r.ID, r.ShortName),
+		LongName:  t.translate("routes", "route_long_name", r.ID, r.LongName),
+		Type:      r.Type,
+		Color:     color,
+		TextColor: textColor,
+	}
+}
+
+// agencies lists all agencies (if the path ends with "/agencies") or
+// describes the agency with the ID given by the path (both served from the
+// catalog of the feed).
+func (s *server) agencies(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	f, release := s.acquire()
+	defer release()
+	t := newTranslator(w, r, f)
+
+	id := strings.TrimPrefix(r.URL.Path, s.prefix+"/agencies/")
+	if id == r.URL.Path {
+		agencies := f.catalog.Agencies()
+		resp := make([]agencyResponse, len(agencies))
+		for i, a := range agencies {
+			resp[i] = newAgencyResponse(a, t)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	agency, ok := f.catalog.Agency(id)
+	if !ok {
+		writeError(w, gorm.ErrRecordNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, newAgencyResponse(agency, t))
+}
+
+// routes lists all routes (if the path ends with "/routes", optionally
+// filtered by the query parameter "agency"), describes the route with the ID
+// given by the path (both served from the catalog of the feed) or returns the
+// map of the route as GeoJSON (if the path ends with "/geojson").
+func (s *server) routes(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	f, release := s.acquire()
+	defer release()
+	t := newTranslator(w, r, f)
+
+	id := strings.TrimPrefix(r.URL.Path, s.prefix+"/routes/")
+	if id == r.URL.Path {
+		routes := f.catalog.Routes(r.URL.Query().Get("agency"))
+		resp := make([]routeResponse, len(routes))
+		for i, route := range routes {
+			resp[i] = newRouteResponse(route, t)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	// the map of the route (shapes and stops per direction)
+	if routeID := strings.TrimSuffix(id, "/geojson"); routeID != id {
+		fc, err := gtfs.RouteGeoJSON(f.db.WithContext(r.Context()), routeID)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		for _, feature := range fc.Features {
+			if feature.Properties["kind"] == "stop" {
+				stopID := feature.Properties["stop_id"].(string)
+				feature.Properties["name"] = t.translate("stops", "stop_name", stopID, feature.Properties["name"].(string))
+			}
+		}
+		w.Header().Set("Content-Type", "application/geo+json")
+		w.WriteHeader(http.StatusOK)
+		_ = json.NewEncoder(w).Encode(fc)
+		return
+	}
+
+	route, ok := f.catalog.Route(id)
+	if !ok {
+		writeError(w, gorm.ErrRecordNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, newRouteResponse(route, t))
+}
+
+// allowGet responds with 405, unless the request is a GET request.
+func allowGet(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodGet {
+		return true
+	}
+	w.Header().Set("Allow", http.MethodGet)
+	writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+	return false
+}
+
+// writeError writes err as response (404, if a record was not found).
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		writeJSON(w, http.StatusNotFound, status{Status: "error", Error: "not found"})
+		return
+	}
+	writeJSON(w, http.StatusInternalServerError, status{Status: "error", Error: err.Error()})
+}
@@ -0,0 +1,155 @@
+package commands
+
+import (
+	"github.com/heimdalr/gtfs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_Agencies(t *testing.T) {
+	_, h := newTestServer(t, "", "", "")
+	var agencies []agencyResponse
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/agencies", ""), http.StatusOK, &agencies)
+	if len(agencies) != 2 || agencies[0].ID != "1" || agencies[1].ID != "2" {
+		t.Fatalf("GET /agencies = %+v, want agencies 1 and 2", agencies)
+	}
+
+	var agency agencyResponse
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/agencies/1", ""), http.StatusOK, &agency)
+	want := agencyResponse{
+		ID:       "1",
+		Name:     "S-Bahn Berlin GmbH",
+		URL:      "https://sbahn.berlin/",
+		Timezone: "Europe/Berlin",
+		Lang:     "de",
+		Phone:    "030 297 43333",
+		FareURL:  "https://sbahn.berlin/tickets/",
+	}
+	if agency != want {
+		t.Errorf("GET /agencies/1 = %+v, want %+v", agency, want)
+	}
+
+	if rec := serveRequest(h, http.MethodGet, "/agencies/3", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /agencies/3 = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+	if rec := serveRequest(h, http.MethodPost, "/agencies", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /agencies = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestServer_Routes(t *testing.T) {
+	_, h := newTestServer(t, "", "", "")
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"/routes", []string{"R1", "R2"}},
+		{"/routes?agency=2", []string{"R2"}},
+		{"/routes?agency=3", nil},
+	}
+	for _, tt := range tests {
+		var routes []routeResponse
+		decodeResponse(t, serveRequest(h, http.MethodGet, tt.target, ""), http.StatusOK, &routes)
+		if len(routes) != len(tt.want) {
+			t.Errorf("GET %s = %+v, want %v", tt.target, routes, tt.want)
+			continue
+		}
+		for i := range routes {
+			if routes[i].ID != tt.want[i] {
+				t.Errorf("GET %s = %+v, want %v", tt.target, routes, tt.want)
+			}
+		}
+	}
+
+	// colors are defaulted as specified by GTFS
+	var route routeResponse
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/routes/R1", ""), http.StatusOK, &route)
+	if route.ShortName != "S1" || route.Color != "008D4F" || route.TextColor != "FFFFFF" {
+		t.Errorf("GET /routes/R1 = %+v", route)
+	}
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/routes/R2", ""), http.StatusOK, &route)
+	if route.Color != "FFFFFF" || route.TextColor != "000000" {
+		t.Errorf("GET /routes/R2 = %+v, want default colors", route)
+	}
+	if rec := serveRequest(h, http.MethodGet, "/routes/R3", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /routes/R3 = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestServer_RouteGeoJSON(t *testing.T) {
+	_, h := newTestServer(t, "", "", "")
+	rec := serveRequest(h, http.MethodGet, "/routes/R1/geojson", "")
+	if ct := rec.Header().Get("Content-Type"); ct != "application/geo+json" {
+		t.Errorf("GET /routes/R1/geojson content type = %s", ct)
+	}
+	var fc gtfs.FeatureCollection
+	decodeResponse(t, rec, http.StatusOK, &fc)
+	var stops []string
+	for _, f := range fc.Features {
+		if f.Properties["kind"] == "stop" && f.Properties["direction_id"] == "0" {
+			stops = append(stops, f.Properties["stop_id"].(string))
+		}
+	}
+	if len(stops) != 4 || stops[0] != "S1" || stops[3] != "S4" {
+		t.Errorf("GET /routes/R1/geojson stops = %v, want S1 to S4", stops)
+	}
+
+	if rec = serveRequest(h, http.MethodGet, "/routes/R3/geojson", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /routes/R3/geojson = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestServer_Translations(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+	feed := t.TempDir()
+	translations := "table_name,field_name,language,translation,record_id\n" +
+		"stops,stop_name,en,Wannsee Station,S1\n" +
+		"routes,route_long_name,en,Wannsee - Steglitz Town Hall,R1\n"
+	if err := os.WriteFile(filepath.Join(feed, "translations.txt"), []byte(translations), 0o644); err != nil {
+		t.Fatalf("failed to write translations: %v", err)
+	}
+	if _, err := gtfs.ImportTranslations(s.feed.db, feed); err != nil {
+		t.Fatalf("ImportTranslations() error = %v", err)
+	}
+
+	// translations are loaded once per feed, i.e. when reloading
+	if rec := serveRequest(h, http.MethodPost, "/admin/reload", ""); rec.Code != http.StatusOK {
+		t.Fatalf("POST /admin/reload = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		wantStop       string
+		wantRoute      string
+		wantLanguage   string
+	}{
+		{"default", "", "S Wannsee", "S Wannsee - S Rathaus Steglitz", ""},
+		{"english", "en-US,en;q=0.9", "Wannsee Station", "Wannsee - Steglitz Town Hall", "en"},
+		{"fallback", "fr", "S Wannsee", "S Wannsee - S Rathaus Steglitz", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/stops/S1", nil)
+			if tt.acceptLanguage != "" {
+				req.Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			rec := serve(h, req)
+			var stop stopResponse
+			decodeResponse(t, rec, http.StatusOK, &stop)
+			if stop.Name != tt.wantStop || rec.Header().Get("Content-Language") != tt.wantLanguage {
+				t.Errorf("GET /stops/S1 = %q (%q), want %q (%q)", stop.Name, rec.Header().Get("Content-Language"), tt.wantStop, tt.wantLanguage)
+			}
+
+			req.URL.Path = "/routes/R1"
+			var route routeResponse
+			decodeResponse(t, serve(h, req), http.StatusOK, &route)
+			if route.LongName != tt.wantRoute {
+				t.Errorf("GET /routes/R1 long name = %q, want %q", route.LongName, tt.wantRoute)
+			}
+		})
+	}
+}
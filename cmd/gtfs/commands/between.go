@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"os"
+	"text/tabwriter"
+)
+
+func gtfsRoutesBetween(cmd *cobra.Command, args []string) error {
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	routes, err := gtfs.RoutesBetween(db, args[1], args[2])
+	if err != nil {
+		return fmt.Errorf("failed to find routes: %w", err)
+	}
+	if len(routes) == 0 {
+		fmt.Printf("no route directly connects %s to %s\n", args[1], args[2])
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "ROUTE\tNAME\tDIRECTION\tHEADSIGN\tTRIPS\tTRAVEL TIME")
+	for _, r := range routes {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", r.RouteID, r.RouteName, r.DirectionID, r.Headsign, r.Trips, r.TravelTime)
+	}
+	return w.Flush()
+}
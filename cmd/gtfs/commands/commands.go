@@ -1,8 +1,19 @@
 package commands
 
 import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
 	"github.com/spf13/cobra"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"log"
+	"os"
+	"time"
 )
 
 // NewRootCmd initializes the root command.
@@ -15,6 +26,12 @@ func NewRootCmd(buildVersion, buildGitHash string) *cobra.Command {
 		RunE:  gtfsTrim,
 		Args:  cobra.ExactArgs(2),
 	}
+	gtfsTrimCmd.Flags().StringSlice("keep-stop", nil, "ID of a stop to keep, even if no remaining trip serves it (may be repeated)")
+	gtfsTrimCmd.Flags().String("plan", "", "write the plan (statements and expected affected rows) as JSON to the given file (- for stdout) before trimming")
+	gtfsTrimCmd.Flags().Bool("dry-run", false, "only plan the trim, without modifying the DB")
+	gtfsTrimCmd.Flags().Bool("optimize", false, "rebuild the trimmed tables on MySQL (OPTIMIZE TABLE) to reclaim the space of the deleted rows")
+	addRetryFlags(gtfsTrimCmd, "statements")
+	addAsyncFlags(gtfsTrimCmd)
 
 	gtfsImportCmd := &cobra.Command{
 		Use:   "import <gtfsBasePath> <dbPath>",
@@ -23,6 +40,286 @@ func NewRootCmd(buildVersion, buildGitHash string) *cobra.Command {
 		RunE:  gtfsImport,
 		Args:  cobra.ExactArgs(2),
 	}
+	gtfsImportCmd.Flags().String("report", "", "write an import report to the given file (HTML, if it ends with .html, JSON otherwise)")
+	gtfsImportCmd.Flags().String("source-url", "", "record the URL the feed was retrieved from")
+	gtfsImportCmd.Flags().String("license", "", "record the license of the feed (e.g. CC-BY-4.0)")
+	gtfsImportCmd.Flags().String("terms", "", "record the terms of use of the feed")
+	gtfsImportCmd.Flags().String("retrieved-at", "", "record the time (RFC 3339) the feed was retrieved at (defaults to now)")
+	gtfsImportCmd.Flags().StringSlice("on-conflict", nil, "handling of repeated IDs: error, skip or replace, for all or a single file (e.g. stops=skip)")
+	gtfsImportCmd.Flags().Bool("skip-failed-rows", false, "retry batches failing to insert row by row, rejecting only the failing rows")
+	gtfsImportCmd.Flags().Int64("max-rows", 0, "import at most the given number of rows per file (0 for no limit)")
+	gtfsImportCmd.Flags().Duration("max-duration", 0, "stop importing (keeping the rows imported so far) after the given duration (0 for no limit)")
+	gtfsImportCmd.Flags().String("delimiter", "", "the delimiter of the CSV files (e.g. ';' or 'tab', detected per file by default)")
+	gtfsImportCmd.Flags().String("encoding", "utf-8", "the encoding of the files: utf-8, latin-1 or auto (latin-1 unless the beginning of a file is valid utf-8)")
+	gtfsImportCmd.Flags().Bool("decimal-comma", false, "accept decimal commas in numbers (e.g. 52,5213), as written in many locales")
+	gtfsImportCmd.Flags().Bool("lenient", false, "accept malformed CSV files (stray quotes, rows with more or fewer values than the header)")
+	gtfsImportCmd.Flags().String("mode", "default", "handling of malformed rows: default (fail), strict (fail, also on missing required columns) or lenient (skip)")
+	gtfsImportCmd.Flags().String("skipped-rows", "", "write the rows failing to parse (with line, raw content and error) as CSV to the given file (implies --mode lenient)")
+	gtfsImportCmd.Flags().Int("batch-size", 1000, "the number of rows inserted per statement")
+	gtfsImportCmd.Flags().StringSlice("only", nil, "import only the given files (e.g. stops,routes), skipping the other files")
+	gtfsImportCmd.Flags().Bool("no-transactions", false, "import files without transactions (leaving files failing midway partially imported)")
+	gtfsImportCmd.Flags().Int("insert-workers", 0, "the number of goroutines inserting the rows of each file while parsing it (0 inserts while parsing)")
+	gtfsImportCmd.Flags().Int("workers", 1, "the number of files imported concurrently (requires --driver postgres or mysql)")
+	gtfsImportCmd.Flags().String("csv-decoder", "gocsv", "the CSV decoder to use: gocsv (most compatible) or fast (considerably faster on large files)")
+	gtfsImportCmd.Flags().Bool("encode-shapes", false, "store shapes as encoded polylines (one row per shape) rather than as rows of points")
+	gtfsImportCmd.Flags().Bool("prepare-stmt", false, "prepare the statements inserting rows once and reuse them across batches")
+	gtfsImportCmd.Flags().Bool("progress", false, "show a live progress bar (percentage, rows and time left) while importing each file")
+	gtfsImportCmd.Flags().Bool("ignore-space", false, "import even if the estimated DB exceeds the free space at its destination")
+	gtfsImportCmd.Flags().Bool("fts5", false, "build the search indexes using SQLite FTS5 (requires building with -tags sqlite_fts5)")
+	gtfsImportCmd.Flags().String("profile", "", "normalize known quirks of feeds (delfi, nl or transitfeeds-generic)")
+	gtfsImportCmd.Flags().Bool("fill-route-names", false, "fill empty route short names with the long names and vice versa (reporting the routes adjusted)")
+	gtfsImportCmd.Flags().Bool("compare", false, "report the stability of stop, route and trip IDs compared to the DB being replaced")
+	gtfsImportCmd.Flags().String("rejects", "", "write the rows skipped, replaced, rejected, normalized or malformed (with reasons) as CSV to the given file")
+	addRetryFlags(gtfsImportCmd, "files")
+	addAsyncFlags(gtfsImportCmd)
+
+	gtfsExportCmd := &cobra.Command{
+		Use:   "export <dbPath> <gtfsBasePath>",
+		Short: "Export an SQLite DB to GTFS data files",
+		Long:  ``,
+		RunE:  gtfsExport,
+		Args:  cobra.ExactArgs(2),
+	}
+	gtfsExportCmd.Flags().Bool("compress-headways", false, "represent trips running at regular headways by frequencies")
+	gtfsExportCmd.Flags().Bool("sanitize", false, "neutralize text values spreadsheets would interpret as formulas")
+	gtfsExportCmd.Flags().Bool("duckdb", false, "export all tables for DuckDB (loaded by IMPORT DATABASE) rather than GTFS files")
+	addAsyncFlags(gtfsExportCmd)
+
+	gtfsServeCmd := &cobra.Command{
+		Use:   "serve [dbPath]",
+		Short: "Serve a GTFS DB (or multiple feed DBs) via HTTP",
+		Long:  ``,
+		RunE:  gtfsServe,
+		Args:  cobra.MaximumNArgs(1),
+	}
+	gtfsServeCmd.Flags().String("addr", ":8080", "address to listen on")
+	gtfsServeCmd.Flags().Duration("shutdown-timeout", 30*time.Second, "time to wait for in-flight requests on shutdown")
+	gtfsServeCmd.Flags().String("import-from", "", "directory of GTFS files to import on POST /admin/import (with --feeds, holding a subdirectory per feed)")
+	gtfsServeCmd.Flags().StringToString("feeds", nil, "serve multiple feed DBs, each below the path prefix /<name> (e.g. city1=./a.db,city2=./b.db)")
+	gtfsServeCmd.Flags().StringSlice("min-rows", nil, "refuse to swap in imports with fewer rows than the given percentage of the served feed, per file (e.g. stop_times=90)")
+	gtfsServeCmd.Flags().Int("keep-snapshots", 0, "archive the DB replaced by POST /admin/import, keeping the given number of snapshots (0 disables, see gtfs history)")
+	gtfsServeCmd.Flags().String("export-to", "", "directory export jobs export below (with --feeds, holding a subdirectory per feed)")
+	addAdminTokenFlag(gtfsServeCmd, "bearer token required by admin requests (e.g. POST /admin/import)")
+
+	gtfsValidateCmd := &cobra.Command{
+		Use:   "validate <dbPath>",
+		Short: "Validate a GTFS DB",
+		Long:  ``,
+		RunE:  gtfsValidate,
+		Args:  cobra.ExactArgs(1),
+	}
+	addValidationGateFlags(gtfsValidateCmd)
+	addAsyncFlags(gtfsValidateCmd)
+	gtfsValidateCmd.Flags().Float64("max-shape-distance", gtfs.DefaultMaxShapeDistance, "the distance (in meters) stops may lie off the shapes of their trips")
+
+	gtfsJobsCmd := &cobra.Command{
+		Use:   "jobs [jobID]",
+		Short: "List the jobs of a running server (or show a single job)",
+		Long:  ``,
+		RunE:  gtfsJobs,
+		Args:  cobra.MaximumNArgs(1),
+	}
+	gtfsJobsCmd.Flags().String("server", "http://localhost:8080", "URL of the server")
+	gtfsJobsCmd.Flags().Bool("cancel", false, "cancel the given job")
+	addAdminTokenFlag(gtfsJobsCmd, "bearer token to authenticate with the server")
+
+	gtfsHistoryCmd := &cobra.Command{
+		Use:   "history <dbPath>",
+		Short: "List the archived snapshots of a GTFS DB (or roll back to one of them)",
+		Long:  ``,
+		RunE:  gtfsHistory,
+		Args:  cobra.ExactArgs(1),
+	}
+	gtfsHistoryCmd.Flags().String("rollback", "", "replace the DB by the snapshot with the given ID (archiving the current DB)")
+	gtfsHistoryCmd.Flags().Int("keep", 10, "the number of snapshots to keep when archiving the current DB on rollback")
+	gtfsHistoryCmd.Flags().Bool("reload", false, "ask the server serving the DB to reload it after rolling back")
+	gtfsHistoryCmd.Flags().String("server", "http://localhost:8080", "URL of the server")
+	addAdminTokenFlag(gtfsHistoryCmd, "bearer token to authenticate with the server")
+
+	gtfsStatsCmd := &cobra.Command{
+		Use:   "stats <dbPath>",
+		Short: "Print statistics of a GTFS DB",
+		Long:  ``,
+		RunE:  gtfsStats,
+		Args:  cobra.ExactArgs(1),
+	}
+	gtfsStatsCmd.Flags().Bool("routes", false, "list the pattern of service and the average speed of each route")
+	gtfsStatsCmd.Flags().Bool("by-agency", false, "list the routes per mode, the trips per weekday and the stops served of each agency")
+
+	gtfsDeparturesCmd := &cobra.Command{
+		Use:   "departures <dbPath> <stopID>",
+		Short: "List the departures from a stop",
+		Long:  ``,
+		RunE:  gtfsDepartures,
+		Args:  cobra.ExactArgs(2),
+	}
+	addDepartureFlags(gtfsDeparturesCmd)
+	addTimeFlags(gtfsDeparturesCmd)
+
+	gtfsRidershipCmd := &cobra.Command{
+		Use:   "ridership <dbPath> <csvPath>",
+		Short: "Import ridership counts (stop_id, boardings, alightings) per stop",
+		Long:  ``,
+		RunE:  gtfsRidership,
+		Args:  cobra.ExactArgs(2),
+	}
+
+	gtfsAmenitiesCmd := &cobra.Command{
+		Use:   "amenities <dbPath> <csvPath>",
+		Short: "Import amenities (stop_id, shelter, bench, lighting, realtime_display) per stop",
+		Long:  ``,
+		RunE:  gtfsAmenities,
+		Args:  cobra.ExactArgs(2),
+	}
+
+	gtfsAnalyzeDirectionsCmd := &cobra.Command{
+		Use:   "directions <dbPath>",
+		Short: "Derive canonical direction labels for all routes",
+		Long:  ``,
+		RunE:  gtfsAnalyzeDirections,
+		Args:  cobra.ExactArgs(1),
+	}
+
+	gtfsAnalyzeHeadwaysCmd := &cobra.Command{
+		Use:   "headways <dbPath>",
+		Short: "Detect trips running at regular headways",
+		Long:  ``,
+		RunE:  gtfsAnalyzeHeadways,
+		Args:  cobra.ExactArgs(1),
+	}
+	addTimeFlags(gtfsAnalyzeHeadwaysCmd)
+
+	gtfsAnalyzeFrequenciesCmd := &cobra.Command{
+		Use:   "frequencies <dbPath>",
+		Short: "Materialize the trips per route and per stop within time buckets of each service day (e.g. for dashboards)",
+		Long:  ``,
+		RunE:  gtfsAnalyzeFrequencies,
+		Args:  cobra.ExactArgs(1),
+	}
+	gtfsAnalyzeFrequenciesCmd.Flags().Duration("bucket", 15*time.Minute, "width of the time buckets")
+	gtfsAnalyzeFrequenciesCmd.Flags().Bool("refresh", false, "rebuild only the days whose services changed (e.g. by updated calendar dates) since the buckets were built")
+
+	gtfsAnalyzeDemandCmd := &cobra.Command{
+		Use:   "demand <dbPath>",
+		Short: "Generate origin-destination demand seeds from stop density and service frequency",
+		Long:  ``,
+		RunE:  gtfsAnalyzeDemand,
+		Args:  cobra.ExactArgs(1),
+	}
+	gtfsAnalyzeDemandCmd.Flags().String("out", "demand.csv", "path of the CSV file (origin_stop_id, destination_stop_id, trips) to write")
+	gtfsAnalyzeDemandCmd.Flags().Float64("radius", 500, "radius (in meters) within which stops count towards the density around a stop")
+	gtfsAnalyzeDemandCmd.Flags().Float64("decay", 2000, "distance (in meters) at which demand decays to 1/e")
+	gtfsAnalyzeDemandCmd.Flags().Float64("total", 10000, "total number of trips to distribute")
+
+	gtfsAnalyzeExceptionsCmd := &cobra.Command{
+		Use:   "exceptions <dbPath>",
+		Short: "Summarize the days deviating from the regular service (e.g. holidays)",
+		Long:  ``,
+		RunE:  gtfsAnalyzeExceptions,
+		Args:  cobra.ExactArgs(1),
+	}
+	addDateRangeFlags(gtfsAnalyzeExceptionsCmd)
+
+	gtfsAnalyzeCompareCmd := &cobra.Command{
+		Use:   "compare <dbPath> <baseDate> <otherDate>",
+		Short: "Diff the trips running per route on two dates (e.g. a weekday and a holiday)",
+		Long:  ``,
+		RunE:  gtfsAnalyzeCompare,
+		Args:  cobra.ExactArgs(3),
+	}
+
+	gtfsAnalyzeFeedCmd := &cobra.Command{
+		Use:   "feed <gtfsBasePath>",
+		Short: "Compute stats and find orphaned records directly from GTFS files (without importing them)",
+		Long:  ``,
+		RunE:  gtfsAnalyzeFeed,
+		Args:  cobra.ExactArgs(1),
+	}
+	addValidationGateFlags(gtfsAnalyzeFeedCmd)
+
+	gtfsAnalyzeCmd := &cobra.Command{
+		Use:   "analyze",
+		Short: "Analyze a GTFS DB",
+		Long:  ``,
+	}
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeDirectionsCmd)
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeHeadwaysCmd)
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeFrequenciesCmd)
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeDemandCmd)
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeExceptionsCmd)
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeCompareCmd)
+	gtfsAnalyzeCmd.AddCommand(gtfsAnalyzeFeedCmd)
+
+	gtfsReplayCmd := &cobra.Command{
+		Use:   "replay <dbPath> <date>",
+		Short: "Print the departures and arrivals of all trips running on a day in order (e.g. for testing consumers or demos)",
+		Long:  ``,
+		RunE:  gtfsReplay,
+		Args:  cobra.ExactArgs(2),
+	}
+	gtfsReplayCmd.Flags().Float64("speedup", 0, "replay along a clock running the given times faster than real time (0 for as fast as possible)")
+	gtfsReplayCmd.Flags().StringSlice("route", nil, "replay the trips of the given routes only")
+
+	gtfsRoutesBetweenCmd := &cobra.Command{
+		Use:   "between <dbPath> <fromStopID> <toStopID>",
+		Short: "List the routes (and directions) directly connecting two stops along with the typical travel time",
+		Long:  ``,
+		RunE:  gtfsRoutesBetween,
+		Args:  cobra.ExactArgs(3),
+	}
+
+	gtfsRoutesLinesCmd := &cobra.Command{
+		Use:   "lines <dbPath>",
+		Short: "List the lines (i.e. the routes of an agency sharing a short name) along with their trips and stops",
+		Long:  ``,
+		RunE:  gtfsRoutesLines,
+		Args:  cobra.ExactArgs(1),
+	}
+	gtfsRoutesLinesCmd.Flags().String("agency", "", "only list the lines of the agency with the given ID")
+
+	gtfsRoutesCmd := &cobra.Command{
+		Use:   "routes",
+		Short: "Look up routes of a GTFS DB",
+		Long:  ``,
+	}
+	gtfsRoutesCmd.AddCommand(gtfsRoutesBetweenCmd)
+	gtfsRoutesCmd.AddCommand(gtfsRoutesLinesCmd)
+
+	gtfsRenderRouteCmd := &cobra.Command{
+		Use:   "route <dbPath> <routeID>",
+		Short: "Render the shapes and stops of a route to a PNG image",
+		Long:  ``,
+		RunE:  gtfsRenderRoute,
+		Args:  cobra.ExactArgs(2),
+	}
+	gtfsRenderRouteCmd.Flags().String("out", "route.png", "path of the PNG image to write")
+	gtfsRenderRouteCmd.Flags().Int("width", 800, "width of the image in pixels")
+	gtfsRenderRouteCmd.Flags().Int("height", 600, "height of the image in pixels")
+
+	gtfsRenderDiagramCmd := &cobra.Command{
+		Use:   "diagram <dbPath> <routeID>",
+		Short: "Render a schematic line diagram of a route to an SVG image",
+		Long:  ``,
+		RunE:  gtfsRenderDiagram,
+		Args:  cobra.ExactArgs(2),
+	}
+	gtfsRenderDiagramCmd.Flags().String("out", "diagram.svg", "path of the SVG image to write")
+
+	gtfsRenderCmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render (parts of) a GTFS DB",
+		Long:  ``,
+	}
+	gtfsRenderCmd.AddCommand(gtfsRenderRouteCmd)
+	gtfsRenderCmd.AddCommand(gtfsRenderDiagramCmd)
+
+	gtfsSchemaCmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Print the DDL creating the tables of a GTFS DB (of the driver given by --driver) without connecting to a DB",
+		Long:  ``,
+		RunE:  gtfsSchema,
+		Args:  cobra.NoArgs,
+	}
 
 	gtfsVersionCmd := &cobra.Command{
 		Use:   "version",
@@ -40,9 +337,178 @@ func NewRootCmd(buildVersion, buildGitHash string) *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 	}
+	rootCmd.PersistentFlags().Duration("slow-query", 0, "log queries slower than the given duration (0 disables)")
+	rootCmd.PersistentFlags().String("driver", "sqlite", "the driver of the DB: sqlite (the DB path is a file), postgres or mysql (the DB path is a DSN, e.g. postgres://gtfs@localhost/vbb or gtfs@tcp(localhost)/vbb)")
+	rootCmd.PersistentFlags().String("dsn", "", "the data source name of the DB (overriding the DB path, e.g. to keep credentials out of scripts)")
+	rootCmd.PersistentFlags().Duration("statement-timeout", 0, "cancel SQL statements running longer than the given duration (0 disables)")
 	rootCmd.AddCommand(gtfsImportCmd)
+	rootCmd.AddCommand(gtfsExportCmd)
 	rootCmd.AddCommand(gtfsTrimCmd)
+	rootCmd.AddCommand(gtfsServeCmd)
+	rootCmd.AddCommand(gtfsAnalyzeCmd)
+	rootCmd.AddCommand(gtfsRoutesCmd)
+	rootCmd.AddCommand(gtfsReplayCmd)
+	rootCmd.AddCommand(gtfsValidateCmd)
+	rootCmd.AddCommand(gtfsJobsCmd)
+	rootCmd.AddCommand(gtfsHistoryCmd)
+	rootCmd.AddCommand(gtfsStatsCmd)
+	rootCmd.AddCommand(gtfsDeparturesCmd)
+	rootCmd.AddCommand(gtfsRidershipCmd)
+	rootCmd.AddCommand(gtfsAmenitiesCmd)
+	rootCmd.AddCommand(gtfsRenderCmd)
+	rootCmd.AddCommand(gtfsSchemaCmd)
 	rootCmd.AddCommand(gtfsVersionCmd)
 
 	return rootCmd
 }
+
+// isServerDB returns true, if DBs are opened using the postgres or mysql
+// driver (see dialector), i.e. DBs are not files.
+func isServerDB(cmd *cobra.Command) (bool, error) {
+	driver, err := cmd.Flags().GetString("driver")
+	if err != nil {
+		return false, err
+	}
+	return isServerDriver(driver)
+}
+
+// isServerDriver returns true, if driver is postgres or mysql, i.e. DBs are
+// not files.
+func isServerDriver(driver string) (bool, error) {
+	switch driver {
+	case "", "sqlite":
+		return false, nil
+	case "postgres", "mysql":
+		return true, nil
+	default:
+		return false, fmt.Errorf("unknown driver '%s' (expected sqlite, postgres or mysql)", driver)
+	}
+}
+
+// dialector returns the dialector of the DB at dbPath using the driver given
+// by the driver flag. If the dsn flag is given, it replaces dbPath.
+func dialector(cmd *cobra.Command, dbPath string) (gorm.Dialector, error) {
+	driver, err := cmd.Flags().GetString("driver")
+	if err != nil {
+		return nil, err
+	}
+	dsn, err := cmd.Flags().GetString("dsn")
+	if err != nil {
+		return nil, err
+	}
+	if dsn == "" {
+		dsn = dbPath
+	}
+	return openDialector(driver, dsn)
+}
+
+// openDialector returns the dialector of the DB at dsn (a file path for
+// sqlite) using the given driver.
+func openDialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case "postgres":
+		return postgres.Open(dsn), nil
+	case "mysql":
+		return mysql.Open(dsn), nil
+	case "", "sqlite":
+		return sqlite.Open(dsn), nil
+	default:
+		return nil, fmt.Errorf("unknown driver '%s' (expected sqlite, postgres or mysql)", driver)
+	}
+}
+
+// registerPlugins registers a slow query logger and a statement timeout with
+// db, if requested via the slow-query and statement-timeout flags.
+func registerPlugins(cmd *cobra.Command, db *gorm.DB) error {
+	threshold, err := cmd.Flags().GetDuration("slow-query")
+	if err != nil {
+		return err
+	}
+	if threshold > 0 {
+		if err = db.Use(&gtfs.SlowQueryLogger{Threshold: threshold}); err != nil {
+			return err
+		}
+	}
+	timeout, err := cmd.Flags().GetDuration("statement-timeout")
+	if err != nil {
+		return err
+	}
+	if timeout > 0 {
+		return db.Use(&gtfs.StatementTimeout{Timeout: timeout})
+	}
+	return nil
+}
+
+// addRetryFlags adds the flags configuring the retry policy (see retryPolicy)
+// to cmd.
+func addRetryFlags(cmd *cobra.Command, operation string) {
+	cmd.Flags().Int("retries", 0, "retry "+operation+" failing with transient errors (e.g. a busy DB) up to the given number of times")
+	cmd.Flags().Duration("retry-backoff", time.Second, "the delay before the first retry (doubling with each further retry)")
+	cmd.Flags().Duration("timeout", 0, "fail "+operation+" taking longer than the given duration (0 for no limit)")
+}
+
+// retryPolicy returns the retry policy configured by the flags added by
+// addRetryFlags (nil, if none).
+func retryPolicy(cmd *cobra.Command) (*gtfs.RetryPolicy, error) {
+	retries, err := cmd.Flags().GetInt("retries")
+	if err != nil {
+		return nil, err
+	}
+	backoff, err := cmd.Flags().GetDuration("retry-backoff")
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := cmd.Flags().GetDuration("timeout")
+	if err != nil {
+		return nil, err
+	}
+	if retries <= 0 && timeout <= 0 {
+		return nil, nil
+	}
+	return &gtfs.RetryPolicy{Attempts: retries + 1, Backoff: backoff, Timeout: timeout}, nil
+}
+
+// openDB opens the existing GTFS DB at dbPath. The returned function closes the
+// DB.
+func openDB(cmd *cobra.Command, dbPath string) (*gorm.DB, func(), error) {
+
+	// some argument validation
+	if dbPath == "" {
+		return nil, nil, errors.New("empty dbPath")
+	}
+
+	// don't let sqlite silently create an empty DB
+	server, err := isServerDB(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err = os.Stat(dbPath); err != nil && !server {
+		return nil, nil, err
+	}
+
+	// open gorm db
+	dial, err := dialector(cmd, dbPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	db, err := gorm.Open(dial, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var sqlDB *sql.DB
+	sqlDB, err = db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	closeDB := func() {
+		_ = sqlDB.Close()
+	}
+	if err = registerPlugins(cmd, db); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+
+	return db, closeDB, nil
+}
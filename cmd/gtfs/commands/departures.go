@@ -0,0 +1,214 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// addDepartureFlags adds the flags filtering departures to cmd.
+func addDepartureFlags(cmd *cobra.Command) {
+	cmd.Flags().String("at", "", "time (RFC 3339) to list departures from (defaults to now)")
+	cmd.Flags().Duration("window", time.Hour, "time span to list departures for")
+	cmd.Flags().Int("limit", 0, "maximum number of departures to list (0 for no limit)")
+	cmd.Flags().Bool("accessible", false, "only list trips accessible by wheelchair (from stops allowing wheelchair boarding)")
+	cmd.Flags().Bool("bikes", false, "only list trips allowing bikes")
+	cmd.Flags().Bool("station", false, "list the departures from all stops (e.g. platforms) of the station of the stop")
+	cmd.Flags().StringSlice("exclude-services", nil, "exclude trips of services of the given categories (school-days, holidays or regular)")
+}
+
+// departureFlags returns the time and the options given by the flags added by
+// addDepartureFlags.
+func departureFlags(cmd *cobra.Command) (time.Time, gtfs.DeparturesOptions, error) {
+	var opts gtfs.DeparturesOptions
+	at, err := cmd.Flags().GetString("at")
+	if err != nil {
+		return time.Time{}, opts, err
+	}
+	from := time.Now()
+	if at != "" {
+		if from, err = time.Parse(time.RFC3339, at); err != nil {
+			return time.Time{}, opts, fmt.Errorf("failed to parse time: %w", err)
+		}
+	}
+	if opts.Window, err = cmd.Flags().GetDuration("window"); err != nil {
+		return time.Time{}, opts, err
+	}
+	if opts.Limit, err = cmd.Flags().GetInt("limit"); err != nil {
+		return time.Time{}, opts, err
+	}
+	if opts.Accessible, err = cmd.Flags().GetBool("accessible"); err != nil {
+		return time.Time{}, opts, err
+	}
+	if opts.Bikes, err = cmd.Flags().GetBool("bikes"); err != nil {
+		return time.Time{}, opts, err
+	}
+	if opts.Station, err = cmd.Flags().GetBool("station"); err != nil {
+		return time.Time{}, opts, err
+	}
+	exclude, err := cmd.Flags().GetStringSlice("exclude-services")
+	if err != nil {
+		return time.Time{}, opts, err
+	}
+	if opts.ExcludeCategories, err = parseServiceCategories(exclude); err != nil {
+		return time.Time{}, opts, err
+	}
+	return from, opts, nil
+}
+
+func gtfsDepartures(cmd *cobra.Command, args []string) error {
+	from, opts, err := departureFlags(cmd)
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	tf, err := newTimeFormatter(cmd, db)
+	if err != nil {
+		return err
+	}
+	departures, err := gtfs.Departures(db, args[1], from, tf.feedTZ, opts)
+	if err != nil {
+		return fmt.Errorf("failed to list departures: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tROUTE\tHEADSIGN\tPLATFORM\tTRIP")
+	for _, d := range departures {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", tf.format(d.Time, from.In(tf.tz)), d.RouteName, d.Headsign, d.Platform, d.TripID)
+	}
+	return w.Flush()
+}
+
+// departureResponse is the type used to describe a departure in API
+// responses.
+type departureResponse struct {
+	Time      time.Time          `json:"time"`
+	StopID    string             `json:"stop_id"`
+	StopName  string             `json:"stop_name"`
+	StopSeq   int                `json:"stop_sequence"`
+	TripID    string             `json:"trip_id"`
+	RouteID   string             `json:"route_id"`
+	RouteName string             `json:"route_name"`
+	Headsign  string             `json:"headsign"`
+	Platform  string             `json:"platform,omitempty"`
+	Occupancy *occupancyResponse `json:"occupancy,omitempty"`
+}
+
+// parseDeparturesQuery parses the time and the options of a departures
+// request from the query parameters "at" (RFC 3339, defaults to now),
+// "window" (e.g. "30m"), "limit", "accessible", "bikes", "station" and
+// "exclude_services" (e.g. "school-days,holidays").
+func parseDeparturesQuery(q url.Values) (time.Time, gtfs.DeparturesOptions, error) {
+	var opts gtfs.DeparturesOptions
+	var err error
+	from := time.Now()
+	if at := q.Get("at"); at != "" {
+		if from, err = time.Parse(time.RFC3339, at); err != nil {
+			return from, opts, fmt.Errorf("invalid time '%s'", at)
+		}
+	}
+	if window := q.Get("window"); window != "" {
+		if opts.Window, err = time.ParseDuration(window); err != nil {
+			return from, opts, fmt.Errorf("invalid window '%s'", window)
+		}
+	}
+	if limit := q.Get("limit"); limit != "" {
+		if opts.Limit, err = strconv.Atoi(limit); err != nil {
+			return from, opts, fmt.Errorf("invalid limit '%s'", limit)
+		}
+	}
+	for name, b := range map[string]*bool{"accessible": &opts.Accessible, "bikes": &opts.Bikes, "station": &opts.Station} {
+		if v := q.Get(name); v != "" {
+			if *b, err = strconv.ParseBool(v); err != nil {
+				return from, opts, fmt.Errorf("invalid %s '%s'", name, v)
+			}
+		}
+	}
+	if exclude := q.Get("exclude_services"); exclude != "" {
+		if opts.ExcludeCategories, err = parseServiceCategories(strings.Split(exclude, ",")); err != nil {
+			return from, opts, err
+		}
+	}
+	return from, opts, nil
+}
+
+// parseServiceCategories parses the names of service categories (see
+// gtfs.ParseServiceCategory).
+func parseServiceCategories(names []string) ([]gtfs.ServiceCategory, error) {
+	var categories []gtfs.ServiceCategory
+	for _, name := range names {
+		c, err := gtfs.ParseServiceCategory(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, nil
+}
+
+// departures lists the departures from the stop given by the query parameter
+// "stop" (see parseDeparturesQuery for further parameters).
+func (s *server) departures(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	stopID := q.Get("stop")
+	if stopID == "" {
+		writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: "missing stop"})
+		return
+	}
+	from, opts, err := parseDeparturesQuery(q)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: err.Error()})
+		return
+	}
+
+	f, release := s.acquire()
+	defer release()
+	db := f.db.WithContext(r.Context())
+
+	tz, err := gtfs.FeedTimezone(db)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	opts.Categories = f.categories
+	opts.Catalog = f.catalog
+	opts.Occupancies = s.occupancy.get()
+	departures, err := gtfs.Departures(db, stopID, from, tz, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	t := newTranslator(w, r, f)
+	resp := make([]departureResponse, len(departures))
+	for i, d := range departures {
+		resp[i] = departureResponse{
+			Time:      d.Time.In(tz),
+			StopID:    d.StopID,
+			StopName:  t.translate("stops", "stop_name", d.StopID, d.StopName),
+			StopSeq:   d.StopSeq,
+			TripID:    d.TripID,
+			RouteID:   d.RouteID,
+			RouteName: t.translate("routes", "route_short_name", d.RouteID, d.RouteName),
+			Headsign:  t.translate("trips", "trip_headsign", d.TripID, d.Headsign),
+			Platform:  d.Platform,
+			Occupancy: newOccupancyResponse(d.Occupancy),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
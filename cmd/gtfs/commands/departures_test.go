@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_Departures(t *testing.T) {
+	_, h := newTestServer(t, "", "", "")
+	target := "/departures?stop=S1&at=2022-03-01T07:55:00%2B01:00"
+	var departures []departureResponse
+	decodeResponse(t, serveRequest(h, http.MethodGet, target, ""), http.StatusOK, &departures)
+	if len(departures) != 2 || departures[0].TripID != "T1" || departures[1].TripID != "T2" {
+		t.Fatalf("GET %s = %+v, want T1 and T2", target, departures)
+	}
+	if d := departures[0]; d.StopName != "S Wannsee" || d.RouteName != "S1" || d.Platform != "" {
+		t.Errorf("GET %s = %+v", target, d)
+	}
+
+	// stop overrides take effect immediately
+	req := httptest.NewRequest(http.MethodPut, "/admin/stop-overrides/S1", strings.NewReader(`{"name": "Berlin-Wannsee", "platform": "3"}`))
+	if rec := serve(h, req); rec.Code != http.StatusOK {
+		t.Fatalf("PUT /admin/stop-overrides/S1 = %d (%s)", rec.Code, rec.Body)
+	}
+	decodeResponse(t, serveRequest(h, http.MethodGet, target, ""), http.StatusOK, &departures)
+	if d := departures[0]; d.StopName != "Berlin-Wannsee" || d.Platform != "3" {
+		t.Errorf("GET %s = %+v, want the override", target, d)
+	}
+
+	tests := []struct {
+		target   string
+		wantCode int
+	}{
+		{"/departures", http.StatusBadRequest},
+		{"/departures?stop=S1&at=tomorrow", http.StatusBadRequest},
+		{"/departures?stop=S1&exclude_services=weekdays", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := serveRequest(h, http.MethodGet, tt.target, ""); rec.Code != tt.wantCode {
+			t.Errorf("GET %s = %d, want %d", tt.target, rec.Code, tt.wantCode)
+		}
+	}
+}
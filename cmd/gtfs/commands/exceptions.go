@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// dateLayout is the layout of dates given to commands and the API.
+const dateLayout = "2006-01-02"
+
+// addDateRangeFlags adds the flags giving a range of dates to cmd.
+func addDateRangeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "", "first date (YYYY-MM-DD) of the range (defaults to the start of the service period)")
+	cmd.Flags().String("to", "", "last date (YYYY-MM-DD) of the range (defaults to the end of the service period)")
+}
+
+// dateRange returns the range of dates given by from and to (in the timezone
+// of the feed), defaulting to the service period of the feed.
+func dateRange(db *gorm.DB, from, to string) (time.Time, time.Time, error) {
+	tz, err := gtfs.FeedTimezone(db)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	first, last, err := gtfs.ServicePeriod(db, tz)
+	if err != nil && (from == "" || to == "") {
+		return time.Time{}, time.Time{}, err
+	}
+	if from != "" {
+		if first, err = time.ParseInLocation(dateLayout, from, tz); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date '%s'", from)
+		}
+	}
+	if to != "" {
+		if last, err = time.ParseInLocation(dateLayout, to, tz); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid date '%s'", to)
+		}
+	}
+	return first, last, nil
+}
+
+func gtfsAnalyzeExceptions(cmd *cobra.Command, args []string) error {
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return err
+	}
+	to, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	first, last, err := dateRange(db, from, to)
+	if err != nil {
+		return err
+	}
+	exceptions, err := gtfs.ServiceExceptions(db, first, last)
+	if err != nil {
+		return fmt.Errorf("failed to summarize service exceptions: %w", err)
+	}
+	if len(exceptions) == 0 {
+		fmt.Printf("regular service from %s to %s\n", first.Format(dateLayout), last.Format(dateLayout))
+		return nil
+	}
+	for _, e := range exceptions {
+		fmt.Println(e.String())
+	}
+	return nil
+}
+
+// serviceExceptionResponse is the type used to describe a service exception
+// in API responses.
+type serviceExceptionResponse struct {
+	Date    string   `json:"date"`
+	Removed []string `json:"removed,omitempty"`
+	Added   []string `json:"added,omitempty"`
+	Summary string   `json:"summary"`
+}
+
+// exceptions summarizes the service exceptions within the range of dates given
+// by the query parameters "from" and "to" (YYYY-MM-DD, defaulting to the
+// service period).
+func (s *server) exceptions(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	for _, name := range []string{"from", "to"} {
+		if v := q.Get(name); v != "" {
+			if _, err := time.Parse(dateLayout, v); err != nil {
+				writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: fmt.Sprintf("invalid date '%s'", v)})
+				return
+			}
+		}
+	}
+
+	f, release := s.acquire()
+	defer release()
+	db := f.db.WithContext(r.Context())
+
+	first, last, err := dateRange(db, q.Get("from"), q.Get("to"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	exceptions, err := gtfs.ServiceExceptions(db, first, last)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := make([]serviceExceptionResponse, len(exceptions))
+	for i, e := range exceptions {
+		resp[i] = serviceExceptionResponse{
+			Date:    e.Date.Format(dateLayout),
+			Removed: e.Removed,
+			Added:   e.Added,
+			Summary: e.String(),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,68 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+func gtfsExport(cmd *cobra.Command, args []string) error {
+	compressHeadways, err := cmd.Flags().GetBool("compress-headways")
+	if err != nil {
+		return err
+	}
+	sanitize, err := cmd.Flags().GetBool("sanitize")
+	if err != nil {
+		return err
+	}
+	duckDB, err := cmd.Flags().GetBool("duckdb")
+	if err != nil {
+		return err
+	}
+
+	// submit as job, if desired
+	isAsync, err := async(cmd)
+	if err != nil {
+		return err
+	}
+	if isAsync {
+		params, err := asyncParams(cmd, []string{"compress-headways", "sanitize"})
+		if err != nil {
+			return err
+		}
+
+		// the server exports below its --export-to
+		params.Set("dir", args[1])
+		return submitJob(cmd, exportJobType, args[0], params)
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	dir := args[1]
+	if duckDB {
+		if err = gtfs.ExportDuckDB(db, dir); err != nil {
+			return err
+		}
+		log.Printf("exported to '%s' (run IMPORT DATABASE '%s' in DuckDB to load it)", dir, dir)
+		return nil
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if err = gtfs.Export(db, dir, gtfs.ExportOptions{CompressHeadways: compressHeadways, Sanitize: sanitize}); err != nil {
+		return err
+	}
+	log.Printf("exported to '%s'", dir)
+	return nil
+}
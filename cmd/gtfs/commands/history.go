@@ -0,0 +1,212 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// snapshotLayout is the layout of the (UTC) timestamps naming snapshots.
+const snapshotLayout = "20060102T150405Z"
+
+// snapshot is an archived (i.e. superseded) version of a DB (see archiveDB).
+type snapshot struct {
+	ID   string    // the timestamp naming the snapshot
+	Path string    // the path of the snapshot file
+	Time time.Time // the time the DB was imported (i.e. last modified)
+	Size int64
+}
+
+// snapshotDir returns the directory holding the snapshots of the DB at dbPath.
+func snapshotDir(dbPath string) string {
+	return dbPath + ".snapshots"
+}
+
+// listSnapshots returns the snapshots of the DB at dbPath (newest first).
+func listSnapshots(dbPath string) ([]snapshot, error) {
+	entries, err := os.ReadDir(snapshotDir(dbPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []snapshot
+	for _, e := range entries {
+		id := strings.TrimSuffix(e.Name(), ".db")
+		t, err := time.Parse(snapshotLayout, id)
+		if err != nil || e.IsDir() || id == e.Name() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot{ID: id, Path: filepath.Join(snapshotDir(dbPath), e.Name()), Time: t, Size: info.Size()})
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Time.After(snapshots[j].Time)
+	})
+	return snapshots, nil
+}
+
+// archiveDB archives the DB at dbPath as snapshot (named by the time it was
+// last modified) and removes all but the newest keep snapshots. If there is no
+// DB at dbPath, nothing is archived. The snapshot may share the file of the DB
+// (i.e. be a hard link), so the DB must be replaced (rather than modified)
+// afterwards.
+func archiveDB(dbPath string, keep int) error {
+	info, err := os.Stat(dbPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(snapshotDir(dbPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	snapshotPath := filepath.Join(snapshotDir(dbPath), info.ModTime().UTC().Format(snapshotLayout)+".db")
+	if _, err = os.Stat(snapshotPath); errors.Is(err, os.ErrNotExist) {
+		if err = os.Link(dbPath, snapshotPath); err != nil {
+			err = copyFile(dbPath, snapshotPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to archive '%s': %w", dbPath, err)
+		}
+		log.Printf("archived '%s' as '%s'", dbPath, snapshotPath)
+	}
+
+	// remove the oldest snapshots
+	snapshots, err := listSnapshots(dbPath)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(snapshots); i++ {
+		if err = os.Remove(snapshots[i].Path); err != nil {
+			return fmt.Errorf("failed to remove snapshot: %w", err)
+		}
+	}
+	return nil
+}
+
+// copyFile copies the file at src to dst (via a temporary file, such that dst
+// is either replaced completely or not at all).
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(tmp)
+		return err
+	}
+	if err = out.Close(); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, dst)
+}
+
+func gtfsHistory(cmd *cobra.Command, args []string) error {
+	rollback, err := cmd.Flags().GetString("rollback")
+	if err != nil {
+		return err
+	}
+	dbPath := args[0]
+	snapshots, err := listSnapshots(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to list snapshots: %w", err)
+	}
+	if rollback != "" {
+		return rollbackDB(cmd, dbPath, rollback, snapshots)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SNAPSHOT\tIMPORTED\tSIZE\tVERSION")
+	if info, err := os.Stat(dbPath); err == nil {
+		_, _ = fmt.Fprintf(w, "current\t%s\t%s\t%s\n", info.ModTime().Format(time.RFC3339), fmt.Sprintf("%.1f MB", float64(info.Size())/(1<<20)), feedVersion(cmd, dbPath))
+	}
+	for _, s := range snapshots {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", s.ID, s.Time.Local().Format(time.RFC3339), fmt.Sprintf("%.1f MB", float64(s.Size)/(1<<20)), feedVersion(cmd, s.Path))
+	}
+	return w.Flush()
+}
+
+// rollbackDB replaces the DB at dbPath by the snapshot with the given ID
+// (archiving the current DB, such that the rollback may be undone) and asks
+// the server serving it to reload (if desired).
+func rollbackDB(cmd *cobra.Command, dbPath, id string, snapshots []snapshot) error {
+	var target *snapshot
+	for i := range snapshots {
+		if snapshots[i].ID == id {
+			target = &snapshots[i]
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("no snapshot '%s' of '%s' (see gtfs history %s)", id, dbPath, dbPath)
+	}
+	keep, err := cmd.Flags().GetInt("keep")
+	if err != nil {
+		return err
+	}
+	if err = archiveDB(dbPath, keep); err != nil {
+		return err
+	}
+	if err = copyFile(target.Path, dbPath); err != nil {
+		return fmt.Errorf("failed to roll back: %w", err)
+	}
+	fmt.Printf("rolled back '%s' to the snapshot of %s\n", dbPath, target.Time.Local().Format(time.RFC3339))
+
+	reload, err := cmd.Flags().GetBool("reload")
+	if err != nil || !reload {
+		return err
+	}
+	server, err := cmd.Flags().GetString("server")
+	if err != nil {
+		return err
+	}
+	token, err := adminToken(cmd)
+	if err != nil {
+		return err
+	}
+	if err = callServer(http.MethodPost, server+"/admin/reload", token, nil); err != nil {
+		return err
+	}
+	fmt.Printf("reloaded '%s' on %s\n", dbPath, server)
+	return nil
+}
+
+// feedVersion returns the version of the feed within the DB at dbPath (see
+// gtfs.GetFeedMeta), "-" if unknown.
+func feedVersion(cmd *cobra.Command, dbPath string) string {
+	db, closeDB, err := openDB(cmd, dbPath)
+	if err != nil {
+		return "-"
+	}
+	defer closeDB()
+	meta, err := gtfs.GetFeedMeta(db)
+	if err != nil || meta.Version == "" {
+		return "-"
+	}
+	return meta.Version
+}
@@ -4,42 +4,202 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/gocarina/gocsv"
 	"github.com/heimdalr/gtfs"
 	"github.com/spf13/cobra"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"log"
 	"os"
-	"path"
+	"path/filepath"
+	"strings"
 	"time"
 )
 
-// batchSize is the size of the batches to use for importing into the DB.
-const batchSize = 1000
+func gtfsImport(cmd *cobra.Command, args []string) error {
 
-// importResult is the type used to describe the result of importing a single item type.
-type importResult struct {
-	ItemType gtfs.ItemType
-	Count    int64
-	Batches  int64
-	Time     time.Duration
-	Error    error
-}
+	gtfsBasePath := args[0]
+	dbPath := args[1]
 
-// String returns a human-readable representation of importResult.
-func (iir importResult) String() string {
-	if iir.Error != nil {
-		return fmt.Sprintf("failed to import %s: %v", iir.ItemType, iir.Error)
+	profileName, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return err
+	}
+	fillRouteNames, err := cmd.Flags().GetBool("fill-route-names")
+	if err != nil {
+		return err
+	}
+	compare, err := cmd.Flags().GetBool("compare")
+	if err != nil {
+		return err
+	}
+
+	// submit as job, if desired
+	isAsync, err := async(cmd)
+	if err != nil {
+		return err
+	}
+	if isAsync {
+		from, err := filepath.Abs(gtfsBasePath)
+		if err != nil {
+			return err
+		}
+		params, err := asyncParams(cmd, importJobFlags)
+		if err != nil {
+			return err
+		}
+		params.Set("from", from)
+		return submitJob(cmd, importJobType, dbPath, params)
 	}
-	return fmt.Sprintf("imported %d %s in %d batches in %s", iir.Count, iir.ItemType, iir.Batches, iir.Time)
-}
 
-func gtfsImport(_ *cobra.Command, args []string) error {
+	var profile *gtfs.ImportProfile
+	if profileName != "" {
+		if profile, err = gtfs.LookupImportProfile(profileName); err != nil {
+			return err
+		}
+	}
 
-	gtfsBasePath := args[0]
-	dbPath := args[1]
+	reportPath, err := cmd.Flags().GetString("report")
+	if err != nil {
+		return err
+	}
+	rejectsPath, err := cmd.Flags().GetString("rejects")
+	if err != nil {
+		return err
+	}
+	fts5, err := cmd.Flags().GetBool("fts5")
+	if err != nil {
+		return err
+	}
+	var meta gtfs.FeedMeta
+	if meta.SourceURL, err = cmd.Flags().GetString("source-url"); err != nil {
+		return err
+	}
+	if meta.License, err = cmd.Flags().GetString("license"); err != nil {
+		return err
+	}
+	if meta.Terms, err = cmd.Flags().GetString("terms"); err != nil {
+		return err
+	}
+	conflictSpecs, err := cmd.Flags().GetStringSlice("on-conflict")
+	if err != nil {
+		return err
+	}
+	conflicts, err := parseConflicts(conflictSpecs)
+	if err != nil {
+		return err
+	}
+	skipFailedRows, err := cmd.Flags().GetBool("skip-failed-rows")
+	if err != nil {
+		return err
+	}
+	maxRows, err := cmd.Flags().GetInt64("max-rows")
+	if err != nil {
+		return err
+	}
+	maxDuration, err := cmd.Flags().GetDuration("max-duration")
+	if err != nil {
+		return err
+	}
+	decoderName, err := cmd.Flags().GetString("csv-decoder")
+	if err != nil {
+		return err
+	}
+	decoder, err := parseDecoder(decoderName)
+	if err != nil {
+		return err
+	}
+	prepareStmt, err := cmd.Flags().GetBool("prepare-stmt")
+	if err != nil {
+		return err
+	}
+	encodeShapes, err := cmd.Flags().GetBool("encode-shapes")
+	if err != nil {
+		return err
+	}
+	delimiterName, err := cmd.Flags().GetString("delimiter")
+	if err != nil {
+		return err
+	}
+	delimiter, err := parseDelimiter(delimiterName)
+	if err != nil {
+		return err
+	}
+	decimalComma, err := cmd.Flags().GetBool("decimal-comma")
+	if err != nil {
+		return err
+	}
+	encodingName, err := cmd.Flags().GetString("encoding")
+	if err != nil {
+		return err
+	}
+	encoding, err := gtfs.ParseEncoding(encodingName)
+	if err != nil {
+		return err
+	}
+	lenient, err := cmd.Flags().GetBool("lenient")
+	if err != nil {
+		return err
+	}
+	modeName, err := cmd.Flags().GetString("mode")
+	if err != nil {
+		return err
+	}
+	mode, err := gtfs.ParseImportMode(modeName)
+	if err != nil {
+		return err
+	}
+	skippedRowsPath, err := cmd.Flags().GetString("skipped-rows")
+	if err != nil {
+		return err
+	}
+	if skippedRowsPath != "" && !cmd.Flags().Changed("mode") {
+		mode = gtfs.ImportLenient
+	}
+	batchSize, err := cmd.Flags().GetInt("batch-size")
+	if err != nil {
+		return err
+	}
+	onlyNames, err := cmd.Flags().GetStringSlice("only")
+	if err != nil {
+		return err
+	}
+	itemTypes, err := parseItemTypes(onlyNames)
+	if err != nil {
+		return err
+	}
+	workers, err := cmd.Flags().GetInt("workers")
+	if err != nil {
+		return err
+	}
+	insertWorkers, err := cmd.Flags().GetInt("insert-workers")
+	if err != nil {
+		return err
+	}
+	noTransactions, err := cmd.Flags().GetBool("no-transactions")
+	if err != nil {
+		return err
+	}
+	retry, err := retryPolicy(cmd)
+	if err != nil {
+		return err
+	}
+	showProgress, err := cmd.Flags().GetBool("progress")
+	if err != nil {
+		return err
+	}
+	ignoreSpace, err := cmd.Flags().GetBool("ignore-space")
+	if err != nil {
+		return err
+	}
+	retrievedAt, err := cmd.Flags().GetString("retrieved-at")
+	if err != nil {
+		return err
+	}
+	if retrievedAt != "" {
+		if meta.RetrievedAt, err = time.Parse(time.RFC3339, retrievedAt); err != nil {
+			return fmt.Errorf("failed to parse retrieval time: %w", err)
+		}
+	}
 
 	// some argument validation
 	if gtfsBasePath == "" {
@@ -49,24 +209,54 @@ func gtfsImport(_ *cobra.Command, args []string) error {
 		return errors.New("empty dbPath")
 	}
 
-	// delete db-file, if it exists
-	_, err := os.Stat(dbPath)
-	if err == nil {
-		if err = os.Remove(dbPath); err != nil {
-			return fmt.Errorf("failed to remove old db file '%s'", dbPath)
-		}
-	} else if !errors.Is(err, os.ErrNotExist) {
+	// delete db-file, if it exists (keeping it aside to compare IDs, if
+	// desired), DBs on Postgres or MySQL are cleared by dropping their tables
+	// instead
+	server, err := isServerDB(cmd)
+	if err != nil {
 		return err
 	}
+	if server && compare {
+		return errors.New("--compare is not supported with postgres or mysql")
+	}
+	if !server && workers > 1 {
+		return errors.New("--workers requires --driver postgres or mysql (SQLite admits a single writer)")
+	}
+	var previousPath string
+	if !server {
+		_, err = os.Stat(dbPath)
+		if err == nil && compare {
+			previousPath = dbPath + ".previous"
+			if err = os.Rename(dbPath, previousPath); err != nil {
+				return fmt.Errorf("failed to move aside old db file '%s'", dbPath)
+			}
+			defer func() {
+				_ = os.Remove(previousPath)
+			}()
+		} else if err == nil {
+			if err = os.Remove(dbPath); err != nil {
+				return fmt.Errorf("failed to remove old db file '%s'", dbPath)
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
 
 	// open gorm db
+	dial, err := dialector(cmd, dbPath)
+	if err != nil {
+		return err
+	}
 	var db *gorm.DB
-	db, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	db, err = gorm.Open(dial, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Error),
 	})
 	if err != nil {
 		return err
 	}
+	if err = registerPlugins(cmd, db); err != nil {
+		return err
+	}
 
 	// close the DB at last
 	var sqlDB *sql.DB
@@ -78,523 +268,307 @@ func gtfsImport(_ *cobra.Command, args []string) error {
 		_ = sqlDB.Close()
 	}(sqlDB)
 
+	// drop the tables of the previous import (rather than the db-file)
+	if server {
+		if err = dropTables(db); err != nil {
+			return fmt.Errorf("failed to drop tables: %w", err)
+		}
+	}
+
 	// ensure tables matching our model
 	err = gtfs.Migrate(db)
 	if err != nil {
 		return fmt.Errorf("failed to migrate DB: %w", err)
 	}
 
-	// import CSV files
-	progress := make(chan *importResult)
-	go importAll(db, gtfsBasePath, progress)
-	for r := range progress {
-		log.Println(r.String())
-	}
-
-	return nil
-}
-
-// importAll imports all GTFS CSV files from the directory gtfsBase into the
-// db.
-//
-// If the progress channel is not nil, import results (for each of the item
-// types) will be sent through the channel.
-func importAll(db *gorm.DB, gtfsBase string, progress chan *importResult) {
-
-	// define what to import
-	sources := []struct {
-		path     string
-		itemType gtfs.ItemType
-	}{
-		{path.Join(gtfsBase, "agency.txt"), gtfs.Agencies},
-		{path.Join(gtfsBase, "routes.txt"), gtfs.Routes},
-		{path.Join(gtfsBase, "trips.txt"), gtfs.Trips},
-		{path.Join(gtfsBase, "stops.txt"), gtfs.Stops},
-		{path.Join(gtfsBase, "stop_times.txt"), gtfs.StopTimes},
-		{path.Join(gtfsBase, "shapes.txt"), gtfs.Shapes},
-		{path.Join(gtfsBase, "calendar.txt"), gtfs.Calendars},
-		{path.Join(gtfsBase, "calendar_dates.txt"), gtfs.CalendarDates},
-	}
-
-	// import each of the sources
-	for _, source := range sources {
-		r := importSingle(source.path, db, source.itemType)
-
-		// send progress if desired
-		if progress != nil {
-			progress <- r
-		}
-	}
-
-	if progress != nil {
-		close(progress)
+	// estimate the size of the import, refusing to import if lacking space
+	estimate, err := gtfs.EstimateImport(db, gtfsBasePath)
+	if err != nil {
+		return fmt.Errorf("failed to estimate import: %w", err)
 	}
-}
-
-// importSingle imports all items of a given type from a CSV-file into a DB.
-func importSingle(csvPath string, db *gorm.DB, importType gtfs.ItemType) *importResult {
-
-	// provide for timing
-	start := time.Now()
-
-	// parse CSV and send each row to the channel (UnmarshalToChan closes the channel)
-	file, err := os.Open(csvPath)
-	if err != nil {
-		return &importResult{Error: err}
-	}
-	defer func() {
-		_ = file.Close()
-	}()
-
-	resultChan := make(chan *importResult)
-
-	var itemChan interface{}
-	switch importType {
-	case gtfs.Agencies:
-		c := make(chan *gtfs.Agency)
-		go importAgencies(c, resultChan, db)
-		itemChan = c
-	case gtfs.Routes:
-		c := make(chan *gtfs.Route)
-		go importRoutes(c, resultChan, db)
-		itemChan = c
-	case gtfs.Trips:
-		c := make(chan *gtfs.Trip)
-		go importTrips(c, resultChan, db)
-		itemChan = c
-	case gtfs.Stops:
-		c := make(chan *gtfs.Stop)
-		go importStops(c, resultChan, db)
-		itemChan = c
-	case gtfs.StopTimes:
-		c := make(chan *gtfs.StopTime)
-		go importStopTimes(c, resultChan, db)
-		itemChan = c
-	case gtfs.Shapes:
-		c := make(chan *gtfs.Shape)
-		go importShapes(c, resultChan, db)
-		itemChan = c
-	case gtfs.Calendars:
-		c := make(chan *gtfs.Calendar)
-		go importCalendars(c, resultChan, db)
-		itemChan = c
-	case gtfs.CalendarDates:
-		c := make(chan *gtfs.CalendarDate)
-		go importCalendarDates(c, resultChan, db)
-		itemChan = c
-	default:
-		return &importResult{Error: fmt.Errorf("unknown ItemType %d", importType)}
+	log.Printf("estimated %s", estimate)
+	if err = estimate.Check(); err != nil && !ignoreSpace {
+		return fmt.Errorf("%w (add --ignore-space to import anyway)", err)
 	}
 
-	if err = gocsv.UnmarshalToChan(file, itemChan); err != nil {
-		return &importResult{Error: err}
+	// import CSV files
+	report := newImportReport(gtfsBasePath, dbPath)
+	report.addEstimate(estimate)
+	var imported int64
+	var fileProgress func(gtfs.FileProgress)
+	if showProgress {
+		fileProgress = printProgress
 	}
-
-	// wait for the batch insert to return counts
-	r := <-resultChan
-
-	// compute the elapsed Time
-	r.Time = time.Since(start)
-
-	return r
-}
-
-// importShapes imports all shapes from a channel into a DB.
-func importAgencies(items chan *gtfs.Agency, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.Agency
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
-
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.Agencies, Error: tx.Error}
-				return
+	importErr := gtfs.DefaultImporter{}.Import(db, gtfsBasePath, gtfs.ImportOptions{
+		OnProgress: func(e gtfs.ImportEvent) {
+			if showProgress {
+				clearProgress()
 			}
-			batchCount++
-
-			// reset batch
-			batch = []*gtfs.Agency{}
-		}
+			imported += e.Result.Count
+			if e.Estimate != nil && e.Estimate.TotalRows > 0 && !e.Result.Skipped {
+				log.Printf("%s (%d of about %d rows)", e.Result, imported, e.Estimate.TotalRows)
+			} else {
+				log.Println(e.Result.String())
+			}
+			for _, rejection := range e.Result.Rejections {
+				log.Printf("rejected %s in %s", rejection, e.Result.Path)
+			}
+			report.add(e.Result)
+		},
+		FTS5:              fts5,
+		Estimate:          estimate,
+		Profile:           profile,
+		FillRouteNames:    fillRouteNames,
+		Conflicts:         conflicts,
+		SkipFailedRows:    skipFailedRows,
+		RecordRejects:     rejectsPath != "",
+		RecordSkippedRows: skippedRowsPath != "",
+		MaxRows:           maxRows,
+		MaxDuration:       maxDuration,
+		Decoder:           decoder,
+		PrepareStmt:       prepareStmt,
+		EncodeShapes:      encodeShapes,
+		Delimiter:         delimiter,
+		DecimalComma:      decimalComma,
+		Encoding:          encoding,
+		LenientCSV:        lenient,
+		Mode:              mode,
+		BatchSize:         batchSize,
+		ItemTypes:         itemTypes,
+		Retry:             retry,
+		Workers:           workers,
+		InsertWorkers:     insertWorkers,
+		NoTransactions:    noTransactions,
+		OnFileProgress:    fileProgress,
+	})
+	// files failing to import do not stop the remaining steps (their errors are
+	// returned once the rejects, the skipped rows and the report are written),
+	// a missing required file does
+	if errors.Is(importErr, gtfs.ErrRequiredFileMissing) {
+		return fmt.Errorf("incomplete feed, nothing imported: %w", importErr)
 	}
 
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.Agencies, Error: tx.Error}
-			return
+	// write the rows skipped or modified, if desired
+	if rejectsPath != "" {
+		if err = writeRejects(db, rejectsPath); err != nil {
+			return fmt.Errorf("failed to write rejects: %w", err)
 		}
-		batchCount++
 	}
 
-	// return the counts
-	result <- &importResult{ItemType: gtfs.Agencies, Count: itemCount, Batches: batchCount}
-}
-
-// importRoutes imports all routes from a channel into a DB.
-func importRoutes(items chan *gtfs.Route, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.Route
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
-
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.Routes, Error: tx.Error}
-				return
-			}
-			batchCount++
-
-			// reset batch
-			batch = []*gtfs.Route{}
+	// write the rows failing to parse, if desired
+	if skippedRowsPath != "" {
+		if err = writeSkippedRows(db, skippedRowsPath); err != nil {
+			return fmt.Errorf("failed to write skipped rows: %w", err)
 		}
 	}
 
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.Routes, Error: tx.Error}
-			return
-		}
-		batchCount++
+	// import the translations of names (if any)
+	translations, err := gtfs.ImportTranslations(db, gtfsBasePath)
+	if err != nil {
+		return err
 	}
-
-	// return the counts
-	result <- &importResult{ItemType: gtfs.Routes, Count: itemCount, Batches: batchCount}
-}
-
-// importTrips imports all trips from a channel into a DB.
-func importTrips(items chan *gtfs.Trip, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.Trip
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
-
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.Trips, Error: tx.Error}
-				return
-			}
-			batchCount++
-
-			// reset batch
-			batch = []*gtfs.Trip{}
-		}
+	if translations > 0 {
+		log.Printf("imported %d translations", translations)
 	}
 
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.Trips, Error: tx.Error}
-			return
-		}
-		batchCount++
+	// import the fares (if any)
+	fares, err := gtfs.ImportFares(db, gtfsBasePath)
+	if err != nil {
+		return err
+	}
+	if fares > 0 {
+		log.Printf("imported %d fare products and rules", fares)
 	}
 
-	// return the counts
-	result <- &importResult{ItemType: gtfs.Trips, Count: itemCount, Batches: batchCount}
-}
-
-// importStops imports all stops from a channel into a DB.
-func importStops(items chan *gtfs.Stop, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.Stop
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
+	// import the vehicle capacities (if any)
+	capacities, err := gtfs.ImportVehicleCapacities(db, gtfsBasePath)
+	if err != nil {
+		return err
+	}
+	if capacities > 0 {
+		log.Printf("imported %d vehicle capacities", capacities)
+	}
 
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.Stops, Error: tx.Error}
-				return
-			}
-			batchCount++
+	// record the origin and the terms of use of the feed
+	if err = gtfs.RecordFeedMeta(db, gtfsBasePath, meta); err != nil {
+		return err
+	}
 
-			// reset batch
-			batch = []*gtfs.Stop{}
+	// compare IDs with the previous version of the feed, if desired
+	if previousPath != "" {
+		previousDB, closePreviousDB, err := openDB(cmd, previousPath)
+		if err != nil {
+			return err
+		}
+		stability, err := gtfs.CompareIDs(previousDB, db)
+		closePreviousDB()
+		if err != nil {
+			return fmt.Errorf("failed to compare IDs: %w", err)
 		}
+		for _, s := range stability {
+			log.Println(s.String())
+		}
+		report.addIDStability(stability)
 	}
 
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.Stops, Error: tx.Error}
-			return
+	// write the report, if desired
+	if reportPath != "" {
+		report.finish()
+		if err = report.write(reportPath); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
 		}
-		batchCount++
 	}
 
-	// return the counts
-	result <- &importResult{ItemType: gtfs.Stops, Count: itemCount, Batches: batchCount}
+	return importErr
 }
 
-// importStopTimes imports all stopTimes from a channel into a DB.
-func importStopTimes(items chan *gtfs.StopTime, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.StopTime
-
-	// successively read all items from the channel
-	for item := range items {
+// dropTables drops the tables of a GTFS DB (if present).
+func dropTables(db *gorm.DB) error {
+	return db.Migrator().DropTable(gtfs.Models()...)
+}
 
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
+// progressBarWidth is the width (in characters) of progress bars.
+const progressBarWidth = 30
 
-		// if batch is "full"
-		if len(batch) == batchSize {
+// printProgress prints the progress of importing a file as progress bar (to
+// stderr, replacing the previous progress).
+func printProgress(fp gtfs.FileProgress) {
+	fraction := fp.Fraction()
+	if fraction < 0 {
+		fmt.Fprintf(os.Stderr, "\r\033[K%s: %d rows", fp.ItemType, fp.Rows)
+		return
+	}
+	done := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", done) + strings.Repeat(" ", progressBarWidth-done)
+	fmt.Fprintf(os.Stderr, "\r\033[K[%s] %5.1f%% %s: %d of about %d rows, %s left", bar, fraction*100, fp.ItemType, fp.Rows, fp.EstimatedRows(), fp.ETA())
+}
 
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.StopTimes, Error: tx.Error}
-				return
-			}
-			batchCount++
+// clearProgress clears the progress bar (see printProgress).
+func clearProgress() {
+	fmt.Fprint(os.Stderr, "\r\033[K")
+}
 
-			// reset batch
-			batch = []*gtfs.StopTime{}
-		}
+// writeRejects writes the rows skipped or modified when importing as CSV to
+// the file at rejectsPath.
+func writeRejects(db *gorm.DB, rejectsPath string) error {
+	f, err := os.Create(rejectsPath)
+	if err != nil {
+		return err
 	}
-
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.StopTimes, Error: tx.Error}
-			return
-		}
-		batchCount++
+	if err = gtfs.WriteImportRejects(db, f); err != nil {
+		_ = f.Close()
+		return err
 	}
-
-	// return the counts
-	result <- &importResult{ItemType: gtfs.StopTimes, Count: itemCount, Batches: batchCount}
+	return f.Close()
 }
 
-// importShapes imports all shapes from a channel into a DB.
-func importShapes(items chan *gtfs.Shape, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.Shape
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
-
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.Shapes, Error: tx.Error}
-				return
-			}
-			batchCount++
-
-			// reset batch
-			batch = []*gtfs.Shape{}
-		}
+// writeSkippedRows writes the rows failing to parse when importing as CSV to
+// the file at skippedRowsPath.
+func writeSkippedRows(db *gorm.DB, skippedRowsPath string) error {
+	rows, err := gtfs.SkippedRows(db)
+	if err != nil {
+		return err
 	}
-
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.Shapes, Error: tx.Error}
-			return
-		}
-		batchCount++
+	f, err := os.Create(skippedRowsPath)
+	if err != nil {
+		return err
 	}
-
-	// return the counts
-	result <- &importResult{ItemType: gtfs.Shapes, Count: itemCount, Batches: batchCount}
+	if err = gtfs.WriteSkippedRows(f, rows); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
 }
 
-// importCalendars imports all calendars from a channel into a DB.
-func importCalendars(items chan *gtfs.Calendar, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.Calendar
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
+// conflictItemTypes maps the names of files (without extension) to the item
+// types whose items are identified by IDs.
+var conflictItemTypes = map[string]gtfs.ItemType{
+	"agency": gtfs.Agencies,
+	"routes": gtfs.Routes,
+	"trips":  gtfs.Trips,
+	"stops":  gtfs.Stops,
+}
 
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.Calendars, Error: tx.Error}
-				return
-			}
-			batchCount++
+// fileItemTypes maps the names of GTFS files (without extension) to their
+// item types.
+var fileItemTypes = map[string]gtfs.ItemType{
+	"agency":         gtfs.Agencies,
+	"routes":         gtfs.Routes,
+	"trips":          gtfs.Trips,
+	"stops":          gtfs.Stops,
+	"stop_times":     gtfs.StopTimes,
+	"shapes":         gtfs.Shapes,
+	"calendar":       gtfs.Calendars,
+	"calendar_dates": gtfs.CalendarDates,
+	"frequencies":    gtfs.Frequencies,
+}
 
-			// reset batch
-			batch = []*gtfs.Calendar{}
+// parseItemTypes returns the item types of the given GTFS files (named
+// without extension, e.g. "stops").
+func parseItemTypes(names []string) ([]gtfs.ItemType, error) {
+	var itemTypes []gtfs.ItemType
+	for _, name := range names {
+		itemType, ok := fileItemTypes[strings.TrimSuffix(name, ".txt")]
+		if !ok {
+			return nil, fmt.Errorf("unknown file '%s'", name)
 		}
+		itemTypes = append(itemTypes, itemType)
 	}
+	return itemTypes, nil
+}
 
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.Calendars, Error: tx.Error}
-			return
-		}
-		batchCount++
+// parseDelimiter returns the delimiter given as single character or "tab" (0,
+// if empty).
+func parseDelimiter(s string) (rune, error) {
+	if s == "tab" {
+		return '\t', nil
 	}
-
-	// return the counts
-	result <- &importResult{ItemType: gtfs.Calendars, Count: itemCount, Batches: batchCount}
+	r := []rune(s)
+	if len(r) > 1 {
+		return 0, fmt.Errorf("invalid delimiter '%s' (expected a single character or tab)", s)
+	}
+	if len(r) == 0 {
+		return 0, nil
+	}
+	return r[0], nil
 }
 
-// importCalendarDates imports all calendars from a channel into a DB.
-func importCalendarDates(items chan *gtfs.CalendarDate, result chan *importResult, db *gorm.DB) {
-
-	// ensure the result channel will be closed at last
-	defer close(result)
-
-	// initialize counters
-	var itemCount int64
-	var batchCount int64
-
-	// initialize the batch
-	var batch []*gtfs.CalendarDate
-
-	// successively read all items from the channel
-	for item := range items {
-
-		// add item to batch and Count it
-		itemCount++
-		batch = append(batch, item)
-
-		// if batch is "full"
-		if len(batch) == batchSize {
+// parseDecoder returns the CSV decoder of the given name.
+func parseDecoder(name string) (gtfs.CSVDecoder, error) {
+	switch name {
+	case "", "gocsv":
+		return gtfs.GocsvDecoder{}, nil
+	case "fast":
+		return gtfs.FastDecoder{}, nil
+	default:
+		return nil, fmt.Errorf("unknown CSV decoder '%s' (expected gocsv or fast)", name)
+	}
+}
 
-			// persist the batch and Count
-			tx := db.Create(batch)
-			if tx.Error != nil {
-				result <- &importResult{ItemType: gtfs.CalendarDates, Error: tx.Error}
-				return
+// parseConflicts parses specifications of conflict strategies, i.e. either
+// "<strategy>" (for all files) or "<file>=<strategy>" (e.g. "stops=skip").
+func parseConflicts(specs []string) (map[gtfs.ItemType]gtfs.ConflictStrategy, error) {
+	conflicts := map[gtfs.ItemType]gtfs.ConflictStrategy{}
+	for _, spec := range specs {
+		name, strategy := "", spec
+		if i := strings.Index(spec, "="); i >= 0 {
+			name, strategy = spec[:i], spec[i+1:]
+		}
+		cs, err := gtfs.ParseConflictStrategy(strategy)
+		if err != nil {
+			return nil, err
+		}
+		if name == "" {
+			for _, itemType := range conflictItemTypes {
+				conflicts[itemType] = cs
 			}
-			batchCount++
-
-			// reset batch
-			batch = []*gtfs.CalendarDate{}
+			continue
 		}
-	}
-
-	// persist any incomplete batch
-	if len(batch) > 0 {
-		tx := db.Create(batch)
-		if tx.Error != nil {
-			result <- &importResult{ItemType: gtfs.CalendarDates, Error: tx.Error}
-			return
+		itemType, ok := conflictItemTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown file '%s' (expected agency, routes, trips or stops)", name)
 		}
-		batchCount++
+		conflicts[itemType] = cs
 	}
-
-	// return the counts
-	result <- &importResult{ItemType: gtfs.CalendarDates, Count: itemCount, Batches: batchCount}
+	return conflicts, nil
 }
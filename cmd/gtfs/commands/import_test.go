@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// copyFixtureFeed copies the fixture feed into a temporary directory (to be
+// modified by the test) and returns its path.
+func copyFixtureFeed(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	entries, err := os.ReadDir(fixtureFeed)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(fixtureFeed, e.Name()))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		if err = os.WriteFile(filepath.Join(dir, e.Name()), b, 0o644); err != nil {
+			t.Fatalf("failed to copy fixture: %v", err)
+		}
+	}
+	return dir
+}
+
+func TestGtfsImport_Malformed(t *testing.T) {
+	feed := copyFixtureFeed(t)
+	f, err := os.OpenFile(filepath.Join(feed, "stop_times.txt"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open stop times: %v", err)
+	}
+	_, err = f.WriteString("T1,08:00:00,08:00:00,S1,x\n")
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		t.Fatalf("failed to write stop times: %v", err)
+	}
+
+	// the import fails, but the report is written nonetheless
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "report.json")
+	cmd := NewRootCmd("", "")
+	cmd.SetArgs([]string{"import", feed, filepath.Join(dir, "feed.db"), "--report", reportPath})
+	cmd.SilenceUsage, cmd.SilenceErrors = true, true
+	if err = cmd.Execute(); err == nil {
+		t.Errorf("Execute() error = nil, want an error")
+	}
+	if _, err = os.Stat(reportPath); err != nil {
+		t.Errorf("report not written: %v", err)
+	}
+}
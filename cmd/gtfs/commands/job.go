@@ -0,0 +1,511 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// job types supported by the server
+const (
+	importJobType   = "import"
+	trimJobType     = "trim"
+	exportJobType   = "export"
+	validateJobType = "validate"
+)
+
+// newJob returns the function of a job of the given type (configured by
+// params).
+func (s *server) newJob(jobType string, params url.Values) (gtfs.JobFunc, error) {
+	switch jobType {
+	case importJobType:
+		if s.importFrom == "" {
+			return nil, errors.New("importing is not enabled (see --import-from)")
+		}
+		from := s.importFrom
+		if f := params.Get("from"); f != "" {
+			var err error
+			if from, err = importPath(s.importFrom, f); err != nil {
+				return nil, err
+			}
+		}
+		if server, err := isServerDriver(s.driver); err != nil || server {
+			return nil, errors.New("import jobs require the sqlite driver (imports are swapped in by replacing the DB file)")
+		}
+		opts, meta, err := importJobOptions(params)
+		if err != nil {
+			return nil, err
+		}
+		return s.importJob(from, opts, meta, params.Get("force") == "true"), nil
+	case trimJobType:
+		agency := params.Get("agency")
+		if agency == "" {
+			return nil, errors.New("missing agency")
+		}
+		return s.trimJob(agency, gtfs.TrimOptions{KeepStops: params["keep-stop"]}), nil
+	case exportJobType:
+		if s.exportTo == "" {
+			return nil, errors.New("exporting is not enabled (see --export-to)")
+		}
+		dir := params.Get("dir")
+		if dir == "" {
+			return nil, errors.New("missing directory to export to")
+		}
+		if !filepath.IsLocal(dir) {
+			return nil, fmt.Errorf("invalid directory to export to '%s' (must be relative to --export-to, without '..')", dir)
+		}
+		return s.exportJob(filepath.Join(s.exportTo, dir), gtfs.ExportOptions{
+			CompressHeadways: params.Get("compress-headways") == "true",
+			Sanitize:         params.Get("sanitize") == "true",
+		}), nil
+	case validateJobType:
+		return s.validateJob(), nil
+	default:
+		return nil, fmt.Errorf("unknown job type '%s'", jobType)
+	}
+}
+
+// importJobFlags are the flags of gtfs import supported by import jobs, i.e.
+// forwarded (by their names) as parameters of the jobs (see importJobOptions).
+var importJobFlags = []string{
+	"profile", "fill-route-names", "on-conflict", "skip-failed-rows", "max-rows", "max-duration", "delimiter",
+	"encoding", "decimal-comma", "lenient", "mode", "batch-size", "only", "no-transactions", "insert-workers",
+	"csv-decoder", "encode-shapes", "prepare-stmt", "fts5", "source-url", "license", "terms", "retrieved-at",
+}
+
+// importJobOptions parses the options of an import job (and the origin of the
+// feed to record) from the parameters named like the flags of gtfs import (see
+// importJobFlags), e.g. "mode=lenient&only=stops&only=routes".
+func importJobOptions(params url.Values) (gtfs.ImportOptions, gtfs.FeedMeta, error) {
+	var opts gtfs.ImportOptions
+	var meta gtfs.FeedMeta
+	var err error
+	if name := params.Get("profile"); name != "" {
+		if opts.Profile, err = gtfs.LookupImportProfile(name); err != nil {
+			return opts, meta, err
+		}
+	}
+	bools := map[string]*bool{
+		"fill-route-names": &opts.FillRouteNames,
+		"skip-failed-rows": &opts.SkipFailedRows,
+		"decimal-comma":    &opts.DecimalComma,
+		"lenient":          &opts.LenientCSV,
+		"no-transactions":  &opts.NoTransactions,
+		"encode-shapes":    &opts.EncodeShapes,
+		"prepare-stmt":     &opts.PrepareStmt,
+		"fts5":             &opts.FTS5,
+	}
+	for name, b := range bools {
+		if v := params.Get(name); v != "" {
+			if *b, err = strconv.ParseBool(v); err != nil {
+				return opts, meta, fmt.Errorf("invalid %s '%s'", name, v)
+			}
+		}
+	}
+	for name, n := range map[string]*int{"batch-size": &opts.BatchSize, "insert-workers": &opts.InsertWorkers} {
+		if v := params.Get(name); v != "" {
+			if *n, err = strconv.Atoi(v); err != nil {
+				return opts, meta, fmt.Errorf("invalid %s '%s'", name, v)
+			}
+		}
+	}
+	if v := params.Get("max-rows"); v != "" {
+		if opts.MaxRows, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return opts, meta, fmt.Errorf("invalid max-rows '%s'", v)
+		}
+	}
+	if v := params.Get("max-duration"); v != "" {
+		if opts.MaxDuration, err = time.ParseDuration(v); err != nil {
+			return opts, meta, fmt.Errorf("invalid max-duration '%s'", v)
+		}
+	}
+	if opts.Conflicts, err = parseConflicts(params["on-conflict"]); err != nil {
+		return opts, meta, err
+	}
+	if opts.Delimiter, err = parseDelimiter(params.Get("delimiter")); err != nil {
+		return opts, meta, err
+	}
+	if v := params.Get("encoding"); v != "" {
+		if opts.Encoding, err = gtfs.ParseEncoding(v); err != nil {
+			return opts, meta, err
+		}
+	}
+	if v := params.Get("mode"); v != "" {
+		if opts.Mode, err = gtfs.ParseImportMode(v); err != nil {
+			return opts, meta, err
+		}
+	}
+	if opts.Decoder, err = parseDecoder(params.Get("csv-decoder")); err != nil {
+		return opts, meta, err
+	}
+	if opts.ItemTypes, err = parseItemTypes(params["only"]); err != nil {
+		return opts, meta, err
+	}
+	meta.SourceURL = params.Get("source-url")
+	meta.License = params.Get("license")
+	meta.Terms = params.Get("terms")
+	if v := params.Get("retrieved-at"); v != "" {
+		if meta.RetrievedAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return opts, meta, fmt.Errorf("invalid retrieved-at '%s'", v)
+		}
+	}
+	return opts, meta, nil
+}
+
+// importPath returns the path of the file or directory from to import, which
+// must lie within the directory importFrom (from is relative to importFrom,
+// unless absolute).
+func importPath(importFrom, from string) (string, error) {
+	root, err := filepath.Abs(importFrom)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(from) {
+		from = filepath.Join(root, from)
+	}
+	rel, err := filepath.Rel(root, filepath.Clean(from))
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("invalid path to import from '%s' (must lie within --import-from)", from)
+	}
+	return filepath.Join(root, rel), nil
+}
+
+// importJob returns a job importing the GTFS files from the directory (or zip
+// archive) from (according to opts, recording meta) into a temporary DB file,
+// which (on success) is moved in place and swapped in (see reload). Unless
+// forced, the job fails rather than swapping in a feed with fewer rows than
+// required (see --min-rows). Stop overrides and amenities of the served feed
+// are kept (see gtfs.CopyStopOverrides and gtfs.CopyStopAmenities). The
+// progress of the job is an import report.
+func (s *server) importJob(from string, opts gtfs.ImportOptions, meta gtfs.FeedMeta, force bool) gtfs.JobFunc {
+	return func(ctx context.Context, progress func(interface{})) (interface{}, error) {
+		log.Printf("importing '%s'", from)
+		tmpPath := s.dbPath + ".import"
+		report := newImportReport(from, s.dbPath)
+		opts.OnProgress = func(e gtfs.ImportEvent) {
+			if report.Estimate == nil && e.Estimate != nil {
+				report.addEstimate(e.Estimate)
+			}
+			report.add(e.Result)
+			progress(report.snapshot())
+		}
+		err := importInto(ctx, s.driver, from, tmpPath, opts, meta)
+
+		// compare IDs with the served (i.e. the previous) version of the feed
+		if err == nil {
+			if stability, errCompare := s.compareIDs(tmpPath); errCompare == nil {
+				report.addIDStability(stability)
+				progress(report.snapshot())
+			} else {
+				log.Printf("failed to compare IDs: %v", errCompare)
+			}
+		}
+
+		// refuse to swap in a drastically shrunken feed (e.g. a broken upload)
+		if err == nil && len(s.minRows) > 0 && !force {
+			var violations []gtfs.RowCountViolation
+			if violations, err = s.checkRowCounts(tmpPath); err == nil && len(violations) > 0 {
+				report.addRowCountViolations(violations)
+				progress(report.snapshot())
+				err = fmt.Errorf("refusing to swap in a feed with too few rows in %d file(s) (see --min-rows, or pass force=true)", len(violations))
+			}
+		}
+
+		// keep the stop overrides of the served feed
+		if err == nil {
+			var copied int
+			if copied, err = s.copyStopOverrides(tmpPath); err == nil && copied > 0 {
+				log.Printf("kept %d stop overrides", copied)
+			}
+		}
+
+		// keep the stop amenities of the served feed
+		if err == nil {
+			var copied int
+			if copied, err = s.copyStopAmenities(tmpPath); err == nil && copied > 0 {
+				log.Printf("kept the amenities of %d stops", copied)
+			}
+		}
+
+		// archive the served feed (if desired)
+		if err == nil && s.keep > 0 {
+			err = archiveDB(s.dbPath, s.keep)
+		}
+
+		if err == nil {
+			err = os.Rename(tmpPath, s.dbPath)
+		}
+		var f *feed
+		if err == nil {
+			f, err = openFeed(s.driver, s.dbPath, s.slowQuery, s.statementTimeout)
+		}
+		report.finish()
+		if err != nil {
+			log.Printf("failed to import '%s': %v", from, err)
+			return report.snapshot(), err
+		}
+		s.swap(f)
+		log.Printf("imported and reloaded '%s'", s.dbPath)
+		return report.snapshot(), nil
+	}
+}
+
+// compareIDs compares the IDs of the served feed with those of the feed
+// within the DB file at dbPath (see gtfs.CompareIDs).
+func (s *server) compareIDs(dbPath string) ([]gtfs.IDStability, error) {
+	var stability []gtfs.IDStability
+	err := s.withImported(dbPath, func(served, imported *gorm.DB) (err error) {
+		stability, err = gtfs.CompareIDs(served, imported)
+		return err
+	})
+	return stability, err
+}
+
+// checkRowCounts checks the number of rows of the feed within the DB file at
+// dbPath against those of the served feed (see gtfs.CheckRowCounts).
+func (s *server) checkRowCounts(dbPath string) ([]gtfs.RowCountViolation, error) {
+	var violations []gtfs.RowCountViolation
+	err := s.withImported(dbPath, func(served, imported *gorm.DB) (err error) {
+		violations, err = gtfs.CheckRowCounts(served, imported, s.minRows)
+		return err
+	})
+	return violations, err
+}
+
+// copyStopOverrides copies the stop overrides of the served feed to the feed
+// within the DB file at dbPath (see gtfs.CopyStopOverrides).
+func (s *server) copyStopOverrides(dbPath string) (int, error) {
+	var copied int
+	err := s.withImported(dbPath, func(served, imported *gorm.DB) (err error) {
+		copied, err = gtfs.CopyStopOverrides(served, imported)
+		return err
+	})
+	return copied, err
+}
+
+// copyStopAmenities copies the stop amenities of the served feed to the feed
+// within the DB file at dbPath (see gtfs.CopyStopAmenities).
+func (s *server) copyStopAmenities(dbPath string) (int, error) {
+	var copied int
+	err := s.withImported(dbPath, func(served, imported *gorm.DB) (err error) {
+		copied, err = gtfs.CopyStopAmenities(served, imported)
+		return err
+	})
+	return copied, err
+}
+
+// withImported opens the DB file at dbPath (i.e. a freshly imported feed) and
+// calls fn with the DB of the served feed and the opened DB.
+func (s *server) withImported(dbPath string, fn func(served, imported *gorm.DB) error) error {
+	dial, err := openDialector(s.driver, dbPath)
+	if err != nil {
+		return err
+	}
+	db, err := gorm.Open(dial, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = sqlDB.Close()
+	}()
+
+	f, release := s.acquire()
+	defer release()
+	return fn(f.db, db)
+}
+
+// trimJob returns a job trimming the served DB (in place) and swapping in the
+// result.
+func (s *server) trimJob(agency string, opts gtfs.TrimOptions) gtfs.JobFunc {
+	return func(ctx context.Context, _ func(interface{})) (interface{}, error) {
+		f, release := s.acquire()
+		r, err := gtfs.Trim(f.db.WithContext(ctx), agency, opts)
+		release()
+		if err != nil {
+			return nil, err
+		}
+		nf, err := openFeed(s.driver, s.dbPath, s.slowQuery, s.statementTimeout)
+		if err != nil {
+			return nil, err
+		}
+		s.swap(nf)
+		return r.String(), nil
+	}
+}
+
+// exportJob returns a job exporting the served DB to dir.
+func (s *server) exportJob(dir string, opts gtfs.ExportOptions) gtfs.JobFunc {
+	return func(ctx context.Context, _ func(interface{})) (interface{}, error) {
+		f, release := s.acquire()
+		defer release()
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		return dir, gtfs.Export(f.db.WithContext(ctx), dir, opts)
+	}
+}
+
+// validateJob returns a job validating the served DB. The result are the
+// issues found.
+func (s *server) validateJob() gtfs.JobFunc {
+	return func(ctx context.Context, _ func(interface{})) (interface{}, error) {
+		f, release := s.acquire()
+		defer release()
+		issues, err := gtfs.Validate(f.db.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+		result := make([]string, len(issues))
+		for i, issue := range issues {
+			result[i] = issue.String()
+		}
+		return result, nil
+	}
+}
+
+// importInto imports the GTFS files from the directory (or zip archive) from
+// into a fresh DB file at dbPath according to opts (calling opts.OnProgress
+// with the result of importing each file, along with the estimated size of the
+// import) and records meta as the origin of the feed. Imports are refused, if
+// the estimated DB exceeds the free space at dbPath (see gtfs.EstimateImport).
+func importInto(ctx context.Context, driver, from, dbPath string, opts gtfs.ImportOptions, meta gtfs.FeedMeta) error {
+
+	// delete db-file, if it exists
+	if err := os.Remove(dbPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove old db file '%s'", dbPath)
+	}
+
+	// open gorm db
+	dial, err := openDialector(driver, dbPath)
+	if err != nil {
+		return err
+	}
+	db, err := gorm.Open(dial, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = sqlDB.Close()
+	}()
+	db = db.WithContext(ctx)
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	// refuse to import if lacking space
+	estimate, err := gtfs.EstimateImport(db, from)
+	if err != nil {
+		return fmt.Errorf("failed to estimate import: %w", err)
+	}
+	if err = estimate.Check(); err != nil {
+		return err
+	}
+
+	// import CSV files, tracking progress
+	opts.Estimate = estimate
+	err = gtfs.DefaultImporter{}.Import(db, from, opts)
+	if err != nil {
+		return fmt.Errorf("failed to import feed: %w", err)
+	}
+
+	// import the translations of names (if any)
+	if _, err = gtfs.ImportTranslations(db, from); err != nil {
+		return err
+	}
+
+	// import the fares (if any)
+	if _, err = gtfs.ImportFares(db, from); err != nil {
+		return err
+	}
+
+	// import the vehicle capacities (if any)
+	if _, err = gtfs.ImportVehicleCapacities(db, from); err != nil {
+		return err
+	}
+
+	// record the origin of the feed
+	return gtfs.RecordFeedMeta(db, from, meta)
+}
+
+// persistImportJob (atomically) writes the status of an import job to the
+// job path, such that clients may follow the import even after a restart.
+func (s *server) persistImportJob(status gtfs.JobStatus) {
+	if status.Type != importJobType {
+		return
+	}
+	b, err := json.MarshalIndent(status, "", "  ")
+	if err == nil {
+		tmp := s.jobPath() + ".tmp"
+		if err = os.WriteFile(tmp, b, 0o644); err == nil {
+			err = os.Rename(tmp, s.jobPath())
+		}
+	}
+	if err != nil {
+		log.Printf("failed to persist import job: %v", err)
+	}
+}
+
+// loadImportJob loads the status of the import job persisted at the job path.
+// As jobs persisted as queued or running are no longer running (i.e. the
+// server was stopped while importing), they are marked as interrupted.
+func (s *server) loadImportJob() (*gtfs.JobStatus, error) {
+	b, err := os.ReadFile(s.jobPath())
+	if err != nil {
+		return nil, err
+	}
+	var status gtfs.JobStatus
+	if err = json.Unmarshal(b, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse import job: %w", err)
+	}
+	if !status.State.Finished() {
+		status.State = gtfs.JobInterrupted
+	}
+	return &status, nil
+}
+
+// parseMinRows parses specifications of the minimum percentage of rows of a
+// file imports must retain, i.e. "<file>=<percent>" (e.g. "stop_times=90").
+func parseMinRows(specs []string) (map[gtfs.ItemType]float64, error) {
+	minRows := map[gtfs.ItemType]float64{}
+	for _, spec := range specs {
+		i := strings.Index(spec, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid minimum rows '%s' (expected e.g. stop_times=90)", spec)
+		}
+		itemType, ok := fileItemTypes[spec[:i]]
+		if !ok {
+			return nil, fmt.Errorf("unknown file '%s'", spec[:i])
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(spec[i+1:], "%"), 64)
+		if err != nil || percent < 0 {
+			return nil, fmt.Errorf("invalid percentage '%s'", spec[i+1:])
+		}
+		minRows[itemType] = percent
+	}
+	return minRows, nil
+}
@@ -0,0 +1,144 @@
+package commands
+
+import (
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestServer_NewJob(t *testing.T) {
+	importFrom, exportTo := t.TempDir(), t.TempDir()
+	tests := []struct {
+		name    string
+		jobType string
+		params  url.Values
+		wantErr bool
+	}{
+		{"import", importJobType, nil, false},
+		{"import from within", importJobType, url.Values{"from": {filepath.Join(importFrom, "feed.zip")}}, false},
+		{"import relative", importJobType, url.Values{"from": {"feed.zip"}}, false},
+		{"import from outside", importJobType, url.Values{"from": {"/etc"}}, true},
+		{"import escaping", importJobType, url.Values{"from": {"../feed"}}, true},
+		{"export", exportJobType, url.Values{"dir": {"weekly"}}, false},
+		{"export absolute", exportJobType, url.Values{"dir": {"/tmp/weekly"}}, true},
+		{"export escaping", exportJobType, url.Values{"dir": {"../weekly"}}, true},
+		{"export missing", exportJobType, nil, true},
+	}
+	s, _ := newTestServer(t, importFrom, exportTo, "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.newJob(tt.jobType, tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("newJob() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// neither importing nor exporting is enabled by default
+	s, _ = newTestServer(t, "", "", "")
+	for _, jobType := range []string{importJobType, exportJobType} {
+		if _, err := s.newJob(jobType, url.Values{"from": {importFrom}, "dir": {"weekly"}}); err == nil {
+			t.Errorf("newJob(%s) error = nil, want an error", jobType)
+		}
+	}
+}
+
+func TestAdminToken(t *testing.T) {
+	tests := []struct {
+		name string
+		flag string
+		env  string
+		want string
+	}{
+		{"none", "", "", ""},
+		{"flag", "secret", "", "secret"},
+		{"env", "", "from env", "from env"},
+		{"flag over env", "secret", "from env", "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(adminTokenEnv, tt.env)
+			cmd := &cobra.Command{}
+			addAdminTokenFlag(cmd, "token")
+			if tt.flag != "" {
+				if err := cmd.Flags().Set("admin-token", tt.flag); err != nil {
+					t.Fatalf("failed to set flag: %v", err)
+				}
+			}
+			if got, err := adminToken(cmd); err != nil || got != tt.want {
+				t.Errorf("adminToken() = %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportJobOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  url.Values
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"options", url.Values{"mode": {"lenient"}, "only": {"stops", "routes"}, "batch-size": {"10"}, "max-duration": {"1m0s"}, "delimiter": {";"}}, false},
+		{"profile", url.Values{"profile": {"delfi"}}, false},
+		{"unknown profile", url.Values{"profile": {"unknown"}}, true},
+		{"invalid bool", url.Values{"lenient": {"maybe"}}, true},
+		{"invalid mode", url.Values{"mode": {"sloppy"}}, true},
+		{"unknown file", url.Values{"only": {"stations"}}, true},
+		{"invalid time", url.Values{"retrieved-at": {"yesterday"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := importJobOptions(tt.params); (err != nil) != tt.wantErr {
+				t.Errorf("importJobOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	opts, meta, err := importJobOptions(url.Values{"mode": {"lenient"}, "only": {"stops", "routes"}, "source-url": {"https://example.com/feed.zip"}})
+	if err != nil {
+		t.Fatalf("importJobOptions() error = %v", err)
+	}
+	if opts.Mode != gtfs.ImportLenient || len(opts.ItemTypes) != 2 || meta.SourceURL != "https://example.com/feed.zip" {
+		t.Errorf("importJobOptions() = %+v, %+v", opts, meta)
+	}
+}
+
+func TestAsyncParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    url.Values
+		wantErr bool
+	}{
+		{"none", []string{"--async"}, url.Values{}, false},
+		{"forwarded", []string{"--async", "--mode", "lenient", "--only", "stops,routes"}, url.Values{"mode": {"lenient"}, "only": {"stops", "routes"}}, false},
+		{"defaults", []string{"--async", "--batch-size", "1000"}, url.Values{"batch-size": {"1000"}}, false},
+		{"parent", []string{"--async", "--driver", "sqlite"}, url.Values{}, false},
+		{"unsupported", []string{"--async", "--report", "report.json"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			cmd.Flags().String("mode", "default", "")
+			cmd.Flags().StringSlice("only", nil, "")
+			cmd.Flags().Int("batch-size", 1000, "")
+			cmd.Flags().String("report", "", "")
+			addAsyncFlags(cmd)
+			root := &cobra.Command{}
+			root.PersistentFlags().String("driver", "sqlite", "")
+			root.AddCommand(cmd)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatalf("failed to parse flags: %v", err)
+			}
+			got, err := asyncParams(cmd, []string{"mode", "only", "batch-size"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("asyncParams() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.Encode() != tt.want.Encode() {
+				t.Errorf("asyncParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
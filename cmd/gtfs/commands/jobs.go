@@ -0,0 +1,192 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+)
+
+// addAsyncFlags adds the flags to run a command as a job of a running server.
+func addAsyncFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("async", false, "submit the operation as a job to the server serving the DB (see gtfs jobs)")
+	cmd.Flags().String("server", "http://localhost:8080", "URL of the server to submit jobs to")
+	addAdminTokenFlag(cmd, "bearer token to authenticate with the server")
+}
+
+// adminTokenEnv is the environment variable the admin token defaults to.
+const adminTokenEnv = "GTFS_ADMIN_TOKEN"
+
+// addAdminTokenFlag adds the flag of the token authenticating admin requests.
+func addAdminTokenFlag(cmd *cobra.Command, usage string) {
+	cmd.Flags().String("admin-token", "", usage+" (defaults to $"+adminTokenEnv+")")
+}
+
+// adminToken returns the token authenticating admin requests, defaulting to
+// the environment variable GTFS_ADMIN_TOKEN (keeping it out of process
+// listings).
+func adminToken(cmd *cobra.Command) (string, error) {
+	token, err := cmd.Flags().GetString("admin-token")
+	if err != nil || token != "" {
+		return token, err
+	}
+	return os.Getenv(adminTokenEnv), nil
+}
+
+// async reports whether the command should be submitted as a job.
+func async(cmd *cobra.Command) (bool, error) {
+	return cmd.Flags().GetBool("async")
+}
+
+// asyncParams returns the values of the flags of cmd set on the command line
+// (among the given ones) to forward as parameters of a job, named like the
+// flags. Other flags of cmd (than those added by addAsyncFlags) are not
+// supported by the job and thus refused.
+func asyncParams(cmd *cobra.Command, names []string) (url.Values, error) {
+	forward := map[string]bool{}
+	for _, name := range names {
+		forward[name] = true
+	}
+	params := url.Values{}
+	var err error
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		switch {
+		case err != nil:
+		case f.Name == "async" || f.Name == "server" || f.Name == "admin-token":
+		case cmd.LocalFlags().Lookup(f.Name) == nil:
+			// flags of the parent commands (e.g. --driver)
+		case !forward[f.Name]:
+			err = fmt.Errorf("--%s is not supported with --async", f.Name)
+		case f.Value.Type() == "stringSlice":
+			params[f.Name], err = cmd.Flags().GetStringSlice(f.Name)
+		default:
+			params.Set(f.Name, f.Value.String())
+		}
+	})
+	return params, err
+}
+
+// submitJob submits a job of the given type (configured by params) to the
+// server serving the DB at dbPath and prints its ID.
+func submitJob(cmd *cobra.Command, jobType, dbPath string, params url.Values) error {
+	server, err := cmd.Flags().GetString("server")
+	if err != nil {
+		return err
+	}
+	if params == nil {
+		params = url.Values{}
+	}
+	params.Set("type", jobType)
+
+	// the server may run in another working directory
+	db, err := filepath.Abs(dbPath)
+	if err != nil {
+		return err
+	}
+	params.Set("db", db)
+
+	token, err := adminToken(cmd)
+	if err != nil {
+		return err
+	}
+	var job gtfs.JobStatus
+	if err = callServer(http.MethodPost, server+"/admin/jobs?"+params.Encode(), token, &job); err != nil {
+		return err
+	}
+	fmt.Printf("submitted %s job %s (follow it via: gtfs jobs %s)\n", job.Type, job.ID, job.ID)
+	return nil
+}
+
+// callServer sends a request to the server (authenticated by the admin token,
+// if not empty) and decodes the JSON response into v. Responses other than 2xx
+// are returned as errors.
+func callServer(method, u, token string, v interface{}) error {
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call server: %w", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var s status
+		if err = json.NewDecoder(resp.Body).Decode(&s); err != nil || s.Error == "" {
+			return fmt.Errorf("server responded with %s", resp.Status)
+		}
+		return fmt.Errorf("server responded with %s: %s", resp.Status, s.Error)
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func gtfsJobs(cmd *cobra.Command, args []string) error {
+	server, err := cmd.Flags().GetString("server")
+	if err != nil {
+		return err
+	}
+	cancel, err := cmd.Flags().GetBool("cancel")
+	if err != nil {
+		return err
+	}
+	token, err := adminToken(cmd)
+	if err != nil {
+		return err
+	}
+
+	// list all jobs
+	if len(args) == 0 {
+		if cancel {
+			return errors.New("--cancel requires a job ID")
+		}
+		var jobs []gtfs.JobStatus
+		if err = callServer(http.MethodGet, server+"/admin/jobs", token, &jobs); err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "ID\tTYPE\tSTATE\tCREATED\tERROR")
+		for _, job := range jobs {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", job.ID, job.Type, job.State, job.Created.Format(time.RFC3339), job.Error)
+		}
+		return tw.Flush()
+	}
+
+	// cancel a single job
+	u := server + "/admin/jobs/" + url.PathEscape(args[0])
+	if cancel {
+		if err = callServer(http.MethodDelete, u, token, nil); err != nil {
+			return err
+		}
+		fmt.Printf("canceled job %s\n", args[0])
+		return nil
+	}
+
+	// print the status of a single job
+	var job json.RawMessage
+	if err = callServer(http.MethodGet, u, token, &job); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err = json.Indent(&b, job, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(b.String())
+	return nil
+}
@@ -0,0 +1,32 @@
+package commands
+
+import (
+	"github.com/heimdalr/gtfs"
+	"net/http"
+)
+
+// translator translates names into the language negotiated for a request
+// (see newTranslator). The zero translator translates nothing.
+type translator struct {
+	translations *gtfs.Translations
+	lang         string
+}
+
+// newTranslator negotiates the language of the response to r (according to its
+// Accept-Language header and the translations of the feed) and announces it
+// by the response headers.
+func newTranslator(w http.ResponseWriter, r *http.Request, f *feed) translator {
+	w.Header().Add("Vary", "Accept-Language")
+	lang := f.translations.Negotiate(r.Header.Get("Accept-Language"))
+	if lang != "" {
+		w.Header().Set("Content-Language", lang)
+	}
+	return translator{translations: f.translations, lang: lang}
+}
+
+// translate returns the translation of the field of the record with the given
+// ID within the given table, falling back to value (see
+// gtfs.Translations.Translate).
+func (t translator) translate(table, field, recordID, value string) string {
+	return t.translations.Translate(table, field, recordID, value, t.lang)
+}
@@ -0,0 +1,101 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func gtfsRoutesLines(cmd *cobra.Command, args []string) error {
+	agency, err := cmd.Flags().GetString("agency")
+	if err != nil {
+		return err
+	}
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	lines, err := gtfs.ListLines(db, agency)
+	if err != nil {
+		return fmt.Errorf("failed to list lines: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LINE\tNAME\tROUTES\tTRIPS\tSTOPS")
+	for _, l := range lines {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", l.ID, l.Name, strings.Join(l.RouteIDs, ","), l.Trips, l.Stops)
+	}
+	return w.Flush()
+}
+
+// lineResponse is the type used to describe a line (along with its branding)
+// in API responses.
+type lineResponse struct {
+	ID        string   `json:"id"`
+	AgencyID  string   `json:"agency_id"`
+	Name      string   `json:"name"`
+	Type      int      `json:"type"`
+	Color     string   `json:"color"`
+	TextColor string   `json:"text_color"`
+	RouteIDs  []string `json:"route_ids"`
+	Trips     int      `json:"trips"`
+	Stops     int      `json:"stops"`
+}
+
+// newLineResponse converts a line into its API representation (defaulting
+// colors as specified by GTFS and with its name translated by t, like the
+// short name of its first route).
+func newLineResponse(l gtfs.Line, t translator) lineResponse {
+	color, textColor := gtfs.Route{Color: l.Color, TextColor: l.TextColor}.RouteColors()
+	return lineResponse{
+		ID:        l.ID,
+		AgencyID:  l.AgencyID,
+		Name:      t.translate("routes", "route_short_name", l.RouteIDs[0], l.Name),
+		Type:      l.Type,
+		Color:     color,
+		TextColor: textColor,
+		RouteIDs:  l.RouteIDs,
+		Trips:     l.Trips,
+		Stops:     l.Stops,
+	}
+}
+
+// lines lists all lines (if the path ends with "/lines", optionally filtered
+// by the query parameter "agency") or describes the line with the ID given by
+// the path.
+func (s *server) lines(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	f, release := s.acquire()
+	defer release()
+	db := f.db.WithContext(r.Context())
+	t := newTranslator(w, r, f)
+
+	id := strings.TrimPrefix(r.URL.Path, s.prefix+"/lines/")
+	if id == r.URL.Path {
+		lines, err := gtfs.ListLines(db, r.URL.Query().Get("agency"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, status{Status: "error", Error: err.Error()})
+			return
+		}
+		resp := make([]lineResponse, len(lines))
+		for i, l := range lines {
+			resp[i] = newLineResponse(l, t)
+		}
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	line, err := gtfs.GetLine(db, id)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, newLineResponse(*line, t))
+}
@@ -0,0 +1,129 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// occupancyStore holds the realtime occupancy of trips (by trip ID), as pushed
+// by a GTFS-RT consumer (see occupancies).
+type occupancyStore struct {
+	mu          sync.RWMutex
+	occupancies map[string]gtfs.TripOccupancy
+}
+
+// get returns the current occupancies (which must not be modified).
+func (oc *occupancyStore) get() map[string]gtfs.TripOccupancy {
+	oc.mu.RLock()
+	defer oc.mu.RUnlock()
+	return oc.occupancies
+}
+
+// set replaces the current occupancies.
+func (oc *occupancyStore) set(occupancies map[string]gtfs.TripOccupancy) {
+	oc.mu.Lock()
+	defer oc.mu.Unlock()
+	oc.occupancies = occupancies
+}
+
+// occupancyRequest is the type used to describe the occupancy of the vehicle
+// of a trip in API requests and responses (named like the fields of GTFS-RT
+// vehicle positions).
+type occupancyRequest struct {
+	TripID     string `json:"trip_id"`
+	Status     string `json:"occupancy_status,omitempty"`
+	Percentage *int   `json:"occupancy_percentage,omitempty"`
+}
+
+// occupancyResponse is the type used to describe the occupancy of the vehicle
+// of a departure in API responses.
+type occupancyResponse struct {
+	Status           string `json:"occupancy_status"`
+	Percentage       *int   `json:"occupancy_percentage,omitempty"`
+	Crowding         string `json:"crowding"`
+	SeatedCapacity   int    `json:"seated_capacity,omitempty"`
+	StandingCapacity int    `json:"standing_capacity,omitempty"`
+}
+
+// newOccupancyResponse returns the description of the given occupancy (nil, if
+// unknown).
+func newOccupancyResponse(o *gtfs.Occupancy) *occupancyResponse {
+	if o == nil {
+		return nil
+	}
+	resp := &occupancyResponse{
+		Status:           o.Status.String(),
+		Crowding:         o.Crowding.String(),
+		SeatedCapacity:   o.SeatedCapacity,
+		StandingCapacity: o.StandingCapacity,
+	}
+	if o.Percentage >= 0 {
+		percentage := o.Percentage
+		resp.Percentage = &percentage
+	}
+	return resp
+}
+
+// occupancies lists the current occupancies of trips (GET) or replaces them
+// (PUT, given as JSON list), such that departures report the expected
+// crowding of their vehicles. Occupancies are kept in memory only and are not
+// affected by reloads.
+func (s *server) occupancies(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		current := s.occupancy.get()
+		resp := make([]occupancyRequest, 0, len(current))
+		for _, o := range current {
+			req := occupancyRequest{TripID: o.TripID, Status: o.Status.String()}
+			if o.Percentage >= 0 {
+				percentage := o.Percentage
+				req.Percentage = &percentage
+			}
+			resp = append(resp, req)
+		}
+		sort.Slice(resp, func(i, j int) bool {
+			return resp[i].TripID < resp[j].TripID
+		})
+		writeJSON(w, http.StatusOK, resp)
+
+	case http.MethodPut:
+		var req []occupancyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: "invalid occupancies: " + err.Error()})
+			return
+		}
+		occupancies := make(map[string]gtfs.TripOccupancy, len(req))
+		for _, o := range req {
+			if o.TripID == "" {
+				writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: "missing trip_id"})
+				return
+			}
+			to := gtfs.TripOccupancy{TripID: o.TripID, Status: gtfs.OccupancyNoDataAvailable, Percentage: -1}
+			if o.Status != "" {
+				var err error
+				if to.Status, err = gtfs.ParseOccupancyStatus(o.Status); err != nil {
+					writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: err.Error()})
+					return
+				}
+			}
+			if o.Percentage != nil {
+				if *o.Percentage < 0 {
+					writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: fmt.Sprintf("invalid occupancy_percentage %d", *o.Percentage)})
+					return
+				}
+				to.Percentage = *o.Percentage
+			}
+			occupancies[o.TripID] = to
+		}
+		s.occupancy.set(occupancies)
+		writeJSON(w, http.StatusOK, status{Status: "ok"})
+
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPut)
+		writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+	}
+}
@@ -0,0 +1,200 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"log"
+	"math"
+	"os"
+)
+
+// the margin (in pixels) around rendered maps
+const renderMargin = 20
+
+var (
+	shapeColor = color.RGBA{R: 0x00, G: 0x6e, B: 0xb7, A: 0xff}
+	stopColor  = color.RGBA{R: 0xd4, G: 0x00, B: 0x2d, A: 0xff}
+)
+
+// projection maps coordinates (equirectangular) to the pixels of an image.
+type projection struct {
+	minLon, maxLat float64
+	cosLat         float64
+	scale          float64
+}
+
+// newProjection returns a projection fitting the given coordinates into an
+// image of the given size.
+func newProjection(lats, lons []float64, width, height int) projection {
+	minLat, maxLat := math.Inf(1), math.Inf(-1)
+	minLon, maxLon := math.Inf(1), math.Inf(-1)
+	for i := range lats {
+		minLat, maxLat = math.Min(minLat, lats[i]), math.Max(maxLat, lats[i])
+		minLon, maxLon = math.Min(minLon, lons[i]), math.Max(maxLon, lons[i])
+	}
+	p := projection{minLon: minLon, maxLat: maxLat, cosLat: math.Cos((minLat + maxLat) / 2 * math.Pi / 180)}
+
+	// fit the bounding box (avoiding division by zero for single points)
+	dx := math.Max((maxLon-minLon)*p.cosLat, 1e-9)
+	dy := math.Max(maxLat-minLat, 1e-9)
+	p.scale = math.Min(float64(width-2*renderMargin)/dx, float64(height-2*renderMargin)/dy)
+	return p
+}
+
+// point returns the pixel of the given coordinates.
+func (p projection) point(lat, lon float64) image.Point {
+	return image.Point{
+		X: renderMargin + int(math.Round((lon-p.minLon)*p.cosLat*p.scale)),
+		Y: renderMargin + int(math.Round((p.maxLat-lat)*p.scale)),
+	}
+}
+
+// fillCircle draws a filled circle.
+func fillCircle(img *image.RGBA, center image.Point, radius int, c color.Color) {
+	for y := -radius; y <= radius; y++ {
+		for x := -radius; x <= radius; x++ {
+			if x*x+y*y <= radius*radius {
+				img.Set(center.X+x, center.Y+y, c)
+			}
+		}
+	}
+}
+
+// drawLine draws a line of the given width.
+func drawLine(img *image.RGBA, from, to image.Point, width int, c color.Color) {
+	dx, dy := to.X-from.X, to.Y-from.Y
+	steps := int(math.Max(math.Abs(float64(dx)), math.Abs(float64(dy))))
+	if steps == 0 {
+		fillCircle(img, from, width/2, c)
+		return
+	}
+	for i := 0; i <= steps; i++ {
+		p := image.Point{X: from.X + dx*i/steps, Y: from.Y + dy*i/steps}
+		fillCircle(img, p, width/2, c)
+	}
+}
+
+func gtfsRenderRoute(cmd *cobra.Command, args []string) error {
+	routeID := args[1]
+
+	outPath, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+	width, err := cmd.Flags().GetInt("width")
+	if err != nil {
+		return err
+	}
+	height, err := cmd.Flags().GetInt("height")
+	if err != nil {
+		return err
+	}
+
+	// some argument validation
+	if outPath == "" {
+		return errors.New("empty out")
+	}
+	if width <= 2*renderMargin || height <= 2*renderMargin {
+		return fmt.Errorf("image must be larger than %dx%d pixels", 2*renderMargin, 2*renderMargin)
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	shapes, err := gtfs.RouteShapes(db, routeID)
+	if err != nil {
+		return fmt.Errorf("failed to get shapes of route '%s': %w", routeID, err)
+	}
+	stops, err := gtfs.RouteStops(db, routeID)
+	if err != nil {
+		return fmt.Errorf("failed to get stops of route '%s': %w", routeID, err)
+	}
+
+	// collect all coordinates
+	var lats, lons []float64
+	for _, points := range shapes {
+		for _, p := range points {
+			lats, lons = append(lats, p.PtLat), append(lons, p.PtLon)
+		}
+	}
+	for _, s := range stops {
+		lats, lons = append(lats, s.Latitude), append(lons, s.Longitude)
+	}
+	if len(lats) == 0 {
+		return fmt.Errorf("route '%s' has neither shapes nor stops", routeID)
+	}
+	proj := newProjection(lats, lons, width, height)
+
+	// draw shapes and stops on a blank background
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for _, points := range shapes {
+		for i := 1; i < len(points); i++ {
+			from := proj.point(points[i-1].PtLat, points[i-1].PtLon)
+			to := proj.point(points[i].PtLat, points[i].PtLon)
+			drawLine(img, from, to, 3, shapeColor)
+		}
+	}
+	for _, s := range stops {
+		center := proj.point(s.Latitude, s.Longitude)
+		fillCircle(img, center, 5, color.Black)
+		fillCircle(img, center, 3, stopColor)
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to encode PNG: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	log.Printf("rendered %d shapes and %d stops of route '%s' to '%s'", len(shapes), len(stops), routeID, outPath)
+	return nil
+}
+
+func gtfsRenderDiagram(cmd *cobra.Command, args []string) error {
+	routeID := args[1]
+
+	outPath, err := cmd.Flags().GetString("out")
+	if err != nil {
+		return err
+	}
+
+	// some argument validation
+	if outPath == "" {
+		return errors.New("empty out")
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	if err = gtfs.ExportRouteDiagram(db, routeID, f); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to export diagram of route '%s': %w", routeID, err)
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	log.Printf("rendered diagram of route '%s' to '%s'", routeID, outPath)
+	return nil
+}
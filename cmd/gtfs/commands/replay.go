@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+)
+
+func gtfsReplay(cmd *cobra.Command, args []string) error {
+	speedup, err := cmd.Flags().GetFloat64("speedup")
+	if err != nil {
+		return err
+	}
+	routeIDs, err := cmd.Flags().GetStringSlice("route")
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	days, err := parseDates(db, args[1])
+	if err != nil {
+		return err
+	}
+	replay, err := gtfs.ReplayDay(db, days[0], speedup, routeIDs...)
+	if err != nil {
+		return fmt.Errorf("failed to replay: %w", err)
+	}
+	for e := range replay.Events {
+		fmt.Println(e.String())
+	}
+	if err = replay.Err(); err != nil {
+		return fmt.Errorf("failed to replay: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package commands
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// importReport is the type used to describe (and persist) the result of
+// importing a GTFS feed.
+type importReport struct {
+	Source      string                   `json:"source"`
+	DB          string                   `json:"db"`
+	Started     time.Time                `json:"started"`
+	DurationMS  int64                    `json:"duration_ms"`
+	Estimate    *importReportEstimate    `json:"estimate,omitempty"`
+	Files       []importReportFile       `json:"files"`
+	Totals      importReportTotals       `json:"totals"`
+	IDs         []importReportIDs        `json:"id_stability,omitempty"`
+	SkippedRows []importReportSkippedRow `json:"skipped_rows,omitempty"`
+	Warnings    []string                 `json:"warnings"`
+}
+
+// importReportFile is the type used to describe the import of a single file.
+type importReportFile struct {
+	File       string `json:"file"`
+	ItemType   string `json:"item_type"`
+	SHA256     string `json:"sha256,omitempty"`
+	Count      int64  `json:"count"`
+	Duplicates int64  `json:"duplicates,omitempty"`
+	Rejected   int64  `json:"rejected,omitempty"`
+	Malformed  int64  `json:"malformed,omitempty"`
+	Normalized int64  `json:"normalized,omitempty"`
+	Batches    int64  `json:"batches"`
+	DurationMS int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+	RolledBack bool   `json:"rolled_back,omitempty"`
+	Truncated  bool   `json:"truncated,omitempty"`
+}
+
+// importReportSkippedRow is the type used to describe a row failed to parse
+// and skipped (see gtfs.ImportLenient).
+type importReportSkippedRow struct {
+	File  string `json:"file"`
+	Line  int64  `json:"line"`
+	Raw   string `json:"raw"`
+	Error string `json:"error"`
+}
+
+// importReportEstimate is the type used to describe the estimated size of an
+// import.
+type importReportEstimate struct {
+	Rows      int64 `json:"rows"`
+	FileSize  int64 `json:"file_size"`
+	DBSize    int64 `json:"db_size"`
+	FreeSpace int64 `json:"free_space"`
+}
+
+// importReportTotals is the type used to describe the totals of an import.
+type importReportTotals struct {
+	Files   int   `json:"files"`
+	Failed  int   `json:"failed"`
+	Count   int64 `json:"count"`
+	Batches int64 `json:"batches"`
+}
+
+// importReportIDs is the type used to describe the stability of the IDs of an
+// item type compared to the previous version of the feed.
+type importReportIDs struct {
+	ItemType        string               `json:"item_type"`
+	Previous        int                  `json:"previous"`
+	Retained        int                  `json:"retained"`
+	RetainedPercent float64              `json:"retained_percent"`
+	Renamed         int                  `json:"renamed"`
+	Removed         int                  `json:"removed"`
+	Added           int                  `json:"added"`
+	Renames         []importReportRename `json:"renames,omitempty"`
+}
+
+// importReportRename is the type used to describe a renamed ID.
+type importReportRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// newImportReport initializes a report for importing source into db.
+func newImportReport(source, db string) *importReport {
+	return &importReport{Source: source, DB: db, Started: time.Now(), Warnings: []string{}}
+}
+
+// add adds the result of importing a single file to the report.
+func (ir *importReport) add(r *gtfs.ImportItemsResult) {
+	if r.Skipped {
+		ir.Warnings = append(ir.Warnings, r.String())
+		return
+	}
+	f := importReportFile{
+		File:       filepath.Base(r.Path),
+		ItemType:   r.ItemType.String(),
+		SHA256:     r.SHA256,
+		Count:      r.Count,
+		Duplicates: r.Duplicates,
+		Rejected:   r.Rejected,
+		Malformed:  r.Malformed,
+		Normalized: r.Normalized,
+		Batches:    r.Batches,
+		DurationMS: r.Time.Milliseconds(),
+		RolledBack: r.RolledBack,
+		Truncated:  r.Truncated,
+	}
+	ir.Totals.Files++
+	if r.Error != nil {
+		f.Error = r.Error.Error()
+		ir.Totals.Failed++
+		ir.Warnings = append(ir.Warnings, r.String())
+	} else if r.Truncated {
+		ir.Warnings = append(ir.Warnings, r.String())
+	}
+	for _, rejection := range r.Rejections {
+		ir.Warnings = append(ir.Warnings, fmt.Sprintf("rejected %s in %s", rejection, f.File))
+	}
+	for _, row := range r.SkippedRows {
+		ir.SkippedRows = append(ir.SkippedRows, importReportSkippedRow{File: row.File, Line: row.Line, Raw: row.Raw, Error: row.Error})
+	}
+	ir.Totals.Count += r.Count
+	ir.Totals.Batches += r.Batches
+	ir.Files = append(ir.Files, f)
+}
+
+// addEstimate adds the estimated size of the import to the report.
+func (ir *importReport) addEstimate(e *gtfs.ImportEstimate) {
+	ir.Estimate = &importReportEstimate{
+		Rows:      e.TotalRows,
+		FileSize:  e.FileSize,
+		DBSize:    e.DBSize,
+		FreeSpace: e.FreeSpace,
+	}
+}
+
+// addIDStability adds the stability of IDs compared to the previous version of
+// the feed to the report, warning about IDs not retained.
+func (ir *importReport) addIDStability(stability []gtfs.IDStability) {
+	for _, s := range stability {
+		ids := importReportIDs{
+			ItemType:        s.ItemType.String(),
+			Previous:        s.Previous,
+			Retained:        s.Retained,
+			RetainedPercent: s.RetainedPercent(),
+			Renamed:         s.Renamed,
+			Removed:         s.Removed,
+			Added:           s.Added,
+		}
+		for _, r := range s.Renames {
+			ids.Renames = append(ids.Renames, importReportRename{From: r.From, To: r.To})
+		}
+		ir.IDs = append(ir.IDs, ids)
+		if !s.Stable() {
+			ir.Warnings = append(ir.Warnings, s.String())
+		}
+	}
+}
+
+// addRowCountViolations adds warnings about files with fewer rows than
+// required (compared to the previous version of the feed) to the report.
+func (ir *importReport) addRowCountViolations(violations []gtfs.RowCountViolation) {
+	for _, v := range violations {
+		ir.Warnings = append(ir.Warnings, "too few rows: "+v.String())
+	}
+}
+
+// snapshot returns a copy of the report.
+func (ir *importReport) snapshot() *importReport {
+	c := *ir
+	c.Files = append([]importReportFile(nil), ir.Files...)
+	c.IDs = append([]importReportIDs(nil), ir.IDs...)
+	c.SkippedRows = append([]importReportSkippedRow(nil), ir.SkippedRows...)
+	c.Warnings = append([]string{}, ir.Warnings...)
+	return &c
+}
+
+// finish records the overall duration of the import.
+func (ir *importReport) finish() {
+	ir.DurationMS = time.Since(ir.Started).Milliseconds()
+}
+
+// write writes the report to the file at path. If the file has the extension
+// ".html", the report is written as HTML, otherwise as JSON.
+func (ir *importReport) write(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		err = importReportTemplate.Execute(file, ir)
+	default:
+		enc := json.NewEncoder(file)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(ir)
+	}
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// importReportTemplate is the template used to render reports as HTML.
+var importReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>GTFS import of {{.Source}}</title>
+</head>
+<body>
+<h1>GTFS import of {{.Source}}</h1>
+<p>Imported into {{.DB}} on {{.Started.Format "2006-01-02 15:04:05"}} in {{.DurationMS}} ms.</p>
+<table>
+<tr><th>File</th><th>Item Type</th><th>Count</th><th>Batches</th><th>Duration (ms)</th><th>SHA-256</th><th>Error</th></tr>
+{{range .Files}}<tr><td>{{.File}}</td><td>{{.ItemType}}</td><td>{{.Count}}</td><td>{{.Batches}}</td><td>{{.DurationMS}}</td><td>{{.SHA256}}</td><td>{{.Error}}{{if .RolledBack}} (rolled back){{end}}</td></tr>
+{{end}}<tr><th>{{.Totals.Files}} files ({{.Totals.Failed}} failed)</th><th></th><th>{{.Totals.Count}}</th><th>{{.Totals.Batches}}</th><th></th><th></th><th></th></tr>
+</table>
+{{if .IDs}}<h2>ID Stability</h2>
+<table>
+<tr><th>Item Type</th><th>Previous</th><th>Retained</th><th>Renamed</th><th>Removed</th><th>Added</th></tr>
+{{range .IDs}}<tr><td>{{.ItemType}}</td><td>{{.Previous}}</td><td>{{.Retained}} ({{printf "%.1f" .RetainedPercent}}%)</td><td>{{.Renamed}}</td><td>{{.Removed}}</td><td>{{.Added}}</td></tr>
+{{end}}</table>
+{{end}}{{if .SkippedRows}}<h2>Skipped Rows</h2>
+<table>
+<tr><th>File</th><th>Line</th><th>Row</th><th>Error</th></tr>
+{{range .SkippedRows}}<tr><td>{{.File}}</td><td>{{.Line}}</td><td><code>{{.Raw}}</code></td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{end}}{{if .Warnings}}<h2>Warnings</h2>
+<ul>
+{{range .Warnings}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
@@ -0,0 +1,37 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"log"
+	"os"
+)
+
+func gtfsRidership(cmd *cobra.Command, args []string) error {
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	n, err := gtfs.ImportRidership(db, file)
+	if err != nil {
+		return err
+	}
+	log.Printf("imported ridership of %d stops", n)
+	return nil
+}
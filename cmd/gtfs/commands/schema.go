@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func gtfsSchema(cmd *cobra.Command, _ []string) error {
+	driver, err := cmd.Flags().GetString("driver")
+	if err != nil {
+		return err
+	}
+	var newDialector func(conn gorm.ConnPool) gorm.Dialector
+	switch driver {
+	case "postgres":
+		newDialector = func(conn gorm.ConnPool) gorm.Dialector {
+			return postgres.New(postgres.Config{Conn: conn})
+		}
+	case "mysql":
+		newDialector = func(conn gorm.ConnPool) gorm.Dialector {
+			return mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true})
+		}
+	case "", "sqlite":
+		newDialector = func(conn gorm.ConnPool) gorm.Dialector {
+			return &sqlite.Dialector{Conn: conn}
+		}
+	default:
+		return fmt.Errorf("unknown driver '%s' (expected sqlite, postgres or mysql)", driver)
+	}
+
+	stmts, err := gtfs.MigrationDDL(newDialector)
+	if err != nil {
+		return err
+	}
+	for _, stmt := range stmts {
+		fmt.Printf("%s;\n", stmt)
+	}
+	return nil
+}
@@ -0,0 +1,626 @@
+package commands
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// feed is an opened GTFS DB (along with its catalog, the categories of its
+// services and its translations) shared by concurrent requests.
+type feed struct {
+	db           *gorm.DB
+	catalog      *gtfs.Catalog
+	categories   map[string]gtfs.ServiceCategory
+	translations *gtfs.Translations
+	tiles        tileCache
+	inFlight     sync.WaitGroup
+}
+
+// openFeed opens the GTFS DB at dbPath (a DSN, if driver is postgres or mysql,
+// see openDialector) and ensures it is usable. If slowQuery is positive,
+// queries slower than slowQuery are logged. If statementTimeout is positive,
+// statements running longer are cancelled.
+func openFeed(driver, dbPath string, slowQuery, statementTimeout time.Duration) (*feed, error) {
+
+	// don't let sqlite silently create an empty DB
+	server, err := isServerDriver(driver)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(dbPath); err != nil && !server {
+		return nil, err
+	}
+
+	// open gorm db
+	dial, err := openDialector(driver, dbPath)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(dial, &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Error),
+	})
+	if err != nil {
+		return nil, err
+	}
+	f := &feed{db: db}
+	if slowQuery > 0 {
+		if err = db.Use(&gtfs.SlowQueryLogger{Threshold: slowQuery}); err != nil {
+			f.close()
+			return nil, err
+		}
+	}
+	if statementTimeout > 0 {
+		if err = db.Use(&gtfs.StatementTimeout{Timeout: statementTimeout}); err != nil {
+			f.close()
+			return nil, err
+		}
+	}
+
+	// ensure this is a GTFS DB
+	if !db.Migrator().HasTable(&gtfs.Agency{}) {
+		f.close()
+		return nil, fmt.Errorf("'%s' is not a GTFS DB", dbPath)
+	}
+
+	// load the catalog once per feed
+	f.catalog, err = gtfs.LoadCatalog(db)
+	if err != nil {
+		f.close()
+		return nil, fmt.Errorf("failed to load catalog: %w", err)
+	}
+
+	// classify services once per feed
+	f.categories, err = gtfs.ClassifyServices(db)
+	if err != nil {
+		f.close()
+		return nil, fmt.Errorf("failed to classify services: %w", err)
+	}
+
+	// load translations once per feed
+	f.translations, err = gtfs.LoadTranslations(db)
+	if err != nil {
+		f.close()
+		return nil, fmt.Errorf("failed to load translations: %w", err)
+	}
+
+	return f, nil
+}
+
+// close closes the DB of the feed.
+func (f *feed) close() {
+	if sqlDB, err := f.db.DB(); err == nil {
+		_ = sqlDB.Close()
+	}
+}
+
+// ping checks the DB connection.
+func (f *feed) ping(ctx context.Context) error {
+	sqlDB, err := f.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// jobQueueSize is the number of jobs the server keeps queued (at most).
+const jobQueueSize = 16
+
+// server serves a GTFS DB via HTTP (below prefix).
+type server struct {
+	name             string
+	prefix           string
+	driver           string // the driver of the DB (see openDialector)
+	dbPath           string
+	slowQuery        time.Duration
+	importFrom       string // the directory import jobs import from
+	exportTo         string // the directory export jobs export below
+	adminToken       string // the bearer token required by admin requests (if not empty)
+	statementTimeout time.Duration
+	keep             int                       // the number of snapshots to keep of DBs replaced by imports
+	minRows          map[gtfs.ItemType]float64 // the minimum percentages of rows imports must retain
+	mu               sync.RWMutex
+	feed             *feed
+	occupancy        occupancyStore
+	jobs             *gtfs.JobQueue
+	lastImport       *gtfs.JobStatus
+}
+
+// status is the type used to describe the result of health, readiness and
+// admin requests.
+type status struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func gtfsServe(cmd *cobra.Command, args []string) error {
+	addr, err := cmd.Flags().GetString("addr")
+	if err != nil {
+		return err
+	}
+	shutdownTimeout, err := cmd.Flags().GetDuration("shutdown-timeout")
+	if err != nil {
+		return err
+	}
+	driver, err := cmd.Flags().GetString("driver")
+	if err != nil {
+		return err
+	}
+	dsn, err := cmd.Flags().GetString("dsn")
+	if err != nil {
+		return err
+	}
+	slowQuery, err := cmd.Flags().GetDuration("slow-query")
+	if err != nil {
+		return err
+	}
+	statementTimeout, err := cmd.Flags().GetDuration("statement-timeout")
+	if err != nil {
+		return err
+	}
+	importFrom, err := cmd.Flags().GetString("import-from")
+	if err != nil {
+		return err
+	}
+	exportTo, err := cmd.Flags().GetString("export-to")
+	if err != nil {
+		return err
+	}
+	token, err := adminToken(cmd)
+	if err != nil {
+		return err
+	}
+	feeds, err := cmd.Flags().GetStringToString("feeds")
+	if err != nil {
+		return err
+	}
+	keep, err := cmd.Flags().GetInt("keep-snapshots")
+	if err != nil {
+		return err
+	}
+	minRowsSpecs, err := cmd.Flags().GetStringSlice("min-rows")
+	if err != nil {
+		return err
+	}
+	minRows, err := parseMinRows(minRowsSpecs)
+	if err != nil {
+		return err
+	}
+
+	// some argument validation
+	if (len(args) == 0) == (len(feeds) == 0) {
+		return errors.New("pass either a dbPath or --feeds")
+	}
+	if len(args) > 0 {
+		if args[0] == "" {
+			return errors.New("empty dbPath")
+		}
+		feeds = map[string]string{"": args[0]}
+	} else if dsn != "" {
+		return errors.New("--dsn requires a single feed (rather than --feeds)")
+	}
+	names := make([]string, 0, len(feeds))
+	for name, dbPath := range feeds {
+		if len(args) == 0 && (name == "" || strings.ContainsAny(name, "/?#")) {
+			return fmt.Errorf("invalid feed name '%s'", name)
+		}
+		if dbPath == "" {
+			return fmt.Errorf("empty dbPath for feed '%s'", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// warn about admin requests (e.g. imports) being open to anyone
+	if token == "" {
+		log.Printf("admin requests are not authenticated (see --admin-token)")
+	}
+
+	// set up a server per feed, each serving below its own path prefix (if
+	// serving multiple feeds) and importing from (and exporting to) its own
+	// subdirectory
+	var servers []*server
+	defer func() {
+		for _, s := range servers {
+			s.close()
+		}
+	}()
+	mux := http.NewServeMux()
+	for _, name := range names {
+		feedImportFrom, feedExportTo := importFrom, exportTo
+		if name != "" && importFrom != "" {
+			feedImportFrom = filepath.Join(importFrom, name)
+		}
+		if name != "" && exportTo != "" {
+			feedExportTo = filepath.Join(exportTo, name)
+		}
+		dbPath := feeds[name]
+		if dsn != "" {
+			dbPath = dsn
+		}
+		s, err := newServer(name, driver, dbPath, slowQuery, statementTimeout, feedImportFrom, feedExportTo, keep, minRows, token)
+		if err != nil {
+			return fmt.Errorf("failed to open feed '%s': %w", feeds[name], err)
+		}
+		servers = append(servers, s)
+		s.register(mux)
+	}
+	if len(args) == 0 {
+		mux.HandleFunc("/healthz", allHealthz(servers))
+		mux.HandleFunc("/readyz", allReadyz(servers))
+	}
+	srv := &http.Server{Addr: addr, Handler: mux}
+
+	// stop serving on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errChan := make(chan error, 1)
+	go func() {
+		if len(args) > 0 {
+			log.Printf("serving '%s' on '%s'", args[0], addr)
+		} else {
+			log.Printf("serving feeds '%s' on '%s'", strings.Join(names, "', '"), addr)
+		}
+		errChan <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err = <-errChan:
+		return err
+	case <-ctx.Done():
+	}
+
+	// drain in-flight requests
+	log.Printf("shutting down (draining for at most %s)", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
+
+// newServer initializes a server for the GTFS DB at dbPath (opened using
+// driver, see openDialector). Unless name is
+// empty, the server serves below the path prefix "/<name>". Import jobs import
+// from within importFrom, export jobs export below exportTo (see newJob). If
+// keep is positive, the DB replaced by an import is archived as snapshot
+// (keeping the newest keep snapshots, see archiveDB). Imports retaining fewer
+// rows than required by minRows are not swapped in (see importJob). Unless
+// adminToken is empty, admin requests must present it (see admin).
+func newServer(name, driver, dbPath string, slowQuery, statementTimeout time.Duration, importFrom, exportTo string, keep int, minRows map[gtfs.ItemType]float64, adminToken string) (*server, error) {
+	f, err := openFeed(driver, dbPath, slowQuery, statementTimeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &server{name: name, driver: driver, dbPath: dbPath, slowQuery: slowQuery, statementTimeout: statementTimeout, importFrom: importFrom, exportTo: exportTo, adminToken: adminToken, keep: keep, minRows: minRows, feed: f}
+	if name != "" {
+		s.prefix = "/" + name
+	}
+
+	// recover the state of the last import job (if any)
+	if job, err := s.loadImportJob(); err == nil {
+		s.lastImport = job
+	} else if !errors.Is(err, os.ErrNotExist) {
+		log.Printf("failed to load import job: %v", err)
+	}
+
+	// run long-running operations as jobs, persisting import jobs
+	s.jobs = gtfs.NewJobQueue(jobQueueSize)
+	s.jobs.OnChange = s.persistImportJob
+
+	return s, nil
+}
+
+// register registers the handlers of the server with mux.
+func (s *server) register(mux *http.ServeMux) {
+	mux.HandleFunc(s.prefix+"/healthz", s.healthz)
+	mux.HandleFunc(s.prefix+"/readyz", s.readyz)
+	mux.HandleFunc(s.prefix+"/agencies", s.agencies)
+	mux.HandleFunc(s.prefix+"/agencies/", s.agencies)
+	mux.HandleFunc(s.prefix+"/routes", s.routes)
+	mux.HandleFunc(s.prefix+"/routes/", s.routes)
+	mux.HandleFunc(s.prefix+"/lines", s.lines)
+	mux.HandleFunc(s.prefix+"/lines/", s.lines)
+	mux.HandleFunc(s.prefix+"/stops/", s.stop)
+	mux.HandleFunc(s.prefix+"/departures", s.departures)
+	mux.HandleFunc(s.prefix+"/exceptions", s.exceptions)
+	mux.HandleFunc(s.prefix+"/service-diff", s.serviceDiff)
+	mux.HandleFunc(s.prefix+"/tiles/", s.tiles)
+	mux.HandleFunc(s.prefix+"/admin/reload", s.admin(s.reload))
+	mux.HandleFunc(s.prefix+"/admin/import", s.admin(s.importFeed))
+	mux.HandleFunc(s.prefix+"/admin/jobs", s.admin(s.listJobs))
+	mux.HandleFunc(s.prefix+"/admin/jobs/", s.admin(s.job))
+	mux.HandleFunc(s.prefix+"/admin/stop-overrides", s.admin(s.stopOverrides))
+	mux.HandleFunc(s.prefix+"/admin/stop-overrides/", s.admin(s.stopOverride))
+	mux.HandleFunc(s.prefix+"/admin/occupancy", s.admin(s.occupancies))
+}
+
+// admin wraps the handler of an admin request, rejecting requests lacking the
+// admin token (as bearer token), if the server requires one.
+func (s *server) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminToken != "" {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.adminToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeJSON(w, http.StatusUnauthorized, status{Status: "error", Error: "missing or invalid admin token"})
+				return
+			}
+		}
+		h(w, r)
+	}
+}
+
+// close cancels pending jobs and closes the DB.
+func (s *server) close() {
+	s.jobs.Close()
+	s.feed.close()
+}
+
+// acquire returns the current feed. Callers must call release when done with
+// the feed, so that a swapped out feed gets closed not before all requests
+// using it have completed.
+func (s *server) acquire() (f *feed, release func()) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f = s.feed
+	f.inFlight.Add(1)
+	return f, f.inFlight.Done
+}
+
+// swap makes f the current feed and closes the previous one as soon as all
+// in-flight requests using it have completed.
+func (s *server) swap(f *feed) {
+	s.mu.Lock()
+	old := s.feed
+	s.feed = f
+	s.mu.Unlock()
+
+	go func() {
+		old.inFlight.Wait()
+		old.close()
+	}()
+}
+
+// healthz reports whether the DB is available.
+func (s *server) healthz(w http.ResponseWriter, r *http.Request) {
+	code, st := s.health(r.Context())
+	writeJSON(w, code, st)
+}
+
+// health checks whether the DB is available.
+func (s *server) health(ctx context.Context) (int, status) {
+	f, release := s.acquire()
+	defer release()
+
+	if err := f.ping(ctx); err != nil {
+		return http.StatusServiceUnavailable, status{Status: "unavailable", Error: err.Error()}
+	}
+	return http.StatusOK, status{Status: "ok"}
+}
+
+// readyz reports whether the DB is available and the feed is (still) valid.
+func (s *server) readyz(w http.ResponseWriter, r *http.Request) {
+	code, st := s.readiness(r.Context())
+	writeJSON(w, code, st)
+}
+
+// readiness checks whether the DB is available and the feed is (still) valid.
+func (s *server) readiness(ctx context.Context) (int, status) {
+	f, release := s.acquire()
+	defer release()
+
+	if err := f.ping(ctx); err != nil {
+		return http.StatusServiceUnavailable, status{Status: "unavailable", Error: err.Error()}
+	}
+	_, last, err := gtfs.ServicePeriod(f.db.WithContext(ctx), time.Local)
+	if err != nil {
+		return http.StatusServiceUnavailable, status{Status: "unavailable", Error: err.Error()}
+	}
+	if time.Now().After(last.AddDate(0, 0, 1)) {
+		err = fmt.Errorf("feed expired on %s", last.Format("2006-01-02"))
+		return http.StatusServiceUnavailable, status{Status: "expired", Error: err.Error()}
+	}
+	return http.StatusOK, status{Status: "ok"}
+}
+
+// allHealthz reports the health of all feeds (by name). Unless all are
+// healthy, the status code is 503.
+func allHealthz(servers []*server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := http.StatusOK
+		feeds := map[string]status{}
+		for _, s := range servers {
+			c, st := s.health(r.Context())
+			if c != http.StatusOK {
+				code = c
+			}
+			feeds[s.name] = st
+		}
+		writeJSON(w, code, feeds)
+	}
+}
+
+// allReadyz reports the readiness of all feeds (by name). Unless all are
+// ready, the status code is 503.
+func allReadyz(servers []*server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		code := http.StatusOK
+		feeds := map[string]status{}
+		for _, s := range servers {
+			c, st := s.readiness(r.Context())
+			if c != http.StatusOK {
+				code = c
+			}
+			feeds[s.name] = st
+		}
+		writeJSON(w, code, feeds)
+	}
+}
+
+// reload (re-)opens the DB file (e.g. after a fresh import has been moved in
+// place) and atomically swaps it in. Requests in-flight complete on the
+// previous DB.
+func (s *server) reload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+		return
+	}
+	f, err := openFeed(s.driver, s.dbPath, s.slowQuery, s.statementTimeout)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, status{Status: "error", Error: err.Error()})
+		return
+	}
+	s.swap(f)
+	log.Printf("reloaded '%s'", s.dbPath)
+	writeJSON(w, http.StatusOK, status{Status: "ok"})
+}
+
+// jobPath returns the path the import job is persisted to.
+func (s *server) jobPath() string {
+	return s.dbPath + ".import.json"
+}
+
+// importFeed reports the status of the current (or last) import job (GET) or
+// submits a new import job (POST, see importJob, forced by force=true). Import
+// jobs are persisted, such that clients may reconnect (even to a restarted
+// server) to follow their progress.
+func (s *server) importFeed(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		job := s.lastImportJob()
+		if job == nil {
+			writeJSON(w, http.StatusNotFound, status{Status: "error", Error: "no import job"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+
+	case http.MethodPost:
+		if s.importFrom == "" {
+			writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: "importing is not enabled (see --import-from)"})
+			return
+		}
+		if job := s.lastImportJob(); job != nil && !job.State.Finished() {
+			writeJSON(w, http.StatusConflict, status{Status: "error", Error: "import already running"})
+			return
+		}
+		s.submitJob(w, importJobType, url.Values{"force": {r.URL.Query().Get("force")}})
+
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+	}
+}
+
+// lastImportJob returns the status of the last import job (nil, if there is
+// none).
+func (s *server) lastImportJob() *gtfs.JobStatus {
+	jobs := s.jobs.Jobs()
+	for i := len(jobs) - 1; i >= 0; i-- {
+		if jobs[i].Type == importJobType {
+			return &jobs[i]
+		}
+	}
+	return s.lastImport
+}
+
+// listJobs lists all jobs (GET) or submits a new job (POST) of the type given
+// by the query parameter "type" (configured by the other query parameters).
+// If the query parameter "db" is given, it must match the served DB.
+func (s *server) listJobs(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.jobs.Jobs())
+
+	case http.MethodPost:
+		params := r.URL.Query()
+		if db := params.Get("db"); db != "" && !samePath(db, s.dbPath) {
+			writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: fmt.Sprintf("serving '%s' rather than '%s'", s.dbPath, db)})
+			return
+		}
+		s.submitJob(w, params.Get("type"), params)
+
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+		writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+	}
+}
+
+// submitJob submits a job of the given type (configured by params) and writes
+// its status.
+func (s *server) submitJob(w http.ResponseWriter, jobType string, params url.Values) {
+	fn, err := s.newJob(jobType, params)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: err.Error()})
+		return
+	}
+	job, err := s.jobs.Submit(jobType, fn)
+	if err != nil {
+		writeJSON(w, http.StatusServiceUnavailable, status{Status: "error", Error: err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// job reports the status of the job with the ID given by the path (GET) or
+// cancels it (DELETE).
+func (s *server) job(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, s.prefix+"/admin/jobs/")
+	switch r.Method {
+	case http.MethodGet:
+		job, err := s.jobs.Status(id)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, status{Status: "error", Error: err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+
+	case http.MethodDelete:
+		err := s.jobs.Cancel(id)
+		switch {
+		case errors.Is(err, gtfs.ErrJobNotFound):
+			writeJSON(w, http.StatusNotFound, status{Status: "error", Error: err.Error()})
+		case errors.Is(err, gtfs.ErrJobFinished):
+			writeJSON(w, http.StatusConflict, status{Status: "error", Error: err.Error()})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, status{Status: "error", Error: err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, status{Status: "ok"})
+		}
+
+	default:
+		w.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+		writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+	}
+}
+
+// samePath reports whether the paths a and b refer to the same file.
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// writeJSON writes v as JSON response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
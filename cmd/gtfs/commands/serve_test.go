@@ -0,0 +1,382 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fixtureFeed is the path of a small GTFS feed used for testing.
+const fixtureFeed = "../../../_fixture/feed"
+
+// writeFixtureDB imports the fixture feed into a fresh DB file at dbPath.
+func writeFixtureDB(t *testing.T, dbPath string) {
+	t.Helper()
+	if err := importInto(context.Background(), "sqlite", fixtureFeed, dbPath, gtfs.ImportOptions{}, gtfs.FeedMeta{}); err != nil {
+		t.Fatalf("failed to import fixture: %v", err)
+	}
+}
+
+// newTestServer returns a server (closed when the test ends) serving the
+// fixture feed from a DB file within a temporary directory, along with the
+// handler of its requests.
+func newTestServer(t *testing.T, importFrom, exportTo, adminToken string) (*server, http.Handler) {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "feed.db")
+	writeFixtureDB(t, dbPath)
+	s, err := newServer("", "sqlite", dbPath, 0, 0, importFrom, exportTo, 0, nil, adminToken)
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	t.Cleanup(s.close)
+	mux := http.NewServeMux()
+	s.register(mux)
+	return s, mux
+}
+
+// serveRequest serves a request (authenticated by token, if not empty) and
+// returns the recorded response.
+func serveRequest(h http.Handler, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return serve(h, req)
+}
+
+// serve serves req and returns the recorded response.
+func serve(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeResponse decodes the JSON body of a response (which must have the
+// status code wantCode) into v.
+func decodeResponse(t *testing.T, rec *httptest.ResponseRecorder, wantCode int, v interface{}) {
+	t.Helper()
+	if rec.Code != wantCode {
+		t.Fatalf("status code = %d, want %d (%s)", rec.Code, wantCode, rec.Body)
+	}
+	if err := json.NewDecoder(rec.Body).Decode(v); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+}
+
+// waitJob waits for the job with the given ID to finish and returns its
+// status.
+func waitJob(t *testing.T, s *server, id string) gtfs.JobStatus {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		job, err := s.jobs.Status(id)
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if job.State.Finished() {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s did not finish (%s)", id, job.State)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_Healthz(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+	var st status
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/healthz", ""), http.StatusOK, &st)
+	if st.Status != "ok" {
+		t.Errorf("GET /healthz = %+v, want ok", st)
+	}
+
+	// the DB is gone
+	s.feed.close()
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/healthz", ""), http.StatusServiceUnavailable, &st)
+	if st.Status != "unavailable" {
+		t.Errorf("GET /healthz = %+v, want unavailable", st)
+	}
+}
+
+func TestServer_Readyz(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+
+	// the services of the fixture feed end in 2022
+	var st status
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/readyz", ""), http.StatusServiceUnavailable, &st)
+	if st.Status != "expired" || st.Error != "feed expired on 2022-12-31" {
+		t.Errorf("GET /readyz = %+v, want expired", st)
+	}
+
+	// extend the services
+	if err := s.feed.db.Model(&gtfs.Calendar{}).Where("1 = 1").Update("end_date", "20991231").Error; err != nil {
+		t.Fatalf("failed to extend services: %v", err)
+	}
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/readyz", ""), http.StatusOK, &st)
+	if st.Status != "ok" {
+		t.Errorf("GET /readyz = %+v, want ok", st)
+	}
+}
+
+func TestServer_Reload(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+	if rec := serveRequest(h, http.MethodGet, "/admin/reload", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET /admin/reload = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+
+	// a request in-flight keeps using the previous feed
+	old, release := s.acquire()
+	var st status
+	decodeResponse(t, serveRequest(h, http.MethodPost, "/admin/reload", ""), http.StatusOK, &st)
+	if f, releaseNew := s.acquire(); f == old {
+		t.Errorf("POST /admin/reload kept the previous feed")
+	} else {
+		releaseNew()
+	}
+	var count int64
+	if err := old.db.Model(&gtfs.Agency{}).Count(&count).Error; err != nil || count != 2 {
+		t.Errorf("previous feed agencies = %d, %v, want 2", count, err)
+	}
+
+	// and the previous feed is closed once the request completes
+	release()
+	deadline := time.Now().Add(10 * time.Second)
+	for old.ping(context.Background()) == nil {
+		if time.Now().After(deadline) {
+			t.Fatalf("previous feed not closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// failing to reload keeps the current feed
+	current, releaseCurrent := s.acquire()
+	releaseCurrent()
+	if err := os.Remove(s.dbPath); err != nil {
+		t.Fatalf("failed to remove DB: %v", err)
+	}
+	decodeResponse(t, serveRequest(h, http.MethodPost, "/admin/reload", ""), http.StatusInternalServerError, &st)
+	if f, releaseNew := s.acquire(); f != current {
+		t.Errorf("POST /admin/reload replaced the feed")
+	} else {
+		releaseNew()
+	}
+	if rec := serveRequest(h, http.MethodGet, "/agencies", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /agencies = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestServer_Feeds(t *testing.T) {
+	mux := http.NewServeMux()
+	var servers []*server
+	for _, name := range []string{"berlin", "potsdam"} {
+		dbPath := filepath.Join(t.TempDir(), name+".db")
+		writeFixtureDB(t, dbPath)
+		s, err := newServer(name, "sqlite", dbPath, 0, 0, "", "", 0, nil, "")
+		if err != nil {
+			t.Fatalf("newServer() error = %v", err)
+		}
+		t.Cleanup(s.close)
+		s.register(mux)
+		servers = append(servers, s)
+	}
+	mux.HandleFunc("/healthz", allHealthz(servers))
+	mux.HandleFunc("/readyz", allReadyz(servers))
+
+	// the API of each feed is served below its name
+	tests := []struct {
+		target   string
+		wantCode int
+	}{
+		{"/berlin/agencies", http.StatusOK},
+		{"/potsdam/routes/R1", http.StatusOK},
+		{"/agencies", http.StatusNotFound},
+		{"/hamburg/agencies", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := serveRequest(mux, http.MethodGet, tt.target, ""); rec.Code != tt.wantCode {
+			t.Errorf("GET %s = %d, want %d", tt.target, rec.Code, tt.wantCode)
+		}
+	}
+
+	// the health and readiness of all feeds
+	var feeds map[string]status
+	decodeResponse(t, serveRequest(mux, http.MethodGet, "/healthz", ""), http.StatusOK, &feeds)
+	if len(feeds) != 2 || feeds["berlin"].Status != "ok" || feeds["potsdam"].Status != "ok" {
+		t.Errorf("GET /healthz = %v, want both ok", feeds)
+	}
+	servers[1].feed.close()
+	decodeResponse(t, serveRequest(mux, http.MethodGet, "/healthz", ""), http.StatusServiceUnavailable, &feeds)
+	if feeds["berlin"].Status != "ok" || feeds["potsdam"].Status != "unavailable" {
+		t.Errorf("GET /healthz = %v, want potsdam unavailable", feeds)
+	}
+	decodeResponse(t, serveRequest(mux, http.MethodGet, "/readyz", ""), http.StatusServiceUnavailable, &feeds)
+	if feeds["berlin"].Status != "expired" || feeds["potsdam"].Status != "unavailable" {
+		t.Errorf("GET /readyz = %v, want berlin expired and potsdam unavailable", feeds)
+	}
+
+	// jobs are run per feed (on the DB of the feed)
+	var job gtfs.JobStatus
+	decodeResponse(t, serveRequest(mux, http.MethodPost, "/berlin/admin/jobs?type=validate", ""), http.StatusAccepted, &job)
+	waitJob(t, servers[0], job.ID)
+	var jobs []gtfs.JobStatus
+	decodeResponse(t, serveRequest(mux, http.MethodGet, "/potsdam/admin/jobs", ""), http.StatusOK, &jobs)
+	if len(jobs) != 0 {
+		t.Errorf("GET /potsdam/admin/jobs = %v, want none", jobs)
+	}
+	target := "/berlin/admin/jobs?type=validate&db=" + servers[1].dbPath
+	if rec := serveRequest(mux, http.MethodPost, target, ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST %s = %d, want %d", target, rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServer_Jobs(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+
+	// submit a job and follow it
+	var job gtfs.JobStatus
+	decodeResponse(t, serveRequest(h, http.MethodPost, "/admin/jobs?type=validate", ""), http.StatusAccepted, &job)
+	if job.Type != validateJobType {
+		t.Errorf("POST /admin/jobs = %+v, want a validate job", job)
+	}
+	waitJob(t, s, job.ID)
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/admin/jobs/"+job.ID, ""), http.StatusOK, &job)
+	if job.State != gtfs.JobSucceeded {
+		t.Errorf("GET /admin/jobs/%s = %+v, want succeeded", job.ID, job)
+	}
+	var jobs []gtfs.JobStatus
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/admin/jobs", ""), http.StatusOK, &jobs)
+	if len(jobs) != 1 || jobs[0].ID != job.ID {
+		t.Errorf("GET /admin/jobs = %v, want job %s", jobs, job.ID)
+	}
+
+	// cancel a running job
+	started := make(chan struct{})
+	running, err := s.jobs.Submit("wait", func(ctx context.Context, _ func(interface{})) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-started
+	if rec := serveRequest(h, http.MethodDelete, "/admin/jobs/"+running.ID, ""); rec.Code != http.StatusOK {
+		t.Errorf("DELETE /admin/jobs/%s = %d, want %d (%s)", running.ID, rec.Code, http.StatusOK, rec.Body)
+	}
+	if running = waitJob(t, s, running.ID); running.State != gtfs.JobCanceled {
+		t.Errorf("job %s = %s, want canceled", running.ID, running.State)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		wantCode int
+	}{
+		{"cancel finished", http.MethodDelete, "/admin/jobs/" + job.ID, http.StatusConflict},
+		{"unknown job", http.MethodGet, "/admin/jobs/unknown", http.StatusNotFound},
+		{"cancel unknown job", http.MethodDelete, "/admin/jobs/unknown", http.StatusNotFound},
+		{"unknown type", http.MethodPost, "/admin/jobs?type=unknown", http.StatusBadRequest},
+		{"import not enabled", http.MethodPost, "/admin/jobs?type=import", http.StatusBadRequest},
+		{"other DB", http.MethodPost, "/admin/jobs?type=validate&db=other.db", http.StatusBadRequest},
+		{"method", http.MethodPut, "/admin/jobs", http.StatusMethodNotAllowed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serveRequest(h, tt.method, tt.target, ""); rec.Code != tt.wantCode {
+				t.Errorf("%s %s = %d, want %d (%s)", tt.method, tt.target, rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
+
+func TestServer_ImportJob(t *testing.T) {
+	s, h := newTestServer(t, fixtureFeed, "", "")
+	if rec := serveRequest(h, http.MethodGet, "/admin/import", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /admin/import = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+
+	// import, swapping in the result
+	old, release := s.acquire()
+	release()
+	var job gtfs.JobStatus
+	decodeResponse(t, serveRequest(h, http.MethodPost, "/admin/import", ""), http.StatusAccepted, &job)
+	if job = waitJob(t, s, job.ID); job.State != gtfs.JobSucceeded {
+		t.Fatalf("import job = %+v, want succeeded", job)
+	}
+	if f, release := s.acquire(); f == old {
+		t.Errorf("import job did not swap in the imported feed")
+	} else {
+		release()
+	}
+	var last gtfs.JobStatus
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/admin/import", ""), http.StatusOK, &last)
+	if last.ID != job.ID || last.State != gtfs.JobSucceeded || last.Progress == nil {
+		t.Errorf("GET /admin/import = %+v, want job %s succeeded with a report", last, job.ID)
+	}
+
+	// the job is persisted, such that a restarted server reports it
+	restarted, err := newServer("", "sqlite", s.dbPath, 0, 0, fixtureFeed, "", 0, nil, "")
+	if err != nil {
+		t.Fatalf("newServer() error = %v", err)
+	}
+	t.Cleanup(restarted.close)
+	if last := restarted.lastImportJob(); last == nil || last.ID != job.ID || last.State != gtfs.JobSucceeded {
+		t.Errorf("lastImportJob() = %+v, want job %s succeeded", last, job.ID)
+	}
+
+	// jobs persisted while running were interrupted
+	job.State = gtfs.JobRunning
+	s.persistImportJob(job)
+	persisted, err := s.loadImportJob()
+	if err != nil {
+		t.Fatalf("loadImportJob() error = %v", err)
+	}
+	if persisted.State != gtfs.JobInterrupted {
+		t.Errorf("loadImportJob() state = %s, want interrupted", persisted.State)
+	}
+
+	// other jobs are not persisted
+	if err = os.Remove(s.jobPath()); err != nil {
+		t.Fatalf("failed to remove job: %v", err)
+	}
+	s.persistImportJob(gtfs.JobStatus{ID: "1", Type: validateJobType})
+	if _, err = s.loadImportJob(); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loadImportJob() error = %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestServer_Admin(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminToken string
+		token      string
+		target     string
+		wantCode   int
+	}{
+		{"no token required", "", "", "/admin/jobs", http.StatusOK},
+		{"token", "secret", "secret", "/admin/jobs", http.StatusOK},
+		{"missing token", "secret", "", "/admin/jobs", http.StatusUnauthorized},
+		{"wrong token", "secret", "guess", "/admin/jobs", http.StatusUnauthorized},
+		{"reload", "secret", "", "/admin/reload", http.StatusUnauthorized},
+		{"stop overrides", "secret", "", "/admin/stop-overrides", http.StatusUnauthorized},
+		{"occupancy", "secret", "", "/admin/occupancy", http.StatusUnauthorized},
+		{"not admin", "secret", "", "/agencies", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, h := newTestServer(t, "", "", tt.adminToken)
+			if rec := serveRequest(h, http.MethodGet, tt.target, tt.token); rec.Code != tt.wantCode {
+				t.Errorf("GET %s = %d, want %d (%s)", tt.target, rec.Code, tt.wantCode, rec.Body)
+			}
+		})
+	}
+}
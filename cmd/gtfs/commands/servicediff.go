@@ -0,0 +1,118 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// parseDates parses the given dates (YYYY-MM-DD) in the timezone of the feed.
+func parseDates(db *gorm.DB, dates ...string) ([]time.Time, error) {
+	tz, err := gtfs.FeedTimezone(db)
+	if err != nil {
+		return nil, err
+	}
+	days := make([]time.Time, len(dates))
+	for i, date := range dates {
+		if days[i], err = time.ParseInLocation(dateLayout, date, tz); err != nil {
+			return nil, fmt.Errorf("invalid date '%s'", date)
+		}
+	}
+	return days, nil
+}
+
+func gtfsAnalyzeCompare(cmd *cobra.Command, args []string) error {
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	days, err := parseDates(db, args[1], args[2])
+	if err != nil {
+		return err
+	}
+	diffs, err := gtfs.CompareService(db, days[0], days[1])
+	if err != nil {
+		return fmt.Errorf("failed to compare service: %w", err)
+	}
+	if len(diffs) == 0 {
+		fmt.Printf("same service on %s and %s\n", args[1], args[2])
+		return nil
+	}
+	var reduced int
+	for _, d := range diffs {
+		fmt.Println(d.String())
+		if d.Reduced() {
+			reduced++
+		}
+	}
+	fmt.Printf("\nreduced service on %d of %d routes changing on %s (compared to %s)\n", reduced, len(diffs), args[2], args[1])
+	return nil
+}
+
+// routeServiceDiffResponse is the type used to describe the difference of the
+// service of a route on two days in API responses.
+type routeServiceDiffResponse struct {
+	RouteID    string   `json:"route_id"`
+	RouteName  string   `json:"route_name"`
+	BaseTrips  int      `json:"base_trips"`
+	OtherTrips int      `json:"other_trips"`
+	Reduced    bool     `json:"reduced"`
+	Removed    []string `json:"removed_trips,omitempty"`
+	Added      []string `json:"added_trips,omitempty"`
+	Summary    string   `json:"summary"`
+}
+
+// serviceDiff diffs the trips running per route on the dates given by the
+// query parameters "base" (e.g. a regular weekday) and "other" (e.g. a
+// holiday, both YYYY-MM-DD).
+func (s *server) serviceDiff(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	q := r.URL.Query()
+	for _, name := range []string{"base", "other"} {
+		v := q.Get(name)
+		if v == "" {
+			writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: "missing " + name})
+			return
+		}
+		if _, err := time.Parse(dateLayout, v); err != nil {
+			writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: fmt.Sprintf("invalid date '%s'", v)})
+			return
+		}
+	}
+
+	f, release := s.acquire()
+	defer release()
+	db := f.db.WithContext(r.Context())
+
+	days, err := parseDates(db, q.Get("base"), q.Get("other"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	diffs, err := gtfs.CompareService(db, days[0], days[1])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := make([]routeServiceDiffResponse, len(diffs))
+	for i, d := range diffs {
+		resp[i] = routeServiceDiffResponse{
+			RouteID:    d.RouteID,
+			RouteName:  d.RouteName,
+			BaseTrips:  d.BaseTrips,
+			OtherTrips: d.OtherTrips,
+			Reduced:    d.Reduced(),
+			Removed:    d.Removed,
+			Added:      d.Added,
+			Summary:    d.String(),
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
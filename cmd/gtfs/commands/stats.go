@@ -0,0 +1,86 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func gtfsStats(cmd *cobra.Command, args []string) error {
+	routes, err := cmd.Flags().GetBool("routes")
+	if err != nil {
+		return err
+	}
+
+	byAgency, err := cmd.Flags().GetBool("by-agency")
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	fs, err := gtfs.Stats(db)
+	if err != nil {
+		return fmt.Errorf("failed to compute stats: %w", err)
+	}
+	fmt.Print(fs.String())
+
+	// list the pattern of service and the average speed of each route, if desired
+	if routes {
+		speeds, err := gtfs.AverageSpeeds(db)
+		if err != nil {
+			return fmt.Errorf("failed to compute average speeds: %w", err)
+		}
+		routeIDs := make([]string, 0, len(fs.Patterns))
+		for routeID := range fs.Patterns {
+			routeIDs = append(routeIDs, routeID)
+		}
+		sort.Strings(routeIDs)
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "ROUTE\tPATTERN\tSPEED")
+		for _, routeID := range routeIDs {
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%.1f km/h\n", routeID, fs.Patterns[routeID], speeds[routeID])
+		}
+		if err = w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	// list the routes, trips and stops of each agency, if desired
+	if byAgency {
+		stats, err := gtfs.StatsByAgency(db)
+		if err != nil {
+			return fmt.Errorf("failed to compute stats by agency: %w", err)
+		}
+
+		fmt.Println()
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "AGENCY\tNAME\tROUTES\tMON\tTUE\tWED\tTHU\tFRI\tSAT\tSUN\tSTOPS")
+		for _, as := range stats {
+			modes := make([]string, 0, len(as.Routes))
+			for mode, n := range as.Routes {
+				modes = append(modes, fmt.Sprintf("%s %d", mode, n))
+			}
+			sort.Strings(modes)
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s", as.AgencyID, as.Name, strings.Join(modes, ", "))
+			for _, day := range []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday, time.Sunday} {
+				_, _ = fmt.Fprintf(w, "\t%d", as.Trips[day])
+			}
+			_, _ = fmt.Fprintf(w, "\t%d\n", as.Stops)
+		}
+		return w.Flush()
+	}
+
+	return nil
+}
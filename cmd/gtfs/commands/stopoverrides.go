@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"encoding/json"
+	"github.com/heimdalr/gtfs"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// stopOverrideRequest is the type used to describe the override of a stop in
+// API requests and responses.
+type stopOverrideRequest struct {
+	StopID   string `json:"stop_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// stopOverrides lists the overrides of all stops (GET).
+func (s *server) stopOverrides(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	f, release := s.acquire()
+	defer release()
+	overrides, err := gtfs.StopOverrides(f.db.WithContext(r.Context()))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	resp := make([]stopOverrideRequest, len(overrides))
+	for i, o := range overrides {
+		resp[i] = stopOverrideRequest(o)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// stopOverride sets (PUT, given the name and/or the platform as JSON) or
+// removes (DELETE) the override of the stop with the ID given by the path and
+// reloads the feed (see reload), such that the catalog and the tiles reflect
+// the change.
+func (s *server) stopOverride(w http.ResponseWriter, r *http.Request) {
+	stopID := strings.TrimPrefix(r.URL.Path, s.prefix+"/admin/stop-overrides/")
+	if stopID == "" {
+		writeJSON(w, http.StatusNotFound, status{Status: "error", Error: "not found"})
+		return
+	}
+
+	f, release := s.acquire()
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		var req stopOverrideRequest
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			release()
+			writeJSON(w, http.StatusBadRequest, status{Status: "error", Error: "invalid override: " + err.Error()})
+			return
+		}
+		err = gtfs.SetStopOverride(f.db, gtfs.StopOverride{StopID: stopID, Name: req.Name, Platform: req.Platform})
+
+	case http.MethodDelete:
+		err = gtfs.DeleteStopOverride(f.db, stopID)
+
+	default:
+		release()
+		w.Header().Set("Allow", http.MethodPut+", "+http.MethodDelete)
+		writeJSON(w, http.StatusMethodNotAllowed, status{Status: "error", Error: "method not allowed"})
+		return
+	}
+	release()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	// reload to refresh the catalog and the tiles
+	nf, err := openFeed(s.driver, s.dbPath, s.slowQuery, s.statementTimeout)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, status{Status: "error", Error: err.Error()})
+		return
+	}
+	s.swap(nf)
+	log.Printf("updated override of stop '%s'", stopID)
+	writeJSON(w, http.StatusOK, status{Status: "ok"})
+}
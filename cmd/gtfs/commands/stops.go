@@ -0,0 +1,113 @@
+package commands
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+func gtfsAmenities(cmd *cobra.Command, args []string) error {
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	n, err := gtfs.ImportStopAmenities(db, file)
+	if err != nil {
+		return err
+	}
+	log.Printf("imported amenities of %d stops", n)
+	return nil
+}
+
+// stopResponse is the type used to describe a stop (along with its amenities)
+// in API responses.
+type stopResponse struct {
+	ID                 string             `json:"id"`
+	Name               string             `json:"name"`
+	Lat                float64            `json:"lat"`
+	Lon                float64            `json:"lon"`
+	WheelchairBoarding int                `json:"wheelchair_boarding"`
+	LocationType       int                `json:"location_type"`
+	ParentStation      string             `json:"parent_station,omitempty"`
+	Amenities          *amenitiesResponse `json:"amenities,omitempty"`
+}
+
+// amenitiesResponse is the type used to describe the amenities of a stop in
+// API responses (omitting unknown amenities).
+type amenitiesResponse struct {
+	Shelter         *bool `json:"shelter,omitempty"`
+	Bench           *bool `json:"bench,omitempty"`
+	Lighting        *bool `json:"lighting,omitempty"`
+	RealtimeDisplay *bool `json:"realtime_display,omitempty"`
+}
+
+// newAmenitiesResponse converts the amenities of a stop into their API
+// representation.
+func newAmenitiesResponse(a gtfs.StopAmenity) *amenitiesResponse {
+	present := func(v int) *bool {
+		if v != 1 && v != 2 {
+			return nil
+		}
+		b := v == 1
+		return &b
+	}
+	return &amenitiesResponse{
+		Shelter:         present(a.Shelter),
+		Bench:           present(a.Bench),
+		Lighting:        present(a.Lighting),
+		RealtimeDisplay: present(a.RealtimeDisplay),
+	}
+}
+
+// stop describes the stop with the ID given by the path (served from the
+// catalog of the feed, by its display name translated as negotiated, see
+// newTranslator) along with its amenities (if imported, see gtfs amenities).
+func (s *server) stop(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	f, release := s.acquire()
+	defer release()
+	db := f.db.WithContext(r.Context())
+	t := newTranslator(w, r, f)
+
+	id := strings.TrimPrefix(r.URL.Path, s.prefix+"/stops/")
+	stop, ok := f.catalog.Stop(id)
+	if !ok {
+		writeError(w, gorm.ErrRecordNotFound)
+		return
+	}
+	resp := stopResponse{
+		ID:                 stop.ID,
+		Name:               t.translate("stops", "stop_name", stop.ID, stop.Name),
+		Lat:                stop.Latitude,
+		Lon:                stop.Longitude,
+		WheelchairBoarding: stop.WheelchairBoarding,
+		LocationType:       stop.LocationType,
+		ParentStation:      stop.ParentStation,
+	}
+	amenities, err := gtfs.GetStopAmenities(db, stop.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		writeError(w, err)
+		return
+	}
+	if amenities != nil {
+		resp.Amenities = newAmenitiesResponse(*amenities)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
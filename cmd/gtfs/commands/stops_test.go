@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"github.com/heimdalr/gtfs"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestServer_Stop(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+
+	// no amenities imported
+	var stop stopResponse
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/stops/S1", ""), http.StatusOK, &stop)
+	if stop.Name != "S Wannsee" || stop.Lat != 52.421 || stop.Amenities != nil {
+		t.Errorf("GET /stops/S1 = %+v, want S Wannsee without amenities", stop)
+	}
+
+	// S1 has a shelter and a bench, but no lighting (nothing known about
+	// a realtime display)
+	amenities := "stop_id,shelter,bench,lighting,realtime_display\nS1,1,1,2,0\n"
+	if _, err := gtfs.ImportStopAmenities(s.feed.db, strings.NewReader(amenities)); err != nil {
+		t.Fatalf("ImportStopAmenities() error = %v", err)
+	}
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/stops/S1", ""), http.StatusOK, &stop)
+	a := stop.Amenities
+	if a == nil || a.Shelter == nil || !*a.Shelter || a.Bench == nil || !*a.Bench || a.Lighting == nil || *a.Lighting || a.RealtimeDisplay != nil {
+		t.Errorf("GET /stops/S1 amenities = %+v", a)
+	}
+	var other stopResponse
+	decodeResponse(t, serveRequest(h, http.MethodGet, "/stops/S2", ""), http.StatusOK, &other)
+	if other.Amenities != nil {
+		t.Errorf("GET /stops/S2 amenities = %+v, want none", other.Amenities)
+	}
+
+	if rec := serveRequest(h, http.MethodGet, "/stops/X1", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /stops/X1 = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
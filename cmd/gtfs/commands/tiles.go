@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxCachedTiles is the maximum number of vector tiles cached per feed (the
+// cache is reset when exceeding it).
+const maxCachedTiles = 4096
+
+// tileCache caches the vector tiles of a feed (by path).
+type tileCache struct {
+	mu    sync.Mutex
+	tiles map[string][]byte
+}
+
+// get returns the cached tile (nil, if not cached).
+func (tc *tileCache) get(key string) []byte {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	return tc.tiles[key]
+}
+
+// put caches a tile.
+func (tc *tileCache) put(key string, b []byte) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if tc.tiles == nil || len(tc.tiles) >= maxCachedTiles {
+		tc.tiles = map[string][]byte{}
+	}
+	tc.tiles[key] = b
+}
+
+// tiles serves the Mapbox Vector Tile (see gtfs.VectorTile) given by the path
+// "/tiles/{z}/{x}/{y}.mvt". Tiles are cached per feed (i.e. until the feed is
+// swapped).
+func (s *server) tiles(w http.ResponseWriter, r *http.Request) {
+	if !allowGet(w, r) {
+		return
+	}
+	var z, x, y int
+	key := strings.TrimPrefix(r.URL.Path, s.prefix+"/tiles/")
+	if n, err := fmt.Sscanf(key, "%d/%d/%d.mvt", &z, &x, &y); err != nil || n != 3 || fmt.Sprintf("%d/%d/%d.mvt", z, x, y) != key {
+		writeJSON(w, http.StatusNotFound, status{Status: "error", Error: "not found"})
+		return
+	}
+
+	f, release := s.acquire()
+	defer release()
+
+	b := f.tiles.get(key)
+	if b == nil {
+		var err error
+		b, err = gtfs.VectorTile(f.db.WithContext(r.Context()), z, x, y)
+		if errors.Is(err, gtfs.ErrInvalidTile) {
+			writeJSON(w, http.StatusNotFound, status{Status: "error", Error: err.Error()})
+			return
+		}
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, status{Status: "error", Error: err.Error()})
+			return
+		}
+		f.tiles.put(key, b)
+	}
+	w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+	_, _ = w.Write(b)
+}
@@ -0,0 +1,44 @@
+package commands
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestServer_Tiles(t *testing.T) {
+	s, h := newTestServer(t, "", "", "")
+
+	// the tile covering Wannsee
+	rec := serveRequest(h, http.MethodGet, "/tiles/10/549/336.mvt", "")
+	if rec.Code != http.StatusOK || rec.Body.Len() == 0 {
+		t.Fatalf("GET /tiles/10/549/336.mvt = %d (%d bytes), want a tile", rec.Code, rec.Body.Len())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/vnd.mapbox-vector-tile" {
+		t.Errorf("GET /tiles/10/549/336.mvt content type = %s", ct)
+	}
+
+	// tiles are cached per feed
+	f, release := s.acquire()
+	cached := f.tiles.get("10/549/336.mvt")
+	release()
+	if !bytes.Equal(cached, rec.Body.Bytes()) {
+		t.Errorf("cached tile differs from the served one")
+	}
+
+	tests := []struct {
+		target   string
+		wantCode int
+	}{
+		{"/tiles/10/0/0.mvt", http.StatusOK},
+		{"/tiles/10/1024/0.mvt", http.StatusNotFound},
+		{"/tiles/30/0/0.mvt", http.StatusNotFound},
+		{"/tiles/10/549/336.png", http.StatusNotFound},
+		{"/tiles/10/549/0336.mvt", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec = serveRequest(h, http.MethodGet, tt.target, ""); rec.Code != tt.wantCode {
+			t.Errorf("GET %s = %d, want %d", tt.target, rec.Code, tt.wantCode)
+		}
+	}
+}
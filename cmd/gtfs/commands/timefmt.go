@@ -0,0 +1,81 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+	"time"
+)
+
+// addTimeFlags adds the flags controlling how times are printed to cmd.
+func addTimeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("tz", "", "timezone to print times in (e.g. America/New_York, defaults to the timezone of the feed)")
+	cmd.Flags().Bool("12h", false, "print times using a 12-hour clock (e.g. 8:05 PM)")
+	cmd.Flags().Bool("24h", false, "print times using a 24-hour clock (e.g. 20:05, the default)")
+}
+
+// timeFormatter formats times in a timezone using a 12 or 24-hour clock.
+type timeFormatter struct {
+	feedTZ *time.Location // the timezone GTFS times are given in
+	tz     *time.Location // the timezone to print times in
+	layout string
+}
+
+// newTimeFormatter returns a timeFormatter configured by the flags added by
+// addTimeFlags.
+func newTimeFormatter(cmd *cobra.Command, db *gorm.DB) (*timeFormatter, error) {
+	h12, err := cmd.Flags().GetBool("12h")
+	if err != nil {
+		return nil, err
+	}
+	h24, err := cmd.Flags().GetBool("24h")
+	if err != nil {
+		return nil, err
+	}
+	if h12 && h24 {
+		return nil, errors.New("--12h and --24h are mutually exclusive")
+	}
+	tzName, err := cmd.Flags().GetString("tz")
+	if err != nil {
+		return nil, err
+	}
+
+	tf := &timeFormatter{layout: "15:04"}
+	if h12 {
+		tf.layout = "3:04 PM"
+	}
+	if tf.feedTZ, err = gtfs.FeedTimezone(db); err != nil {
+		return nil, err
+	}
+	tf.tz = tf.feedTZ
+	if tzName != "" {
+		if tf.tz, err = time.LoadLocation(tzName); err != nil {
+			return nil, fmt.Errorf("unknown timezone '%s'", tzName)
+		}
+	}
+	return tf, nil
+}
+
+// format formats t as local time, marking times falling on another day than
+// the (calendar) date of day (e.g. "01:30 (+1)").
+func (tf *timeFormatter) format(t time.Time, day time.Time) string {
+	t = t.In(tf.tz)
+	s := t.Format(tf.layout)
+	y, m, d := t.Date()
+	dy, dm, dd := day.Date()
+	days := int(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Sub(time.Date(dy, dm, dd, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	if days != 0 {
+		s += fmt.Sprintf(" (%+d)", days)
+	}
+	return s
+}
+
+// formatOffset formats a GTFS time (seconds since "noon minus 12h" of a
+// service day, possibly exceeding 24h) as local time, taking today as the
+// service day (which matters on days daylight saving time starts or ends).
+func (tf *timeFormatter) formatOffset(dt gtfs.DateTime) string {
+	date, _ := gtfs.ServiceDay(time.Now(), tf.feedTZ)
+	return tf.format(gtfs.ServiceTime(date, int(dt.Int32)), date)
+}
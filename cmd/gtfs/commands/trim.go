@@ -2,118 +2,55 @@ package commands
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/heimdalr/gtfs"
 	"github.com/spf13/cobra"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 	"log"
-	"strings"
-	"time"
+	"os"
 )
 
-const (
-
-	// statement to remove all agencies not like a given name
-	delAgencyStmt = `
-DELETE
-FROM
-	agencies
-WHERE
-	id <> ?;
-`
-
-	// statement to remove all routes not belonging to any of the known agencies
-	delRoutesStmt = `
-DELETE
-FROM
-	routes
-WHERE agency_id NOT IN (
-	SELECT DISTINCT id
-	FROM
-		agencies);
-`
-
-	// statement to remove all trips not belonging to any of the known routes
-	delTripsStmt = `
-DELETE
-FROM
-	trips
-WHERE route_id NOT IN (
-	SELECT DISTINCT id
-	FROM
-		routes);
-`
-
-	// statement to remove all stops times not belonging to any known trip
-	delStopTimesStmt = `
-DELETE
-FROM
-	stop_times
-WHERE trip_id NOT IN (
-	SELECT DISTINCT
-		id
-	FROM
-		trips);
-`
-
-	// statement to remove stops that don't have a stop time associated
-	delStopsStmt = `
-DELETE
-FROM
-	stops
-WHERE
-	id NOT IN (
-	SELECT DISTINCT
-		stop_id
-	FROM
-		stop_times);
-`
-
-	// statement to remove all shapes that don't belong to any relevant trip
-	delShapesStmt = `
-DELETE
-FROM
-	shapes
-WHERE
-	shape_id NOT IN (
-	SELECT DISTINCT
-		shape_id
-	FROM
-		trips);
-`
-)
-
-// trimItemsResult is the type used to describe the result of trimming a single item type.
-type trimItemsResult struct {
-	ItemType  gtfs.ItemType
-	Affected  int64
-	Remaining int64
-	Time      time.Duration
-}
-
-// String returns a human-readable representation of trimItemsResult.
-func (tir trimItemsResult) String() string {
-	return fmt.Sprintf("trimed %d %s to %d in %s", tir.Affected, tir.ItemType, tir.Remaining, tir.Time)
-}
-
-// trimResult is the type used to describe the result of trimming all item types.
-type trimResult map[gtfs.ItemType]*trimItemsResult
+func gtfsTrim(cmd *cobra.Command, args []string) error {
+	dbPath := args[0]
+	agency := args[1]
 
-// String returns a human-readable representation of trimResult.
-func (tr trimResult) String() string {
-	var sb strings.Builder
-	for _, trimItemsResult := range tr {
-		sb.WriteString(fmt.Sprintf("%s\n", trimItemsResult))
+	keepStops, err := cmd.Flags().GetStringSlice("keep-stop")
+	if err != nil {
+		return err
+	}
+	planPath, err := cmd.Flags().GetString("plan")
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	optimize, err := cmd.Flags().GetBool("optimize")
+	if err != nil {
+		return err
+	}
+	retry, err := retryPolicy(cmd)
+	if err != nil {
+		return err
 	}
-	return sb.String()
-}
 
-func gtfsTrim(_ *cobra.Command, args []string) error {
-	dbPath := args[0]
-	agency := args[1]
+	// submit as job, if desired
+	isAsync, err := async(cmd)
+	if err != nil {
+		return err
+	}
+	if isAsync {
+		params, err := asyncParams(cmd, []string{"keep-stop"})
+		if err != nil {
+			return err
+		}
+		params.Set("agency", agency)
+		return submitJob(cmd, trimJobType, dbPath, params)
+	}
 
 	// some argument validation
 	if dbPath == "" {
@@ -124,12 +61,20 @@ func gtfsTrim(_ *cobra.Command, args []string) error {
 	}
 
 	// open gorm db
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	dial, err := dialector(cmd, dbPath)
+	if err != nil {
+		return err
+	}
+	var db *gorm.DB
+	db, err = gorm.Open(dial, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Error),
 	})
 	if err != nil {
 		return err
 	}
+	if err = registerPlugins(cmd, db); err != nil {
+		return err
+	}
 
 	// close the DB at last
 	var sqlDB *sql.DB
@@ -147,78 +92,67 @@ func gtfsTrim(_ *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to migrate DB: %w", err)
 	}
 
+	// only plan, if desired
+	opts := gtfs.TrimOptions{KeepStops: keepStops, Retry: retry, Optimize: optimize}
+	if dryRun {
+		plan, err := gtfs.PlanTrim(db, agency, opts)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			log.Println(fmt.Sprintf("could not find an agency like '%s', not trimming", agency))
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to plan trim: %w", err)
+		}
+		if planPath != "" {
+			return writeTrimPlan(plan, planPath)
+		}
+		fmt.Print(plan.String())
+		return nil
+	}
+
+	// write the plan before executing it, if desired
+	if planPath != "" {
+		opts.OnPlan = func(plan *gtfs.TrimPlan) error {
+			return writeTrimPlan(plan, planPath)
+		}
+	}
+
 	// trim to agency
-	r, errTrim := trim(db, agency)
+	r, errTrim := gtfs.Trim(db, agency, opts)
 	if errTrim != nil {
 		if errors.Is(errTrim, gorm.ErrRecordNotFound) {
 			log.Println(fmt.Sprintf("could not find an agency like '%s', not trimming", agency))
-		} else {
-			return fmt.Errorf("failed to trim DB: %w", errTrim)
+			return nil
 		}
+		return fmt.Errorf("failed to trim DB: %w", errTrim)
 	}
 	log.Println(r.String())
 
 	return nil
 }
 
-// trim removes all items from the DB that are not associated with the agency
-// that matches like. After completion, trim returns some stats.
-func trim(db *gorm.DB, like string) (*trimResult, error) {
-
-	// ensure all necessary tables are available for stripping
-	requiredTables := []string{"agencies", "routes", "trips", "stop_times", "stops", "shapes", "calendars", "calendar_dates"}
-	for _, tableName := range requiredTables {
-		if !db.Migrator().HasTable(tableName) {
-			return nil, fmt.Errorf("missing table '%s'", tableName)
+// writeTrimPlan writes the plan of a trim as JSON to the given file (to stdout,
+// if "-").
+func writeTrimPlan(plan *gtfs.TrimPlan, path string) error {
+	out := os.Stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
 		}
+		defer func() {
+			_ = file.Close()
+		}()
+		out = file
 	}
-
-	var agency gtfs.Agency
-	tx := db.Where("name LIKE ?", fmt.Sprintf("%%%s%%", like)).First(&agency)
-	if tx.Error != nil {
-		return nil, tx.Error
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(plan); err != nil {
+		return fmt.Errorf("failed to write trim plan: %w", err)
 	}
-
-	// trim config (note, the order of executing the trim statements is relevant)
-	config := []struct {
-		itemType gtfs.ItemType
-		stmt     string
-		tblName  string
-		values   []interface{}
-	}{
-		{gtfs.Agencies, delAgencyStmt, "agencies", []interface{}{agency.ID}},
-		{gtfs.Routes, delRoutesStmt, "routes", nil},
-		{gtfs.Trips, delTripsStmt, "trips", nil},
-		{gtfs.StopTimes, delStopTimesStmt, "stop_times", nil},
-		{gtfs.Stops, delStopsStmt, "stops", nil},
-		{gtfs.Shapes, delShapesStmt, "shapes", nil},
-		// TODO: also trim calendar and calendar_dates
+	if out != os.Stdout {
+		return out.Close()
 	}
-
-	// execute each of the statements
-	trimResult := trimResult{}
-	for _, c := range config {
-
-		start := time.Now()
-		tx := db.Exec(c.stmt, c.values...)
-		if tx.Error != nil {
-			return nil, fmt.Errorf("failed to trim %s: %w", c.itemType, tx.Error)
-		}
-		trimItemsResult := trimItemsResult{
-			ItemType: c.itemType,
-			Affected: tx.RowsAffected,
-			Time:     time.Since(start),
-		}
-		db.Table(c.tblName).Count(&trimItemsResult.Remaining)
-		trimResult[c.itemType] = &trimItemsResult
-
-	}
-
-	// vacuum
-	tx = db.Exec("vacuum")
-	if tx.Error != nil {
-		return nil, fmt.Errorf("failed to vacuum: %w", tx.Error)
-	}
-
-	return &trimResult, nil
+	return nil
 }
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"github.com/spf13/cobra"
+)
+
+func gtfsValidate(cmd *cobra.Command, args []string) error {
+
+	// submit as job, if desired
+	isAsync, err := async(cmd)
+	if err != nil {
+		return err
+	}
+	if isAsync {
+		if _, err = asyncParams(cmd, nil); err != nil {
+			return err
+		}
+		return submitJob(cmd, validateJobType, args[0], nil)
+	}
+
+	gate, err := validationGate(cmd)
+	if err != nil {
+		return err
+	}
+	maxShapeDistance, err := cmd.Flags().GetFloat64("max-shape-distance")
+	if err != nil {
+		return err
+	}
+
+	db, closeDB, err := openDB(cmd, args[0])
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	// ensure tables matching our model
+	if err = gtfs.Migrate(db); err != nil {
+		return fmt.Errorf("failed to migrate DB: %w", err)
+	}
+
+	// apply the default rules (with the given tolerance of stops off shapes)
+	rules := make([]gtfs.Rule, len(gtfs.DefaultRules))
+	copy(rules, gtfs.DefaultRules)
+	for i := range rules {
+		if rules[i].Name == "trips_off_shape" {
+			rules[i].Check = gtfs.TripsOffShapeWithin(maxShapeDistance)
+		}
+	}
+	issues, err := gtfs.Validate(db, rules...)
+	if err != nil {
+		return fmt.Errorf("failed to validate: %w", err)
+	}
+	for _, i := range issues {
+		fmt.Println(i.String())
+	}
+	return gate.Check(issues)
+}
+
+// addValidationGateFlags adds the flags deciding which issues fail a
+// validation (see validationGate).
+func addValidationGateFlags(cmd *cobra.Command) {
+	cmd.Flags().String("fail-on", "info", "the lowest severity failing (i.e. exiting non-zero): info (any issue), warning or error")
+	cmd.Flags().Int("max-warnings", -1, "the number of warnings tolerated regardless of --fail-on (-1 to decide by --fail-on)")
+}
+
+// validationGate returns the gate deciding which issues fail a validation
+// (see addValidationGateFlags).
+func validationGate(cmd *cobra.Command) (gtfs.ValidationGate, error) {
+	var gate gtfs.ValidationGate
+	failOn, err := cmd.Flags().GetString("fail-on")
+	if err != nil {
+		return gate, err
+	}
+	if gate.FailOn, err = gtfs.ParseSeverity(failOn); err != nil {
+		return gate, err
+	}
+	if gate.MaxWarnings, err = cmd.Flags().GetInt("max-warnings"); err != nil {
+		return gate, err
+	}
+	return gate, nil
+}
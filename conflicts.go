@@ -0,0 +1,103 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"strings"
+)
+
+// ConflictStrategy enumerates the ways of handling items repeating the ID (or
+// the natural key, see naturalKeys) of another item when importing.
+type ConflictStrategy uint32
+
+const (
+
+	// ConflictError fails the import of the item type, reporting the line of
+	// the repeated ID.
+	ConflictError ConflictStrategy = iota
+
+	// ConflictSkip keeps the first item and skips the items repeating its ID.
+	ConflictSkip
+
+	// ConflictReplace keeps the last item, i.e. each item replaces previous
+	// items with the same ID.
+	ConflictReplace
+)
+
+var txConflictStrategy = map[ConflictStrategy]string{
+	ConflictError:   "error",
+	ConflictSkip:    "skip",
+	ConflictReplace: "replace",
+}
+
+// String returns a human-readable representation of ConflictStrategy.
+func (cs ConflictStrategy) String() string {
+	if s := txConflictStrategy[cs]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ConflictStrategy (%d)", uint32(cs))
+}
+
+// ParseConflictStrategy returns the ConflictStrategy with the given name (i.e.
+// "error", "skip" or "replace").
+func ParseConflictStrategy(s string) (ConflictStrategy, error) {
+	for cs, name := range txConflictStrategy {
+		if name == s {
+			return cs, nil
+		}
+	}
+	return ConflictError, fmt.Errorf("unknown conflict strategy '%s'", s)
+}
+
+// naturalKey describes the fields (along with their columns) identifying the
+// items of models not identified by a string ID.
+type naturalKey struct {
+	fields  []string
+	columns []string
+}
+
+// naturalKeys maps item types to their natural keys (see naturalKey). Migrate
+// enforces these by unique indexes.
+var naturalKeys = map[ItemType]naturalKey{
+	Shapes:        {[]string{"ShapeID", "PtSequence"}, []string{"shape_id", "pt_sequence"}},
+	Calendars:     {[]string{"ServiceID"}, []string{"service_id"}},
+	CalendarDates: {[]string{"ServiceID", "Date"}, []string{"service_id", "date"}},
+}
+
+// keyName returns the name of the key identifying items of the given type
+// (e.g. "ID" or "shape_id/pt_sequence").
+func keyName(itemType ItemType) string {
+	if key, ok := naturalKeys[itemType]; ok {
+		return strings.Join(key.columns, "/")
+	}
+	return "ID"
+}
+
+// conflictColumns returns the columns conflicts of items of the given type
+// arise on (nil for the primary key).
+func conflictColumns(itemType ItemType) []clause.Column {
+	var columns []clause.Column
+	for _, c := range naturalKeys[itemType].columns {
+		columns = append(columns, clause.Column{Name: c})
+	}
+	return columns
+}
+
+// itemKey returns the key of the given item (a pointer to a model of the given
+// type), i.e. its natural key (see naturalKeys) or its ID, if the model is
+// identified by a string ID (rather than an auto-incremented one).
+func itemKey(itemType ItemType, item reflect.Value) (string, bool) {
+	if key, ok := naturalKeys[itemType]; ok {
+		values := make([]string, len(key.fields))
+		for i, f := range key.fields {
+			values[i] = fmt.Sprint(item.Elem().FieldByName(f).Interface())
+		}
+		return strings.Join(values, "/"), true
+	}
+	id := item.Elem().FieldByName("ID")
+	if !id.IsValid() || id.Kind() != reflect.String {
+		return "", false
+	}
+	return id.String(), true
+}
@@ -0,0 +1,179 @@
+package gtfs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CSVDecoder decodes the rows of GTFS files into items (see
+// ImportOptions.Decoder).
+type CSVDecoder interface {
+
+	// Decode decodes the rows of r (following a header) into items (pointers
+	// to models) sent to c (a channel of pointers to models). Decode closes c
+	// when done.
+	Decode(r io.Reader, c interface{}) error
+}
+
+// GocsvDecoder is the default CSVDecoder (based on github.com/gocarina/gocsv).
+type GocsvDecoder struct{}
+
+// Decode decodes the rows of r into items sent to c (see CSVDecoder).
+func (GocsvDecoder) Decode(r io.Reader, c interface{}) error {
+	return gocsv.UnmarshalToChan(r, c)
+}
+
+// FastDecoder is a CSVDecoder based on encoding/csv, which maps columns to
+// fields (by their csv tags) once per file rather than reflecting on each
+// value. Values are parsed like GocsvDecoder parses them, except that fields
+// of embedded models (e.g. Trip.Route) are not set.
+type FastDecoder struct{}
+
+// decodeField describes a field of a model set from a column.
+type decodeField struct {
+	index int
+	kind  reflect.Kind
+	csv   bool // the field implements gocsv.TypeUnmarshaller
+}
+
+// decodeFields caches the fields (by column) of models (by type).
+var decodeFields sync.Map
+
+// csvUnmarshaller is the type of fields unmarshalling themselves.
+var csvUnmarshaller = reflect.TypeOf((*gocsv.TypeUnmarshaller)(nil)).Elem()
+
+// fieldsOf returns the fields (by column) of a model.
+func fieldsOf(t reflect.Type) map[string]decodeField {
+	if fields, ok := decodeFields.Load(t); ok {
+		return fields.(map[string]decodeField)
+	}
+	fields := map[string]decodeField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		column := strings.Split(f.Tag.Get("csv"), ",")[0]
+		if column == "" || column == "-" || f.PkgPath != "" {
+			continue
+		}
+		if _, ok := fields[column]; ok {
+			continue
+		}
+		fields[column] = decodeField{index: i, kind: f.Type.Kind(), csv: reflect.PtrTo(f.Type).Implements(csvUnmarshaller)}
+	}
+	decodeFields.Store(t, fields)
+	return fields
+}
+
+// Decode decodes the rows of r into items sent to c (see CSVDecoder).
+func (FastDecoder) Decode(r io.Reader, c interface{}) error {
+	ch := reflect.ValueOf(c)
+	if ch.Kind() != reflect.Chan || ch.Type().Elem().Kind() != reflect.Ptr || ch.Type().Elem().Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("cannot decode into %T (expected a channel of pointers to structs)", c)
+	}
+	defer ch.Close()
+	model := ch.Type().Elem().Elem()
+
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// map columns to fields (ignoring unknown columns)
+	fields := fieldsOf(model)
+	columns := make([]*decodeField, len(header))
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		if f, ok := fields[name]; ok {
+			columns[i] = &f
+		}
+	}
+
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		item := reflect.New(model)
+		v := item.Elem()
+		for i, value := range record {
+			if i >= len(columns) || columns[i] == nil {
+				continue
+			}
+			if err = setValue(v.Field(columns[i].index), columns[i], value); err != nil {
+				return &csv.ParseError{StartLine: line, Line: line, Column: i + 1, Err: err}
+			}
+		}
+		ch.Send(item)
+	}
+}
+
+// setValue sets a field to the value of a column.
+func setValue(field reflect.Value, f *decodeField, value string) error {
+	if f.csv {
+		return field.Addr().Interface().(gocsv.TypeUnmarshaller).UnmarshalCSV(value)
+	}
+	switch f.kind {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := strings.TrimSpace(value)
+		if s == "" {
+			field.SetInt(0)
+			return nil
+		}
+		i, err := strconv.ParseInt(strings.SplitN(s, ".", 2)[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s := strings.TrimSpace(value)
+		if s == "" {
+			field.SetUint(0)
+			return nil
+		}
+		u, err := strconv.ParseUint(strings.SplitN(s, ".", 2)[0], 10, 64)
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		s := strings.TrimSpace(value)
+		if s == "" {
+			field.SetFloat(0)
+			return nil
+		}
+		fl, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		field.SetFloat(fl)
+	case reflect.Bool:
+		s := strings.TrimSpace(value)
+		if s == "" {
+			field.SetBool(false)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFastDecoder(t *testing.T) {
+
+	// importing the fixture via either decoder yields the same items
+	fingerprints := map[string]string{}
+	for name, decoder := range map[string]gtfs.CSVDecoder{"gocsv": gtfs.GocsvDecoder{}, "fast": gtfs.FastDecoder{}} {
+		db := newTestDB(t)
+		gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+			Decoder: decoder,
+			OnProgress: func(e gtfs.ImportEvent) {
+				if e.Result.Error != nil {
+					t.Fatalf("ImportWithOptions(%s) error = %v", name, e.Result.Error)
+				}
+			},
+		})
+		fp, err := gtfs.Fingerprint(db)
+		if err != nil {
+			t.Fatalf("Fingerprint() error = %v", err)
+		}
+		fingerprints[name] = fp
+	}
+	if fingerprints["fast"] != fingerprints["gocsv"] {
+		t.Errorf("Fingerprint(fast) = %s, want %s", fingerprints["fast"], fingerprints["gocsv"])
+	}
+}
+
+func TestFastDecoder_Error(t *testing.T) {
+	feed := t.TempDir()
+	if err := os.WriteFile(filepath.Join(feed, "stops.txt"), []byte("\ufeffstop_id, stop_name,stop_lat,stop_lon\nS1,One,52.5,13.4\nS2,Two,north,13.4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	db := newTestDB(t)
+	var stopsErr error
+	gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+		Decoder:   gtfs.FastDecoder{},
+		ItemTypes: []gtfs.ItemType{gtfs.Stops},
+		OnProgress: func(e gtfs.ImportEvent) {
+			if e.Result.ItemType == gtfs.Stops {
+				stopsErr = e.Result.Error
+			}
+		},
+	})
+	if stopsErr == nil || !strings.Contains(stopsErr.Error(), "line 3") {
+		t.Errorf("ImportWithOptions() error = %v, want an error in line 3", stopsErr)
+	}
+}
@@ -0,0 +1,151 @@
+package gtfs
+
+import (
+	"encoding/csv"
+	"gorm.io/gorm"
+	"io"
+	"math"
+	"strconv"
+)
+
+// statement to select the number of departures (stop times) per stop
+const stopDeparturesStmt = `
+SELECT
+	stop_id,
+	COUNT(*) AS departures
+FROM
+	stop_times
+GROUP BY
+	stop_id;
+`
+
+// DemandOptions configures GenerateDemand. Zero values select the defaults.
+type DemandOptions struct {
+
+	// Radius is the radius (in meters) within which stops count towards the
+	// density around a stop (defaults to 500).
+	Radius float64
+
+	// Decay is the distance (in meters) at which demand between two stops
+	// decays to 1/e (defaults to 2000).
+	Decay float64
+
+	// Total is the total number of trips to distribute (defaults to 10000).
+	Total float64
+}
+
+// DemandMatrix holds the (seeds of) the demand between all pairs of stops.
+type DemandMatrix struct {
+
+	// Stops are the stops (ordered by ID) serving as origins and destinations.
+	Stops []Stop
+
+	// Trips holds the number of trips from Stops[i] to Stops[j].
+	Trips [][]float64
+}
+
+// WriteCSV writes the matrix in long format (origin_stop_id,
+// destination_stop_id, trips), omitting pairs without demand, as consumed by
+// simulation tools.
+func (dm DemandMatrix) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"origin_stop_id", "destination_stop_id", "trips"}); err != nil {
+		return err
+	}
+	for i, row := range dm.Trips {
+		for j, trips := range row {
+			if trips == 0 {
+				continue
+			}
+			if err := cw.Write([]string{dm.Stops[i].ID, dm.Stops[j].ID, strconv.FormatFloat(trips, 'f', 3, 64)}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// GenerateDemand generates plausible (origin-destination) demand seeds
+// between the stops of the DB by a gravity model: the weight of each stop is
+// the product of the density of stops around it and the number of departures
+// at it, and the demand between two stops is proportional to the product of
+// their weights, decaying exponentially with their distance. The demand is
+// scaled to the total number of trips configured. Stops without departures
+// neither produce nor attract demand. As all pairs of stops are considered,
+// the runtime grows quadratically with the number of stops.
+func GenerateDemand(db *gorm.DB, opts DemandOptions) (*DemandMatrix, error) {
+	if opts.Radius <= 0 {
+		opts.Radius = 500
+	}
+	if opts.Decay <= 0 {
+		opts.Decay = 2000
+	}
+	if opts.Total <= 0 {
+		opts.Total = 10000
+	}
+
+	dm := &DemandMatrix{}
+	if tx := db.Order("id").Find(&dm.Stops); tx.Error != nil {
+		return nil, tx.Error
+	}
+	var departures []struct {
+		StopID     string
+		Departures int64
+	}
+	if tx := db.Raw(stopDeparturesStmt).Scan(&departures); tx.Error != nil {
+		return nil, tx.Error
+	}
+	stopDepartures := map[string]float64{}
+	for _, d := range departures {
+		stopDepartures[d.StopID] = float64(d.Departures)
+	}
+
+	// distances between all pairs of stops
+	n := len(dm.Stops)
+	distances := make([][]float64, n)
+	for i := range distances {
+		distances[i] = make([]float64, n)
+		for j := 0; j < i; j++ {
+			d := haversine(dm.Stops[i].Latitude, dm.Stops[i].Longitude, dm.Stops[j].Latitude, dm.Stops[j].Longitude)
+			distances[i][j], distances[j][i] = d, d
+		}
+	}
+
+	// weights by density (including the stop itself) and departures
+	weights := make([]float64, n)
+	for i, s := range dm.Stops {
+		density := 0.0
+		for j := range dm.Stops {
+			if distances[i][j] <= opts.Radius {
+				density++
+			}
+		}
+		weights[i] = density * stopDepartures[s.ID]
+	}
+
+	// unscaled demand
+	var sum float64
+	dm.Trips = make([][]float64, n)
+	for i := range dm.Trips {
+		dm.Trips[i] = make([]float64, n)
+		for j := range dm.Trips[i] {
+			if i == j {
+				continue
+			}
+			t := weights[i] * weights[j] * math.Exp(-distances[i][j]/opts.Decay)
+			dm.Trips[i][j] = t
+			sum += t
+		}
+	}
+
+	// scale to the total number of trips
+	if sum > 0 {
+		for i := range dm.Trips {
+			for j := range dm.Trips[i] {
+				dm.Trips[i][j] *= opts.Total / sum
+			}
+		}
+	}
+	return dm, nil
+}
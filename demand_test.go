@@ -0,0 +1,46 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"github.com/heimdalr/gtfs"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDemand(t *testing.T) {
+	db := newFixtureDB(t)
+
+	dm, err := gtfs.GenerateDemand(db, gtfs.DemandOptions{Total: 100})
+	if err != nil {
+		t.Fatalf("GenerateDemand() error = %v", err)
+	}
+	if len(dm.Stops) != 7 || len(dm.Trips) != 7 {
+		t.Fatalf("GenerateDemand() = %d stops, want 7", len(dm.Stops))
+	}
+
+	// demand sums up to the total and is symmetric
+	var sum float64
+	for i := range dm.Trips {
+		if dm.Trips[i][i] != 0 {
+			t.Errorf("GenerateDemand() demand from %s to itself = %f, want 0", dm.Stops[i].ID, dm.Trips[i][i])
+		}
+		for j := range dm.Trips[i] {
+			sum += dm.Trips[i][j]
+			if math.Abs(dm.Trips[i][j]-dm.Trips[j][i]) > 1e-9 {
+				t.Errorf("GenerateDemand() is not symmetric for %s and %s", dm.Stops[i].ID, dm.Stops[j].ID)
+			}
+		}
+	}
+	if math.Abs(sum-100) > 1e-6 {
+		t.Errorf("GenerateDemand() total = %f, want 100", sum)
+	}
+
+	var b bytes.Buffer
+	if err = dm.WriteCSV(&b); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	if !strings.HasPrefix(b.String(), "origin_stop_id,destination_stop_id,trips\n") {
+		t.Errorf("WriteCSV() = %q, want a header", b.String())
+	}
+}
@@ -0,0 +1,256 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Departure describes a trip departing from a stop.
+type Departure struct {
+	Time      time.Time
+	StopID    string
+	StopName  string // the display name of the stop (see StopOverride)
+	StopSeq   int
+	TripID    string
+	RouteID   string
+	RouteName string
+	Headsign  string
+	Platform  string     // the platform label of the stop (see StopOverride), if any
+	Occupancy *Occupancy // the occupancy of the vehicle (see DeparturesOptions.Occupancies), if known
+}
+
+// DeparturesOptions configures Departures.
+type DeparturesOptions struct {
+
+	// Window is the time span to return departures for (defaults to one hour).
+	Window time.Duration
+
+	// Limit (if > 0) is the maximum number of departures returned.
+	Limit int
+
+	// Accessible (if true) restricts departures to trips accessible by
+	// wheelchair from stops allowing wheelchair boarding.
+	Accessible bool
+
+	// Bikes (if true) restricts departures to trips allowing bikes.
+	Bikes bool
+
+	// ExcludeCategories (if not empty) excludes trips of services of the given
+	// categories (e.g. SchoolDaysOnly, see ClassifyServices).
+	ExcludeCategories []ServiceCategory
+
+	// Categories are the categories of services considered by
+	// ExcludeCategories. If nil, services are classified by Departures (see
+	// ClassifyServices), so pass the categories when listing departures
+	// repeatedly.
+	Categories map[string]ServiceCategory
+
+	// Station (if true) includes the departures from all stops of the station
+	// the stop is part of (see StationOf), e.g. from all platforms of a
+	// station, given either the station or one of its platforms.
+	Station bool
+
+	// Catalog (if not nil) provides the names of stops and routes, the
+	// overrides of stops and the capacities of vehicles, rather than querying
+	// them on each call (see LoadCatalog), so pass the catalog of the feed when
+	// listing departures repeatedly.
+	Catalog *Catalog
+
+	// Occupancies (if not nil) maps trip IDs to the realtime occupancy of
+	// their vehicles (e.g. from GTFS-RT vehicle positions). Departures of
+	// these trips are annotated with the occupancy and the expected crowding
+	// (considering vehicle capacities, see ImportVehicleCapacities).
+	Occupancies map[string]TripOccupancy
+}
+
+// defaultDeparturesWindow is the default time span of Departures.
+const defaultDeparturesWindow = time.Hour
+
+// statement to select the departures from a stop within a time span (the
+// names of stops and routes, the joins and the filters are filled in)
+const departuresStmt = `
+SELECT
+	stop_times.departure,
+	stop_times.stop_id,
+	stop_times.stop_seq,
+	stop_times.trip_id,
+	trips.route_id,
+	trips.headsign%s
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id%s
+WHERE
+	stop_times.stop_id IN ? AND
+	trips.service_id IN ? AND
+	stop_times.departure >= ? AND
+	stop_times.departure < ?%s
+ORDER BY
+	stop_times.departure,
+	stop_times.trip_id;
+`
+
+// Departures returns the departures from the stop with the given ID within a
+// time span (see DeparturesOptions.Window) starting at from, ordered by time.
+// GTFS times are interpreted in tz (UTC, if tz is nil), i.e. the timezone of
+// the agency. Time spans crossing midnight cover trips of the previous service
+// day (with times beyond 24h) as well as trips of the next (see
+// ServiceWindows). Departures defined by frequencies are not considered.
+func Departures(db *gorm.DB, stopID string, from time.Time, tz *time.Location, opts DeparturesOptions) ([]Departure, error) {
+	if opts.Window <= 0 {
+		opts.Window = defaultDeparturesWindow
+	}
+
+	var filters []string
+	if opts.Accessible {
+		filters = append(filters, "trips.wheelchair_accessible = 1", "stops.wheelchair_boarding = 1")
+	}
+	if opts.Bikes {
+		filters = append(filters, "trips.bikes_allowed = 1")
+	}
+	var filter string
+	if len(filters) > 0 {
+		filter = " AND\n\t" + strings.Join(filters, " AND\n\t")
+	}
+
+	// select the names of stops and routes, unless provided by the catalog
+	var names, joins string
+	if opts.Catalog == nil {
+		names = ",\n\tstops.name,\n\troutes.short_name"
+		joins = "\n\tJOIN routes ON routes.id = trips.route_id"
+	}
+	if opts.Catalog == nil || opts.Accessible {
+		joins += "\n\tJOIN stops ON stops.id = stop_times.stop_id"
+	}
+	stmt := fmt.Sprintf(departuresStmt, names, joins, filter)
+	stopIDs := []string{stopID}
+	if opts.Station {
+		var err error
+		if stopIDs, err = stationStops(db, stopID); err != nil {
+			return nil, fmt.Errorf("failed to find the stops of the station: %w", err)
+		}
+	}
+	if len(opts.ExcludeCategories) > 0 && opts.Categories == nil {
+		var err error
+		if opts.Categories, err = ClassifyServices(db); err != nil {
+			return nil, fmt.Errorf("failed to classify services: %w", err)
+		}
+	}
+
+	// collect the departures of all service days overlapping the time span
+	departures := []Departure{}
+	for _, w := range ServiceWindows(from, from.Add(opts.Window), tz) {
+		services, err := ActiveServices(db, w.Date)
+		if err != nil {
+			return nil, err
+		}
+		services = excludeServices(services, opts.Categories, opts.ExcludeCategories)
+		if len(services) == 0 {
+			continue
+		}
+		ds, err := departuresWithin(db, stmt, opts.Catalog == nil, stopIDs, services, w, opts.Limit)
+		if err != nil {
+			return nil, err
+		}
+		departures = append(departures, ds...)
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		if !departures[i].Time.Equal(departures[j].Time) {
+			return departures[i].Time.Before(departures[j].Time)
+		}
+		return departures[i].TripID < departures[j].TripID
+	})
+	if opts.Limit > 0 && len(departures) > opts.Limit {
+		departures = departures[:opts.Limit]
+	}
+
+	// name the stops and routes by the catalog (if given)
+	if opts.Catalog != nil {
+		for i, d := range departures {
+			stop, _ := opts.Catalog.Stop(d.StopID)
+			route, _ := opts.Catalog.Route(d.RouteID)
+			departures[i].StopName = stop.Name
+			departures[i].RouteName = route.ShortName
+		}
+	}
+
+	// apply the display name and label the platform (if set)
+	var overrides stopOverrideMap
+	var err error
+	if opts.Catalog != nil {
+		overrides = opts.Catalog.overrides
+	} else if overrides, err = stopOverrides(db); err != nil {
+		return nil, err
+	}
+	for i, d := range departures {
+		departures[i].StopName = overrides.name(Stop{ID: d.StopID, Name: d.StopName})
+		departures[i].Platform = overrides[d.StopID].Platform
+	}
+
+	// annotate the occupancy (if provided)
+	if len(opts.Occupancies) > 0 {
+		var capacities *vehicleCapacities
+		if opts.Catalog != nil {
+			capacities = opts.Catalog.capacities
+		} else if capacities, err = loadVehicleCapacities(db); err != nil {
+			return nil, err
+		}
+		for i, d := range departures {
+			departures[i].Occupancy = capacities.occupancy(d, opts.Occupancies)
+		}
+	}
+	return departures, nil
+}
+
+// excludeServices returns the services not of the excluded categories.
+func excludeServices(services []string, categories map[string]ServiceCategory, exclude []ServiceCategory) []string {
+	if len(exclude) == 0 {
+		return services
+	}
+	var kept []string
+	for _, serviceID := range services {
+		excluded := false
+		for _, c := range exclude {
+			excluded = excluded || categories[serviceID] == c
+		}
+		if !excluded {
+			kept = append(kept, serviceID)
+		}
+	}
+	return kept
+}
+
+// departuresWithin returns (up to limit, if > 0) departures from the given
+// stops within a service window, considering the given services only. If names
+// is true, the statement selects the names of stops and routes as well.
+func departuresWithin(db *gorm.DB, stmt string, names bool, stopIDs []string, services []string, w ServiceWindow, limit int) ([]Departure, error) {
+	rows, err := db.Raw(stmt, stopIDs, services, w.From, w.To).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var departures []Departure
+	for rows.Next() {
+		var dep Departure
+		var departure DateTime
+		dest := []interface{}{&departure, &dep.StopID, &dep.StopSeq, &dep.TripID, &dep.RouteID, &dep.Headsign}
+		if names {
+			dest = append(dest, &dep.StopName, &dep.RouteName)
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		dep.Time = ServiceTime(w.Date, int(departure.Int32))
+		departures = append(departures, dep)
+		if limit > 0 && len(departures) == limit {
+			break
+		}
+	}
+	return departures, rows.Err()
+}
@@ -0,0 +1,141 @@
+package gtfs_test
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+)
+
+func TestDepartures(t *testing.T) {
+	db := newFixtureDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	// T2 is accessible (from S1), T1 allows bikes
+	db.Model(&gtfs.Trip{}).Where("id = ?", "T2").Update("wheelchair_accessible", 1)
+	db.Model(&gtfs.Stop{}).Where("id = ?", "S1").Update("wheelchair_boarding", 1)
+	db.Model(&gtfs.Trip{}).Where("id = ?", "T1").Update("bikes_allowed", 1)
+
+	tuesday := time.Date(2022, 3, 1, 7, 55, 0, 0, berlin)
+	tests := []struct {
+		name string
+		from time.Time
+		opts gtfs.DeparturesOptions
+		want []string
+	}{
+		{"all", tuesday, gtfs.DeparturesOptions{}, []string{"T1", "T2"}},
+		{"window", tuesday, gtfs.DeparturesOptions{Window: 10 * time.Minute}, []string{"T1"}},
+		{"limit", tuesday, gtfs.DeparturesOptions{Limit: 1}, []string{"T1"}},
+		{"accessible", tuesday, gtfs.DeparturesOptions{Accessible: true}, []string{"T2"}},
+		{"bikes", tuesday, gtfs.DeparturesOptions{Bikes: true}, []string{"T1"}},
+		{"holiday", time.Date(2022, 12, 26, 7, 55, 0, 0, berlin), gtfs.DeparturesOptions{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			departures, err := gtfs.Departures(db, "S1", tt.from, berlin, tt.opts)
+			if err != nil {
+				t.Fatalf("Departures() error = %v", err)
+			}
+			var got []string
+			for _, d := range departures {
+				got = append(got, d.TripID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Departures() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Departures() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	departures, err := gtfs.Departures(db, "S1", tuesday, berlin, gtfs.DeparturesOptions{Limit: 1})
+	if err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if d := departures[0]; !d.Time.Equal(time.Date(2022, 3, 1, 8, 0, 0, 0, berlin)) || d.RouteID != "R1" || d.Headsign != "S Rathaus Steglitz" {
+		t.Errorf("Departures() = %+v", d)
+	}
+}
+
+func TestDepartures_Catalog(t *testing.T) {
+	db := newFixtureDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+	if err = gtfs.SetStopOverride(db, gtfs.StopOverride{StopID: "S1", Name: "Berlin-Wannsee", Platform: "3"}); err != nil {
+		t.Fatalf("SetStopOverride() error = %v", err)
+	}
+	catalog, err := gtfs.LoadCatalog(db)
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+
+	// names and platforms are taken from the catalog
+	tuesday := time.Date(2022, 3, 1, 7, 55, 0, 0, berlin)
+	for _, opts := range []gtfs.DeparturesOptions{{}, {Station: true}, {Accessible: true}} {
+		want, err := gtfs.Departures(db, "S1", tuesday, berlin, opts)
+		if err != nil {
+			t.Fatalf("Departures() error = %v", err)
+		}
+		opts.Catalog = catalog
+		got, err := gtfs.Departures(db, "S1", tuesday, berlin, opts)
+		if err != nil {
+			t.Fatalf("Departures() error = %v", err)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("Departures(%+v) = %v, want %v", opts, got, want)
+		}
+	}
+
+	// the catalog reflects the DB at the time of loading
+	if err = gtfs.DeleteStopOverride(db, "S1"); err != nil {
+		t.Fatalf("DeleteStopOverride() error = %v", err)
+	}
+	departures, err := gtfs.Departures(db, "S1", tuesday, berlin, gtfs.DeparturesOptions{Limit: 1, Catalog: catalog})
+	if err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if d := departures[0]; d.StopName != "Berlin-Wannsee" || d.Platform != "3" || d.RouteName == "" {
+		t.Errorf("Departures() = %+v, want names of the catalog", d)
+	}
+}
+
+func TestDepartures_Overnight(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// T5 leaves S1 at 25:00 (01:00 of the next day), T6 at 00:30
+	copyTrip(t, db, "T1", "T5", 17*3600)
+	copyTrip(t, db, "T1", "T6", -7*3600-30*60)
+
+	tests := []struct {
+		name string
+		from time.Time
+		want []string
+	}{
+		{"spanning midnight", time.Date(2022, 3, 1, 23, 30, 0, 0, time.UTC), []string{"T6", "T5"}},
+		{"after midnight", time.Date(2022, 3, 2, 0, 45, 0, 0, time.UTC), []string{"T5"}},
+		{"before midnight", time.Date(2022, 3, 1, 22, 0, 0, 0, time.UTC), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			departures, err := gtfs.Departures(db, "S1", tt.from, nil, gtfs.DeparturesOptions{Window: 2 * time.Hour})
+			if err != nil {
+				t.Fatalf("Departures() error = %v", err)
+			}
+			var got []string
+			for _, d := range departures {
+				got = append(got, d.TripID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Departures() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package gtfs
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"sort"
+	"strings"
+)
+
+// layout of route diagrams (in pixels)
+const (
+	diagramMargin  = 40
+	diagramSpacing = 80
+	diagramLineY   = 170
+	diagramHeight  = 250
+)
+
+// statement to select the other routes serving the given stops
+const transferRoutesStmt = `
+SELECT DISTINCT
+	stop_times.stop_id,
+	routes.short_name
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+	JOIN routes ON routes.id = trips.route_id
+WHERE
+	stop_times.stop_id IN ?
+	AND routes.id <> ?;
+`
+
+// ExportRouteDiagram writes a schematic SVG line diagram of the given route to
+// w. The diagram shows the stops of the route in sequence (see RouteSequence)
+// as beads on a string, marking stops served by other routes as transfers.
+func ExportRouteDiagram(db *gorm.DB, routeID string, w io.Writer) error {
+	var route Route
+	if tx := db.Limit(1).Find(&route, "id = ?", routeID); tx.Error != nil {
+		return tx.Error
+	} else if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	stops, err := RouteSequence(db, routeID)
+	if err != nil {
+		return err
+	}
+
+	// collect the other routes serving the stops
+	transfers := map[string][]string{}
+	if len(stops) > 0 {
+		stopIDs := make([]string, len(stops))
+		for i, s := range stops {
+			stopIDs[i] = s.ID
+		}
+		rows, err := db.Raw(transferRoutesStmt, stopIDs, routeID).Rows()
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		for rows.Next() {
+			var stopID, shortName string
+			if err = rows.Scan(&stopID, &shortName); err != nil {
+				return err
+			}
+			transfers[stopID] = append(transfers[stopID], shortName)
+		}
+		if err = rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	writeRouteDiagram(bw, route, stops, transfers)
+	return bw.Flush()
+}
+
+// writeRouteDiagram writes the SVG of a route diagram.
+func writeRouteDiagram(w *bufio.Writer, route Route, stops []Stop, transfers map[string][]string) {
+	width := 2*diagramMargin + diagramSpacing*maxInt(len(stops)-1, 0) + 120
+	_, _ = fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, diagramHeight)
+
+	// the string
+	if len(stops) > 1 {
+		_, _ = fmt.Fprintf(w, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="#006eb7" stroke-width="6"/>`+"\n",
+			diagramMargin, diagramLineY, diagramMargin+diagramSpacing*(len(stops)-1), diagramLineY)
+	}
+
+	// the beads along with their labels and transfers
+	for i, s := range stops {
+		x := diagramMargin + diagramSpacing*i
+		routes := transfers[s.ID]
+		if len(routes) > 0 {
+			_, _ = fmt.Fprintf(w, `<circle class="transfer" cx="%d" cy="%d" r="9" fill="white" stroke="black" stroke-width="3"/>`+"\n", x, diagramLineY)
+			sort.Strings(routes)
+			_, _ = fmt.Fprintf(w, `<text x="%d" y="%d" text-anchor="middle" font-size="10">%s</text>`+"\n", x, diagramLineY+28, escapeXML(strings.Join(routes, " ")))
+		} else {
+			_, _ = fmt.Fprintf(w, `<circle class="stop" cx="%d" cy="%d" r="6" fill="white" stroke="#006eb7" stroke-width="3"/>`+"\n", x, diagramLineY)
+		}
+		_, _ = fmt.Fprintf(w, `<text x="%d" y="%d" transform="rotate(-45 %d %d)">%s</text>`+"\n", x+4, diagramLineY-16, x+4, diagramLineY-16, escapeXML(s.Name))
+	}
+
+	// the title
+	title := strings.TrimSpace(route.ShortName + " " + route.LongName)
+	_, _ = fmt.Fprintf(w, `<text x="%d" y="%d" font-size="14" font-weight="bold">%s</text>`+"\n", diagramMargin, diagramHeight-10, escapeXML(title))
+	_, _ = fmt.Fprintln(w, `</svg>`)
+}
+
+// escapeXML escapes s for use as XML text.
+func escapeXML(s string) string {
+	var sb strings.Builder
+	_ = xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
+
+// maxInt returns the larger of a and b.
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
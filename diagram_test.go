@@ -0,0 +1,41 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+)
+
+func TestExportRouteDiagram(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// let route R2 serve S1 as well
+	db.Create(&gtfs.Trip{ID: "T9", RouteID: "R2", ServiceID: "WE"})
+	db.Create(&gtfs.StopTime{TripID: "T9", StopID: "S1", StopSeq: 1})
+
+	var buf bytes.Buffer
+	if err := gtfs.ExportRouteDiagram(db, "R1", &buf); err != nil {
+		t.Fatalf("ExportRouteDiagram() error = %v", err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg") {
+		t.Errorf("ExportRouteDiagram() = %q, want an SVG", svg)
+	}
+	if got := strings.Count(svg, `class="stop"`); got != 3 {
+		t.Errorf("ExportRouteDiagram() has %d stops, want 3", got)
+	}
+	if got := strings.Count(svg, `class="transfer"`); got != 1 {
+		t.Errorf("ExportRouteDiagram() has %d transfers, want 1", got)
+	}
+	for _, want := range []string{">S Wannsee<", ">S Rathaus Steglitz<", ">218<"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("ExportRouteDiagram() = %q, want it to contain %q", svg, want)
+		}
+	}
+
+	// stops appear in sequence
+	if strings.Index(svg, "S Wannsee") > strings.Index(svg, "S Nikolassee") {
+		t.Errorf("ExportRouteDiagram() does not list the stops in sequence")
+	}
+}
@@ -0,0 +1,116 @@
+package gtfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// maxHeaderLength is the maximum length of header lines considered when
+// detecting delimiters.
+const maxHeaderLength = 64 << 10
+
+// delimiters are the delimiters detected (see detectDelimiter).
+var delimiters = []rune{',', ';', '\t', '|'}
+
+// detectDelimiter returns the delimiter occurring most often (outside of
+// quotes) within the given header line, defaulting to a comma.
+func detectDelimiter(header []byte) rune {
+	counts := map[rune]int{}
+	quoted := false
+	for _, r := range string(header) {
+		if r == '"' {
+			quoted = !quoted
+		} else if !quoted {
+			counts[r]++
+		}
+	}
+	delimiter := ','
+	for _, d := range delimiters {
+		if counts[d] > counts[delimiter] {
+			delimiter = d
+		}
+	}
+	return delimiter
+}
+
+// normalizeCSV returns a reader reading the CSV file of items of the given type
+// from r as comma delimited file with decimal points (as expected by decoders),
+// along with a function to be called when done reading. If delimiter is 0, the
+// delimiter is detected from the header line (see detectDelimiter). Decimal
+// commas are replaced (in float columns, in values lacking a decimal point) if
+// decimalComma is true or the file isn't comma delimited. Files that are comma
+// delimited already are not transcoded at all, unless decimalComma or lenient is
+// true. If lenient is true, stray quotes are kept as they are and rows are
+// padded or cut to the number of columns of the header.
+func normalizeCSV(r io.Reader, itemType reflect.Type, delimiter rune, decimalComma, lenient bool) (io.Reader, func()) {
+	br := bufio.NewReaderSize(r, maxHeaderLength)
+	if delimiter == 0 {
+		delimiter = detectDelimiter(peekLine(br))
+	}
+	if delimiter == ',' && !decimalComma && !lenient {
+		return br, func() {}
+	}
+
+	// transcode the file (reporting errors to the reader)
+	pr, pw := io.Pipe()
+	go func() {
+		cr := csv.NewReader(br)
+		cr.Comma = delimiter
+		cr.FieldsPerRecord = -1
+		cr.LazyQuotes = lenient
+		cw := csv.NewWriter(pw)
+		var floats []bool
+		for {
+			record, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+
+			// float columns (by the header)
+			if floats == nil {
+				fields := fieldsOf(itemType)
+				floats = make([]bool, len(record))
+				for i, name := range record {
+					f, ok := fields[strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))]
+					floats[i] = ok && (f.kind == reflect.Float32 || f.kind == reflect.Float64)
+				}
+			} else {
+				if lenient && len(record) != len(floats) {
+					record = append(record, make([]string, len(floats))...)[:len(floats)]
+				}
+				for i, value := range record {
+					if i < len(floats) && floats[i] && strings.Contains(value, ",") && !strings.Contains(value, ".") {
+						record[i] = strings.Replace(value, ",", ".", 1)
+					}
+				}
+			}
+			if err = cw.Write(record); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		cw.Flush()
+		_ = pw.CloseWithError(cw.Error())
+	}()
+	return pr, func() {
+		_ = pr.Close()
+	}
+}
+
+// peekLine returns the first line (at most maxHeaderLength bytes) of br
+// (without consuming it).
+func peekLine(br *bufio.Reader) []byte {
+	b, _ := br.Peek(maxHeaderLength)
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[:i]
+	}
+	return b
+}
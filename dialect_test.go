@@ -0,0 +1,46 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestImportWithOptions_Dialects(t *testing.T) {
+	tests := []struct {
+		name  string
+		stops string
+		opts  gtfs.ImportOptions
+	}{
+		{"comma", "stop_id,stop_name,stop_lat,stop_lon\nS1,Zoo,52.5,13.25\n", gtfs.ImportOptions{}},
+		{"semicolon", "stop_id;stop_name;stop_lat;stop_lon\nS1;Zoo;52,5;13,25\n", gtfs.ImportOptions{}},
+		{"tab", "stop_id\tstop_name\tstop_lat\tstop_lon\nS1\tZoo\t52.5\t13.25\n", gtfs.ImportOptions{}},
+		{"decimal comma", "stop_id,stop_name,stop_lat,stop_lon\nS1,Zoo,\"52,5\",\"13,25\"\n", gtfs.ImportOptions{DecimalComma: true}},
+		{"override", "stop_id|stop_name|stop_lat|stop_lon\nS1|Zoo|52.5|13.25\n", gtfs.ImportOptions{Delimiter: '|'}},
+		{"lenient", "stop_id,stop_name,stop_lat,stop_lon,location_type\nS1,Zoo,52.5,13.25\nS2,Tor,52.5,13.25,0,x\n", gtfs.NewImportOptions(gtfs.WithLenientCSV())},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := t.TempDir()
+			if err := os.WriteFile(path.Join(feed, "stops.txt"), []byte(tt.stops), 0o644); err != nil {
+				t.Fatalf("failed to write feed: %v", err)
+			}
+			db := newTestDB(t)
+			tt.opts.ItemTypes = []gtfs.ItemType{gtfs.Stops}
+			tt.opts.OnProgress = func(e gtfs.ImportEvent) {
+				if e.Result.ItemType == gtfs.Stops && e.Result.Error != nil {
+					t.Fatalf("ImportWithOptions() error = %v", e.Result.Error)
+				}
+			}
+			gtfs.ImportWithOptions(db, feed, tt.opts)
+			var stop gtfs.Stop
+			if tx := db.First(&stop, "id = ?", "S1"); tx.Error != nil {
+				t.Fatalf("failed to find stop: %v", tx.Error)
+			}
+			if stop.Name != "Zoo" || stop.Latitude != 52.5 || stop.Longitude != 13.25 {
+				t.Errorf("ImportWithOptions() stop = %+v", stop)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+)
+
+// RouteDirection model (derived by AnalyzeDirections).
+type RouteDirection struct {
+	RouteID     string `gorm:"primaryKey"`
+	DirectionID string `gorm:"primaryKey"`
+	Terminus    string
+	Label       string
+	Trips       int64
+}
+
+// statement to count the trips per route, direction and terminus (i.e. the
+// name of the last stop)
+const terminiStmt = `
+SELECT
+	trips.route_id,
+	trips.direction_id,
+	stops.name,
+	COUNT(*) AS trips
+FROM (
+	SELECT
+		trip_id,
+		MAX(stop_seq) AS stop_seq
+	FROM
+		stop_times
+	GROUP BY
+		trip_id) AS last
+	JOIN stop_times ON stop_times.trip_id = last.trip_id AND stop_times.stop_seq = last.stop_seq
+	JOIN stops ON stops.id = stop_times.stop_id
+	JOIN trips ON trips.id = last.trip_id
+GROUP BY
+	trips.route_id,
+	trips.direction_id,
+	stops.name
+ORDER BY
+	trips.route_id,
+	trips.direction_id,
+	trips DESC,
+	stops.name;
+`
+
+// AnalyzeDirections derives a canonical label (e.g. "→ S Wannsee") for each
+// direction of each route. The label names the terminus most of the trips in
+// that direction end at. The derived directions replace any directions
+// previously stored in the DB and are returned.
+func AnalyzeDirections(db *gorm.DB) ([]RouteDirection, error) {
+
+	rows, err := db.Raw(terminiStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// rows are ordered by trip count, thus the first one per direction wins
+	var directions []RouteDirection
+	for rows.Next() {
+		var d RouteDirection
+		if err = rows.Scan(&d.RouteID, &d.DirectionID, &d.Terminus, &d.Trips); err != nil {
+			return nil, err
+		}
+		if n := len(directions); n > 0 && directions[n-1].RouteID == d.RouteID && directions[n-1].DirectionID == d.DirectionID {
+			continue
+		}
+		d.Label = "→ " + d.Terminus
+		directions = append(directions, d)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// replace the stored directions
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM route_directions").Error; err != nil {
+			return err
+		}
+		if len(directions) == 0 {
+			return nil
+		}
+		return tx.Create(&directions).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return directions, nil
+}
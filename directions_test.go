@@ -0,0 +1,34 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeDirections(t *testing.T) {
+	db := newFixtureDB(t)
+
+	want := []gtfs.RouteDirection{
+		{RouteID: "R1", DirectionID: "0", Terminus: "S Rathaus Steglitz", Label: "→ S Rathaus Steglitz", Trips: 2},
+		{RouteID: "R1", DirectionID: "1", Terminus: "S Wannsee", Label: "→ S Wannsee", Trips: 1},
+		{RouteID: "R2", DirectionID: "0", Terminus: "Strandbad Wannsee", Label: "→ Strandbad Wannsee", Trips: 1},
+	}
+	got, err := gtfs.AnalyzeDirections(db)
+	if err != nil {
+		t.Fatalf("AnalyzeDirections() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("AnalyzeDirections() = %v, want %v", got, want)
+	}
+
+	// analyzing again replaces the stored directions
+	if _, err = gtfs.AnalyzeDirections(db); err != nil {
+		t.Fatalf("AnalyzeDirections() error = %v", err)
+	}
+	var stored []gtfs.RouteDirection
+	db.Order("route_id, direction_id").Find(&stored)
+	if !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored directions = %v, want %v", stored, want)
+	}
+}
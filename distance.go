@@ -0,0 +1,143 @@
+package gtfs
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"math"
+)
+
+// earthRadius is the mean radius of the earth in meters.
+const earthRadius = 6371000.0
+
+// RouteDistances is the type used to describe the distances between the stops
+// of a route.
+type RouteDistances struct {
+
+	// Stops are the stops of the route in sequence (see RouteSequence).
+	Stops []Stop
+
+	// Along holds the distance (in meters) of each of the stops from the first
+	// stop, along the shape of the route (if available) or as the crow flies
+	// from stop to stop (otherwise).
+	Along []float64
+}
+
+// Distance returns the distance (in meters) between the stops at the given
+// indexes.
+func (rd RouteDistances) Distance(from, to int) float64 {
+	return math.Abs(rd.Along[to] - rd.Along[from])
+}
+
+// Matrix returns the distances (in meters) between all pairs of stops.
+func (rd RouteDistances) Matrix() [][]float64 {
+	m := make([][]float64, len(rd.Along))
+	for i := range m {
+		m[i] = make([]float64, len(rd.Along))
+		for j := range m[i] {
+			m[i][j] = rd.Distance(i, j)
+		}
+	}
+	return m
+}
+
+// StopDistances returns the distances between the stops of the given route
+// (see RouteSequence). Distances are measured along the shape of the
+// representative trip, falling back to the haversine distance between
+// consecutive stops, if the trip has no shape. If there is no such route,
+// gorm.ErrRecordNotFound is returned.
+func StopDistances(db *gorm.DB, routeID string) (*RouteDistances, error) {
+	trip, err := representativeTrip(db, routeID)
+	if err != nil {
+		return nil, err
+	}
+	rd := &RouteDistances{}
+	if trip == nil {
+		return rd, nil
+	}
+	if tx := db.Raw(tripStopsStmt, trip.ID).Scan(&rd.Stops); tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	var shape []Shape
+	if trip.ShapeID != "" {
+		if shape, err = ShapeGeometry(db, trip.ShapeID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if len(shape) < 2 {
+		rd.Along = make([]float64, len(rd.Stops))
+		for i := 1; i < len(rd.Stops); i++ {
+			prev, s := rd.Stops[i-1], rd.Stops[i]
+			rd.Along[i] = rd.Along[i-1] + haversine(prev.Latitude, prev.Longitude, s.Latitude, s.Longitude)
+		}
+		return rd, nil
+	}
+
+	rd.Along = alongShape(shape, rd.Stops)
+	return rd, nil
+}
+
+// alongShape returns the distance (in meters) of each of the stops from the
+// start of the shape. Stops are projected (in sequence) onto the closest
+// segment of the shape not preceding the segment of the previous stop.
+func alongShape(shape []Shape, stops []Stop) []float64 {
+
+	// the distance of each of the shape points from the start
+	cumulative := make([]float64, len(shape))
+	for i := 1; i < len(shape); i++ {
+		cumulative[i] = cumulative[i-1] + haversine(shape[i-1].PtLat, shape[i-1].PtLon, shape[i].PtLat, shape[i].PtLon)
+	}
+
+	along := make([]float64, len(stops))
+	segment := 0
+	for i, s := range stops {
+		best, bestDist := segment, math.Inf(1)
+		bestAlong := cumulative[segment]
+		for j := segment; j < len(shape)-1; j++ {
+			t, d := project(shape[j], shape[j+1], s)
+			if d < bestDist {
+				best, bestDist = j, d
+				bestAlong = cumulative[j] + t*(cumulative[j+1]-cumulative[j])
+			}
+		}
+		segment = best
+		along[i] = bestAlong
+	}
+
+	// the first stop is the origin
+	origin := along[0]
+	for i := range along {
+		along[i] -= origin
+	}
+	return along
+}
+
+// project projects the stop onto the segment from a to b and returns the
+// fraction of the segment up to the projection as well as the distance (in
+// meters) of the stop from the projection.
+func project(a, b Shape, s Stop) (float64, float64) {
+
+	// local equirectangular coordinates (in meters) relative to a
+	cosLat := math.Cos(a.PtLat * math.Pi / 180)
+	toXY := func(lat, lon float64) (float64, float64) {
+		return (lon - a.PtLon) * math.Pi / 180 * earthRadius * cosLat, (lat - a.PtLat) * math.Pi / 180 * earthRadius
+	}
+	bx, by := toXY(b.PtLat, b.PtLon)
+	sx, sy := toXY(s.Latitude, s.Longitude)
+
+	t := 0.0
+	if l := bx*bx + by*by; l > 0 {
+		t = math.Max(0, math.Min(1, (sx*bx+sy*by)/l))
+	}
+	return t, math.Hypot(sx-t*bx, sy-t*by)
+}
+
+// haversine returns the great-circle distance (in meters) between two
+// coordinates.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi, dLambda := (lat2-lat1)*math.Pi/180, (lon2-lon1)*math.Pi/180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
@@ -0,0 +1,48 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"math"
+	"testing"
+)
+
+func TestStopDistances(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// along the shape (SH1 passes right through the stops)
+	rd, err := gtfs.StopDistances(db, "R1")
+	if err != nil {
+		t.Fatalf("StopDistances() error = %v", err)
+	}
+	if len(rd.Stops) != 4 || len(rd.Along) != 4 {
+		t.Fatalf("StopDistances() = %v, want 4 stops", rd)
+	}
+	if rd.Along[0] != 0 {
+		t.Errorf("StopDistances() first stop at %f, want 0", rd.Along[0])
+	}
+	for i := 1; i < len(rd.Along); i++ {
+		if rd.Along[i] <= rd.Along[i-1] {
+			t.Errorf("StopDistances() along = %v, want increasing distances", rd.Along)
+		}
+	}
+	if d := rd.Distance(0, 1); math.Abs(d-1880) > 20 {
+		t.Errorf("Distance(0, 1) = %f, want about 1880", d)
+	}
+	m := rd.Matrix()
+	if m[3][0] != m[0][3] || m[0][3] != rd.Along[3] {
+		t.Errorf("Matrix() = %v, want symmetric distances", m)
+	}
+
+	// falling back to haversine distances
+	db.Model(&gtfs.Trip{}).Where("route_id = ?", "R1").Update("shape_id", "")
+	fallback, err := gtfs.StopDistances(db, "R1")
+	if err != nil {
+		t.Fatalf("StopDistances() error = %v", err)
+	}
+	for i := range rd.Along {
+		if math.Abs(fallback.Along[i]-rd.Along[i]) > 1 {
+			t.Errorf("StopDistances() fallback = %v, want %v", fallback.Along, rd.Along)
+			break
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package gtfs
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// duckDBNull is the representation of NULL values in the CSV files of DuckDB
+// exports (telling NULL apart from empty strings).
+const duckDBNull = `\N`
+
+// duckDBTypes maps the data types of fields to the types of DuckDB columns.
+var duckDBTypes = map[schema.DataType]string{
+	schema.Bool:   "BOOLEAN",
+	schema.Int:    "BIGINT",
+	schema.Uint:   "UBIGINT",
+	schema.Float:  "DOUBLE",
+	schema.String: "VARCHAR",
+	schema.Time:   "TIMESTAMPTZ",
+	schema.Bytes:  "BLOB",
+}
+
+// ExportDuckDB exports the tables of db (i.e. the tables of the models, see
+// Models) to the directory dir in the format of DuckDB's EXPORT DATABASE
+// statement, i.e. schema.sql creating the tables, load.sql loading the tables
+// and a CSV file per table, bridging GTFS DBs and analysts' tooling without
+// requiring a DuckDB driver. A DuckDB file (e.g. a read-only mirror for
+// analytical queries) is created from the export by DuckDB's IMPORT DATABASE
+// statement, e.g.
+//
+//	duckdb vbb.duckdb "IMPORT DATABASE 'vbb.export'"
+//
+// Tables missing from db are skipped. Constraints and indexes are left out, as
+// DuckDB scans columns rather than looking up rows.
+func ExportDuckDB(db *gorm.DB, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	var schemaSQL, loadSQL strings.Builder
+	for _, model := range Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err = stmt.Parse(model); err != nil {
+			return fmt.Errorf("failed to parse model %T: %w", model, err)
+		}
+		if !db.Migrator().HasTable(stmt.Schema.Table) {
+			continue
+		}
+
+		// the table
+		table := stmt.Schema.Table
+		var columns []string
+		var fields []*schema.Field
+		for _, name := range stmt.Schema.DBNames {
+			field := stmt.Schema.FieldsByDBName[name]
+			t, ok := duckDBTypes[field.DataType]
+			if !ok {
+				t = "VARCHAR"
+			}
+			columns = append(columns, fmt.Sprintf("%s %s", duckDBIdentifier(name), t))
+			fields = append(fields, field)
+		}
+		fmt.Fprintf(&schemaSQL, "CREATE TABLE %s(%s);\n", duckDBIdentifier(table), strings.Join(columns, ", "))
+
+		// the rows of the table
+		file := filepath.Join(dir, table+".csv")
+		if err = exportDuckDBTable(db, table, fields, file); err != nil {
+			return fmt.Errorf("failed to export %s: %w", table, err)
+		}
+		fmt.Fprintf(&loadSQL, "COPY %s FROM %s (FORMAT 'csv', HEADER 1, DELIMITER ',', QUOTE '\"', NULL %s);\n",
+			duckDBIdentifier(table), duckDBString(file), duckDBString(duckDBNull))
+	}
+
+	if err = os.WriteFile(filepath.Join(dir, "schema.sql"), []byte(schemaSQL.String()), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "load.sql"), []byte(loadSQL.String()), 0644)
+}
+
+// exportDuckDBTable writes the rows of a table (the given fields of them) as
+// CSV file (see ExportDuckDB).
+func exportDuckDBTable(db *gorm.DB, table string, fields []*schema.Field, file string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	bw := bufio.NewWriter(f)
+	w := csv.NewWriter(bw)
+
+	names := make([]string, len(fields))
+	for i, field := range fields {
+		names[i] = field.DBName
+	}
+	if err = w.Write(names); err != nil {
+		return err
+	}
+
+	rows, err := db.Table(table).Select(names).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	values := make([]interface{}, len(fields))
+	dest := make([]interface{}, len(fields))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(fields))
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = duckDBValue(v, fields[i].DataType)
+		}
+		if err = w.Write(record); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// duckDBValue returns the CSV representation of a value (as scanned) of a
+// column of the given data type.
+func duckDBValue(v interface{}, dataType schema.DataType) string {
+	switch v := v.(type) {
+	case nil:
+		return duckDBNull
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		if dataType == schema.Bool {
+			return strconv.FormatBool(v != 0)
+		}
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05.999999-07:00")
+	case []byte:
+		return string(v)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// duckDBIdentifier returns the given name as (quoted) DuckDB identifier.
+func duckDBIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// duckDBString returns the given string as DuckDB string literal.
+func duckDBString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,118 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExportDuckDB(t *testing.T) {
+	db := newFixtureDB(t)
+	db.Create(&gtfs.FeedMeta{SourceURL: "https://example.com/gtfs.zip", RetrievedAt: time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)})
+	db.Exec("UPDATE stops SET name = NULL WHERE id = ?", "S4")
+	dir := t.TempDir()
+	if err := gtfs.ExportDuckDB(db, dir); err != nil {
+		t.Fatalf("ExportDuckDB() error = %v", err)
+	}
+
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(b)
+	}
+	tests := []struct {
+		file string
+		want []string
+	}{
+		{"schema.sql", []string{
+			`CREATE TABLE "stops"("id" VARCHAR, "name" VARCHAR, "latitude" DOUBLE, "longitude" DOUBLE, `,
+			`CREATE TABLE "feed_meta"(`,
+			`"retrieved_at" TIMESTAMPTZ`,
+		}},
+		{"load.sql", []string{
+			`COPY "stops" FROM '` + filepath.Join(dir, "stops.csv") + `' (FORMAT 'csv', HEADER 1, DELIMITER ',', QUOTE '"', NULL '\N');`,
+		}},
+		{"stops.csv", []string{
+			"id,name,latitude,longitude,",
+			"S4,\\N,",
+		}},
+		{"feed_meta.csv", []string{
+			"2022-05-01 12:00:00+00:00",
+		}},
+	}
+	for _, tt := range tests {
+		got := read(tt.file)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("ExportDuckDB() %s = %s, want %q", tt.file, got, want)
+			}
+		}
+	}
+	if got := strings.Count(read("stops.csv"), "\n"); got != 8 {
+		t.Errorf("ExportDuckDB() stops.csv = %d lines, want 8", got)
+	}
+}
+
+func TestExportDuckDB_Extensions(t *testing.T) {
+	feed := writeFeed(t, nil)
+	if err := os.WriteFile(filepath.Join(feed, "translations.txt"), []byte(translationsCSV), 0o644); err != nil {
+		t.Fatalf("failed to write translations: %v", err)
+	}
+	for name, csv := range faresCSV {
+		if err := os.WriteFile(filepath.Join(feed, name), []byte(csv), 0o644); err != nil {
+			t.Fatalf("failed to write fares: %v", err)
+		}
+	}
+	db := newTestDB(t)
+	gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{})
+	if _, err := gtfs.ImportTranslations(db, feed); err != nil {
+		t.Fatalf("ImportTranslations() error = %v", err)
+	}
+	if _, err := gtfs.ImportFares(db, feed); err != nil {
+		t.Fatalf("ImportFares() error = %v", err)
+	}
+	if err := gtfs.IndexSearch(db, false); err != nil {
+		t.Fatalf("IndexSearch() error = %v", err)
+	}
+	dir := t.TempDir()
+	if err := gtfs.ExportDuckDB(db, dir); err != nil {
+		t.Fatalf("ExportDuckDB() error = %v", err)
+	}
+
+	tests := []struct {
+		table string
+		lines int
+	}{
+		{"translations", 5},
+		{"fare_products", 5},
+		{"fare_leg_rules", 6},
+		{"fare_transfer_rules", 2},
+		{"stop_amenities", 1},
+		{"vehicle_capacities", 1},
+	}
+	schemaSQL, err := os.ReadFile(filepath.Join(dir, "schema.sql"))
+	if err != nil {
+		t.Fatalf("failed to read schema.sql: %v", err)
+	}
+	for _, tt := range tests {
+		if !strings.Contains(string(schemaSQL), `CREATE TABLE "`+tt.table+`"(`) {
+			t.Errorf("ExportDuckDB() schema.sql misses table %s", tt.table)
+		}
+		b, err := os.ReadFile(filepath.Join(dir, tt.table+".csv"))
+		if err != nil {
+			t.Errorf("failed to read %s.csv: %v", tt.table, err)
+			continue
+		}
+		if got := strings.Count(string(b), "\n"); got != tt.lines {
+			t.Errorf("ExportDuckDB() %s.csv = %d lines, want %d", tt.table, got, tt.lines)
+		}
+	}
+	if b, err := os.ReadFile(filepath.Join(dir, "search.csv")); err != nil || !strings.Contains(string(b), "Wannsee") {
+		t.Errorf("ExportDuckDB() search.csv = %s, %v", b, err)
+	}
+}
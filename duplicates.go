@@ -0,0 +1,146 @@
+package gtfs
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// statement to select the stop times of all trips (along with their service)
+const tripStopTimesStmt = `
+SELECT
+	trips.id,
+	trips.service_id,
+	stop_times.stop_id,
+	stop_times.arrival,
+	stop_times.departure
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+ORDER BY
+	trips.id,
+	stop_times.stop_seq;
+`
+
+// statement to select the time span of all trips belonging to a block
+const blockTripsStmt = `
+SELECT
+	trips.id,
+	trips.block_id,
+	trips.service_id,
+	MIN(stop_times.departure) AS start,
+	MAX(stop_times.arrival)
+FROM
+	trips
+	JOIN stop_times ON stop_times.trip_id = trips.id
+WHERE
+	trips.block_id <> ''
+GROUP BY
+	trips.id,
+	trips.block_id,
+	trips.service_id
+ORDER BY
+	trips.block_id,
+	trips.service_id,
+	start;
+`
+
+// DuplicateTrips finds trips that share the service and the sequence of stops
+// and times with another trip, i.e. trips that probably got duplicated under a
+// different ID.
+func DuplicateTrips(db *gorm.DB) ([]Issue, error) {
+
+	rows, err := db.Raw(tripStopTimesStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// compute a signature for each trip and remember the first trip per signature
+	var issues []Issue
+	first := map[[sha256.Size]byte]string{}
+	var tripID, serviceID string
+	h := sha256.New()
+	flush := func() {
+		if tripID == "" {
+			return
+		}
+		var sig [sha256.Size]byte
+		copy(sig[:], h.Sum(nil))
+		if original, ok := first[sig]; ok {
+			issues = append(issues, Issue{
+				Severity: Warning,
+				ItemType: Trips,
+				ItemID:   tripID,
+				Message:  fmt.Sprintf("same service and stop times as trip '%s'", original),
+			})
+		} else {
+			first[sig] = tripID
+		}
+		h.Reset()
+	}
+	for rows.Next() {
+		var id, service, stopID string
+		var arrival, departure DateTime
+		if err = rows.Scan(&id, &service, &stopID, &arrival, &departure); err != nil {
+			return nil, err
+		}
+		if id != tripID {
+			flush()
+			tripID, serviceID = id, service
+			_, _ = fmt.Fprintf(h, "%s\n", serviceID)
+		}
+		_, _ = fmt.Fprintf(h, "%s %d %d\n", stopID, arrival.Int32, departure.Int32)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return issues, nil
+}
+
+// OverlappingBlockTrips finds trips that overlap in time with another trip of
+// the same block and service. As the trips of a block are served by the same
+// vehicle, they must not overlap.
+func OverlappingBlockTrips(db *gorm.DB) ([]Issue, error) {
+
+	rows, err := db.Raw(blockTripsStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// trips are ordered by block, service and start, thus compare successive ones
+	var issues []Issue
+	var prevID, prevBlock, prevService string
+	var prevEnd DateTime
+	for rows.Next() {
+		var id, block, service string
+		var start, end DateTime
+		if err = rows.Scan(&id, &block, &service, &start, &end); err != nil {
+			return nil, err
+		}
+		if block == prevBlock && service == prevService && start.Int32 < prevEnd.Int32 {
+			issues = append(issues, Issue{
+				Severity: Error,
+				ItemType: Trips,
+				ItemID:   id,
+				Message:  fmt.Sprintf("overlaps with trip '%s' of block '%s'", prevID, block),
+			})
+		}
+		if block != prevBlock || service != prevService || end.Int32 > prevEnd.Int32 {
+			prevID, prevEnd = id, end
+		}
+		prevBlock, prevService = block, service
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return issues, nil
+}
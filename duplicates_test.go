@@ -0,0 +1,45 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestDuplicateTrips(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// duplicate T1 as T5
+	copyTrip(t, db, "T1", "T5", 0)
+
+	issues, err := gtfs.DuplicateTrips(db)
+	if err != nil {
+		t.Fatalf("DuplicateTrips() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].ItemID != "T5" {
+		t.Errorf("DuplicateTrips() = %v, want a single issue for T5", issues)
+	}
+}
+
+func TestOverlappingBlockTrips(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// T1 (08:00 - 08:14) and T2 (08:20 - 08:34) don't overlap
+	db.Model(&gtfs.Trip{}).Where("id IN ?", []string{"T1", "T2"}).Update("block_id", "B1")
+	issues, err := gtfs.OverlappingBlockTrips(db)
+	if err != nil {
+		t.Fatalf("OverlappingBlockTrips() error = %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("OverlappingBlockTrips() = %v, want none", issues)
+	}
+
+	// delaying the arrival of T1 lets it overlap with T2
+	db.Model(&gtfs.StopTime{}).Where("trip_id = ? AND stop_seq = ?", "T1", 4).Update("arrival", 30*60+8*3600)
+	issues, err = gtfs.OverlappingBlockTrips(db)
+	if err != nil {
+		t.Fatalf("OverlappingBlockTrips() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].ItemID != "T2" || issues[0].Severity != gtfs.Error {
+		t.Errorf("OverlappingBlockTrips() = %v, want a single error for T2", issues)
+	}
+}
@@ -0,0 +1,146 @@
+package gtfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Encoding enumerates the character encodings of CSV files (see
+// ImportOptions.Encoding).
+type Encoding uint32
+
+const (
+
+	// EncodingUTF8 reads files as UTF-8 (as required by the GTFS reference).
+	EncodingUTF8 Encoding = iota
+
+	// EncodingLatin1 transcodes files from Latin-1 (ISO 8859-1) to UTF-8.
+	EncodingLatin1
+
+	// EncodingAuto transcodes files from Latin-1 to UTF-8, unless their
+	// beginning (see maxHeaderLength) is valid UTF-8.
+	EncodingAuto
+)
+
+var txEncoding = map[Encoding]string{
+	EncodingUTF8:   "utf-8",
+	EncodingLatin1: "latin-1",
+	EncodingAuto:   "auto",
+}
+
+// String returns a human-readable representation of Encoding.
+func (e Encoding) String() string {
+	if s := txEncoding[e]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown Encoding (%d)", uint32(e))
+}
+
+// ParseEncoding returns the Encoding with the given name (i.e. "utf-8",
+// "latin-1" or "auto").
+func ParseEncoding(s string) (Encoding, error) {
+	for e, name := range txEncoding {
+		if name == s {
+			return e, nil
+		}
+	}
+	return EncodingUTF8, fmt.Errorf("unknown encoding '%s'", s)
+}
+
+// utf8BOM is the byte order mark some tools (e.g. spreadsheets) start UTF-8
+// files with.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// textReader reads text, stripping a leading byte order mark, normalizing line
+// endings (CRLF and CR) outside of quoted CSV fields to LF and transcoding from
+// Latin-1 (if desired). Line breaks within quoted fields are kept as they are.
+type textReader struct {
+	r      *bufio.Reader
+	latin1 bool   // transcode from Latin-1
+	cr     bool   // the last byte read was CR (translated to LF)
+	quoted bool   // within a quoted field (i.e. after an odd number of quotes)
+	err    error  // the error of reading from r
+	buf    []byte // the bytes read from r
+	norm   []byte // the bytes normalized
+	out    []byte // the bytes normalized but not yet returned
+}
+
+// newTextReader returns a reader reading the text (e.g. a CSV file) from r,
+// decoded according to enc, as UTF-8 without byte order mark and with LF line
+// endings (as expected by normalizeCSV and the decoders).
+func newTextReader(r io.Reader, enc Encoding) io.Reader {
+	br := bufio.NewReaderSize(r, maxHeaderLength)
+	if b, _ := br.Peek(len(utf8BOM)); bytes.Equal(b, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+		enc = EncodingUTF8
+	}
+	latin1 := enc == EncodingLatin1
+	if enc == EncodingAuto {
+		b, err := br.Peek(maxHeaderLength)
+		latin1 = !validUTF8(b, err == nil)
+	}
+	return &textReader{r: br, latin1: latin1, buf: make([]byte, 32<<10)}
+}
+
+// Read reads normalized text.
+func (tr *textReader) Read(p []byte) (int, error) {
+	for len(tr.out) == 0 {
+		if tr.err != nil {
+			return 0, tr.err
+		}
+		var n int
+		n, tr.err = tr.r.Read(tr.buf)
+		tr.norm = tr.normalize(tr.norm[:0], tr.buf[:n])
+		tr.out = tr.norm
+	}
+	n := copy(p, tr.out)
+	tr.out = tr.out[n:]
+	return n, nil
+}
+
+// normalize appends the normalized text of b to out.
+func (tr *textReader) normalize(out, b []byte) []byte {
+	if !tr.latin1 && !tr.cr && bytes.IndexByte(b, '\r') < 0 {
+		if bytes.Count(b, []byte{'"'})%2 == 1 {
+			tr.quoted = !tr.quoted
+		}
+		return append(out, b...)
+	}
+	for _, c := range b {
+		if tr.cr {
+			tr.cr = false
+			if c == '\n' {
+				continue
+			}
+		}
+		switch {
+		case c == '"':
+			tr.quoted = !tr.quoted
+			out = append(out, c)
+		case c == '\r' && !tr.quoted:
+			out = append(out, '\n')
+			tr.cr = true
+		case tr.latin1 && c >= utf8.RuneSelf:
+			out = utf8.AppendRune(out, rune(c))
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// validUTF8 returns true, if b is valid UTF-8. If truncated is true, b may end
+// with an incomplete character.
+func validUTF8(b []byte, truncated bool) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			return truncated && len(b) < utf8.UTFMax && !utf8.FullRune(b)
+		}
+		b = b[size:]
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImportWithOptions_Encoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		encoding gtfs.Encoding
+		want     string
+	}{
+		{"utf-8", "stop_id,stop_name,stop_lat,stop_lon\nS1,Münchener Str.,52.5,13.4\n", gtfs.EncodingUTF8, "Münchener Str."},
+		{"bom and crlf", "\ufeffstop_id,stop_name,stop_lat,stop_lon\r\nS1,Münchener Str.,52.5,13.4\r\n", gtfs.EncodingUTF8, "Münchener Str."},
+		{"cr", "stop_id,stop_name,stop_lat,stop_lon\rS1,Münchener Str.,52.5,13.4\r", gtfs.EncodingUTF8, "Münchener Str."},
+		{"quoted crlf", "stop_id,stop_name,stop_lat,stop_lon\r\nS1,\"Münchener\r\nStr.\",52.5,13.4\r\n", gtfs.EncodingUTF8, "Münchener\nStr."},
+		{"quoted cr", "stop_id,stop_name,stop_lat,stop_lon\rS1,\"Münchener\rStr.\",52.5,13.4\r", gtfs.EncodingUTF8, "Münchener\rStr."},
+		{"quoted cr and quotes", "stop_id,stop_name,stop_lat,stop_lon\rS1,\"\"\"M\xfcnchener\"\"\rStr.\",52.5,13.4\r", gtfs.EncodingLatin1, "\"Münchener\"\rStr."},
+		{"latin-1", "stop_id,stop_name,stop_lat,stop_lon\r\nS1,M\xfcnchener Str.,52.5,13.4\r\n", gtfs.EncodingLatin1, "Münchener Str."},
+		{"auto latin-1", "stop_id,stop_name,stop_lat,stop_lon\nS1,M\xfcnchener Str.,52.5,13.4\n", gtfs.EncodingAuto, "Münchener Str."},
+		{"auto utf-8", "\ufeffstop_id,stop_name,stop_lat,stop_lon\nS1,Münchener Str.,52.5,13.4\n", gtfs.EncodingAuto, "Münchener Str."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := t.TempDir()
+			if err := os.WriteFile(filepath.Join(feed, "stops.txt"), []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			for _, decoder := range []gtfs.CSVDecoder{gtfs.GocsvDecoder{}, gtfs.FastDecoder{}} {
+				db := newTestDB(t)
+				summary := gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+					Encoding:  tt.encoding,
+					Decoder:   decoder,
+					ItemTypes: []gtfs.ItemType{gtfs.Stops},
+				})
+				if err := summary.Err(); err != nil {
+					t.Fatalf("ImportWithOptions(%T) error = %v", decoder, err)
+				}
+				var stop gtfs.Stop
+				if err := db.First(&stop, "id = ?", "S1").Error; err != nil {
+					t.Fatalf("ImportWithOptions(%T) did not import S1: %v", decoder, err)
+				}
+				if stop.Name != tt.want || stop.Latitude != 52.5 || stop.Longitude != 13.4 {
+					t.Errorf("ImportWithOptions(%T) stop = %q (%v, %v), want %q (52.5, 13.4)", decoder, stop.Name, stop.Latitude, stop.Longitude, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for _, e := range []gtfs.Encoding{gtfs.EncodingUTF8, gtfs.EncodingLatin1, gtfs.EncodingAuto} {
+		if got, err := gtfs.ParseEncoding(e.String()); err != nil || got != e {
+			t.Errorf("ParseEncoding(%q) = %v, %v, want %v", e.String(), got, err, e)
+		}
+	}
+	if _, err := gtfs.ParseEncoding("utf-16"); err == nil {
+		t.Errorf("ParseEncoding() expected error")
+	}
+}
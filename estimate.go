@@ -0,0 +1,170 @@
+package gtfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+)
+
+// dbSizeFactor is the (conservative) ratio of the size of the DB (including
+// indexes and search indexes) to the size of the imported CSV files.
+const dbSizeFactor = 2
+
+// ErrInsufficientSpace is returned (wrapped) by ImportEstimate.Check, if the
+// destination of an import lacks the space estimated.
+var ErrInsufficientSpace = errors.New("insufficient space")
+
+// ImportEstimate is the estimated size of an import (see EstimateImport).
+type ImportEstimate struct {
+	Rows      map[ItemType]int64 // the number of rows per item type (of the files present)
+	TotalRows int64              // the total number of rows
+	FileSize  int64              // the total size (in bytes) of the CSV files
+	DBSize    int64              // the estimated size (in bytes) of the DB
+	FreeSpace int64              // the free space (in bytes) at the destination of the DB (-1, if unknown)
+}
+
+// String returns a human-readable representation of ImportEstimate (e.g.
+// "about 1234567 rows, 210.3 MB of DB (15.2 GB free)").
+func (ie ImportEstimate) String() string {
+	s := fmt.Sprintf("about %d rows, %s of DB", ie.TotalRows, formatSize(ie.DBSize))
+	if ie.FreeSpace >= 0 {
+		s += fmt.Sprintf(" (%s free)", formatSize(ie.FreeSpace))
+	}
+	return s
+}
+
+// Fits returns true, if the estimated DB fits into the free space at the
+// destination (or the free space is unknown).
+func (ie ImportEstimate) Fits() bool {
+	return ie.FreeSpace < 0 || ie.DBSize <= ie.FreeSpace
+}
+
+// Check returns an error wrapping ErrInsufficientSpace, if the estimated DB
+// doesn't fit into the free space at the destination (see Fits).
+func (ie ImportEstimate) Check() error {
+	if ie.Fits() {
+		return nil
+	}
+	return fmt.Errorf("%w: the import needs %s, but only %s are free", ErrInsufficientSpace, formatSize(ie.DBSize), formatSize(ie.FreeSpace))
+}
+
+// EstimateImport scans the GTFS CSV files of the directory (or zip archive,
+// see ImportZip) gtfsBase to estimate the number of rows and the size of the
+// DB an import into db would result in, along with the free space at the
+// destination of db. Rows are estimated by counting lines (i.e. quoted line
+// breaks are counted as rows). Callers should check the estimate (see Check)
+// before importing (rather than having huge imports fail at the very end) and
+// may pass it along with the progress of the import (see
+// ImportOptions.Estimate).
+func EstimateImport(db *gorm.DB, gtfsBase string) (*ImportEstimate, error) {
+	fsys, dir, closeFeed, err := openFeed(gtfsBase)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFeed()
+
+	// count the rows of each of the files present
+	estimate := &ImportEstimate{Rows: map[ItemType]int64{}}
+	for _, source := range importSources {
+		name := path.Join(dir, itemFiles[source.itemType])
+		info, err := fs.Stat(fsys, name)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		rows, err := countRows(fsys, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan '%s': %w", path.Join(gtfsBase, name), err)
+		}
+		estimate.Rows[source.itemType] = rows
+		estimate.TotalRows += rows
+		estimate.FileSize += info.Size()
+	}
+	estimate.DBSize = estimate.FileSize * dbSizeFactor
+
+	// the free space at the destination
+	estimate.FreeSpace = -1
+	if dbPath := dbFile(db); dbPath != "" {
+		estimate.FreeSpace = freeSpace(filepath.Dir(dbPath))
+	}
+	return estimate, nil
+}
+
+// countRows returns the number of rows (i.e. lines, but the header) of the
+// file named name within fsys.
+func countRows(fsys fs.FS, name string) (int64, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+	var lines int64
+	var last byte = '\n'
+	buf := make([]byte, 64<<10)
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			lines += int64(bytes.Count(buf[:n], []byte{'\n'}))
+			last = buf[n-1]
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+	if last != '\n' {
+		lines++
+	}
+	if lines == 0 {
+		return 0, nil
+	}
+	return lines - 1, nil
+}
+
+// dbFile returns the path of the file of the (main) SQLite db ("", if
+// unknown, e.g. for in-memory DBs or DBs other than SQLite).
+func dbFile(db *gorm.DB) string {
+	if !isSQLite(db) {
+		return ""
+	}
+	var databases []struct {
+		Seq  int
+		Name string
+		File string
+	}
+	if tx := db.Raw("PRAGMA database_list").Scan(&databases); tx.Error != nil {
+		return ""
+	}
+	for _, d := range databases {
+		if d.Name == "main" {
+			return d.File
+		}
+	}
+	return ""
+}
+
+// formatSize returns a human-readable representation of a size in bytes (e.g.
+// "15.2 GB").
+func formatSize(size int64) string {
+	const unit = 1000
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(size)/float64(div), "kMGTPE"[exp])
+}
@@ -0,0 +1,110 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"path"
+	"testing"
+)
+
+func TestEstimateImport(t *testing.T) {
+	zipPath := path.Join(t.TempDir(), "feed.zip")
+	writeZip(t, zipPath, []string{"gtfs"}, nil)
+	wantRows := map[gtfs.ItemType]int64{
+		gtfs.Agencies:      2,
+		gtfs.Routes:        2,
+		gtfs.Trips:         4,
+		gtfs.Stops:         7,
+		gtfs.StopTimes:     15,
+		gtfs.Shapes:        11,
+		gtfs.Calendars:     2,
+		gtfs.CalendarDates: 2,
+	}
+
+	tests := []struct {
+		name          string
+		gtfsBase      string
+		fileDB        bool
+		wantFreeSpace bool
+	}{
+		{"directory", fixtureFeed, false, false},
+		{"zip", zipPath, false, false},
+		{"file DB", fixtureFeed, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if tt.fileDB {
+				var err error
+				db, err = gorm.Open(sqlite.Open(path.Join(t.TempDir(), "gtfs.db")), &gorm.Config{
+					Logger: logger.Default.LogMode(logger.Silent),
+				})
+				if err != nil {
+					t.Fatalf("failed to open DB: %v", err)
+				}
+				sqlDB, err := db.DB()
+				if err != nil {
+					t.Fatalf("failed to get DB: %v", err)
+				}
+				t.Cleanup(func() {
+					_ = sqlDB.Close()
+				})
+			}
+			estimate, err := gtfs.EstimateImport(db, tt.gtfsBase)
+			if err != nil {
+				t.Fatalf("EstimateImport() error = %v", err)
+			}
+			if len(estimate.Rows) != len(wantRows) {
+				t.Errorf("EstimateImport() rows = %v, want %v", estimate.Rows, wantRows)
+			}
+			for itemType, want := range wantRows {
+				if got := estimate.Rows[itemType]; got != want {
+					t.Errorf("EstimateImport() rows of %s = %d, want %d", itemType, got, want)
+				}
+			}
+			if estimate.TotalRows != 45 {
+				t.Errorf("EstimateImport() total rows = %d, want 45", estimate.TotalRows)
+			}
+			if estimate.DBSize <= estimate.FileSize || estimate.FileSize == 0 {
+				t.Errorf("EstimateImport() DB size = %d, file size = %d", estimate.DBSize, estimate.FileSize)
+			}
+			if gotFreeSpace := estimate.FreeSpace >= 0; gotFreeSpace != tt.wantFreeSpace {
+				t.Errorf("EstimateImport() free space = %d, want known = %v", estimate.FreeSpace, tt.wantFreeSpace)
+			}
+			if err = estimate.Check(); err != nil {
+				t.Errorf("Check() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestImportEstimate_Check(t *testing.T) {
+	tests := []struct {
+		name      string
+		freeSpace int64
+		wantErr   bool
+	}{
+		{"unknown", -1, false},
+		{"fits", 2000, false},
+		{"exact", 1000, false},
+		{"lacking", 999, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estimate := gtfs.ImportEstimate{DBSize: 1000, FreeSpace: tt.freeSpace}
+			err := estimate.Check()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, gtfs.ErrInsufficientSpace) {
+				t.Errorf("Check() error = %v, want ErrInsufficientSpace", err)
+			}
+			if estimate.Fits() == tt.wantErr {
+				t.Errorf("Fits() = %v, want %v", estimate.Fits(), !tt.wantErr)
+			}
+		})
+	}
+}
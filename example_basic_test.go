@@ -20,8 +20,8 @@ func Example() {
 	// SELECT * FROM agencies WHERE id = 1;
 	agency := gtfs.Agency{}
 	db.First(&agency, "id = ?", 1)
-	fmt.Println(agency)
+	fmt.Println(agency.ID, agency.Name, agency.URL)
 
 	// Output:
-	// {1 S-Bahn Berlin GmbH https://sbahn.berlin/}
+	// 1 S-Bahn Berlin GmbH https://sbahn.berlin/
 }
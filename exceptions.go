@@ -0,0 +1,96 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// ServiceException summarizes the calendar dates of a day deviating from the
+// regular (weekly) service given by the calendars.
+type ServiceException struct {
+	Date    time.Time // the day (midnight in the location of from, see ServiceExceptions)
+	Removed []string  // services not running, although scheduled by their calendar
+	Added   []string  // services running, although not scheduled by their calendar
+}
+
+// String returns a human-readable representation of ServiceException (e.g.
+// "no service on 2024-12-25 for services S1,S2; extra service on 2024-12-25
+// for service S3").
+func (se ServiceException) String() string {
+	date := se.Date.Format("2006-01-02")
+	services := func(ids []string) string {
+		if len(ids) == 1 {
+			return "service " + ids[0]
+		}
+		return "services " + strings.Join(ids, ",")
+	}
+	var parts []string
+	if len(se.Removed) > 0 {
+		parts = append(parts, fmt.Sprintf("no service on %s for %s", date, services(se.Removed)))
+	}
+	if len(se.Added) > 0 {
+		parts = append(parts, fmt.Sprintf("extra service on %s for %s", date, services(se.Added)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ServiceExceptions summarizes the calendar dates from the date of from to the
+// date of to (both inclusive) into the days deviating from the regular service
+// (ordered by date), i.e. services removed although scheduled by their calendar
+// and services added although not scheduled by their calendar. Calendar dates
+// not changing the service (e.g. removing a weekday service on a Sunday) and
+// services without calendar (i.e. defined by calendar dates only) are omitted.
+func ServiceExceptions(db *gorm.DB, from, to time.Time) ([]ServiceException, error) {
+	var calendarDates []CalendarDate
+	tx := db.
+		Where("date BETWEEN ? AND ?", from.Format(DateLayout), to.Format(DateLayout)).
+		Where("service_id IN (?)", db.Model(&Calendar{}).Select("service_id")).
+		Order("date").
+		Order("service_id").
+		Find(&calendarDates)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	var calendars []Calendar
+	if tx = db.Find(&calendars); tx.Error != nil {
+		return nil, tx.Error
+	}
+	calendarsByService := map[string]Calendar{}
+	for _, c := range calendars {
+		calendarsByService[c.ServiceID] = c
+	}
+
+	exceptions := []ServiceException{}
+	for _, cd := range calendarDates {
+		date, err := time.ParseInLocation(DateLayout, cd.Date, from.Location())
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse GTFS date from '%s': %w", cd.Date, err)
+		}
+		scheduled := calendarsByService[cd.ServiceID].scheduled(date)
+		if (cd.ExceptionType == 1) == scheduled {
+			continue
+		}
+		if n := len(exceptions); n == 0 || !exceptions[n-1].Date.Equal(date) {
+			exceptions = append(exceptions, ServiceException{Date: date})
+		}
+		e := &exceptions[len(exceptions)-1]
+		if cd.ExceptionType == 1 {
+			e.Added = append(e.Added, cd.ServiceID)
+		} else {
+			e.Removed = append(e.Removed, cd.ServiceID)
+		}
+	}
+	return exceptions, nil
+}
+
+// scheduled returns true, if the calendar schedules its service on the date of
+// day (disregarding calendar dates).
+func (c Calendar) scheduled(day time.Time) bool {
+	date := day.Format(DateLayout)
+	if date < c.StartDate || date > c.EndDate {
+		return false
+	}
+	return [...]int{c.Sunday, c.Monday, c.Tuesday, c.Wednesday, c.Thursday, c.Friday, c.Saturday}[day.Weekday()] == 1
+}
@@ -0,0 +1,49 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+)
+
+func TestServiceExceptions(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// removing a service not scheduled anyway is no exception
+	db.Create(&gtfs.CalendarDate{ServiceID: "WD", Date: "20221225", ExceptionType: 2})
+	// neither are services defined by calendar dates only
+	db.Create(&gtfs.CalendarDate{ServiceID: "X", Date: "20221231", ExceptionType: 1})
+	// but adding the weekend service on a Friday is
+	db.Create(&gtfs.CalendarDate{ServiceID: "WE", Date: "20221230", ExceptionType: 1})
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     []string
+	}{
+		{"all", "20221201", "20221231", []string{
+			"no service on 2022-12-26 for service WD; extra service on 2022-12-26 for service WE",
+			"extra service on 2022-12-30 for service WE",
+		}},
+		{"day", "20221230", "20221230", []string{"extra service on 2022-12-30 for service WE"}},
+		{"none", "20221101", "20221130", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, _ := time.Parse(gtfs.DateLayout, tt.from)
+			to, _ := time.Parse(gtfs.DateLayout, tt.to)
+			got, err := gtfs.ServiceExceptions(db, from, to)
+			if err != nil {
+				t.Fatalf("ServiceExceptions() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ServiceExceptions() = %v, want %v", got, tt.want)
+			}
+			for i, e := range got {
+				if e.String() != tt.want[i] {
+					t.Errorf("ServiceExceptions()[%d] = %q, want %q", i, e.String(), tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,303 @@
+package gtfs
+
+import (
+	"encoding/csv"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvMarshaler is implemented by types that marshal themselves to CSV.
+type csvMarshaler interface {
+	MarshalCSV() (string, error)
+}
+
+var csvMarshalerType = reflect.TypeOf((*csvMarshaler)(nil)).Elem()
+
+// csvColumn describes a CSV column backed by a field of a model.
+type csvColumn struct {
+	name  string
+	index int
+}
+
+// csvColumns returns the CSV columns of the given model type, i.e. all fields
+// having a csv tag.
+func csvColumns(t reflect.Type) []csvColumn {
+	var columns []csvColumn
+	for i := 0; i < t.NumField(); i++ {
+		name := t.Field(i).Tag.Get("csv")
+		if name == "" || name == "-" {
+			continue
+		}
+		columns = append(columns, csvColumn{name: name, index: i})
+	}
+	return columns
+}
+
+// csvValue returns the CSV representation of the given (addressable) field.
+func csvValue(v reflect.Value) (string, error) {
+	if v.CanAddr() && v.Addr().Type().Implements(csvMarshalerType) {
+		return v.Addr().Interface().(csvMarshaler).MarshalCSV()
+	}
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return "1", nil
+		}
+		return "0", nil
+	default:
+		return "", fmt.Errorf("cannot marshal %s to CSV", v.Type())
+	}
+}
+
+// formulaPrefixes are the characters spreadsheets interpret as the start of a
+// formula (when leading a cell).
+const formulaPrefixes = "=+-@\t\r"
+
+// sanitizeCSV neutralizes a value spreadsheets would interpret as formula by
+// prefixing it with a single quote.
+func sanitizeCSV(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes, rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// exportOrders maps item types to the order of their rows on export (by their
+// natural keys, falling back to the order of insertion for items sharing
+// those). Item types not mapped are ordered by ID.
+var exportOrders = map[ItemType]string{
+	StopTimes:     "trip_id, stop_seq, id",
+	Shapes:        "shape_id, pt_sequence, id",
+	Calendars:     "service_id, id",
+	CalendarDates: "service_id, date, id",
+	Frequencies:   "trip_id, start_time, id",
+}
+
+// exportOrder returns the order of the rows of the given item type on export.
+func exportOrder(itemType ItemType) string {
+	if order, ok := exportOrders[itemType]; ok {
+		return order
+	}
+	return "id"
+}
+
+// ExportOptions configures Export.
+type ExportOptions struct {
+
+	// CompressHeadways represents trips running at a regular headway (see
+	// DetectHeadways) by a single trip and a frequency.
+	CompressHeadways bool
+
+	// Sanitize prefixes text values starting with "=", "+", "-", "@", a tab or
+	// a carriage return with a single quote, such that exported files opened
+	// in spreadsheets can't inject formulas. Numbers are not affected. Note
+	// that sanitized files no longer reproduce the original values.
+	Sanitize bool
+}
+
+// Export writes all items within the DB as GTFS CSV files to the directory
+// dir (which must exist). Files for item types without items are omitted.
+// Rows are ordered by their natural keys (e.g. stop times by trip and stop
+// sequence), such that the same DB content always results in the same bytes.
+// Encoded shapes (see EncodeShapes) are decoded and stops are exported by their
+// display names (see SetStopOverride). Translations, fares and vehicle
+// capacities (if imported, see ImportTranslations, ImportFares and
+// ImportVehicleCapacities) are exported as well.
+func Export(db *gorm.DB, dir string, opts ExportOptions) error {
+
+	// trips represented by others and frequencies representing them
+	skipTrips := map[string]bool{}
+	var frequencies []*Frequency
+	if opts.CompressHeadways {
+		patterns, err := DetectHeadways(db)
+		if err != nil {
+			return fmt.Errorf("failed to detect headways: %w", err)
+		}
+		for _, p := range patterns {
+			for _, tripID := range p.TripIDs[1:] {
+				skipTrips[tripID] = true
+			}
+			f := p.Frequency()
+			frequencies = append(frequencies, &f)
+		}
+	}
+
+	// stops are exported by their display names
+	overrides, err := stopOverrides(db)
+	if err != nil {
+		return err
+	}
+
+	skip := func(item interface{}) bool {
+		switch i := item.(type) {
+		case *Trip:
+			return skipTrips[i.ID]
+		case *StopTime:
+			return skipTrips[i.TripID]
+		case *Stop:
+			i.Name = overrides.name(*i)
+		}
+		return false
+	}
+
+	itemTypes := make([]ItemType, 0, len(itemModels))
+	for itemType := range itemModels {
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Slice(itemTypes, func(i, j int) bool {
+		return itemTypes[i] < itemTypes[j]
+	})
+
+	for _, itemType := range itemTypes {
+		var extra []interface{}
+		if itemType == Frequencies {
+			for _, f := range frequencies {
+				extra = append(extra, f)
+			}
+		}
+		if itemType == Shapes {
+			points, err := encodedShapes(db, nil)
+			if err != nil {
+				return fmt.Errorf("failed to decode shapes: %w", err)
+			}
+			for i := range points {
+				extra = append(extra, &points[i])
+			}
+		}
+		if err := exportFile(db, path.Join(dir, itemFiles[itemType]), itemModels[itemType], exportOrder(itemType), skip, extra, opts.Sanitize); err != nil {
+			return fmt.Errorf("failed to export %s: %w", itemType, err)
+		}
+	}
+
+	// export translations (if imported)
+	if db.Migrator().HasTable(&Translation{}) {
+		if err := exportFile(db, path.Join(dir, translationsFile), &Translation{}, "id", nil, nil, opts.Sanitize); err != nil {
+			return fmt.Errorf("failed to export translations: %w", err)
+		}
+	}
+
+	// export fares (if imported)
+	for _, f := range fareFiles {
+		if !db.Migrator().HasTable(f.model) {
+			continue
+		}
+		if err := exportFile(db, path.Join(dir, f.name), f.model, "id", nil, nil, opts.Sanitize); err != nil {
+			return fmt.Errorf("failed to export %s: %w", f.name, err)
+		}
+	}
+
+	// export vehicle capacities (if imported)
+	if db.Migrator().HasTable(&VehicleCapacity{}) {
+		if err := exportFile(db, path.Join(dir, vehicleCapacitiesFile), &VehicleCapacity{}, "id", nil, nil, opts.Sanitize); err != nil {
+			return fmt.Errorf("failed to export vehicle capacities: %w", err)
+		}
+	}
+	return nil
+}
+
+// exportFile writes all items of the given model (except for skipped ones)
+// followed by extra items to a CSV file (see exportItems). If there are no
+// items, no file is written.
+func exportFile(db *gorm.DB, filePath string, model interface{}, order string, skip func(interface{}) bool, extra []interface{}, sanitize bool) error {
+
+	var count int64
+	if tx := db.Model(model).Count(&count); tx.Error != nil {
+		return tx.Error
+	}
+	if count == 0 && len(extra) == 0 {
+		return nil
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	if err = exportItems(db, file, model, order, skip, extra, sanitize); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// exportItems writes all items of the given model (except for skipped ones,
+// skip may modify the others) in the given order followed by extra items as
+// CSV to w. Values containing commas, quotes or line
+// breaks are quoted (see csv.Writer). If sanitize is true, text values are
+// sanitized (see sanitizeCSV).
+func exportItems(db *gorm.DB, w io.Writer, model interface{}, order string, skip func(interface{}) bool, extra []interface{}, sanitize bool) error {
+	t := reflect.TypeOf(model).Elem()
+	columns := csvColumns(t)
+
+	cw := csv.NewWriter(w)
+	record := make([]string, len(columns))
+	for i, c := range columns {
+		record[i] = c.name
+	}
+	if err := cw.Write(record); err != nil {
+		return err
+	}
+	write := func(item interface{}) error {
+		v := reflect.ValueOf(item).Elem()
+		for i, c := range columns {
+			f := v.Field(c.index)
+			s, err := csvValue(f)
+			if err != nil {
+				return err
+			}
+			if sanitize && f.Kind() == reflect.String {
+				s = sanitizeCSV(s)
+			}
+			record[i] = s
+		}
+		return cw.Write(record)
+	}
+
+	rows, err := db.Model(model).Order(order).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		item := reflect.New(t).Interface()
+		if err = db.ScanRows(rows, item); err != nil {
+			return err
+		}
+		if skip != nil && skip(item) {
+			continue
+		}
+		if err = write(item); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+	for _, item := range extra {
+		if err = write(item); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
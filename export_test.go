@@ -0,0 +1,126 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	db := newFixtureDB(t)
+	copyTrip(t, db, "T2", "T6", 20*60)
+
+	dir := t.TempDir()
+	if err := gtfs.Export(db, dir, gtfs.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	// files without items are omitted
+	if _, err := os.Stat(path.Join(dir, "frequencies.txt")); !os.IsNotExist(err) {
+		t.Errorf("Export() wrote frequencies.txt without frequencies")
+	}
+
+	b, err := os.ReadFile(path.Join(dir, "stop_times.txt"))
+	if err != nil {
+		t.Fatalf("failed to read stop_times.txt: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	if want := "stop_id,trip_id,departure_time,arrival_time,stop_sequence"; lines[0] != want {
+		t.Errorf("Export() header = %q, want %q", lines[0], want)
+	}
+	if want := "S1,T1,08:00:00,08:00:00,1"; lines[1] != want {
+		t.Errorf("Export() first row = %q, want %q", lines[1], want)
+	}
+}
+
+func TestExport_CompressHeadways(t *testing.T) {
+	db := newFixtureDB(t)
+	copyTrip(t, db, "T2", "T6", 20*60)
+
+	dir := t.TempDir()
+	if err := gtfs.Export(db, dir, gtfs.ExportOptions{CompressHeadways: true}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	b, err := os.ReadFile(path.Join(dir, "frequencies.txt"))
+	if err != nil {
+		t.Fatalf("failed to read frequencies.txt: %v", err)
+	}
+	if want := "trip_id,start_time,end_time,headway_secs,exact_times\nT1,08:00:00,09:00:00,1200,1\n"; string(b) != want {
+		t.Errorf("Export() frequencies = %q, want %q", string(b), want)
+	}
+
+	// T2 and T6 are represented by T1
+	b, err = os.ReadFile(path.Join(dir, "trips.txt"))
+	if err != nil {
+		t.Fatalf("failed to read trips.txt: %v", err)
+	}
+	if s := string(b); strings.Contains(s, "T2") || strings.Contains(s, "T6") || !strings.Contains(s, "T1") {
+		t.Errorf("Export() trips = %q, want T1 but neither T2 nor T6", s)
+	}
+}
+
+func TestExport_Sanitize(t *testing.T) {
+	db := newFixtureDB(t)
+	if tx := db.Model(&gtfs.Stop{}).Where("id = ?", "S1").Updates(map[string]interface{}{"name": `=HYPERLINK("http://x","S Wannsee")`, "longitude": -13.179}); tx.Error != nil {
+		t.Fatalf("failed to update stop: %v", tx.Error)
+	}
+
+	tests := []struct {
+		sanitize bool
+		want     string
+	}{
+		{false, `S1,"=HYPERLINK(""http://x"",""S Wannsee"")",52.421,-13.179,0,0,`},
+		{true, `S1,"'=HYPERLINK(""http://x"",""S Wannsee"")",52.421,-13.179,0,0,`},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		if err := gtfs.Export(db, dir, gtfs.ExportOptions{Sanitize: tt.sanitize}); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		b, err := os.ReadFile(path.Join(dir, "stops.txt"))
+		if err != nil {
+			t.Fatalf("failed to read stops.txt: %v", err)
+		}
+		if !strings.Contains(string(b), tt.want+"\n") {
+			t.Errorf("Export(Sanitize: %t) = %q, want row %q", tt.sanitize, string(b), tt.want)
+		}
+	}
+}
+
+func TestExport_Deterministic(t *testing.T) {
+
+	// export the fixture
+	want := t.TempDir()
+	if err := gtfs.Export(newFixtureDB(t), want, gtfs.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	// re-insert the stop times of T1 (in reverse order) and export again
+	db := newFixtureDB(t)
+	var stopTimes []gtfs.StopTime
+	db.Order("stop_seq DESC").Find(&stopTimes, "trip_id = ?", "T1")
+	db.Delete(&gtfs.StopTime{}, "trip_id = ?", "T1")
+	for _, st := range stopTimes {
+		st.ID = 0
+		db.Create(&st)
+	}
+	got := t.TempDir()
+	if err := gtfs.Export(db, got, gtfs.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	entries, err := os.ReadDir(want)
+	if err != nil {
+		t.Fatalf("failed to read dir: %v", err)
+	}
+	for _, e := range entries {
+		wantBytes, _ := os.ReadFile(path.Join(want, e.Name()))
+		gotBytes, _ := os.ReadFile(path.Join(got, e.Name()))
+		if string(gotBytes) != string(wantBytes) {
+			t.Errorf("Export() %s = %q, want %q", e.Name(), gotBytes, wantBytes)
+		}
+	}
+}
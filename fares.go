@@ -0,0 +1,190 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+)
+
+// names of the (optional) GTFS files describing fares (GTFS Fares v2)
+const (
+	fareProductsFile      = "fare_products.txt"
+	fareLegRulesFile      = "fare_leg_rules.txt"
+	fareTransferRulesFile = "fare_transfer_rules.txt"
+)
+
+// FareProduct model (a fare product, e.g. a single ticket or a day pass, as
+// available via a fare media).
+type FareProduct struct {
+	ID              uint    `gorm:"primaryKey,autoIncrement" csv:"-"`
+	FareProductID   string  `gorm:"index" csv:"fare_product_id"`
+	FareProductName string  `csv:"fare_product_name"`
+	FareMediaID     string  `csv:"fare_media_id"`
+	Amount          float64 `csv:"amount"`
+	Currency        string  `csv:"currency"`
+}
+
+// FareLegRule model (the fare product to use for legs within a network from
+// one area to another).
+type FareLegRule struct {
+	ID                   uint   `gorm:"primaryKey,autoIncrement" csv:"-"`
+	LegGroupID           string `csv:"leg_group_id"`
+	NetworkID            string `csv:"network_id"`
+	FromAreaID           string `csv:"from_area_id"`
+	ToAreaID             string `csv:"to_area_id"`
+	FromTimeframeGroupID string `csv:"from_timeframe_group_id"`
+	ToTimeframeGroupID   string `csv:"to_timeframe_group_id"`
+	FareProductID        string `csv:"fare_product_id"`
+	RulePriority         int    `csv:"rule_priority"`
+}
+
+// FareTransferRule model (the cost of transferring between legs of two leg
+// groups).
+type FareTransferRule struct {
+	ID                uint   `gorm:"primaryKey,autoIncrement" csv:"-"`
+	FromLegGroupID    string `csv:"from_leg_group_id"`
+	ToLegGroupID      string `csv:"to_leg_group_id"`
+	TransferCount     int    `csv:"transfer_count"`      // -1 (no limit), the number of transfers or 0 (not applicable)
+	DurationLimit     int    `csv:"duration_limit"`      // in seconds, 0 (no limit)
+	DurationLimitType int    `csv:"duration_limit_type"` // 0 - 3 (the events the duration is measured between)
+	FareTransferType  int    `csv:"fare_transfer_type"`  // 0 - 2 (how the cost of the transfer is computed)
+	FareProductID     string `csv:"fare_product_id"`
+}
+
+// fareFiles lists the files describing fares along with (prototypes of) their
+// models and the functions importing them (in the order of import and export).
+var fareFiles = []struct {
+	name    string
+	model   interface{}
+	replace func(db *gorm.DB, fsys fs.FS, name string) (int64, error)
+}{
+	{fareProductsFile, &FareProduct{}, replaceItems[FareProduct]},
+	{fareLegRulesFile, &FareLegRule{}, replaceItems[FareLegRule]},
+	{fareTransferRulesFile, &FareTransferRule{}, replaceItems[FareTransferRule]},
+}
+
+// ImportFares imports the files describing fares (GTFS Fares v2, i.e.
+// fare_products.txt, fare_leg_rules.txt and fare_transfer_rules.txt, each if
+// present) from the directory (or zip archive, see ImportZip) gtfsBase
+// (replacing any previously imported fares) and returns the number of items
+// imported.
+func ImportFares(db *gorm.DB, gtfsBase string) (int64, error) {
+	fsys, dir, closeFeed, err := openFeed(gtfsBase)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFeed()
+
+	var count int64
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for _, f := range fareFiles {
+			name := path.Join(dir, f.name)
+			if _, err := fs.Stat(fsys, name); errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			n, err := f.replace(tx, fsys, name)
+			if err != nil {
+				return err
+			}
+			count += n
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to import fares: %w", err)
+	}
+	return count, nil
+}
+
+// GetFareProduct returns the fare product with the given ID (one item per fare
+// media it is available via). If there is no such fare product,
+// gorm.ErrRecordNotFound is returned.
+func GetFareProduct(db *gorm.DB, id string) ([]FareProduct, error) {
+	var products []FareProduct
+	if db.Migrator().HasTable(&FareProduct{}) {
+		if tx := db.Where("fare_product_id = ?", id).Order("id").Find(&products); tx.Error != nil {
+			return nil, tx.Error
+		}
+	}
+	if len(products) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return products, nil
+}
+
+// FareLeg describes a leg of a journey to find the fare leg rules for (see
+// MatchFareLegRules).
+type FareLeg struct {
+	NetworkID  string
+	FromAreaID string
+	ToAreaID   string
+}
+
+// MatchFareLegRules returns the fare leg rules applying to the given leg. As
+// defined by GTFS Fares v2, a rule matches a leg if each of its network and
+// areas either equals the one of the leg or is empty, while empty values only
+// match if no rule names the value of the leg. Among the matching rules, only
+// those of the highest priority are returned. Timeframes are not considered.
+// If there are no fare leg rules, none are returned.
+func MatchFareLegRules(db *gorm.DB, leg FareLeg) ([]FareLegRule, error) {
+	var rules []FareLegRule
+	if !db.Migrator().HasTable(&FareLegRule{}) {
+		return rules, nil
+	}
+	if tx := db.Order("id").Find(&rules); tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// match network and areas (falling back to empty values)
+	for _, field := range []struct {
+		value string
+		get   func(r FareLegRule) string
+	}{
+		{leg.NetworkID, func(r FareLegRule) string { return r.NetworkID }},
+		{leg.FromAreaID, func(r FareLegRule) string { return r.FromAreaID }},
+		{leg.ToAreaID, func(r FareLegRule) string { return r.ToAreaID }},
+	} {
+		want := ""
+		for _, r := range rules {
+			if field.value != "" && field.get(r) == field.value {
+				want = field.value
+				break
+			}
+		}
+		matching := rules[:0]
+		for _, r := range rules {
+			if field.get(r) == want {
+				matching = append(matching, r)
+			}
+		}
+		rules = matching
+	}
+
+	// keep the rules of the highest priority
+	var matches []FareLegRule
+	for _, r := range rules {
+		if len(matches) > 0 && r.RulePriority < matches[0].RulePriority {
+			continue
+		}
+		if len(matches) > 0 && r.RulePriority > matches[0].RulePriority {
+			matches = matches[:0]
+		}
+		matches = append(matches, r)
+	}
+	return matches, nil
+}
+
+// FareTransferRules returns the fare transfer rules for transferring from a leg
+// of the leg group from to a leg of the leg group to (see FareLegRule).
+func FareTransferRules(db *gorm.DB, from, to string) ([]FareTransferRule, error) {
+	var rules []FareTransferRule
+	if !db.Migrator().HasTable(&FareTransferRule{}) {
+		return rules, nil
+	}
+	if tx := db.Where("from_leg_group_id = ? AND to_leg_group_id = ?", from, to).Order("id").Find(&rules); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return rules, nil
+}
@@ -0,0 +1,117 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"os"
+	"path"
+	"testing"
+)
+
+// fares (GTFS Fares v2) of the fixture: single tickets (paper or app) within
+// and between zones A and B, a cheaper single ticket for the bus network and
+// free transfers within 2 hours
+var faresCSV = map[string]string{
+	"fare_products.txt": `fare_product_id,fare_product_name,fare_media_id,amount,currency
+single_ab,Single AB,paper,3.20,EUR
+single_ab,Single AB,app,3.00,EUR
+single_a,Single A,paper,2.50,EUR
+short,Short Trip,paper,2.00,EUR
+`,
+	"fare_leg_rules.txt": `leg_group_id,network_id,from_area_id,to_area_id,from_timeframe_group_id,to_timeframe_group_id,fare_product_id,rule_priority
+single,,A,A,,,single_a,0
+single,,A,B,,,single_ab,0
+single,,,,,,single_ab,0
+single,bus,A,A,,,single_a,0
+short,bus,A,A,,,short,1
+`,
+	"fare_transfer_rules.txt": `from_leg_group_id,to_leg_group_id,transfer_count,duration_limit,duration_limit_type,fare_transfer_type,fare_product_id
+single,single,-1,7200,1,0,
+`,
+}
+
+func TestImportFares(t *testing.T) {
+	feed := writeFeed(t, nil)
+	db := newTestDB(t)
+	gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{})
+
+	// no fares
+	if n, err := gtfs.ImportFares(db, feed); err != nil || n != 0 {
+		t.Errorf("ImportFares() = %d, %v, want 0", n, err)
+	}
+	if _, err := gtfs.GetFareProduct(db, "single_ab"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetFareProduct() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if rules, err := gtfs.MatchFareLegRules(db, gtfs.FareLeg{FromAreaID: "A", ToAreaID: "B"}); err != nil || len(rules) != 0 {
+		t.Errorf("MatchFareLegRules() = %v, %v, want none", rules, err)
+	}
+
+	// importing twice replaces fares
+	for name, csv := range faresCSV {
+		if err := os.WriteFile(path.Join(feed, name), []byte(csv), 0o644); err != nil {
+			t.Fatalf("failed to write fares: %v", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := gtfs.ImportFares(db, feed); err != nil || n != 10 {
+			t.Fatalf("ImportFares() = %d, %v, want 10", n, err)
+		}
+	}
+
+	products, err := gtfs.GetFareProduct(db, "single_ab")
+	if err != nil {
+		t.Fatalf("GetFareProduct() error = %v", err)
+	}
+	if len(products) != 2 || products[0].FareMediaID != "paper" || products[0].Amount != 3.2 || products[1].Currency != "EUR" {
+		t.Errorf("GetFareProduct() = %+v", products)
+	}
+
+	transfers, err := gtfs.FareTransferRules(db, "single", "single")
+	if err != nil {
+		t.Fatalf("FareTransferRules() error = %v", err)
+	}
+	if len(transfers) != 1 || transfers[0].TransferCount != -1 || transfers[0].DurationLimit != 7200 {
+		t.Errorf("FareTransferRules() = %+v", transfers)
+	}
+}
+
+func TestMatchFareLegRules(t *testing.T) {
+	db := newFixtureDB(t)
+	feed := t.TempDir()
+	for name, csv := range faresCSV {
+		if err := os.WriteFile(path.Join(feed, name), []byte(csv), 0o644); err != nil {
+			t.Fatalf("failed to write fares: %v", err)
+		}
+	}
+	if _, err := gtfs.ImportFares(db, feed); err != nil {
+		t.Fatalf("ImportFares() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		leg  gtfs.FareLeg
+		want []string
+	}{
+		{"within zone", gtfs.FareLeg{FromAreaID: "A", ToAreaID: "A"}, []string{"single_a"}},
+		{"between zones", gtfs.FareLeg{FromAreaID: "A", ToAreaID: "B"}, []string{"single_ab"}},
+		{"unknown zones", gtfs.FareLeg{FromAreaID: "B", ToAreaID: "C"}, []string{"single_ab"}},
+		{"by priority", gtfs.FareLeg{NetworkID: "bus", FromAreaID: "A", ToAreaID: "A"}, []string{"short"}},
+		{"unknown network", gtfs.FareLeg{NetworkID: "ferry", FromAreaID: "A", ToAreaID: "B"}, []string{"single_ab"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := gtfs.MatchFareLegRules(db, tt.leg)
+			if err != nil {
+				t.Fatalf("MatchFareLegRules() error = %v", err)
+			}
+			var got []string
+			for _, r := range rules {
+				got = append(got, r.FareProductID)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && got[0] != tt.want[0]) {
+				t.Errorf("MatchFareLegRules() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// ErrNoFeedMeta is returned if no metadata has been recorded for a feed.
+var ErrNoFeedMeta = errors.New("no feed metadata")
+
+// feedInfoFile is the name of the (optional) GTFS file holding feed info.
+const feedInfoFile = "feed_info.txt"
+
+// FeedMeta model (the origin and the terms of use of a feed, allowing to trace
+// compliance when redistributing it).
+type FeedMeta struct {
+	ID            uint      `gorm:"primaryKey" csv:"-"`
+	SourceURL     string    `csv:"-"`
+	License       string    `csv:"-"`
+	Terms         string    `csv:"-"`
+	RetrievedAt   time.Time `csv:"-"`
+	PublisherName string    `csv:"feed_publisher_name"`
+	PublisherURL  string    `csv:"feed_publisher_url"`
+	Version       string    `csv:"feed_version"`
+}
+
+// TableName returns the name of the table holding FeedMeta items.
+func (FeedMeta) TableName() string {
+	return "feed_meta"
+}
+
+// String returns a human-readable representation of FeedMeta.
+func (fm FeedMeta) String() string {
+	var s string
+	if !fm.RetrievedAt.IsZero() {
+		s = fmt.Sprintf("Retrieved: %s\n", fm.RetrievedAt.Format(time.RFC3339))
+	}
+	for _, f := range []struct {
+		name  string
+		value string
+	}{
+		{"Source", fm.SourceURL},
+		{"License", fm.License},
+		{"Terms", fm.Terms},
+		{"Publisher", fm.PublisherName},
+		{"Publisher URL", fm.PublisherURL},
+		{"Version", fm.Version},
+	} {
+		if f.value != "" {
+			s += fmt.Sprintf("%s: %s\n", f.name, f.value)
+		}
+	}
+	return s
+}
+
+// RecordFeedMeta records the given metadata of the feed imported from the
+// directory (or zip archive, see ImportZip) gtfsBase (replacing any previously recorded metadata). Publisher
+// and version not given are taken from the feed_info.txt file (if present) and
+// if RetrievedAt is not given, the current time is recorded.
+func RecordFeedMeta(db *gorm.DB, gtfsBase string, meta FeedMeta) error {
+
+	// complement the metadata by the feed info
+	fsys, dir, closeFeed, err := openFeed(gtfsBase)
+	if err != nil {
+		return err
+	}
+	defer closeFeed()
+	info, err := readFeedInfo(fsys, path.Join(dir, feedInfoFile), path.Join(gtfsBase, dir, feedInfoFile))
+	if err != nil {
+		return err
+	}
+	if meta.PublisherName == "" {
+		meta.PublisherName = info.PublisherName
+	}
+	if meta.PublisherURL == "" {
+		meta.PublisherURL = info.PublisherURL
+	}
+	if meta.Version == "" {
+		meta.Version = info.Version
+	}
+	if meta.RetrievedAt.IsZero() {
+		meta.RetrievedAt = time.Now()
+	}
+
+	// there is a single record of metadata only
+	meta.ID = 1
+	if tx := db.Save(&meta); tx.Error != nil {
+		return fmt.Errorf("failed to record feed metadata: %w", tx.Error)
+	}
+	return nil
+}
+
+// GetFeedMeta returns the metadata recorded for the feed in db (see
+// RecordFeedMeta) or ErrNoFeedMeta, if none was recorded.
+func GetFeedMeta(db *gorm.DB) (*FeedMeta, error) {
+	if !db.Migrator().HasTable(&FeedMeta{}) {
+		return nil, ErrNoFeedMeta
+	}
+	var meta FeedMeta
+	tx := db.Limit(1).Find(&meta)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, ErrNoFeedMeta
+	}
+	return &meta, nil
+}
+
+// readFeedInfo reads the (first row of the) feed info file named name within
+// fsys (reported as csvPath). If the file is not present, empty feed info is
+// returned.
+func readFeedInfo(fsys fs.FS, name, csvPath string) (*FeedMeta, error) {
+	file, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &FeedMeta{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	var infos []*FeedMeta
+	if err = gocsv.Unmarshal(file, &infos); err != nil {
+		return nil, fmt.Errorf("failed to parse '%s': %w", csvPath, err)
+	}
+	if len(infos) == 0 {
+		return &FeedMeta{}, nil
+	}
+	return infos[0], nil
+}
@@ -0,0 +1,49 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordFeedMeta(t *testing.T) {
+	db := newFixtureDB(t)
+
+	if _, err := gtfs.GetFeedMeta(db); !errors.Is(err, gtfs.ErrNoFeedMeta) {
+		t.Errorf("GetFeedMeta() error = %v, want %v", err, gtfs.ErrNoFeedMeta)
+	}
+
+	// publisher and version are taken from feed_info.txt
+	retrievedAt := time.Date(2022, 2, 24, 12, 0, 0, 0, time.UTC)
+	meta := gtfs.FeedMeta{SourceURL: "https://example.com/GTFS.zip", License: "CC-BY-4.0", RetrievedAt: retrievedAt}
+	if err := gtfs.RecordFeedMeta(db, fixtureFeed, meta); err != nil {
+		t.Fatalf("RecordFeedMeta() error = %v", err)
+	}
+
+	// recording again replaces the metadata
+	meta.Version = "custom"
+	if err := gtfs.RecordFeedMeta(db, fixtureFeed, meta); err != nil {
+		t.Fatalf("RecordFeedMeta() error = %v", err)
+	}
+
+	got, err := gtfs.GetFeedMeta(db)
+	if err != nil {
+		t.Fatalf("GetFeedMeta() error = %v", err)
+	}
+	if got.License != "CC-BY-4.0" || got.PublisherName != "VBB Verkehrsverbund Berlin-Brandenburg GmbH" || got.Version != "custom" || !got.RetrievedAt.Equal(retrievedAt) {
+		t.Errorf("GetFeedMeta() = %+v", got)
+	}
+
+	fs, err := gtfs.Stats(db)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	s := fs.String()
+	for _, want := range []string{"License: CC-BY-4.0\n", "Retrieved: 2022-02-24T12:00:00Z\n"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("String() = %q, want it to contain %q", s, want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package gtfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// Fingerprint returns a stable hash (hex encoded SHA-256) over the content of
+// the feed within the DB. The content is normalized as on Export (i.e. rows
+// ordered by their natural keys, excluding auto-increment IDs), such that the
+// same feed results in the same fingerprint, regardless of how (or in which
+// order) it was imported. Use it e.g. as cache key or to detect changes
+// between imports.
+func Fingerprint(db *gorm.DB) (string, error) {
+	itemTypes := make([]ItemType, 0, len(itemModels))
+	for itemType := range itemModels {
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Slice(itemTypes, func(i, j int) bool {
+		return itemTypes[i] < itemTypes[j]
+	})
+
+	hash := sha256.New()
+	for _, itemType := range itemTypes {
+		if _, err := fmt.Fprintf(hash, "%s\n", itemFiles[itemType]); err != nil {
+			return "", err
+		}
+		if err := exportItems(db, hash, itemModels[itemType], exportOrder(itemType), nil, nil, false); err != nil {
+			return "", fmt.Errorf("failed to hash %s: %w", itemType, err)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
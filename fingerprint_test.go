@@ -0,0 +1,42 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	want, err := gtfs.Fingerprint(newFixtureDB(t))
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	if len(want) != 64 {
+		t.Fatalf("Fingerprint() = %q, want a SHA-256", want)
+	}
+
+	// re-inserting items (with new IDs) doesn't change the fingerprint
+	db := newFixtureDB(t)
+	var stopTimes []gtfs.StopTime
+	db.Order("stop_seq DESC").Find(&stopTimes, "trip_id = ?", "T1")
+	db.Delete(&gtfs.StopTime{}, "trip_id = ?", "T1")
+	for _, st := range stopTimes {
+		st.ID = 0
+		db.Create(&st)
+	}
+	got, err := gtfs.Fingerprint(db)
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Fingerprint() after re-inserting = %q, want %q", got, want)
+	}
+
+	// changing content does
+	db.Model(&gtfs.Stop{}).Where("id = ?", "S1").Update("name", "Wannsee")
+	if got, err = gtfs.Fingerprint(db); err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	if got == want {
+		t.Errorf("Fingerprint() after changing a stop = %q, want a different fingerprint", got)
+	}
+}
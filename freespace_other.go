@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package gtfs
+
+// freeSpace returns -1, as the free space is unknown on this platform.
+func freeSpace(string) int64 {
+	return -1
+}
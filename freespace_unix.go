@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd
+
+package gtfs
+
+import "syscall"
+
+// freeSpace returns the space (in bytes) available to unprivileged users on
+// the file system holding dir (-1, if unknown).
+func freeSpace(dir string) int64 {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return -1
+	}
+	return int64(st.Bavail) * int64(st.Bsize)
+}
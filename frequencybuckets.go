@@ -0,0 +1,295 @@
+package gtfs
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrNoFrequencyBuckets is returned (wrapped) by RefreshFrequencyBuckets, if
+// the DB holds no frequency buckets (see BuildFrequencyBuckets).
+var ErrNoFrequencyBuckets = errors.New("no frequency buckets")
+
+// FrequencyBucketDate model, i.e. a service day covered by the frequency
+// buckets (see BuildFrequencyBuckets), along with the width of the buckets and
+// a digest of the services running on that day (telling the days affected by
+// changes of calendars and calendar dates, see RefreshFrequencyBuckets).
+type FrequencyBucketDate struct {
+	Date     string `gorm:"primaryKey"` // see DateLayout
+	Width    int    // the width of the buckets (in seconds)
+	Services string // the digest of the IDs of the services running
+}
+
+// RouteFrequencyBucket model, i.e. the number of trips of a route starting
+// within a time bucket of a service day (see BuildFrequencyBuckets).
+type RouteFrequencyBucket struct {
+	Date      string `gorm:"primaryKey"` // see DateLayout
+	RouteID   string `gorm:"primaryKey"`
+	StartTime int    `gorm:"primaryKey"` // seconds since "noon minus 12h" (inclusive, see ServiceDay)
+	EndTime   int    // seconds since "noon minus 12h" (exclusive)
+	Trips     int64
+}
+
+// StopFrequencyBucket model, i.e. the number of trips departing from a stop
+// within a time bucket of a service day (see BuildFrequencyBuckets).
+type StopFrequencyBucket struct {
+	Date      string `gorm:"primaryKey"` // see DateLayout
+	StopID    string `gorm:"primaryKey"`
+	StartTime int    `gorm:"primaryKey"` // seconds since "noon minus 12h" (inclusive, see ServiceDay)
+	EndTime   int    // seconds since "noon minus 12h" (exclusive)
+	Trips     int64
+}
+
+// statement to count the trips per service, route and first departure
+const routeBucketsStmt = `
+SELECT
+	trips.service_id,
+	trips.route_id AS id,
+	starts.departure,
+	COUNT(*) AS trips
+FROM (
+	SELECT
+		trip_id,
+		MIN(departure) AS departure
+	FROM
+		stop_times
+	GROUP BY
+		trip_id) AS starts
+	JOIN trips ON trips.id = starts.trip_id
+WHERE
+	trips.service_id IN ?
+GROUP BY
+	trips.service_id,
+	trips.route_id,
+	starts.departure;
+`
+
+// statement to count the trips per service, stop and departure
+const stopBucketsStmt = `
+SELECT
+	trips.service_id,
+	stop_times.stop_id AS id,
+	stop_times.departure,
+	COUNT(*) AS trips
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+WHERE
+	trips.service_id IN ?
+GROUP BY
+	trips.service_id,
+	stop_times.stop_id,
+	stop_times.departure;
+`
+
+// BuildFrequencyBuckets materializes the number of trips per route (counting
+// trips by their first departure) and per stop (counting departures) within
+// time buckets of the given width (e.g. 15 minutes) for each day of the
+// service period (see ServicePeriod), i.e. the tables route_frequency_buckets
+// and stop_frequency_buckets, for dashboards to chart service frequency
+// without expanding calendars. Buckets without trips are omitted and the
+// buckets previously built are replaced. Trips defined by frequencies are
+// counted once. As the buckets derive from trips and stop times, they must be
+// rebuilt after (re-)importing those, whereas changes of calendars and
+// calendar dates are applied incrementally by RefreshFrequencyBuckets.
+func BuildFrequencyBuckets(db *gorm.DB, bucket time.Duration) error {
+	width := int(bucket / time.Second)
+	if width <= 0 {
+		return fmt.Errorf("invalid bucket width %s (must be at least 1s)", bucket)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range []string{"frequency_bucket_dates", "route_frequency_buckets", "stop_frequency_buckets"} {
+			if err := tx.Exec("DELETE FROM " + table).Error; err != nil {
+				return err
+			}
+		}
+		_, err := refreshFrequencyBuckets(tx, width)
+		return err
+	})
+}
+
+// RefreshFrequencyBuckets rebuilds the frequency buckets (see
+// BuildFrequencyBuckets) of the days whose services changed since the buckets
+// were built (e.g. by updating calendar dates), i.e. of the days the services
+// running on differ, the days added to the service period and the days
+// removed from it. It returns the days (see DateLayout) rebuilt or removed.
+func RefreshFrequencyBuckets(db *gorm.DB) ([]string, error) {
+	var width sql.NullInt64
+	if err := db.Model(&FrequencyBucketDate{}).Select("MAX(width)").Row().Scan(&width); err != nil {
+		return nil, err
+	}
+	if !width.Valid {
+		return nil, fmt.Errorf("failed to refresh frequency buckets: %w", ErrNoFrequencyBuckets)
+	}
+	var dates []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		dates, err = refreshFrequencyBuckets(tx, int(width.Int64))
+		return err
+	})
+	return dates, err
+}
+
+// frequencyBucketKey identifies a bucket of a route or a stop.
+type frequencyBucketKey struct {
+	id    string
+	start int
+}
+
+// serviceDepartures is the number of trips of a service departing at a time
+// (from a stop or the first stop of a route).
+type serviceDepartures struct {
+	ServiceID string
+	ID        string
+	Departure int
+	Trips     int64
+}
+
+// refreshFrequencyBuckets rebuilds the frequency buckets (of the given width)
+// of the days whose services changed (see RefreshFrequencyBuckets) and returns
+// those days (ordered).
+func refreshFrequencyBuckets(db *gorm.DB, width int) ([]string, error) {
+	var built []FrequencyBucketDate
+	if err := db.Find(&built).Error; err != nil {
+		return nil, err
+	}
+	digests := make(map[string]string, len(built))
+	for _, d := range built {
+		if d.Width == width {
+			digests[d.Date] = d.Services
+		}
+	}
+
+	// the days of the service period whose services changed
+	first, last, err := ServicePeriod(db, nil)
+	if errors.Is(err, ErrNoServicePeriod) {
+		last = first.AddDate(0, 0, -1)
+	} else if err != nil {
+		return nil, err
+	}
+	inPeriod := map[string]bool{}
+	changed := map[string][]string{}
+	running := map[string]bool{}
+	var dates []string
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		services, err := ActiveServices(db, day)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(services)
+		date := day.Format(DateLayout)
+		inPeriod[date] = true
+		if digest, ok := digests[date]; ok && digest == servicesDigest(services) {
+			continue
+		}
+		changed[date] = services
+		dates = append(dates, date)
+		for _, serviceID := range services {
+			running[serviceID] = true
+		}
+	}
+	for _, d := range built {
+		if !inPeriod[d.Date] {
+			dates = append(dates, d.Date)
+		}
+	}
+	sort.Strings(dates)
+	if len(dates) == 0 {
+		return nil, nil
+	}
+
+	// remove the buckets of these days
+	for _, table := range []string{"frequency_bucket_dates", "route_frequency_buckets", "stop_frequency_buckets"} {
+		if err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE date IN ?", table), dates).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	// the trips per service (running on any of these days) and bucket
+	serviceIDs := make([]string, 0, len(running))
+	for serviceID := range running {
+		serviceIDs = append(serviceIDs, serviceID)
+	}
+	routeBuckets, err := serviceBuckets(db, routeBucketsStmt, serviceIDs, width)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the trips of routes: %w", err)
+	}
+	stopBuckets, err := serviceBuckets(db, stopBucketsStmt, serviceIDs, width)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count the trips of stops: %w", err)
+	}
+
+	// sum up the buckets of the services running on each day
+	for _, date := range dates {
+		services, ok := changed[date]
+		if !ok {
+			continue
+		}
+		var routes []*RouteFrequencyBucket
+		for key, trips := range sumBuckets(routeBuckets, services) {
+			routes = append(routes, &RouteFrequencyBucket{Date: date, RouteID: key.id, StartTime: key.start, EndTime: key.start + width, Trips: trips})
+		}
+		var stops []*StopFrequencyBucket
+		for key, trips := range sumBuckets(stopBuckets, services) {
+			stops = append(stops, &StopFrequencyBucket{Date: date, StopID: key.id, StartTime: key.start, EndTime: key.start + width, Trips: trips})
+		}
+		if len(routes) > 0 {
+			if err := db.CreateInBatches(routes, 1000).Error; err != nil {
+				return nil, err
+			}
+		}
+		if len(stops) > 0 {
+			if err := db.CreateInBatches(stops, 1000).Error; err != nil {
+				return nil, err
+			}
+		}
+		if err := db.Create(&FrequencyBucketDate{Date: date, Width: width, Services: servicesDigest(services)}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return dates, nil
+}
+
+// serviceBuckets returns the number of trips per service and bucket (of the
+// given width) as counted by the given statement (see routeBucketsStmt),
+// considering the given services only.
+func serviceBuckets(db *gorm.DB, stmt string, serviceIDs []string, width int) (map[string]map[frequencyBucketKey]int64, error) {
+	buckets := map[string]map[frequencyBucketKey]int64{}
+	if len(serviceIDs) == 0 {
+		return buckets, nil
+	}
+	var departures []serviceDepartures
+	if err := db.Raw(stmt, serviceIDs).Scan(&departures).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range departures {
+		if buckets[d.ServiceID] == nil {
+			buckets[d.ServiceID] = map[frequencyBucketKey]int64{}
+		}
+		buckets[d.ServiceID][frequencyBucketKey{d.ID, d.Departure - d.Departure%width}] += d.Trips
+	}
+	return buckets, nil
+}
+
+// sumBuckets sums up the buckets of the given services.
+func sumBuckets(buckets map[string]map[frequencyBucketKey]int64, serviceIDs []string) map[frequencyBucketKey]int64 {
+	sum := map[frequencyBucketKey]int64{}
+	for _, serviceID := range serviceIDs {
+		for key, trips := range buckets[serviceID] {
+			sum[key] += trips
+		}
+	}
+	return sum
+}
+
+// servicesDigest returns a digest of the given (ordered) service IDs.
+func servicesDigest(serviceIDs []string) string {
+	h := sha256.Sum256([]byte(strings.Join(serviceIDs, "\n")))
+	return hex.EncodeToString(h[:])
+}
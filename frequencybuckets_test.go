@@ -0,0 +1,108 @@
+package gtfs_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// frequencyBuckets returns the buckets (e.g. "S1 28800-32400: 2") of the
+// routes or stops (see table) on a date.
+func frequencyBuckets(t *testing.T, db *gorm.DB, table, column, date string) []string {
+	t.Helper()
+	var rows []struct {
+		ID        string
+		StartTime int
+		EndTime   int
+		Trips     int64
+	}
+	stmt := fmt.Sprintf("SELECT %s AS id, start_time, end_time, trips FROM %s WHERE date = ? ORDER BY id, start_time", column, table)
+	if err := db.Raw(stmt, date).Scan(&rows).Error; err != nil {
+		t.Fatalf("failed to select %s: %v", table, err)
+	}
+	var buckets []string
+	for _, r := range rows {
+		buckets = append(buckets, fmt.Sprintf("%s %d-%d: %d", r.ID, r.StartTime, r.EndTime, r.Trips))
+	}
+	return buckets
+}
+
+func TestBuildFrequencyBuckets(t *testing.T) {
+	db := newFixtureDB(t)
+	if err := gtfs.BuildFrequencyBuckets(db, time.Hour); err != nil {
+		t.Fatalf("BuildFrequencyBuckets() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		date       string
+		wantRoutes []string
+		wantStops  []string
+	}{
+		{"weekday", "20221221", []string{"R1 28800-32400: 2", "R1 32400-36000: 1"}, []string{"S1 28800-32400: 2", "S1 32400-36000: 1", "S2 28800-32400: 2", "S2 32400-36000: 1", "S3 28800-32400: 2", "S3 32400-36000: 1", "S4 28800-32400: 2", "S4 32400-36000: 1"}},
+		{"holiday", "20221226", []string{"R2 36000-39600: 1"}, []string{"B1 36000-39600: 1", "B2 36000-39600: 1", "B3 36000-39600: 1"}},
+		{"outside service period", "20230101", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := frequencyBuckets(t, db, "route_frequency_buckets", "route_id", tt.date); fmt.Sprint(got) != fmt.Sprint(tt.wantRoutes) {
+				t.Errorf("route buckets = %q, want %q", got, tt.wantRoutes)
+			}
+			if got := frequencyBuckets(t, db, "stop_frequency_buckets", "stop_id", tt.date); fmt.Sprint(got) != fmt.Sprint(tt.wantStops) {
+				t.Errorf("stop buckets = %q, want %q", got, tt.wantStops)
+			}
+		})
+	}
+
+	if err := gtfs.BuildFrequencyBuckets(db, time.Millisecond); err == nil {
+		t.Errorf("BuildFrequencyBuckets(1ms) error = nil, want an error")
+	}
+}
+
+func TestRefreshFrequencyBuckets(t *testing.T) {
+	db := newFixtureDB(t)
+	if _, err := gtfs.RefreshFrequencyBuckets(db); !errors.Is(err, gtfs.ErrNoFrequencyBuckets) {
+		t.Fatalf("RefreshFrequencyBuckets() error = %v, want %v", err, gtfs.ErrNoFrequencyBuckets)
+	}
+	if err := gtfs.BuildFrequencyBuckets(db, 30*time.Minute); err != nil {
+		t.Fatalf("BuildFrequencyBuckets() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		change     func(tx *gorm.DB) error
+		wantDates  []string
+		wantRoutes []string // the buckets of routes on 20221227
+	}{
+		{"unchanged", func(tx *gorm.DB) error { return nil }, nil, []string{"R1 28800-30600: 2", "R1 32400-34200: 1"}},
+		{"removed service", func(tx *gorm.DB) error {
+			return tx.Create(&gtfs.CalendarDate{ServiceID: "WD", Date: "20221227", ExceptionType: 2}).Error
+		}, []string{"20221227"}, nil},
+		{"added service", func(tx *gorm.DB) error {
+			return tx.Model(&gtfs.CalendarDate{}).Where("date = ?", "20221227").Updates(map[string]interface{}{"service_id": "WE", "exception_type": 1}).Error
+		}, []string{"20221227"}, []string{"R1 28800-30600: 2", "R1 32400-34200: 1", "R2 36000-37800: 1"}},
+		{"extended period", func(tx *gorm.DB) error {
+			return tx.Create(&gtfs.CalendarDate{ServiceID: "WD", Date: "20230102", ExceptionType: 1}).Error
+		}, []string{"20230101", "20230102"}, []string{"R1 28800-30600: 2", "R1 32400-34200: 1", "R2 36000-37800: 1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.change(db); err != nil {
+				t.Fatal(err)
+			}
+			dates, err := gtfs.RefreshFrequencyBuckets(db)
+			if err != nil {
+				t.Fatalf("RefreshFrequencyBuckets() error = %v", err)
+			}
+			if fmt.Sprint(dates) != fmt.Sprint(tt.wantDates) {
+				t.Errorf("RefreshFrequencyBuckets() = %v, want %v", dates, tt.wantDates)
+			}
+			if got := frequencyBuckets(t, db, "route_frequency_buckets", "route_id", "20221227"); fmt.Sprint(got) != fmt.Sprint(tt.wantRoutes) {
+				t.Errorf("route buckets = %q, want %q", got, tt.wantRoutes)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package gtfs
+
+import (
+	"errors"
+	"gorm.io/gorm"
+)
+
+// statement to select the representative trips of a route per direction, i.e.
+// the trips serving the most stops (see representativeTripStmt), ordered by
+// direction and preference
+const directionTripsStmt = `
+SELECT
+	trips.id,
+	trips.direction_id,
+	trips.shape_id
+FROM
+	trips
+	JOIN stop_times ON stop_times.trip_id = trips.id
+WHERE
+	trips.route_id = ?
+GROUP BY
+	trips.id,
+	trips.shape_id,
+	trips.direction_id
+ORDER BY
+	trips.direction_id,
+	COUNT(*) DESC,
+	trips.id;
+`
+
+// FeatureCollection is a GeoJSON feature collection.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature (with a point or a line string as geometry).
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   Geometry               `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON geometry. Coordinates are a position (i.e.
+// [longitude, latitude]) for points and a slice of positions for line strings.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// RouteGeoJSON returns the map of a route as GeoJSON, i.e. per direction the
+// shape (if any) and the stops (in sequence) of the representative trip of
+// the direction (the trip serving the most stops). Shapes are line strings
+// with the properties kind ("shape"), direction_id, trip_id and shape_id.
+// Stops are points with the properties kind ("stop"), direction_id, stop_id,
+// name and sequence (the position of the stop, starting at 1). If there is no
+// such route, gorm.ErrRecordNotFound is returned.
+func RouteGeoJSON(db *gorm.DB, routeID string) (*FeatureCollection, error) {
+	if err := findRoute(db, routeID); err != nil {
+		return nil, err
+	}
+	var trips []Trip
+	if tx := db.Raw(directionTripsStmt, routeID).Scan(&trips); tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	fc := &FeatureCollection{Type: "FeatureCollection", Features: []Feature{}}
+	for i, trip := range trips {
+		if i > 0 && trip.DirectionID == trips[i-1].DirectionID {
+			continue
+		}
+
+		// the shape of the direction
+		if trip.ShapeID != "" {
+			points, err := ShapeGeometry(db, trip.ShapeID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, err
+			}
+			if len(points) > 0 {
+				coordinates := make([][2]float64, len(points))
+				for j, p := range points {
+					coordinates[j] = [2]float64{p.PtLon, p.PtLat}
+				}
+				fc.Features = append(fc.Features, Feature{
+					Type:     "Feature",
+					Geometry: Geometry{Type: "LineString", Coordinates: coordinates},
+					Properties: map[string]interface{}{
+						"kind":         "shape",
+						"direction_id": trip.DirectionID,
+						"trip_id":      trip.ID,
+						"shape_id":     trip.ShapeID,
+					},
+				})
+			}
+		}
+
+		// the stops of the direction
+		var stops []Stop
+		if tx := db.Raw(tripStopsStmt, trip.ID).Scan(&stops); tx.Error != nil {
+			return nil, tx.Error
+		}
+		if err := ApplyStopOverrides(db, stops); err != nil {
+			return nil, err
+		}
+		for j, s := range stops {
+			fc.Features = append(fc.Features, Feature{
+				Type:     "Feature",
+				Geometry: Geometry{Type: "Point", Coordinates: [2]float64{s.Longitude, s.Latitude}},
+				Properties: map[string]interface{}{
+					"kind":         "stop",
+					"direction_id": trip.DirectionID,
+					"stop_id":      s.ID,
+					"name":         s.Name,
+					"sequence":     j + 1,
+				},
+			})
+		}
+	}
+	return fc, nil
+}
@@ -0,0 +1,54 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestRouteGeoJSON(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// R1 runs in both directions (along SH1 and SH2), serving 4 stops each
+	fc, err := gtfs.RouteGeoJSON(db, "R1")
+	if err != nil {
+		t.Fatalf("RouteGeoJSON() error = %v", err)
+	}
+	if fc.Type != "FeatureCollection" || len(fc.Features) != 10 {
+		t.Fatalf("RouteGeoJSON() = %v, want 10 features", fc)
+	}
+	tests := []struct {
+		i            int
+		wantGeometry string
+		wantKind     string
+		wantDir      string
+		wantID       string
+	}{
+		{0, "LineString", "shape", "0", "SH1"},
+		{1, "Point", "stop", "0", "S1"},
+		{4, "Point", "stop", "0", "S4"},
+		{5, "LineString", "shape", "1", "SH2"},
+		{6, "Point", "stop", "1", "S4"},
+	}
+	for _, tt := range tests {
+		f := fc.Features[tt.i]
+		id := f.Properties["stop_id"]
+		if tt.wantKind == "shape" {
+			id = f.Properties["shape_id"]
+		}
+		if f.Geometry.Type != tt.wantGeometry || f.Properties["kind"] != tt.wantKind || f.Properties["direction_id"] != tt.wantDir || id != tt.wantID {
+			t.Errorf("RouteGeoJSON() feature %d = %v, want %s %s in direction %s", tt.i, f, tt.wantKind, tt.wantID, tt.wantDir)
+		}
+	}
+	if seq := fc.Features[4].Properties["sequence"]; seq != 4 {
+		t.Errorf("RouteGeoJSON() sequence of S4 = %v, want 4", seq)
+	}
+	if c := fc.Features[1].Geometry.Coordinates.([2]float64); c != [2]float64{13.1790, 52.4210} {
+		t.Errorf("RouteGeoJSON() coordinates of S1 = %v, want [13.179 52.421]", c)
+	}
+
+	if _, err = gtfs.RouteGeoJSON(db, "R9"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("RouteGeoJSON() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
@@ -69,12 +69,14 @@ func (dt DateTime) Value() (driver.Value, error) {
 
 // Agency model.
 type Agency struct {
-	ID   string `csv:"agency_id"`
-	Name string `csv:"agency_name"`
-	URL  string `csv:"agency_url"`
-	//Timezone string `csv:"agency_timezone"`
-	//Language string `csv:"agency_lang"`
-	//Phone    string `csv:"agency_phone"`
+	ID       string `csv:"agency_id"`
+	Name     string `csv:"agency_name"`
+	URL      string `csv:"agency_url"`
+	Timezone string `csv:"agency_timezone"`
+	Lang     string `csv:"agency_lang"`
+	Phone    string `csv:"agency_phone"`
+	FareURL  string `csv:"agency_fare_url"`
+	Email    string `csv:"agency_email"`
 }
 
 // Route model.
@@ -87,20 +89,23 @@ type Route struct {
 	Type      int    `csv:"route_type"`
 	//Desc      string `csv:"route_url"`
 	//URL       string `csv:"route_desc"`
-	//Color     string `csv:"route_color"`
-	//TextColor string `csv:"route_text_color"`
+	Color     string `csv:"route_color"`
+	TextColor string `csv:"route_text_color"`
 }
 
 // Trip model.
 type Trip struct {
-	ID          string `csv:"trip_id"`
-	Name        string `csv:"trip_short_name"`
-	RouteID     string `csv:"route_id"`
-	Route       Route
-	ServiceID   string `csv:"service_id"`
-	DirectionID string `csv:"direction_id"`
-	ShapeID     string `csv:"shape_id"`
-	//ServiceID   string `csv:"service_id"`
+	ID                   string `csv:"trip_id"`
+	Name                 string `csv:"trip_short_name"`
+	Headsign             string `csv:"trip_headsign"`
+	RouteID              string `csv:"route_id"`
+	Route                Route
+	ServiceID            string `csv:"service_id"`
+	DirectionID          string `csv:"direction_id"`
+	ShapeID              string `csv:"shape_id"`
+	BlockID              string `csv:"block_id"`
+	WheelchairAccessible int    `csv:"wheelchair_accessible"` // 1 (yes), 2 (no) or 0 (unknown)
+	BikesAllowed         int    `csv:"bikes_allowed"`         // 1 (yes), 2 (no) or 0 (unknown)
 }
 
 // StopTime model.
@@ -119,29 +124,30 @@ type StopTime struct {
 
 // Stop model.
 type Stop struct {
-	ID        string  `csv:"stop_id"`
-	Name      string  `csv:"stop_name"`
-	Latitude  float64 `csv:"stop_lat"`
-	Longitude float64 `csv:"stop_lon"`
+	ID                 string  `csv:"stop_id"`
+	Name               string  `csv:"stop_name"`
+	Latitude           float64 `csv:"stop_lat"`
+	Longitude          float64 `csv:"stop_lon"`
+	WheelchairBoarding int     `csv:"wheelchair_boarding"` // 1 (possible), 2 (not possible) or 0 (unknown)
+	LocationType       int     `csv:"location_type"`       // 0 (stop or platform), 1 (station), 2 (entrance or exit), 3 (generic node) or 4 (boarding area)
+	ParentStation      string  `csv:"parent_station"`      // the station of platforms, entrances and generic nodes, the platform of boarding areas
 	// Code        string  `csv:"stop_code"`
 	// Description string  `csv:"stop_desc"`
-	// Type        string  `csv:"location_type"`
-	// Parent      string  `csv:"parent_station"`
 }
 
 // Shape model.
 type Shape struct {
 	ID         uint    `gorm:"primaryKey,autoIncrement"`
-	ShapeID    string  `csv:"shape_id"`
+	ShapeID    string  `gorm:"size:191;uniqueIndex:idx_shapes_shape_pt" csv:"shape_id"`
 	PtLat      float64 `csv:"shape_pt_lat"`
 	PtLon      float64 `csv:"shape_pt_lon"`
-	PtSequence int     `csv:"shape_pt_sequence"`
+	PtSequence int     `gorm:"uniqueIndex:idx_shapes_shape_pt" csv:"shape_pt_sequence"`
 }
 
 // Calendar model.
 type Calendar struct {
 	ID        uint   `gorm:"primaryKey,autoIncrement"`
-	ServiceID string `csv:"service_id"`
+	ServiceID string `gorm:"size:191;uniqueIndex" csv:"service_id"`
 	Monday    int    `csv:"monday"`
 	Tuesday   int    `csv:"tuesday"`
 	Wednesday int    `csv:"wednesday"`
@@ -156,11 +162,21 @@ type Calendar struct {
 // CalendarDate model.
 type CalendarDate struct {
 	ID            uint   `gorm:"primaryKey,autoIncrement"`
-	ServiceID     string `csv:"service_id"`
-	Date          string `csv:"date"`
+	ServiceID     string `gorm:"size:191;uniqueIndex:idx_calendar_dates_service_date" csv:"service_id"`
+	Date          string `gorm:"size:191;uniqueIndex:idx_calendar_dates_service_date" csv:"date"`
 	ExceptionType int    `csv:"exception_type"`
 }
 
+// Frequency model.
+type Frequency struct {
+	ID          uint     `gorm:"primaryKey,autoIncrement"`
+	TripID      string   `csv:"trip_id"`
+	StartTime   DateTime `csv:"start_time"`
+	EndTime     DateTime `csv:"end_time"`
+	HeadwaySecs int      `csv:"headway_secs"`
+	ExactTimes  int      `csv:"exact_times"`
+}
+
 // ItemType enumerates different item types.
 type ItemType uint32
 
@@ -189,6 +205,9 @@ const (
 
 	// CalendarDates the item type for shape items.
 	CalendarDates
+
+	// Frequencies the item type for frequency items.
+	Frequencies
 )
 
 var txItemType = map[ItemType]string{
@@ -200,6 +219,33 @@ var txItemType = map[ItemType]string{
 	Shapes:        "Shapes",
 	Calendars:     "Calendars",
 	CalendarDates: "Calendar Dates",
+	Frequencies:   "Frequencies",
+}
+
+// itemModels maps item types to (prototypes of) their models.
+var itemModels = map[ItemType]interface{}{
+	Agencies:      &Agency{},
+	Routes:        &Route{},
+	Trips:         &Trip{},
+	Stops:         &Stop{},
+	StopTimes:     &StopTime{},
+	Shapes:        &Shape{},
+	Calendars:     &Calendar{},
+	CalendarDates: &CalendarDate{},
+	Frequencies:   &Frequency{},
+}
+
+// itemFiles maps item types to the names of their GTFS files.
+var itemFiles = map[ItemType]string{
+	Agencies:      "agency.txt",
+	Routes:        "routes.txt",
+	Trips:         "trips.txt",
+	Stops:         "stops.txt",
+	StopTimes:     "stop_times.txt",
+	Shapes:        "shapes.txt",
+	Calendars:     "calendar.txt",
+	CalendarDates: "calendar_dates.txt",
+	Frequencies:   "frequencies.txt",
 }
 
 // String returns a human-readable representation of ItemType.
@@ -210,16 +256,30 @@ func (it ItemType) String() string {
 	return fmt.Sprintf("Unknown Status (%d)", uint32(it))
 }
 
-// Migrate ensure the given DB matches our models.
-func Migrate(db *gorm.DB) error {
-	return db.AutoMigrate(
-		&Agency{},
-		&Route{},
-		&Trip{},
-		&StopTime{},
-		&Stop{},
-		&Shape{},
-		&Calendar{},
-		&CalendarDate{},
-	)
+// isSQLite returns true, if db is an SQLite DB.
+func isSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+// isMySQL returns true, if db is a MySQL (or MariaDB) DB.
+func isMySQL(db *gorm.DB) bool {
+	return db.Dialector.Name() == "mysql"
+}
+
+// castText returns the SQL expression casting expr to text (MySQL lacks a TEXT
+// type to cast to).
+func castText(db *gorm.DB, expr string) string {
+	if isMySQL(db) {
+		return "CAST(" + expr + " AS CHAR)"
+	}
+	return "CAST(" + expr + " AS TEXT)"
+}
+
+// concat returns the SQL expression concatenating the given expressions (MySQL
+// takes || for a logical OR).
+func concat(db *gorm.DB, exprs ...string) string {
+	if isMySQL(db) {
+		return "CONCAT(" + strings.Join(exprs, ", ") + ")"
+	}
+	return strings.Join(exprs, " || ")
 }
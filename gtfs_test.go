@@ -2,9 +2,76 @@ package gtfs_test
 
 import (
 	"github.com/heimdalr/gtfs"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 	"testing"
 )
 
+// fixtureFeed is the path of a small GTFS feed used for testing.
+const fixtureFeed = "_fixture/feed"
+
+// newTestDB returns a migrated, in-memory DB, that is closed when the test ends.
+func newTestDB(t testing.TB) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open DB: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get DB: %v", err)
+	}
+
+	// each connection to an in-memory DB gets its own DB, thus use a single one
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	if err = gtfs.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate DB: %v", err)
+	}
+	return db
+}
+
+// newFixtureDB returns an in-memory DB (see newTestDB) holding the fixture feed.
+func newFixtureDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := newTestDB(t)
+	gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+		OnProgress: func(e gtfs.ImportEvent) {
+			if e.Result.Error != nil {
+				t.Fatalf("failed to import fixture: %v", e.Result.Error)
+			}
+		},
+	})
+	return db
+}
+
+// copyTrip copies the trip (along with its stop times) with the ID from to a
+// trip with the ID to, shifting all times by offset seconds.
+func copyTrip(t *testing.T, db *gorm.DB, from, to string, offset int32) {
+	t.Helper()
+	var trip gtfs.Trip
+	if tx := db.First(&trip, "id = ?", from); tx.Error != nil {
+		t.Fatalf("failed to find trip '%s': %v", from, tx.Error)
+	}
+	trip.ID = to
+	db.Create(&trip)
+
+	var stopTimes []gtfs.StopTime
+	db.Where("trip_id = ?", from).Find(&stopTimes)
+	for _, st := range stopTimes {
+		st.ID = 0
+		st.TripID = to
+		st.Arrival.Int32 += offset
+		st.Departure.Int32 += offset
+		db.Create(&st)
+	}
+}
+
 func TestGTFSDateTime_UnmarshalCSV(t *testing.T) {
 
 	tests := []struct {
@@ -0,0 +1,170 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+)
+
+// minHeadwayTrips is the minimum number of trips running at a regular headway
+// to be considered a headway pattern.
+const minHeadwayTrips = 3
+
+// HeadwayPattern describes trips of a route, that run at a regular headway
+// and could thus be represented by a single trip and a frequency.
+type HeadwayPattern struct {
+	RouteID     string
+	ServiceID   string
+	TripIDs     []string
+	StartTime   DateTime
+	EndTime     DateTime
+	HeadwaySecs int
+}
+
+// Frequency returns the frequency (with exact times), that (along with the
+// first trip of the pattern) represents all trips of the pattern.
+func (hp HeadwayPattern) Frequency() Frequency {
+	return Frequency{
+		TripID:      hp.TripIDs[0],
+		StartTime:   hp.StartTime,
+		EndTime:     hp.EndTime,
+		HeadwaySecs: hp.HeadwaySecs,
+		ExactTimes:  1,
+	}
+}
+
+// statement to select the stop times of all trips not being frequency based
+const scheduledStopTimesStmt = `
+SELECT
+	trips.id,
+	trips.route_id,
+	trips.service_id,
+	trips.direction_id,
+	trips.shape_id,
+	trips.name,
+	stop_times.stop_id,
+	stop_times.arrival,
+	stop_times.departure
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+WHERE
+	trips.id NOT IN (
+	SELECT DISTINCT
+		trip_id
+	FROM
+		frequencies)
+ORDER BY
+	trips.id,
+	stop_times.stop_seq;
+`
+
+// DetectHeadways finds trips (at least three), that share route, service,
+// direction, shape and the sequence of stops (with the same relative times)
+// and start at a regular headway. Such trips may be represented by a single
+// trip and an entry in frequencies.txt.
+func DetectHeadways(db *gorm.DB) ([]HeadwayPattern, error) {
+
+	rows, err := db.Raw(scheduledStopTimesStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// the trips (and their start) grouped by signature
+	type trip struct {
+		id    string
+		start int32
+	}
+	type group struct {
+		routeID   string
+		serviceID string
+		trips     []trip
+	}
+	groups := map[string]*group{}
+
+	var current struct {
+		id, routeID, serviceID string
+		start                  int32
+		sig                    strings.Builder
+	}
+	flush := func() {
+		if current.id == "" {
+			return
+		}
+		sig := current.sig.String()
+		g, ok := groups[sig]
+		if !ok {
+			g = &group{routeID: current.routeID, serviceID: current.serviceID}
+			groups[sig] = g
+		}
+		g.trips = append(g.trips, trip{id: current.id, start: current.start})
+		current.sig.Reset()
+	}
+	for rows.Next() {
+		var id, routeID, serviceID, directionID, shapeID, name, stopID string
+		var arrival, departure DateTime
+		if err = rows.Scan(&id, &routeID, &serviceID, &directionID, &shapeID, &name, &stopID, &arrival, &departure); err != nil {
+			return nil, err
+		}
+		if id != current.id {
+			flush()
+			current.id, current.routeID, current.serviceID = id, routeID, serviceID
+			current.start = departure.Int32
+			_, _ = fmt.Fprintf(&current.sig, "%s\n%s\n%s\n%s\n%s\n", routeID, serviceID, directionID, shapeID, name)
+		}
+		_, _ = fmt.Fprintf(&current.sig, "%s %d %d\n", stopID, arrival.Int32-current.start, departure.Int32-current.start)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	// find runs of trips starting at a regular headway
+	var patterns []HeadwayPattern
+	for _, g := range groups {
+		trips := g.trips
+		sort.Slice(trips, func(i, j int) bool {
+			return trips[i].start < trips[j].start
+		})
+		for i := 0; i < len(trips)-1; {
+			headway := trips[i+1].start - trips[i].start
+			j := i + 1
+			for j+1 < len(trips) && trips[j+1].start-trips[j].start == headway {
+				j++
+			}
+			if headway <= 0 || j-i+1 < minHeadwayTrips {
+				i++
+				continue
+			}
+			p := HeadwayPattern{
+				RouteID:     g.routeID,
+				ServiceID:   g.serviceID,
+				StartTime:   DateTime{Int32: trips[i].start},
+				EndTime:     DateTime{Int32: trips[j].start + headway},
+				HeadwaySecs: int(headway),
+			}
+			for _, t := range trips[i : j+1] {
+				p.TripIDs = append(p.TripIDs, t.id)
+			}
+			patterns = append(patterns, p)
+			i = j + 1
+		}
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		pi, pj := patterns[i], patterns[j]
+		if pi.RouteID != pj.RouteID {
+			return pi.RouteID < pj.RouteID
+		}
+		if pi.ServiceID != pj.ServiceID {
+			return pi.ServiceID < pj.ServiceID
+		}
+		return pi.TripIDs[0] < pj.TripIDs[0]
+	})
+
+	return patterns, nil
+}
@@ -0,0 +1,30 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"reflect"
+	"testing"
+)
+
+func TestDetectHeadways(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// T1 and T2 run 20 minutes apart, add T6 20 minutes after T2
+	copyTrip(t, db, "T2", "T6", 20*60)
+
+	want := []gtfs.HeadwayPattern{{
+		RouteID:     "R1",
+		ServiceID:   "WD",
+		TripIDs:     []string{"T1", "T2", "T6"},
+		StartTime:   gtfs.DateTime{Int32: 8 * 3600},
+		EndTime:     gtfs.DateTime{Int32: 9 * 3600},
+		HeadwaySecs: 20 * 60,
+	}}
+	got, err := gtfs.DetectHeadways(db)
+	if err != nil {
+		t.Fatalf("DetectHeadways() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DetectHeadways() = %v, want %v", got, want)
+	}
+}
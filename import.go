@@ -0,0 +1,1037 @@
+package gtfs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// batchSize is the default size of the batches to use for importing into the
+// DB (see ImportOptions.BatchSize).
+const batchSize = 1000
+
+// importSources defines what to import (in which order).
+var importSources = []struct {
+	itemType ItemType
+	optional bool
+}{
+	{Agencies, false},
+	{Routes, false},
+	{Trips, false},
+	{Stops, false},
+	{StopTimes, false},
+	{Shapes, false},
+	{Calendars, false},
+	{CalendarDates, false},
+	{Frequencies, true},
+}
+
+// ImportItemsResult is the type used to describe the result of importing a
+// single item type.
+type ImportItemsResult struct {
+	ItemType    ItemType
+	Path        string
+	SHA256      string
+	Skipped     bool // the file is optional and not present or not selected
+	Excluded    bool // the file is not selected (see ImportOptions.ItemTypes)
+	Count       int64
+	Batches     int64
+	Duplicates  int64        // items repeating the ID of a previous item (see ConflictStrategy)
+	Rejected    int64        // items failed to insert (see ImportOptions.SkipFailedRows)
+	Malformed   int64        // rows failed to parse and skipped (see ImportLenient)
+	Normalized  int64        // items normalized (see ImportOptions.Profile and FillRouteNames)
+	Rejections  []Rejection  // (up to 100 of) the rows malformed or rejected
+	SkippedRows []SkippedRow // (up to 100 of) the rows malformed along with their raw content
+	RolledBack  bool         // the import failed and the table was left as it was before
+	Truncated   bool         // the import stopped early (see ImportOptions.MaxRows and MaxDuration)
+	Time        time.Duration
+	Error       error
+}
+
+// maxRejections is the maximum number of rejections recorded per item type.
+const maxRejections = 100
+
+// Rejection describes a row that failed to parse (see ImportLenient) or an
+// item that failed to insert.
+type Rejection struct {
+	Line  int64
+	Error error
+}
+
+// String returns a human-readable representation of Rejection.
+func (r Rejection) String() string {
+	return fmt.Sprintf("line %d: %v", r.Line, r.Error)
+}
+
+// String returns a human-readable representation of ImportItemsResult.
+func (iir ImportItemsResult) String() string {
+	if iir.Skipped && iir.Excluded {
+		return fmt.Sprintf("skipped %s (not selected)", iir.ItemType)
+	}
+	if iir.Skipped {
+		return fmt.Sprintf("skipped %s ('%s' not present)", iir.ItemType, iir.Path)
+	}
+	if iir.Error != nil {
+		if iir.RolledBack {
+			return fmt.Sprintf("failed to import %s (rolled back): %v", iir.ItemType, iir.Error)
+		}
+		return fmt.Sprintf("failed to import %s: %v", iir.ItemType, iir.Error)
+	}
+	var notes []string
+	if iir.Duplicates > 0 {
+		notes = append(notes, fmt.Sprintf("%d duplicates", iir.Duplicates))
+	}
+	if iir.Rejected > 0 {
+		notes = append(notes, fmt.Sprintf("%d rejected", iir.Rejected))
+	}
+	if iir.Malformed > 0 {
+		notes = append(notes, fmt.Sprintf("%d malformed", iir.Malformed))
+	}
+	if iir.Normalized > 0 {
+		notes = append(notes, fmt.Sprintf("%d normalized", iir.Normalized))
+	}
+	if iir.Truncated {
+		notes = append(notes, "truncated")
+	}
+	if len(notes) > 0 {
+		return fmt.Sprintf("imported %d %s (%s) in %d batches in %s", iir.Count, iir.ItemType, strings.Join(notes, ", "), iir.Batches, iir.Time)
+	}
+	return fmt.Sprintf("imported %d %s in %d batches in %s", iir.Count, iir.ItemType, iir.Batches, iir.Time)
+}
+
+// ImportEvent is the type used to report the progress of an import.
+type ImportEvent struct {
+
+	// Result is the result of importing a single item type.
+	Result *ImportItemsResult
+
+	// Estimate (if not nil) is the estimated size of the whole import (see
+	// ImportOptions.Estimate), e.g. to relate the rows imported so far to.
+	Estimate *ImportEstimate
+}
+
+// ImportOptions configures ImportWithOptions.
+type ImportOptions struct {
+
+	// OnProgress (if not nil) is called (synchronously) with the result of
+	// importing each of the item types (one at a time, also when importing
+	// concurrently, see Workers).
+	OnProgress func(ImportEvent)
+
+	// Estimate (if not nil) is the estimated size of the import (see
+	// EstimateImport), passed along with the progress (see
+	// ImportEvent.Estimate).
+	Estimate *ImportEstimate
+
+	// FTS5 (if true) builds the search indexes as SQLite FTS5 virtual tables
+	// (see IndexStops and IndexSearch).
+	FTS5 bool
+
+	// Conflicts maps item types to the way of handling items repeating the ID
+	// of another item (item types not mapped default to ConflictError).
+	Conflicts map[ItemType]ConflictStrategy
+
+	// SkipFailedRows (if true) retries batches failing to insert row by row,
+	// rejecting only the rows failing to insert (rather than failing the
+	// import of the item type).
+	SkipFailedRows bool
+
+	// Profile (if not nil) normalizes known quirks of feeds (see
+	// ImportProfiles).
+	Profile *ImportProfile
+
+	// FillRouteNames (if true) fills the empty short names of routes with
+	// their long names and vice versa, as recommended by the GTFS reference,
+	// such that UIs never show blank route labels (see
+	// ImportItemsResult.Normalized).
+	FillRouteNames bool
+
+	// RecordSkippedRows (if true) records the rows failing to parse and
+	// skipped (see ImportLenient) along with their raw content and the
+	// errors in the skipped_rows table (see SkippedRows).
+	RecordSkippedRows bool
+
+	// RecordRejects (if true) records rows skipped, replaced, rejected,
+	// normalized or malformed (along with the reasons) in the import_rejects
+	// table (see ImportRejects), allowing data owners to fix their feeds.
+	RecordRejects bool
+
+	// Delimiter (if not 0) is the delimiter of the CSV files. By default, the
+	// delimiter (comma, semicolon, tab or pipe) is detected per file.
+	Delimiter rune
+
+	// DecimalComma (if true) accepts decimal commas in numbers (e.g.
+	// "52,5213" as latitude), as written by spreadsheets in many locales
+	// (e.g. German or French). Decimal commas are accepted in files not
+	// delimited by commas anyway.
+	DecimalComma bool
+
+	// Encoding is the character encoding of the CSV files (defaults to
+	// EncodingUTF8). Regardless of the encoding, byte order marks are
+	// stripped and line endings (CRLF and CR) are normalized.
+	Encoding Encoding
+
+	// EncodeShapes (if true) stores shapes as encoded polylines rather than
+	// as rows of points (see EncodeShapes).
+	EncodeShapes bool
+
+	// Decoder (if not nil) decodes the CSV files (defaults to GocsvDecoder).
+	// FastDecoder is considerably faster on large files (e.g. stop times).
+	Decoder CSVDecoder
+
+	// PrepareStmt (if true) prepares the statements inserting items once and
+	// reuses them across batches (rather than having the DB plan each batch
+	// anew). With SQLite and full batches the gain is small (about 1% on
+	// stop times, see BenchmarkImport_PrepareStmt), as inserting dominates
+	// planning.
+	PrepareStmt bool
+
+	// MaxRows (if > 0) is the maximum number of rows imported per file. Files
+	// holding more rows are imported partially (see
+	// ImportItemsResult.Truncated).
+	MaxRows int64
+
+	// MaxDuration (if > 0) is the time budget of the import. Once exceeded,
+	// the file being imported is imported partially and the remaining files
+	// are not imported at all (see ImportItemsResult.Truncated).
+	MaxDuration time.Duration
+
+	// BatchSize (if > 0) is the number of items inserted per statement
+	// (defaults to 1000). Larger batches speed up imports into local DBs,
+	// smaller ones keep statements within the limits of remote DBs.
+	BatchSize int
+
+	// ItemTypes (if not empty) are the item types to import. Files of other
+	// item types are skipped (see ImportItemsResult.Excluded), leaving their
+	// tables as they were before.
+	ItemTypes []ItemType
+
+	// LenientCSV (if true) accepts malformed CSV files as written by some
+	// tools, i.e. stray quotes within unquoted values and rows holding more
+	// or fewer values than the header (padded with empty values or cut).
+	LenientCSV bool
+
+	// Mode is the way of handling malformed rows and files, i.e. failing on
+	// the first row failing to parse (ImportDefault), also failing on files
+	// lacking required columns (ImportStrict) or skipping the rows failing to
+	// parse (ImportLenient).
+	Mode ImportMode
+
+	// Retry (if not nil) bounds the time of importing each file and retries
+	// files failing to import with transient errors (e.g. a busy DB, see
+	// IsTransient), as long as the import was rolled back.
+	Retry *RetryPolicy
+
+	// Workers (if > 1) is the number of files imported concurrently (each in
+	// a transaction of its own), cutting the time of imports into server DBs
+	// (e.g. Postgres). Files depending on others wait for them (e.g. stops
+	// for routes and trips, building the search indexes). SQLite admits a
+	// single writer at a time, thus concurrent files fail with "database is
+	// locked" (see Retry) once waiting longer than the busy timeout.
+	Workers int
+
+	// InsertWorkers (if > 0) is the number of goroutines inserting the
+	// batches of each file, such that parsing a file (e.g. stop times) and
+	// inserting its rows overlap. Batches are inserted in no particular order
+	// (except for item types whose conflicts are replaced, see Conflicts).
+	InsertWorkers int
+
+	// OnFileProgress (if not nil) is called (synchronously, but concurrently
+	// for files imported concurrently, see Workers) with the progress of
+	// importing each file every ProgressInterval, e.g. to show the time left
+	// importing huge stop times.
+	OnFileProgress func(FileProgress)
+
+	// ProgressInterval (if > 0) is the interval of reporting the progress of
+	// importing a file (see OnFileProgress, defaults to a second).
+	ProgressInterval time.Duration
+
+	// NoTransactions (if true) imports files without wrapping each of them in
+	// a transaction (e.g. sparing server DBs the undo log of huge files).
+	// Files failing to import are left partially imported (rather than
+	// rolled back, see ImportItemsResult.RolledBack) and are not retried (see
+	// Retry).
+	NoTransactions bool
+
+	// BeforeTable (if not nil) is called before importing the items of each
+	// file (not skipped) with the transaction importing them, e.g. to drop
+	// views depending on the table. If BeforeTable returns an error, the
+	// import of the file fails (and is rolled back).
+	BeforeTable func(tx *gorm.DB, itemType ItemType) error
+
+	// AfterTable (if not nil) is called after importing the items of each
+	// file successfully (along with deriving items, e.g. the search indexes)
+	// with the transaction importing them, e.g. to build derived tables. If
+	// AfterTable returns an error, the import of the file fails (and is
+	// rolled back).
+	AfterTable func(tx *gorm.DB, r *ImportItemsResult) error
+
+	// AfterImport (if not nil) is called once all files are imported (or
+	// failed to import) with the results of all item types, e.g. to refresh
+	// materialized views.
+	AfterImport func(db *gorm.DB, results []*ImportItemsResult)
+
+	// Logger (if not nil) is the handler the diagnostics of the import are
+	// logged to, i.e. the result of each file (at level info, debug if
+	// skipped and error if failed), the rows rejected and the retries (at
+	// level warn).
+	Logger slog.Handler
+
+	// deadline is the time the budget (see MaxDuration) is exceeded at.
+	deadline time.Time
+
+	// logger logs to Logger (see newLogger).
+	logger *slog.Logger
+}
+
+// ImportOption is a functional option setting ImportOptions (see
+// NewImportOptions).
+type ImportOption func(*ImportOptions)
+
+// NewImportOptions returns the ImportOptions set by the given options, e.g.
+//
+//	gtfs.ImportWithOptions(db, gtfsBase, gtfs.NewImportOptions(gtfs.WithBatchSize(5000), gtfs.WithLenientCSV()))
+func NewImportOptions(options ...ImportOption) ImportOptions {
+	var opts ImportOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return opts
+}
+
+// WithBatchSize sets the number of items inserted per statement (see
+// ImportOptions.BatchSize).
+func WithBatchSize(n int) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.BatchSize = n
+	}
+}
+
+// WithItemTypes restricts the import to the given item types (see
+// ImportOptions.ItemTypes).
+func WithItemTypes(itemTypes ...ItemType) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.ItemTypes = append(opts.ItemTypes, itemTypes...)
+	}
+}
+
+// WithLenientCSV accepts malformed CSV files (see ImportOptions.LenientCSV).
+func WithLenientCSV() ImportOption {
+	return func(opts *ImportOptions) {
+		opts.LenientCSV = true
+	}
+}
+
+// WithMode sets the way of handling malformed rows and files (see
+// ImportOptions.Mode).
+func WithMode(mode ImportMode) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.Mode = mode
+	}
+}
+
+// WithWorkers sets the number of files imported concurrently (see
+// ImportOptions.Workers).
+func WithWorkers(n int) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.Workers = n
+	}
+}
+
+// WithLogger sets the handler the diagnostics of the import are logged to (see
+// ImportOptions.Logger).
+func WithLogger(h slog.Handler) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.Logger = h
+	}
+}
+
+// WithInsertWorkers sets the number of goroutines inserting the batches of
+// each file (see ImportOptions.InsertWorkers).
+func WithInsertWorkers(n int) ImportOption {
+	return func(opts *ImportOptions) {
+		opts.InsertWorkers = n
+	}
+}
+
+// batchSize returns the number of items inserted per statement.
+func (opts ImportOptions) batchSize() int {
+	if opts.BatchSize > 0 {
+		return opts.BatchSize
+	}
+	return batchSize
+}
+
+// selected returns whether items of the given type are to be imported.
+func (opts ImportOptions) selected(itemType ItemType) bool {
+	if len(opts.ItemTypes) == 0 {
+		return true
+	}
+	for _, t := range opts.ItemTypes {
+		if t == itemType {
+			return true
+		}
+	}
+	return false
+}
+
+// Import imports all GTFS CSV files from the directory gtfsBase into the db.
+//
+// If the progress channel is not nil, import results (for each of the item
+// types) will be sent through the channel. Passing the channel hands over its
+// ownership: Import closes the channel when done, thus the caller must neither
+// close nor send to it, but should receive from it until it gets closed (i.e.
+// run Import in a separate goroutine). ImportWithOptions offers a callback
+// based alternative. Import returns the summary of the import (see
+// ImportSummary.Err).
+func Import(db *gorm.DB, gtfsBase string, progress chan<- *ImportItemsResult) *ImportSummary {
+	var opts ImportOptions
+	if progress != nil {
+		defer close(progress)
+		opts.OnProgress = func(e ImportEvent) {
+			progress <- e.Result
+		}
+	}
+	return ImportWithOptions(db, gtfsBase, opts)
+}
+
+// ImportWithOptions imports all GTFS CSV files from the directory gtfsBase
+// into the db. After importing the stops, the search indexes are built (see
+// IndexStops and IndexSearch). Each file is imported within a transaction,
+// thus a file failing to import leaves its table as it was before (see
+// ImportItemsResult.RolledBack). Files failing to import don't stop the
+// import, ImportWithOptions returns the summary of the import, holding the
+// results of all files (see ImportSummary.Err). If any of the required files
+// (see CheckRequiredFiles) is missing, no file is imported at all and the
+// summary holds the results of the files missing only (see
+// ErrRequiredFileMissing).
+func ImportWithOptions(db *gorm.DB, gtfsBase string, opts ImportOptions) *ImportSummary {
+	dir := gtfsBase
+	if dir == "" {
+		dir = "."
+	}
+	return importFS(db, os.DirFS(dir), ".", gtfsBase, opts)
+}
+
+// ImportFS imports all GTFS CSV files from the root of fsys into the db (see
+// Import), e.g. feeds embedded with embed.FS, held in memory (e.g. a
+// zip.Reader) or provided by custom sources. The paths of the files (as
+// reported) are relative to the root of fsys.
+func ImportFS(db *gorm.DB, fsys fs.FS, progress chan<- *ImportItemsResult) *ImportSummary {
+	var opts ImportOptions
+	if progress != nil {
+		defer close(progress)
+		opts.OnProgress = func(e ImportEvent) {
+			progress <- e.Result
+		}
+	}
+	return ImportFSWithOptions(db, fsys, opts)
+}
+
+// ImportFSWithOptions imports all GTFS CSV files from the root of fsys into the
+// db (see ImportWithOptions and ImportFS).
+func ImportFSWithOptions(db *gorm.DB, fsys fs.FS, opts ImportOptions) *ImportSummary {
+	return importFS(db, fsys, ".", "", opts)
+}
+
+// importFS imports all GTFS CSV files from the directory dir of fsys into the
+// db (see ImportWithOptions). The paths of the files (as reported) are
+// relative to base.
+func importFS(db *gorm.DB, fsys fs.FS, dir, base string, opts ImportOptions) *ImportSummary {
+	start := time.Now()
+	if opts.MaxDuration > 0 {
+		opts.deadline = time.Now().Add(opts.MaxDuration)
+	}
+	opts.logger = newLogger(opts.Logger)
+
+	// check the required files (importing none, if any is missing)
+	if missing := missingFiles(fsys, dir, base, opts); len(missing) > 0 {
+		for _, r := range missing {
+			logImportResult(opts.logger, r)
+			if opts.OnProgress != nil {
+				opts.OnProgress(ImportEvent{Result: r, Estimate: opts.Estimate})
+			}
+		}
+		return &ImportSummary{Results: missing, Time: time.Since(start)}
+	}
+
+	// import each of the sources (concurrently, if desired)
+	var results []*ImportItemsResult
+	if opts.Workers > 1 {
+		results = importConcurrently(db, fsys, dir, base, opts)
+	} else {
+		for _, source := range importSources {
+			r := importSource(db, fsys, dir, base, source.itemType, source.optional, opts)
+			results = append(results, r)
+
+			// send progress if desired
+			if opts.OnProgress != nil {
+				opts.OnProgress(ImportEvent{Result: r, Estimate: opts.Estimate})
+			}
+		}
+	}
+
+	// complete the import, if desired
+	if opts.AfterImport != nil {
+		opts.AfterImport(db, results)
+	}
+
+	summary := &ImportSummary{Results: results, Time: time.Since(start)}
+	for _, r := range results {
+		summary.Rows += r.Count
+	}
+	return summary
+}
+
+// importConcurrently imports the sources like importFS, but imports up to
+// opts.Workers files at a time. Files wait for the files they depend on (see
+// importDependencies) and progress is sent one event at a time. The results
+// are returned in the order of the sources.
+func importConcurrently(db *gorm.DB, fsys fs.FS, dir, base string, opts ImportOptions) []*ImportItemsResult {
+	results := make([]*ImportItemsResult, len(importSources))
+	done := make(map[ItemType]chan struct{}, len(importSources))
+	for _, source := range importSources {
+		done[source.itemType] = make(chan struct{})
+	}
+	workers := make(chan struct{}, opts.Workers)
+	var progress sync.Mutex
+	var wg sync.WaitGroup
+	for i, source := range importSources {
+		wg.Add(1)
+		go func(i int, itemType ItemType, optional bool) {
+			defer wg.Done()
+			defer close(done[itemType])
+			for _, dependency := range importDependencies(itemType, opts) {
+				<-done[dependency]
+			}
+
+			workers <- struct{}{}
+			r := importSource(db, fsys, dir, base, itemType, optional, opts)
+			<-workers
+			results[i] = r
+
+			// send progress if desired
+			if opts.OnProgress != nil {
+				progress.Lock()
+				opts.OnProgress(ImportEvent{Result: r, Estimate: opts.Estimate})
+				progress.Unlock()
+			}
+		}(i, source.itemType, source.optional)
+	}
+	wg.Wait()
+	return results
+}
+
+// importDependencies returns the item types whose import has to complete
+// before importing the given item type, i.e. agencies before routes (when
+// merging agencies, see ImportProfile.MergeAgencies) and routes and trips
+// before stops (building the search indexes).
+func importDependencies(itemType ItemType, opts ImportOptions) []ItemType {
+	switch {
+	case itemType == Routes && opts.Profile != nil && opts.Profile.MergeAgencies:
+		return []ItemType{Agencies}
+	case itemType == Stops:
+		return []ItemType{Routes, Trips}
+	}
+	return nil
+}
+
+// importSource imports the file of the given item type from fsys (see
+// importFS), retrying as desired (see ImportOptions.Retry) and logging the
+// result (see ImportOptions.Logger).
+func importSource(db *gorm.DB, fsys fs.FS, dir, base string, itemType ItemType, optional bool, opts ImportOptions) (r *ImportItemsResult) {
+	name := path.Join(dir, itemFiles[itemType])
+	csvPath := path.Join(base, name)
+	defer func() {
+		logImportResult(opts.logger, r)
+	}()
+
+	// skip files not selected, optional files not present (and any file,
+	// once out of time)
+	if !opts.selected(itemType) {
+		return &ImportItemsResult{ItemType: itemType, Path: csvPath, Skipped: true, Excluded: true}
+	}
+	if _, err := fs.Stat(fsys, name); optional && errors.Is(err, fs.ErrNotExist) {
+		return &ImportItemsResult{ItemType: itemType, Path: csvPath, Skipped: true}
+	}
+	if !opts.deadline.IsZero() && time.Now().After(opts.deadline) {
+		return &ImportItemsResult{ItemType: itemType, Path: csvPath, Truncated: true}
+	}
+	_ = opts.Retry.Do(dbContext(db), func(ctx context.Context) error {
+		if r != nil {
+			opts.logger.Warn("retrying file", slog.String("item_type", itemType.String()), slog.String("path", csvPath), slog.Any("error", r.Error))
+		}
+		r = importFile(db.WithContext(ctx), fsys, name, csvPath, itemType, opts)
+		if r.RolledBack {
+			return r.Error
+		}
+		return nil
+	})
+	return r
+}
+
+// importFile imports all items of a given type from a CSV-file (named name
+// within fsys and reported as csvPath) into a DB (along with merging agencies
+// and building the search indexes, if due, and calling the hooks, see
+// ImportOptions.BeforeTable and AfterTable) within a transaction (unless
+// opts.NoTransactions is true). The transaction is rolled back, if the import
+// fails.
+func importFile(db *gorm.DB, fsys fs.FS, name, csvPath string, itemType ItemType, opts ImportOptions) *ImportItemsResult {
+	if opts.PrepareStmt {
+		db = db.Session(&gorm.Session{PrepareStmt: true})
+	}
+	tx := db
+	if !opts.NoTransactions {
+		if tx = db.Begin(); tx.Error != nil {
+			return &ImportItemsResult{ItemType: itemType, Path: csvPath, Error: &DBError{Op: "begin transaction", Err: tx.Error}}
+		}
+	}
+
+	// prepare the table, if desired
+	var r *ImportItemsResult
+	if opts.BeforeTable != nil {
+		if err := opts.BeforeTable(tx, itemType); err != nil {
+			r = &ImportItemsResult{ItemType: itemType, Path: csvPath, Error: fmt.Errorf("failed to prepare %s: %w", itemType, err)}
+		}
+	}
+	if r == nil {
+		r = importItems(tx, fsys, name, csvPath, itemType, opts)
+	}
+
+	// merge duplicate agencies (once the routes referencing them are imported)
+	if itemType == Routes && r.Error == nil && opts.Profile != nil && opts.Profile.MergeAgencies {
+		if err := mergeAgencies(tx); err != nil {
+			r.Error = fmt.Errorf("failed to merge agencies: %w", err)
+		}
+	}
+
+	// encode the shapes, if desired
+	if itemType == Shapes && r.Error == nil && opts.EncodeShapes {
+		if _, err := EncodeShapes(tx); err != nil {
+			r.Error = fmt.Errorf("failed to encode shapes: %w", err)
+		}
+	}
+
+	// compute the bounding boxes of the shapes
+	if itemType == Shapes && r.Error == nil {
+		if err := IndexShapeBounds(tx); err != nil {
+			r.Error = fmt.Errorf("failed to index shape bounds: %w", err)
+		}
+	}
+
+	// build the search indexes (routes and trips are imported before stops)
+	if itemType == Stops && r.Error == nil {
+		if err := IndexStops(tx, opts.FTS5); err != nil {
+			r.Error = fmt.Errorf("failed to index stops: %w", err)
+		} else if err = IndexSearch(tx, opts.FTS5); err != nil {
+			r.Error = fmt.Errorf("failed to build search index: %w", err)
+		}
+	}
+
+	// complete the table, if desired
+	if r.Error == nil && opts.AfterTable != nil {
+		if err := opts.AfterTable(tx, r); err != nil {
+			r.Error = fmt.Errorf("failed to complete %s: %w", itemType, err)
+		}
+	}
+
+	// roll back on failure (leaving the table as it was before)
+	if opts.NoTransactions {
+		return r
+	}
+	if r.Error != nil {
+		if err := tx.Rollback().Error; err != nil {
+			r.Error = fmt.Errorf("%v (failed to roll back: %w)", r.Error, err)
+		} else {
+			r.RolledBack = true
+		}
+		return r
+	}
+	if err := tx.Commit().Error; err != nil {
+		r.Error = &DBError{Op: "commit", Err: err}
+		r.RolledBack = true
+	}
+	return r
+}
+
+// importItems imports all items of a given type from a CSV-file (named name
+// within fsys and reported as csvPath) into a DB (according to opts, see
+// insertBatches).
+func importItems(db *gorm.DB, fsys fs.FS, name, csvPath string, itemType ItemType, opts ImportOptions) *ImportItemsResult {
+
+	// provide for timing
+	start := time.Now()
+
+	model, ok := itemModels[itemType]
+	if !ok {
+		return &ImportItemsResult{ItemType: itemType, Path: csvPath, Error: fmt.Errorf("unknown ItemType %d", itemType)}
+	}
+
+	// serialize the batchers inserting into the transaction (if any, see
+	// batcher.savepoint)
+	db = db.Set(txLockKey, new(sync.Mutex)).Session(&gorm.Session{})
+
+	file, err := fsys.Open(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &ImportItemsResult{ItemType: itemType, Path: csvPath, Error: &FileMissingError{ItemType: itemType, Path: csvPath, Err: err}}
+	}
+	if err != nil {
+		return &ImportItemsResult{ItemType: itemType, Path: csvPath, Error: fmt.Errorf("failed to open '%s': %w", csvPath, err)}
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	// hash the file while parsing it (allowing to stop parsing early)
+	hash := sha256.New()
+	reader := &stopReader{r: io.TeeReader(file, hash)}
+
+	// report the progress of parsing the file, if desired
+	var progress func(rows int64)
+	if opts.OnFileProgress != nil {
+		var size int64
+		if info, err := file.Stat(); err == nil {
+			size = info.Size()
+		}
+		progress = func(rows int64) {
+			opts.OnFileProgress(FileProgress{
+				ItemType:  itemType,
+				Path:      csvPath,
+				Rows:      rows,
+				BytesRead: reader.read(),
+				FileSize:  size,
+				Elapsed:   time.Since(start),
+			})
+		}
+	}
+
+	// normalize the encoding, delimiters and decimal separators
+	csvReader, done := normalizeCSV(newTextReader(reader, opts.Encoding), reflect.TypeOf(model).Elem(), opts.Delimiter, opts.DecimalComma, opts.LenientCSV)
+	defer done()
+
+	// record rows skipped or modified (if desired, serializing the rows
+	// rejected by insert workers)
+	var audit *batcher
+	var auditErr error
+	var auditMu sync.Mutex
+	var record func(line int64, action, reason string)
+	if opts.RecordRejects {
+		audit = newBatcher(db, reflect.TypeOf(&ImportReject{}), opts.batchSize(), ConflictError, nil, false)
+		file := path.Base(csvPath)
+		record = func(line int64, action, reason string) {
+			auditMu.Lock()
+			defer auditMu.Unlock()
+			if err := audit.add(reflect.ValueOf(&ImportReject{File: file, Line: line, Action: action, Reason: reason}), line); err != nil && auditErr == nil {
+				auditErr = err
+			}
+		}
+	}
+
+	// record rows skipped as malformed (if desired)
+	var skipped *batcher
+	var skippedErr error
+	onSkip := func(line int64, raw string, err error) {
+		if record != nil {
+			record(line, RejectMalformed, err.Error())
+		}
+		if skipped != nil {
+			if err := skipped.add(reflect.ValueOf(&SkippedRow{File: path.Base(csvPath), Line: line, Raw: raw, Error: err.Error()}), line); err != nil && skippedErr == nil {
+				skippedErr = err
+			}
+		}
+	}
+	if opts.RecordSkippedRows {
+		skipped = newBatcher(db, reflect.TypeOf(&SkippedRow{}), opts.batchSize(), ConflictError, nil, false)
+	}
+
+	// check the rows, if desired (skipping malformed rows, if lenient)
+	csvReader, malformed, checked := checkRows(csvReader, path.Base(csvPath), itemType, reflect.TypeOf(model).Elem(), opts.Mode, onSkip)
+	defer checked()
+
+	// parse CSV and send each row to the channel (the decoder closes the channel)
+	items := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, reflect.TypeOf(model)), 0)
+	resultChan := make(chan *ImportItemsResult)
+	decoder := opts.Decoder
+	if decoder == nil {
+		decoder = GocsvDecoder{}
+	}
+	go insertBatches(db, itemType, opts, record, progress, reader.stop, malformed, items, resultChan)
+	err = decoder.Decode(csvReader, items.Interface())
+
+	// wait for the batch insert to return counts (ignoring errors parsing the
+	// remainder of a file stopped early)
+	r := <-resultChan
+	if err != nil && r.Error == nil && !r.Truncated {
+		r.Error = newRowParseError(itemType, csvPath, err)
+	}
+
+	// report the rows skipped (ahead of the items rejected)
+	if n := malformed.count(); n > 0 {
+		r.Malformed = n
+		r.Rejections = append(malformed.rejections(), r.Rejections...)
+		if len(r.Rejections) > maxRejections {
+			r.Rejections = r.Rejections[:maxRejections]
+		}
+		r.SkippedRows = malformed.skippedRows()
+	}
+
+	// persist the rows recorded
+	if audit != nil && r.Error == nil {
+		if err = audit.flush(); err == nil {
+			err = auditErr
+		}
+		if err != nil {
+			r.Error = fmt.Errorf("failed to record rejects: %w", err)
+		}
+	}
+	if skipped != nil && r.Error == nil {
+		if err = skipped.flush(); err == nil {
+			err = skippedErr
+		}
+		if err != nil {
+			r.Error = fmt.Errorf("failed to record skipped rows: %w", err)
+		}
+	}
+
+	// compute the elapsed Time
+	r.Time = time.Since(start)
+	r.Path = csvPath
+	var rowErr *RowParseError
+	if errors.As(r.Error, &rowErr) && rowErr.Path == "" {
+		rowErr.Path = csvPath
+	}
+	if !r.Truncated {
+		r.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	}
+
+	return r
+}
+
+// stopReader is a reader reading from r until stopped (returning io.EOF
+// afterwards), counting the bytes read.
+type stopReader struct {
+	r       io.Reader
+	stopped int32
+	n       int64
+}
+
+// Read reads from the underlying reader (unless stopped).
+func (sr *stopReader) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&sr.stopped) == 1 {
+		return 0, io.EOF
+	}
+	n, err := sr.r.Read(p)
+	atomic.AddInt64(&sr.n, int64(n))
+	return n, err
+}
+
+// stop stops reading.
+func (sr *stopReader) stop() {
+	atomic.StoreInt32(&sr.stopped, 1)
+}
+
+// read returns the number of bytes read so far.
+func (sr *stopReader) read() int64 {
+	return atomic.LoadInt64(&sr.n)
+}
+
+// insertBatches inserts all items (pointers to models) from a channel into a
+// DB in batches. Items repeating the ID of a previous item are handled
+// according to opts.Conflicts (the same applies to items conflicting with
+// items already in the DB). If opts.SkipFailedRows is true, batches failing to
+// insert are retried row by row, rejecting only the failing rows. If
+// opts.Profile is not nil (or opts.FillRouteNames is true), items are
+// normalized accordingly. If record is not nil, it is called for each item
+// skipped, replaced, rejected or normalized. If progress is not nil, it is
+// called with the number of items read every opts.ProgressInterval. The lines
+// of items account for the rows skipped (see malformed, nil unless opts.Mode
+// is ImportLenient). Once opts.MaxRows or the deadline of opts is exceeded,
+// stop is called and the remaining items are discarded.
+func insertBatches(db *gorm.DB, itemType ItemType, opts ImportOptions, record func(line int64, action, reason string), progress func(rows int64), stop func(), malformed *malformedRows, items reflect.Value, result chan *ImportItemsResult) {
+
+	// ensure the result channel will be closed at last
+	defer close(result)
+
+	// initialize counters
+	var itemCount int64
+	var duplicates int64
+	var normalized int64
+	var truncated bool
+
+	// initialize the batcher (handling conflicts with items already in the DB)
+	conflicts, profile := opts.Conflicts[itemType], opts.Profile
+	b := newBatcher(db, items.Type().Elem(), opts.batchSize(), conflicts, conflictColumns(itemType), opts.SkipFailedRows)
+	if record != nil {
+		b.onReject = func(line int64, err error) {
+			record(line, RejectRejected, err.Error())
+		}
+	}
+
+	// insert batches concurrently, if desired (keeping the order of batches
+	// replacing items of previous batches)
+	if opts.InsertWorkers > 0 && conflicts != ConflictReplace {
+		b.startWorkers(opts.InsertWorkers)
+	}
+
+	// provide for reporting the progress
+	lastProgress, interval := time.Now(), opts.ProgressInterval
+	if interval <= 0 {
+		interval = defaultProgressInterval
+	}
+
+	// remember the lines of IDs (overall) and their indexes (within the batch)
+	lines := map[string]int64{}
+	indexes := map[string]int{}
+
+	// fail reports an error and drains the channel to not block the parser
+	fail := func(err error) {
+		_ = b.wait()
+		result <- &ImportItemsResult{ItemType: itemType, Error: err}
+		for ok := true; ok; {
+			_, ok = items.Recv()
+		}
+	}
+
+	// successively read all items from the channel
+	for {
+		item, ok := items.Recv()
+		if !ok {
+			break
+		}
+
+		// stop (discarding the remaining items) once out of rows or time
+		if (opts.MaxRows > 0 && itemCount == opts.MaxRows) || (!opts.deadline.IsZero() && time.Now().After(opts.deadline)) {
+			truncated = true
+			stop()
+			for ok := true; ok; {
+				_, ok = items.Recv()
+			}
+			break
+		}
+
+		// Count the item (the first item is in line 2, following the header)
+		itemCount++
+		line := malformed.line(itemCount)
+
+		// report the progress (checking the time every 1000 items only)
+		if progress != nil && itemCount%1000 == 0 && time.Since(lastProgress) >= interval {
+			progress(itemCount)
+			lastProgress = time.Now()
+		}
+
+		// normalize the item (before considering its key)
+		var changes []string
+		if profile != nil {
+			changes = profile.normalize(item)
+		}
+		if opts.FillRouteNames {
+			changes = append(changes, fillRouteNames(item)...)
+		}
+		if len(changes) > 0 {
+			normalized++
+			if record != nil {
+				record(line, RejectNormalized, strings.Join(changes, ", "))
+			}
+		}
+
+		// handle items repeating an ID
+		if key, ok := itemKey(itemType, item); ok {
+			if first, seen := lines[key]; seen {
+				duplicates++
+				switch conflicts {
+				case ConflictSkip:
+					if record != nil {
+						record(line, RejectSkipped, fmt.Sprintf("duplicate %s '%s' (first in line %d)", keyName(itemType), key, first))
+					}
+					continue
+				case ConflictReplace:
+					if record != nil {
+						record(line, RejectReplaced, fmt.Sprintf("duplicate %s '%s' (first in line %d)", keyName(itemType), key, first))
+					}
+					if i, ok := indexes[key]; ok {
+						b.set(i, item, line)
+						continue
+					}
+				default:
+					fail(&RowParseError{ItemType: itemType, Line: line, Err: fmt.Errorf("duplicate %s '%s' in line %d (first in line %d)", keyName(itemType), key, line, first)})
+					return
+				}
+			} else {
+				lines[key] = line
+			}
+			indexes[key] = b.len()
+		}
+
+		// add item to batch (persisting the batch if "full")
+		if err := b.add(item, line); err != nil {
+			fail(err)
+			return
+		}
+		if b.len() == 0 {
+			indexes = map[string]int{}
+		}
+	}
+
+	// persist any incomplete batch (and wait for the batches being inserted)
+	err := b.flush()
+	if waitErr := b.wait(); err == nil {
+		err = waitErr
+	}
+	if err != nil {
+		result <- &ImportItemsResult{ItemType: itemType, Error: err}
+		return
+	}
+
+	// return the counts
+	result <- &ImportItemsResult{
+		ItemType:   itemType,
+		Count:      itemCount,
+		Batches:    b.batches,
+		Duplicates: duplicates,
+		Normalized: normalized,
+		Rejected:   b.rejected,
+		Rejections: b.rejections,
+		Truncated:  truncated,
+	}
+}
+
+// replaceItems replaces the items of type T (i.e. of an optional file not
+// imported by Import) with the items of the file name within fsys and returns
+// the number of items imported.
+func replaceItems[T any](db *gorm.DB, fsys fs.FS, name string) (int64, error) {
+	if res := db.Where("1 = 1").Delete(new(T)); res.Error != nil {
+		return 0, res.Error
+	}
+	var batch []*T
+	var insertErr error
+	insert := func() {
+		if len(batch) > 0 && insertErr == nil {
+			insertErr = db.Create(batch).Error
+		}
+		batch = batch[:0]
+	}
+	n, err := scanItems(fsys, name, func(_ int64, item *T) {
+		batch = append(batch, item)
+		if len(batch) == batchSize {
+			insert()
+		}
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse '%s': %w", name, err)
+	}
+	insert()
+	return n, insertErr
+}
@@ -0,0 +1,806 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestImport(t *testing.T) {
+	db := newTestDB(t)
+
+	progress := make(chan *gtfs.ImportItemsResult)
+	go gtfs.Import(db, fixtureFeed, progress)
+	counts := map[gtfs.ItemType]int64{}
+	var skipped []gtfs.ItemType
+	for r := range progress {
+		if r.Error != nil {
+			t.Errorf("Import() %s error = %v", r.ItemType, r.Error)
+		}
+		if r.Skipped {
+			skipped = append(skipped, r.ItemType)
+		}
+		counts[r.ItemType] = r.Count
+	}
+	if counts[gtfs.StopTimes] != 15 {
+		t.Errorf("Import() stop times = %d, want 15", counts[gtfs.StopTimes])
+	}
+	if len(skipped) != 1 || skipped[0] != gtfs.Frequencies {
+		t.Errorf("Import() skipped = %v, want [%v]", skipped, gtfs.Frequencies)
+	}
+
+	var stopTime gtfs.StopTime
+	db.First(&stopTime, "trip_id = ? AND stop_seq = ?", "T1", 2)
+	if stopTime.StopID != "S2" || stopTime.Arrival.Int32 != 8*3600+3*60 {
+		t.Errorf("Import() stop time = %v", stopTime)
+	}
+}
+
+func TestImportWithOptions(t *testing.T) {
+	db := newTestDB(t)
+
+	var events []gtfs.ImportEvent
+	gtfs.ImportWithOptions(db, "_fixture/missing", gtfs.ImportOptions{
+		OnProgress: func(e gtfs.ImportEvent) {
+			events = append(events, e)
+		},
+	})
+	if len(events) == 0 || events[0].Result.ItemType != gtfs.Agencies || events[0].Result.Error == nil {
+		t.Errorf("ImportWithOptions() events = %v, want an error importing agencies first", events)
+	}
+
+	// passing a nil channel is fine
+	gtfs.Import(db, fixtureFeed, nil)
+	var count int64
+	db.Model(&gtfs.Agency{}).Count(&count)
+	if count != 2 {
+		t.Errorf("Import() agencies = %d, want 2", count)
+	}
+}
+
+func TestImportFS(t *testing.T) {
+
+	// the fixture held in memory
+	fsys := fstest.MapFS{}
+	entries, err := os.ReadDir(fixtureFeed)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(path.Join(fixtureFeed, e.Name()))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		fsys[e.Name()] = &fstest.MapFile{Data: b}
+	}
+
+	db := newTestDB(t)
+	progress := make(chan *gtfs.ImportItemsResult)
+	go gtfs.ImportFS(db, fsys, progress)
+	counts := map[gtfs.ItemType]int64{}
+	for r := range progress {
+		if r.Error != nil {
+			t.Errorf("ImportFS() %s error = %v", r.ItemType, r.Error)
+		}
+		if r.ItemType == gtfs.Stops && r.Path != "stops.txt" {
+			t.Errorf("ImportFS() path = %s, want stops.txt", r.Path)
+		}
+		counts[r.ItemType] = r.Count
+	}
+	if counts[gtfs.StopTimes] != 15 || counts[gtfs.Stops] != 7 {
+		t.Errorf("ImportFS() counts = %v, want 15 stop times and 7 stops", counts)
+	}
+}
+
+func TestImportWithOptions_Conflicts(t *testing.T) {
+
+	// a feed repeating trip T1 (with a different headsign) in line 6
+	feed := t.TempDir()
+	for _, name := range []string{"agency.txt", "routes.txt", "trips.txt", "stops.txt", "stop_times.txt", "shapes.txt", "calendar.txt", "calendar_dates.txt"} {
+		b, err := os.ReadFile(path.Join(fixtureFeed, name))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		if name == "trips.txt" {
+			b = append(b, []byte("R1,WD,T1,Elsewhere,,0,SH1\n")...)
+		}
+		if err = os.WriteFile(path.Join(feed, name), b, 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		conflicts    gtfs.ConflictStrategy
+		wantErr      string
+		wantHeadsign string
+	}{
+		{gtfs.ConflictError, "duplicate ID 'T1' in line 6 (first in line 2)", ""},
+		{gtfs.ConflictSkip, "", "S Rathaus Steglitz"},
+		{gtfs.ConflictReplace, "", "Elsewhere"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflicts.String(), func(t *testing.T) {
+			db := newTestDB(t)
+			var result *gtfs.ImportItemsResult
+			gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.ItemType == gtfs.Trips {
+						result = e.Result
+					}
+				},
+				Conflicts: map[gtfs.ItemType]gtfs.ConflictStrategy{gtfs.Trips: tt.conflicts},
+			})
+			if tt.wantErr != "" {
+				if result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+					t.Errorf("ImportWithOptions() error = %v, want %q", result.Error, tt.wantErr)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("ImportWithOptions() error = %v", result.Error)
+			}
+			if result.Duplicates != 1 {
+				t.Errorf("ImportWithOptions() duplicates = %d, want 1", result.Duplicates)
+			}
+			var trips []gtfs.Trip
+			db.Find(&trips)
+			if len(trips) != 4 {
+				t.Errorf("ImportWithOptions() trips = %d, want 4", len(trips))
+			}
+			var trip gtfs.Trip
+			db.First(&trip, "id = ?", "T1")
+			if trip.Headsign != tt.wantHeadsign {
+				t.Errorf("ImportWithOptions() headsign = %q, want %q", trip.Headsign, tt.wantHeadsign)
+			}
+		})
+	}
+}
+
+func TestImportWithOptions_RolledBack(t *testing.T) {
+
+	// a feed with a trip failing to parse (following valid trips) in line 6
+	feed := t.TempDir()
+	for _, name := range []string{"agency.txt", "routes.txt", "trips.txt", "stops.txt", "stop_times.txt", "shapes.txt", "calendar.txt", "calendar_dates.txt"} {
+		b, err := os.ReadFile(path.Join(fixtureFeed, name))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		if name == "trips.txt" {
+			b = append(b, []byte("R1,WD,T9\n")...)
+		}
+		if err = os.WriteFile(path.Join(feed, name), b, 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name           string
+		noTransactions bool
+		wantTrips      int64
+	}{
+		{"rolled back", false, 0},
+		{"without transactions", true, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			results := map[gtfs.ItemType]*gtfs.ImportItemsResult{}
+			gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					results[e.Result.ItemType] = e.Result
+				},
+				NoTransactions: tt.noTransactions,
+			})
+			if r := results[gtfs.Trips]; r.Error == nil || r.RolledBack == tt.noTransactions {
+				t.Errorf("ImportWithOptions() trips error = %v, rolled back = %v, want error and rolled back = %v", r.Error, r.RolledBack, !tt.noTransactions)
+			}
+			if r := results[gtfs.Routes]; r.Error != nil || r.RolledBack {
+				t.Errorf("ImportWithOptions() routes error = %v, rolled back = %v, want committed", r.Error, r.RolledBack)
+			}
+			var trips, routes int64
+			db.Model(&gtfs.Trip{}).Count(&trips)
+			db.Model(&gtfs.Route{}).Count(&routes)
+			if trips != tt.wantTrips || routes == 0 {
+				t.Errorf("ImportWithOptions() trips = %d, routes = %d, want %d trips and routes", trips, routes, tt.wantTrips)
+			}
+		})
+	}
+}
+
+func TestImportWithOptions_Limits(t *testing.T) {
+	tests := []struct {
+		name                   string
+		opts                   gtfs.ImportOptions
+		wantStopTimes          int64
+		wantTruncated          bool
+		wantCalendars          int64
+		wantCalendarsTruncated bool
+	}{
+		{"none", gtfs.ImportOptions{}, 15, false, 2, false},
+		{"max rows", gtfs.ImportOptions{MaxRows: 3}, 3, true, 2, false},
+		{"max duration", gtfs.ImportOptions{MaxDuration: time.Nanosecond}, 0, true, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			results := map[gtfs.ItemType]*gtfs.ImportItemsResult{}
+			tt.opts.OnProgress = func(e gtfs.ImportEvent) {
+				if e.Result.Error != nil {
+					t.Errorf("ImportWithOptions() %s error = %v", e.Result.ItemType, e.Result.Error)
+				}
+				results[e.Result.ItemType] = e.Result
+			}
+			gtfs.ImportWithOptions(db, fixtureFeed, tt.opts)
+
+			r := results[gtfs.StopTimes]
+			if r.Count != tt.wantStopTimes || r.Truncated != tt.wantTruncated {
+				t.Errorf("ImportWithOptions() stop times = %d (truncated %v), want %d (truncated %v)", r.Count, r.Truncated, tt.wantStopTimes, tt.wantTruncated)
+			}
+			var stopTimes int64
+			db.Model(&gtfs.StopTime{}).Count(&stopTimes)
+			if stopTimes != tt.wantStopTimes {
+				t.Errorf("ImportWithOptions() stop times in DB = %d, want %d", stopTimes, tt.wantStopTimes)
+			}
+			r = results[gtfs.Calendars]
+			if r.Count != tt.wantCalendars || r.Truncated != tt.wantCalendarsTruncated {
+				t.Errorf("ImportWithOptions() calendars = %d (truncated %v), want %d (truncated %v)", r.Count, r.Truncated, tt.wantCalendars, tt.wantCalendarsTruncated)
+			}
+		})
+	}
+}
+
+func TestNewImportOptions(t *testing.T) {
+	db := newTestDB(t)
+	results := map[gtfs.ItemType]*gtfs.ImportItemsResult{}
+	opts := gtfs.NewImportOptions(gtfs.WithBatchSize(4), gtfs.WithItemTypes(gtfs.Trips, gtfs.StopTimes))
+	opts.OnProgress = func(e gtfs.ImportEvent) {
+		if e.Result.Error != nil {
+			t.Errorf("ImportWithOptions() %s error = %v", e.Result.ItemType, e.Result.Error)
+		}
+		results[e.Result.ItemType] = e.Result
+	}
+	gtfs.ImportWithOptions(db, fixtureFeed, opts)
+
+	if r := results[gtfs.StopTimes]; r.Count != 15 || r.Batches != 4 {
+		t.Errorf("ImportWithOptions() stop times = %d in %d batches, want 15 in 4 batches", r.Count, r.Batches)
+	}
+	if r := results[gtfs.Stops]; !r.Skipped || !r.Excluded {
+		t.Errorf("ImportWithOptions() stops = %v, want excluded", r)
+	}
+	var trips, stops int64
+	db.Model(&gtfs.Trip{}).Count(&trips)
+	db.Model(&gtfs.Stop{}).Count(&stops)
+	if trips != 4 || stops != 0 {
+		t.Errorf("ImportWithOptions() trips = %d, stops = %d, want 4 trips and no stops", trips, stops)
+	}
+}
+
+// slowFS is a file system opening a given file slowly.
+type slowFS struct {
+	fs.FS
+	slow string
+}
+
+// Open opens the named file (delaying the slow file).
+func (sf slowFS) Open(name string) (fs.File, error) {
+	if name == sf.slow {
+		time.Sleep(100 * time.Millisecond)
+	}
+	return sf.FS.Open(name)
+}
+
+func TestImportFSWithOptions_Workers(t *testing.T) {
+	db := newTestDB(t)
+	counts := map[gtfs.ItemType]int64{}
+	opts := gtfs.NewImportOptions(gtfs.WithWorkers(4))
+	opts.OnProgress = func(e gtfs.ImportEvent) {
+		if e.Result.Error != nil {
+			t.Errorf("ImportWithOptions() %s error = %v", e.Result.ItemType, e.Result.Error)
+		}
+		counts[e.Result.ItemType] = e.Result.Count
+	}
+	gtfs.ImportFSWithOptions(db, slowFS{FS: os.DirFS(fixtureFeed), slow: "routes.txt"}, opts)
+
+	if len(counts) != 9 || counts[gtfs.StopTimes] != 15 || counts[gtfs.Stops] != 7 {
+		t.Errorf("ImportWithOptions() counts = %v, want 15 stop times and 7 stops", counts)
+	}
+
+	// stops wait for routes and trips (indexing route names and headsigns)
+	hits, err := gtfs.Search(db, "wannsee")
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+	var routes int
+	for _, h := range hits {
+		if h.Type == gtfs.RouteHit {
+			routes++
+		}
+	}
+	if routes != 2 {
+		t.Errorf("Search() = %v, want routes R1 and R2", hits)
+	}
+}
+
+func TestImportWithOptions_InsertWorkers(t *testing.T) {
+	db := newTestDB(t)
+	results := map[gtfs.ItemType]*gtfs.ImportItemsResult{}
+	opts := gtfs.NewImportOptions(gtfs.WithBatchSize(2), gtfs.WithInsertWorkers(3))
+	opts.OnProgress = func(e gtfs.ImportEvent) {
+		if e.Result.Error != nil {
+			t.Errorf("ImportWithOptions() %s error = %v", e.Result.ItemType, e.Result.Error)
+		}
+		results[e.Result.ItemType] = e.Result
+	}
+	gtfs.ImportWithOptions(db, fixtureFeed, opts)
+
+	if r := results[gtfs.StopTimes]; r.Count != 15 || r.Batches != 8 {
+		t.Errorf("ImportWithOptions() stop times = %d in %d batches, want 15 in 8 batches", r.Count, r.Batches)
+	}
+	var stopTimes int64
+	db.Model(&gtfs.StopTime{}).Count(&stopTimes)
+	if stopTimes != 15 {
+		t.Errorf("ImportWithOptions() inserted %d stop times, want 15", stopTimes)
+	}
+}
+
+func TestImportWithOptions_Hooks(t *testing.T) {
+	db := newTestDB(t)
+	var prepared []gtfs.ItemType
+	var results []*gtfs.ImportItemsResult
+	gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+		BeforeTable: func(tx *gorm.DB, itemType gtfs.ItemType) error {
+			prepared = append(prepared, itemType)
+			if itemType == gtfs.Shapes {
+				return errors.New("not today")
+			}
+			return nil
+		},
+		AfterTable: func(tx *gorm.DB, r *gtfs.ImportItemsResult) error {
+			if r.ItemType != gtfs.Routes {
+				return nil
+			}
+			return tx.Exec("CREATE TABLE route_names AS SELECT id, short_name FROM routes").Error
+		},
+		AfterImport: func(db *gorm.DB, r []*gtfs.ImportItemsResult) {
+			results = r
+		},
+	})
+
+	// frequencies are not present (thus not prepared)
+	if len(prepared) != 8 {
+		t.Errorf("BeforeTable() called for %v, want all but frequencies", prepared)
+	}
+	if len(results) != 9 {
+		t.Fatalf("AfterImport() results = %v, want 9", results)
+	}
+	for _, r := range results {
+		if r.ItemType == gtfs.Shapes && (r.Error == nil || !r.RolledBack) {
+			t.Errorf("ImportWithOptions() shapes = %v, want failed", r)
+		}
+	}
+	var shapes, routeNames int64
+	db.Model(&gtfs.Shape{}).Count(&shapes)
+	db.Table("route_names").Count(&routeNames)
+	if shapes != 0 || routeNames != 2 {
+		t.Errorf("ImportWithOptions() shapes = %d, route names = %d, want no shapes and 2 route names", shapes, routeNames)
+	}
+}
+
+func TestImportWithOptions_NaturalKeys(t *testing.T) {
+	tests := []struct {
+		conflicts gtfs.ConflictStrategy
+		wantErr   bool
+	}{
+		{gtfs.ConflictError, true},
+		{gtfs.ConflictSkip, false},
+		{gtfs.ConflictReplace, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.conflicts.String(), func(t *testing.T) {
+			db := newFixtureDB(t)
+			var want int64
+			db.Model(&gtfs.Shape{}).Count(&want)
+
+			// importing the shapes again must not double them
+			var result *gtfs.ImportItemsResult
+			gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.ItemType == gtfs.Shapes {
+						result = e.Result
+					}
+				},
+				Conflicts: map[gtfs.ItemType]gtfs.ConflictStrategy{gtfs.Shapes: tt.conflicts},
+			})
+			if (result.Error != nil) != tt.wantErr {
+				t.Errorf("ImportWithOptions() error = %v, wantErr %t", result.Error, tt.wantErr)
+			}
+			var got int64
+			db.Model(&gtfs.Shape{}).Count(&got)
+			if got != want {
+				t.Errorf("ImportWithOptions() shapes = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestParseConflictStrategy(t *testing.T) {
+	for _, cs := range []gtfs.ConflictStrategy{gtfs.ConflictError, gtfs.ConflictSkip, gtfs.ConflictReplace} {
+		got, err := gtfs.ParseConflictStrategy(cs.String())
+		if err != nil || got != cs {
+			t.Errorf("ParseConflictStrategy(%q) = %v, %v, want %v", cs.String(), got, err, cs)
+		}
+	}
+	if _, err := gtfs.ParseConflictStrategy("ignore"); err == nil {
+		t.Errorf("ParseConflictStrategy() expected error")
+	}
+}
+
+func TestImportWithOptions_SkipFailedRows(t *testing.T) {
+	tests := []struct {
+		skipFailedRows bool
+		wantErr        string
+		wantAgencies   int64
+	}{
+		{false, "failed to insert lines 2-3", 1},
+		{true, "", 2},
+	}
+	for _, tt := range tests {
+		db := newTestDB(t)
+
+		// agency 1 conflicts with the agency in line 2
+		db.Create(&gtfs.Agency{ID: "1", Name: "Existing"})
+
+		var result *gtfs.ImportItemsResult
+		gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+			OnProgress: func(e gtfs.ImportEvent) {
+				if e.Result.ItemType == gtfs.Agencies {
+					result = e.Result
+				}
+			},
+			SkipFailedRows: tt.skipFailedRows,
+		})
+		if tt.wantErr != "" {
+			if result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+				t.Errorf("ImportWithOptions() error = %v, want %q", result.Error, tt.wantErr)
+			}
+		} else {
+			if result.Error != nil {
+				t.Fatalf("ImportWithOptions() error = %v", result.Error)
+			}
+			if result.Rejected != 1 || len(result.Rejections) != 1 || result.Rejections[0].Line != 2 {
+				t.Errorf("ImportWithOptions() rejections = %v, want line 2", result.Rejections)
+			}
+		}
+
+		var count int64
+		db.Model(&gtfs.Agency{}).Count(&count)
+		if count != tt.wantAgencies {
+			t.Errorf("ImportWithOptions() agencies = %d, want %d", count, tt.wantAgencies)
+		}
+	}
+}
+
+func TestImportWithOptions_PrepareStmt(t *testing.T) {
+	want, err := gtfs.Fingerprint(newFixtureDB(t))
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	db := newTestDB(t)
+	gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+		PrepareStmt: true,
+		OnProgress: func(e gtfs.ImportEvent) {
+			if e.Result.Error != nil {
+				t.Fatalf("ImportWithOptions() error = %v", e.Result.Error)
+			}
+		},
+	})
+	got, err := gtfs.Fingerprint(db)
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	if got != want {
+		t.Errorf("Fingerprint() = %s, want %s", got, want)
+	}
+}
+
+// BenchmarkImport_PrepareStmt compares importing a feed of 50,000 stop times
+// (via FastDecoder, so that inserting dominates) with and without prepared
+// statements, e.g.:
+//
+//	go test -run '^$' -bench BenchmarkImport_PrepareStmt
+func BenchmarkImport_PrepareStmt(b *testing.B) {
+
+	// the fixture feed, with trip T1 serving a stop 50,000 times
+	feed := b.TempDir()
+	entries, err := os.ReadDir(fixtureFeed)
+	if err != nil {
+		b.Fatalf("failed to read fixture: %v", err)
+	}
+	for _, e := range entries {
+		content, err := os.ReadFile(path.Join(fixtureFeed, e.Name()))
+		if err != nil {
+			b.Fatalf("failed to read fixture: %v", err)
+		}
+		if e.Name() == "stop_times.txt" {
+			var sb strings.Builder
+			sb.WriteString("trip_id,arrival_time,departure_time,stop_id,stop_sequence\n")
+			for i := 1; i <= 50000; i++ {
+				sb.WriteString(fmt.Sprintf("T1,08:00:00,08:00:00,S1,%d\n", i))
+			}
+			content = []byte(sb.String())
+		}
+		if err = os.WriteFile(path.Join(feed, e.Name()), content, 0o644); err != nil {
+			b.Fatalf("failed to write feed: %v", err)
+		}
+	}
+
+	for _, prepareStmt := range []bool{false, true} {
+		b.Run(fmt.Sprintf("PrepareStmt=%t", prepareStmt), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				db := newTestDB(b)
+				b.StartTimer()
+				gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{PrepareStmt: prepareStmt, Decoder: gtfs.FastDecoder{}})
+			}
+		})
+	}
+}
+
+func TestImportWithOptions_Logger(t *testing.T) {
+	db := newTestDB(t)
+	var buf bytes.Buffer
+	gtfs.ImportWithOptions(db, fixtureFeed, gtfs.NewImportOptions(gtfs.WithLogger(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+
+	levels := map[string]map[string]string{} // levels of messages per item type
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record struct {
+			Level    string `json:"level"`
+			Msg      string `json:"msg"`
+			ItemType string `json:"item_type"`
+			Rows     int64  `json:"rows"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("failed to parse %q: %v", line, err)
+		}
+		if levels[record.ItemType] == nil {
+			levels[record.ItemType] = map[string]string{}
+		}
+		levels[record.ItemType][record.Msg] = record.Level
+		if record.ItemType == gtfs.StopTimes.String() && record.Rows != 15 {
+			t.Errorf("ImportWithOptions() logged %q, want 15 rows", line)
+		}
+	}
+	if len(levels) != 9 {
+		t.Errorf("ImportWithOptions() logged %v, want 9 item types", levels)
+	}
+	if got := levels[gtfs.StopTimes.String()]["imported file"]; got != "INFO" {
+		t.Errorf("ImportWithOptions() logged imported stop times at %q, want INFO", got)
+	}
+	if got := levels[gtfs.Frequencies.String()]["skipped file"]; got != "DEBUG" {
+		t.Errorf("ImportWithOptions() logged skipped frequencies at %q, want DEBUG", got)
+	}
+}
+
+func TestImportWithOptions_Summary(t *testing.T) {
+	summary := gtfs.ImportWithOptions(newTestDB(t), fixtureFeed, gtfs.ImportOptions{})
+	if err := summary.Err(); err != nil || len(summary.Results) != 9 || summary.Rows == 0 {
+		t.Errorf("ImportWithOptions() = %v (error %v), want 9 results and no error", summary, err)
+	}
+
+	tests := []struct {
+		name     string
+		alter    map[string]func([]byte) []byte
+		existing *gtfs.Agency
+		check    func(err error) bool
+	}{
+		{"missing file", map[string]func([]byte) []byte{"stops.txt": func([]byte) []byte { return nil }}, nil, func(err error) bool {
+			var e *gtfs.FileMissingError
+			return errors.As(err, &e) && e.ItemType == gtfs.Stops && errors.Is(err, fs.ErrNotExist)
+		}},
+		{"corrupt row", map[string]func([]byte) []byte{"stop_times.txt": appendRow("T1,08:00:00,08:00:00,S1,x\n")}, nil, func(err error) bool {
+			var e *gtfs.RowParseError
+			return errors.As(err, &e) && e.ItemType == gtfs.StopTimes && e.Line == 17
+		}},
+		{"duplicate row", map[string]func([]byte) []byte{"trips.txt": appendRow("R1,WD,T1,Elsewhere,,0,SH1\n")}, nil, func(err error) bool {
+			var e *gtfs.RowParseError
+			return errors.As(err, &e) && strings.HasSuffix(e.Path, "trips.txt") && e.Line == 6
+		}},
+		{"DB error", nil, &gtfs.Agency{ID: "1", Name: "Existing"}, func(err error) bool {
+			var e *gtfs.DBError
+			return errors.As(err, &e) && e.Op == "insert lines 2-3"
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if tt.existing != nil {
+				db.Create(tt.existing)
+			}
+			summary := gtfs.ImportWithOptions(db, alteredFeed(t, tt.alter), gtfs.ImportOptions{})
+			if len(summary.Failed()) != 1 {
+				t.Fatalf("ImportWithOptions() failed = %v, want a single file", summary.Failed())
+			}
+			if err := summary.Err(); !tt.check(err) {
+				t.Errorf("ImportWithOptions() error = %v (%T)", err, summary.Failed()[0].Error)
+			}
+		})
+	}
+}
+
+// alteredFeed returns a copy of the fixture altered by the given function per
+// file (leaving out files the function returns nil for).
+func alteredFeed(t *testing.T, alter map[string]func([]byte) []byte) string {
+	feed := t.TempDir()
+	for _, name := range []string{"agency.txt", "routes.txt", "trips.txt", "stops.txt", "stop_times.txt", "shapes.txt", "calendar.txt", "calendar_dates.txt"} {
+		b, err := os.ReadFile(path.Join(fixtureFeed, name))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		if fn, ok := alter[name]; ok {
+			if b = fn(b); b == nil {
+				continue
+			}
+		}
+		if err = os.WriteFile(path.Join(feed, name), b, 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+	return feed
+}
+
+// appendRow returns a function appending the given row to a file.
+func appendRow(row string) func([]byte) []byte {
+	return func(b []byte) []byte {
+		return append(b, []byte(row)...)
+	}
+}
+
+func TestImportWithOptions_Mode(t *testing.T) {
+	corruptRow := map[string]func([]byte) []byte{"stop_times.txt": appendRow("T1,08:00:00,08:00:00,S1,x\n")}
+	missingColumn := map[string]func([]byte) []byte{"calendar_dates.txt": func(b []byte) []byte {
+		return bytes.Replace(b, []byte("exception_type"), []byte("exception"), 1)
+	}}
+
+	tests := []struct {
+		name          string
+		alter         map[string]func([]byte) []byte
+		mode          gtfs.ImportMode
+		wantErr       string
+		wantLine      int64
+		wantMalformed int64
+	}{
+		{"default corrupt row", corruptRow, gtfs.ImportDefault, "x", 17, 0},
+		{"default missing column", missingColumn, gtfs.ImportDefault, "", 0, 0},
+		{"strict corrupt row", corruptRow, gtfs.ImportStrict, "parse error on line 17, column 5: invalid stop_sequence 'x'", 17, 0},
+		{"strict missing column", missingColumn, gtfs.ImportStrict, "missing required column 'exception_type'", 1, 0},
+		{"lenient corrupt row", corruptRow, gtfs.ImportLenient, "", 0, 1},
+		{"lenient missing column", missingColumn, gtfs.ImportLenient, "", 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := gtfs.ImportWithOptions(newTestDB(t), alteredFeed(t, tt.alter), gtfs.ImportOptions{Mode: tt.mode})
+			if tt.wantErr != "" {
+				var e *gtfs.RowParseError
+				if err := summary.Err(); !errors.As(err, &e) || !strings.Contains(err.Error(), tt.wantErr) || e.Line != tt.wantLine {
+					t.Errorf("ImportWithOptions() error = %v, want %q in line %d", err, tt.wantErr, tt.wantLine)
+				}
+				return
+			}
+			if err := summary.Err(); err != nil {
+				t.Fatalf("ImportWithOptions() error = %v", err)
+			}
+			for _, r := range summary.Results {
+				if r.ItemType == gtfs.StopTimes && (r.Malformed != tt.wantMalformed || r.Count != 15) {
+					t.Errorf("ImportWithOptions() stop times = %d (%d malformed), want 15 (%d malformed)", r.Count, r.Malformed, tt.wantMalformed)
+				}
+			}
+		})
+	}
+}
+
+func TestImportWithOptions_ModeLines(t *testing.T) {
+
+	// trips holding a row lacking values in line 2 and repeating T1 in line 7
+	feed := alteredFeed(t, map[string]func([]byte) []byte{"trips.txt": func(b []byte) []byte {
+		i := bytes.IndexByte(b, '\n') + 1
+		b = append(b[:i:i], append([]byte("R1,WD\n"), b[i:]...)...)
+		return append(b, []byte("R1,WD,T1,Elsewhere,,0,SH1\n")...)
+	}})
+	db := newTestDB(t)
+	summary := gtfs.ImportWithOptions(db, feed, gtfs.NewImportOptions(gtfs.WithMode(gtfs.ImportLenient), func(opts *gtfs.ImportOptions) {
+		opts.Conflicts = map[gtfs.ItemType]gtfs.ConflictStrategy{gtfs.Trips: gtfs.ConflictSkip}
+		opts.RecordRejects = true
+	}))
+	if err := summary.Err(); err != nil {
+		t.Fatalf("ImportWithOptions() error = %v", err)
+	}
+	rejects, err := gtfs.ImportRejects(db)
+	if err != nil {
+		t.Fatalf("ImportRejects() error = %v", err)
+	}
+	var got []string
+	for _, r := range rejects {
+		got = append(got, fmt.Sprintf("%s:%d:%s", r.File, r.Line, r.Action))
+	}
+	if want := "[trips.txt:2:malformed trips.txt:7:skipped]"; fmt.Sprint(got) != want {
+		t.Errorf("ImportRejects() = %v, want %s", got, want)
+	}
+}
+
+func TestParseImportMode(t *testing.T) {
+	for _, m := range []gtfs.ImportMode{gtfs.ImportDefault, gtfs.ImportStrict, gtfs.ImportLenient} {
+		got, err := gtfs.ParseImportMode(m.String())
+		if err != nil || got != m {
+			t.Errorf("ParseImportMode(%q) = %v, %v, want %v", m.String(), got, err, m)
+		}
+	}
+	if _, err := gtfs.ParseImportMode("sloppy"); err == nil {
+		t.Errorf("ParseImportMode() expected error")
+	}
+}
+
+func TestImportWithOptions_RequiredFiles(t *testing.T) {
+	tests := []struct {
+		name         string
+		missing      []string
+		itemTypes    []gtfs.ItemType
+		wantCheckErr bool
+		wantMissing  []gtfs.ItemType
+	}{
+		{"complete", nil, nil, false, nil},
+		{"routes and trips missing", []string{"routes.txt", "trips.txt"}, nil, true, []gtfs.ItemType{gtfs.Routes, gtfs.Trips}},
+		{"unselected file missing", []string{"routes.txt"}, []gtfs.ItemType{gtfs.Stops}, true, nil},
+		{"unchecked file missing", []string{"shapes.txt"}, []gtfs.ItemType{gtfs.Stops}, false, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			alter := map[string]func([]byte) []byte{}
+			for _, name := range tt.missing {
+				alter[name] = func([]byte) []byte { return nil }
+			}
+			feed := alteredFeed(t, alter)
+
+			err := gtfs.CheckRequiredFiles(os.DirFS(feed))
+			if (err != nil) != tt.wantCheckErr || (err != nil && !errors.Is(err, gtfs.ErrRequiredFileMissing)) {
+				t.Errorf("CheckRequiredFiles() error = %v, want error %v", err, tt.wantCheckErr)
+			}
+
+			db := newTestDB(t)
+			summary := gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{ItemTypes: tt.itemTypes})
+			if len(tt.wantMissing) == 0 {
+				if err = summary.Err(); err != nil {
+					t.Errorf("ImportWithOptions() error = %v", err)
+				}
+				return
+			}
+
+			// nothing is imported, the files missing are reported
+			var missing []gtfs.ItemType
+			for _, r := range summary.Results {
+				missing = append(missing, r.ItemType)
+			}
+			if fmt.Sprint(missing) != fmt.Sprint(tt.wantMissing) || !errors.Is(summary.Err(), gtfs.ErrRequiredFileMissing) {
+				t.Errorf("ImportWithOptions() = %v (error %v), want %v missing", missing, summary.Err(), tt.wantMissing)
+			}
+			var stops int64
+			db.Model(&gtfs.Stop{}).Count(&stops)
+			if stops != 0 {
+				t.Errorf("ImportWithOptions() imported %d stops, want none", stops)
+			}
+			if err = (gtfs.DefaultImporter{}).Import(newTestDB(t), feed, gtfs.ImportOptions{}); !errors.Is(err, gtfs.ErrRequiredFileMissing) {
+				t.Errorf("DefaultImporter.Import() error = %v, want %v", err, gtfs.ErrRequiredFileMissing)
+			}
+		})
+	}
+}
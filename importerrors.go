@@ -0,0 +1,163 @@
+package gtfs
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// ErrRequiredFileMissing is returned (wrapped, e.g. as FileMissingError), if a
+// file required to import a feed is missing (see CheckRequiredFiles).
+var ErrRequiredFileMissing = errors.New("required file missing")
+
+// requiredFiles are the item types whose files are checked before importing
+// (see CheckRequiredFiles).
+var requiredFiles = []ItemType{Agencies, Stops, Routes, Trips, StopTimes}
+
+// FileMissingError is the error of importing a required file missing from the
+// feed (optional files missing are skipped, see ImportItemsResult.Skipped).
+type FileMissingError struct {
+	ItemType ItemType
+	Path     string
+	Err      error
+}
+
+// Error returns a human-readable representation of FileMissingError.
+func (e *FileMissingError) Error() string {
+	return fmt.Sprintf("failed to open '%s': %v", e.Path, e.Err)
+}
+
+// Unwrap returns the error of opening the file.
+func (e *FileMissingError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is ErrRequiredFileMissing.
+func (e *FileMissingError) Is(target error) bool {
+	return target == ErrRequiredFileMissing
+}
+
+// CheckRequiredFiles checks that the files required to import a feed (i.e.
+// agency.txt, stops.txt, routes.txt, trips.txt and stop_times.txt) are present
+// in the root of fsys, returning a FileMissingError (see
+// ErrRequiredFileMissing) per file missing (joined) or nil. Imports check the
+// files before importing any of them (see ImportWithOptions).
+func CheckRequiredFiles(fsys fs.FS) error {
+	var errs []error
+	for _, r := range missingFiles(fsys, ".", "", ImportOptions{}) {
+		errs = append(errs, r.Error)
+	}
+	return errors.Join(errs...)
+}
+
+// missingFiles returns the results of the required files (of the item types
+// selected by opts) missing from the directory dir of fsys (reported relative
+// to base).
+func missingFiles(fsys fs.FS, dir, base string, opts ImportOptions) []*ImportItemsResult {
+	var missing []*ImportItemsResult
+	for _, itemType := range requiredFiles {
+		name := path.Join(dir, itemFiles[itemType])
+		if _, err := fs.Stat(fsys, name); opts.selected(itemType) && errors.Is(err, fs.ErrNotExist) {
+			csvPath := path.Join(base, name)
+			missing = append(missing, &ImportItemsResult{ItemType: itemType, Path: csvPath, Error: &FileMissingError{ItemType: itemType, Path: csvPath, Err: err}})
+		}
+	}
+	return missing
+}
+
+// RowParseError is the error of importing a file holding a row failing to
+// parse (or repeating the ID of another row, see ConflictError).
+type RowParseError struct {
+	ItemType ItemType
+	Path     string
+	Line     int64 // the line of the row (0, if unknown)
+	Err      error
+}
+
+// Error returns a human-readable representation of RowParseError.
+func (e *RowParseError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of parsing the row.
+func (e *RowParseError) Unwrap() error {
+	return e.Err
+}
+
+// DBError is the error of the DB failing to execute an operation of an import
+// (e.g. inserting a batch or committing).
+type DBError struct {
+	Op  string // the operation failed, e.g. "insert lines 2-3"
+	Err error
+}
+
+// Error returns a human-readable representation of DBError.
+func (e *DBError) Error() string {
+	return fmt.Sprintf("failed to %s: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the error of the DB.
+func (e *DBError) Unwrap() error {
+	return e.Err
+}
+
+// newRowParseError returns the error of decoding a file as RowParseError
+// (taking the line from CSV parse errors).
+func newRowParseError(itemType ItemType, path string, err error) *RowParseError {
+	e := &RowParseError{ItemType: itemType, Path: path, Err: err}
+	var pe *csv.ParseError
+	if errors.As(err, &pe) {
+		e.Line = int64(pe.Line)
+	}
+	return e
+}
+
+// ImportSummary summarizes an import (see ImportWithOptions).
+type ImportSummary struct {
+
+	// Results are the results of importing each of the item types (in the
+	// order of importing them).
+	Results []*ImportItemsResult
+
+	// Rows is the number of rows imported (of all files).
+	Rows int64
+
+	// Time is the time the import took.
+	Time time.Duration
+}
+
+// Failed returns the results of the files failing to import.
+func (s *ImportSummary) Failed() []*ImportItemsResult {
+	var failed []*ImportItemsResult
+	for _, r := range s.Results {
+		if r.Error != nil {
+			failed = append(failed, r)
+		}
+	}
+	return failed
+}
+
+// SkippedRows returns (up to 100 per file of) the rows failed to parse and
+// skipped (see ImportLenient) of all files, along with their raw content.
+func (s *ImportSummary) SkippedRows() []SkippedRow {
+	var rows []SkippedRow
+	for _, r := range s.Results {
+		rows = append(rows, r.SkippedRows...)
+	}
+	return rows
+}
+
+// Err returns the errors of the files failing to import (joined, each
+// prefixed by the path of the file) or nil, if all files are imported. The
+// typed errors (i.e. FileMissingError, RowParseError and DBError) are found
+// by errors.As.
+func (s *ImportSummary) Err() error {
+	var errs []error
+	for _, r := range s.Failed() {
+		errs = append(errs, fmt.Errorf("%s: %w", r.Path, r.Error))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,303 @@
+package gtfs
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ImportMode enumerates the ways of handling malformed rows and files (see
+// ImportOptions.Mode).
+type ImportMode uint32
+
+const (
+
+	// ImportDefault fails the import of a file on the first row failing to
+	// parse (as reported by the decoder, see ImportOptions.Decoder), but
+	// accepts files lacking required columns.
+	ImportDefault ImportMode = iota
+
+	// ImportStrict fails the import of a file lacking a required column (see
+	// RequiredColumns) or holding a row failing to parse (reporting the line,
+	// the column and the value of the row, see RowParseError).
+	ImportStrict
+
+	// ImportLenient skips the rows failing to parse (see
+	// ImportItemsResult.Malformed) and accepts files lacking required columns.
+	ImportLenient
+)
+
+var txImportMode = map[ImportMode]string{
+	ImportDefault: "default",
+	ImportStrict:  "strict",
+	ImportLenient: "lenient",
+}
+
+// String returns a human-readable representation of ImportMode.
+func (m ImportMode) String() string {
+	if s := txImportMode[m]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ImportMode (%d)", uint32(m))
+}
+
+// ParseImportMode returns the ImportMode with the given name (i.e. "default",
+// "strict" or "lenient").
+func ParseImportMode(s string) (ImportMode, error) {
+	for m, name := range txImportMode {
+		if name == s {
+			return m, nil
+		}
+	}
+	return ImportDefault, fmt.Errorf("unknown import mode '%s'", s)
+}
+
+// requiredColumns maps item types to the columns required by the GTFS
+// reference (leaving out conditionally required columns).
+var requiredColumns = map[ItemType][]string{
+	Agencies:      {"agency_name", "agency_url", "agency_timezone"},
+	Routes:        {"route_id", "route_type"},
+	Trips:         {"route_id", "service_id", "trip_id"},
+	Stops:         {"stop_id"},
+	StopTimes:     {"trip_id", "stop_id", "stop_sequence"},
+	Shapes:        {"shape_id", "shape_pt_lat", "shape_pt_lon", "shape_pt_sequence"},
+	Calendars:     {"service_id", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday", "start_date", "end_date"},
+	CalendarDates: {"service_id", "date", "exception_type"},
+	Frequencies:   {"trip_id", "start_time", "end_time", "headway_secs"},
+}
+
+// RequiredColumns returns the columns the files of items of the given type
+// must hold (see ImportStrict).
+func RequiredColumns(itemType ItemType) []string {
+	return append([]string(nil), requiredColumns[itemType]...)
+}
+
+// malformedRows collects the rows skipped (see ImportLenient), shared by the
+// goroutine checking rows and the one inserting them.
+type malformedRows struct {
+	mu    sync.Mutex
+	lines []int64      // the lines of the rows skipped (ascending)
+	errs  []error      // the errors of (up to maxRejections of) the rows skipped
+	rows  []SkippedRow // (up to maxRejections of) the rows skipped
+	next  int          // the index of the next line not yet passed (see line)
+}
+
+// add adds a row skipped (given by its raw content).
+func (m *malformedRows) add(file string, line int64, raw string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lines = append(m.lines, line)
+	if len(m.errs) < maxRejections {
+		m.errs = append(m.errs, err)
+		m.rows = append(m.rows, SkippedRow{File: file, Line: line, Raw: raw, Error: err.Error()})
+	}
+}
+
+// line returns the line of the n-th row (starting at 1) passed on to the
+// decoder (i.e. not skipped). Calls must pass ascending n.
+func (m *malformedRows) line(n int64) int64 {
+	if m == nil {
+		return n + 1
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	line := n + 1 + int64(m.next)
+	for m.next < len(m.lines) && m.lines[m.next] <= line {
+		m.next++
+		line++
+	}
+	return line
+}
+
+// count returns the number of rows skipped so far.
+func (m *malformedRows) count() int64 {
+	if m == nil {
+		return 0
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return int64(len(m.lines))
+}
+
+// rejections returns (up to maxRejections of) the rows skipped so far.
+func (m *malformedRows) rejections() []Rejection {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rejections := make([]Rejection, len(m.errs))
+	for i, err := range m.errs {
+		rejections[i] = Rejection{Line: m.lines[i], Error: err}
+	}
+	return rejections
+}
+
+// skippedRows returns (up to maxRejections of) the rows skipped so far.
+func (m *malformedRows) skippedRows() []SkippedRow {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]SkippedRow(nil), m.rows...)
+}
+
+// checkRows returns a reader reading the (comma delimited, see normalizeCSV)
+// CSV file of items of the given type (named file) from r, checking the header
+// and the rows according to mode (see ImportStrict and ImportLenient), along
+// with the rows skipped (nil, unless lenient) and a function to be called when
+// done reading. If onSkip is not nil, it is called (by a single goroutine) for
+// each row skipped. Files are passed on as they are in ImportDefault mode.
+func checkRows(r io.Reader, file string, itemType ItemType, model reflect.Type, mode ImportMode, onSkip func(line int64, raw string, err error)) (io.Reader, *malformedRows, func()) {
+	if mode != ImportStrict && mode != ImportLenient {
+		return r, nil, func() {}
+	}
+	var malformed *malformedRows
+	if mode == ImportLenient {
+		malformed = &malformedRows{}
+	}
+
+	// check the file (reporting errors to the reader)
+	pr, pw := io.Pipe()
+	src := &rawReader{r: r}
+	go func() {
+		cr := csv.NewReader(bufio.NewReader(src))
+		cw := csv.NewWriter(pw)
+		header, err := cr.Read()
+		if err == io.EOF {
+			_ = pw.Close()
+			return
+		}
+		if err != nil {
+			_ = pw.CloseWithError(err)
+			return
+		}
+
+		// map columns to fields (ignoring unknown columns)
+		fields := fieldsOf(model)
+		columns := make([]*decodeField, len(header))
+		names := make([]string, len(header))
+		present := map[string]bool{}
+		for i, name := range header {
+			name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+			names[i] = name
+			present[name] = true
+			if f, ok := fields[name]; ok {
+				columns[i] = &f
+			}
+		}
+		if mode == ImportStrict {
+			for _, name := range requiredColumns[itemType] {
+				if !present[name] {
+					_ = pw.CloseWithError(&csv.ParseError{StartLine: 1, Line: 1, Err: fmt.Errorf("missing required column '%s'", name)})
+					return
+				}
+			}
+		}
+		if err = cw.Write(header); err != nil {
+			_ = pw.CloseWithError(err)
+			return
+		}
+
+		// pass on the rows parsing (skipping or failing on others)
+		item := reflect.New(model).Elem()
+		for line := int64(2); ; line++ {
+			from := cr.InputOffset()
+			row, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			var pe *csv.ParseError
+			if errors.As(err, &pe) && src.err == nil {
+				err = &csv.ParseError{StartLine: int(line), Line: int(line), Column: pe.Column, Err: pe.Err}
+			} else if err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			} else {
+				err = checkRow(item, columns, names, row, line)
+			}
+			if err != nil {
+				if malformed == nil {
+					_ = pw.CloseWithError(err)
+					return
+				}
+				raw := src.row(from, cr.InputOffset())
+				malformed.add(file, line, raw, err)
+				if onSkip != nil {
+					onSkip(line, raw, err)
+				}
+				continue
+			}
+			src.discard(cr.InputOffset())
+			if err = cw.Write(row); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		cw.Flush()
+		_ = pw.CloseWithError(cw.Error())
+	}()
+	return pr, malformed, func() {
+		_ = pr.Close()
+	}
+}
+
+// checkRow returns an error (as csv.ParseError), if any value of the row
+// in the given line fails to parse into item (a model), naming the column
+// (see names).
+func checkRow(item reflect.Value, columns []*decodeField, names, row []string, line int64) error {
+	for i, value := range row {
+		if i >= len(columns) || columns[i] == nil {
+			continue
+		}
+		if err := setValue(item.Field(columns[i].index), columns[i], value); err != nil {
+			return &csv.ParseError{StartLine: int(line), Line: int(line), Column: i + 1, Err: fmt.Errorf("invalid %s '%s': %w", names[i], value, err)}
+		}
+	}
+	return nil
+}
+
+// rawReader is a reader keeping the bytes read from r (since the offset last
+// discarded), allowing to report the raw content of rows. It remembers the
+// error (other than io.EOF) of reading from r, telling the errors of reading
+// from r apart from rows failing to parse.
+type rawReader struct {
+	r    io.Reader
+	err  error
+	buf  []byte // the bytes read from offset off on
+	off  int64  // the offset of buf
+	keep int64  // the offset of the bytes to keep (see discard)
+}
+
+// Read reads from the underlying reader (dropping the bytes discarded).
+func (rr *rawReader) Read(p []byte) (int, error) {
+	if d := rr.keep - rr.off; d > 0 {
+		rr.buf = append(rr.buf[:0], rr.buf[d:]...)
+		rr.off = rr.keep
+	}
+	n, err := rr.r.Read(p)
+	rr.buf = append(rr.buf, p[:n]...)
+	if err != nil && err != io.EOF {
+		rr.err = err
+	}
+	return n, err
+}
+
+// row returns the bytes between the given offsets (without the line break),
+// discarding the bytes before to.
+func (rr *rawReader) row(from, to int64) string {
+	raw := string(rr.buf[from-rr.off : to-rr.off])
+	rr.discard(to)
+	return strings.TrimRight(raw, "\r\n")
+}
+
+// discard discards the bytes before the given offset.
+func (rr *rawReader) discard(offset int64) {
+	rr.keep = offset
+}
@@ -0,0 +1,340 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"reflect"
+	"sync"
+)
+
+// InserterOptions configures an Inserter.
+type InserterOptions struct {
+
+	// BatchSize is the number of items inserted at once (defaults to 1000).
+	BatchSize int
+
+	// Conflicts is the way of handling items conflicting with items already in
+	// the DB (on ConflictColumns, the primary key if empty). ConflictError
+	// fails the batch.
+	Conflicts ConflictStrategy
+
+	// ConflictColumns are the columns conflicts are detected on.
+	ConflictColumns []string
+
+	// SkipFailedRows (if true) retries batches failing to insert row by row,
+	// rejecting only the rows failing to insert (rather than failing the
+	// batch).
+	SkipFailedRows bool
+}
+
+// Inserter inserts items (models or pointers to models) into a DB in batches.
+// This is the batching used by ImportWithOptions, exposed for custom
+// importers. Errors are accumulated rather than aborting, i.e. a batch failing
+// to insert is dropped and the inserter continues with the next batch. An
+// Inserter is not safe for concurrent use.
+type Inserter[T any] struct {
+	b     *batcher
+	added int64
+	errs  []error
+}
+
+// NewInserter returns an Inserter inserting into db. Call Close when done to
+// insert any incomplete batch. db may be a transaction (e.g. within
+// db.Transaction), such that the items are inserted (and rolled back) along
+// with the caller's changes. Within a transaction, each batch (and each row
+// retried, see InserterOptions.SkipFailedRows) is inserted within a savepoint,
+// which is rolled back to if inserting fails, thus a failing batch doesn't
+// abort the transaction (as it would on Postgres).
+func NewInserter[T any](db *gorm.DB, opts InserterOptions) *Inserter[T] {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = batchSize
+	}
+	var columns []clause.Column
+	for _, c := range opts.ConflictColumns {
+		columns = append(columns, clause.Column{Name: c})
+	}
+	b := newBatcher(db, reflect.TypeOf((*T)(nil)).Elem(), opts.BatchSize, opts.Conflicts, columns, opts.SkipFailedRows)
+	b.unit = "items"
+	return &Inserter[T]{b: b}
+}
+
+// Add adds an item to the current batch and inserts the batch once it is
+// full. The returned error (if any) is the error of inserting the batch.
+func (in *Inserter[T]) Add(item T) error {
+	in.added++
+	if err := in.b.add(reflect.ValueOf(item), in.added); err != nil {
+		in.errs = append(in.errs, err)
+		return err
+	}
+	return nil
+}
+
+// Flush inserts the current (incomplete) batch.
+func (in *Inserter[T]) Flush() error {
+	if err := in.b.flush(); err != nil {
+		in.errs = append(in.errs, err)
+		return err
+	}
+	return nil
+}
+
+// Close inserts any incomplete batch and returns the errors accumulated (if
+// any) by Add, Flush and Close (the first of them, if there are several).
+func (in *Inserter[T]) Close() error {
+	_ = in.Flush()
+	switch len(in.errs) {
+	case 0:
+		return nil
+	case 1:
+		return in.errs[0]
+	default:
+		return fmt.Errorf("%d batches failed (first: %w)", len(in.errs), in.errs[0])
+	}
+}
+
+// Errors returns the errors accumulated so far.
+func (in *Inserter[T]) Errors() []error {
+	return in.errs
+}
+
+// Added returns the number of items added so far.
+func (in *Inserter[T]) Added() int64 {
+	return in.added
+}
+
+// Batches returns the number of batches inserted (or failed to insert) so far.
+func (in *Inserter[T]) Batches() int64 {
+	return in.b.batches
+}
+
+// Rejected returns the number of items rejected so far (see
+// InserterOptions.SkipFailedRows).
+func (in *Inserter[T]) Rejected() int64 {
+	return in.b.rejected
+}
+
+// Rejections returns (up to 100 of) the items rejected so far. The Line of a
+// Rejection is the position of the item (counting the items added from 1).
+func (in *Inserter[T]) Rejections() []Rejection {
+	return in.b.rejections
+}
+
+// batcher collects items of a given type (along with their lines) and inserts
+// them into a DB in batches.
+type batcher struct {
+	create         *gorm.DB
+	tx             *gorm.DB    // the transaction inserted into (if any, see savepoint)
+	txMu           *sync.Mutex // serializes inserting into tx (shared by the batchers of a file, see txLockKey)
+	size           int
+	skipFailedRows bool
+	unit           string                      // what lines are called in errors
+	onReject       func(line int64, err error) // called for each row rejected (if not nil)
+
+	sliceType  reflect.Type
+	batch      reflect.Value
+	lines      []int64
+	batches    int64
+	rejected   int64
+	rejections []Rejection
+
+	// batches are inserted by workers (if started, see startWorkers)
+	queue   chan pendingBatch
+	workers sync.WaitGroup
+	mu      sync.Mutex // guards rejected, rejections and err (once workers are started)
+	err     error      // the first error of the workers
+}
+
+// pendingBatch is a batch (along with the lines of its items) queued for
+// insertion by the workers of a batcher.
+type pendingBatch struct {
+	batch reflect.Value
+	lines []int64
+}
+
+// newBatcher returns a batcher inserting items of type itemType into db,
+// handling conflicts (on columns, the primary key if empty) with items already
+// in the DB according to conflicts.
+func newBatcher(db *gorm.DB, itemType reflect.Type, size int, conflicts ConflictStrategy, columns []clause.Column, skipFailedRows bool) *batcher {
+	create := db
+	switch conflicts {
+	case ConflictSkip:
+		create = db.Clauses(clause.OnConflict{Columns: columns, DoNothing: true}).Session(&gorm.Session{})
+	case ConflictReplace:
+		create = db.Clauses(clause.OnConflict{Columns: columns, UpdateAll: true}).Session(&gorm.Session{})
+	}
+	var tx *gorm.DB
+	var txMu *sync.Mutex
+	if committer, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok && committer != nil {
+		tx, txMu = db, new(sync.Mutex)
+		if mu, ok := db.Get(txLockKey); ok {
+			txMu = mu.(*sync.Mutex)
+		}
+	}
+	sliceType := reflect.SliceOf(itemType)
+	return &batcher{
+		create:         create,
+		tx:             tx,
+		txMu:           txMu,
+		size:           size,
+		skipFailedRows: skipFailedRows,
+		unit:           "lines",
+		sliceType:      sliceType,
+		batch:          reflect.MakeSlice(sliceType, 0, size),
+		lines:          make([]int64, 0, size),
+	}
+}
+
+// len returns the number of items in the current batch.
+func (b *batcher) len() int {
+	return b.batch.Len()
+}
+
+// add adds an item (in the given line) to the current batch and flushes the
+// batch once it is full.
+func (b *batcher) add(item reflect.Value, line int64) error {
+	b.batch = reflect.Append(b.batch, item)
+	b.lines = append(b.lines, line)
+	if b.batch.Len() >= b.size {
+		return b.flush()
+	}
+	return nil
+}
+
+// set replaces the i-th item of the current batch.
+func (b *batcher) set(i int, item reflect.Value, line int64) {
+	b.batch.Index(i).Set(item)
+	b.lines[i] = line
+}
+
+// startWorkers starts n goroutines inserting the batches flushed (rather than
+// inserting them synchronously), such that items are added while batches are
+// inserted. Batches are inserted in no particular order. Call wait, once done.
+func (b *batcher) startWorkers(n int) {
+	queue := make(chan pendingBatch, n)
+	b.queue = queue
+	for i := 0; i < n; i++ {
+		b.workers.Add(1)
+		go func() {
+			defer b.workers.Done()
+			for p := range queue {
+				if err := b.insert(p.batch, p.lines); err != nil {
+					b.mu.Lock()
+					if b.err == nil {
+						b.err = err
+					}
+					b.mu.Unlock()
+				}
+			}
+		}()
+	}
+}
+
+// wait waits for the workers (if started) to insert the batches flushed and
+// returns the first error of inserting a batch (if any).
+func (b *batcher) wait() error {
+	if b.queue == nil {
+		return nil
+	}
+	close(b.queue)
+	b.queue = nil
+	b.workers.Wait()
+	return b.err
+}
+
+// flush inserts the current batch (if any) and starts a new one. If the batch
+// fails to insert (and failed rows are not to be skipped), the batch is
+// dropped and the error is returned. Once workers are started, the batch is
+// queued for insertion instead and the error returned is the first error of
+// the workers (if any).
+func (b *batcher) flush() error {
+	if b.batch.Len() == 0 {
+		return nil
+	}
+	batch, lines := b.batch, b.lines
+	b.batch = reflect.MakeSlice(b.sliceType, 0, b.size)
+	b.lines = make([]int64, 0, b.size)
+	b.batches++
+
+	if b.queue != nil {
+		b.mu.Lock()
+		err := b.err
+		b.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		b.queue <- pendingBatch{batch: batch, lines: lines}
+		return nil
+	}
+	return b.insert(batch, lines)
+}
+
+// insert inserts a batch (see flush).
+func (b *batcher) insert(batch reflect.Value, lines []int64) error {
+	if b.txMu != nil {
+		b.txMu.Lock()
+		defer b.txMu.Unlock()
+	}
+
+	// insert the batch (via a pointer to the slice, allowing for setting IDs)
+	ptr := reflect.New(b.sliceType)
+	ptr.Elem().Set(batch)
+	err := b.savepoint(func() error {
+		return b.create.Create(ptr.Interface()).Error
+	})
+	if err != nil && !b.skipFailedRows {
+		return &DBError{Op: fmt.Sprintf("insert %s %d-%d", b.unit, lines[0], lines[len(lines)-1]), Err: err}
+	}
+
+	// retry row by row, rejecting the failing rows
+	if err != nil {
+		for i, line := range lines {
+			row := reflect.New(b.sliceType)
+			row.Elem().Set(batch.Slice(i, i+1))
+			err := b.savepoint(func() error {
+				return b.create.Create(row.Interface()).Error
+			})
+			if err != nil {
+				b.mu.Lock()
+				b.rejected++
+				if len(b.rejections) < maxRejections {
+					b.rejections = append(b.rejections, Rejection{Line: line, Error: err})
+				}
+				b.mu.Unlock()
+				if b.onReject != nil {
+					b.onReject(line, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// batchSavepoint is the name of the savepoint set before inserting into a
+// transaction (see savepoint).
+const batchSavepoint = "gtfs_batch"
+
+// txLockKey is the key of the mutex (set via gorm.DB.Set on a transaction)
+// shared by the batchers inserting into the transaction, such that no batcher
+// inserts between the savepoint of another batcher and rolling back to it.
+const txLockKey = "gtfs:batch_lock"
+
+// savepoint calls insert within a savepoint of the transaction inserted into
+// (if any), rolling back to the savepoint if insert fails. DBs like Postgres
+// abort a transaction on the first failing statement, such that retrying a
+// failed batch row by row (or inserting the next batch) would fail otherwise.
+func (b *batcher) savepoint(insert func() error) error {
+	if b.tx == nil {
+		return insert()
+	}
+	if err := b.tx.SavePoint(batchSavepoint).Error; err != nil {
+		return err
+	}
+	if err := insert(); err != nil {
+		if errRollback := b.tx.RollbackTo(batchSavepoint).Error; errRollback != nil {
+			return fmt.Errorf("failed to roll back (%v): %w", errRollback, err)
+		}
+		return err
+	}
+	return b.tx.Exec("RELEASE SAVEPOINT " + batchSavepoint).Error
+}
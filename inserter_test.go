@@ -0,0 +1,131 @@
+package gtfs_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestInserter(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           gtfs.InserterOptions
+		wantErr        bool
+		wantErrors     int
+		wantBatches    int64
+		wantRejected   int64
+		wantStops      int64
+		wantRejections []int64
+	}{
+		{"error", gtfs.InserterOptions{BatchSize: 2}, true, 1, 3, 0, 4, nil},
+		{"skip failed rows", gtfs.InserterOptions{BatchSize: 2, SkipFailedRows: true}, false, 0, 3, 1, 5, []int64{3}},
+		{"skip conflicts", gtfs.InserterOptions{BatchSize: 2, Conflicts: gtfs.ConflictSkip}, false, 0, 3, 0, 5, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+
+			// stop S0 conflicts with the third item (dropping the second batch on error)
+			db.Create(&gtfs.Stop{ID: "S0", Name: "Existing"})
+
+			in := gtfs.NewInserter[*gtfs.Stop](db, tt.opts)
+			for _, id := range []string{"S1", "S2", "S0", "S3", "S4"} {
+				_ = in.Add(&gtfs.Stop{ID: id, Name: fmt.Sprintf("Stop %s", id)})
+			}
+			if err := in.Close(); (err != nil) != tt.wantErr {
+				t.Fatalf("Close() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := len(in.Errors()); got != tt.wantErrors {
+				t.Errorf("Errors() = %d, want %d", got, tt.wantErrors)
+			}
+			if got := in.Added(); got != 5 {
+				t.Errorf("Added() = %d, want 5", got)
+			}
+			if got := in.Batches(); got != tt.wantBatches {
+				t.Errorf("Batches() = %d, want %d", got, tt.wantBatches)
+			}
+			if got := in.Rejected(); got != tt.wantRejected {
+				t.Errorf("Rejected() = %d, want %d", got, tt.wantRejected)
+			}
+			var lines []int64
+			for _, r := range in.Rejections() {
+				lines = append(lines, r.Line)
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(tt.wantRejections) {
+				t.Errorf("Rejections() = %v, want %v", lines, tt.wantRejections)
+			}
+			var stops int64
+			db.Model(&gtfs.Stop{}).Count(&stops)
+			if stops != tt.wantStops {
+				t.Errorf("stops = %d, want %d", stops, tt.wantStops)
+			}
+		})
+	}
+}
+
+func TestInserter_Values(t *testing.T) {
+	db := newTestDB(t)
+	in := gtfs.NewInserter[gtfs.Agency](db, gtfs.InserterOptions{})
+	for i := 0; i < 3; i++ {
+		if err := in.Add(gtfs.Agency{ID: fmt.Sprintf("A%d", i), Name: "Agency"}); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+	if err := in.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if in.Batches() != 1 {
+		t.Errorf("Batches() = %d, want 1", in.Batches())
+	}
+	var agencies int64
+	db.Model(&gtfs.Agency{}).Count(&agencies)
+	if agencies != 3 {
+		t.Errorf("agencies = %d, want 3", agencies)
+	}
+}
+
+func TestInserter_Transaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		rollback  bool
+		wantStops int64
+	}{
+		{"commit", false, 5},
+		{"rollback", true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			db.Create(&gtfs.Stop{ID: "S0", Name: "Existing"})
+
+			// the failing batch doesn't abort the transaction
+			errRollback := errors.New("rollback")
+			err := db.Transaction(func(tx *gorm.DB) error {
+				in := gtfs.NewInserter[*gtfs.Stop](tx, gtfs.InserterOptions{BatchSize: 2, SkipFailedRows: true})
+				for _, id := range []string{"S1", "S2", "S0", "S3", "S4"} {
+					_ = in.Add(&gtfs.Stop{ID: id, Name: fmt.Sprintf("Stop %s", id)})
+				}
+				if err := in.Close(); err != nil {
+					return err
+				}
+				if in.Rejected() != 1 {
+					t.Errorf("Rejected() = %d, want 1", in.Rejected())
+				}
+				if tt.rollback {
+					return errRollback
+				}
+				return nil
+			})
+			if (err != nil) != tt.rollback {
+				t.Fatalf("Transaction() error = %v", err)
+			}
+			var stops int64
+			db.Model(&gtfs.Stop{}).Count(&stops)
+			if stops != tt.wantStops {
+				t.Errorf("stops = %d, want %d", stops, tt.wantStops)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+)
+
+// Importer imports GTFS feeds into DBs. Applications embedding this package
+// may depend on Importer (rather than on ImportWithOptions and ImportZip),
+// allowing to unit test their orchestration with a MockImporter.
+type Importer interface {
+
+	// Import imports the GTFS files from the directory (or zip archive)
+	// gtfsBase into db (see ImportWithOptions and ImportZip).
+	Import(db *gorm.DB, gtfsBase string, opts ImportOptions) error
+}
+
+// Trimmer trims DBs to the items of a single agency. Applications embedding
+// this package may depend on Trimmer (rather than on Trim), allowing to unit
+// test their orchestration with a MockTrimmer.
+type Trimmer interface {
+
+	// Trim removes all items not associated with the agency matching like
+	// (see Trim).
+	Trim(db *gorm.DB, like string, opts TrimOptions) (*TrimResult, error)
+}
+
+// DefaultImporter is the Importer of this package.
+type DefaultImporter struct{}
+
+// Import imports the GTFS files from the directory gtfsBase (see
+// ImportWithOptions) or the zip archive gtfsBase (see ImportZip, if gtfsBase
+// ends with ".zip") into db. The errors of files failing to import are
+// returned joined (see ImportSummary.Err), e.g. matching
+// ErrRequiredFileMissing or RowParseError.
+func (DefaultImporter) Import(db *gorm.DB, gtfsBase string, opts ImportOptions) error {
+	var summary *ImportSummary
+	if IsZip(gtfsBase) {
+		var err error
+		if summary, err = ImportZip(db, gtfsBase, opts); err != nil {
+			return err
+		}
+	} else {
+		summary = ImportWithOptions(db, gtfsBase, opts)
+	}
+	return summary.Err()
+}
+
+// DefaultTrimmer is the Trimmer of this package.
+type DefaultTrimmer struct{}
+
+// Trim removes all items not associated with the agency matching like (see
+// Trim).
+func (DefaultTrimmer) Trim(db *gorm.DB, like string, opts TrimOptions) (*TrimResult, error) {
+	return Trim(db, like, opts)
+}
+
+// ImportCall describes a call of MockImporter.Import.
+type ImportCall struct {
+	GTFSBase string
+	Opts     ImportOptions
+}
+
+// MockImporter is an Importer not touching any DB (db may be nil), which
+// records its calls and reports the given results (see Results and Err). A
+// MockImporter is not safe for concurrent use.
+type MockImporter struct {
+
+	// Results are reported (in order) to ImportOptions.OnProgress (if not
+	// nil) by each call of Import.
+	Results []*ImportItemsResult
+
+	// Err (if not nil) is returned by each call of Import (after reporting
+	// the results).
+	Err error
+
+	// Calls are the calls of Import so far.
+	Calls []ImportCall
+}
+
+// Import records the call and reports the results (see MockImporter).
+func (mi *MockImporter) Import(_ *gorm.DB, gtfsBase string, opts ImportOptions) error {
+	mi.Calls = append(mi.Calls, ImportCall{GTFSBase: gtfsBase, Opts: opts})
+	if opts.OnProgress != nil {
+		for _, r := range mi.Results {
+			opts.OnProgress(ImportEvent{Result: r, Estimate: opts.Estimate})
+		}
+	}
+	return mi.Err
+}
+
+// TrimCall describes a call of MockTrimmer.Trim.
+type TrimCall struct {
+	Like string
+	Opts TrimOptions
+}
+
+// MockTrimmer is a Trimmer not touching any DB (db may be nil), which records
+// its calls and returns the given result (see Result and Err). A MockTrimmer
+// is not safe for concurrent use.
+type MockTrimmer struct {
+
+	// Result is returned by each call of Trim (an empty result, if nil).
+	Result *TrimResult
+
+	// Err (if not nil) is returned by each call of Trim (rather than Result).
+	Err error
+
+	// Calls are the calls of Trim so far.
+	Calls []TrimCall
+}
+
+// Trim records the call and returns the result (see MockTrimmer).
+func (mt *MockTrimmer) Trim(_ *gorm.DB, like string, opts TrimOptions) (*TrimResult, error) {
+	mt.Calls = append(mt.Calls, TrimCall{Like: like, Opts: opts})
+	if mt.Err != nil {
+		return nil, mt.Err
+	}
+	if mt.Result == nil {
+		return &TrimResult{}, nil
+	}
+	return mt.Result, nil
+}
@@ -0,0 +1,92 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"path"
+	"testing"
+)
+
+var (
+	_ gtfs.Importer = gtfs.DefaultImporter{}
+	_ gtfs.Importer = &gtfs.MockImporter{}
+	_ gtfs.Trimmer  = gtfs.DefaultTrimmer{}
+	_ gtfs.Trimmer  = &gtfs.MockTrimmer{}
+)
+
+func TestDefaultImporter(t *testing.T) {
+	zipPath := path.Join(t.TempDir(), "feed.zip")
+	writeZip(t, zipPath, []string{""}, nil)
+	for _, gtfsBase := range []string{fixtureFeed, zipPath} {
+		t.Run(path.Base(gtfsBase), func(t *testing.T) {
+			db := newTestDB(t)
+			var importer gtfs.Importer = gtfs.DefaultImporter{}
+			if err := importer.Import(db, gtfsBase, gtfs.ImportOptions{}); err != nil {
+				t.Fatalf("Import() error = %v", err)
+			}
+			var trimmer gtfs.Trimmer = gtfs.DefaultTrimmer{}
+			r, err := trimmer.Trim(db, "S-Bahn", gtfs.TrimOptions{})
+			if err != nil {
+				t.Fatalf("Trim() error = %v", err)
+			}
+			if got := (*r)[gtfs.Stops].Remaining; got != 4 {
+				t.Errorf("Trim() remaining stops = %d, want 4", got)
+			}
+		})
+	}
+}
+
+func TestDefaultImporter_Malformed(t *testing.T) {
+	feed := writeFeed(t, map[string]string{"stop_times.txt": "T1,08:00:00,08:00:00,S1,x\n"})
+	db := newTestDB(t)
+	err := gtfs.DefaultImporter{}.Import(db, feed, gtfs.ImportOptions{})
+	var e *gtfs.RowParseError
+	if !errors.As(err, &e) || e.ItemType != gtfs.StopTimes {
+		t.Fatalf("Import() error = %v, want a RowParseError for stop times", err)
+	}
+
+	// the files not failing are imported nonetheless
+	var stops int64
+	db.Model(&gtfs.Stop{}).Count(&stops)
+	if stops == 0 {
+		t.Errorf("Import() imported no stops")
+	}
+}
+
+func TestMockImporter(t *testing.T) {
+	wantErr := errors.New("boom")
+	importer := &gtfs.MockImporter{
+		Results: []*gtfs.ImportItemsResult{{ItemType: gtfs.Agencies, Count: 2}, {ItemType: gtfs.Routes, Count: 3}},
+		Err:     wantErr,
+	}
+	var count int64
+	err := importer.Import(nil, "feed.zip", gtfs.ImportOptions{
+		OnProgress: func(e gtfs.ImportEvent) {
+			count += e.Result.Count
+		},
+	})
+	if !errors.Is(err, wantErr) {
+		t.Errorf("Import() error = %v, want %v", err, wantErr)
+	}
+	if count != 5 {
+		t.Errorf("Import() reported %d items, want 5", count)
+	}
+	if len(importer.Calls) != 1 || importer.Calls[0].GTFSBase != "feed.zip" {
+		t.Errorf("Import() calls = %v, want a single call for feed.zip", importer.Calls)
+	}
+}
+
+func TestMockTrimmer(t *testing.T) {
+	trimmer := &gtfs.MockTrimmer{}
+	r, err := trimmer.Trim(nil, "S-Bahn", gtfs.TrimOptions{KeepStops: []string{"S1"}})
+	if err != nil || r == nil || len(*r) != 0 {
+		t.Errorf("Trim() = %v, %v, want an empty result", r, err)
+	}
+	trimmer.Err = errors.New("boom")
+	if _, err = trimmer.Trim(nil, "U-Bahn", gtfs.TrimOptions{}); err == nil {
+		t.Errorf("Trim() error = nil, want boom")
+	}
+	if len(trimmer.Calls) != 2 || trimmer.Calls[0].Like != "S-Bahn" || trimmer.Calls[0].Opts.KeepStops[0] != "S1" {
+		t.Errorf("Trim() calls = %v", trimmer.Calls)
+	}
+}
@@ -0,0 +1,340 @@
+package gtfs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ErrJobNotFound is returned if there is no job with a given ID.
+var ErrJobNotFound = errors.New("job not found")
+
+// ErrJobFinished is returned when canceling a job that already finished.
+var ErrJobFinished = errors.New("job already finished")
+
+// DefaultMaxFinishedJobs is the number of finished jobs a JobQueue keeps by
+// default (see JobQueue.MaxFinished).
+const DefaultMaxFinishedJobs = 100
+
+// JobState enumerates the states of jobs.
+type JobState uint32
+
+const (
+
+	// JobQueued the state of jobs waiting to be run.
+	JobQueued JobState = iota
+
+	// JobRunning the state of jobs being run.
+	JobRunning
+
+	// JobSucceeded the state of jobs that completed successfully.
+	JobSucceeded
+
+	// JobFailed the state of jobs that failed.
+	JobFailed
+
+	// JobCanceled the state of jobs that were canceled.
+	JobCanceled
+
+	// JobInterrupted the state of jobs that were queued or running when the
+	// process running them stopped.
+	JobInterrupted
+)
+
+var txJobState = map[JobState]string{
+	JobQueued:      "queued",
+	JobRunning:     "running",
+	JobSucceeded:   "succeeded",
+	JobFailed:      "failed",
+	JobCanceled:    "canceled",
+	JobInterrupted: "interrupted",
+}
+
+// String returns a human-readable representation of JobState.
+func (js JobState) String() string {
+	if s := txJobState[js]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown JobState (%d)", uint32(js))
+}
+
+// Finished returns true, if the state is final.
+func (js JobState) Finished() bool {
+	return js != JobQueued && js != JobRunning
+}
+
+// MarshalJSON marshals JobState to JSON (as string).
+func (js JobState) MarshalJSON() ([]byte, error) {
+	return json.Marshal(js.String())
+}
+
+// UnmarshalJSON unmarshalls JobState from JSON (as string).
+func (js *JobState) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	for state, name := range txJobState {
+		if name == s {
+			*js = state
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown job state '%s'", s)
+}
+
+// JobFunc is the function run by a job. It should stop early (returning an
+// error), when ctx is canceled, and may report (intermediate) progress.
+type JobFunc func(ctx context.Context, progress func(interface{})) (result interface{}, err error)
+
+// JobStatus describes the state, the progress and (once finished) the result
+// of a job.
+type JobStatus struct {
+	ID       string      `json:"id"`
+	Type     string      `json:"type"`
+	State    JobState    `json:"state"`
+	Created  time.Time   `json:"created"`
+	Started  time.Time   `json:"started,omitempty"`
+	Finished time.Time   `json:"finished,omitempty"`
+	Progress interface{} `json:"progress,omitempty"`
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// job is a job within a JobQueue.
+type job struct {
+	status   JobStatus
+	fn       JobFunc
+	ctx      context.Context
+	cancel   context.CancelFunc
+	version  int // number of changes of status (guarded by JobQueue.mu)
+	notified int // version last reported (guarded by JobQueue.notifyMu)
+}
+
+// change returns the (changed) status of the job along with its version. The
+// JobQueue.mu must be held.
+func (j *job) change() (JobStatus, int) {
+	j.version++
+	return j.status, j.version
+}
+
+// JobQueue runs long-running operations (e.g. imports, trims, exports or
+// validations) as jobs in the background, one after another. A JobQueue is
+// safe for concurrent use.
+type JobQueue struct {
+
+	// OnChange (if not nil) is called with the status of a job whenever it
+	// changes (e.g. to persist it). Calls are serialized and report the
+	// statuses of a job in order (skipping statuses outdated by the time of
+	// the call). OnChange must not submit or cancel jobs.
+	OnChange func(JobStatus)
+
+	// MaxFinished is the number of finished jobs kept (see Status and Jobs),
+	// forgetting the oldest ones beyond. If zero, DefaultMaxFinishedJobs are
+	// kept.
+	MaxFinished int
+
+	mu       sync.Mutex
+	notifyMu sync.Mutex
+	nextID   int
+	jobs     map[string]*job
+	order    []string
+	queue    chan *job
+	closed   bool
+}
+
+// NewJobQueue initializes a JobQueue keeping (at most) size jobs queued.
+func NewJobQueue(size int) *JobQueue {
+	q := &JobQueue{jobs: map[string]*job{}, queue: make(chan *job, size)}
+	go q.work()
+	return q
+}
+
+// Submit queues a job of the given type running fn and returns its status.
+// If the queue is full, an error is returned.
+func (q *JobQueue) Submit(jobType string, fn JobFunc) (JobStatus, error) {
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return JobStatus{}, errors.New("job queue is closed")
+	}
+	q.nextID++
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		status: JobStatus{ID: strconv.Itoa(q.nextID), Type: jobType, State: JobQueued, Created: time.Now()},
+		fn:     fn,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+	select {
+	case q.queue <- j:
+	default:
+		q.mu.Unlock()
+		cancel()
+		return JobStatus{}, errors.New("job queue is full")
+	}
+	q.jobs[j.status.ID] = j
+	q.order = append(q.order, j.status.ID)
+	status, version := j.change()
+	q.mu.Unlock()
+
+	q.changed(j, status, version)
+	return status, nil
+}
+
+// Status returns the status of the job with the given ID.
+func (q *JobQueue) Status(id string) (JobStatus, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	j, ok := q.jobs[id]
+	if !ok {
+		return JobStatus{}, ErrJobNotFound
+	}
+	return j.status, nil
+}
+
+// Jobs returns the status of all jobs (in the order of their submission).
+func (q *JobQueue) Jobs() []JobStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	statuses := make([]JobStatus, len(q.order))
+	for i, id := range q.order {
+		statuses[i] = q.jobs[id].status
+	}
+	return statuses
+}
+
+// Cancel cancels the job with the given ID. Queued jobs won't run, running
+// jobs are asked to stop (via their context).
+func (q *JobQueue) Cancel(id string) error {
+	q.mu.Lock()
+	j, ok := q.jobs[id]
+	if !ok {
+		q.mu.Unlock()
+		return ErrJobNotFound
+	}
+	if j.status.State.Finished() {
+		q.mu.Unlock()
+		return ErrJobFinished
+	}
+	j.cancel()
+	if j.status.State != JobQueued {
+		q.mu.Unlock()
+		return nil
+	}
+	j.status.State = JobCanceled
+	j.status.Finished = time.Now()
+	status, version := j.change()
+	q.prune()
+	q.mu.Unlock()
+
+	q.changed(j, status, version)
+	return nil
+}
+
+// Close cancels all queued and running jobs and stops accepting new ones.
+func (q *JobQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	q.closed = true
+	for _, j := range q.jobs {
+		j.cancel()
+	}
+	close(q.queue)
+}
+
+// work runs the queued jobs one after another.
+func (q *JobQueue) work() {
+	for j := range q.queue {
+		q.mu.Lock()
+		if j.status.State != JobQueued {
+			q.mu.Unlock()
+			continue
+		}
+		if j.ctx.Err() != nil {
+			j.status.State = JobCanceled
+			j.status.Finished = time.Now()
+			status, version := j.change()
+			q.prune()
+			q.mu.Unlock()
+			q.changed(j, status, version)
+			continue
+		}
+		j.status.State = JobRunning
+		j.status.Started = time.Now()
+		status, version := j.change()
+		q.mu.Unlock()
+		q.changed(j, status, version)
+
+		result, err := j.fn(j.ctx, func(progress interface{}) {
+			q.mu.Lock()
+			j.status.Progress = progress
+			status, version := j.change()
+			q.mu.Unlock()
+			q.changed(j, status, version)
+		})
+
+		q.mu.Lock()
+		j.status.Finished = time.Now()
+		j.status.Result = result
+		switch {
+		case err == nil:
+			j.status.State = JobSucceeded
+		case j.ctx.Err() != nil:
+			j.status.State = JobCanceled
+			j.status.Error = err.Error()
+		default:
+			j.status.State = JobFailed
+			j.status.Error = err.Error()
+		}
+		j.cancel()
+		status, version = j.change()
+		q.prune()
+		q.mu.Unlock()
+		q.changed(j, status, version)
+	}
+}
+
+// prune forgets the oldest finished jobs beyond MaxFinished. The mu must be
+// held.
+func (q *JobQueue) prune() {
+	limit := q.MaxFinished
+	if limit <= 0 {
+		limit = DefaultMaxFinishedJobs
+	}
+	finished := 0
+	for _, id := range q.order {
+		if q.jobs[id].status.State.Finished() {
+			finished++
+		}
+	}
+	order := q.order[:0]
+	for _, id := range q.order {
+		if finished > limit && q.jobs[id].status.State.Finished() {
+			delete(q.jobs, id)
+			finished--
+			continue
+		}
+		order = append(order, id)
+	}
+	q.order = order
+}
+
+// changed calls OnChange (if set) with the status of j, unless a later
+// version of the status was reported already (by a concurrent call).
+func (q *JobQueue) changed(j *job, status JobStatus, version int) {
+	q.notifyMu.Lock()
+	defer q.notifyMu.Unlock()
+	if q.OnChange == nil || version <= j.notified {
+		return
+	}
+	j.notified = version
+	q.OnChange(status)
+}
@@ -0,0 +1,161 @@
+package gtfs_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// waitForJob waits for the job with the given ID to finish.
+func waitForJob(t *testing.T, q *gtfs.JobQueue, id string) gtfs.JobStatus {
+	t.Helper()
+	for i := 0; i < 100; i++ {
+		status, err := q.Status(id)
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if status.State.Finished() {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("job %s did not finish", id)
+	return gtfs.JobStatus{}
+}
+
+func TestJobQueue(t *testing.T) {
+	q := gtfs.NewJobQueue(10)
+	defer q.Close()
+
+	// a job blocking the queue until canceled
+	started := make(chan struct{})
+	blocking, err := q.Submit("block", func(ctx context.Context, progress func(interface{})) (interface{}, error) {
+		progress("blocking")
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("Submit() error = %v", err)
+	}
+	<-started
+	if status, _ := q.Status(blocking.ID); status.State != gtfs.JobRunning || status.Progress != "blocking" {
+		t.Errorf("Status() = %+v, want running and progress", status)
+	}
+
+	succeeding, _ := q.Submit("succeed", func(_ context.Context, _ func(interface{})) (interface{}, error) {
+		return 42, nil
+	})
+	failing, _ := q.Submit("fail", func(_ context.Context, _ func(interface{})) (interface{}, error) {
+		return nil, errors.New("failed")
+	})
+	canceled, _ := q.Submit("cancel", func(_ context.Context, _ func(interface{})) (interface{}, error) {
+		return nil, nil
+	})
+
+	// cancel a queued and the running job
+	if err = q.Cancel(canceled.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+	if err = q.Cancel(blocking.ID); err != nil {
+		t.Fatalf("Cancel() error = %v", err)
+	}
+
+	tests := []struct {
+		id    string
+		state gtfs.JobState
+	}{
+		{blocking.ID, gtfs.JobCanceled},
+		{succeeding.ID, gtfs.JobSucceeded},
+		{failing.ID, gtfs.JobFailed},
+		{canceled.ID, gtfs.JobCanceled},
+	}
+	for _, tt := range tests {
+		if status := waitForJob(t, q, tt.id); status.State != tt.state {
+			t.Errorf("job %s state = %v, want %v", tt.id, status.State, tt.state)
+		}
+	}
+	if status, _ := q.Status(succeeding.ID); status.Result != 42 {
+		t.Errorf("Status() result = %v, want 42", status.Result)
+	}
+
+	if len(q.Jobs()) != 4 {
+		t.Errorf("Jobs() = %v, want 4 jobs", q.Jobs())
+	}
+	if err = q.Cancel(succeeding.ID); !errors.Is(err, gtfs.ErrJobFinished) {
+		t.Errorf("Cancel() error = %v, want %v", err, gtfs.ErrJobFinished)
+	}
+	if _, err = q.Status("unknown"); !errors.Is(err, gtfs.ErrJobNotFound) {
+		t.Errorf("Status() error = %v, want %v", err, gtfs.ErrJobNotFound)
+	}
+}
+
+func TestJobQueue_MaxFinished(t *testing.T) {
+	q := gtfs.NewJobQueue(10)
+	q.MaxFinished = 2
+	defer q.Close()
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		status, err := q.Submit("succeed", func(_ context.Context, _ func(interface{})) (interface{}, error) {
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+		ids = append(ids, status.ID)
+	}
+	waitForJob(t, q, ids[3])
+
+	// the oldest finished jobs are forgotten
+	var kept []string
+	for _, status := range q.Jobs() {
+		kept = append(kept, status.ID)
+	}
+	if fmt.Sprint(kept) != fmt.Sprint(ids[2:]) {
+		t.Errorf("Jobs() = %v, want %v", kept, ids[2:])
+	}
+	if _, err := q.Status(ids[0]); !errors.Is(err, gtfs.ErrJobNotFound) {
+		t.Errorf("Status() error = %v, want %v", err, gtfs.ErrJobNotFound)
+	}
+}
+
+func TestJobQueue_OnChange(t *testing.T) {
+	q := gtfs.NewJobQueue(100)
+	defer q.Close()
+
+	// OnChange is never called concurrently and sees the states of a job in order
+	var calls, concurrent int32
+	states := map[string]gtfs.JobState{}
+	q.OnChange = func(status gtfs.JobStatus) {
+		if atomic.AddInt32(&calls, 1) > 1 {
+			atomic.StoreInt32(&concurrent, 1)
+		}
+		defer atomic.AddInt32(&calls, -1)
+		if state, ok := states[status.ID]; ok && status.State < state {
+			t.Errorf("OnChange() job %s state %v after %v", status.ID, status.State, state)
+		}
+		states[status.ID] = status.State
+	}
+
+	var last gtfs.JobStatus
+	for i := 0; i < 50; i++ {
+		var err error
+		last, err = q.Submit("progress", func(_ context.Context, progress func(interface{})) (interface{}, error) {
+			progress(1)
+			progress(2)
+			return nil, nil
+		})
+		if err != nil {
+			t.Fatalf("Submit() error = %v", err)
+		}
+	}
+	waitForJob(t, q, last.ID)
+	if atomic.LoadInt32(&concurrent) != 0 {
+		t.Errorf("OnChange() called concurrently")
+	}
+}
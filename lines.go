@@ -0,0 +1,174 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+	"sort"
+)
+
+// Line describes a branded line, i.e. the routes of an agency sharing a short
+// name (see ListLines). Feeds often split a line into several routes (e.g. per
+// variant or direction).
+type Line struct {
+	ID        string   // the agency ID and the name (e.g. "BVG:S1", see LineID)
+	AgencyID  string   // the ID of the agency operating the line
+	Name      string   // the short name of the routes (the long name, if lacking)
+	Type      int      // the type of the first route
+	Color     string   // the color of the first route giving one
+	TextColor string   // the text color of the first route giving one
+	RouteIDs  []string // the IDs of the routes (ordered)
+	Trips     int      // the number of trips of all routes
+	Stops     int      // the number of distinct stops served by the routes
+}
+
+// LineID returns the ID of the line the given route belongs to, i.e. the ID of
+// its agency and its short name (the long name, if lacking) separated by a
+// colon (e.g. "BVG:S1"). If the route has no agency, the name alone is the ID.
+// Routes with neither short nor long name form a line of their own (with the
+// route ID as name).
+func LineID(r Route) string {
+	name := lineName(r)
+	if r.AgencyID == "" {
+		return name
+	}
+	return r.AgencyID + ":" + name
+}
+
+// lineName returns the name of the line of a route.
+func lineName(r Route) string {
+	switch {
+	case r.ShortName != "":
+		return r.ShortName
+	case r.LongName != "":
+		return r.LongName
+	default:
+		return r.ID
+	}
+}
+
+// statement to select the number of trips per route
+const lineTripsStmt = `
+SELECT
+	route_id,
+	COUNT(*)
+FROM
+	trips
+GROUP BY
+	route_id;
+`
+
+// statement to select the stops served per route
+const lineStopsStmt = `
+SELECT DISTINCT
+	trips.route_id,
+	stop_times.stop_id
+FROM
+	trips
+	JOIN stop_times ON stop_times.trip_id = trips.id;
+`
+
+// ListLines groups the routes of the agency with the given ID (all routes, if
+// agencyID is empty) into lines (see LineID) and returns them, along with the
+// number of trips and stops, ordered by agency ID and name.
+func ListLines(db *gorm.DB, agencyID string) ([]Line, error) {
+	routes, err := ListRoutes(db, agencyID)
+	if err != nil {
+		return nil, err
+	}
+
+	// group routes by line
+	lines := []Line{}
+	byID := map[string]int{}
+	lineOf := map[string]int{}
+	for _, r := range routes {
+		id := LineID(r)
+		i, ok := byID[id]
+		if !ok {
+			i = len(lines)
+			byID[id] = i
+			lines = append(lines, Line{ID: id, AgencyID: r.AgencyID, Name: lineName(r), Type: r.Type})
+		}
+		l := &lines[i]
+		l.RouteIDs = append(l.RouteIDs, r.ID)
+		if l.Color == "" && l.TextColor == "" {
+			l.Color, l.TextColor = r.Color, r.TextColor
+		}
+		lineOf[r.ID] = i
+	}
+	if len(lines) == 0 {
+		return lines, nil
+	}
+
+	// count trips per line
+	rows, err := db.Raw(lineTripsStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var routeID string
+		var n int
+		if err = rows.Scan(&routeID, &n); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if i, ok := lineOf[routeID]; ok {
+			lines[i].Trips += n
+		}
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// count distinct stops per line
+	stops := make([]map[string]bool, len(lines))
+	rows, err = db.Raw(lineStopsStmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var routeID, stopID string
+		if err = rows.Scan(&routeID, &stopID); err != nil {
+			return nil, err
+		}
+		i, ok := lineOf[routeID]
+		if !ok {
+			continue
+		}
+		if stops[i] == nil {
+			stops[i] = map[string]bool{}
+		}
+		stops[i][stopID] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range lines {
+		lines[i].Stops = len(stops[i])
+	}
+
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].AgencyID != lines[j].AgencyID {
+			return lines[i].AgencyID < lines[j].AgencyID
+		}
+		return lines[i].Name < lines[j].Name
+	})
+	return lines, nil
+}
+
+// GetLine returns the line with the given ID (see LineID). If there is no such
+// line, gorm.ErrRecordNotFound is returned.
+func GetLine(db *gorm.DB, lineID string) (*Line, error) {
+	lines, err := ListLines(db, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, l := range lines {
+		if l.ID == lineID {
+			return &l, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
@@ -0,0 +1,66 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"reflect"
+	"testing"
+)
+
+func TestListLines(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// R3 is a variant of S1 (serving the stops of T4)
+	db.Create(&gtfs.Route{ID: "R3", AgencyID: "1", ShortName: "S1", Type: 109})
+	copyTrip(t, db, "T4", "T5", 0)
+	db.Model(&gtfs.Trip{}).Where("id = ?", "T5").Update("route_id", "R3")
+
+	s1 := gtfs.Line{ID: "1:S1", AgencyID: "1", Name: "S1", Type: 109, Color: "008D4F", TextColor: "FFFFFF", RouteIDs: []string{"R1", "R3"}, Trips: 4, Stops: 7}
+	bus := gtfs.Line{ID: "2:218", AgencyID: "2", Name: "218", Type: 3, RouteIDs: []string{"R2"}, Trips: 1, Stops: 3}
+	tests := []struct {
+		agency string
+		want   []gtfs.Line
+	}{
+		{"", []gtfs.Line{s1, bus}},
+		{"2", []gtfs.Line{bus}},
+		{"3", []gtfs.Line{}},
+	}
+	for _, tt := range tests {
+		got, err := gtfs.ListLines(db, tt.agency)
+		if err != nil {
+			t.Fatalf("ListLines() error = %v", err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ListLines(%q) = %v, want %v", tt.agency, got, tt.want)
+		}
+	}
+
+	line, err := gtfs.GetLine(db, "1:S1")
+	if err != nil {
+		t.Fatalf("GetLine() error = %v", err)
+	}
+	if !reflect.DeepEqual(*line, s1) {
+		t.Errorf("GetLine() = %v, want %v", *line, s1)
+	}
+	if _, err = gtfs.GetLine(db, "S1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetLine() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestLineID(t *testing.T) {
+	tests := []struct {
+		route gtfs.Route
+		want  string
+	}{
+		{gtfs.Route{ID: "R1", AgencyID: "BVG", ShortName: "S1", LongName: "Ring"}, "BVG:S1"},
+		{gtfs.Route{ID: "R1", AgencyID: "BVG", LongName: "Ring"}, "BVG:Ring"},
+		{gtfs.Route{ID: "R1", ShortName: "S1"}, "S1"},
+		{gtfs.Route{ID: "R1"}, "R1"},
+	}
+	for _, tt := range tests {
+		if got := gtfs.LineID(tt.route); got != tt.want {
+			t.Errorf("LineID(%v) = %q, want %q", tt.route, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+	"sync"
+)
+
+// maxLODZoom is the zoom level from which on shapes are no longer simplified.
+const maxLODZoom = 18
+
+// maxLODEntries is the maximum number of simplified shapes kept cached (the
+// cache is reset when exceeding it).
+const maxLODEntries = 10000
+
+// lodKey identifies a simplified shape within the LOD cache.
+type lodKey struct {
+	config  *gorm.Config
+	shapeID string
+	bucket  int
+}
+
+// lodCache caches simplified shapes (see ShapeGeometryLOD).
+var lodCache = struct {
+	sync.Mutex
+	shapes map[lodKey][]Shape
+}{shapes: map[lodKey][]Shape{}}
+
+// ShapeGeometryLOD returns the points of the given shape simplified for
+// display at the given (web map) zoom level, i.e. omitting points deviating
+// less than a pixel from the line through their neighbors
+// (Ramer-Douglas-Peucker). Zoom levels are bucketed by two, and simplified
+// shapes are cached per DB, shape and bucket, such that tile servers don't
+// repeatedly simplify the same shapes (changes to shapes within an opened DB
+// are thus not reflected). From zoom level 18 on, all points are returned. If there is no such shape, gorm.ErrRecordNotFound is returned.
+func ShapeGeometryLOD(db *gorm.DB, shapeID string, zoom int) ([]Shape, error) {
+	if zoom < 0 {
+		zoom = 0
+	}
+	if zoom > maxLODZoom {
+		zoom = maxLODZoom
+	}
+	key := lodKey{config: db.Config, shapeID: shapeID, bucket: zoom - zoom%2}
+
+	lodCache.Lock()
+	shape, ok := lodCache.shapes[key]
+	lodCache.Unlock()
+	if ok {
+		return append([]Shape(nil), shape...), nil
+	}
+
+	shape, err := ShapeGeometry(db, shapeID)
+	if err != nil {
+		return nil, err
+	}
+	if key.bucket < maxLODZoom {
+		shape = simplify(shape, metersPerPixel(key.bucket))
+	}
+
+	lodCache.Lock()
+	if len(lodCache.shapes) >= maxLODEntries {
+		lodCache.shapes = map[lodKey][]Shape{}
+	}
+	lodCache.shapes[key] = shape
+	lodCache.Unlock()
+
+	return append([]Shape(nil), shape...), nil
+}
+
+// metersPerPixel returns the (equatorial) ground resolution of a 256 pixel
+// web map tile at the given zoom level.
+func metersPerPixel(zoom int) float64 {
+	return 156543.03392 / float64(int(1)<<zoom)
+}
+
+// simplify returns the points of the shape deviating at least tolerance
+// meters from the line through their neighbors (Ramer-Douglas-Peucker). The
+// first and the last point are always kept.
+func simplify(shape []Shape, tolerance float64) []Shape {
+	if len(shape) < 3 {
+		return shape
+	}
+	keep := make([]bool, len(shape))
+	keep[0], keep[len(shape)-1] = true, true
+
+	// iteratively process ranges (rather than recursing on long shapes)
+	ranges := [][2]int{{0, len(shape) - 1}}
+	for len(ranges) > 0 {
+		r := ranges[len(ranges)-1]
+		ranges = ranges[:len(ranges)-1]
+
+		maxDist, maxIndex := 0.0, -1
+		for i := r[0] + 1; i < r[1]; i++ {
+			_, d := project(shape[r[0]], shape[r[1]], Stop{Latitude: shape[i].PtLat, Longitude: shape[i].PtLon})
+			if d > maxDist {
+				maxDist, maxIndex = d, i
+			}
+		}
+		if maxIndex >= 0 && maxDist >= tolerance {
+			keep[maxIndex] = true
+			ranges = append(ranges, [2]int{r[0], maxIndex}, [2]int{maxIndex, r[1]})
+		}
+	}
+
+	simplified := make([]Shape, 0, len(shape))
+	for i, p := range shape {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
@@ -0,0 +1,48 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestShapeGeometryLOD(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// a zig-zag deviating ~10m from the straight line from (52.4, 13.0) to (52.4, 13.1)
+	for i := 0; i <= 10; i++ {
+		lat := 52.4
+		if i%2 == 1 {
+			lat += 0.0001
+		}
+		db.Create(&gtfs.Shape{ShapeID: "ZZ", PtLat: lat, PtLon: 13.0 + float64(i)*0.01, PtSequence: i + 1})
+	}
+
+	tests := []struct {
+		zoom int
+		want int
+	}{
+		{-1, 2},
+		{10, 2},
+		{11, 2},
+		{16, 11},
+		{25, 11},
+	}
+	for _, tt := range tests {
+		shape, err := gtfs.ShapeGeometryLOD(db, "ZZ", tt.zoom)
+		if err != nil {
+			t.Fatalf("ShapeGeometryLOD(%d) error = %v", tt.zoom, err)
+		}
+		if len(shape) != tt.want {
+			t.Errorf("ShapeGeometryLOD(%d) = %d points, want %d", tt.zoom, len(shape), tt.want)
+		}
+		if shape[0].PtSequence != 1 || shape[len(shape)-1].PtSequence != 11 {
+			t.Errorf("ShapeGeometryLOD(%d) dropped the first or the last point", tt.zoom)
+		}
+	}
+
+	if _, err := gtfs.ShapeGeometryLOD(db, "X", 10); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("ShapeGeometryLOD() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
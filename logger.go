@@ -0,0 +1,64 @@
+package gtfs
+
+import (
+	"context"
+	"log/slog"
+)
+
+// discardHandler is a slog.Handler discarding all records (used unless a
+// handler is given, see ImportOptions.Logger and TrimOptions.Logger).
+type discardHandler struct{}
+
+// Enabled reports that no level is enabled.
+func (discardHandler) Enabled(context.Context, slog.Level) bool {
+	return false
+}
+
+// Handle discards the record.
+func (discardHandler) Handle(context.Context, slog.Record) error {
+	return nil
+}
+
+// WithAttrs returns the handler itself.
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler {
+	return h
+}
+
+// WithGroup returns the handler itself.
+func (h discardHandler) WithGroup(string) slog.Handler {
+	return h
+}
+
+// newLogger returns a logger passing records to h (discarding records, if h is
+// nil).
+func newLogger(h slog.Handler) *slog.Logger {
+	if h == nil {
+		h = discardHandler{}
+	}
+	return slog.New(h)
+}
+
+// logImportResult logs the result of importing a file (see
+// ImportOptions.Logger).
+func logImportResult(logger *slog.Logger, r *ImportItemsResult) {
+	attrs := []any{slog.String("item_type", r.ItemType.String()), slog.String("path", r.Path)}
+	switch {
+	case r.Error != nil:
+		logger.Error("failed to import file", append(attrs, slog.Any("error", r.Error), slog.Bool("rolled_back", r.RolledBack))...)
+	case r.Skipped:
+		logger.Debug("skipped file", append(attrs, slog.Bool("excluded", r.Excluded))...)
+	default:
+		logger.Info("imported file", append(attrs,
+			slog.Int64("rows", r.Count),
+			slog.Int64("batches", r.Batches),
+			slog.Int64("duplicates", r.Duplicates),
+			slog.Int64("rejected", r.Rejected),
+			slog.Int64("malformed", r.Malformed),
+			slog.Int64("normalized", r.Normalized),
+			slog.Bool("truncated", r.Truncated),
+			slog.Duration("duration", r.Time))...)
+	}
+	for _, rejection := range r.Rejections {
+		logger.Warn("rejected row", append(attrs, slog.Int64("line", rejection.Line), slog.Any("error", rejection.Error))...)
+	}
+}
@@ -0,0 +1,187 @@
+package gtfs
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ErrNoObservations is returned if there is nothing to match.
+var ErrNoObservations = errors.New("no observations")
+
+// maxMatchDeviation is the maximum deviation of an observed time from the
+// scheduled time for the observation to match a stop time.
+const maxMatchDeviation = 30 * time.Minute
+
+// StopEvent describes a vehicle observed at a stop (e.g. by AVL or ticketing
+// systems).
+type StopEvent struct {
+	StopID string
+	Time   time.Time
+}
+
+// TripMatch describes the trip most likely observed (see MatchTrip).
+type TripMatch struct {
+	TripID    string
+	RouteID   string
+	Date      time.Time     // the service day of the trip (see ServiceDay)
+	Matched   int           // the number of observations matching a stop time of the trip
+	Delay     time.Duration // the mean deviation of the matching observations from the schedule
+	Deviation time.Duration // the mean absolute deviation of the matching observations
+}
+
+// statement to select the stop times at the given stops (ordered by trip and
+// stop sequence)
+const observedStopTimesStmt = `
+SELECT
+	stop_times.trip_id,
+	trips.route_id,
+	trips.service_id,
+	stop_times.stop_id,
+	stop_times.stop_seq,
+	stop_times.departure
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+WHERE
+	stop_times.stop_id IN ?
+ORDER BY
+	stop_times.trip_id,
+	stop_times.stop_seq;
+`
+
+// observedStopTime is a stop time at an observed stop.
+type observedStopTime struct {
+	TripID    string
+	RouteID   string
+	ServiceID string
+	StopID    string
+	StopSeq   int
+	Departure DateTime
+}
+
+// MatchTrip identifies the trip most likely observed at the given sequence of
+// stops (ordered by time), e.g. to integrate AVL or ticketing data lacking
+// trip IDs. Trips running on the service day of the first observation (or
+// running past midnight from the day before) are matched by visiting the
+// observed stops in order, departing within 30 minutes of the observed times.
+// The trip matching most observations (and, among those, deviating least from
+// the observed times) is returned. Times of observations are interpreted in
+// the timezone of the feed (see FeedTimezone). If no trip matches,
+// gorm.ErrRecordNotFound is returned. Trips defined by frequencies are not
+// expanded.
+func MatchTrip(db *gorm.DB, observed []StopEvent) (*TripMatch, error) {
+	if len(observed) == 0 {
+		return nil, ErrNoObservations
+	}
+	tz, err := FeedTimezone(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// select the stop times at the observed stops
+	stopIDs := make([]string, 0, len(observed))
+	for _, e := range observed {
+		stopIDs = append(stopIDs, e.StopID)
+	}
+	var stopTimes []observedStopTime
+	if tx := db.Raw(observedStopTimesStmt, stopIDs).Scan(&stopTimes); tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// consider the service day of the first observation and the day before
+	day, _ := ServiceDay(observed[0].Time, tz)
+	dates := []time.Time{day.AddDate(0, 0, -1), day}
+	active := make([]map[string]bool, len(dates))
+	for i, date := range dates {
+		services, err := ActiveServices(db, date)
+		if err != nil {
+			return nil, err
+		}
+		active[i] = map[string]bool{}
+		for _, serviceID := range services {
+			active[i][serviceID] = true
+		}
+	}
+
+	// match the stop times of each trip on each day
+	var best *TripMatch
+	for start := 0; start < len(stopTimes); {
+		end := start
+		for end < len(stopTimes) && stopTimes[end].TripID == stopTimes[start].TripID {
+			end++
+		}
+		for i, date := range dates {
+			if !active[i][stopTimes[start].ServiceID] {
+				continue
+			}
+			m := matchStopTimes(stopTimes[start:end], observed, date)
+			if m.Matched > 0 && (best == nil || betterMatch(m, *best)) {
+				best = &m
+			}
+		}
+		start = end
+	}
+	if best == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return best, nil
+}
+
+// matchStopTimes matches the observations to the stop times of a trip (ordered
+// by stop sequence) running on the service day date.
+func matchStopTimes(stopTimes []observedStopTime, observed []StopEvent, date time.Time) TripMatch {
+	m := TripMatch{TripID: stopTimes[0].TripID, RouteID: stopTimes[0].RouteID, Date: date}
+	reference := ServiceTime(date, 0)
+	var delay, deviation time.Duration
+	next := 0
+	for _, e := range observed {
+
+		// the closest stop time at the stop (later on than the last match)
+		match := -1
+		var matchDelay time.Duration
+		for i := next; i < len(stopTimes); i++ {
+			if stopTimes[i].StopID != e.StopID {
+				continue
+			}
+			d := e.Time.Sub(reference) - time.Duration(stopTimes[i].Departure.Int32)*time.Second
+			if abs(d) <= maxMatchDeviation && (match < 0 || abs(d) < abs(matchDelay)) {
+				match, matchDelay = i, d
+			}
+		}
+		if match < 0 {
+			continue
+		}
+		m.Matched++
+		delay += matchDelay
+		deviation += abs(matchDelay)
+		next = match + 1
+	}
+	if m.Matched > 0 {
+		m.Delay = delay / time.Duration(m.Matched)
+		m.Deviation = deviation / time.Duration(m.Matched)
+	}
+	return m
+}
+
+// betterMatch reports whether the match a is better than the match b.
+func betterMatch(a, b TripMatch) bool {
+	if a.Matched != b.Matched {
+		return a.Matched > b.Matched
+	}
+	if a.Deviation != b.Deviation {
+		return a.Deviation < b.Deviation
+	}
+	if !a.Date.Equal(b.Date) {
+		return a.Date.After(b.Date)
+	}
+	return a.TripID < b.TripID
+}
+
+// abs returns the absolute value of d.
+func abs(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
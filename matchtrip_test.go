@@ -0,0 +1,85 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+func TestMatchTrip(t *testing.T) {
+	db := newFixtureDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	// T5 runs past midnight
+	copyTrip(t, db, "T1", "T5", 16*3600)
+
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2022, 3, day, hour, min, 0, 0, berlin)
+	}
+	tests := []struct {
+		name      string
+		observed  []gtfs.StopEvent
+		wantTrip  string
+		wantDate  time.Time
+		wantDelay time.Duration
+		wantErr   error
+	}{
+		{
+			name:      "closest in time",
+			observed:  []gtfs.StopEvent{{"S2", at(1, 8, 25)}, {"S3", at(1, 8, 30)}},
+			wantTrip:  "T2",
+			wantDate:  at(1, 0, 0),
+			wantDelay: 2 * time.Minute,
+		},
+		{
+			name:      "direction",
+			observed:  []gtfs.StopEvent{{"S3", at(1, 9, 5)}, {"S2", at(1, 9, 12)}},
+			wantTrip:  "T3",
+			wantDate:  at(1, 0, 0),
+			wantDelay: 0,
+		},
+		{
+			name:      "past midnight",
+			observed:  []gtfs.StopEvent{{"S1", at(2, 0, 1)}, {"S2", at(2, 0, 4)}},
+			wantTrip:  "T5",
+			wantDate:  at(1, 0, 0),
+			wantDelay: time.Minute,
+		},
+		{
+			name:     "not running on Saturdays",
+			observed: []gtfs.StopEvent{{"S1", at(5, 8, 0)}},
+			wantErr:  gorm.ErrRecordNotFound,
+		},
+		{
+			name:     "too late",
+			observed: []gtfs.StopEvent{{"S4", at(1, 11, 0)}},
+			wantErr:  gorm.ErrRecordNotFound,
+		},
+		{
+			name:    "nothing observed",
+			wantErr: gtfs.ErrNoObservations,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gtfs.MatchTrip(db, tt.observed)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("MatchTrip() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MatchTrip() error = %v", err)
+			}
+			if got.TripID != tt.wantTrip || !got.Date.Equal(tt.wantDate) || got.Delay != tt.wantDelay || got.Matched != len(tt.observed) {
+				t.Errorf("MatchTrip() = %+v, want trip %s on %v delayed by %v", got, tt.wantTrip, tt.wantDate, tt.wantDelay)
+			}
+		})
+	}
+}
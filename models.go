@@ -0,0 +1,94 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+	"reflect"
+	"sync"
+)
+
+// models are the models migrated by Migrate (see Models and RegisterModels).
+var models = struct {
+	sync.Mutex
+	list []interface{}
+}{list: []interface{}{
+	&Agency{},
+	&Route{},
+	&Trip{},
+	&StopTime{},
+	&Stop{},
+	&Shape{},
+	&EncodedShape{},
+	&ShapeBounds{},
+	&Calendar{},
+	&CalendarDate{},
+	&Frequency{},
+	&RouteDirection{},
+	&FrequencyBucketDate{},
+	&RouteFrequencyBucket{},
+	&StopFrequencyBucket{},
+	&StopRidership{},
+	&FeedMeta{},
+	&ImportReject{},
+	&SkippedRow{},
+	&StopOverride{},
+	&StopAmenity{},
+	&Translation{},
+	&FareProduct{},
+	&FareLegRule{},
+	&FareTransferRule{},
+	&VehicleCapacity{},
+	&StopSearch{},
+	&SearchEntry{},
+}}
+
+// Models returns the models migrated by Migrate (i.e. pointers to new zero
+// values of the models of this package, followed by the models registered via
+// RegisterModels), e.g. for tools reflecting over the tables of a GTFS DB.
+func Models() []interface{} {
+	models.Lock()
+	defer models.Unlock()
+	list := make([]interface{}, len(models.list))
+	for i, m := range models.list {
+		list[i] = reflect.New(reflect.TypeOf(m).Elem()).Interface()
+	}
+	return list
+}
+
+// RegisterModels registers additional models (pointers to structs, e.g. of
+// tables extending GTFS DBs) to be migrated by Migrate (and returned by
+// Models). Models already registered are ignored.
+func RegisterModels(ms ...interface{}) {
+	models.Lock()
+	defer models.Unlock()
+	for _, m := range ms {
+		registered := false
+		for _, r := range models.list {
+			registered = registered || reflect.TypeOf(r) == reflect.TypeOf(m)
+		}
+		if !registered {
+			models.list = append(models.list, m)
+		}
+	}
+}
+
+// Migrate ensure the given DB matches our models (see Models). Shapes,
+// calendars and calendar dates are unique by their natural keys (see
+// naturalKeys), thus migrating a DB holding duplicates of these fails.
+// References between items (e.g. of trips to routes) are not backed by
+// foreign key constraints (which DBs like Postgres or MySQL would enforce), as
+// feeds commonly reference missing items and Trim removes referenced items
+// first. Indexed strings are limited to 191 characters (the longest key MySQL
+// indexes in utf8mb4). Search indexes built as SQLite FTS5 virtual tables (see
+// IndexStops and IndexSearch) are left as they are.
+func Migrate(db *gorm.DB) error {
+	db = db.Session(&gorm.Session{})
+	db.Config.DisableForeignKeyConstraintWhenMigrating = true
+	var list []interface{}
+	for _, m := range Models() {
+		if t, ok := m.(interface{ TableName() string }); ok && isFTS5(db, t.TableName()) {
+			continue
+		}
+		list = append(list, m)
+	}
+	return db.AutoMigrate(list...)
+}
@@ -0,0 +1,35 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+// StopNote is a model extending GTFS DBs (see TestRegisterModels).
+type StopNote struct {
+	StopID string `gorm:"primaryKey"`
+	Note   string
+}
+
+func TestModels(t *testing.T) {
+	models := gtfs.Models()
+	if _, ok := models[0].(*gtfs.Agency); !ok {
+		t.Fatalf("Models()[0] = %T, want *gtfs.Agency", models[0])
+	}
+	models[0].(*gtfs.Agency).Name = "modified"
+	if got := gtfs.Models()[0].(*gtfs.Agency).Name; got != "" {
+		t.Errorf("Models()[0] name = %s, want a new zero value", got)
+	}
+}
+
+func TestRegisterModels(t *testing.T) {
+	n := len(gtfs.Models())
+	gtfs.RegisterModels(&StopNote{}, &StopNote{})
+	if got := len(gtfs.Models()); got != n+1 {
+		t.Errorf("Models() = %d models, want %d", got, n+1)
+	}
+	db := newTestDB(t)
+	if !db.Migrator().HasTable(&StopNote{}) {
+		t.Errorf("Migrate() did not create the table of a registered model")
+	}
+}
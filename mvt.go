@@ -0,0 +1,312 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"math"
+)
+
+// ErrInvalidTile is returned if tile coordinates are out of range.
+var ErrInvalidTile = errors.New("invalid tile")
+
+// maxTileZoom is the maximum zoom level of vector tiles.
+const maxTileZoom = 22
+
+// tileExtent is the extent (in tile coordinates) of vector tiles.
+const tileExtent = 4096
+
+// tileBuffer is the buffer (in tile coordinates) around vector tiles within
+// which stops are included, such that symbols aren't cut at tile borders.
+const tileBuffer = 64
+
+// statement to select the shapes (by ID) whose bounding box intersects a
+// bounding box (south, north, west, east)
+const tileShapesStmt = `
+SELECT
+	shape_id
+FROM
+	shapes
+GROUP BY
+	shape_id
+HAVING
+	MAX(pt_lat) >= ? AND
+	MIN(pt_lat) <= ? AND
+	MAX(pt_lon) >= ? AND
+	MIN(pt_lon) <= ?
+%s
+ORDER BY
+	shape_id;
+`
+
+// clause of tileShapesStmt to also select encoded shapes (see EncodeShapes)
+const tileEncodedShapesClause = `
+UNION
+SELECT
+	shape_id
+FROM
+	encoded_shapes
+WHERE
+	max_lat >= ? AND
+	min_lat <= ? AND
+	max_lon >= ? AND
+	min_lon <= ?`
+
+// statement to select the routes (along with their color) using the given
+// shapes
+const shapeRoutesStmt = `
+SELECT DISTINCT
+	trips.shape_id,
+	routes.id AS route_id,
+	routes.color
+FROM
+	trips
+	JOIN routes ON routes.id = trips.route_id
+WHERE
+	trips.shape_id IN ?
+ORDER BY
+	routes.id;
+`
+
+// VectorTile returns the Mapbox Vector Tile (version 2) at the given zoom
+// level and tile coordinates (as used by web maps) holding the layers "stops"
+// (points with the properties stop_id and name, see SetStopOverride) and
+// "shapes" (line strings with the properties shape_id, route_id and color,
+// simplified for the zoom level, see ShapeGeometryLOD). Shapes are included if
+// their bounding box intersects the tile and are not clipped. If the
+// coordinates are out of range, ErrInvalidTile is returned.
+func VectorTile(db *gorm.DB, z, x, y int) ([]byte, error) {
+	if z < 0 || z > maxTileZoom || x < 0 || y < 0 || x >= 1<<z || y >= 1<<z {
+		return nil, ErrInvalidTile
+	}
+	t := tile{z: z, x: x, y: y}
+
+	// stops within the tile (including the buffer)
+	buffer := float64(tileBuffer) / tileExtent
+	south, west := t.latLon(1+buffer, -buffer)
+	north, east := t.latLon(-buffer, 1+buffer)
+	var stops []Stop
+	tx := db.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", south, north, west, east).Order("id").Find(&stops)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := ApplyStopOverrides(db, stops); err != nil {
+		return nil, err
+	}
+	stopsLayer := newMVTLayer("stops")
+	for _, s := range stops {
+		px, py := t.pixel(s.Latitude, s.Longitude)
+		stopsLayer.addFeature(mvtPoint, [][2]int32{{px, py}}, "stop_id", s.ID, "name", s.Name)
+	}
+
+	// shapes intersecting the tile (along with a route using them), falling
+	// back to aggregating the points for DBs lacking bounding boxes
+	var shapeIDs []string
+	if hasShapeBounds(db) {
+		var err error
+		if shapeIDs, err = ShapesInBounds(db, south, west, north, east); err != nil {
+			return nil, err
+		}
+	} else {
+		stmt, values := fmt.Sprintf(tileShapesStmt, ""), []interface{}{south, north, west, east}
+		if db.Migrator().HasTable(&EncodedShape{}) {
+			stmt, values = fmt.Sprintf(tileShapesStmt, tileEncodedShapesClause), append(values, south, north, west, east)
+		}
+		if tx = db.Raw(stmt, values...).Scan(&shapeIDs); tx.Error != nil {
+			return nil, tx.Error
+		}
+	}
+	shapesLayer := newMVTLayer("shapes")
+	if len(shapeIDs) > 0 {
+		var shapeRoutes []struct {
+			ShapeID string
+			RouteID string
+			Color   string
+		}
+		if tx = db.Raw(shapeRoutesStmt, shapeIDs).Scan(&shapeRoutes); tx.Error != nil {
+			return nil, tx.Error
+		}
+		routes := map[string]Route{}
+		for _, sr := range shapeRoutes {
+			if _, ok := routes[sr.ShapeID]; !ok {
+				routes[sr.ShapeID] = Route{ID: sr.RouteID, Color: sr.Color}
+			}
+		}
+		for _, shapeID := range shapeIDs {
+			shape, err := ShapeGeometryLOD(db, shapeID, z)
+			if err != nil {
+				return nil, err
+			}
+			var line [][2]int32
+			for _, p := range shape {
+				px, py := t.pixel(p.PtLat, p.PtLon)
+				if n := len(line); n == 0 || line[n-1] != [2]int32{px, py} {
+					line = append(line, [2]int32{px, py})
+				}
+			}
+			if len(line) < 2 {
+				continue
+			}
+			route := routes[shapeID]
+			color, _ := route.RouteColors()
+			shapesLayer.addFeature(mvtLineString, line, "shape_id", shapeID, "route_id", route.ID, "color", "#"+color)
+		}
+	}
+
+	var pb protobuf
+	pb.bytes(3, stopsLayer.encode())
+	pb.bytes(3, shapesLayer.encode())
+	return pb.b, nil
+}
+
+// tile is a web map tile.
+type tile struct {
+	z, x, y int
+}
+
+// pixel returns the tile coordinates (within the extent) of the given
+// coordinate (web mercator).
+func (t tile) pixel(lat, lon float64) (int32, int32) {
+	n := float64(int(1) << t.z)
+	mx := (lon + 180) / 360 * n
+	latRad := lat * math.Pi / 180
+	my := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+	return int32(math.Round((mx - float64(t.x)) * tileExtent)), int32(math.Round((my - float64(t.y)) * tileExtent))
+}
+
+// latLon returns the coordinate at the given fraction of the tile (0, 0 being
+// the north-west corner and 1, 1 the south-east corner).
+func (t tile) latLon(fy, fx float64) (float64, float64) {
+	n := float64(int(1) << t.z)
+	lon := (float64(t.x)+fx)/n*360 - 180
+	lat := math.Atan(math.Sinh(math.Pi*(1-2*(float64(t.y)+fy)/n))) * 180 / math.Pi
+	return lat, lon
+}
+
+// geometry types of vector tile features
+const (
+	mvtPoint      = 1
+	mvtLineString = 2
+)
+
+// mvtLayer is a layer of a vector tile under construction.
+type mvtLayer struct {
+	name     string
+	features []byte
+	keys     []string
+	values   []string
+	keyIdx   map[string]uint32
+	valueIdx map[string]uint32
+	nextID   uint64
+}
+
+// newMVTLayer initializes a layer with the given name.
+func newMVTLayer(name string) *mvtLayer {
+	return &mvtLayer{name: name, keyIdx: map[string]uint32{}, valueIdx: map[string]uint32{}}
+}
+
+// addFeature adds a feature of the given geometry type (a point or a line
+// string) with the given properties (alternating keys and string values).
+func (l *mvtLayer) addFeature(geomType uint64, points [][2]int32, properties ...string) {
+	var tags []uint32
+	for i := 0; i+1 < len(properties); i += 2 {
+		tags = append(tags, l.index(properties[i], l.keyIdx, &l.keys), l.index(properties[i+1], l.valueIdx, &l.values))
+	}
+
+	// MoveTo the first point and LineTo the others (with zig-zag encoded deltas)
+	geometry := []uint32{1 | 1<<3}
+	var cx, cy int32
+	for i, p := range points {
+		if i == 1 {
+			geometry = append(geometry, 2|uint32(len(points)-1)<<3)
+		}
+		geometry = append(geometry, zigZag(p[0]-cx), zigZag(p[1]-cy))
+		cx, cy = p[0], p[1]
+	}
+
+	l.nextID++
+	var f protobuf
+	f.uint(1, l.nextID)
+	f.packed(2, tags)
+	f.uint(3, geomType)
+	f.packed(4, geometry)
+	var pb protobuf
+	pb.bytes(2, f.b)
+	l.features = append(l.features, pb.b...)
+}
+
+// index returns the index of s within the given (keys or values) table,
+// adding it if needed.
+func (l *mvtLayer) index(s string, idx map[string]uint32, table *[]string) uint32 {
+	if i, ok := idx[s]; ok {
+		return i
+	}
+	i := uint32(len(*table))
+	idx[s] = i
+	*table = append(*table, s)
+	return i
+}
+
+// encode returns the layer encoded as protocol buffer.
+func (l *mvtLayer) encode() []byte {
+	var pb protobuf
+	pb.uint(15, 2)
+	pb.string(1, l.name)
+	pb.b = append(pb.b, l.features...)
+	for _, k := range l.keys {
+		pb.string(3, k)
+	}
+	for _, v := range l.values {
+		var value protobuf
+		value.string(1, v)
+		pb.bytes(4, value.b)
+	}
+	pb.uint(5, tileExtent)
+	return pb.b
+}
+
+// zigZag encodes a signed integer as (vector tile) parameter integer.
+func zigZag(n int32) uint32 {
+	return uint32((n << 1) ^ (n >> 31))
+}
+
+// protobuf is a minimal protocol buffer encoder.
+type protobuf struct {
+	b []byte
+}
+
+// varint appends v as varint.
+func (pb *protobuf) varint(v uint64) {
+	for v >= 0x80 {
+		pb.b = append(pb.b, byte(v)|0x80)
+		v >>= 7
+	}
+	pb.b = append(pb.b, byte(v))
+}
+
+// uint appends the field with the given number as varint.
+func (pb *protobuf) uint(field int, v uint64) {
+	pb.varint(uint64(field)<<3 | 0)
+	pb.varint(v)
+}
+
+// bytes appends the field with the given number as length-delimited bytes.
+func (pb *protobuf) bytes(field int, b []byte) {
+	pb.varint(uint64(field)<<3 | 2)
+	pb.varint(uint64(len(b)))
+	pb.b = append(pb.b, b...)
+}
+
+// string appends the field with the given number as string.
+func (pb *protobuf) string(field int, s string) {
+	pb.bytes(field, []byte(s))
+}
+
+// packed appends the field with the given number as packed varints.
+func (pb *protobuf) packed(field int, vs []uint32) {
+	var p protobuf
+	for _, v := range vs {
+		p.varint(uint64(v))
+	}
+	pb.bytes(field, p.b)
+}
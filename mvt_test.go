@@ -0,0 +1,96 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+// pbFields decodes the (varint and length-delimited) fields of a protocol
+// buffer message.
+func pbFields(t *testing.T, b []byte) (fields []int, values [][]byte) {
+	t.Helper()
+	varint := func() uint64 {
+		var v uint64
+		for shift := 0; ; shift += 7 {
+			if len(b) == 0 {
+				t.Fatalf("truncated varint")
+			}
+			c := b[0]
+			b = b[1:]
+			v |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				return v
+			}
+		}
+	}
+	for len(b) > 0 {
+		key := varint()
+		switch key & 7 {
+		case 0:
+			varint()
+			fields, values = append(fields, int(key>>3)), append(values, nil)
+		case 2:
+			n := varint()
+			fields, values = append(fields, int(key>>3)), append(values, b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return fields, values
+}
+
+// tileLayers returns the number of features per layer of a vector tile.
+func tileLayers(t *testing.T, b []byte) map[string]int {
+	layers := map[string]int{}
+	fields, values := pbFields(t, b)
+	for i, f := range fields {
+		if f != 3 {
+			continue
+		}
+		var name string
+		var features int
+		layerFields, layerValues := pbFields(t, values[i])
+		for j, lf := range layerFields {
+			switch lf {
+			case 1:
+				name = string(layerValues[j])
+			case 2:
+				features++
+			}
+		}
+		layers[name] = features
+	}
+	return layers
+}
+
+func TestVectorTile(t *testing.T) {
+	db := newFixtureDB(t)
+	var shapes int64
+	db.Model(&gtfs.Shape{}).Distinct("shape_id").Count(&shapes)
+
+	tests := []struct {
+		z, x, y    int
+		wantStops  int
+		wantShapes int
+	}{
+		{5, 17, 10, 7, int(shapes)},
+		{10, 549, 336, 7, int(shapes)},
+		{10, 0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		b, err := gtfs.VectorTile(db, tt.z, tt.x, tt.y)
+		if err != nil {
+			t.Fatalf("VectorTile(%d, %d, %d) error = %v", tt.z, tt.x, tt.y, err)
+		}
+		layers := tileLayers(t, b)
+		if layers["stops"] != tt.wantStops || layers["shapes"] != tt.wantShapes {
+			t.Errorf("VectorTile(%d, %d, %d) = %v, want %d stops and %d shapes", tt.z, tt.x, tt.y, layers, tt.wantStops, tt.wantShapes)
+		}
+	}
+
+	if _, err := gtfs.VectorTile(db, 1, 2, 0); !errors.Is(err, gtfs.ErrInvalidTile) {
+		t.Errorf("VectorTile() error = %v, want %v", err, gtfs.ErrInvalidTile)
+	}
+}
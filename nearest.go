@@ -0,0 +1,221 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+	"math"
+	"sort"
+)
+
+// Point is a coordinate (e.g. of a GPS trace).
+type Point struct {
+	Lat float64
+	Lon float64
+}
+
+// StopMatch describes the stop nearest to a point (see NearestStops).
+type StopMatch struct {
+	Point    Point
+	Stop     Stop    // the nearest stop (by its display name, see SetStopOverride)
+	Distance float64 // the great-circle distance between point and stop (in meters)
+	Entrance *Stop   // the entrance of the station the distance is measured to (see NearestOptions), if any
+}
+
+// NearestOptions configures NearestStopsWithOptions.
+type NearestOptions struct {
+
+	// Entrances (if true) matches points to the entrances of stations (see
+	// LocationEntrance) as well.
+	Entrances bool
+
+	// Stations (if true) matches points to the stations of platforms rather
+	// than to the platforms, at the distance of the nearest entrance of the
+	// station (or of the nearest of its platforms, if it has no entrances),
+	// e.g. for estimating the time of walking to a station.
+	Stations bool
+}
+
+// NearestStops matches each of the given points (e.g. the positions of a
+// vehicle) to its nearest stop (i.e. stop or platform, see LocationStop) and
+// returns the matches in the order of the points. Stops are loaded once and
+// indexed by a k-d tree (over their positions on the unit sphere), such that
+// matching large batches of points is cheap. If there are no stops,
+// gorm.ErrRecordNotFound is returned.
+func NearestStops(db *gorm.DB, points []Point) ([]StopMatch, error) {
+	return NearestStopsWithOptions(db, points, NearestOptions{})
+}
+
+// NearestStopsWithOptions matches each of the given points to its nearest
+// stop like NearestStops, matching entrances or stations as desired (see
+// NearestOptions).
+func NearestStopsWithOptions(db *gorm.DB, points []Point, opts NearestOptions) ([]StopMatch, error) {
+	matches := make([]StopMatch, len(points))
+	if len(points) == 0 {
+		return matches, nil
+	}
+	var stops []Stop
+	if tx := db.Order("id").Find(&stops); tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := ApplyStopOverrides(db, stops); err != nil {
+		return nil, err
+	}
+	candidates, targets := nearestCandidates(stops, opts)
+	if len(candidates) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	index := newStopIndex(candidates)
+	for i, p := range points {
+		j := index.nearest(p)
+		c := candidates[j]
+		matches[i] = StopMatch{Point: p, Stop: targets[j], Distance: haversine(p.Lat, p.Lon, c.Latitude, c.Longitude)}
+		if c.LocationType == LocationEntrance && c.ID != targets[j].ID {
+			matches[i].Entrance = &candidates[j]
+		}
+	}
+	return matches, nil
+}
+
+// nearestCandidates returns the stops points are matched to (see
+// NearestOptions) along with the stop each of them stands for (i.e. the
+// station of platforms and entrances, if matching stations, or the candidate
+// itself).
+func nearestCandidates(stops []Stop, opts NearestOptions) ([]Stop, []Stop) {
+	var candidates, targets []Stop
+	if !opts.Stations {
+		for _, s := range stops {
+			if s.LocationType == LocationStop || opts.Entrances && s.LocationType == LocationEntrance {
+				candidates = append(candidates, s)
+			}
+		}
+		return candidates, candidates
+	}
+
+	// the station of each stop (if any)
+	byID := make(map[string]Stop, len(stops))
+	for _, s := range stops {
+		byID[s.ID] = s
+	}
+	stations := make([]*Stop, len(stops))
+	entrances := map[string]bool{} // the stations having entrances
+	for i, s := range stops {
+		for depth := 0; depth <= maxStationDepth; depth++ {
+			if s.LocationType == LocationStation {
+				station := s
+				stations[i] = &station
+				break
+			}
+			parent, ok := byID[s.ParentStation]
+			if !ok {
+				break
+			}
+			s = parent
+		}
+		if stations[i] != nil && stops[i].LocationType == LocationEntrance {
+			entrances[stations[i].ID] = true
+		}
+	}
+
+	// stations are reached via their entrances (if any), otherwise via
+	// themselves, their platforms and boarding areas
+	for i, s := range stops {
+		station := stations[i]
+		if station == nil {
+			if s.LocationType == LocationStop || opts.Entrances && s.LocationType == LocationEntrance {
+				candidates, targets = append(candidates, s), append(targets, s)
+			}
+			continue
+		}
+		reaches := s.LocationType == LocationEntrance
+		if !entrances[station.ID] {
+			reaches = s.LocationType == LocationStop || s.LocationType == LocationStation || s.LocationType == LocationBoardingArea
+		}
+		if reaches {
+			candidates, targets = append(candidates, s), append(targets, *station)
+		}
+	}
+	return candidates, targets
+}
+
+// stopIndex is a k-d tree over the positions of stops on the unit sphere. As
+// the chord distance between two positions grows with their great-circle
+// distance, the nearest neighbor in the tree is the nearest stop.
+type stopIndex struct {
+	nodes []stopNode // the nodes in the order of the stops
+	root  int
+}
+
+// stopNode is a node of a stopIndex (i.e. a stop splitting its subtree).
+type stopNode struct {
+	pos         [3]float64
+	axis        int
+	left, right int // the indexes of the children (-1, if none)
+}
+
+// newStopIndex builds the k-d tree over the given stops.
+func newStopIndex(stops []Stop) *stopIndex {
+	idx := &stopIndex{nodes: make([]stopNode, len(stops))}
+	order := make([]int, len(stops))
+	for i, s := range stops {
+		idx.nodes[i].pos = unitVector(s.Latitude, s.Longitude)
+		order[i] = i
+	}
+	idx.root = idx.build(order, 0)
+	return idx
+}
+
+// build builds the subtree over the stops with the given indexes and returns
+// the index of its root (-1, if empty).
+func (idx *stopIndex) build(order []int, depth int) int {
+	if len(order) == 0 {
+		return -1
+	}
+	axis := depth % 3
+	sort.Slice(order, func(i, j int) bool {
+		return idx.nodes[order[i]].pos[axis] < idx.nodes[order[j]].pos[axis]
+	})
+	mid := len(order) / 2
+	root := order[mid]
+	n := &idx.nodes[root]
+	n.axis = axis
+	n.left = idx.build(order[:mid], depth+1)
+	n.right = idx.build(order[mid+1:], depth+1)
+	return root
+}
+
+// nearest returns the index of the stop nearest to p.
+func (idx *stopIndex) nearest(p Point) int {
+	target := unitVector(p.Lat, p.Lon)
+	best, bestDist := -1, math.Inf(1)
+	var search func(i int)
+	search = func(i int) {
+		if i < 0 {
+			return
+		}
+		n := &idx.nodes[i]
+		var d float64
+		for k := range target {
+			d += (n.pos[k] - target[k]) * (n.pos[k] - target[k])
+		}
+		if d < bestDist || (d == bestDist && i < best) {
+			best, bestDist = i, d
+		}
+		diff := target[n.axis] - n.pos[n.axis]
+		near, far := n.left, n.right
+		if diff > 0 {
+			near, far = far, near
+		}
+		search(near)
+		if diff*diff <= bestDist {
+			search(far)
+		}
+	}
+	search(idx.root)
+	return best
+}
+
+// unitVector returns the position of a coordinate on the unit sphere.
+func unitVector(lat, lon float64) [3]float64 {
+	phi, lambda := lat*math.Pi/180, lon*math.Pi/180
+	return [3]float64{math.Cos(phi) * math.Cos(lambda), math.Cos(phi) * math.Sin(lambda), math.Sin(phi)}
+}
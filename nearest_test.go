@@ -0,0 +1,135 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestNearestStops(t *testing.T) {
+	db := newFixtureDB(t)
+
+	tests := []struct {
+		name  string
+		point gtfs.Point
+		want  string
+	}{
+		{"at S1", gtfs.Point{Lat: 52.4210, Lon: 13.1790}, "S1"},
+		{"near B1", gtfs.Point{Lat: 52.4214, Lon: 13.1796}, "B1"},
+		{"near S4", gtfs.Point{Lat: 52.4600, Lon: 13.3300}, "S4"},
+		{"far away", gtfs.Point{Lat: -33.8688, Lon: 151.2093}, "S4"},
+	}
+	points := make([]gtfs.Point, len(tests))
+	for i, tt := range tests {
+		points[i] = tt.point
+	}
+	matches, err := gtfs.NearestStops(db, points)
+	if err != nil {
+		t.Fatalf("NearestStops() error = %v", err)
+	}
+	for i, tt := range tests {
+		if m := matches[i]; m.Stop.ID != tt.want || m.Point != tt.point {
+			t.Errorf("NearestStops() %s = %v, want %s", tt.name, m, tt.want)
+		}
+	}
+	if matches[0].Distance > 0.01 || matches[2].Distance < 500 || matches[2].Distance > 1000 {
+		t.Errorf("NearestStops() distances = %v, %v", matches[0].Distance, matches[2].Distance)
+	}
+
+	// no points, no stops
+	if matches, err = gtfs.NearestStops(db, nil); err != nil || len(matches) != 0 {
+		t.Errorf("NearestStops() = %v, %v, want none", matches, err)
+	}
+	if _, err = gtfs.NearestStops(newTestDB(t), points); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("NearestStops() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestNearestStops_BruteForce(t *testing.T) {
+	db := newTestDB(t)
+	db.AutoMigrate(&gtfs.Stop{})
+	rnd := rand.New(rand.NewSource(1))
+	var stops []gtfs.Stop
+	for i := 0; i < 500; i++ {
+		s := gtfs.Stop{ID: string(rune('A'+i%26)) + string(rune('0'+i/26)), Latitude: 52.3 + rnd.Float64()*0.4, Longitude: 13.1 + rnd.Float64()*0.6}
+		stops = append(stops, s)
+		db.Create(&s)
+	}
+	points := make([]gtfs.Point, 1000)
+	for i := range points {
+		points[i] = gtfs.Point{Lat: 52.2 + rnd.Float64()*0.6, Lon: 13.0 + rnd.Float64()*0.8}
+	}
+
+	matches, err := gtfs.NearestStops(db, points)
+	if err != nil {
+		t.Fatalf("NearestStops() error = %v", err)
+	}
+	for i, p := range points {
+		best := math.Inf(1)
+		for _, s := range stops {
+			best = math.Min(best, greatCircle(p.Lat, p.Lon, s.Latitude, s.Longitude))
+		}
+		if math.Abs(matches[i].Distance-best) > 1e-6 {
+			t.Fatalf("NearestStops() %v = %v, want distance %v", p, matches[i], best)
+		}
+	}
+}
+
+// greatCircle returns the great-circle distance (in meters) between two
+// coordinates.
+func greatCircle(lat1, lon1, lat2, lon2 float64) float64 {
+	phi1, phi2 := lat1*math.Pi/180, lat2*math.Pi/180
+	dPhi, dLambda := (lat2-lat1)*math.Pi/180, (lon2-lon1)*math.Pi/180
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * 6371000.0 * math.Asin(math.Sqrt(a))
+}
+
+func TestNearestStopsWithOptions(t *testing.T) {
+	db := newStationDB(t)
+	db.Model(&gtfs.Stop{}).Where("id = ?", "E1").Updates(map[string]interface{}{"latitude": 52.4230, "longitude": 13.1800})
+
+	// a point just north of the entrance E1 of the station WS (of S1 and B1)
+	point := gtfs.Point{Lat: 52.4232, Lon: 13.1800}
+	tests := []struct {
+		name         string
+		opts         gtfs.NearestOptions
+		want         string
+		wantEntrance string
+	}{
+		{"platforms", gtfs.NearestOptions{}, "B1", ""},
+		{"entrances", gtfs.NearestOptions{Entrances: true}, "E1", ""},
+		{"stations", gtfs.NearestOptions{Stations: true}, "WS", "E1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matches, err := gtfs.NearestStopsWithOptions(db, []gtfs.Point{point}, tt.opts)
+			if err != nil {
+				t.Fatalf("NearestStopsWithOptions() error = %v", err)
+			}
+			m := matches[0]
+			var entrance string
+			if m.Entrance != nil {
+				entrance = m.Entrance.ID
+			}
+			if m.Stop.ID != tt.want || entrance != tt.wantEntrance {
+				t.Errorf("NearestStopsWithOptions() = %s (via %q), want %s (via %q)", m.Stop.ID, entrance, tt.want, tt.wantEntrance)
+			}
+			if tt.wantEntrance != "" && (m.Distance < 15 || m.Distance > 30) {
+				t.Errorf("NearestStopsWithOptions() distance = %.0fm, want about 22m (to the entrance)", m.Distance)
+			}
+		})
+	}
+
+	// without entrances, stations are reached via their platforms
+	db.Delete(&gtfs.Stop{}, "id = ?", "E1")
+	matches, err := gtfs.NearestStopsWithOptions(db, []gtfs.Point{{Lat: 52.4210, Lon: 13.1790}}, gtfs.NearestOptions{Stations: true})
+	if err != nil {
+		t.Fatalf("NearestStopsWithOptions() error = %v", err)
+	}
+	if m := matches[0]; m.Stop.ID != "WS" || m.Entrance != nil || m.Distance > 0.01 {
+		t.Errorf("NearestStopsWithOptions() = %v, want WS at the position of S1", m)
+	}
+}
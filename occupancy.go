@@ -0,0 +1,259 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+)
+
+// vehicleCapacitiesFile is the name of the (optional, non-standard) GTFS file
+// holding the capacities of the vehicles serving routes or trips.
+const vehicleCapacitiesFile = "vehicle_capacities.txt"
+
+// OccupancyStatus enumerates the degrees of passenger occupancy of a vehicle
+// (as reported by GTFS-RT vehicle positions and trip updates).
+type OccupancyStatus uint32
+
+const (
+
+	// OccupancyEmpty the vehicle is considered empty.
+	OccupancyEmpty OccupancyStatus = iota
+
+	// OccupancyManySeatsAvailable the vehicle has a large number of seats
+	// available.
+	OccupancyManySeatsAvailable
+
+	// OccupancyFewSeatsAvailable the vehicle has a small number of seats
+	// available.
+	OccupancyFewSeatsAvailable
+
+	// OccupancyStandingRoomOnly the vehicle can accommodate only standing
+	// passengers.
+	OccupancyStandingRoomOnly
+
+	// OccupancyCrushedStandingRoomOnly the vehicle can accommodate only
+	// standing passengers and has limited space for them.
+	OccupancyCrushedStandingRoomOnly
+
+	// OccupancyFull the vehicle is considered full.
+	OccupancyFull
+
+	// OccupancyNotAcceptingPassengers the vehicle is not accepting passengers.
+	OccupancyNotAcceptingPassengers
+
+	// OccupancyNoDataAvailable the occupancy is unknown.
+	OccupancyNoDataAvailable
+
+	// OccupancyNotBoardable the vehicle is not boardable (e.g. a locomotive).
+	OccupancyNotBoardable
+)
+
+var txOccupancyStatus = map[OccupancyStatus]string{
+	OccupancyEmpty:                   "EMPTY",
+	OccupancyManySeatsAvailable:      "MANY_SEATS_AVAILABLE",
+	OccupancyFewSeatsAvailable:       "FEW_SEATS_AVAILABLE",
+	OccupancyStandingRoomOnly:        "STANDING_ROOM_ONLY",
+	OccupancyCrushedStandingRoomOnly: "CRUSHED_STANDING_ROOM_ONLY",
+	OccupancyFull:                    "FULL",
+	OccupancyNotAcceptingPassengers:  "NOT_ACCEPTING_PASSENGERS",
+	OccupancyNoDataAvailable:         "NO_DATA_AVAILABLE",
+	OccupancyNotBoardable:            "NOT_BOARDABLE",
+}
+
+// String returns a human-readable representation of OccupancyStatus.
+func (st OccupancyStatus) String() string {
+	if s := txOccupancyStatus[st]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown OccupancyStatus (%d)", uint32(st))
+}
+
+// ParseOccupancyStatus returns the OccupancyStatus with the given (GTFS-RT)
+// name (e.g. "FEW_SEATS_AVAILABLE").
+func ParseOccupancyStatus(s string) (OccupancyStatus, error) {
+	for status, name := range txOccupancyStatus {
+		if name == s {
+			return status, nil
+		}
+	}
+	return OccupancyNoDataAvailable, fmt.Errorf("unknown occupancy status '%s'", s)
+}
+
+// CrowdingLevel enumerates the levels of crowding passengers may expect (see
+// ExpectedCrowding).
+type CrowdingLevel uint32
+
+const (
+
+	// CrowdingUnknown the crowding is unknown.
+	CrowdingUnknown CrowdingLevel = iota
+
+	// CrowdingLow plenty of seats are available.
+	CrowdingLow
+
+	// CrowdingMedium few seats are available.
+	CrowdingMedium
+
+	// CrowdingHigh standing room only.
+	CrowdingHigh
+
+	// CrowdingFull the vehicle is full (or not boardable).
+	CrowdingFull
+)
+
+var txCrowdingLevel = map[CrowdingLevel]string{
+	CrowdingUnknown: "unknown",
+	CrowdingLow:     "low",
+	CrowdingMedium:  "medium",
+	CrowdingHigh:    "high",
+	CrowdingFull:    "full",
+}
+
+// String returns a human-readable representation of CrowdingLevel.
+func (cl CrowdingLevel) String() string {
+	if s := txCrowdingLevel[cl]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown CrowdingLevel (%d)", uint32(cl))
+}
+
+// VehicleCapacity model (the capacity of the vehicles serving a route or a
+// single trip of a route, imported from the non-standard file
+// vehicle_capacities.txt).
+type VehicleCapacity struct {
+	ID               uint   `gorm:"primaryKey,autoIncrement" csv:"-"`
+	RouteID          string `gorm:"index" csv:"route_id"`
+	TripID           string `gorm:"index" csv:"trip_id"` // empty for all trips of the route
+	SeatedCapacity   int    `csv:"seated_capacity"`
+	StandingCapacity int    `csv:"standing_capacity"`
+}
+
+// ImportVehicleCapacities imports the vehicle_capacities.txt file (if present)
+// from the directory (or zip archive, see ImportZip) gtfsBase (replacing any
+// previously imported capacities) and returns the number of capacities
+// imported.
+func ImportVehicleCapacities(db *gorm.DB, gtfsBase string) (int64, error) {
+	fsys, dir, closeFeed, err := openFeed(gtfsBase)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFeed()
+	name := path.Join(dir, vehicleCapacitiesFile)
+	if _, err = fs.Stat(fsys, name); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	var count int64
+	err = db.Transaction(func(tx *gorm.DB) (err error) {
+		count, err = replaceItems[VehicleCapacity](tx, fsys, name)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to import vehicle capacities: %w", err)
+	}
+	return count, nil
+}
+
+// TripOccupancy describes the (realtime) occupancy of the vehicle serving a
+// trip, e.g. as reported by GTFS-RT vehicle positions.
+type TripOccupancy struct {
+	TripID     string
+	Status     OccupancyStatus
+	Percentage int // the occupancy in percent of the total capacity (negative if unknown)
+}
+
+// Occupancy describes the occupancy of the vehicle of a departure (see
+// DeparturesOptions.Occupancies) along with the crowding passengers may
+// expect.
+type Occupancy struct {
+	Status           OccupancyStatus
+	Percentage       int // negative if unknown
+	Crowding         CrowdingLevel
+	SeatedCapacity   int // 0 if unknown (see VehicleCapacity)
+	StandingCapacity int
+}
+
+// ExpectedCrowding returns the level of crowding passengers may expect given
+// the occupancy of a vehicle. Unless the status is NO_DATA_AVAILABLE, the
+// level is derived from the status. Otherwise, the percentage (if known) is
+// related to the capacity (if known, i.e. low while fewer than half of the
+// seats are taken, medium while seats are available and high while standing
+// room is left) or to fixed thresholds (50%, 80% and 100%).
+func ExpectedCrowding(o Occupancy) CrowdingLevel {
+	switch o.Status {
+	case OccupancyEmpty, OccupancyManySeatsAvailable:
+		return CrowdingLow
+	case OccupancyFewSeatsAvailable:
+		return CrowdingMedium
+	case OccupancyStandingRoomOnly, OccupancyCrushedStandingRoomOnly:
+		return CrowdingHigh
+	case OccupancyFull, OccupancyNotAcceptingPassengers, OccupancyNotBoardable:
+		return CrowdingFull
+	}
+	if o.Percentage < 0 {
+		return CrowdingUnknown
+	}
+	total := o.SeatedCapacity + o.StandingCapacity
+	occupied := float64(o.Percentage) * float64(total) / 100
+	lowLimit, mediumLimit, highLimit := float64(o.SeatedCapacity)/2, float64(o.SeatedCapacity), float64(total)
+	if o.SeatedCapacity <= 0 {
+		occupied, lowLimit, mediumLimit, highLimit = float64(o.Percentage), 50, 80, 100
+	}
+	switch {
+	case occupied < lowLimit:
+		return CrowdingLow
+	case occupied < mediumLimit:
+		return CrowdingMedium
+	case occupied < highLimit:
+		return CrowdingHigh
+	default:
+		return CrowdingFull
+	}
+}
+
+// vehicleCapacities holds the imported vehicle capacities by route and by
+// trip.
+type vehicleCapacities struct {
+	byRoute map[string]VehicleCapacity
+	byTrip  map[string]VehicleCapacity
+}
+
+// loadVehicleCapacities loads the imported vehicle capacities (none, if not
+// imported).
+func loadVehicleCapacities(db *gorm.DB) (*vehicleCapacities, error) {
+	vc := &vehicleCapacities{byRoute: map[string]VehicleCapacity{}, byTrip: map[string]VehicleCapacity{}}
+	if !db.Migrator().HasTable(&VehicleCapacity{}) {
+		return vc, nil
+	}
+	var capacities []VehicleCapacity
+	if tx := db.Order("id").Find(&capacities); tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, c := range capacities {
+		if c.TripID != "" {
+			vc.byTrip[c.TripID] = c
+		} else {
+			vc.byRoute[c.RouteID] = c
+		}
+	}
+	return vc, nil
+}
+
+// occupancy returns the occupancy of the vehicle of a departure (nil, if there
+// is no occupancy of its trip).
+func (vc *vehicleCapacities) occupancy(d Departure, occupancies map[string]TripOccupancy) *Occupancy {
+	to, ok := occupancies[d.TripID]
+	if !ok {
+		return nil
+	}
+	o := &Occupancy{Status: to.Status, Percentage: to.Percentage}
+	c, ok := vc.byTrip[d.TripID]
+	if !ok {
+		c = vc.byRoute[d.RouteID]
+	}
+	o.SeatedCapacity, o.StandingCapacity = c.SeatedCapacity, c.StandingCapacity
+	o.Crowding = ExpectedCrowding(*o)
+	return o
+}
@@ -0,0 +1,77 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestExpectedCrowding(t *testing.T) {
+	tests := []struct {
+		name string
+		o    gtfs.Occupancy
+		want gtfs.CrowdingLevel
+	}{
+		{"by status", gtfs.Occupancy{Status: gtfs.OccupancyFewSeatsAvailable, Percentage: 10}, gtfs.CrowdingMedium},
+		{"not boardable", gtfs.Occupancy{Status: gtfs.OccupancyNotBoardable, Percentage: -1}, gtfs.CrowdingFull},
+		{"unknown", gtfs.Occupancy{Status: gtfs.OccupancyNoDataAvailable, Percentage: -1}, gtfs.CrowdingUnknown},
+		{"by percentage", gtfs.Occupancy{Status: gtfs.OccupancyNoDataAvailable, Percentage: 60}, gtfs.CrowdingMedium},
+		{"by capacity, seats", gtfs.Occupancy{Status: gtfs.OccupancyNoDataAvailable, Percentage: 60, SeatedCapacity: 80, StandingCapacity: 20}, gtfs.CrowdingMedium},
+		{"by capacity, standing", gtfs.Occupancy{Status: gtfs.OccupancyNoDataAvailable, Percentage: 60, SeatedCapacity: 30, StandingCapacity: 70}, gtfs.CrowdingHigh},
+		{"by capacity, full", gtfs.Occupancy{Status: gtfs.OccupancyNoDataAvailable, Percentage: 100, SeatedCapacity: 30, StandingCapacity: 70}, gtfs.CrowdingFull},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gtfs.ExpectedCrowding(tt.o); got != tt.want {
+				t.Errorf("ExpectedCrowding() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDepartures_Occupancy(t *testing.T) {
+	db := newFixtureDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	// vehicles of R1 seat 40 (T2 is served by a larger vehicle)
+	feed := t.TempDir()
+	capacities := "route_id,trip_id,seated_capacity,standing_capacity\nR1,,40,60\nR1,T2,100,100\n"
+	if err = os.WriteFile(path.Join(feed, "vehicle_capacities.txt"), []byte(capacities), 0o644); err != nil {
+		t.Fatalf("failed to write capacities: %v", err)
+	}
+	if n, err := gtfs.ImportVehicleCapacities(db, feed); err != nil || n != 2 {
+		t.Fatalf("ImportVehicleCapacities() = %d, %v, want 2", n, err)
+	}
+
+	opts := gtfs.DeparturesOptions{Occupancies: map[string]gtfs.TripOccupancy{
+		"T1": {TripID: "T1", Status: gtfs.OccupancyNoDataAvailable, Percentage: 50},
+		"T2": {TripID: "T2", Status: gtfs.OccupancyNoDataAvailable, Percentage: 40},
+	}}
+	departures, err := gtfs.Departures(db, "S1", time.Date(2022, 3, 1, 7, 55, 0, 0, berlin), berlin, opts)
+	if err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if len(departures) != 2 {
+		t.Fatalf("Departures() = %v, want T1 and T2", departures)
+	}
+	if o := departures[0].Occupancy; o == nil || o.SeatedCapacity != 40 || o.Crowding != gtfs.CrowdingHigh {
+		t.Errorf("Departures() T1 occupancy = %+v, want high crowding", o)
+	}
+	if o := departures[1].Occupancy; o == nil || o.SeatedCapacity != 100 || o.Crowding != gtfs.CrowdingMedium {
+		t.Errorf("Departures() T2 occupancy = %+v, want medium crowding", o)
+	}
+
+	// no occupancy, no annotation
+	departures, err = gtfs.Departures(db, "S1", time.Date(2022, 3, 1, 7, 55, 0, 0, berlin), berlin, gtfs.DeparturesOptions{})
+	if err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if departures[0].Occupancy != nil {
+		t.Errorf("Departures() occupancy = %+v, want none", departures[0].Occupancy)
+	}
+}
@@ -0,0 +1,133 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"math"
+)
+
+// statement to select the stops of the trips having a given shape
+const shapeTripStopsStmt = `
+SELECT
+	trips.id,
+	stop_times.stop_id,
+	stops.latitude,
+	stops.longitude
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+	JOIN stops ON stops.id = stop_times.stop_id
+WHERE
+	trips.shape_id = ?
+ORDER BY
+	trips.id,
+	stop_times.stop_seq;
+`
+
+// DefaultMaxShapeDistance is the distance (in meters) from their shapes the
+// stops of trips may lie at most (see TripsOffShape).
+const DefaultMaxShapeDistance = 100
+
+// TripsOffShape finds trips with stops lying farther than
+// DefaultMaxShapeDistance from the shapes of the trips, i.e. trips most likely
+// assigned the wrong shape.
+func TripsOffShape(db *gorm.DB) ([]Issue, error) {
+	return TripsOffShapeWithin(DefaultMaxShapeDistance)(db)
+}
+
+// TripsOffShapeWithin returns a check (see Rule) finding trips with stops lying
+// farther than maxDistance meters from the shapes of the trips. Each trip is
+// reported once (naming its farthest stop). Trips without shape or referring
+// to missing shapes (see OrphanedRecords) are skipped.
+func TripsOffShapeWithin(maxDistance float64) func(db *gorm.DB) ([]Issue, error) {
+	return func(db *gorm.DB) ([]Issue, error) {
+		var shapeIDs []string
+		if tx := db.Model(&Trip{}).Where("shape_id <> ''").Distinct().Order("shape_id").Pluck("shape_id", &shapeIDs); tx.Error != nil {
+			return nil, tx.Error
+		}
+		var issues []Issue
+		for _, shapeID := range shapeIDs {
+			points, err := ShapeGeometry(db, shapeID)
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to load shape '%s': %w", shapeID, err)
+			}
+			shapeIssues, err := tripsOffShape(db, shapeID, points, maxDistance)
+			if err != nil {
+				return nil, err
+			}
+			issues = append(issues, shapeIssues...)
+		}
+		return issues, nil
+	}
+}
+
+// tripsOffShape finds the trips having the given shape (given by its points)
+// with stops lying farther than maxDistance meters from the shape.
+func tripsOffShape(db *gorm.DB, shapeID string, points []Shape, maxDistance float64) ([]Issue, error) {
+	rows, err := db.Raw(shapeTripStopsStmt, shapeID).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var issues []Issue
+	var tripID, farthestStopID string
+	farthest := -1.0
+	distances := map[string]float64{} // the distances of stops (shared by trips) from the shape
+	report := func() {
+		if farthest > maxDistance {
+			issues = append(issues, Issue{
+				Severity: Warning,
+				ItemType: Trips,
+				ItemID:   tripID,
+				Message:  fmt.Sprintf("stop '%s' lies %.0fm off shape '%s' (at most %.0fm tolerated)", farthestStopID, farthest, shapeID, maxDistance),
+			})
+		}
+		farthest = -1
+	}
+	for rows.Next() {
+		var rowTripID string
+		var stop Stop
+		if err = rows.Scan(&rowTripID, &stop.ID, &stop.Latitude, &stop.Longitude); err != nil {
+			return nil, err
+		}
+		if rowTripID != tripID {
+			report()
+			tripID = rowTripID
+		}
+		d, ok := distances[stop.ID]
+		if !ok {
+			d = shapeDistance(points, stop)
+			distances[stop.ID] = d
+		}
+		if d > farthest {
+			farthest, farthestStopID = d, stop.ID
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	report()
+	return issues, nil
+}
+
+// shapeDistance returns the distance (in meters) of the stop from the nearest
+// segment of the shape given by its points.
+func shapeDistance(points []Shape, s Stop) float64 {
+	if len(points) == 1 {
+		return haversine(points[0].PtLat, points[0].PtLon, s.Latitude, s.Longitude)
+	}
+	nearest := math.Inf(1)
+	for i := 0; i+1 < len(points); i++ {
+		if _, d := project(points[i], points[i+1], s); d < nearest {
+			nearest = d
+		}
+	}
+	return nearest
+}
@@ -0,0 +1,42 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+)
+
+func TestTripsOffShape(t *testing.T) {
+	db := newFixtureDB(t)
+
+	issues, err := gtfs.TripsOffShape(db)
+	if err != nil {
+		t.Fatalf("TripsOffShape() error = %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("TripsOffShape() = %v, want none", issues)
+	}
+
+	// T4 assigned the shape of T1 and T2 (passing B3 at about 1.5km)
+	db.Model(&gtfs.Trip{}).Where("id = ?", "T4").Update("shape_id", "SH1")
+
+	tests := []struct {
+		maxDistance float64
+		wantIssues  int
+	}{
+		{gtfs.DefaultMaxShapeDistance, 1},
+		{5000, 0},
+	}
+	for _, tt := range tests {
+		issues, err = gtfs.TripsOffShapeWithin(tt.maxDistance)(db)
+		if err != nil {
+			t.Fatalf("TripsOffShapeWithin() error = %v", err)
+		}
+		if len(issues) != tt.wantIssues {
+			t.Fatalf("TripsOffShapeWithin(%.0f) = %v, want %d issues", tt.maxDistance, issues, tt.wantIssues)
+		}
+		if tt.wantIssues > 0 && (issues[0].ItemID != "T4" || !strings.Contains(issues[0].Message, "stop 'B3'")) {
+			t.Errorf("TripsOffShapeWithin(%.0f) = %v, want T4 off shape at B3", tt.maxDistance, issues[0])
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// orphanQuery describes how to find orphaned items of a given type.
+type orphanQuery struct {
+	table    string
+	idColumn string
+	where    string
+}
+
+// orphanQueries maps item types to the queries used to find their orphans,
+// i.e. items referring to missing items or items not referred to at all.
+var orphanQueries = map[ItemType]orphanQuery{
+	Routes: {
+		table:    "routes",
+		idColumn: "id",
+		where:    "agency_id <> '' AND agency_id NOT IN (SELECT id FROM agencies)",
+	},
+	Trips: {
+		table:    "trips",
+		idColumn: "id",
+		where:    "route_id NOT IN (SELECT id FROM routes)",
+	},
+	StopTimes: {
+		table:    "stop_times",
+		idColumn: "id",
+		where:    "trip_id NOT IN (SELECT id FROM trips) OR stop_id NOT IN (SELECT id FROM stops)",
+	},
+	Shapes: {
+		table:    "shapes",
+		idColumn: "shape_id",
+		where:    "shape_id NOT IN (SELECT shape_id FROM trips)",
+	},
+	Frequencies: {
+		table:    "frequencies",
+		idColumn: "id",
+		where:    "trip_id NOT IN (SELECT id FROM trips)",
+	},
+}
+
+// Orphans returns the number of orphaned items per item type, i.e. stop times
+// without trip or stop, trips without route, routes without agency, unused
+// shapes and frequencies without trip. Item types without orphans are omitted.
+func Orphans(db *gorm.DB) (map[ItemType]int64, error) {
+	counts := map[ItemType]int64{}
+	for it, q := range orphanQueries {
+		var count int64
+		stmt := fmt.Sprintf("SELECT COUNT(DISTINCT %s) FROM %s WHERE %s", q.idColumn, q.table, q.where)
+		if err := db.Raw(stmt).Scan(&count).Error; err != nil {
+			return nil, fmt.Errorf("failed to count orphaned %s: %w", it, err)
+		}
+		if count > 0 {
+			counts[it] = count
+		}
+	}
+	return counts, nil
+}
+
+// ListOrphans returns the IDs of (at most limit, all if limit is not positive)
+// orphaned items of the given type (see Orphans). Stop times and frequencies
+// are identified by their row ID, shapes by their shape ID.
+func ListOrphans(db *gorm.DB, itemType ItemType, limit int) ([]string, error) {
+	q, ok := orphanQueries[itemType]
+	if !ok {
+		return nil, fmt.Errorf("cannot list orphans of %s", itemType)
+	}
+	stmt := fmt.Sprintf("SELECT DISTINCT %s FROM %s WHERE %s ORDER BY 1", castText(db, q.idColumn), q.table, q.where)
+	if limit > 0 {
+		stmt += fmt.Sprintf(" LIMIT %d", limit)
+	}
+	var ids []string
+	if err := db.Raw(stmt).Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to list orphaned %s: %w", itemType, err)
+	}
+	return ids, nil
+}
+
+// OrphanedRecords finds items referring to missing items and shapes not used
+// by any trip (see Orphans).
+func OrphanedRecords(db *gorm.DB) ([]Issue, error) {
+	var issues []Issue
+	for _, it := range []ItemType{Routes, Trips, StopTimes, Frequencies, Shapes} {
+		ids, err := ListOrphans(db, it, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			message := "refers to a missing item"
+			if it == Shapes {
+				message = "is not used by any trip"
+			}
+			issues = append(issues, Issue{Severity: Warning, ItemType: it, ItemID: id, Message: message})
+		}
+	}
+	return issues, nil
+}
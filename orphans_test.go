@@ -0,0 +1,51 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"reflect"
+	"testing"
+)
+
+func TestOrphans(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// the fixture feed has no orphans
+	counts, err := gtfs.Orphans(db)
+	if err != nil {
+		t.Fatalf("Orphans() error = %v", err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("Orphans() = %v, want none", counts)
+	}
+
+	// removing route R2 orphans trip T4, removing trip T1 orphans its stop times
+	db.Delete(&gtfs.Route{}, "id = ?", "R2")
+	db.Delete(&gtfs.Trip{}, "id = ?", "T1")
+	counts, err = gtfs.Orphans(db)
+	if err != nil {
+		t.Fatalf("Orphans() error = %v", err)
+	}
+	want := map[gtfs.ItemType]int64{gtfs.Trips: 1, gtfs.StopTimes: 4}
+	if !reflect.DeepEqual(counts, want) {
+		t.Errorf("Orphans() = %v, want %v", counts, want)
+	}
+
+	ids, err := gtfs.ListOrphans(db, gtfs.Trips, 10)
+	if err != nil {
+		t.Fatalf("ListOrphans() error = %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"T4"}) {
+		t.Errorf("ListOrphans() = %v, want [T4]", ids)
+	}
+	ids, err = gtfs.ListOrphans(db, gtfs.StopTimes, 2)
+	if err != nil {
+		t.Fatalf("ListOrphans() error = %v", err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("ListOrphans() = %v, want 2 IDs", ids)
+	}
+
+	if _, err = gtfs.ListOrphans(db, gtfs.Agencies, 0); err == nil {
+		t.Errorf("ListOrphans() expected error for agencies")
+	}
+}
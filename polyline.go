@@ -0,0 +1,215 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+	"strings"
+)
+
+// polylinePrecision is the factor coordinates are scaled by in encoded
+// polylines (i.e. five decimal places, about a meter).
+const polylinePrecision = 1e5
+
+// EncodedShape model (see EncodeShapes), i.e. a shape stored as a single row
+// holding its points as Google encoded polyline along with the number of
+// points and the bounding box.
+type EncodedShape struct {
+	ShapeID  string `gorm:"primaryKey"`
+	Polyline string
+	Points   int
+	MinLat   float64
+	MinLon   float64
+	MaxLat   float64
+	MaxLon   float64
+}
+
+// statement to select the IDs of shapes stored as rows of points
+const pointShapeIDsStmt = `
+SELECT DISTINCT
+	shape_id
+FROM
+	shapes
+ORDER BY
+	shape_id;
+`
+
+// statement to remove all encoded shapes that don't belong to any trip
+const delEncodedShapesStmt = `
+DELETE
+FROM
+	encoded_shapes
+WHERE
+	shape_id NOT IN (
+	SELECT DISTINCT
+		shape_id
+	FROM
+		trips);
+`
+
+// EncodeShapes replaces the points (rows) of all shapes by encoded shapes,
+// which cuts the storage of shapes by an order of magnitude. Coordinates are
+// rounded to five decimal places and points are renumbered (from 1), i.e.
+// sequence numbers with gaps are not retained. ShapeGeometry (and thus the
+// functions reading shapes, e.g. RouteShapes or Export) transparently decodes
+// encoded shapes. EncodeShapes returns the number of shapes encoded.
+func EncodeShapes(db *gorm.DB) (int64, error) {
+	var shapeIDs []string
+	if tx := db.Raw(pointShapeIDsStmt).Scan(&shapeIDs); tx.Error != nil {
+		return 0, tx.Error
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, shapeID := range shapeIDs {
+			var points []Shape
+			if res := tx.Order("pt_sequence").Find(&points, "shape_id = ?", shapeID); res.Error != nil {
+				return res.Error
+			}
+			shape := encodeShape(shapeID, points)
+			if res := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&shape); res.Error != nil {
+				return fmt.Errorf("failed to store shape '%s': %w", shapeID, res.Error)
+			}
+			if res := tx.Delete(&Shape{}, "shape_id = ?", shapeID); res.Error != nil {
+				return fmt.Errorf("failed to remove points of shape '%s': %w", shapeID, res.Error)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(shapeIDs)), nil
+}
+
+// ShapeGeometry returns the points (ordered by sequence) of the given shape,
+// decoding encoded shapes (see EncodeShapes). If there is no such shape,
+// gorm.ErrRecordNotFound is returned.
+func ShapeGeometry(db *gorm.DB, shapeID string) ([]Shape, error) {
+	var points []Shape
+	if tx := db.Order("pt_sequence").Find(&points, "shape_id = ?", shapeID); tx.Error != nil {
+		return nil, tx.Error
+	}
+	if len(points) > 0 {
+		return points, nil
+	}
+	points, err := encodedShapes(db, []string{shapeID})
+	if err != nil {
+		return nil, err
+	}
+	if len(points) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return points, nil
+}
+
+// encodedShapes returns the decoded points (ordered by shape and sequence) of
+// all encoded shapes (if the table exists) whose IDs are given (as slice or
+// subquery, if not nil).
+func encodedShapes(db *gorm.DB, shapeIDs interface{}) ([]Shape, error) {
+	if !db.Migrator().HasTable(&EncodedShape{}) {
+		return nil, nil
+	}
+	var shapes []EncodedShape
+	tx := db.Order("shape_id")
+	if shapeIDs != nil {
+		tx = tx.Where("shape_id IN (?)", shapeIDs)
+	}
+	if tx = tx.Find(&shapes); tx.Error != nil {
+		return nil, tx.Error
+	}
+	var points []Shape
+	for _, s := range shapes {
+		decoded, err := s.Decode()
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, decoded...)
+	}
+	return points, nil
+}
+
+// Decode returns the points of the encoded shape.
+func (s EncodedShape) Decode() ([]Shape, error) {
+	points := make([]Shape, 0, s.Points)
+	var lat, lon int64
+	for i := 0; i < len(s.Polyline); {
+		var deltas [2]int64
+		for j := range deltas {
+			var result int64
+			var shift uint
+			for {
+				if i >= len(s.Polyline) {
+					return nil, fmt.Errorf("truncated polyline of shape '%s'", s.ShapeID)
+				}
+				b := int64(s.Polyline[i]) - 63
+				i++
+				if b < 0 || shift > 60 {
+					return nil, fmt.Errorf("invalid polyline of shape '%s'", s.ShapeID)
+				}
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[j] = ^(result >> 1)
+			} else {
+				deltas[j] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lon += deltas[1]
+		points = append(points, Shape{
+			ShapeID:    s.ShapeID,
+			PtLat:      float64(lat) / polylinePrecision,
+			PtLon:      float64(lon) / polylinePrecision,
+			PtSequence: len(points) + 1,
+		})
+	}
+	if len(points) == 0 {
+		return nil, fmt.Errorf("empty polyline of shape '%s'", s.ShapeID)
+	}
+	return points, nil
+}
+
+// encodeShape returns the given points (ordered by sequence) of a shape as
+// encoded shape.
+func encodeShape(shapeID string, points []Shape) EncodedShape {
+	shape := EncodedShape{ShapeID: shapeID, Points: len(points)}
+	var sb strings.Builder
+	var prevLat, prevLon int64
+	for i, p := range points {
+		lat, lon := int64(math.Round(p.PtLat*polylinePrecision)), int64(math.Round(p.PtLon*polylinePrecision))
+		writePolylineValue(&sb, lat-prevLat)
+		writePolylineValue(&sb, lon-prevLon)
+		prevLat, prevLon = lat, lon
+		if i == 0 || p.PtLat < shape.MinLat {
+			shape.MinLat = p.PtLat
+		}
+		if i == 0 || p.PtLat > shape.MaxLat {
+			shape.MaxLat = p.PtLat
+		}
+		if i == 0 || p.PtLon < shape.MinLon {
+			shape.MinLon = p.PtLon
+		}
+		if i == 0 || p.PtLon > shape.MaxLon {
+			shape.MaxLon = p.PtLon
+		}
+	}
+	shape.Polyline = sb.String()
+	return shape
+}
+
+// writePolylineValue writes a (scaled) value to an encoded polyline.
+func writePolylineValue(sb *strings.Builder, v int64) {
+	u := v << 1
+	if v < 0 {
+		u = ^u
+	}
+	for u >= 0x20 {
+		sb.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	sb.WriteByte(byte(u + 63))
+}
@@ -0,0 +1,95 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeShapes(t *testing.T) {
+	db := newFixtureDB(t)
+	want, err := gtfs.ShapeGeometry(db, "SH1")
+	if err != nil {
+		t.Fatalf("ShapeGeometry() error = %v", err)
+	}
+	wantRoute, err := gtfs.RouteShapes(db, "R1")
+	if err != nil {
+		t.Fatalf("RouteShapes() error = %v", err)
+	}
+	wantTile, err := gtfs.VectorTile(db, 10, 549, 336)
+	if err != nil {
+		t.Fatalf("VectorTile() error = %v", err)
+	}
+
+	n, err := gtfs.EncodeShapes(db)
+	if err != nil {
+		t.Fatalf("EncodeShapes() error = %v", err)
+	}
+	if n != 3 {
+		t.Errorf("EncodeShapes() = %d, want 3", n)
+	}
+	var points int64
+	db.Model(&gtfs.Shape{}).Count(&points)
+	if points != 0 {
+		t.Errorf("EncodeShapes() left %d points", points)
+	}
+	var shape gtfs.EncodedShape
+	db.First(&shape, "shape_id = ?", "SH1")
+	if shape.Points != len(want) || shape.MinLat != 52.421 || shape.MaxLon < shape.MinLon {
+		t.Errorf("EncodeShapes() = %+v", shape)
+	}
+
+	// decoded transparently (IDs aside)
+	got, err := gtfs.ShapeGeometry(db, "SH1")
+	if err != nil {
+		t.Fatalf("ShapeGeometry() error = %v", err)
+	}
+	for i := range want {
+		want[i].ID = 0
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ShapeGeometry() = %v, want %v", got, want)
+	}
+	gotRoute, err := gtfs.RouteShapes(db, "R1")
+	if err != nil {
+		t.Fatalf("RouteShapes() error = %v", err)
+	}
+	if len(gotRoute) != len(wantRoute) || len(gotRoute["SH1"]) != len(wantRoute["SH1"]) {
+		t.Errorf("RouteShapes() = %v, want %v", gotRoute, wantRoute)
+	}
+	gotTile, err := gtfs.VectorTile(db, 10, 549, 336)
+	if err != nil {
+		t.Fatalf("VectorTile() error = %v", err)
+	}
+	if !reflect.DeepEqual(gotTile, wantTile) {
+		t.Errorf("VectorTile() differs after encoding shapes")
+	}
+	if _, err = gtfs.ShapeGeometry(db, "missing"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("ShapeGeometry() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestImportWithOptions_EncodeShapes(t *testing.T) {
+	db := newTestDB(t)
+	gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{EncodeShapes: true})
+
+	// exporting decodes the shapes
+	shapes := func(db *gorm.DB) string {
+		dir := t.TempDir()
+		if err := gtfs.Export(db, dir, gtfs.ExportOptions{}); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+		b, err := os.ReadFile(path.Join(dir, "shapes.txt"))
+		if err != nil {
+			t.Fatalf("failed to read shapes: %v", err)
+		}
+		return string(b)
+	}
+	if got, want := shapes(db), shapes(newFixtureDB(t)); got != want {
+		t.Errorf("Export() shapes = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,216 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// ImportProfile encapsulates normalizations addressing known quirks of the
+// feeds of a country or publisher (see ImportProfiles), applied when
+// importing (see ImportOptions).
+type ImportProfile struct {
+
+	// Name is the name of the profile.
+	Name string
+
+	// StripIDPrefixes are prefixes removed from IDs (and references to them,
+	// see idColumns).
+	StripIDPrefixes []string
+
+	// BasicRouteTypes maps extended route types to basic ones (see
+	// BasicRouteType) for consumers not supporting extended route types.
+	BasicRouteTypes bool
+
+	// RepairEncoding repairs text encoded as UTF-8 twice (e.g. "MÃ¼nchen").
+	RepairEncoding bool
+
+	// MergeAgencies merges agencies sharing name and URL into the one with the
+	// lowest ID (rewriting the agencies of routes accordingly).
+	MergeAgencies bool
+}
+
+// ImportProfiles are the known import profiles (by name).
+var ImportProfiles = map[string]ImportProfile{
+	"delfi": {
+		Name:            "delfi",
+		BasicRouteTypes: true,
+		RepairEncoding:  true,
+		MergeAgencies:   true,
+	},
+	"nl": {
+		Name:            "nl",
+		StripIDPrefixes: []string{"IFF:"},
+		BasicRouteTypes: true,
+	},
+	"transitfeeds-generic": {
+		Name:            "transitfeeds-generic",
+		BasicRouteTypes: true,
+		RepairEncoding:  true,
+	},
+}
+
+// LookupImportProfile returns the import profile with the given name.
+func LookupImportProfile(name string) (*ImportProfile, error) {
+	p, ok := ImportProfiles[name]
+	if !ok {
+		names := make([]string, 0, len(ImportProfiles))
+		for n := range ImportProfiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown import profile '%s' (known: %s)", name, strings.Join(names, ", "))
+	}
+	return &p, nil
+}
+
+// BasicRouteType maps an extended route type (e.g. 109 for suburban railway)
+// to the corresponding basic one (e.g. 2 for rail). Basic route types and
+// extended route types without a basic counterpart are returned unchanged.
+func BasicRouteType(routeType int) int {
+	switch {
+	case routeType >= 100 && routeType < 200:
+		return 2 // railway
+	case routeType >= 200 && routeType < 300:
+		return 3 // coach
+	case routeType >= 400 && routeType < 500:
+		return 1 // urban railway, metro, underground
+	case routeType >= 700 && routeType < 800:
+		return 3 // bus
+	case routeType >= 800 && routeType < 900:
+		return 11 // trolleybus
+	case routeType >= 900 && routeType < 1000:
+		return 0 // tram
+	case routeType >= 1000 && routeType < 1300:
+		return 4 // water transport
+	case routeType >= 1300 && routeType < 1400:
+		return 6 // aerial lift
+	case routeType >= 1400 && routeType < 1500:
+		return 7 // funicular
+	default:
+		return routeType
+	}
+}
+
+// idColumns are the columns holding IDs or references to them (see
+// ImportProfile.StripIDPrefixes).
+var idColumns = map[string]bool{
+	"agency_id":      true,
+	"route_id":       true,
+	"trip_id":        true,
+	"stop_id":        true,
+	"shape_id":       true,
+	"service_id":     true,
+	"block_id":       true,
+	"parent_station": true,
+}
+
+// normalize applies the profile to an item (a pointer to a model) and returns
+// descriptions of the changes made (if any).
+func (p *ImportProfile) normalize(item reflect.Value) (changes []string) {
+	v := item.Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := v.Field(i)
+		if f.Kind() != reflect.String {
+			continue
+		}
+		s := f.String()
+		if idColumns[columnName(t.Field(i))] {
+			for _, prefix := range p.StripIDPrefixes {
+				s = strings.TrimPrefix(s, prefix)
+			}
+		} else if p.RepairEncoding {
+			s = repairEncoding(s)
+		}
+		if s != f.String() {
+			changes = append(changes, fmt.Sprintf("%s '%s' -> '%s'", columnName(t.Field(i)), f.String(), s))
+			f.SetString(s)
+		}
+	}
+	if route, ok := item.Interface().(*Route); ok && p.BasicRouteTypes {
+		if basic := BasicRouteType(route.Type); basic != route.Type {
+			changes = append(changes, fmt.Sprintf("route_type %d -> %d", route.Type, basic))
+			route.Type = basic
+		}
+	}
+	return changes
+}
+
+// fillRouteNames fills the empty short name of a route (an item, i.e. a pointer
+// to a model) with its long name or vice versa and returns descriptions of the
+// changes made (if any). Items other than routes are left unchanged.
+func fillRouteNames(item reflect.Value) []string {
+	route, ok := item.Interface().(*Route)
+	if !ok {
+		return nil
+	}
+	switch {
+	case route.ShortName == "" && route.LongName != "":
+		route.ShortName = route.LongName
+		return []string{fmt.Sprintf("route_short_name '' -> '%s'", route.ShortName)}
+	case route.LongName == "" && route.ShortName != "":
+		route.LongName = route.ShortName
+		return []string{fmt.Sprintf("route_long_name '' -> '%s'", route.LongName)}
+	default:
+		return nil
+	}
+}
+
+// columnName returns the CSV column of a field (its name, if not tagged).
+func columnName(f reflect.StructField) string {
+	if c := f.Tag.Get("csv"); c != "" {
+		return c
+	}
+	return f.Name
+}
+
+// repairEncoding repairs text encoded as UTF-8 twice (i.e. UTF-8 decoded as
+// Latin-1 and encoded as UTF-8 again). Other text is returned unchanged.
+func repairEncoding(s string) string {
+	if !strings.ContainsAny(s, "ÃÂ") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r > 0xFF {
+			return s
+		}
+		b = append(b, byte(r))
+	}
+	if !utf8.Valid(b) {
+		return s
+	}
+	return string(b)
+}
+
+// statements to merge agencies sharing name and URL (the agencies kept are
+// selected via a derived table, as MySQL refuses to delete from a table
+// selected from in a subquery)
+const (
+	mergeAgencyRoutesStmt = `
+UPDATE routes SET agency_id = (
+	SELECT MIN(other.id)
+	FROM agencies AS agency JOIN agencies AS other ON other.name = agency.name AND other.url = agency.url
+	WHERE agency.id = routes.agency_id)
+WHERE agency_id IN (SELECT id FROM agencies);
+`
+	mergeAgenciesStmt = `
+DELETE FROM agencies WHERE id NOT IN (
+	SELECT id FROM (SELECT MIN(id) AS id FROM agencies GROUP BY name, url) AS kept);
+`
+)
+
+// mergeAgencies merges agencies sharing name and URL into the one with the
+// lowest ID.
+func mergeAgencies(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(mergeAgencyRoutesStmt).Error; err != nil {
+			return err
+		}
+		return tx.Exec(mergeAgenciesStmt).Error
+	})
+}
@@ -0,0 +1,160 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestImportWithOptions_Profile(t *testing.T) {
+
+	// a feed with duplicate agencies, extended route types, twice encoded
+	// umlauts and prefixed IDs (including references to parent stations)
+	feed := t.TempDir()
+	files := map[string]string{
+		"agency.txt":         "agency_id,agency_name,agency_url\nX:1,BVG,https://www.bvg.de/\nX:2,BVG,https://www.bvg.de/\n",
+		"routes.txt":         "route_id,agency_id,route_short_name,route_long_name,route_type\nX:R1,X:1,M1,Mitte - RosenthalÃ¤,900\nX:R2,X:2,100,Zoo - Alex,700\n",
+		"trips.txt":          "route_id,service_id,trip_id\nX:R1,WD,X:T1\n",
+		"stops.txt":          "stop_id,stop_name,stop_lat,stop_lon,location_type,parent_station\nX:S1,MÃ¼nchener Str.,52.5,13.4,1,\nX:S1a,MÃ¼nchener Str.,52.5,13.4,0,X:S1\n",
+		"stop_times.txt":     "trip_id,arrival_time,departure_time,stop_id,stop_sequence\nX:T1,08:00:00,08:00:00,X:S1,1\n",
+		"shapes.txt":         "shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence\n",
+		"calendar.txt":       "service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date\nWD,1,1,1,1,1,0,0,20220101,20221231\n",
+		"calendar_dates.txt": "service_id,date,exception_type\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(feed, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+
+	profile := gtfs.ImportProfile{
+		StripIDPrefixes: []string{"X:"},
+		BasicRouteTypes: true,
+		RepairEncoding:  true,
+		MergeAgencies:   true,
+	}
+
+	// merging agencies deletes from the table it selects from (which MySQL
+	// requires to be wrapped into a derived table)
+	tests := []struct {
+		name  string
+		newDB func(t *testing.T) *gorm.DB
+	}{
+		{"sqlite", func(t *testing.T) *gorm.DB { return newTestDB(t) }},
+		{"postgres", func(t *testing.T) *gorm.DB { return newServerDB(t, "GTFS_TEST_POSTGRES_DSN", postgres.Open) }},
+		{"mysql", func(t *testing.T) *gorm.DB { return newServerDB(t, "GTFS_TEST_MYSQL_DSN", mysql.Open) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := tt.newDB(t)
+			gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+				Profile: &profile,
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.Error != nil {
+						t.Errorf("ImportWithOptions() error = %v", e.Result.Error)
+					}
+				},
+			})
+
+			var agencies []gtfs.Agency
+			db.Find(&agencies)
+			if len(agencies) != 1 || agencies[0].ID != "1" {
+				t.Errorf("ImportWithOptions() agencies = %v, want only agency 1", agencies)
+			}
+			var routes []gtfs.Route
+			db.Order("id").Find(&routes)
+			if len(routes) != 2 || routes[0].ID != "R1" || routes[0].Type != 0 || routes[1].Type != 3 || routes[1].AgencyID != "1" {
+				t.Errorf("ImportWithOptions() routes = %+v, want R1 (tram) and R2 (bus) of agency 1", routes)
+			}
+			var stop gtfs.Stop
+			db.First(&stop, "id = ?", "S1")
+			if stop.ID != "S1" || stop.Name != "Münchener Str." {
+				t.Errorf("ImportWithOptions() stop = %+v, want S1 'Münchener Str.'", stop)
+			}
+			if children, err := gtfs.StationChildren(db, "S1"); err != nil || len(children) != 1 || children[0].ID != "S1a" {
+				t.Errorf("StationChildren() = %+v, %v, want S1a", children, err)
+			}
+			var stopTime gtfs.StopTime
+			db.First(&stopTime)
+			if stopTime.TripID != "T1" || stopTime.StopID != "S1" {
+				t.Errorf("ImportWithOptions() stop time = %+v, want T1 at S1", stopTime)
+			}
+		})
+	}
+}
+
+func TestLookupImportProfile(t *testing.T) {
+	for name := range gtfs.ImportProfiles {
+		p, err := gtfs.LookupImportProfile(name)
+		if err != nil || p.Name != name {
+			t.Errorf("LookupImportProfile(%q) = %v, %v", name, p, err)
+		}
+	}
+	if _, err := gtfs.LookupImportProfile("xx"); err == nil {
+		t.Errorf("LookupImportProfile() expected error")
+	}
+}
+
+func TestBasicRouteType(t *testing.T) {
+	tests := []struct {
+		routeType int
+		want      int
+	}{
+		{3, 3},
+		{109, 2},
+		{400, 1},
+		{700, 3},
+		{900, 0},
+		{1000, 4},
+		{1700, 1700},
+	}
+	for _, tt := range tests {
+		if got := gtfs.BasicRouteType(tt.routeType); got != tt.want {
+			t.Errorf("BasicRouteType(%d) = %d, want %d", tt.routeType, got, tt.want)
+		}
+	}
+}
+
+func TestImportWithOptions_FillRouteNames(t *testing.T) {
+	feed := alteredFeed(t, map[string]func([]byte) []byte{"routes.txt": func([]byte) []byte {
+		return []byte("route_id,agency_id,route_short_name,route_long_name,route_type\nR1,1,,S Wannsee - S Rathaus Steglitz,109\nR2,2,218,,3\nR3,2,X10,Zoo - Teltow,3\n")
+	}})
+
+	tests := []struct {
+		name           string
+		fill           bool
+		want           []string
+		wantNormalized int64
+	}{
+		{"fill", true, []string{"S Wannsee - S Rathaus Steglitz|S Wannsee - S Rathaus Steglitz", "218|218", "X10|Zoo - Teltow"}, 2},
+		{"keep", false, []string{"|S Wannsee - S Rathaus Steglitz", "218|", "X10|Zoo - Teltow"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			summary := gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{FillRouteNames: tt.fill})
+			if err := summary.Err(); err != nil {
+				t.Fatalf("ImportWithOptions() error = %v", err)
+			}
+			var routes []gtfs.Route
+			db.Order("id").Find(&routes)
+			var got []string
+			for _, r := range routes {
+				got = append(got, r.ShortName+"|"+r.LongName)
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("ImportWithOptions() routes = %q, want %q", got, tt.want)
+			}
+			for _, r := range summary.Results {
+				if r.ItemType == gtfs.Routes && r.Normalized != tt.wantNormalized {
+					t.Errorf("ImportWithOptions() normalized routes = %d, want %d", r.Normalized, tt.wantNormalized)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package gtfs
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultProgressInterval is the default interval of reporting the progress
+// of importing a file (see ImportOptions.ProgressInterval).
+const defaultProgressInterval = time.Second
+
+// FileProgress describes the progress of importing a single file (see
+// ImportOptions.OnFileProgress).
+type FileProgress struct {
+	ItemType  ItemType
+	Path      string
+	Rows      int64 // the rows read so far
+	BytesRead int64 // the bytes of the file read so far
+	FileSize  int64 // the size of the file in bytes (0, if unknown)
+	Elapsed   time.Duration
+}
+
+// Fraction returns the fraction of the file read (between 0 and 1, -1 if the
+// size of the file is unknown).
+func (fp FileProgress) Fraction() float64 {
+	if fp.FileSize <= 0 {
+		return -1
+	}
+	if fp.BytesRead >= fp.FileSize {
+		return 1
+	}
+	return float64(fp.BytesRead) / float64(fp.FileSize)
+}
+
+// EstimatedRows returns the estimated number of rows of the file,
+// extrapolating the rows read so far by the bytes read (0, if unknown).
+func (fp FileProgress) EstimatedRows() int64 {
+	if fp.FileSize <= 0 || fp.BytesRead <= 0 {
+		return 0
+	}
+	return int64(float64(fp.Rows) / fp.Fraction())
+}
+
+// ETA returns the estimated time left to import the file, extrapolating the
+// time elapsed by the bytes read (-1, if unknown).
+func (fp FileProgress) ETA() time.Duration {
+	fraction := fp.Fraction()
+	if fraction <= 0 {
+		return -1
+	}
+	eta := time.Duration(float64(fp.Elapsed)/fraction) - fp.Elapsed
+	return eta.Round(time.Second)
+}
+
+// String returns a human-readable representation of FileProgress (e.g.
+// "importing Stop Times: 45.2% (1234567 of about 2731000 rows), about 3m12s
+// left").
+func (fp FileProgress) String() string {
+	if fp.Fraction() < 0 {
+		return fmt.Sprintf("importing %s: %d rows", fp.ItemType, fp.Rows)
+	}
+	return fmt.Sprintf("importing %s: %.1f%% (%d of about %d rows), about %s left", fp.ItemType, fp.Fraction()*100, fp.Rows, fp.EstimatedRows(), fp.ETA())
+}
@@ -0,0 +1,91 @@
+package gtfs_test
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileProgress(t *testing.T) {
+	tests := []struct {
+		name     string
+		fp       gtfs.FileProgress
+		wantRows int64
+		wantETA  time.Duration
+		wantStr  string
+	}{
+		{
+			name:     "a quarter",
+			fp:       gtfs.FileProgress{ItemType: gtfs.StopTimes, Rows: 1000, BytesRead: 250, FileSize: 1000, Elapsed: time.Minute},
+			wantRows: 4000,
+			wantETA:  3 * time.Minute,
+			wantStr:  "importing Stop Times: 25.0% (1000 of about 4000 rows), about 3m0s left",
+		},
+		{
+			name:    "unknown size",
+			fp:      gtfs.FileProgress{ItemType: gtfs.StopTimes, Rows: 1000, BytesRead: 250, Elapsed: time.Minute},
+			wantETA: -1,
+			wantStr: "importing Stop Times: 1000 rows",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fp.EstimatedRows(); got != tt.wantRows {
+				t.Errorf("EstimatedRows() = %d, want %d", got, tt.wantRows)
+			}
+			if got := tt.fp.ETA(); got != tt.wantETA {
+				t.Errorf("ETA() = %s, want %s", got, tt.wantETA)
+			}
+			if got := tt.fp.String(); got != tt.wantStr {
+				t.Errorf("String() = %q, want %q", got, tt.wantStr)
+			}
+		})
+	}
+}
+
+func TestImportWithOptions_OnFileProgress(t *testing.T) {
+
+	// a feed of 3000 stop times
+	feed := t.TempDir()
+	for _, name := range []string{"agency.txt", "routes.txt", "trips.txt", "stops.txt", "shapes.txt", "calendar.txt", "calendar_dates.txt"} {
+		b, err := os.ReadFile(path.Join(fixtureFeed, name))
+		if err != nil {
+			t.Fatalf("failed to read fixture: %v", err)
+		}
+		if err = os.WriteFile(path.Join(feed, name), b, 0o644); err != nil {
+			t.Fatalf("failed to write feed: %v", err)
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("trip_id,arrival_time,departure_time,stop_id,stop_sequence\n")
+	for i := 1; i <= 3000; i++ {
+		sb.WriteString(fmt.Sprintf("T1,08:00:00,08:00:00,S1,%d\n", i))
+	}
+	if err := os.WriteFile(path.Join(feed, "stop_times.txt"), []byte(sb.String()), 0o644); err != nil {
+		t.Fatalf("failed to write feed: %v", err)
+	}
+
+	db := newTestDB(t)
+	var progress []gtfs.FileProgress
+	gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{
+		OnFileProgress: func(fp gtfs.FileProgress) {
+			progress = append(progress, fp)
+		},
+		ProgressInterval: time.Nanosecond,
+	})
+	if len(progress) != 3 {
+		t.Fatalf("OnFileProgress() called %d times, want 3", len(progress))
+	}
+	for i, fp := range progress {
+		if fp.ItemType != gtfs.StopTimes || fp.Rows != int64(i+1)*1000 || fp.FileSize != int64(sb.Len()) {
+			t.Errorf("OnFileProgress() = %+v, want %d of 3000 stop times", fp, (i+1)*1000)
+		}
+		if fp.BytesRead <= 0 || fp.BytesRead > fp.FileSize || (i > 0 && fp.BytesRead < progress[i-1].BytesRead) {
+			t.Errorf("OnFileProgress() read %d of %d bytes", fp.BytesRead, fp.FileSize)
+		}
+	}
+}
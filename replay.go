@@ -0,0 +1,197 @@
+package gtfs
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ReplayEventKind enumerates the kinds of events of a replay.
+type ReplayEventKind uint32
+
+const (
+
+	// ReplayDeparture the kind of events of trips departing from a stop.
+	ReplayDeparture ReplayEventKind = iota
+
+	// ReplayArrival the kind of events of trips arriving at a stop.
+	ReplayArrival
+)
+
+var txReplayEventKind = map[ReplayEventKind]string{
+	ReplayDeparture: "departure",
+	ReplayArrival:   "arrival",
+}
+
+// String returns a human-readable representation of ReplayEventKind.
+func (k ReplayEventKind) String() string {
+	if s := txReplayEventKind[k]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ReplayEventKind (%d)", uint32(k))
+}
+
+// ReplayEvent describes a trip departing from or arriving at a stop.
+type ReplayEvent struct {
+	Time    time.Time
+	Kind    ReplayEventKind
+	TripID  string
+	RouteID string
+	StopID  string
+	StopSeq int
+}
+
+// String returns a human-readable representation of ReplayEvent (e.g.
+// "08:00:00 departure of trip T1 (route R1) from stop S1").
+func (e ReplayEvent) String() string {
+	preposition := "from"
+	if e.Kind == ReplayArrival {
+		preposition = "at"
+	}
+	return fmt.Sprintf("%s %s of trip %s (route %s) %s stop %s", e.Time.Format("15:04:05"), e.Kind, e.TripID, e.RouteID, preposition, e.StopID)
+}
+
+// statement to select the arrivals (but at the first stop) and departures (but
+// from the last stop) of the trips of the given services, ordered by time (the
+// filter is filled in twice)
+const replayStmt = `
+WITH bounds AS (
+	SELECT
+		trip_id,
+		MIN(stop_seq) AS first_seq,
+		MAX(stop_seq) AS last_seq
+	FROM
+		stop_times
+	GROUP BY
+		trip_id)
+SELECT
+	stop_times.arrival AS time,
+	1 AS kind,
+	stop_times.trip_id AS trip_id,
+	trips.route_id,
+	stop_times.stop_id,
+	stop_times.stop_seq AS stop_seq
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+	JOIN bounds ON bounds.trip_id = stop_times.trip_id
+WHERE
+	trips.service_id IN ? AND
+	stop_times.stop_seq > bounds.first_seq%s
+UNION ALL
+SELECT
+	stop_times.departure AS time,
+	0 AS kind,
+	stop_times.trip_id AS trip_id,
+	trips.route_id,
+	stop_times.stop_id,
+	stop_times.stop_seq AS stop_seq
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+	JOIN bounds ON bounds.trip_id = stop_times.trip_id
+WHERE
+	trips.service_id IN ? AND
+	stop_times.stop_seq < bounds.last_seq%s
+ORDER BY
+	time,
+	kind DESC,
+	trip_id,
+	stop_seq;
+`
+
+// Replay is a replay of the trips of a service day (see ReplayDay).
+type Replay struct {
+
+	// Events are the events of the replay (ordered by time). The channel is
+	// closed when the replay is done (see Err).
+	Events <-chan ReplayEvent
+
+	err error
+}
+
+// Err returns the error stopping the replay (nil, if all events were sent).
+// Err must not be called before Events is closed.
+func (r *Replay) Err() error {
+	return r.err
+}
+
+// ReplayDay replays the trips (of the given routes, of all routes if none are
+// given) running on the service day of date (according to calendars and
+// calendar dates, see ActiveServices), i.e. sends the arrivals (but at the
+// first stop) and departures (but from the last stop) of the trips as events
+// (ordered by time) through a channel. GTFS times are interpreted in the
+// location of date (see ServiceTime). If speedup is > 0, events are sent
+// along a simulated clock starting at the first event and running speedup
+// times faster than real time (e.g. 60 replays an hour per minute), otherwise
+// events are sent as fast as they are received. The replay is stopped when
+// the context of db is done. Trips defined by frequencies are not expanded.
+func ReplayDay(db *gorm.DB, date time.Time, speedup float64, routeIDs ...string) (*Replay, error) {
+	y, m, d := date.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, date.Location())
+	services, err := ActiveServices(db, day)
+	if err != nil {
+		return nil, err
+	}
+
+	// start selecting the events
+	var filter string
+	args := []interface{}{services}
+	if len(routeIDs) > 0 {
+		filter = " AND\n\ttrips.route_id IN ?"
+		args = append(args, routeIDs)
+	}
+	args = append(args, args...)
+	rows, err := db.Raw(fmt.Sprintf(replayStmt, filter, filter), args...).Rows()
+	if err != nil {
+		return nil, err
+	}
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	// send the events (along the simulated clock, if desired)
+	events := make(chan ReplayEvent)
+	replay := &Replay{Events: events}
+	go func() {
+		defer close(events)
+		defer func() {
+			_ = rows.Close()
+		}()
+		var start, first time.Time
+		for rows.Next() {
+			var e ReplayEvent
+			var t DateTime
+			if replay.err = rows.Scan(&t, &e.Kind, &e.TripID, &e.RouteID, &e.StopID, &e.StopSeq); replay.err != nil {
+				return
+			}
+			e.Time = ServiceTime(day, int(t.Int32))
+			if speedup > 0 {
+				if first.IsZero() {
+					start, first = time.Now(), e.Time
+				}
+				due := start.Add(time.Duration(float64(e.Time.Sub(first)) / speedup))
+				if wait := time.Until(due); wait > 0 {
+					timer := time.NewTimer(wait)
+					select {
+					case <-timer.C:
+					case <-ctx.Done():
+						timer.Stop()
+						replay.err = ctx.Err()
+						return
+					}
+				}
+			}
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				replay.err = ctx.Err()
+				return
+			}
+		}
+		replay.err = rows.Err()
+	}()
+	return replay, nil
+}
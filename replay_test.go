@@ -0,0 +1,105 @@
+package gtfs_test
+
+import (
+	"context"
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+)
+
+func TestReplayDay(t *testing.T) {
+	db := newFixtureDB(t)
+	tests := []struct {
+		name      string
+		date      time.Time
+		routeIDs  []string
+		wantCount int
+		wantFirst string
+		wantLast  string
+	}{
+		{
+			name:      "weekday",
+			date:      time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC),
+			wantCount: 18,
+			wantFirst: "08:00:00 departure of trip T1 (route R1) from stop S1",
+			wantLast:  "09:14:00 arrival of trip T3 (route R1) at stop S1",
+		},
+		{
+			name:      "weekend",
+			date:      time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC),
+			wantCount: 4,
+			wantFirst: "10:00:00 departure of trip T4 (route R2) from stop B1",
+			wantLast:  "10:09:00 arrival of trip T4 (route R2) at stop B3",
+		},
+		{
+			name:      "weekday of other route",
+			date:      time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+			routeIDs:  []string{"R2"},
+			wantCount: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replay, err := gtfs.ReplayDay(db, tt.date, 0, tt.routeIDs...)
+			if err != nil {
+				t.Fatalf("ReplayDay() error = %v", err)
+			}
+			var events []gtfs.ReplayEvent
+			for e := range replay.Events {
+				if n := len(events); n > 0 && e.Time.Before(events[n-1].Time) {
+					t.Errorf("ReplayDay() sent %v after %v", e, events[n-1])
+				}
+				events = append(events, e)
+			}
+			if err = replay.Err(); err != nil {
+				t.Fatalf("Err() = %v", err)
+			}
+			if len(events) != tt.wantCount {
+				t.Fatalf("ReplayDay() sent %d events, want %d", len(events), tt.wantCount)
+			}
+			if tt.wantCount == 0 {
+				return
+			}
+			if got := events[0].String(); got != tt.wantFirst {
+				t.Errorf("ReplayDay() first event = %q, want %q", got, tt.wantFirst)
+			}
+			if got := events[len(events)-1].String(); got != tt.wantLast {
+				t.Errorf("ReplayDay() last event = %q, want %q", got, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestReplayDay_Speedup(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// replaying the 9 minutes of trip T4 within 20ms
+	start := time.Now()
+	replay, err := gtfs.ReplayDay(db, time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC), 27000)
+	if err != nil {
+		t.Fatalf("ReplayDay() error = %v", err)
+	}
+	for range replay.Events {
+	}
+	if err = replay.Err(); err != nil {
+		t.Fatalf("Err() = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("ReplayDay() took %s, want at least 20ms", elapsed)
+	}
+
+	// stopping the replay
+	ctx, cancel := context.WithCancel(context.Background())
+	replay, err = gtfs.ReplayDay(db.WithContext(ctx), time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC), 1)
+	if err != nil {
+		t.Fatalf("ReplayDay() error = %v", err)
+	}
+	<-replay.Events
+	cancel()
+	for range replay.Events {
+	}
+	if err = replay.Err(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Err() = %v, want %v", err, context.Canceled)
+	}
+}
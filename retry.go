@@ -0,0 +1,177 @@
+package gtfs
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"gorm.io/gorm"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// statementCancelKey is the key used to store the function cancelling the
+// context of a statement.
+const statementCancelKey = "gtfs:statement_cancel"
+
+// RetryPolicy bounds the time and the number of attempts of operations (e.g.
+// importing a file, see ImportOptions.Retry, or a statement trimming a DB, see
+// TrimOptions.Retry). Attempts failing with transient errors (see IsTransient)
+// are retried. A nil RetryPolicy makes a single attempt without time limit.
+type RetryPolicy struct {
+
+	// Attempts is the maximum number of attempts (at least one attempt is
+	// made).
+	Attempts int
+
+	// Backoff is the delay before the second attempt, doubling with each
+	// further attempt.
+	Backoff time.Duration
+
+	// Timeout (if > 0) is the time budget of each attempt. Attempts exceeding
+	// it fail (with context.DeadlineExceeded) and are not retried.
+	Timeout time.Duration
+}
+
+// Do calls fn (with a context derived from ctx, limited by the timeout of the
+// policy) until it succeeds, fails with an error that isn't transient, the
+// attempts are exhausted or ctx is done. Do returns the error of the last
+// attempt.
+func (rp *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if rp == nil {
+		return fn(ctx)
+	}
+	backoff := rp.Backoff
+	for attempt := 1; ; attempt++ {
+		err := rp.attempt(ctx, fn)
+		if err == nil || attempt >= rp.Attempts || !IsTransient(err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// attempt calls fn once (limited by the timeout of the policy).
+func (rp *RetryPolicy) attempt(ctx context.Context, fn func(ctx context.Context) error) error {
+	if rp.Timeout <= 0 {
+		return fn(ctx)
+	}
+	ctx, cancel := context.WithTimeout(ctx, rp.Timeout)
+	defer cancel()
+	return fn(ctx)
+}
+
+// dbContext returns the context of db (defaulting to context.Background()).
+func dbContext(db *gorm.DB) context.Context {
+	if db.Statement.Context == nil {
+		return context.Background()
+	}
+	return db.Statement.Context
+}
+
+// transientMessages are (parts of) the messages of transient errors not
+// identifiable otherwise (e.g. SQLITE_BUSY).
+var transientMessages = []string{
+	"database is locked",
+	"database table is locked",
+	"SQLITE_BUSY",
+	"connection reset by peer",
+	"broken pipe",
+}
+
+// IsTransient returns true, if err is likely to be transient, i.e. a busy or
+// locked SQLite DB, a connection to a DB failing (e.g. Postgres restarting)
+// or a transaction failing to serialize (or deadlocking) on Postgres.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+
+	// SQLSTATE of Postgres errors (e.g. pgconn.PgError): connection
+	// exceptions, serialization failures, deadlocks and shutdowns
+	var stateErr interface{ SQLState() string }
+	if errors.As(err, &stateErr) {
+		state := stateErr.SQLState()
+		return strings.HasPrefix(state, "08") || state == "40001" || state == "40P01" || state == "57P01" || state == "57P03"
+	}
+
+	msg := err.Error()
+	for _, m := range transientMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// StatementTimeout is a gorm plugin limiting the duration of each statement
+// (cancelling statements running longer than Timeout). Use it via
+// db.Use(&StatementTimeout{Timeout: time.Minute}). Statements returning rows
+// to iterate (i.e. Row and Rows) are not limited, as their rows are read after
+// the statement returns.
+type StatementTimeout struct {
+
+	// Timeout is the maximum duration of statements.
+	Timeout time.Duration
+}
+
+// Name returns the name of the plugin.
+func (st *StatementTimeout) Name() string {
+	return "gtfs:statement_timeout"
+}
+
+// Initialize registers the callbacks of the plugin with db.
+func (st *StatementTimeout) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("gtfs:timeout_before_create", st.before),
+		cb.Create().After("gorm:create").Register("gtfs:timeout_after_create", st.after),
+		cb.Query().Before("gorm:query").Register("gtfs:timeout_before_query", st.before),
+		cb.Query().After("gorm:query").Register("gtfs:timeout_after_query", st.after),
+		cb.Update().Before("gorm:update").Register("gtfs:timeout_before_update", st.before),
+		cb.Update().After("gorm:update").Register("gtfs:timeout_after_update", st.after),
+		cb.Delete().Before("gorm:delete").Register("gtfs:timeout_before_delete", st.before),
+		cb.Delete().After("gorm:delete").Register("gtfs:timeout_after_delete", st.after),
+		cb.Raw().Before("gorm:raw").Register("gtfs:timeout_before_raw", st.before),
+		cb.Raw().After("gorm:raw").Register("gtfs:timeout_after_raw", st.after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before limits the context of a statement.
+func (st *StatementTimeout) before(db *gorm.DB) {
+	if st.Timeout <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(dbContext(db), st.Timeout)
+	db.Statement.Context = ctx
+	db.InstanceSet(statementCancelKey, cancel)
+}
+
+// after releases the context of a statement.
+func (st *StatementTimeout) after(db *gorm.DB) {
+	if v, ok := db.InstanceGet(statementCancelKey); ok {
+		if cancel, ok := v.(context.CancelFunc); ok {
+			cancel()
+		}
+	}
+}
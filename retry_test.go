@@ -0,0 +1,92 @@
+package gtfs_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("database is locked"), true},
+		{fmt.Errorf("failed to insert: %w", driver.ErrBadConn), true},
+		{errors.New("UNIQUE constraint failed: stops.id"), false},
+		{context.DeadlineExceeded, false},
+	}
+	for _, tt := range tests {
+		if got := gtfs.IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicy_Do(t *testing.T) {
+	locked := errors.New("database is locked")
+	failing := errors.New("no such table: stops")
+	tests := []struct {
+		name         string
+		policy       *gtfs.RetryPolicy
+		errs         []error
+		wantAttempts int
+		wantErr      error
+	}{
+		{"nil policy", nil, []error{locked}, 1, locked},
+		{"transient", &gtfs.RetryPolicy{Attempts: 3}, []error{locked, locked}, 3, nil},
+		{"exhausted", &gtfs.RetryPolicy{Attempts: 2, Backoff: time.Millisecond}, []error{locked, locked, locked}, 2, locked},
+		{"not transient", &gtfs.RetryPolicy{Attempts: 3}, []error{failing}, 1, failing},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := 0
+			err := tt.policy.Do(context.Background(), func(context.Context) error {
+				attempts++
+				if attempts <= len(tt.errs) {
+					return tt.errs[attempts-1]
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || attempts != tt.wantAttempts {
+				t.Errorf("Do() = %v after %d attempts, want %v after %d attempts", err, attempts, tt.wantErr, tt.wantAttempts)
+			}
+		})
+	}
+
+	// attempts are limited in time
+	policy := &gtfs.RetryPolicy{Attempts: 3, Timeout: time.Millisecond}
+	err := policy.Do(context.Background(), func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestStatementTimeout(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Use(&gtfs.StatementTimeout{Timeout: 10 * time.Millisecond}); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+	const slowStmt = "CREATE TEMP TABLE numbers AS WITH RECURSIVE n(x) AS (SELECT 1 UNION ALL SELECT x + 1 FROM n WHERE x < 100000000) SELECT SUM(x) FROM n"
+	start := time.Now()
+	if err := db.Exec(slowStmt).Error; err == nil {
+		t.Errorf("Exec() error = nil, want the statement to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Exec() took %s, want it to time out", elapsed)
+	}
+
+	// fast statements are fine
+	var stops int64
+	if err := db.Model(&gtfs.Stop{}).Count(&stops).Error; err != nil {
+		t.Errorf("Count() error = %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package gtfs
+
+import (
+	"fmt"
+	"github.com/gocarina/gocsv"
+	"gorm.io/gorm"
+	"io"
+)
+
+// StopRidership model (external, user-provided boarding and alighting counts
+// per stop).
+type StopRidership struct {
+	StopID     string `csv:"stop_id" gorm:"primaryKey"`
+	Boardings  int64  `csv:"boardings"`
+	Alightings int64  `csv:"alightings"`
+}
+
+// TableName returns the name of the table holding StopRidership items.
+func (StopRidership) TableName() string {
+	return "stop_ridership"
+}
+
+// ImportRidership imports ridership counts per stop from CSV (with the columns
+// stop_id, boardings and alightings), replacing any previously imported
+// counts. It returns the number of imported rows.
+func ImportRidership(db *gorm.DB, r io.Reader) (int64, error) {
+	var items []*StopRidership
+	if err := gocsv.Unmarshal(r, &items); err != nil {
+		return 0, fmt.Errorf("failed to parse ridership: %w", err)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM stop_ridership").Error; err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(items, 1000).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(items)), nil
+}
@@ -0,0 +1,32 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+)
+
+func TestImportRidership(t *testing.T) {
+	db := newFixtureDB(t)
+
+	csv := "stop_id,boardings,alightings\nS1,100,80\nS4,20,40\n"
+	n, err := gtfs.ImportRidership(db, strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ImportRidership() error = %v", err)
+	}
+	if n != 2 {
+		t.Errorf("ImportRidership() = %d, want 2", n)
+	}
+
+	// importing again replaces the counts
+	if _, err = gtfs.ImportRidership(db, strings.NewReader(csv)); err != nil {
+		t.Fatalf("ImportRidership() error = %v", err)
+	}
+	fs, err := gtfs.Stats(db)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if fs.Boardings != 120 || fs.Alightings != 120 {
+		t.Errorf("Stats() boardings, alightings = %d, %d, want 120, 120", fs.Boardings, fs.Alightings)
+	}
+}
@@ -0,0 +1,145 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+)
+
+// statement to select the representative trip of a route, i.e. the trip
+// (preferably in direction 0) serving the most stops
+const representativeTripStmt = `
+SELECT
+	trips.id,
+	trips.shape_id
+FROM
+	trips
+	JOIN stop_times ON stop_times.trip_id = trips.id
+WHERE
+	trips.route_id = ?
+GROUP BY
+	trips.id,
+	trips.shape_id,
+	trips.direction_id
+ORDER BY
+	trips.direction_id,
+	COUNT(*) DESC,
+	trips.id
+LIMIT 1;
+`
+
+// statement to select the stops of a trip in sequence
+const tripStopsStmt = `
+SELECT
+	stops.*
+FROM
+	stop_times
+	JOIN stops ON stops.id = stop_times.stop_id
+WHERE
+	stop_times.trip_id = ?
+ORDER BY
+	stop_times.stop_seq;
+`
+
+// RouteStops returns the stops served by the trips of the given route (ordered
+// by ID). If there is no such route, gorm.ErrRecordNotFound is returned.
+func RouteStops(db *gorm.DB, routeID string) ([]Stop, error) {
+	if err := findRoute(db, routeID); err != nil {
+		return nil, err
+	}
+	var stops []Stop
+	tx := db.
+		Where("id IN (?)", db.
+			Table("stop_times").
+			Select("stop_times.stop_id").
+			Joins("JOIN trips ON trips.id = stop_times.trip_id").
+			Where("trips.route_id = ?", routeID)).
+		Order("id").
+		Find(&stops)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := ApplyStopOverrides(db, stops); err != nil {
+		return nil, err
+	}
+	return stops, nil
+}
+
+// RouteSequence returns the stops (in sequence) of the representative trip of
+// the given route, i.e. the trip (preferably in direction 0) serving the most
+// stops. If there is no such route, gorm.ErrRecordNotFound is returned.
+func RouteSequence(db *gorm.DB, routeID string) ([]Stop, error) {
+	trip, err := representativeTrip(db, routeID)
+	if err != nil || trip == nil {
+		return nil, err
+	}
+	var stops []Stop
+	if tx := db.Raw(tripStopsStmt, trip.ID).Scan(&stops); tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err = ApplyStopOverrides(db, stops); err != nil {
+		return nil, err
+	}
+	return stops, nil
+}
+
+// representativeTrip returns the representative trip of the given route (see
+// RouteSequence) or nil, if the route has no trips.
+func representativeTrip(db *gorm.DB, routeID string) (*Trip, error) {
+	if err := findRoute(db, routeID); err != nil {
+		return nil, err
+	}
+	var trips []Trip
+	if tx := db.Raw(representativeTripStmt, routeID).Scan(&trips); tx.Error != nil {
+		return nil, tx.Error
+	}
+	if len(trips) == 0 {
+		return nil, nil
+	}
+	return &trips[0], nil
+}
+
+// RouteShapes returns the points (ordered by sequence) of all shapes used by
+// the trips of the given route, keyed by shape ID. If there is no such route,
+// gorm.ErrRecordNotFound is returned.
+func RouteShapes(db *gorm.DB, routeID string) (map[string][]Shape, error) {
+	if err := findRoute(db, routeID); err != nil {
+		return nil, err
+	}
+	shapeIDs := db.
+		Model(&Trip{}).
+		Select("shape_id").
+		Where("route_id = ?", routeID)
+	var points []Shape
+	tx := db.
+		Where("shape_id IN (?)", shapeIDs).
+		Order("shape_id").
+		Order("pt_sequence").
+		Find(&points)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+
+	// along with encoded shapes (see EncodeShapes)
+	encoded, err := encodedShapes(db, shapeIDs)
+	if err != nil {
+		return nil, err
+	}
+	points = append(points, encoded...)
+	shapes := map[string][]Shape{}
+	for _, p := range points {
+		shapes[p.ShapeID] = append(shapes[p.ShapeID], p)
+	}
+	return shapes, nil
+}
+
+// findRoute returns gorm.ErrRecordNotFound, if there is no route with the
+// given ID (without logging it, as First would).
+func findRoute(db *gorm.DB, routeID string) error {
+	tx := db.Limit(1).Find(&Route{}, "id = ?", routeID)
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestRouteStops(t *testing.T) {
+	db := newFixtureDB(t)
+
+	stops, err := gtfs.RouteStops(db, "R2")
+	if err != nil {
+		t.Fatalf("RouteStops() error = %v", err)
+	}
+	if len(stops) != 3 || stops[0].ID != "B1" || stops[2].ID != "B3" {
+		t.Errorf("RouteStops() = %v, want B1, B2 and B3", stops)
+	}
+
+	if _, err = gtfs.RouteStops(db, "R9"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("RouteStops() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestRouteShapes(t *testing.T) {
+	db := newFixtureDB(t)
+
+	shapes, err := gtfs.RouteShapes(db, "R1")
+	if err != nil {
+		t.Fatalf("RouteShapes() error = %v", err)
+	}
+	if len(shapes) != 2 || len(shapes["SH1"]) != 4 || len(shapes["SH2"]) != 4 {
+		t.Fatalf("RouteShapes() = %v, want SH1 and SH2 with 4 points each", shapes)
+	}
+	for i, p := range shapes["SH2"] {
+		if p.PtSequence != i+1 {
+			t.Errorf("RouteShapes() SH2 point %d has sequence %d", i, p.PtSequence)
+		}
+	}
+
+	if _, err = gtfs.RouteShapes(db, "R9"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("RouteShapes() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestRouteSequence(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// the representative trip runs in direction 0
+	stops, err := gtfs.RouteSequence(db, "R1")
+	if err != nil {
+		t.Fatalf("RouteSequence() error = %v", err)
+	}
+	var ids []string
+	for _, s := range stops {
+		ids = append(ids, s.ID)
+	}
+	if got := strings.Join(ids, ","); got != "S1,S2,S3,S4" {
+		t.Errorf("RouteSequence() = %s, want S1,S2,S3,S4", got)
+	}
+}
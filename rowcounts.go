@@ -0,0 +1,61 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// RowCountViolation describes an item type whose number of rows shrank below
+// the required percentage of the previous version of a feed (see
+// CheckRowCounts).
+type RowCountViolation struct {
+	ItemType   ItemType
+	Previous   int64
+	Current    int64
+	MinPercent float64
+}
+
+// Percent returns the number of rows of the current version as percentage of
+// the number of rows of the previous version.
+func (v RowCountViolation) Percent() float64 {
+	if v.Previous == 0 {
+		return 100
+	}
+	return float64(v.Current) / float64(v.Previous) * 100
+}
+
+// String returns a human-readable representation of RowCountViolation.
+func (v RowCountViolation) String() string {
+	return fmt.Sprintf("%s: %d rows, %.1f%% of the previous %d rows (at least %g%% required)", v.ItemType, v.Current, v.Percent(), v.Previous, v.MinPercent)
+}
+
+// CheckRowCounts compares the number of rows per item type of the previous
+// version of a feed with those of the current version and returns the item
+// types shrinking below the minimum percentage given by minPercent (e.g.
+// StopTimes: 90 to require at least 90% of the previous stop times). This
+// protects from swapping in feeds broken by their publishers (e.g. truncated
+// uploads). Item types without minimum percentage are not checked.
+func CheckRowCounts(previous, current *gorm.DB, minPercent map[ItemType]float64) ([]RowCountViolation, error) {
+	var violations []RowCountViolation
+	for itemType, percent := range minPercent {
+		model, ok := itemModels[itemType]
+		if !ok {
+			return nil, fmt.Errorf("unknown item type %s", itemType)
+		}
+		v := RowCountViolation{ItemType: itemType, MinPercent: percent}
+		if tx := previous.Model(model).Count(&v.Previous); tx.Error != nil {
+			return nil, fmt.Errorf("failed to count previous %s: %w", itemType, tx.Error)
+		}
+		if tx := current.Model(model).Count(&v.Current); tx.Error != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", itemType, tx.Error)
+		}
+		if v.Percent() < percent {
+			violations = append(violations, v)
+		}
+	}
+	sort.Slice(violations, func(i, j int) bool {
+		return violations[i].ItemType < violations[j].ItemType
+	})
+	return violations, nil
+}
@@ -0,0 +1,50 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestCheckRowCounts(t *testing.T) {
+	previous := newFixtureDB(t)
+
+	// remove the stop times of T2 and T3 (i.e. 8 of 15) and add a stop
+	current := newFixtureDB(t)
+	current.Exec("DELETE FROM stop_times WHERE trip_id IN ('T2', 'T3')")
+	current.Create(&gtfs.Stop{ID: "S9", Name: "Somewhere", Latitude: 52.5, Longitude: 13.4})
+
+	tests := []struct {
+		name       string
+		minPercent map[gtfs.ItemType]float64
+		want       []gtfs.RowCountViolation
+	}{
+		{
+			name:       "shrunken stop times",
+			minPercent: map[gtfs.ItemType]float64{gtfs.StopTimes: 90, gtfs.Stops: 100, gtfs.Trips: 100},
+			want:       []gtfs.RowCountViolation{{ItemType: gtfs.StopTimes, Previous: 15, Current: 7, MinPercent: 90}},
+		},
+		{
+			name:       "tolerated",
+			minPercent: map[gtfs.ItemType]float64{gtfs.StopTimes: 40},
+		},
+		{
+			name: "unchecked",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gtfs.CheckRowCounts(previous, current, tt.minPercent)
+			if err != nil {
+				t.Fatalf("CheckRowCounts() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("CheckRowCounts() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("CheckRowCounts() = %v, want %v", got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package gtfs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"io"
+	"sync"
+)
+
+// MigrationDDL returns the statements (e.g. CREATE TABLE and CREATE INDEX)
+// Migrate executes on an empty DB, without connecting to a DB, e.g. for DBAs
+// to review or provision the schema where applications lack DDL rights. The
+// dialect is given by newDialector, which is called with the connection the
+// dialector is to use (recording the statements executed and answering
+// queries as an empty DB would), e.g.
+//
+//	gtfs.MigrationDDL(func(conn gorm.ConnPool) gorm.Dialector {
+//		return postgres.New(postgres.Config{Conn: conn})
+//	})
+func MigrationDDL(newDialector func(conn gorm.ConnPool) gorm.Dialector) ([]string, error) {
+	c := &ddlConnector{}
+	conn := sql.OpenDB(c)
+	defer func() {
+		_ = conn.Close()
+	}()
+	db, err := gorm.Open(newDialector(conn), &gorm.Config{Logger: logger.Discard, DisableAutomaticPing: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize dialector: %w", err)
+	}
+	if err = Migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to migrate: %w", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stmts, nil
+}
+
+// ddlConnector is a driver.Connector of connections recording the statements
+// executed (see MigrationDDL).
+type ddlConnector struct {
+	mu    sync.Mutex
+	stmts []string
+}
+
+// Connect returns a connection recording the statements executed.
+func (c *ddlConnector) Connect(context.Context) (driver.Conn, error) {
+	return ddlConn{c}, nil
+}
+
+// Driver returns the driver of the connector.
+func (c *ddlConnector) Driver() driver.Driver {
+	return ddlDriver{c}
+}
+
+// record records a statement executed.
+func (c *ddlConnector) record(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stmts = append(c.stmts, query)
+}
+
+// ddlDriver is the driver.Driver of ddlConnector.
+type ddlDriver struct {
+	c *ddlConnector
+}
+
+// Open returns a connection recording the statements executed.
+func (d ddlDriver) Open(string) (driver.Conn, error) {
+	return ddlConn(d), nil
+}
+
+// ddlConn is a driver.Conn recording the statements executed (and answering
+// queries with a single zero, e.g. the count of tables having a name).
+type ddlConn struct {
+	c *ddlConnector
+}
+
+// Prepare returns the statement of the query.
+func (dc ddlConn) Prepare(query string) (driver.Stmt, error) {
+	return ddlStmt{c: dc.c, query: query}, nil
+}
+
+// Close does nothing.
+func (ddlConn) Close() error {
+	return nil
+}
+
+// Begin returns a transaction doing nothing.
+func (ddlConn) Begin() (driver.Tx, error) {
+	return ddlTx{}, nil
+}
+
+// ddlTx is a driver.Tx doing nothing.
+type ddlTx struct{}
+
+// Commit does nothing.
+func (ddlTx) Commit() error {
+	return nil
+}
+
+// Rollback does nothing.
+func (ddlTx) Rollback() error {
+	return nil
+}
+
+// ddlStmt is a driver.Stmt recording the statement, if executed.
+type ddlStmt struct {
+	c     *ddlConnector
+	query string
+}
+
+// Close does nothing.
+func (ddlStmt) Close() error {
+	return nil
+}
+
+// NumInput returns -1 (i.e. the number of placeholders is not checked).
+func (ddlStmt) NumInput() int {
+	return -1
+}
+
+// Exec records the statement.
+func (s ddlStmt) Exec([]driver.Value) (driver.Result, error) {
+	s.c.record(s.query)
+	return driver.RowsAffected(0), nil
+}
+
+// Query returns a single zero.
+func (ddlStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &ddlRows{}, nil
+}
+
+// ddlRows are driver.Rows holding a single zero.
+type ddlRows struct {
+	done bool
+}
+
+// Columns returns the single column.
+func (*ddlRows) Columns() []string {
+	return []string{"count"}
+}
+
+// Close does nothing.
+func (*ddlRows) Close() error {
+	return nil
+}
+
+// Next returns the single zero (and io.EOF afterwards).
+func (r *ddlRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(0)
+	return nil
+}
@@ -0,0 +1,80 @@
+package gtfs_test
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+// tables are the tables of the models of this package (excluding models
+// registered by tests, see RegisterModels)
+var tables = []string{
+	"agencies", "routes", "trips", "stop_times", "stops", "shapes",
+	"encoded_shapes", "shape_bounds", "calendars", "calendar_dates",
+	"frequencies", "route_directions", "frequency_bucket_dates",
+	"route_frequency_buckets", "stop_frequency_buckets", "stop_ridership",
+	"feed_meta", "import_rejects", "skipped_rows", "stop_overrides",
+	"stop_amenities", "translations", "fare_products", "fare_leg_rules",
+	"fare_transfer_rules", "vehicle_capacities", "stop_search", "search",
+}
+
+func TestMigrationDDL(t *testing.T) {
+	tests := []struct {
+		name         string
+		newDialector func(conn gorm.ConnPool) gorm.Dialector
+		createTable  string
+		wantIndex    string
+	}{
+		{"sqlite", func(conn gorm.ConnPool) gorm.Dialector {
+			return &sqlite.Dialector{Conn: conn}
+		}, "CREATE TABLE `%s` (", "CREATE UNIQUE INDEX `idx_shapes_shape_pt` ON `shapes`"},
+		{"postgres", func(conn gorm.ConnPool) gorm.Dialector {
+			return postgres.New(postgres.Config{Conn: conn})
+		}, `CREATE TABLE "%s" (`, `CREATE UNIQUE INDEX IF NOT EXISTS "idx_shapes_shape_pt" ON "shapes"`},
+		{"mysql", func(conn gorm.ConnPool) gorm.Dialector {
+			return mysql.New(mysql.Config{Conn: conn, SkipInitializeWithVersion: true})
+		}, "CREATE TABLE `%s` (", "UNIQUE INDEX idx_shapes_shape_pt (`shape_id`,`pt_sequence`)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmts, err := gtfs.MigrationDDL(tt.newDialector)
+			if err != nil {
+				t.Fatalf("MigrationDDL() error = %v", err)
+			}
+			ddl := strings.Join(stmts, ";\n")
+			if !strings.Contains(ddl, tt.wantIndex) {
+				t.Errorf("MigrationDDL() = %s, want %q", ddl, tt.wantIndex)
+			}
+			for _, table := range tables {
+				if got := strings.Count(ddl, fmt.Sprintf(tt.createTable, table)); got != 1 {
+					t.Errorf("MigrationDDL() creates %s %d times, want once", table, got)
+				}
+			}
+		})
+	}
+
+	// the statements create the schema
+	stmts, err := gtfs.MigrationDDL(func(conn gorm.ConnPool) gorm.Dialector {
+		return &sqlite.Dialector{Conn: conn}
+	})
+	if err != nil {
+		t.Fatalf("MigrationDDL() error = %v", err)
+	}
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open DB: %v", err)
+	}
+	for _, stmt := range stmts {
+		if err = db.Exec(stmt).Error; err != nil {
+			t.Fatalf("failed to execute %q: %v", stmt, err)
+		}
+	}
+	if !db.Migrator().HasTable(&gtfs.Stop{}) {
+		t.Errorf("MigrationDDL() doesn't create stops")
+	}
+}
@@ -0,0 +1,155 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// ErrNoSearch is returned when searching without an index (see IndexSearch).
+var ErrNoSearch = errors.New("no search index")
+
+// HitType enumerates the types of search hits.
+type HitType uint32
+
+const (
+
+	// StopHit the hit type for stops matching by name.
+	StopHit HitType = iota
+
+	// RouteHit the hit type for routes matching by short or long name.
+	RouteHit
+
+	// HeadsignHit the hit type for routes matching by the headsign of one of
+	// their trips.
+	HeadsignHit
+)
+
+var txHitType = map[HitType]string{
+	StopHit:     "stop",
+	RouteHit:    "route",
+	HeadsignHit: "headsign",
+}
+
+// String returns a human-readable representation of HitType.
+func (ht HitType) String() string {
+	if s := txHitType[ht]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown HitType (%d)", uint32(ht))
+}
+
+// Hit is a single search result. ID is the ID of the stop or the route (for
+// route and headsign hits) and Name is the name of the stop, the route or the
+// headsign.
+type Hit struct {
+	Type HitType
+	ID   string
+	Name string
+}
+
+// SearchEntry model (a single entry of the search index, see IndexSearch).
+type SearchEntry struct {
+	Type   HitType
+	ItemID string
+	Label  string
+	Name   string
+}
+
+// TableName returns the name of the table holding SearchEntry items.
+func (SearchEntry) TableName() string {
+	return "search"
+}
+
+// statement to select the entries of the search index (given the expression
+// concatenating the names of routes, see concat)
+const searchEntriesStmt = `
+SELECT
+	0 AS type,
+	id AS item_id,
+	name AS label
+FROM
+	stops
+UNION ALL
+SELECT
+	1 AS type,
+	id AS item_id,
+	TRIM(%s) AS label
+FROM
+	routes
+UNION ALL
+SELECT DISTINCT
+	2 AS type,
+	route_id AS item_id,
+	headsign AS label
+FROM
+	trips
+WHERE
+	headsign <> '';
+`
+
+// IndexSearch (re-)builds the search index (i.e. the search table) covering
+// stop names (the display names, see SetStopOverride), route names and trip
+// headsigns. If fts5 is true, the index is an
+// SQLite FTS5 virtual table (which requires building with the sqlite_fts5
+// tag).
+func IndexSearch(db *gorm.DB, fts5 bool) error {
+	var entries []SearchEntry
+	stmt := fmt.Sprintf(searchEntriesStmt, concat(db, "short_name", "' '", "long_name"))
+	if err := db.Raw(stmt).Scan(&entries).Error; err != nil {
+		return err
+	}
+	overrides, err := stopOverrides(db)
+	if err != nil {
+		return err
+	}
+	for i := range entries {
+		if entries[i].Type == StopHit {
+			entries[i].Label = overrides.name(Stop{ID: entries[i].ItemID, Name: entries[i].Label})
+		}
+		entries[i].Name = NormalizeName(entries[i].Label)
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := resetIndex(tx, &SearchEntry{}, fts5, "type UNINDEXED, item_id UNINDEXED, label UNINDEXED, name"); err != nil {
+			return fmt.Errorf("failed to create search index: %w", err)
+		}
+		if len(entries) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(entries, batchSize).Error
+	})
+}
+
+// Search returns all stops, routes and headsigns whose normalized names (see
+// NormalizeName) contain all words of the (normalized) query. The search index
+// must have been built before (see IndexSearch), otherwise (i.e. if the index
+// is empty) ErrNoSearch is returned.
+func Search(db *gorm.DB, query string) ([]Hit, error) {
+	if !hasRows(db, "search") {
+		return nil, ErrNoSearch
+	}
+	words := strings.Fields(NormalizeName(query))
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	tx := db.Model(&SearchEntry{})
+	if isFTS5(db, "search") {
+		tx = tx.Where("search MATCH ?", ftsPrefixQuery(words)).Order("rank")
+	} else {
+		for _, w := range words {
+			tx = tx.Where("name LIKE ?", "%"+w+"%")
+		}
+	}
+	var entries []SearchEntry
+	if err := tx.Order("type").Order("label").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	hits := make([]Hit, len(entries))
+	for i, e := range entries {
+		hits[i] = Hit{Type: e.Type, ID: e.ItemID, Name: e.Label}
+	}
+	return hits, nil
+}
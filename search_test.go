@@ -0,0 +1,61 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	if _, err := gtfs.Search(newTestDB(t), "wannsee"); !errors.Is(err, gtfs.ErrNoSearch) {
+		t.Errorf("Search() error = %v, want %v", err, gtfs.ErrNoSearch)
+	}
+
+	// importing builds the index
+	db := newFixtureDB(t)
+	testSearch(t, db)
+
+	// FTS5 requires building with the sqlite_fts5 tag
+	if err := gtfs.IndexSearch(db, true); err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			t.Skip("FTS5 not available")
+		}
+		t.Fatalf("IndexSearch() error = %v", err)
+	}
+	testSearch(t, db)
+}
+
+func testSearch(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	tests := []struct {
+		query string
+		want  map[gtfs.HitType]int
+	}{
+		{"strandbad", map[gtfs.HitType]int{gtfs.StopHit: 1, gtfs.RouteHit: 1, gtfs.HeadsignHit: 1}},
+		{"steglitz", map[gtfs.HitType]int{gtfs.StopHit: 1, gtfs.RouteHit: 1, gtfs.HeadsignHit: 1}},
+		{"218", map[gtfs.HitType]int{gtfs.RouteHit: 1}},
+		{"Großen", map[gtfs.HitType]int{gtfs.StopHit: 1}},
+		{"alexanderplatz", map[gtfs.HitType]int{}},
+	}
+	for _, tt := range tests {
+		hits, err := gtfs.Search(db, tt.query)
+		if err != nil {
+			t.Fatalf("Search(%q) error = %v", tt.query, err)
+		}
+		got := map[gtfs.HitType]int{}
+		for _, h := range hits {
+			got[h.Type]++
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("Search(%q) = %v, want %v hits", tt.query, hits, tt.want)
+			continue
+		}
+		for ht, n := range tt.want {
+			if got[ht] != n {
+				t.Errorf("Search(%q) = %v, want %d %s hits", tt.query, hits, n, ht)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"os"
+	"testing"
+)
+
+// newServerDB returns a migrated DB (dropping any tables left by previous
+// tests) on the server given by the DSN in the environment variable dsnEnv.
+// Tests requiring the server are skipped, if the variable is unset.
+func newServerDB(t *testing.T, dsnEnv string, open func(dsn string) gorm.Dialector) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv(dsnEnv)
+	if dsn == "" {
+		t.Skipf("%s not set", dsnEnv)
+	}
+	db, err := gorm.Open(open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("failed to open DB: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("failed to get DB: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	tables, err := db.Migrator().GetTables()
+	if err != nil {
+		t.Fatalf("failed to list tables: %v", err)
+	}
+	for _, table := range tables {
+		if err = db.Migrator().DropTable(table); err != nil {
+			t.Fatalf("failed to drop table '%s': %v", table, err)
+		}
+	}
+	if err = gtfs.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate DB: %v", err)
+	}
+	return db
+}
+
+func TestServerDB(t *testing.T) {
+	tests := []struct {
+		name   string
+		dsnEnv string
+		open   func(dsn string) gorm.Dialector
+	}{
+		{"postgres", "GTFS_TEST_POSTGRES_DSN", postgres.Open},
+		{"mysql", "GTFS_TEST_MYSQL_DSN", mysql.Open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newServerDB(t, tt.dsnEnv, tt.open)
+			gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.Error != nil {
+						t.Fatalf("failed to import fixture: %v", e.Result.Error)
+					}
+				},
+			})
+			var count int64
+			if db.Model(&gtfs.StopTime{}).Count(&count); count != 15 {
+				t.Fatalf("Import() stop times = %d, want 15", count)
+			}
+
+			r, err := gtfs.Trim(db, "s-bahn", gtfs.TrimOptions{})
+			if err != nil {
+				t.Fatalf("Trim() error = %v", err)
+			}
+			if got := (*r)[gtfs.Trips].Remaining; got != 3 {
+				t.Errorf("Trim() remaining trips = %d, want 3", got)
+			}
+			var stops []string
+			db.Model(&gtfs.Stop{}).Order("id").Pluck("id", &stops)
+			if len(stops) != 4 {
+				t.Errorf("Trim() stops = %v, want S1 to S4", stops)
+			}
+
+			// removing trip T1 orphans its stop times (listed by row ID)
+			db.Delete(&gtfs.Trip{}, "id = ?", "T1")
+			ids, err := gtfs.ListOrphans(db, gtfs.StopTimes, 0)
+			if err != nil {
+				t.Fatalf("ListOrphans() error = %v", err)
+			}
+			if len(ids) != 4 {
+				t.Errorf("ListOrphans() = %v, want 4 IDs", ids)
+			}
+			if _, err = gtfs.Validate(db); err != nil {
+				t.Errorf("Validate() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestServerDB_SkipFailedRows(t *testing.T) {
+	tests := []struct {
+		name   string
+		dsnEnv string
+		open   func(dsn string) gorm.Dialector
+	}{
+		{"postgres", "GTFS_TEST_POSTGRES_DSN", postgres.Open},
+		{"mysql", "GTFS_TEST_MYSQL_DSN", mysql.Open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newServerDB(t, tt.dsnEnv, tt.open)
+
+			// agency 1 conflicts with the agency in line 2, failing the batch
+			// (which aborts the transaction of the file on Postgres)
+			db.Create(&gtfs.Agency{ID: "1", Name: "Existing"})
+			var result *gtfs.ImportItemsResult
+			gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.ItemType == gtfs.Agencies {
+						result = e.Result
+					}
+				},
+				SkipFailedRows: true,
+			})
+			if result.Error != nil {
+				t.Fatalf("ImportWithOptions() error = %v", result.Error)
+			}
+			if result.Rejected != 1 || len(result.Rejections) != 1 || result.Rejections[0].Line != 2 {
+				t.Errorf("ImportWithOptions() rejections = %v, want line 2", result.Rejections)
+			}
+			var count int64
+			if db.Model(&gtfs.Agency{}).Count(&count); count != 2 {
+				t.Errorf("ImportWithOptions() agencies = %d, want 2", count)
+			}
+		})
+	}
+}
+
+func TestServerDB_RollBack(t *testing.T) {
+	tests := []struct {
+		name   string
+		dsnEnv string
+		open   func(dsn string) gorm.Dialector
+	}{
+		{"sqlite", "", nil},
+		{"postgres", "GTFS_TEST_POSTGRES_DSN", postgres.Open},
+		{"mysql", "GTFS_TEST_MYSQL_DSN", mysql.Open},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var db *gorm.DB
+			if tt.open == nil {
+				db = newTestDB(t)
+			} else {
+				db = newServerDB(t, tt.dsnEnv, tt.open)
+			}
+
+			// failing after building the search indexes rolls back the stops
+			// (no DDL committing the transaction of the file on MySQL)
+			var result *gtfs.ImportItemsResult
+			gtfs.ImportWithOptions(db, fixtureFeed, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.ItemType == gtfs.Stops {
+						result = e.Result
+					}
+				},
+				AfterTable: func(_ *gorm.DB, r *gtfs.ImportItemsResult) error {
+					if r.ItemType == gtfs.Stops {
+						return errors.New("boom")
+					}
+					return nil
+				},
+			})
+			if result == nil || result.Error == nil || !result.RolledBack {
+				t.Fatalf("ImportWithOptions() stops = %v, want rolled back", result)
+			}
+			for _, table := range []string{"stops", "stop_search", "search"} {
+				var count int64
+				if db.Table(table).Count(&count); count != 0 {
+					t.Errorf("ImportWithOptions() left %d rows in %s, want none", count, table)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package gtfs
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// DateLayout is the layout of GTFS dates (e.g. "20220224").
+const DateLayout = "20060102"
+
+// ErrNoServicePeriod is returned if the DB holds neither calendars nor calendar
+// dates adding service.
+var ErrNoServicePeriod = errors.New("no service period")
+
+// statement to select the first and the last day of service
+const servicePeriodStmt = `
+SELECT
+	MIN(day),
+	MAX(day)
+FROM (
+	SELECT start_date AS day FROM calendars
+	UNION ALL
+	SELECT end_date AS day FROM calendars
+	UNION ALL
+	SELECT date AS day FROM calendar_dates WHERE exception_type = 1);
+`
+
+// statement to select the services running on a date (the weekday column is
+// filled in)
+const activeServicesStmt = `
+SELECT service_id FROM calendars
+WHERE start_date <= ? AND end_date >= ? AND %s = 1 AND service_id NOT IN (
+	SELECT service_id FROM calendar_dates WHERE date = ? AND exception_type = 2)
+UNION
+SELECT service_id FROM calendar_dates WHERE date = ? AND exception_type = 1;
+`
+
+// ServicePeriod returns the first and the last day of service covered by the
+// calendars and calendar dates within the given DB. The returned days are
+// midnight in loc (UTC, if loc is nil).
+func ServicePeriod(db *gorm.DB, loc *time.Location) (first time.Time, last time.Time, err error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	var firstDay, lastDay sql.NullString
+	if err = db.Raw(servicePeriodStmt).Row().Scan(&firstDay, &lastDay); err != nil {
+		return first, last, err
+	}
+	if !firstDay.Valid || !lastDay.Valid {
+		return first, last, ErrNoServicePeriod
+	}
+
+	first, err = time.ParseInLocation(DateLayout, firstDay.String, loc)
+	if err != nil {
+		return first, last, fmt.Errorf("cannot parse GTFS date from '%s': %w", firstDay.String, err)
+	}
+	last, err = time.ParseInLocation(DateLayout, lastDay.String, loc)
+	if err != nil {
+		return first, last, fmt.Errorf("cannot parse GTFS date from '%s': %w", lastDay.String, err)
+	}
+	return first, last, nil
+}
+
+// ServiceDay returns the service day of t in tz (UTC, if tz is nil), i.e. the
+// date (midnight in tz) of t in tz, along with the number of seconds elapsed
+// since "noon minus 12h" of that date. GTFS times (of stop times and
+// frequencies) are measured relative to noon minus 12h, which differs from
+// midnight on days when daylight saving time starts or ends.
+func ServiceDay(t time.Time, tz *time.Location) (date time.Time, secondsIntoDay int) {
+	if tz == nil {
+		tz = time.UTC
+	}
+	t = t.In(tz)
+	y, m, d := t.Date()
+	date = time.Date(y, m, d, 0, 0, 0, 0, tz)
+	reference := time.Date(y, m, d, 12, 0, 0, 0, tz).Add(-12 * time.Hour)
+	return date, int(t.Sub(reference) / time.Second)
+}
+
+// ServiceTime is the inverse of ServiceDay, i.e. it returns the time a GTFS
+// time (seconds since "noon minus 12h", possibly exceeding 24h) on the
+// service day date refers to. The returned time is in the location of date.
+func ServiceTime(date time.Time, secondsIntoDay int) time.Time {
+	y, m, d := date.Date()
+	reference := time.Date(y, m, d, 12, 0, 0, 0, date.Location()).Add(-12 * time.Hour)
+	return reference.Add(time.Duration(secondsIntoDay) * time.Second)
+}
+
+// ActiveServices returns the IDs of the services running on the date of day
+// (according to calendars and calendar dates).
+func ActiveServices(db *gorm.DB, day time.Time) ([]string, error) {
+	date := day.Format(DateLayout)
+	stmt := fmt.Sprintf(activeServicesStmt, strings.ToLower(day.Weekday().String()))
+	var serviceIDs []string
+	if tx := db.Raw(stmt, date, date, date, date).Scan(&serviceIDs); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return serviceIDs, nil
+}
+
+// maxServiceDayLength is the maximum GTFS time considered (i.e. trips of a
+// service day may run into the next day, but not beyond).
+const maxServiceDayLength = 48 * 3600
+
+// ServiceWindow is a span of GTFS times on a single service day.
+type ServiceWindow struct {
+	Date time.Time // the service day (midnight, see ServiceDay)
+	From int       // seconds since "noon minus 12h" of Date (inclusive)
+	To   int       // seconds since "noon minus 12h" of Date (exclusive)
+}
+
+// ServiceWindows splits the time span from from to to into spans of GTFS times
+// on each of the service days (in tz, UTC if tz is nil) overlapping it,
+// ordered by service day. As GTFS times of a service day may exceed 24h, the
+// span 23:30-01:30 (e.g.) yields 23:30-25:30 on the first day (matching trips
+// running past midnight) and 00:00-01:30 on the next (matching trips starting
+// after midnight), along with 47:30-48:00 on the day before the first day.
+// GTFS times beyond 48h are not considered.
+func ServiceWindows(from, to time.Time, tz *time.Location) []ServiceWindow {
+	if !to.After(from) {
+		return nil
+	}
+	first, _ := ServiceDay(from, tz)
+	last, _ := ServiceDay(to, tz)
+
+	var windows []ServiceWindow
+	for date := first.AddDate(0, 0, -maxServiceDayLength/(24*3600)+1); !date.After(last); date = date.AddDate(0, 0, 1) {
+		reference := ServiceTime(date, 0)
+		w := ServiceWindow{
+			Date: date,
+			From: int(from.Sub(reference) / time.Second),
+			To:   int(to.Sub(reference) / time.Second),
+		}
+		if w.From < 0 {
+			w.From = 0
+		}
+		if w.To > maxServiceDayLength {
+			w.To = maxServiceDayLength
+		}
+		if w.From < w.To {
+			windows = append(windows, w)
+		}
+	}
+	return windows
+}
@@ -0,0 +1,176 @@
+package gtfs_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestServicePeriod(t *testing.T) {
+	db := newTestDB(t)
+
+	// without any calendars, there is no service period
+	if _, _, err := gtfs.ServicePeriod(db, nil); !errors.Is(err, gtfs.ErrNoServicePeriod) {
+		t.Fatalf("ServicePeriod() error = %v, want %v", err, gtfs.ErrNoServicePeriod)
+	}
+
+	db.Create(&gtfs.Calendar{ServiceID: "1", Monday: 1, StartDate: "20220101", EndDate: "20221231"})
+	db.Create(&gtfs.CalendarDate{ServiceID: "1", Date: "20230115", ExceptionType: 1})
+	db.Create(&gtfs.CalendarDate{ServiceID: "1", Date: "20230201", ExceptionType: 2})
+
+	first, last, err := gtfs.ServicePeriod(db, nil)
+	if err != nil {
+		t.Fatalf("ServicePeriod() error = %v", err)
+	}
+	if want := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC); !first.Equal(want) {
+		t.Errorf("ServicePeriod() first = %v, want %v", first, want)
+	}
+	if want := time.Date(2023, 1, 15, 0, 0, 0, 0, time.UTC); !last.Equal(want) {
+		t.Errorf("ServicePeriod() last = %v, want %v", last, want)
+	}
+}
+
+func TestServiceDay(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		t       time.Time
+		tz      *time.Location
+		date    time.Time
+		seconds int
+	}{
+		{
+			name:    "UTC",
+			t:       time.Date(2022, 3, 1, 8, 30, 0, 0, time.UTC),
+			date:    time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC),
+			seconds: 8*3600 + 30*60,
+		},
+		{
+			name:    "other timezone",
+			t:       time.Date(2022, 3, 1, 23, 30, 0, 0, time.UTC),
+			tz:      berlin,
+			date:    time.Date(2022, 3, 2, 0, 0, 0, 0, berlin),
+			seconds: 30 * 60,
+		},
+		{
+			name:    "DST start, before the change",
+			t:       time.Date(2022, 3, 27, 1, 0, 0, 0, berlin),
+			tz:      berlin,
+			date:    time.Date(2022, 3, 27, 0, 0, 0, 0, berlin),
+			seconds: 2 * 3600,
+		},
+		{
+			name:    "DST start, after the change",
+			t:       time.Date(2022, 3, 27, 10, 0, 0, 0, berlin),
+			tz:      berlin,
+			date:    time.Date(2022, 3, 27, 0, 0, 0, 0, berlin),
+			seconds: 10 * 3600,
+		},
+		{
+			name:    "DST end, after the change",
+			t:       time.Date(2022, 10, 30, 10, 0, 0, 0, berlin),
+			tz:      berlin,
+			date:    time.Date(2022, 10, 30, 0, 0, 0, 0, berlin),
+			seconds: 10 * 3600,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			date, seconds := gtfs.ServiceDay(tt.t, tt.tz)
+			if !date.Equal(tt.date) {
+				t.Errorf("ServiceDay() date = %v, want %v", date, tt.date)
+			}
+			if seconds != tt.seconds {
+				t.Errorf("ServiceDay() seconds = %d, want %d", seconds, tt.seconds)
+			}
+			if got := gtfs.ServiceTime(date, seconds); !got.Equal(tt.t) {
+				t.Errorf("ServiceTime() = %v, want %v", got, tt.t)
+			}
+		})
+	}
+}
+
+func TestServiceTime_Overnight(t *testing.T) {
+	date := time.Date(2022, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got, want := gtfs.ServiceTime(date, 25*3600+30*60), time.Date(2022, 3, 2, 1, 30, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("ServiceTime() = %v, want %v", got, want)
+	}
+}
+
+func TestActiveServices(t *testing.T) {
+	db := newFixtureDB(t)
+	tests := []struct {
+		day  time.Time
+		want string
+	}{
+		{time.Date(2022, 12, 27, 0, 0, 0, 0, time.UTC), "[WD]"},
+		{time.Date(2022, 12, 24, 0, 0, 0, 0, time.UTC), "[WE]"},
+		{time.Date(2022, 12, 26, 0, 0, 0, 0, time.UTC), "[WE]"}, // a Monday, but a holiday
+		{time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC), "[]"},
+	}
+	for _, tt := range tests {
+		got, err := gtfs.ActiveServices(db, tt.day)
+		if err != nil {
+			t.Fatalf("ActiveServices() error = %v", err)
+		}
+		if fmt.Sprint(got) != tt.want {
+			t.Errorf("ActiveServices(%s) = %v, want %s", tt.day.Format("2006-01-02"), got, tt.want)
+		}
+	}
+}
+
+func TestServiceWindows(t *testing.T) {
+	day := func(d int) time.Time {
+		return time.Date(2022, 3, d, 0, 0, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     []gtfs.ServiceWindow
+	}{
+		{
+			name: "within a day",
+			from: time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC),
+			to:   time.Date(2022, 3, 1, 9, 0, 0, 0, time.UTC),
+			want: []gtfs.ServiceWindow{
+				{Date: time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC), From: 32 * 3600, To: 33 * 3600},
+				{Date: day(1), From: 8 * 3600, To: 9 * 3600},
+			},
+		},
+		{
+			name: "spanning midnight",
+			from: time.Date(2022, 3, 1, 23, 30, 0, 0, time.UTC),
+			to:   time.Date(2022, 3, 2, 1, 30, 0, 0, time.UTC),
+			want: []gtfs.ServiceWindow{
+				{Date: time.Date(2022, 2, 28, 0, 0, 0, 0, time.UTC), From: 47*3600 + 30*60, To: 48 * 3600},
+				{Date: day(1), From: 23*3600 + 30*60, To: 25*3600 + 30*60},
+				{Date: day(2), From: 0, To: 3600 + 30*60},
+			},
+		},
+		{
+			name: "empty",
+			from: time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC),
+			to:   time.Date(2022, 3, 1, 8, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := gtfs.ServiceWindows(tt.from, tt.to, nil)
+			if len(got) != len(tt.want) {
+				t.Fatalf("ServiceWindows() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if !got[i].Date.Equal(tt.want[i].Date) || got[i].From != tt.want[i].From || got[i].To != tt.want[i].To {
+					t.Errorf("ServiceWindows()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
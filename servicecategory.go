@@ -0,0 +1,198 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"time"
+)
+
+// ServiceCategory enumerates categories of services inferred from the days
+// they run on (see ClassifyServices).
+type ServiceCategory uint32
+
+const (
+
+	// RegularService the category of services not restricted to school days or
+	// holidays.
+	RegularService ServiceCategory = iota
+
+	// SchoolDaysOnly the category of services pausing during school holidays.
+	SchoolDaysOnly
+
+	// HolidaysOnly the category of services only running during school
+	// holidays.
+	HolidaysOnly
+)
+
+var txServiceCategory = map[ServiceCategory]string{
+	RegularService: "regular",
+	SchoolDaysOnly: "school-days",
+	HolidaysOnly:   "holidays",
+}
+
+// String returns a human-readable representation of ServiceCategory.
+func (sc ServiceCategory) String() string {
+	if s := txServiceCategory[sc]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown ServiceCategory (%d)", uint32(sc))
+}
+
+// ParseServiceCategory returns the ServiceCategory with the given name (i.e.
+// "regular", "school-days" or "holidays").
+func ParseServiceCategory(s string) (ServiceCategory, error) {
+	for category, name := range txServiceCategory {
+		if name == s {
+			return category, nil
+		}
+	}
+	return RegularService, fmt.Errorf("unknown service category '%s'", s)
+}
+
+// minSchoolBreak is the minimum number of consecutive (regular) days a service
+// must pause for to be considered as pausing for school holidays (i.e. more
+// than a long weekend).
+const minSchoolBreak = 5
+
+// ClassifyServices infers the category of each service (running at all) from
+// the days it runs on (according to calendars and calendar dates). Services
+// running on weekdays, but pausing for at least 5 consecutive weekdays they
+// would regularly run on (e.g. Monday to Friday), are SchoolDaysOnly. Services
+// running on weekdays, but only while SchoolDaysOnly services pause, are
+// HolidaysOnly. All other services are RegularService. As the classification
+// is a heuristic, it is meant for soft filters (e.g. hiding trips by default)
+// rather than for dropping trips.
+func ClassifyServices(db *gorm.DB) (map[string]ServiceCategory, error) {
+	categories := map[string]ServiceCategory{}
+	first, last, err := ServicePeriod(db, nil)
+	if errors.Is(err, ErrNoServicePeriod) {
+		return categories, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	days := int(last.Sub(first)/(24*time.Hour)) + 1
+	day := func(i int) time.Time {
+		return first.AddDate(0, 0, i)
+	}
+
+	// determine the days each service runs on
+	var calendars []Calendar
+	if tx := db.Find(&calendars); tx.Error != nil {
+		return nil, tx.Error
+	}
+	running := map[string][]bool{}
+	get := func(serviceID string) []bool {
+		r, ok := running[serviceID]
+		if !ok {
+			r = make([]bool, days)
+			running[serviceID] = r
+		}
+		return r
+	}
+	for _, c := range calendars {
+		r := get(c.ServiceID)
+		for i := range r {
+			r[i] = r[i] || c.scheduled(day(i))
+		}
+	}
+	var dates []CalendarDate
+	if tx := db.Find(&dates); tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, cd := range dates {
+		date, err := time.Parse(DateLayout, cd.Date)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse GTFS date from '%s': %w", cd.Date, err)
+		}
+		i := int(date.Sub(first) / (24 * time.Hour))
+		if i < 0 || i >= days {
+			continue
+		}
+		get(cd.ServiceID)[i] = cd.ExceptionType == 1
+	}
+
+	// find services pausing on weekdays, marking the days they pause
+	breaks := make([]bool, days)
+	for serviceID, r := range running {
+		categories[serviceID] = RegularService
+		if gaps := weekdayGaps(r, day); len(gaps) > 0 {
+			categories[serviceID] = SchoolDaysOnly
+			for _, g := range gaps {
+				for i := g[0]; i < g[1]; i++ {
+					breaks[i] = true
+				}
+			}
+		}
+	}
+
+	// find services running on weekdays, but during breaks only
+	for serviceID, r := range running {
+		if categories[serviceID] != RegularService {
+			continue
+		}
+		var weekdays bool
+		holidays := true
+		for i, ok := range r {
+			if !ok {
+				continue
+			}
+			if wd := day(i).Weekday(); wd != time.Saturday && wd != time.Sunday {
+				weekdays = true
+			}
+			if !breaks[i] {
+				holidays = false
+				break
+			}
+		}
+		if weekdays && holidays {
+			categories[serviceID] = HolidaysOnly
+		}
+	}
+
+	// drop services not running at all
+	for serviceID, r := range running {
+		var runs bool
+		for _, ok := range r {
+			runs = runs || ok
+		}
+		if !runs {
+			delete(categories, serviceID)
+		}
+	}
+	return categories, nil
+}
+
+// weekdayGaps returns the spans of days (start inclusive, end exclusive) a
+// service (running on the days marked in r) pauses on at least minSchoolBreak
+// consecutive weekdays it regularly runs on (i.e. weekdays it runs on at least
+// once). Spans reach from the day after the service last ran to the day before
+// it runs again.
+func weekdayGaps(r []bool, day func(int) time.Time) [][2]int {
+	var regular [7]bool
+	for i, ok := range r {
+		if ok {
+			regular[day(i).Weekday()] = true
+		}
+	}
+	if !regular[time.Monday] && !regular[time.Tuesday] && !regular[time.Wednesday] && !regular[time.Thursday] && !regular[time.Friday] {
+		return nil
+	}
+
+	var gaps [][2]int
+	lastRun, missed := -1, 0
+	for i, ok := range r {
+		if ok {
+			if lastRun >= 0 && missed >= minSchoolBreak {
+				gaps = append(gaps, [2]int{lastRun + 1, i})
+			}
+			lastRun, missed = i, 0
+			continue
+		}
+		if wd := day(i).Weekday(); regular[wd] && wd != time.Saturday && wd != time.Sunday {
+			missed++
+		}
+	}
+	return gaps
+}
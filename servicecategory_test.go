@@ -0,0 +1,100 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClassifyServices(t *testing.T) {
+	tests := []struct {
+		name  string
+		dates []gtfs.CalendarDate
+		want  map[string]gtfs.ServiceCategory
+	}{
+		{
+			name: "public holiday",
+			want: map[string]gtfs.ServiceCategory{"WD": gtfs.RegularService, "WE": gtfs.RegularService},
+		},
+		{
+			name: "long weekend",
+			dates: []gtfs.CalendarDate{
+				{ServiceID: "WD", Date: "20220415", ExceptionType: 2},
+				{ServiceID: "WD", Date: "20220418", ExceptionType: 2},
+			},
+			want: map[string]gtfs.ServiceCategory{"WD": gtfs.RegularService, "WE": gtfs.RegularService},
+		},
+		{
+			name: "school holidays",
+			dates: []gtfs.CalendarDate{
+				{ServiceID: "WD", Date: "20220711", ExceptionType: 2},
+				{ServiceID: "WD", Date: "20220712", ExceptionType: 2},
+				{ServiceID: "WD", Date: "20220713", ExceptionType: 2},
+				{ServiceID: "WD", Date: "20220714", ExceptionType: 2},
+				{ServiceID: "WD", Date: "20220715", ExceptionType: 2},
+				{ServiceID: "HO", Date: "20220712", ExceptionType: 1},
+				{ServiceID: "HO", Date: "20220716", ExceptionType: 1},
+				{ServiceID: "EV", Date: "20220712", ExceptionType: 1},
+				{ServiceID: "EV", Date: "20220720", ExceptionType: 1},
+			},
+			want: map[string]gtfs.ServiceCategory{"WD": gtfs.SchoolDaysOnly, "WE": gtfs.RegularService, "HO": gtfs.HolidaysOnly, "EV": gtfs.RegularService},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFixtureDB(t)
+			for _, cd := range tt.dates {
+				if tx := db.Create(&cd); tx.Error != nil {
+					t.Fatalf("failed to add calendar date: %v", tx.Error)
+				}
+			}
+			got, err := gtfs.ClassifyServices(db)
+			if err != nil {
+				t.Fatalf("ClassifyServices() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ClassifyServices() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseServiceCategory(t *testing.T) {
+	for _, c := range []gtfs.ServiceCategory{gtfs.RegularService, gtfs.SchoolDaysOnly, gtfs.HolidaysOnly} {
+		if got, err := gtfs.ParseServiceCategory(c.String()); err != nil || got != c {
+			t.Errorf("ParseServiceCategory(%q) = %v, %v, want %v", c.String(), got, err, c)
+		}
+	}
+	if _, err := gtfs.ParseServiceCategory("weekends"); err == nil {
+		t.Errorf("ParseServiceCategory() error = nil, want error")
+	}
+}
+
+func TestDepartures_ExcludeCategories(t *testing.T) {
+	db := newFixtureDB(t)
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+	from := time.Date(2022, 3, 1, 7, 55, 0, 0, berlin)
+	opts := gtfs.DeparturesOptions{ExcludeCategories: []gtfs.ServiceCategory{gtfs.SchoolDaysOnly}}
+
+	// classified by Departures
+	departures, err := gtfs.Departures(db, "S1", from, berlin, opts)
+	if err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if len(departures) != 2 {
+		t.Errorf("Departures() = %v, want 2 departures", departures)
+	}
+
+	// classified by the caller
+	opts.Categories = map[string]gtfs.ServiceCategory{"WD": gtfs.SchoolDaysOnly}
+	if departures, err = gtfs.Departures(db, "S1", from, berlin, opts); err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if len(departures) != 0 {
+		t.Errorf("Departures() = %v, want none", departures)
+	}
+}
@@ -0,0 +1,133 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"time"
+)
+
+// statement to select the trips (along with their route) of the given services
+const serviceTripsStmt = `
+SELECT
+	trips.route_id,
+	routes.short_name,
+	trips.id
+FROM
+	trips
+	JOIN routes ON routes.id = trips.route_id
+WHERE
+	trips.service_id IN ?
+ORDER BY
+	trips.route_id,
+	trips.id;
+`
+
+// RouteServiceDiff describes how the trips of a route running on one day
+// differ from those running on another (see CompareService).
+type RouteServiceDiff struct {
+	RouteID    string
+	RouteName  string
+	BaseTrips  int      // the number of trips running on the base day
+	OtherTrips int      // the number of trips running on the other day
+	Removed    []string // the trips running on the base day only
+	Added      []string // the trips running on the other day only
+}
+
+// String returns a human-readable representation of RouteServiceDiff (e.g.
+// "S1: 40 -> 20 trips (-50%)").
+func (d RouteServiceDiff) String() string {
+	switch {
+	case d.OtherTrips == 0:
+		return fmt.Sprintf("%s: %d -> 0 trips (no service)", d.RouteName, d.BaseTrips)
+	case d.BaseTrips == 0:
+		return fmt.Sprintf("%s: 0 -> %d trips (extra service)", d.RouteName, d.OtherTrips)
+	default:
+		return fmt.Sprintf("%s: %d -> %d trips (%+d%%)", d.RouteName, d.BaseTrips, d.OtherTrips, (d.OtherTrips-d.BaseTrips)*100/d.BaseTrips)
+	}
+}
+
+// Reduced returns true, if fewer trips of the route run on the other day.
+func (d RouteServiceDiff) Reduced() bool {
+	return d.OtherTrips < d.BaseTrips
+}
+
+// CompareService diffs the trips running on the date of base (e.g. a regular
+// weekday) with those running on the date of other (e.g. a holiday) per route,
+// according to calendars and calendar dates. Routes running the same trips on
+// both days are omitted, the others are ordered by route ID.
+func CompareService(db *gorm.DB, base, other time.Time) ([]RouteServiceDiff, error) {
+	baseTrips, err := runningTrips(db, base)
+	if err != nil {
+		return nil, err
+	}
+	otherTrips, err := runningTrips(db, other)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := map[string]*RouteServiceDiff{}
+	diff := func(t routeTrip) *RouteServiceDiff {
+		d, ok := diffs[t.RouteID]
+		if !ok {
+			d = &RouteServiceDiff{RouteID: t.RouteID, RouteName: t.ShortName}
+			diffs[t.RouteID] = d
+		}
+		return d
+	}
+	for key, t := range baseTrips {
+		d := diff(t)
+		d.BaseTrips++
+		if _, ok := otherTrips[key]; !ok {
+			d.Removed = append(d.Removed, t.ID)
+		}
+	}
+	for key, t := range otherTrips {
+		d := diff(t)
+		d.OtherTrips++
+		if _, ok := baseTrips[key]; !ok {
+			d.Added = append(d.Added, t.ID)
+		}
+	}
+
+	result := []RouteServiceDiff{}
+	for _, d := range diffs {
+		if len(d.Removed) == 0 && len(d.Added) == 0 {
+			continue
+		}
+		sort.Strings(d.Removed)
+		sort.Strings(d.Added)
+		result = append(result, *d)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].RouteID < result[j].RouteID
+	})
+	return result, nil
+}
+
+// routeTrip is a trip along with its route.
+type routeTrip struct {
+	RouteID   string
+	ShortName string
+	ID        string
+}
+
+// runningTrips returns the trips (by ID) running on the date of day.
+func runningTrips(db *gorm.DB, day time.Time) (map[string]routeTrip, error) {
+	serviceIDs, err := ActiveServices(db, day)
+	if err != nil {
+		return nil, err
+	}
+	trips := map[string]routeTrip{}
+	if len(serviceIDs) == 0 {
+		return trips, nil
+	}
+	var rows []routeTrip
+	if tx := db.Raw(serviceTripsStmt, serviceIDs).Scan(&rows); tx.Error != nil {
+		return nil, tx.Error
+	}
+	for _, t := range rows {
+		trips[t.ID] = t
+	}
+	return trips, nil
+}
@@ -0,0 +1,47 @@
+package gtfs_test
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"testing"
+	"time"
+)
+
+func TestCompareService(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// a weekend trip of route R1
+	copyTrip(t, db, "T1", "T9", 0)
+	db.Model(&gtfs.Trip{}).Where("id = ?", "T9").Update("service_id", "WE")
+
+	tests := []struct {
+		name        string
+		base, other string
+		want        []string
+		wantRemoved []string
+	}{
+		{"holiday", "20221221", "20221226", []string{"S1: 3 -> 1 trips (-66%)", "218: 0 -> 1 trips (extra service)"}, []string{"T1", "T2", "T3"}},
+		{"weekend", "20221224", "20221221", []string{"S1: 1 -> 3 trips (+200%)", "218: 1 -> 0 trips (no service)"}, []string{"T9"}},
+		{"same", "20221221", "20221222", nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, _ := time.Parse(gtfs.DateLayout, tt.base)
+			other, _ := time.Parse(gtfs.DateLayout, tt.other)
+			got, err := gtfs.CompareService(db, base, other)
+			if err != nil {
+				t.Fatalf("CompareService() error = %v", err)
+			}
+			var summaries []string
+			for _, d := range got {
+				summaries = append(summaries, d.String())
+			}
+			if fmt.Sprint(summaries) != fmt.Sprint(tt.want) {
+				t.Errorf("CompareService() = %q, want %q", summaries, tt.want)
+			}
+			if len(got) > 0 && fmt.Sprint(got[0].Removed) != fmt.Sprint(tt.wantRemoved) {
+				t.Errorf("CompareService() removed = %v, want %v", got[0].Removed, tt.wantRemoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+)
+
+// ShapeBounds model, i.e. the bounding box of a shape (see IndexShapeBounds).
+type ShapeBounds struct {
+	ShapeID string  `gorm:"primaryKey"`
+	MinLat  float64 `gorm:"index:idx_shape_bounds_lat,priority:1"`
+	MaxLat  float64 `gorm:"index:idx_shape_bounds_lat,priority:2"`
+	MinLon  float64 `gorm:"index:idx_shape_bounds_lon,priority:1"`
+	MaxLon  float64 `gorm:"index:idx_shape_bounds_lon,priority:2"`
+}
+
+// TableName returns the name of the table holding ShapeBounds items.
+func (ShapeBounds) TableName() string {
+	return "shape_bounds"
+}
+
+// statement to compute the bounding boxes of all shapes (stored as rows of
+// points or encoded, see EncodeShapes)
+const shapeBoundsStmt = `
+INSERT INTO shape_bounds (shape_id, min_lat, max_lat, min_lon, max_lon)
+SELECT
+	shape_id,
+	MIN(pt_lat),
+	MAX(pt_lat),
+	MIN(pt_lon),
+	MAX(pt_lon)
+FROM
+	shapes
+GROUP BY
+	shape_id
+UNION
+SELECT
+	shape_id,
+	min_lat,
+	max_lat,
+	min_lon,
+	max_lon
+FROM
+	encoded_shapes
+WHERE
+	shape_id NOT IN (
+	SELECT DISTINCT
+		shape_id
+	FROM
+		shapes);
+`
+
+// statement to select the shapes (by ID) whose bounding box intersects a
+// bounding box (south, north, west, east)
+const shapesInBoundsStmt = `
+SELECT
+	shape_id
+FROM
+	shape_bounds
+WHERE
+	min_lat <= ? AND
+	max_lat >= ? AND
+	min_lon <= ? AND
+	max_lon >= ?
+ORDER BY
+	shape_id;
+`
+
+// statement to remove the bounding boxes of shapes that no longer exist
+const delShapeBoundsStmt = `
+DELETE
+FROM
+	shape_bounds
+WHERE
+	shape_id NOT IN (
+	SELECT DISTINCT
+		shape_id
+	FROM
+		shapes)
+	AND shape_id NOT IN (
+	SELECT
+		shape_id
+	FROM
+		encoded_shapes);
+`
+
+// statement to remove the bounding boxes of shapes that no longer exist (in
+// DBs lacking encoded shapes)
+const delUnencodedShapeBoundsStmt = `
+DELETE
+FROM
+	shape_bounds
+WHERE
+	shape_id NOT IN (
+	SELECT DISTINCT
+		shape_id
+	FROM
+		shapes);
+`
+
+// IndexShapeBounds (re-)computes the bounding boxes of all shapes (i.e. the
+// shape_bounds table), allowing for selecting the shapes within a viewport by
+// a range scan (see ShapesInBounds). The bounding boxes are computed when
+// importing shapes.
+func IndexShapeBounds(db *gorm.DB) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM shape_bounds").Error; err != nil {
+			return err
+		}
+		return tx.Exec(shapeBoundsStmt).Error
+	})
+}
+
+// hasShapeBounds returns true, if the DB holds the bounding boxes of shapes
+// (see IndexShapeBounds).
+func hasShapeBounds(db *gorm.DB) bool {
+	if !db.Migrator().HasTable(&ShapeBounds{}) {
+		return false
+	}
+	var exists bool
+	return db.Raw("SELECT EXISTS (SELECT 1 FROM shape_bounds)").Scan(&exists).Error == nil && exists
+}
+
+// ShapesInBounds returns the IDs (ordered) of the shapes whose bounding box
+// intersects the given bounding box. Candidates are selected by a range scan
+// on the bounding boxes of the shapes (see IndexShapeBounds), thus callers
+// interested in the exact geometry must filter the candidates further.
+func ShapesInBounds(db *gorm.DB, south, west, north, east float64) ([]string, error) {
+	shapeIDs := []string{}
+	if tx := db.Raw(shapesInBoundsStmt, north, south, east, west).Scan(&shapeIDs); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return shapeIDs, nil
+}
@@ -0,0 +1,51 @@
+package gtfs_test
+
+import (
+	"fmt"
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestShapesInBounds(t *testing.T) {
+	db := newFixtureDB(t)
+
+	var sh1 gtfs.ShapeBounds
+	if tx := db.First(&sh1, "shape_id = ?", "SH1"); tx.Error != nil {
+		t.Fatalf("failed to find bounds of SH1: %v", tx.Error)
+	}
+	if want := (gtfs.ShapeBounds{ShapeID: "SH1", MinLat: 52.421, MaxLat: 52.456, MinLon: 13.179, MaxLon: 13.321}); sh1 != want {
+		t.Errorf("bounds of SH1 = %+v, want %+v", sh1, want)
+	}
+
+	tests := []struct {
+		name                     string
+		south, west, north, east float64
+		want                     []string
+	}{
+		{"all", 52, 13, 53, 14, []string{"SH1", "SH2", "SH3"}},
+		{"west", 52.42, 13.16, 52.43, 13.17, []string{"SH3"}},
+		{"east", 52.45, 13.3, 52.46, 13.4, []string{"SH1", "SH2"}},
+		{"none", 0, 0, 1, 1, []string{}},
+	}
+	check := func() {
+		for _, tt := range tests {
+			got, err := gtfs.ShapesInBounds(db, tt.south, tt.west, tt.north, tt.east)
+			if err != nil {
+				t.Fatalf("ShapesInBounds() error = %v", err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("ShapesInBounds(%s) = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+	check()
+
+	// encoded shapes are indexed just the same
+	if _, err := gtfs.EncodeShapes(db); err != nil {
+		t.Fatalf("EncodeShapes() error = %v", err)
+	}
+	if err := gtfs.IndexShapeBounds(db); err != nil {
+		t.Fatalf("IndexShapeBounds() error = %v", err)
+	}
+	check()
+}
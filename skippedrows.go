@@ -0,0 +1,38 @@
+package gtfs
+
+import (
+	"github.com/gocarina/gocsv"
+	"gorm.io/gorm"
+	"io"
+)
+
+// SkippedRow model (a row failed to parse and skipped when importing, see
+// ImportLenient and ImportOptions.RecordSkippedRows).
+type SkippedRow struct {
+	ID    uint   `gorm:"primaryKey,autoIncrement" csv:"-"`
+	File  string `csv:"file"`
+	Line  int64  `csv:"line"`
+	Raw   string `csv:"raw"` // the content of the row (comma delimited, without the line break)
+	Error string `csv:"error"`
+}
+
+// TableName returns the name of the table holding SkippedRow items.
+func (SkippedRow) TableName() string {
+	return "skipped_rows"
+}
+
+// SkippedRows returns all rows recorded as skipped when importing (in the
+// order recorded).
+func SkippedRows(db *gorm.DB) ([]SkippedRow, error) {
+	var rows []SkippedRow
+	if err := db.Order("id").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// WriteSkippedRows writes the given rows skipped when importing (e.g. see
+// ImportSummary.SkippedRows or SkippedRows) as CSV to w.
+func WriteSkippedRows(w io.Writer, rows []SkippedRow) error {
+	return gocsv.Marshal(rows, w)
+}
@@ -0,0 +1,60 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+)
+
+func TestImportWithOptions_RecordSkippedRows(t *testing.T) {
+
+	// stops holding an invalid latitude (in line 3) and a stray quote (in
+	// line 5)
+	feed := alteredFeed(t, map[string]func([]byte) []byte{"stops.txt": func(b []byte) []byte {
+		lines := strings.SplitAfter(string(b), "\n")
+		lines = append(lines[:2], append([]string{"X1,Nowhere,north,13.3\n"}, lines[2:]...)...)
+		lines = append(lines[:4], append([]string{"X2,Stray \"Quote,52.5,13.3\n"}, lines[4:]...)...)
+		return []byte(strings.Join(lines, ""))
+	}})
+	db := newTestDB(t)
+	summary := gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{Mode: gtfs.ImportLenient, RecordSkippedRows: true})
+	if err := summary.Err(); err != nil {
+		t.Fatalf("ImportWithOptions() error = %v", err)
+	}
+
+	want := []gtfs.SkippedRow{
+		{File: "stops.txt", Line: 3, Raw: "X1,Nowhere,north,13.3", Error: "invalid stop_lat 'north'"},
+		{File: "stops.txt", Line: 5, Raw: "X2,Stray \"Quote,52.5,13.3", Error: "bare \" in non-quoted-field"},
+	}
+	recorded, err := gtfs.SkippedRows(db)
+	if err != nil {
+		t.Fatalf("SkippedRows() error = %v", err)
+	}
+	for name, rows := range map[string][]gtfs.SkippedRow{"ImportSummary.SkippedRows()": summary.SkippedRows(), "SkippedRows()": recorded} {
+		if len(rows) != len(want) {
+			t.Fatalf("%s = %+v, want %d rows", name, rows, len(want))
+		}
+		for i, w := range want {
+			got := rows[i]
+			if got.File != w.File || got.Line != w.Line || got.Raw != w.Raw || !strings.Contains(got.Error, w.Error) {
+				t.Errorf("%s[%d] = %+v, want %+v", name, i, got, w)
+			}
+		}
+	}
+
+	// the stops following the rows skipped are imported
+	var count int64
+	db.Model(&gtfs.Stop{}).Count(&count)
+	if count != 7 {
+		t.Errorf("ImportWithOptions() stops = %d, want 7", count)
+	}
+
+	var buf bytes.Buffer
+	if err = gtfs.WriteSkippedRows(&buf, recorded); err != nil {
+		t.Fatalf("WriteSkippedRows() error = %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "file,line,raw,error\n") || strings.Count(buf.String(), "\n") != 3 {
+		t.Errorf("WriteSkippedRows() = %q", buf.String())
+	}
+}
@@ -0,0 +1,154 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// statement to select the stop times of all trips along with the positions of
+// the stops and the types of the routes
+const tripSegmentsStmt = `
+SELECT
+	trips.id,
+	trips.route_id,
+	routes.type,
+	stop_times.stop_id,
+	stop_times.arrival,
+	stop_times.departure,
+	stops.latitude,
+	stops.longitude
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+	JOIN routes ON routes.id = trips.route_id
+	JOIN stops ON stops.id = stop_times.stop_id
+ORDER BY
+	trips.id,
+	stop_times.stop_seq;
+`
+
+// segment is the travel of a trip between two consecutive stops.
+type segment struct {
+	tripID     string
+	routeID    string
+	routeType  int
+	fromStopID string
+	toStopID   string
+	meters     float64
+	seconds    int32
+}
+
+// kmh returns the speed on the segment in km/h.
+func (s segment) kmh() float64 {
+	return s.meters / float64(s.seconds) * 3.6
+}
+
+// MaxSpeed returns the maximum plausible speed (in km/h) of vehicles serving
+// routes of the given (basic or extended) route type.
+func MaxSpeed(routeType int) float64 {
+	switch {
+	case routeType == 0 || routeType >= 900 && routeType < 1000:
+		return 120 // tram
+	case routeType == 1 || routeType >= 400 && routeType < 500:
+		return 150 // subway, metro, urban railway
+	case routeType == 2 || routeType >= 100 && routeType < 200:
+		return 350 // railway
+	case routeType == 4 || routeType >= 1000 && routeType < 1300:
+		return 100 // ferry, water transport
+	case routeType == 5 || routeType == 6 || routeType == 7 || routeType >= 1300 && routeType < 1500:
+		return 60 // cable tram, aerial lift, funicular
+	default:
+		return 200 // bus, coach, trolleybus and others
+	}
+}
+
+// tripSegments calls fn for each of the segments of all trips.
+func tripSegments(db *gorm.DB, fn func(s segment)) error {
+	rows, err := db.Raw(tripSegmentsStmt).Rows()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	var prevTripID, prevStopID string
+	var prevDeparture DateTime
+	var prevLat, prevLon float64
+	for rows.Next() {
+		var tripID, routeID, stopID string
+		var routeType int
+		var arrival, departure DateTime
+		var lat, lon float64
+		if err = rows.Scan(&tripID, &routeID, &routeType, &stopID, &arrival, &departure, &lat, &lon); err != nil {
+			return err
+		}
+		if tripID == prevTripID {
+			fn(segment{
+				tripID:     tripID,
+				routeID:    routeID,
+				routeType:  routeType,
+				fromStopID: prevStopID,
+				toStopID:   stopID,
+				meters:     haversine(prevLat, prevLon, lat, lon),
+				seconds:    arrival.Int32 - prevDeparture.Int32,
+			})
+		}
+		prevTripID, prevStopID, prevDeparture, prevLat, prevLon = tripID, stopID, departure, lat, lon
+	}
+	return rows.Err()
+}
+
+// ImplausibleSpeeds finds trips travelling back in time between consecutive
+// stops or travelling faster than vehicles of their route type plausibly do
+// (see MaxSpeed). Distances are measured as the crow flies, thus speeds are
+// underestimated rather than overestimated.
+func ImplausibleSpeeds(db *gorm.DB) ([]Issue, error) {
+	var issues []Issue
+	err := tripSegments(db, func(s segment) {
+		switch {
+		case s.seconds < 0:
+			issues = append(issues, Issue{
+				Severity: Error,
+				ItemType: Trips,
+				ItemID:   s.tripID,
+				Message:  fmt.Sprintf("negative travel time (%ds) from stop '%s' to '%s'", s.seconds, s.fromStopID, s.toStopID),
+			})
+		case s.seconds > 0 && s.kmh() > MaxSpeed(s.routeType):
+			issues = append(issues, Issue{
+				Severity: Warning,
+				ItemType: Trips,
+				ItemID:   s.tripID,
+				Message:  fmt.Sprintf("implausible speed (%.0f km/h) from stop '%s' to '%s'", s.kmh(), s.fromStopID, s.toStopID),
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issues, nil
+}
+
+// AverageSpeeds returns the average speed (in km/h, as the crow flies from stop
+// to stop and excluding dwell times) of the trips of each route.
+func AverageSpeeds(db *gorm.DB) (map[string]float64, error) {
+	meters := map[string]float64{}
+	seconds := map[string]int64{}
+	err := tripSegments(db, func(s segment) {
+		if s.seconds < 0 {
+			return
+		}
+		meters[s.routeID] += s.meters
+		seconds[s.routeID] += int64(s.seconds)
+	})
+	if err != nil {
+		return nil, err
+	}
+	speeds := make(map[string]float64, len(meters))
+	for routeID, m := range meters {
+		if seconds[routeID] > 0 {
+			speeds[routeID] = m / float64(seconds[routeID]) * 3.6
+		}
+	}
+	return speeds, nil
+}
@@ -0,0 +1,72 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+)
+
+func TestImplausibleSpeeds(t *testing.T) {
+	db := newFixtureDB(t)
+
+	issues, err := gtfs.ImplausibleSpeeds(db)
+	if err != nil {
+		t.Fatalf("ImplausibleSpeeds() error = %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("ImplausibleSpeeds() = %v, want none", issues)
+	}
+
+	// T1 reaching S2 within 10s, T4 reaching B2 before leaving B1
+	db.Model(&gtfs.StopTime{}).Where("trip_id = ? AND stop_seq = ?", "T1", 2).Update("arrival", gtfs.DateTime{Int32: 8*3600 + 10})
+	db.Model(&gtfs.StopTime{}).Where("trip_id = ? AND stop_seq = ?", "T4", 2).Update("arrival", gtfs.DateTime{Int32: 9*3600 + 59*60})
+
+	issues, err = gtfs.ImplausibleSpeeds(db)
+	if err != nil {
+		t.Fatalf("ImplausibleSpeeds() error = %v", err)
+	}
+	if len(issues) != 2 {
+		t.Fatalf("ImplausibleSpeeds() = %v, want 2 issues", issues)
+	}
+	if issues[0].ItemID != "T1" || issues[0].Severity != gtfs.Warning || !strings.Contains(issues[0].Message, "speed") {
+		t.Errorf("ImplausibleSpeeds() = %v, want an implausible speed of T1", issues[0])
+	}
+	if issues[1].ItemID != "T4" || issues[1].Severity != gtfs.Error || !strings.Contains(issues[1].Message, "negative") {
+		t.Errorf("ImplausibleSpeeds() = %v, want a negative travel time of T4", issues[1])
+	}
+}
+
+func TestMaxSpeed(t *testing.T) {
+	tests := []struct {
+		routeType int
+		want      float64
+	}{
+		{3, 200},
+		{700, 200},
+		{109, 350},
+		{900, 120},
+		{1000, 100},
+	}
+	for _, tt := range tests {
+		if got := gtfs.MaxSpeed(tt.routeType); got != tt.want {
+			t.Errorf("MaxSpeed(%d) = %f, want %f", tt.routeType, got, tt.want)
+		}
+	}
+}
+
+func TestAverageSpeeds(t *testing.T) {
+	db := newFixtureDB(t)
+
+	speeds, err := gtfs.AverageSpeeds(db)
+	if err != nil {
+		t.Fatalf("AverageSpeeds() error = %v", err)
+	}
+	if len(speeds) != 2 {
+		t.Fatalf("AverageSpeeds() = %v, want speeds of 2 routes", speeds)
+	}
+	for routeID, speed := range speeds {
+		if speed < 10 || speed > 60 {
+			t.Errorf("AverageSpeeds() %s = %f, want a plausible speed", routeID, speed)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+)
+
+// maxRenames is the maximum number of renames recorded per item type.
+const maxRenames = 100
+
+// statements to select the IDs of stops, routes and trips along with a
+// signature identifying them regardless of their ID (i.e. the stop name and
+// its approximate location, the agency and names of a route, as well as the
+// route, direction, headsign and first departure of a trip)
+const (
+	stopSignaturesStmt = `
+SELECT
+	id,
+	name || '|' || printf('%.3f|%.3f', latitude, longitude)
+FROM
+	stops;
+`
+	routeSignaturesStmt = `
+SELECT
+	id,
+	agency_id || '|' || short_name || '|' || long_name || '|' || type
+FROM
+	routes;
+`
+	tripSignaturesStmt = `
+SELECT
+	trips.id,
+	routes.short_name || '|' || trips.direction_id || '|' || trips.headsign || '|' || IFNULL(MIN(stop_times.departure), '')
+FROM
+	trips
+	LEFT JOIN routes ON routes.id = trips.route_id
+	LEFT JOIN stop_times ON stop_times.trip_id = trips.id
+GROUP BY
+	trips.id;
+`
+)
+
+// signatureStmts maps the item types compared by CompareIDs to the
+// statements selecting their signatures.
+var signatureStmts = []struct {
+	itemType ItemType
+	stmt     string
+}{
+	{Stops, stopSignaturesStmt},
+	{Routes, routeSignaturesStmt},
+	{Trips, tripSignaturesStmt},
+}
+
+// Rename describes an item whose ID changed between feed versions.
+type Rename struct {
+	From string
+	To   string
+}
+
+// IDStability is the type used to describe how stable the IDs of an item
+// type are between two versions of a feed.
+type IDStability struct {
+	ItemType ItemType
+
+	// Previous is the number of items in the previous version.
+	Previous int
+
+	// Retained is the number of IDs present in both versions.
+	Retained int
+
+	// Renamed is the number of items whose ID changed (i.e. items of the
+	// previous version matching a single item of the current version by
+	// signature, see CompareIDs).
+	Renamed int
+
+	// Removed is the number of IDs of the previous version neither retained
+	// nor renamed.
+	Removed int
+
+	// Added is the number of IDs of the current version neither retained nor
+	// renamed.
+	Added int
+
+	// Renames are (up to 100 of) the renamed items.
+	Renames []Rename
+}
+
+// RetainedPercent returns the percentage of IDs of the previous version
+// retained in the current version (100, if there were none).
+func (s IDStability) RetainedPercent() float64 {
+	if s.Previous == 0 {
+		return 100
+	}
+	return float64(s.Retained) / float64(s.Previous) * 100
+}
+
+// Stable reports whether all IDs of the previous version were retained.
+func (s IDStability) Stable() bool {
+	return s.Retained == s.Previous
+}
+
+// String returns a human-readable representation of IDStability.
+func (s IDStability) String() string {
+	return fmt.Sprintf("%s: %.1f%% of %d IDs retained, %d renamed, %d removed, %d added", s.ItemType, s.RetainedPercent(), s.Previous, s.Renamed, s.Removed, s.Added)
+}
+
+// CompareIDs compares the IDs of stops, routes and trips of the previous
+// version of a feed with those of the current version (e.g. to warn
+// integrators referencing IDs in bookmarks or favorites). Items whose ID is
+// no longer present are considered renamed, if their signature (stops: name
+// and location rounded to 3 decimal places, routes: agency and names, trips:
+// route, direction, headsign and first departure) matches the signature of
+// exactly one added item (and no other removed item).
+func CompareIDs(previous, current *gorm.DB) ([]IDStability, error) {
+	var result []IDStability
+	for _, s := range signatureStmts {
+		prev, err := loadSignatures(previous, s.stmt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load previous %s: %w", s.itemType, err)
+		}
+		cur, err := loadSignatures(current, s.stmt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load current %s: %w", s.itemType, err)
+		}
+		result = append(result, compareSignatures(s.itemType, prev, cur))
+	}
+	return result, nil
+}
+
+// loadSignatures loads IDs along with their signatures.
+func loadSignatures(db *gorm.DB, stmt string) (map[string]string, error) {
+	rows, err := db.Raw(stmt).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	signatures := map[string]string{}
+	for rows.Next() {
+		var id, signature string
+		if err = rows.Scan(&id, &signature); err != nil {
+			return nil, err
+		}
+		signatures[id] = signature
+	}
+	return signatures, rows.Err()
+}
+
+// compareSignatures compares the IDs (and signatures) of two versions.
+func compareSignatures(itemType ItemType, prev, cur map[string]string) IDStability {
+	s := IDStability{ItemType: itemType, Previous: len(prev)}
+
+	// IDs removed and added (by signature)
+	removed := map[string][]string{}
+	for id, sig := range prev {
+		if _, ok := cur[id]; ok {
+			s.Retained++
+			continue
+		}
+		removed[sig] = append(removed[sig], id)
+	}
+	added := map[string][]string{}
+	for id, sig := range cur {
+		if _, ok := prev[id]; !ok {
+			added[sig] = append(added[sig], id)
+		}
+	}
+
+	// unambiguously matching signatures are renames
+	for sig, from := range removed {
+		to := added[sig]
+		if len(from) == 1 && len(to) == 1 {
+			s.Renamed++
+			if len(s.Renames) < maxRenames {
+				s.Renames = append(s.Renames, Rename{From: from[0], To: to[0]})
+			}
+			delete(added, sig)
+			continue
+		}
+		s.Removed += len(from)
+	}
+	for _, to := range added {
+		s.Added += len(to)
+	}
+	sort.Slice(s.Renames, func(i, j int) bool {
+		return s.Renames[i].From < s.Renames[j].From
+	})
+	return s
+}
@@ -0,0 +1,40 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestCompareIDs(t *testing.T) {
+	previous := newFixtureDB(t)
+
+	// rename S1, remove S2, add S9 and rename T3
+	current := newFixtureDB(t)
+	current.Exec("UPDATE stops SET id = 'S1x' WHERE id = 'S1'")
+	current.Exec("DELETE FROM stops WHERE id = 'S2'")
+	current.Create(&gtfs.Stop{ID: "S9", Name: "Somewhere", Latitude: 52.5, Longitude: 13.4})
+	current.Exec("UPDATE trips SET id = 'T3x' WHERE id = 'T3'")
+	current.Exec("UPDATE stop_times SET trip_id = 'T3x' WHERE trip_id = 'T3'")
+
+	stability, err := gtfs.CompareIDs(previous, current)
+	if err != nil {
+		t.Fatalf("CompareIDs() error = %v", err)
+	}
+	want := map[gtfs.ItemType]gtfs.IDStability{
+		gtfs.Stops:  {Previous: 7, Retained: 5, Renamed: 1, Removed: 1, Added: 1},
+		gtfs.Routes: {Previous: 2, Retained: 2},
+		gtfs.Trips:  {Previous: 4, Retained: 3, Renamed: 1},
+	}
+	for _, s := range stability {
+		w := want[s.ItemType]
+		if s.Previous != w.Previous || s.Retained != w.Retained || s.Renamed != w.Renamed || s.Removed != w.Removed || s.Added != w.Added {
+			t.Errorf("CompareIDs() = %s, want %+v", s, w)
+		}
+		if s.Stable() != (w.Retained == w.Previous) {
+			t.Errorf("CompareIDs() %s stable = %t", s.ItemType, s.Stable())
+		}
+	}
+	if r := stability[0].Renames; len(r) != 1 || r[0].From != "S1" || r[0].To != "S1x" {
+		t.Errorf("CompareIDs() stop renames = %v, want S1 to S1x", r)
+	}
+}
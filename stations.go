@@ -0,0 +1,92 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+)
+
+// The location types of stops (see Stop.LocationType).
+const (
+
+	// LocationStop the location type of stops and platforms.
+	LocationStop = iota
+
+	// LocationStation the location type of stations (grouping platforms).
+	LocationStation
+
+	// LocationEntrance the location type of entrances and exits of stations.
+	LocationEntrance
+
+	// LocationGenericNode the location type of nodes within stations (e.g.
+	// connecting pathways).
+	LocationGenericNode
+
+	// LocationBoardingArea the location type of areas of platforms.
+	LocationBoardingArea
+)
+
+// maxStationDepth is the maximum number of parents followed from a stop to its
+// station (i.e. from a boarding area via its platform), guarding against
+// cyclic parent stations.
+const maxStationDepth = 3
+
+// StationChildren returns the stops (i.e. platforms, entrances and generic
+// nodes) of the station with the given ID along with the boarding areas of its
+// platforms, ordered by location type and ID. If there is no such stop,
+// gorm.ErrRecordNotFound is returned.
+func StationChildren(db *gorm.DB, stationID string) ([]Stop, error) {
+	if _, err := GetStop(db, stationID); err != nil {
+		return nil, err
+	}
+	var children []Stop
+	tx := db.
+		Where("parent_station = ?", stationID).
+		Or("parent_station IN (?)", db.Model(&Stop{}).Select("id").Where("parent_station = ?", stationID)).
+		Order("location_type").
+		Order("id").
+		Find(&children)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if err := ApplyStopOverrides(db, children); err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// StationOf returns the station the stop with the given ID is part of (i.e.
+// the parent station of platforms, entrances and generic nodes, the station of
+// the platform of boarding areas) or the stop itself, if it is a station or
+// has no parent station. If there is no such stop, gorm.ErrRecordNotFound is
+// returned.
+func StationOf(db *gorm.DB, stopID string) (*Stop, error) {
+	stop, err := GetStop(db, stopID)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < maxStationDepth && stop.LocationType != LocationStation && stop.ParentStation != ""; i++ {
+		parent, err := GetStop(db, stop.ParentStation)
+		if err != nil {
+			return nil, err
+		}
+		stop = parent
+	}
+	return stop, nil
+}
+
+// stationStops returns the IDs of the stop with the given ID and of all stops
+// of its station (see StationOf and StationChildren).
+func stationStops(db *gorm.DB, stopID string) ([]string, error) {
+	station, err := StationOf(db, stopID)
+	if err != nil {
+		return nil, err
+	}
+	children, err := StationChildren(db, station.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []string{station.ID}
+	for _, c := range children {
+		ids = append(ids, c.ID)
+	}
+	return ids, nil
+}
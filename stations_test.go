@@ -0,0 +1,111 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+	"time"
+)
+
+// newStationDB returns the fixture DB with the station WS grouping S1 and B1
+// (along with the entrance E1 and the boarding area A1 of S1).
+func newStationDB(t *testing.T) *gorm.DB {
+	db := newFixtureDB(t)
+	db.Create(&gtfs.Stop{ID: "WS", Name: "Wannsee", Latitude: 52.4212, Longitude: 13.1792, LocationType: gtfs.LocationStation})
+	db.Create(&gtfs.Stop{ID: "E1", Name: "Wannsee (Kronprinzessinnenweg)", LocationType: gtfs.LocationEntrance, ParentStation: "WS"})
+	db.Create(&gtfs.Stop{ID: "A1", Name: "Wannsee Gleis 1 (Mitte)", LocationType: gtfs.LocationBoardingArea, ParentStation: "S1"})
+	db.Model(&gtfs.Stop{}).Where("id IN ?", []string{"S1", "B1"}).Update("parent_station", "WS")
+	return db
+}
+
+func TestStationChildren(t *testing.T) {
+	db := newStationDB(t)
+
+	children, err := gtfs.StationChildren(db, "WS")
+	if err != nil {
+		t.Fatalf("StationChildren() error = %v", err)
+	}
+	want := []string{"B1", "S1", "E1", "A1"}
+	if len(children) != len(want) {
+		t.Fatalf("StationChildren() = %v, want %v", children, want)
+	}
+	for i, c := range children {
+		if c.ID != want[i] {
+			t.Errorf("StationChildren() = %v, want %v", children, want)
+		}
+	}
+
+	if _, err = gtfs.StationChildren(db, "X"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("StationChildren() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestStationOf(t *testing.T) {
+	db := newStationDB(t)
+	tests := []struct {
+		stopID  string
+		want    string
+		wantErr error
+	}{
+		{"WS", "WS", nil},
+		{"S1", "WS", nil},
+		{"A1", "WS", nil},
+		{"S2", "S2", nil},
+		{"X", "", gorm.ErrRecordNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.stopID, func(t *testing.T) {
+			station, err := gtfs.StationOf(db, tt.stopID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("StationOf() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && station.ID != tt.want {
+				t.Errorf("StationOf() = %s, want %s", station.ID, tt.want)
+			}
+		})
+	}
+}
+
+func TestDepartures_Station(t *testing.T) {
+	db := newStationDB(t)
+
+	// B1 is served on weekends only, S1 (of the same station) on weekdays
+	tuesday := time.Date(2022, 3, 1, 7, 55, 0, 0, time.UTC)
+	tests := []struct {
+		station bool
+		want    int
+	}{
+		{false, 0},
+		{true, 2},
+	}
+	for _, tt := range tests {
+		departures, err := gtfs.Departures(db, "B1", tuesday, nil, gtfs.DeparturesOptions{Station: tt.station})
+		if err != nil {
+			t.Fatalf("Departures() error = %v", err)
+		}
+		if len(departures) != tt.want {
+			t.Errorf("Departures(station = %v) = %v, want %d departures", tt.station, departures, tt.want)
+		}
+	}
+}
+
+func TestTrim_Stations(t *testing.T) {
+	db := newStationDB(t)
+	if _, err := gtfs.Trim(db, "S-Bahn", gtfs.TrimOptions{}); err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+
+	// the station of S1 is kept (along with its entrance), B1 is removed
+	var stops []string
+	db.Model(&gtfs.Stop{}).Order("id").Pluck("id", &stops)
+	want := []string{"A1", "E1", "S1", "S2", "S3", "S4", "WS"}
+	if len(stops) != len(want) {
+		t.Fatalf("Trim() stops = %v, want %v", stops, want)
+	}
+	for i := range stops {
+		if stops[i] != want[i] {
+			t.Errorf("Trim() stops = %v, want %v", stops, want)
+		}
+	}
+}
@@ -0,0 +1,312 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FeedStats is the type used to describe the contents of a feed.
+type FeedStats struct {
+
+	// Counts holds the number of items per item type.
+	Counts map[ItemType]int64
+
+	// FirstDay and LastDay are the first and the last day of service (zero if
+	// there is no service).
+	FirstDay time.Time
+	LastDay  time.Time
+
+	// Patterns maps route IDs to the pattern of service of the routes.
+	Patterns map[string]ServicePattern
+
+	// Boardings and Alightings are the totals of the (external) ridership
+	// counts (see ImportRidership).
+	Boardings  int64
+	Alightings int64
+
+	// Meta is the metadata recorded for the feed (nil if none was recorded,
+	// see RecordFeedMeta).
+	Meta *FeedMeta
+
+	// Issues are the issues found when analyzing the CSV files of a feed (see
+	// AnalyzeFeed), nil if not analyzed (see Validate).
+	Issues []Issue
+}
+
+// Stats computes statistics of the feed within the given DB.
+func Stats(db *gorm.DB) (*FeedStats, error) {
+	fs := &FeedStats{Counts: map[ItemType]int64{}}
+
+	for itemType, model := range itemModels {
+		var count int64
+		if tx := db.Model(model).Count(&count); tx.Error != nil {
+			return nil, fmt.Errorf("failed to count %s: %w", itemType, tx.Error)
+		}
+		fs.Counts[itemType] = count
+	}
+
+	var err error
+	fs.FirstDay, fs.LastDay, err = ServicePeriod(db, nil)
+	if err != nil && !errors.Is(err, ErrNoServicePeriod) {
+		return nil, err
+	}
+
+	fs.Patterns, err = ClassifyRoutes(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to classify routes: %w", err)
+	}
+
+	// ridership counts are optional
+	if db.Migrator().HasTable(&StopRidership{}) {
+		row := db.Model(&StopRidership{}).Select("COALESCE(SUM(boardings), 0), COALESCE(SUM(alightings), 0)").Row()
+		if err = row.Scan(&fs.Boardings, &fs.Alightings); err != nil {
+			return nil, fmt.Errorf("failed to sum ridership: %w", err)
+		}
+	}
+
+	// metadata is optional
+	fs.Meta, err = GetFeedMeta(db)
+	if err != nil && !errors.Is(err, ErrNoFeedMeta) {
+		return nil, fmt.Errorf("failed to get feed metadata: %w", err)
+	}
+
+	return fs, nil
+}
+
+// String returns a human-readable representation of FeedStats.
+func (fs FeedStats) String() string {
+	var sb strings.Builder
+
+	itemTypes := make([]ItemType, 0, len(fs.Counts))
+	for itemType := range fs.Counts {
+		itemTypes = append(itemTypes, itemType)
+	}
+	sort.Slice(itemTypes, func(i, j int) bool {
+		return itemTypes[i] < itemTypes[j]
+	})
+	for _, itemType := range itemTypes {
+		sb.WriteString(fmt.Sprintf("%s: %d\n", itemType, fs.Counts[itemType]))
+	}
+
+	if !fs.FirstDay.IsZero() {
+		sb.WriteString(fmt.Sprintf("Service: %s - %s\n", fs.FirstDay.Format("2006-01-02"), fs.LastDay.Format("2006-01-02")))
+	}
+
+	if fs.Patterns != nil {
+		patterns := map[ServicePattern]int{}
+		for _, p := range fs.Patterns {
+			patterns[p]++
+		}
+		for _, p := range []ServicePattern{AllDay, PeakOnly, WeekendOnly, Night} {
+			sb.WriteString(fmt.Sprintf("Routes %s: %d\n", p, patterns[p]))
+		}
+	}
+
+	if fs.Boardings > 0 || fs.Alightings > 0 {
+		sb.WriteString(fmt.Sprintf("Boardings: %d\nAlightings: %d\n", fs.Boardings, fs.Alightings))
+	}
+
+	if fs.Meta != nil {
+		sb.WriteString(fs.Meta.String())
+	}
+
+	if fs.Issues != nil {
+		sb.WriteString(fmt.Sprintf("Issues: %d\n", len(fs.Issues)))
+	}
+
+	return sb.String()
+}
+
+// AgencyStats is the type used to describe the share of a single agency in
+// the contents of a feed (see StatsByAgency).
+type AgencyStats struct {
+	AgencyID string
+	Name     string
+
+	// Routes holds the number of routes per mode (see RouteMode).
+	Routes map[string]int64
+
+	// Trips holds the number of trips operated per weekday (indexed by
+	// time.Weekday) according to the regular (weekly) service given by the
+	// calendars (i.e. disregarding calendar dates).
+	Trips [7]int64
+
+	// Stops is the number of distinct stops served by the trips of the
+	// agency.
+	Stops int64
+}
+
+// routeModes are the names of the modes of the basic route types.
+var routeModes = map[int]string{
+	0:  "tram",
+	1:  "subway",
+	2:  "rail",
+	3:  "bus",
+	4:  "ferry",
+	5:  "cable tram",
+	6:  "aerial lift",
+	7:  "funicular",
+	11: "trolleybus",
+	12: "monorail",
+}
+
+// RouteMode returns the name of the mode of transport of the given (basic or
+// extended, see BasicRouteType) route type (e.g. "rail" for 109), or the
+// route type itself if unknown.
+func RouteMode(routeType int) string {
+	if mode, ok := routeModes[BasicRouteType(routeType)]; ok {
+		return mode
+	}
+	return fmt.Sprintf("route type %d", routeType)
+}
+
+// statement to select the number of routes per agency and route type
+const agencyRoutesStmt = `
+SELECT
+	agency_id,
+	type,
+	COUNT(*)
+FROM
+	routes
+GROUP BY
+	agency_id,
+	type;
+`
+
+// statement to select the number of trips per agency and weekday (Sunday
+// first)
+const agencyTripsStmt = `
+SELECT
+	routes.agency_id,
+	SUM(calendars.sunday),
+	SUM(calendars.monday),
+	SUM(calendars.tuesday),
+	SUM(calendars.wednesday),
+	SUM(calendars.thursday),
+	SUM(calendars.friday),
+	SUM(calendars.saturday)
+FROM
+	trips
+	JOIN routes ON routes.id = trips.route_id
+	JOIN calendars ON calendars.service_id = trips.service_id
+GROUP BY
+	routes.agency_id;
+`
+
+// statement to select the stops served per agency
+const agencyStopsStmt = `
+SELECT DISTINCT
+	routes.agency_id,
+	stop_times.stop_id
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id
+	JOIN routes ON routes.id = trips.route_id;
+`
+
+// StatsByAgency computes statistics of the feed within the given DB per
+// agency (ordered by agency ID). Routes lacking an agency ID are attributed
+// to the only agency of the feed (to an agency with empty ID and name, if the
+// feed has several agencies).
+func StatsByAgency(db *gorm.DB) ([]AgencyStats, error) {
+	var agencies []Agency
+	if tx := db.Order("id").Find(&agencies); tx.Error != nil {
+		return nil, tx.Error
+	}
+	stats := make([]AgencyStats, 0, len(agencies))
+	index := map[string]int{}
+	for i, a := range agencies {
+		stats = append(stats, AgencyStats{AgencyID: a.ID, Name: a.Name, Routes: map[string]int64{}})
+		index[a.ID] = i
+	}
+
+	// of returns the stats of the agency with the given ID
+	of := func(agencyID string) *AgencyStats {
+		if agencyID == "" && len(agencies) == 1 {
+			agencyID = agencies[0].ID
+		}
+		i, ok := index[agencyID]
+		if !ok {
+			i = len(stats)
+			stats = append(stats, AgencyStats{AgencyID: agencyID, Routes: map[string]int64{}})
+			index[agencyID] = i
+		}
+		return &stats[i]
+	}
+
+	// count routes per mode
+	rows, err := db.Raw(agencyRoutesStmt).Rows()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count routes: %w", err)
+	}
+	for rows.Next() {
+		var agencyID string
+		var routeType int
+		var n int64
+		if err = rows.Scan(&agencyID, &routeType, &n); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to count routes: %w", err)
+		}
+		of(agencyID).Routes[RouteMode(routeType)] += n
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count routes: %w", err)
+	}
+
+	// count trips per weekday
+	if rows, err = db.Raw(agencyTripsStmt).Rows(); err != nil {
+		return nil, fmt.Errorf("failed to count trips: %w", err)
+	}
+	for rows.Next() {
+		var agencyID string
+		var trips [7]int64
+		if err = rows.Scan(&agencyID, &trips[0], &trips[1], &trips[2], &trips[3], &trips[4], &trips[5], &trips[6]); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to count trips: %w", err)
+		}
+		as := of(agencyID)
+		for i, n := range trips {
+			as.Trips[i] += n
+		}
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count trips: %w", err)
+	}
+
+	// count distinct stops (routes lacking an agency ID may share stops with
+	// the routes of the only agency)
+	if rows, err = db.Raw(agencyStopsStmt).Rows(); err != nil {
+		return nil, fmt.Errorf("failed to count stops: %w", err)
+	}
+	served := map[string]map[string]bool{}
+	for rows.Next() {
+		var agencyID, stopID string
+		if err = rows.Scan(&agencyID, &stopID); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("failed to count stops: %w", err)
+		}
+		as := of(agencyID)
+		if served[as.AgencyID] == nil {
+			served[as.AgencyID] = map[string]bool{}
+		}
+		if !served[as.AgencyID][stopID] {
+			served[as.AgencyID][stopID] = true
+			as.Stops++
+		}
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count stops: %w", err)
+	}
+
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].AgencyID < stats[j].AgencyID
+	})
+	return stats, nil
+}
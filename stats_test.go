@@ -0,0 +1,65 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	db := newFixtureDB(t)
+
+	fs, err := gtfs.Stats(db)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	if got := fs.Counts[gtfs.StopTimes]; got != 15 {
+		t.Errorf("Stats() stop times = %d, want 15", got)
+	}
+	if got := fs.Patterns["R2"]; got != gtfs.WeekendOnly {
+		t.Errorf("Stats() R2 = %v, want %v", got, gtfs.WeekendOnly)
+	}
+	s := fs.String()
+	for _, want := range []string{"Agencies: 2\n", "Service: 2022-01-01 - 2022-12-31\n", "Routes weekend-only: 1\n"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("String() = %q, want it to contain %q", s, want)
+		}
+	}
+}
+
+func TestStatsByAgency(t *testing.T) {
+	db := newFixtureDB(t)
+
+	stats, err := gtfs.StatsByAgency(db)
+	if err != nil {
+		t.Fatalf("StatsByAgency() error = %v", err)
+	}
+	if len(stats) != 2 {
+		t.Fatalf("StatsByAgency() = %+v, want 2 agencies", stats)
+	}
+	sbahn, bvg := stats[0], stats[1]
+	if sbahn.Name != "S-Bahn Berlin GmbH" || sbahn.Routes["rail"] != 1 || sbahn.Trips[time.Monday] != 3 || sbahn.Trips[time.Sunday] != 0 || sbahn.Stops != 4 {
+		t.Errorf("StatsByAgency() S-Bahn = %+v", sbahn)
+	}
+	if bvg.Routes["bus"] != 1 || bvg.Trips[time.Monday] != 0 || bvg.Trips[time.Saturday] != 1 || bvg.Stops != 3 {
+		t.Errorf("StatsByAgency() BVG = %+v", bvg)
+	}
+}
+
+func TestRouteMode(t *testing.T) {
+	tests := []struct {
+		routeType int
+		want      string
+	}{
+		{3, "bus"},
+		{109, "rail"},
+		{715, "bus"},
+		{1700, "route type 1700"},
+	}
+	for _, tt := range tests {
+		if got := gtfs.RouteMode(tt.routeType); got != tt.want {
+			t.Errorf("RouteMode(%d) = %s, want %s", tt.routeType, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StopOverride model, i.e. the display name and/or the platform label of a
+// stop set by operators (see SetStopOverride), overriding the imported data
+// without modifying it.
+type StopOverride struct {
+	StopID   string `gorm:"primaryKey"`
+	Name     string // the display name (the name of the stop, if empty)
+	Platform string // the platform label (e.g. "2" or "B"), if any
+}
+
+// TableName returns the name of the table holding StopOverride items.
+func (StopOverride) TableName() string {
+	return "stop_overrides"
+}
+
+// SetStopOverride sets (i.e. adds or replaces) the override of a stop and
+// updates the search indexes (if built, see IndexStops and IndexSearch)
+// accordingly. Overrides are applied when querying stops (e.g. SearchStops,
+// RouteStops, RouteSequence, LoadCatalog or Departures), when rendering tiles
+// and when exporting. If there is no such stop, gorm.ErrRecordNotFound is
+// returned.
+func SetStopOverride(db *gorm.DB, o StopOverride) error {
+	var stop Stop
+	if tx := db.First(&stop, "id = ?", o.StopID); tx.Error != nil {
+		return tx.Error
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		if res := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&o); res.Error != nil {
+			return fmt.Errorf("failed to set override of stop '%s': %w", o.StopID, res.Error)
+		}
+		name := stop.Name
+		if o.Name != "" {
+			name = o.Name
+		}
+		return indexStopName(tx, stop.ID, name)
+	})
+}
+
+// DeleteStopOverride removes the override of a stop (see SetStopOverride),
+// restoring its imported name in the search indexes. If there is no such
+// override, gorm.ErrRecordNotFound is returned.
+func DeleteStopOverride(db *gorm.DB, stopID string) error {
+	if !db.Migrator().HasTable(&StopOverride{}) {
+		return gorm.ErrRecordNotFound
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Delete(&StopOverride{}, "stop_id = ?", stopID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		var stop Stop
+		if res = tx.First(&stop, "id = ?", stopID); res.Error != nil {
+			if errors.Is(res.Error, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return res.Error
+		}
+		return indexStopName(tx, stop.ID, stop.Name)
+	})
+}
+
+// StopOverrides returns all overrides of stops (ordered by stop ID).
+func StopOverrides(db *gorm.DB) ([]StopOverride, error) {
+	overrides := []StopOverride{}
+	if !db.Migrator().HasTable(&StopOverride{}) {
+		return overrides, nil
+	}
+	if tx := db.Order("stop_id").Find(&overrides); tx.Error != nil {
+		return nil, tx.Error
+	}
+	return overrides, nil
+}
+
+// CopyStopOverrides copies the overrides of stops from one DB (e.g. the
+// previous version of a feed) to another (e.g. the version replacing it),
+// such that overrides survive re-importing a feed. Overrides of stops missing
+// in the other DB are dropped. CopyStopOverrides returns the number of
+// overrides copied.
+func CopyStopOverrides(from, to *gorm.DB) (int, error) {
+	overrides, err := StopOverrides(from)
+	if err != nil {
+		return 0, err
+	}
+	var copied int
+	for _, o := range overrides {
+		if err = SetStopOverride(to, o); errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return copied, err
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+// ApplyStopOverrides replaces the names of the given stops by the display
+// names set by overrides (see SetStopOverride).
+func ApplyStopOverrides(db *gorm.DB, stops []Stop) error {
+	overrides, err := stopOverrides(db)
+	if err != nil {
+		return err
+	}
+	for i := range stops {
+		stops[i].Name = overrides.name(stops[i])
+	}
+	return nil
+}
+
+// GetStop returns the stop with the given ID (by its display name, see
+// SetStopOverride). If there is no such stop, gorm.ErrRecordNotFound is
+// returned.
+func GetStop(db *gorm.DB, stopID string) (*Stop, error) {
+	var stop Stop
+	tx := db.Limit(1).Find(&stop, "id = ?", stopID)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	stops := []Stop{stop}
+	if err := ApplyStopOverrides(db, stops); err != nil {
+		return nil, err
+	}
+	return &stops[0], nil
+}
+
+// stopOverrideMap maps stop IDs to their overrides.
+type stopOverrideMap map[string]StopOverride
+
+// stopOverrides returns the overrides of all stops (none, if the table
+// doesn't exist).
+func stopOverrides(db *gorm.DB) (stopOverrideMap, error) {
+	overrides, err := StopOverrides(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stop overrides: %w", err)
+	}
+	m := make(stopOverrideMap, len(overrides))
+	for _, o := range overrides {
+		m[o.StopID] = o
+	}
+	return m, nil
+}
+
+// name returns the display name of the given stop.
+func (m stopOverrideMap) name(stop Stop) string {
+	if o, ok := m[stop.ID]; ok && o.Name != "" {
+		return o.Name
+	}
+	return stop.Name
+}
+
+// indexStopName updates the entries of a stop within the search indexes (if
+// built) to the given name.
+func indexStopName(db *gorm.DB, stopID, name string) error {
+	if db.Migrator().HasTable("stop_search") {
+		if err := db.Exec("UPDATE stop_search SET name = ? WHERE stop_id = ?", NormalizeName(name), stopID).Error; err != nil {
+			return fmt.Errorf("failed to update stop search index: %w", err)
+		}
+	}
+	if db.Migrator().HasTable("search") {
+		if err := db.Exec("UPDATE search SET label = ?, name = ? WHERE type = ? AND item_id = ?", name, NormalizeName(name), StopHit, stopID).Error; err != nil {
+			return fmt.Errorf("failed to update search index: %w", err)
+		}
+	}
+	return nil
+}
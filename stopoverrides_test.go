@@ -0,0 +1,107 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSetStopOverride(t *testing.T) {
+	db := newFixtureDB(t)
+	if err := gtfs.SetStopOverride(db, gtfs.StopOverride{StopID: "X1", Name: "Nowhere"}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("SetStopOverride() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if err := gtfs.SetStopOverride(db, gtfs.StopOverride{StopID: "S1", Name: "Berlin-Wannsee", Platform: "3"}); err != nil {
+		t.Fatalf("SetStopOverride() error = %v", err)
+	}
+
+	// the imported data is kept
+	var stop gtfs.Stop
+	if tx := db.First(&stop, "id = ?", "S1"); tx.Error != nil || stop.Name != "S Wannsee" {
+		t.Errorf("stop S1 = %v (%v), want imported name", stop, tx.Error)
+	}
+
+	// search
+	stops, err := gtfs.SearchStops(db, "berlin wannsee", 0)
+	if err != nil {
+		t.Fatalf("SearchStops() error = %v", err)
+	}
+	if len(stops) != 1 || stops[0].ID != "S1" || stops[0].Name != "Berlin-Wannsee" {
+		t.Errorf("SearchStops() = %v, want S1 named Berlin-Wannsee", stops)
+	}
+
+	// catalog
+	catalog, err := gtfs.LoadCatalog(db)
+	if err != nil {
+		t.Fatalf("LoadCatalog() error = %v", err)
+	}
+	if stop, _ = catalog.Stop("S1"); stop.Name != "Berlin-Wannsee" {
+		t.Errorf("Catalog.Stop() = %v, want Berlin-Wannsee", stop)
+	}
+
+	// departures
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("failed to load location: %v", err)
+	}
+	departures, err := gtfs.Departures(db, "S1", time.Date(2022, 3, 1, 7, 55, 0, 0, berlin), berlin, gtfs.DeparturesOptions{})
+	if err != nil {
+		t.Fatalf("Departures() error = %v", err)
+	}
+	if len(departures) == 0 || departures[0].Platform != "3" {
+		t.Errorf("Departures() = %v, want platform 3", departures)
+	}
+
+	// export
+	dir := t.TempDir()
+	if err = gtfs.Export(db, dir, gtfs.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	b, err := os.ReadFile(path.Join(dir, "stops.txt"))
+	if err != nil {
+		t.Fatalf("failed to read export: %v", err)
+	}
+	if !strings.Contains(string(b), "Berlin-Wannsee") || strings.Contains(string(b), "S Wannsee,") {
+		t.Errorf("exported stops = %s, want override applied", b)
+	}
+
+	// delete
+	if err = gtfs.DeleteStopOverride(db, "S1"); err != nil {
+		t.Fatalf("DeleteStopOverride() error = %v", err)
+	}
+	if err = gtfs.DeleteStopOverride(db, "S1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("DeleteStopOverride() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+	if stops, err = gtfs.SearchStops(db, "berlin wannsee", 0); err != nil || len(stops) != 0 {
+		t.Errorf("SearchStops() = %v (%v), want none", stops, err)
+	}
+}
+
+func TestCopyStopOverrides(t *testing.T) {
+	from := newFixtureDB(t)
+	for _, o := range []gtfs.StopOverride{{StopID: "S1", Platform: "3"}, {StopID: "S4", Name: "Steglitz"}} {
+		if err := gtfs.SetStopOverride(from, o); err != nil {
+			t.Fatalf("SetStopOverride() error = %v", err)
+		}
+	}
+
+	// S4 is missing in the other DB
+	to := newFixtureDB(t)
+	to.Delete(&gtfs.Stop{}, "id = ?", "S4")
+	copied, err := gtfs.CopyStopOverrides(from, to)
+	if err != nil {
+		t.Fatalf("CopyStopOverrides() error = %v", err)
+	}
+	overrides, err := gtfs.StopOverrides(to)
+	if err != nil {
+		t.Fatalf("StopOverrides() error = %v", err)
+	}
+	if copied != 1 || len(overrides) != 1 || overrides[0] != (gtfs.StopOverride{StopID: "S1", Platform: "3"}) {
+		t.Errorf("CopyStopOverrides() = %d, %v, want S1 only", copied, overrides)
+	}
+}
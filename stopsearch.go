@@ -0,0 +1,183 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+	"unicode"
+)
+
+// ErrNoStopSearch is returned when searching for stops without an index (see
+// IndexStops).
+var ErrNoStopSearch = errors.New("no stop search index")
+
+// StopSearch model (the normalized name of a stop, see NormalizeName).
+type StopSearch struct {
+	StopID string `gorm:"primaryKey"`
+	Name   string
+}
+
+// TableName returns the name of the table holding StopSearch items.
+func (StopSearch) TableName() string {
+	return "stop_search"
+}
+
+// unaccent replaces (lowercase) accented characters by their base characters.
+var unaccent = strings.NewReplacer(
+	"ß", "ss",
+	"ä", "a", "à", "a", "á", "a", "â", "a", "ã", "a", "å", "a",
+	"ç", "c",
+	"è", "e", "é", "e", "ê", "e", "ë", "e",
+	"ì", "i", "í", "i", "î", "i", "ï", "i",
+	"ñ", "n",
+	"ö", "o", "ò", "o", "ó", "o", "ô", "o", "õ", "o", "ø", "o",
+	"ü", "u", "ù", "u", "ú", "u", "û", "u",
+	"ý", "y", "ÿ", "y",
+)
+
+// abbreviations maps (unaccented) abbreviations to their expansions.
+var abbreviations = map[string]string{
+	"str": "strasse",
+	"pl":  "platz",
+	"bhf": "bahnhof",
+	"hbf": "hauptbahnhof",
+}
+
+// NormalizeName normalizes a (stop) name for searching, i.e. lowercases it,
+// removes accents (e.g. "Straße" becomes "strasse"), expands common
+// abbreviations (e.g. "Hauptstr." becomes "hauptstrasse") and separates words
+// by single spaces.
+func NormalizeName(name string) string {
+	name = unaccent.Replace(strings.ToLower(name))
+	words := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.'
+	})
+	normalized := make([]string, 0, len(words))
+	for _, w := range words {
+		abbreviated := strings.HasSuffix(w, ".")
+		w = strings.Trim(w, ".")
+		if w == "" {
+			continue
+		}
+		if expanded, ok := abbreviations[w]; ok {
+			w = expanded
+		} else if strings.HasSuffix(w, "str") {
+			w += "asse"
+		} else if abbreviated && strings.HasSuffix(w, "pl") {
+			w += "atz"
+		}
+		normalized = append(normalized, w)
+	}
+	return strings.Join(normalized, " ")
+}
+
+// IndexStops (re-)builds the stop search index (i.e. the stop_search table)
+// from all stops in the DB (by their display names, see SetStopOverride). If
+// fts5 is true, the index is an SQLite FTS5 virtual table (which requires
+// building with the sqlite_fts5 tag).
+func IndexStops(db *gorm.DB, fts5 bool) error {
+	var stops []Stop
+	if err := db.Find(&stops).Error; err != nil {
+		return err
+	}
+	if err := ApplyStopOverrides(db, stops); err != nil {
+		return err
+	}
+	items := make([]StopSearch, len(stops))
+	for i, s := range stops {
+		items[i] = StopSearch{StopID: s.ID, Name: NormalizeName(s.Name)}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := resetIndex(tx, &StopSearch{}, fts5, "stop_id UNINDEXED, name"); err != nil {
+			return fmt.Errorf("failed to create stop search index: %w", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(items, batchSize).Error
+	})
+}
+
+// SearchStops returns (at most limit, all if limit is not positive) stops
+// whose normalized names contain all words of the (normalized) query. The stop
+// search index must have been built before (see IndexStops), otherwise (i.e.
+// if the index is empty) ErrNoStopSearch is returned.
+func SearchStops(db *gorm.DB, query string, limit int) ([]Stop, error) {
+	if !hasRows(db, "stop_search") {
+		return nil, ErrNoStopSearch
+	}
+	words := strings.Fields(NormalizeName(query))
+	if len(words) == 0 {
+		return nil, nil
+	}
+
+	tx := db.Model(&Stop{}).Joins("JOIN stop_search ON stop_search.stop_id = stops.id")
+	if isFTS5(db, "stop_search") {
+		tx = tx.Where("stop_search MATCH ?", ftsPrefixQuery(words)).Order("rank")
+	} else {
+		for _, w := range words {
+			tx = tx.Where("stop_search.name LIKE ?", "%"+w+"%")
+		}
+	}
+	if limit > 0 {
+		tx = tx.Limit(limit)
+	}
+	var stops []Stop
+	if err := tx.Order("stops.name").Find(&stops).Error; err != nil {
+		return nil, err
+	}
+	if err := ApplyStopOverrides(db, stops); err != nil {
+		return nil, err
+	}
+	return stops, nil
+}
+
+// isFTS5 returns true, if the given table is an SQLite FTS5 virtual table.
+func isFTS5(db *gorm.DB, table string) bool {
+	if !isSQLite(db) {
+		return false
+	}
+	var count int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE name = ? AND sql LIKE '%USING fts5%'", table).Scan(&count)
+	return count > 0
+}
+
+// hasRows returns true, if the given table exists and holds at least one row.
+func hasRows(db *gorm.DB, table string) bool {
+	if !db.Migrator().HasTable(table) {
+		return false
+	}
+	var exists bool
+	return db.Raw(fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM %s)", table)).Scan(&exists).Error == nil && exists
+}
+
+// resetIndex empties the table of a search index (the model, created as
+// SQLite FTS5 virtual table with the given columns, if fts5 is true). Tables
+// are only (re-)created if missing or of the other kind, as DDL statements
+// implicitly commit open transactions on MySQL (e.g. that of importing stops,
+// whose tables Migrate creates).
+func resetIndex(tx *gorm.DB, model interface{ TableName() string }, fts5 bool, columns string) error {
+	table := model.TableName()
+	if tx.Migrator().HasTable(table) && isFTS5(tx, table) == fts5 {
+		return tx.Exec(fmt.Sprintf("DELETE FROM %s", table)).Error
+	}
+	if err := tx.Exec(fmt.Sprintf("DROP TABLE IF EXISTS %s", table)).Error; err != nil {
+		return err
+	}
+	if fts5 {
+		return tx.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s)", table, columns)).Error
+	}
+	return tx.Migrator().CreateTable(model)
+}
+
+// ftsPrefixQuery returns an FTS5 query matching rows containing all words
+// (as prefixes).
+func ftsPrefixQuery(words []string) string {
+	terms := make([]string, len(words))
+	for i, w := range words {
+		terms[i] = fmt.Sprintf(`"%s"*`, strings.ReplaceAll(w, `"`, `""`))
+	}
+	return strings.Join(terms, " ")
+}
@@ -0,0 +1,83 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"S Wannsee", "s wannsee"},
+		{"Am Großen Wannsee", "am grossen wannsee"},
+		{"Hauptstr. 5", "hauptstrasse 5"},
+		{"Hauptstraße 5", "hauptstrasse 5"},
+		{"Haupt Str.", "haupt strasse"},
+		{"Hermannpl.", "hermannplatz"},
+		{"S+U Berlin Hbf (tief)", "s u berlin hauptbahnhof tief"},
+		{"Müggelsee  Bhf", "muggelsee bahnhof"},
+		{"Café Zoé", "cafe zoe"},
+	}
+	for _, tt := range tests {
+		if got := gtfs.NormalizeName(tt.name); got != tt.want {
+			t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchStops(t *testing.T) {
+	if _, err := gtfs.SearchStops(newTestDB(t), "wannsee", 0); !errors.Is(err, gtfs.ErrNoStopSearch) {
+		t.Errorf("SearchStops() error = %v, want %v", err, gtfs.ErrNoStopSearch)
+	}
+
+	// importing builds the index
+	db := newFixtureDB(t)
+	testSearchStops(t, db)
+
+	// FTS5 requires building with the sqlite_fts5 tag
+	if err := gtfs.IndexStops(db, true); err != nil {
+		if strings.Contains(err.Error(), "no such module") {
+			t.Skip("FTS5 not available")
+		}
+		t.Fatalf("IndexStops() error = %v", err)
+	}
+	testSearchStops(t, db)
+
+	// migrating leaves the FTS5 index as it is
+	if err := gtfs.Migrate(db); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	testSearchStops(t, db)
+}
+
+func testSearchStops(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	tests := []struct {
+		query string
+		limit int
+		want  int
+	}{
+		{"wannsee", 0, 4},
+		{"wannsee", 2, 2},
+		{"Wannsee Bahnhof", 0, 1},
+		{"großen", 0, 1},
+		{"grossen", 0, 1},
+		{"steglitz rathaus", 0, 1},
+		{"", 0, 0},
+		{"alexanderplatz", 0, 0},
+	}
+	for _, tt := range tests {
+		stops, err := gtfs.SearchStops(db, tt.query, tt.limit)
+		if err != nil {
+			t.Fatalf("SearchStops(%q) error = %v", tt.query, err)
+		}
+		if len(stops) != tt.want {
+			t.Errorf("SearchStops(%q) = %v, want %d stops", tt.query, stops, tt.want)
+		}
+	}
+}
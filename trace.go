@@ -0,0 +1,122 @@
+package gtfs
+
+import (
+	"gorm.io/gorm"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// traceStartKey is the key used to store the start time of a statement.
+const traceStartKey = "gtfs:trace_start"
+
+// QueryStat is the type used to describe the recorded durations of a single
+// SQL statement.
+type QueryStat struct {
+	SQL   string
+	Count int64
+	Total time.Duration
+	Max   time.Duration
+}
+
+// SlowQueryLogger is a gorm plugin recording the durations of all statements
+// and logging statements (along with their parameters) that take longer than
+// Threshold. Use it via db.Use(&SlowQueryLogger{Threshold: time.Second}).
+type SlowQueryLogger struct {
+
+	// Threshold is the duration above which statements are logged.
+	Threshold time.Duration
+
+	// Logger is the logger to log slow statements to (defaults to log.Default()).
+	Logger *log.Logger
+
+	mu    sync.Mutex
+	stats map[string]*QueryStat
+}
+
+// Name returns the name of the plugin.
+func (l *SlowQueryLogger) Name() string {
+	return "gtfs:slow_query_logger"
+}
+
+// Initialize registers the callbacks of the plugin with db.
+func (l *SlowQueryLogger) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("gtfs:trace_before_create", l.before),
+		cb.Create().After("gorm:create").Register("gtfs:trace_after_create", l.after),
+		cb.Query().Before("gorm:query").Register("gtfs:trace_before_query", l.before),
+		cb.Query().After("gorm:query").Register("gtfs:trace_after_query", l.after),
+		cb.Update().Before("gorm:update").Register("gtfs:trace_before_update", l.before),
+		cb.Update().After("gorm:update").Register("gtfs:trace_after_update", l.after),
+		cb.Delete().Before("gorm:delete").Register("gtfs:trace_before_delete", l.before),
+		cb.Delete().After("gorm:delete").Register("gtfs:trace_after_delete", l.after),
+		cb.Row().Before("gorm:row").Register("gtfs:trace_before_row", l.before),
+		cb.Row().After("gorm:row").Register("gtfs:trace_after_row", l.after),
+		cb.Raw().Before("gorm:raw").Register("gtfs:trace_before_raw", l.before),
+		cb.Raw().After("gorm:raw").Register("gtfs:trace_after_raw", l.after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Stats returns the recorded durations, sorted by total duration (descending).
+func (l *SlowQueryLogger) Stats() []QueryStat {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	stats := make([]QueryStat, 0, len(l.stats))
+	for _, s := range l.stats {
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Total > stats[j].Total
+	})
+	return stats
+}
+
+// before records the start time of a statement.
+func (l *SlowQueryLogger) before(db *gorm.DB) {
+	db.InstanceSet(traceStartKey, time.Now())
+}
+
+// after records the duration of a statement and logs it, if it was slow.
+func (l *SlowQueryLogger) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(traceStartKey)
+	if !ok {
+		return
+	}
+	start, ok := v.(time.Time)
+	if !ok {
+		return
+	}
+	elapsed := time.Since(start)
+	sql := db.Statement.SQL.String()
+
+	l.mu.Lock()
+	if l.stats == nil {
+		l.stats = map[string]*QueryStat{}
+	}
+	s, ok := l.stats[sql]
+	if !ok {
+		s = &QueryStat{SQL: sql}
+		l.stats[sql] = s
+	}
+	s.Count++
+	s.Total += elapsed
+	if elapsed > s.Max {
+		s.Max = elapsed
+	}
+	l.mu.Unlock()
+
+	if elapsed > l.Threshold {
+		logger := l.Logger
+		if logger == nil {
+			logger = log.Default()
+		}
+		logger.Printf("slow query (%s): %s", elapsed, db.Dialector.Explain(sql, db.Statement.Vars...))
+	}
+}
@@ -0,0 +1,44 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"github.com/heimdalr/gtfs"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestSlowQueryLogger(t *testing.T) {
+	db := newTestDB(t)
+
+	var buf bytes.Buffer
+	l := &gtfs.SlowQueryLogger{Logger: log.New(&buf, "", 0)}
+	if err := db.Use(l); err != nil {
+		t.Fatalf("Use() error = %v", err)
+	}
+
+	db.Create(&gtfs.Agency{ID: "1", Name: "S-Bahn Berlin GmbH"})
+	for i := 0; i < 2; i++ {
+		var agency gtfs.Agency
+		db.First(&agency, "id = ?", "1")
+	}
+
+	// with a zero threshold, every statement is slow
+	if !strings.Contains(buf.String(), `"1"`) {
+		t.Errorf("SlowQueryLogger logged %q, want statements with parameters", buf.String())
+	}
+
+	stats := l.Stats()
+	var found bool
+	for _, s := range stats {
+		if strings.HasPrefix(s.SQL, "SELECT") {
+			found = true
+			if s.Count != 2 {
+				t.Errorf("Stats() count = %d, want 2", s.Count)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Stats() = %v, want a SELECT statement", stats)
+	}
+}
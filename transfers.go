@@ -0,0 +1,85 @@
+package gtfs
+
+import (
+	"errors"
+	"gorm.io/gorm"
+)
+
+// ErrNotConnected is returned if two stops are not connected via any routes.
+var ErrNotConnected = errors.New("stops not connected")
+
+// statement to select the stops served per route
+const routeStopsStmt = `
+SELECT DISTINCT
+	trips.route_id,
+	stop_times.stop_id
+FROM
+	stop_times
+	JOIN trips ON trips.id = stop_times.trip_id;
+`
+
+// MinTransfers returns the minimum number of transfers needed to get from one
+// stop to another. It searches (breadth first) the graph of routes sharing
+// stops, i.e. it neither considers times nor directions nor walking between
+// stops. It is thus a quick connectivity metric rather than a journey
+// planner. If the stops are not connected, ErrNotConnected is returned.
+func MinTransfers(db *gorm.DB, fromStop, toStop string) (int, error) {
+	if fromStop == toStop {
+		return 0, nil
+	}
+
+	rows, err := db.Raw(routeStopsStmt).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	// build the route-stop graph
+	routeStops := map[string][]string{}
+	stopRoutes := map[string][]string{}
+	for rows.Next() {
+		var routeID, stopID string
+		if err = rows.Scan(&routeID, &stopID); err != nil {
+			return 0, err
+		}
+		routeStops[routeID] = append(routeStops[routeID], stopID)
+		stopRoutes[stopID] = append(stopRoutes[stopID], routeID)
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// the routes serving the destination
+	destination := map[string]bool{}
+	for _, routeID := range stopRoutes[toStop] {
+		destination[routeID] = true
+	}
+
+	// search level by level, starting with the routes serving the origin
+	visited := map[string]bool{}
+	level := stopRoutes[fromStop]
+	for _, routeID := range level {
+		visited[routeID] = true
+	}
+	for transfers := 0; len(level) > 0; transfers++ {
+		var next []string
+		for _, routeID := range level {
+			if destination[routeID] {
+				return transfers, nil
+			}
+			for _, stopID := range routeStops[routeID] {
+				for _, r := range stopRoutes[stopID] {
+					if !visited[r] {
+						visited[r] = true
+						next = append(next, r)
+					}
+				}
+			}
+		}
+		level = next
+	}
+
+	return 0, ErrNotConnected
+}
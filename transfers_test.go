@@ -0,0 +1,37 @@
+package gtfs_test
+
+import (
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestMinTransfers(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// let T4 (R2) start at S4 (R1)
+	db.Create(&gtfs.StopTime{TripID: "T4", StopID: "S4", StopSeq: 0})
+
+	tests := []struct {
+		name     string
+		from, to string
+		want     int
+		wantErr  error
+	}{
+		{name: "same stop", from: "S1", to: "S1", want: 0},
+		{name: "same route", from: "S1", to: "S4", want: 0},
+		{name: "one transfer", from: "S1", to: "B3", want: 1},
+		{name: "unknown stop", from: "S1", to: "X", wantErr: gtfs.ErrNotConnected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := gtfs.MinTransfers(db, tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("MinTransfers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("MinTransfers() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package gtfs
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// translationsFile is the name of the (optional) GTFS file holding
+// translations.
+const translationsFile = "translations.txt"
+
+// Translation model (the translation of a field of a record, or of all fields
+// holding a value, into a language).
+type Translation struct {
+	ID          uint   `gorm:"primaryKey,autoIncrement" csv:"-"`
+	Table       string `gorm:"column:table_name" csv:"table_name"`
+	FieldName   string `csv:"field_name"`
+	Language    string `csv:"language"`
+	Text        string `gorm:"column:translation" csv:"translation"`
+	RecordID    string `csv:"record_id"`
+	RecordSubID string `csv:"record_sub_id"`
+	FieldValue  string `csv:"field_value"`
+}
+
+// TableName returns the name of the table holding Translation items.
+func (Translation) TableName() string {
+	return "translations"
+}
+
+// ImportTranslations imports the translations.txt file (if present) from the
+// directory (or zip archive, see ImportZip) gtfsBase (replacing any previously
+// imported translations) and returns the number of translations imported.
+func ImportTranslations(db *gorm.DB, gtfsBase string) (int64, error) {
+	fsys, dir, closeFeed, err := openFeed(gtfsBase)
+	if err != nil {
+		return 0, err
+	}
+	defer closeFeed()
+	name := path.Join(dir, translationsFile)
+	if _, err = fs.Stat(fsys, name); errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	var count int64
+	err = db.Transaction(func(tx *gorm.DB) (err error) {
+		count, err = replaceItems[Translation](tx, fsys, name)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to import translations: %w", err)
+	}
+	return count, nil
+}
+
+// translationKey identifies the translation of a field (of a record, or of
+// all records holding a value) into a language.
+type translationKey struct {
+	table, field, language, id string
+}
+
+// Translations holds the translations of a feed (see LoadTranslations). A nil
+// *Translations translates nothing.
+type Translations struct {
+	byRecord  map[translationKey]string
+	byValue   map[translationKey]string
+	languages []string
+}
+
+// LoadTranslations loads the translations imported into db (see
+// ImportTranslations). If none were imported, the returned Translations
+// translate nothing.
+func LoadTranslations(db *gorm.DB) (*Translations, error) {
+	t := &Translations{byRecord: map[translationKey]string{}, byValue: map[translationKey]string{}}
+	if !db.Migrator().HasTable(&Translation{}) {
+		return t, nil
+	}
+	var translations []Translation
+	if tx := db.Order("id").Find(&translations); tx.Error != nil {
+		return nil, tx.Error
+	}
+	languages := map[string]bool{}
+	for _, tr := range translations {
+		switch {
+		case tr.RecordID != "":
+			t.byRecord[translationKey{tr.Table, tr.FieldName, tr.Language, tr.RecordID}] = tr.Text
+		case tr.FieldValue != "":
+			t.byValue[translationKey{tr.Table, tr.FieldName, tr.Language, tr.FieldValue}] = tr.Text
+		default:
+			continue
+		}
+		if !languages[tr.Language] {
+			languages[tr.Language] = true
+			t.languages = append(t.languages, tr.Language)
+		}
+	}
+	sort.Strings(t.languages)
+	return t, nil
+}
+
+// Languages returns the languages (e.g. "en" or "de-CH") translations are
+// given for (ordered).
+func (t *Translations) Languages() []string {
+	if t == nil {
+		return nil
+	}
+	return t.languages
+}
+
+// Translate returns the translation into language of the field (e.g.
+// "stop_name") of the record with the given ID within the given table (i.e.
+// the name of the GTFS file without extension, e.g. "stops"), falling back to
+// the translation of the value of the field and to value itself.
+func (t *Translations) Translate(table, field, recordID, value, language string) string {
+	if t == nil || language == "" {
+		return value
+	}
+	if s, ok := t.byRecord[translationKey{table, field, language, recordID}]; ok {
+		return s
+	}
+	if s, ok := t.byValue[translationKey{table, field, language, value}]; ok {
+		return s
+	}
+	return value
+}
+
+// Negotiate returns the language (see Languages) best matching the given
+// Accept-Language header (e.g. "de-CH, de;q=0.9, en;q=0.8"), preferring exact
+// matches over matches of the primary language (e.g. "de" for "de-CH" or vice
+// versa). If no language matches (or the wildcard "*" is preferred), ""
+// (i.e. the default language of the feed) is returned.
+func (t *Translations) Negotiate(acceptLanguage string) string {
+	if len(t.Languages()) == 0 || acceptLanguage == "" {
+		return ""
+	}
+
+	// order the accepted languages by quality
+	type accepted struct {
+		tag string
+		q   float64
+	}
+	var tags []accepted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		params := strings.Split(part, ";")
+		a := accepted{tag: strings.TrimSpace(params[0]), q: 1}
+		for _, p := range params[1:] {
+			if p = strings.TrimSpace(p); strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(p, "q="), 64); err == nil {
+					a.q = q
+				}
+			}
+		}
+		if a.tag != "" && a.q > 0 {
+			tags = append(tags, a)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	primary := func(tag string) string {
+		return strings.ToLower(strings.SplitN(tag, "-", 2)[0])
+	}
+	for _, a := range tags {
+		if a.tag == "*" {
+			return ""
+		}
+		for _, l := range t.languages {
+			if strings.EqualFold(l, a.tag) {
+				return l
+			}
+		}
+		for _, l := range t.languages {
+			if primary(l) == primary(a.tag) {
+				return l
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,112 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+const translationsCSV = `table_name,field_name,language,translation,record_id,record_sub_id,field_value
+stops,stop_name,en,Wannsee Station,S1,,
+stops,stop_name,fr,Gare de Wannsee,S1,,
+stops,stop_name,en,Wannsee Lido,,,Strandbad Wannsee
+routes,route_long_name,en-GB,Wannsee - Steglitz Town Hall,R1,,
+`
+
+func TestImportTranslations(t *testing.T) {
+	feed := writeFeed(t, nil)
+	db := newTestDB(t)
+	gtfs.ImportWithOptions(db, feed, gtfs.ImportOptions{})
+
+	// no translations
+	if n, err := gtfs.ImportTranslations(db, feed); err != nil || n != 0 {
+		t.Errorf("ImportTranslations() = %d, %v, want 0", n, err)
+	}
+	tr, err := gtfs.LoadTranslations(db)
+	if err != nil {
+		t.Fatalf("LoadTranslations() error = %v", err)
+	}
+	if got := tr.Translate("stops", "stop_name", "S1", "S Wannsee", "en"); got != "S Wannsee" {
+		t.Errorf("Translate() = %q, want %q", got, "S Wannsee")
+	}
+
+	// importing twice replaces translations
+	if err = os.WriteFile(path.Join(feed, "translations.txt"), []byte(translationsCSV), 0o644); err != nil {
+		t.Fatalf("failed to write translations: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if n, err := gtfs.ImportTranslations(db, feed); err != nil || n != 4 {
+			t.Fatalf("ImportTranslations() = %d, %v, want 4", n, err)
+		}
+	}
+	if tr, err = gtfs.LoadTranslations(db); err != nil {
+		t.Fatalf("LoadTranslations() error = %v", err)
+	}
+	if got, want := tr.Languages(), []string{"en", "en-GB", "fr"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Languages() = %v, want %v", got, want)
+	}
+	tests := []struct {
+		table, field, id, value, lang string
+		want                          string
+	}{
+		{"stops", "stop_name", "S1", "S Wannsee", "fr", "Gare de Wannsee"},
+		{"stops", "stop_name", "B3", "Strandbad Wannsee", "en", "Wannsee Lido"},
+		{"stops", "stop_name", "S2", "S Nikolassee", "en", "S Nikolassee"},
+		{"stops", "stop_name", "S1", "S Wannsee", "", "S Wannsee"},
+		{"routes", "route_long_name", "R1", "S Wannsee - S Rathaus Steglitz", "en", "S Wannsee - S Rathaus Steglitz"},
+	}
+	for _, tt := range tests {
+		if got := tr.Translate(tt.table, tt.field, tt.id, tt.value, tt.lang); got != tt.want {
+			t.Errorf("Translate(%s, %s, %s, %s) = %q, want %q", tt.table, tt.field, tt.id, tt.lang, got, tt.want)
+		}
+	}
+
+	// translations are exported
+	dir := t.TempDir()
+	if err = gtfs.Export(db, dir, gtfs.ExportOptions{}); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if b, err := os.ReadFile(path.Join(dir, "translations.txt")); err != nil || string(b) != translationsCSV {
+		t.Errorf("exported translations = %s (%v), want %s", b, err, translationsCSV)
+	}
+}
+
+func TestTranslations_Negotiate(t *testing.T) {
+	db := newTestDB(t)
+	db.AutoMigrate(&gtfs.Translation{})
+	for _, lang := range []string{"en", "en-GB", "fr-CA"} {
+		db.Create(&gtfs.Translation{Table: "stops", FieldName: "stop_name", Language: lang, Text: "x", RecordID: "S1"})
+	}
+	tr, err := gtfs.LoadTranslations(db)
+	if err != nil {
+		t.Fatalf("LoadTranslations() error = %v", err)
+	}
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"en-GB", "en-GB"},
+		{"en-gb", "en-GB"},
+		{"en-US", "en"},
+		{"fr", "fr-CA"},
+		{"de-DE, de;q=0.9, en;q=0.8", "en"},
+		{"de, *;q=0.5, en;q=0.1", ""},
+		{"en;q=0, fr;q=0.5", "fr-CA"},
+		{"de", ""},
+	}
+	for _, tt := range tests {
+		if got := tr.Negotiate(tt.accept); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+
+	// nil translations negotiate nothing
+	var none *gtfs.Translations
+	if got := none.Negotiate("en"); got != "" {
+		t.Errorf("Negotiate() = %q, want none", got)
+	}
+}
@@ -0,0 +1,654 @@
+package gtfs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+const (
+
+	// statement to remove all agencies not like a given name
+	delAgencyStmt = `
+DELETE
+FROM
+	agencies
+WHERE
+	id <> ?;
+`
+
+	// statement to remove all routes not belonging to any of the known agencies
+	delRoutesStmt = `
+DELETE
+FROM
+	routes
+WHERE agency_id NOT IN (
+	SELECT DISTINCT id
+	FROM
+		agencies);
+`
+
+	// statement to remove all trips not belonging to any of the known routes
+	delTripsStmt = `
+DELETE
+FROM
+	trips
+WHERE route_id NOT IN (
+	SELECT DISTINCT id
+	FROM
+		routes);
+`
+
+	// statement to remove all stops times not belonging to any known trip
+	delStopTimesStmt = `
+DELETE
+FROM
+	stop_times
+WHERE trip_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		trips);
+`
+
+	// statement to remove all frequencies not belonging to any known trip
+	delFrequenciesStmt = `
+DELETE
+FROM
+	frequencies
+WHERE trip_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		trips);
+`
+
+	// condition matching stops that neither have a stop time associated nor are
+	// part of a station having stops with stop times (i.e. the station, its
+	// entrances and generic nodes or boarding areas of platforms with stop
+	// times), the subquery selecting the stations is wrapped in a derived
+	// table (as MySQL refuses to select from the table deleted from otherwise)
+	unservedStopsCond = `
+	id NOT IN (
+	SELECT DISTINCT
+		stop_id
+	FROM
+		stop_times)
+	AND id NOT IN (
+	SELECT
+		parent_station
+	FROM
+		(SELECT DISTINCT
+			stops.parent_station
+		FROM
+			stop_times
+			JOIN stops ON stops.id = stop_times.stop_id
+		WHERE
+			stops.parent_station <> '') AS served_stations)
+	AND (COALESCE(location_type, 0) = 0 OR
+	COALESCE(parent_station, '') NOT IN (
+	SELECT DISTINCT
+		stop_id
+	FROM
+		stop_times)
+	AND COALESCE(parent_station, '') NOT IN (
+	SELECT
+		parent_station
+	FROM
+		(SELECT DISTINCT
+			stops.parent_station
+		FROM
+			stop_times
+			JOIN stops ON stops.id = stop_times.stop_id
+		WHERE
+			stops.parent_station <> '') AS served_stations))`
+
+	// statement to remove stops that don't have a stop time associated (see
+	// unservedStopsCond)
+	delStopsStmt = `
+DELETE
+FROM
+	stops
+WHERE` + unservedStopsCond + `;
+`
+
+	// statement to remove stops that don't have a stop time associated (see
+	// unservedStopsCond), except for the given ones
+	delStopsKeepingStmt = `
+DELETE
+FROM
+	stops
+WHERE` + unservedStopsCond + `
+	AND id NOT IN ?;
+`
+
+	// statement to remove all shapes that don't belong to any relevant trip
+	delShapesStmt = `
+DELETE
+FROM
+	shapes
+WHERE
+	shape_id NOT IN (
+	SELECT DISTINCT
+		shape_id
+	FROM
+		trips);
+`
+
+	// statement to remove all calendars not referenced by any known trip
+	delCalendarsStmt = `
+DELETE
+FROM
+	calendars
+WHERE
+	service_id NOT IN (
+	SELECT DISTINCT
+		service_id
+	FROM
+		trips);
+`
+
+	// statement to remove all calendar dates not referenced by any known trip
+	delCalendarDatesStmt = `
+DELETE
+FROM
+	calendar_dates
+WHERE
+	service_id NOT IN (
+	SELECT DISTINCT
+		service_id
+	FROM
+		trips);
+`
+
+	// statement to remove all derived directions of routes that were removed
+	delRouteDirectionsStmt = `
+DELETE
+FROM
+	route_directions
+WHERE
+	route_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		routes);
+`
+
+	// statement to remove all ridership counts of stops that were removed
+	delStopRidershipStmt = `
+DELETE
+FROM
+	stop_ridership
+WHERE
+	stop_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops);
+`
+
+	// statement to remove the overrides of stops that were removed
+	delStopOverridesStmt = `
+DELETE
+FROM
+	stop_overrides
+WHERE
+	stop_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops);
+`
+
+	// statement to remove the amenities of stops that were removed
+	delStopAmenitiesStmt = `
+DELETE
+FROM
+	stop_amenities
+WHERE
+	stop_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops);
+`
+
+	// statement to remove the translations of records that were removed
+	// (translations by field value are kept)
+	delTranslationsStmt = `
+DELETE
+FROM
+	translations
+WHERE
+	(table_name = 'agency' AND record_id <> '' AND record_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		agencies))
+	OR (table_name = 'routes' AND record_id <> '' AND record_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		routes))
+	OR (table_name = 'trips' AND record_id <> '' AND record_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		trips))
+	OR (table_name = 'stops' AND record_id <> '' AND record_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops));
+`
+
+	// statement to remove the fare leg rules referencing fare products that
+	// don't exist (fares aren't tied to agencies, thus trimming keeps them)
+	delFareLegRulesStmt = `
+DELETE
+FROM
+	fare_leg_rules
+WHERE
+	fare_product_id NOT IN (
+	SELECT DISTINCT
+		fare_product_id
+	FROM
+		fare_products);
+`
+
+	// statement to remove the fare transfer rules referencing fare products
+	// that don't exist
+	delFareTransferRulesStmt = `
+DELETE
+FROM
+	fare_transfer_rules
+WHERE
+	fare_product_id <> '' AND fare_product_id NOT IN (
+	SELECT DISTINCT
+		fare_product_id
+	FROM
+		fare_products);
+`
+
+	// statement to remove the frequency buckets of routes that were removed
+	delRouteFrequencyBucketsStmt = `
+DELETE
+FROM
+	route_frequency_buckets
+WHERE
+	route_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		routes);
+`
+
+	// statement to remove the frequency buckets of stops that were removed
+	delStopFrequencyBucketsStmt = `
+DELETE
+FROM
+	stop_frequency_buckets
+WHERE
+	stop_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops);
+`
+
+	// statement to remove the capacities of vehicles serving routes or trips
+	// that were removed
+	delVehicleCapacitiesStmt = `
+DELETE
+FROM
+	vehicle_capacities
+WHERE
+	route_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		routes)
+	OR (trip_id <> '' AND trip_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		trips));
+`
+
+	// statement to remove the search index entries of stops that were removed
+	delStopSearchStmt = `
+DELETE
+FROM
+	stop_search
+WHERE
+	stop_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops);
+`
+
+	// statement to remove the search index entries of stops and routes that were removed
+	delSearchStmt = `
+DELETE
+FROM
+	search
+WHERE
+	(type = 0 AND item_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		stops))
+	OR (type <> 0 AND item_id NOT IN (
+	SELECT DISTINCT
+		id
+	FROM
+		routes));
+`
+)
+
+// TrimItemsResult is the type used to describe the result of trimming a single item type.
+type TrimItemsResult struct {
+	ItemType  ItemType
+	Affected  int64
+	Remaining int64
+	Time      time.Duration
+}
+
+// String returns a human-readable representation of TrimItemsResult.
+func (tir TrimItemsResult) String() string {
+	return fmt.Sprintf("trimed %d %s to %d in %s", tir.Affected, tir.ItemType, tir.Remaining, tir.Time)
+}
+
+// TrimResult is the type used to describe the result of trimming all item types.
+type TrimResult map[ItemType]*TrimItemsResult
+
+// TrimOptions configures Trim.
+type TrimOptions struct {
+
+	// KeepStops are the IDs of stops to keep (e.g. interchanges with other
+	// agencies), even if no remaining trip serves them.
+	KeepStops []string
+
+	// OnPlan (if not nil) is called with the plan of the trim (see PlanTrim)
+	// before executing it. If OnPlan returns an error, the DB is not trimmed
+	// and Trim returns the error (e.g. to have plans reviewed and approved).
+	OnPlan func(*TrimPlan) error
+
+	// Retry (if not nil) bounds the time of each statement and retries
+	// statements failing with transient errors (e.g. a busy DB, see
+	// IsTransient).
+	Retry *RetryPolicy
+
+	// Optimize (if true) rebuilds the trimmed tables on MySQL (via OPTIMIZE
+	// TABLE, locking each table while copying it), reclaiming the space of the
+	// deleted rows. Other DBs are vacuumed after trimming, whereas MySQL (which
+	// lacks VACUUM, but reuses the space of deleted rows) is left as it is,
+	// unless Optimize is true.
+	Optimize bool
+
+	// Logger (if not nil) is the handler the diagnostics of the trim are
+	// logged to, i.e. the rows deleted per item type and the vacuum (at level
+	// info), the other statements (at level debug) and the retries (at level
+	// warn).
+	Logger slog.Handler
+}
+
+// String returns a human-readable representation of TrimResult.
+func (tr TrimResult) String() string {
+	var sb strings.Builder
+	for _, trimItemsResult := range tr {
+		sb.WriteString(fmt.Sprintf("%s\n", trimItemsResult))
+	}
+	return sb.String()
+}
+
+// TrimStep describes a single statement of a trim plan.
+type TrimStep struct {
+	Table     string        `json:"table"`
+	Statement string        `json:"statement"`
+	Args      []interface{} `json:"args,omitempty"`
+	Expected  int64         `json:"expected_affected"` // the number of rows expected to be deleted
+}
+
+// TrimPlan is the type used to describe the (ordered) statements Trim
+// executes (followed by vacuuming the DB).
+type TrimPlan struct {
+	AgencyID   string     `json:"agency_id"`
+	AgencyName string     `json:"agency_name"`
+	Steps      []TrimStep `json:"steps"`
+}
+
+// String returns a human-readable representation of TrimPlan.
+func (tp TrimPlan) String() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("trim to agency %s (%s)\n", tp.AgencyID, tp.AgencyName))
+	for _, step := range tp.Steps {
+		sb.WriteString(fmt.Sprintf("delete %d from %s\n", step.Expected, step.Table))
+	}
+	return sb.String()
+}
+
+// trimStep is a single statement executed by Trim.
+type trimStep struct {
+	itemType ItemType // the item type reported (see TrimResult), if reported
+	reported bool
+	name     string
+	table    string
+	stmt     string
+	values   []interface{}
+}
+
+// trimSteps returns the agency that matches like and the (ordered) steps of
+// trimming the DB to it (see Trim).
+func trimSteps(db *gorm.DB, like string, opts TrimOptions) (*Agency, []trimStep, error) {
+
+	// ensure all necessary tables are available for stripping
+	requiredTables := []string{"agencies", "routes", "trips", "stop_times", "stops", "shapes", "calendars", "calendar_dates", "frequencies"}
+	for _, tableName := range requiredTables {
+		if !db.Migrator().HasTable(tableName) {
+			return nil, nil, fmt.Errorf("missing table '%s'", tableName)
+		}
+	}
+
+	// LIKE is case-sensitive on Postgres (but not on SQLite or MySQL)
+	var agency Agency
+	tx := db.Where("LOWER(name) LIKE LOWER(?)", fmt.Sprintf("%%%s%%", like)).First(&agency)
+	if tx.Error != nil {
+		return nil, nil, tx.Error
+	}
+
+	// keep the given stops
+	stopsStmt, stopsValues := delStopsStmt, []interface{}(nil)
+	if len(opts.KeepStops) > 0 {
+		stopsStmt, stopsValues = delStopsKeepingStmt, []interface{}{opts.KeepStops}
+	}
+
+	// note, the order of executing the trim statements is relevant
+	steps := []trimStep{
+		{Agencies, true, "agencies", "agencies", delAgencyStmt, []interface{}{agency.ID}},
+		{Routes, true, "routes", "routes", delRoutesStmt, nil},
+		{Trips, true, "trips", "trips", delTripsStmt, nil},
+		{StopTimes, true, "stop times", "stop_times", delStopTimesStmt, nil},
+		{Frequencies, true, "frequencies", "frequencies", delFrequenciesStmt, nil},
+		{Stops, true, "stops", "stops", stopsStmt, stopsValues},
+		{Shapes, true, "shapes", "shapes", delShapesStmt, nil},
+		{Calendars, true, "calendars", "calendars", delCalendarsStmt, nil},
+		{CalendarDates, true, "calendar dates", "calendar_dates", delCalendarDatesStmt, nil},
+	}
+
+	// remove the items of (derived or extending) tables referencing removed
+	// items, each if the table exists
+	cascade := []struct {
+		name  string
+		table string
+		stmt  string
+	}{
+		{"route directions", "route_directions", delRouteDirectionsStmt},
+		{"encoded shapes", "encoded_shapes", delEncodedShapesStmt},
+		{"shape bounds", "shape_bounds", delShapeBoundsStmt},
+		{"stop ridership", "stop_ridership", delStopRidershipStmt},
+		{"stop overrides", "stop_overrides", delStopOverridesStmt},
+		{"stop amenities", "stop_amenities", delStopAmenitiesStmt},
+		{"translations", "translations", delTranslationsStmt},
+		{"fare leg rules", "fare_leg_rules", delFareLegRulesStmt},
+		{"fare transfer rules", "fare_transfer_rules", delFareTransferRulesStmt},
+		{"route frequency buckets", "route_frequency_buckets", delRouteFrequencyBucketsStmt},
+		{"stop frequency buckets", "stop_frequency_buckets", delStopFrequencyBucketsStmt},
+		{"vehicle capacities", "vehicle_capacities", delVehicleCapacitiesStmt},
+	}
+	for _, c := range cascade {
+		if !db.Migrator().HasTable(c.table) {
+			continue
+		}
+		stmt := c.stmt
+
+		// shape bounds are kept for encoded shapes (if any, see EncodeShapes)
+		if c.table == "shape_bounds" && !db.Migrator().HasTable(&EncodedShape{}) {
+			stmt = delUnencodedShapeBoundsStmt
+		}
+		steps = append(steps, trimStep{name: c.name, table: c.table, stmt: stmt})
+	}
+
+	// remove stop search index entries
+	if db.Migrator().HasTable("stop_search") {
+		steps = append(steps, trimStep{name: "stop search index", table: "stop_search", stmt: delStopSearchStmt})
+	}
+
+	// remove search index entries
+	if db.Migrator().HasTable("search") {
+		steps = append(steps, trimStep{name: "search index", table: "search", stmt: delSearchStmt})
+	}
+
+	return &agency, steps, nil
+}
+
+// vacuumStmt returns the statement reclaiming the space of the rows deleted by
+// the given steps, i.e. VACUUM or OPTIMIZE TABLE on MySQL (lacking VACUUM, if
+// optimize is true, empty otherwise, see TrimOptions.Optimize).
+func vacuumStmt(db *gorm.DB, steps []trimStep, optimize bool) string {
+	if !isMySQL(db) {
+		return "vacuum"
+	}
+	if !optimize {
+		return ""
+	}
+	tables := make([]string, 0, len(steps))
+	seen := map[string]bool{}
+	for _, step := range steps {
+		if !seen[step.table] {
+			seen[step.table] = true
+			tables = append(tables, step.table)
+		}
+	}
+	return "OPTIMIZE TABLE " + strings.Join(tables, ", ")
+}
+
+// errTrimPlanned is returned to roll back the transaction planning a trim.
+var errTrimPlanned = errors.New("trim planned")
+
+// planTrim returns the plan of executing the given steps of trimming the DB to
+// the given agency. The rows expected to be deleted are counted by SELECTs
+// matching the DELETE statements, each run after the statements preceding it
+// within a transaction that is rolled back (i.e. the DB is not modified).
+func planTrim(db *gorm.DB, agency *Agency, steps []trimStep) (*TrimPlan, error) {
+	plan := &TrimPlan{AgencyID: agency.ID, AgencyName: agency.Name, Steps: make([]TrimStep, 0, len(steps))}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, step := range steps {
+			ts := TrimStep{Table: step.table, Statement: strings.TrimSpace(step.stmt), Args: step.values}
+			count := strings.Replace(step.stmt, "DELETE\nFROM", "SELECT COUNT(*)\nFROM", 1)
+			if res := tx.Raw(count, step.values...).Scan(&ts.Expected); res.Error != nil {
+				return fmt.Errorf("failed to count %s: %w", step.name, res.Error)
+			}
+			if res := tx.Exec(step.stmt, step.values...); res.Error != nil {
+				return fmt.Errorf("failed to trim %s: %w", step.name, res.Error)
+			}
+			plan.Steps = append(plan.Steps, ts)
+		}
+		return errTrimPlanned
+	})
+	if !errors.Is(err, errTrimPlanned) {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// PlanTrim returns the plan of trimming the DB to the agency that matches like
+// (see Trim), without modifying the DB. If there is no agency matching like,
+// gorm.ErrRecordNotFound is returned.
+func PlanTrim(db *gorm.DB, like string, opts TrimOptions) (*TrimPlan, error) {
+	agency, steps, err := trimSteps(db, like, opts)
+	if err != nil {
+		return nil, err
+	}
+	return planTrim(db, agency, steps)
+}
+
+// Trim removes all items from the DB that are not associated with the agency
+// that matches like (except for the stops to keep, see TrimOptions). After
+// completion, Trim returns some stats. If there is no agency matching like,
+// gorm.ErrRecordNotFound is returned.
+func Trim(db *gorm.DB, like string, opts TrimOptions) (*TrimResult, error) {
+	agency, steps, err := trimSteps(db, like, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// have the plan reviewed, if desired
+	if opts.OnPlan != nil {
+		plan, err := planTrim(db, agency, steps)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan trim: %w", err)
+		}
+		if err = opts.OnPlan(plan); err != nil {
+			return nil, err
+		}
+	}
+
+	// execute each of the statements
+	logger := newLogger(opts.Logger)
+	trimResult := TrimResult{}
+	for _, step := range steps {
+
+		start := time.Now()
+		var tx *gorm.DB
+		err = opts.Retry.Do(dbContext(db), func(ctx context.Context) error {
+			if tx != nil {
+				logger.Warn("retrying trim", slog.String("step", step.name), slog.Any("error", tx.Error))
+			}
+			tx = db.WithContext(ctx).Exec(step.stmt, step.values...)
+			return tx.Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to trim %s: %w", step.name, err)
+		}
+		if !step.reported {
+			logger.Debug("trimmed", slog.String("step", step.name), slog.Int64("rows", tx.RowsAffected), slog.Duration("duration", time.Since(start)))
+			continue
+		}
+		trimItemsResult := TrimItemsResult{
+			ItemType: step.itemType,
+			Affected: tx.RowsAffected,
+			Time:     time.Since(start),
+		}
+		db.Table(step.table).Count(&trimItemsResult.Remaining)
+		trimResult[step.itemType] = &trimItemsResult
+		logger.Info("trimmed", slog.String("item_type", step.itemType.String()), slog.Int64("rows", trimItemsResult.Affected), slog.Int64("remaining", trimItemsResult.Remaining), slog.Duration("duration", trimItemsResult.Time))
+
+	}
+
+	// vacuum (unless on MySQL and not optimizing)
+	if stmt := vacuumStmt(db, steps, opts.Optimize); stmt != "" {
+		start := time.Now()
+		if tx := db.Exec(stmt); tx.Error != nil {
+			return nil, fmt.Errorf("failed to vacuum: %w", tx.Error)
+		}
+		logger.Info("vacuumed", slog.Duration("duration", time.Since(start)))
+	}
+
+	return &trimResult, nil
+}
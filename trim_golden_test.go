@@ -0,0 +1,91 @@
+package gtfs_test
+
+import (
+	"flag"
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"testing"
+)
+
+// update (if set via "go test -update") rewrites golden files rather than
+// comparing against them.
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenTrim is the directory holding the snapshots of trimmed fixture feeds.
+const goldenTrim = "_fixture/golden/trim"
+
+// TestTrim_Golden trims the fixture feed (extended by translations, fares and
+// vehicle capacities) and compares the contents of all tables (exported as
+// GTFS files) against golden snapshots, guarding the order of deleting items
+// (e.g. stops still referenced by remaining stop times).
+func TestTrim_Golden(t *testing.T) {
+	tests := []struct {
+		name string
+		like string
+		opts gtfs.TrimOptions
+	}{
+		{"sbahn", "S-Bahn", gtfs.TrimOptions{}},
+		{"sbahn_keeping_stops", "S-Bahn", gtfs.TrimOptions{KeepStops: []string{"B1"}}},
+		{"bvg", "BVG", gtfs.TrimOptions{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFixtureDB(t)
+			db.Create([]gtfs.Translation{
+				{Table: "stops", FieldName: "stop_name", Language: "en", Text: "Wannsee Station", RecordID: "S1"},
+				{Table: "stops", FieldName: "stop_name", Language: "en", Text: "Wannsee Lido", RecordID: "B3"},
+				{Table: "stops", FieldName: "stop_name", Language: "en", Text: "Wannsee Lido", FieldValue: "Strandbad Wannsee"},
+				{Table: "routes", FieldName: "route_long_name", Language: "en", Text: "Wannsee - Steglitz Town Hall", RecordID: "R1"},
+			})
+			db.Create([]gtfs.FareProduct{{FareProductID: "single", FareProductName: "Single", Amount: 3.2, Currency: "EUR"}})
+			db.Create([]gtfs.FareLegRule{{LegGroupID: "single", FareProductID: "single"}, {LegGroupID: "day", FareProductID: "day"}})
+			db.Create([]gtfs.VehicleCapacity{{RouteID: "R1", SeatedCapacity: 200}, {RouteID: "R2", TripID: "T4", SeatedCapacity: 40}})
+			if _, err := gtfs.Trim(db, tt.like, tt.opts); err != nil {
+				t.Fatalf("Trim() error = %v", err)
+			}
+			dir := t.TempDir()
+			if err := gtfs.Export(db, dir, gtfs.ExportOptions{}); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			golden := path.Join(goldenTrim, tt.name)
+			files, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatalf("failed to read export: %v", err)
+			}
+			if *update {
+				if err = os.RemoveAll(golden); err == nil {
+					err = os.MkdirAll(golden, 0o755)
+				}
+				if err != nil {
+					t.Fatalf("failed to reset golden files: %v", err)
+				}
+			}
+			for _, f := range files {
+				got, err := os.ReadFile(path.Join(dir, f.Name()))
+				if err != nil {
+					t.Fatalf("failed to read export: %v", err)
+				}
+				if *update {
+					if err = os.WriteFile(path.Join(golden, f.Name()), got, 0o644); err != nil {
+						t.Fatalf("failed to write golden file: %v", err)
+					}
+					continue
+				}
+				want, err := os.ReadFile(path.Join(golden, f.Name()))
+				if err != nil {
+					t.Fatalf("failed to read golden file (run with -update to create it): %v", err)
+				}
+				if string(got) != string(want) {
+					t.Errorf("Trim() %s =\n%s\nwant\n%s", f.Name(), got, want)
+				}
+			}
+
+			// detect tables no longer exported (e.g. becoming empty)
+			if golds, err := os.ReadDir(golden); err == nil && len(golds) != len(files) {
+				t.Errorf("Trim() exported %d files, want %d", len(files), len(golds))
+			}
+		})
+	}
+}
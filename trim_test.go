@@ -0,0 +1,188 @@
+package gtfs_test
+
+import (
+	"bytes"
+	"errors"
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      gtfs.TrimOptions
+		wantStops []string
+	}{
+		{
+			name:      "default",
+			wantStops: []string{"S1", "S2", "S3", "S4"},
+		},
+		{
+			name:      "keeping stops",
+			opts:      gtfs.TrimOptions{KeepStops: []string{"B1", "S1"}},
+			wantStops: []string{"B1", "S1", "S2", "S3", "S4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFixtureDB(t)
+			r, err := gtfs.Trim(db, "S-Bahn", tt.opts)
+			if err != nil {
+				t.Fatalf("Trim() error = %v", err)
+			}
+			if got := (*r)[gtfs.Trips].Remaining; got != 3 {
+				t.Errorf("Trim() remaining trips = %d, want 3", got)
+			}
+
+			var stops []string
+			db.Model(&gtfs.Stop{}).Order("id").Pluck("id", &stops)
+			if len(stops) != len(tt.wantStops) {
+				t.Fatalf("Trim() stops = %v, want %v", stops, tt.wantStops)
+			}
+			for i := range stops {
+				if stops[i] != tt.wantStops[i] {
+					t.Errorf("Trim() stops = %v, want %v", stops, tt.wantStops)
+					break
+				}
+			}
+		})
+	}
+
+	if _, err := gtfs.Trim(newFixtureDB(t), "Unknown", gtfs.TrimOptions{}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("Trim() error = %v, want %v", err, gorm.ErrRecordNotFound)
+	}
+}
+
+func TestTrim_Cascade(t *testing.T) {
+	tests := []struct {
+		name    string
+		missing []string // tables dropped before trimming
+	}{
+		{"all tables", nil},
+		{"missing tables", []string{"encoded_shapes", "route_directions", "stop_ridership", "stop_overrides"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newFixtureDB(t)
+			db.Create([]gtfs.StopAmenity{{StopID: "S1", Shelter: 1}, {StopID: "B2", Bench: 1}})
+			db.Create([]gtfs.StopRidership{{StopID: "S1", Boardings: 10}, {StopID: "B1", Boardings: 5}})
+			db.Create([]gtfs.StopOverride{{StopID: "S1", Name: "Wannsee"}, {StopID: "B1", Name: "Wannsee (Bus)"}})
+			if err := gtfs.BuildFrequencyBuckets(db, time.Hour); err != nil {
+				t.Fatalf("BuildFrequencyBuckets() error = %v", err)
+			}
+			if err := db.Migrator().DropTable(stringsToInterfaces(tt.missing)...); err != nil {
+				t.Fatalf("failed to drop tables: %v", err)
+			}
+
+			if _, err := gtfs.Trim(db, "S-Bahn", gtfs.TrimOptions{}); err != nil {
+				t.Fatalf("Trim() error = %v", err)
+			}
+			counts := []struct {
+				table string
+				cond  string
+				want  int64
+			}{
+				{"stop_amenities", "stop_id = 'B2'", 0},
+				{"stop_amenities", "stop_id = 'S1'", 1},
+				{"stop_ridership", "stop_id = 'B1'", 0},
+				{"stop_overrides", "stop_id = 'B1'", 0},
+				{"route_frequency_buckets", "route_id = 'R2'", 0},
+				{"stop_frequency_buckets", "stop_id LIKE 'B%'", 0},
+				{"shape_bounds", "shape_id NOT IN (SELECT shape_id FROM shapes)", 0},
+			}
+			for _, c := range counts {
+				if !db.Migrator().HasTable(c.table) {
+					continue
+				}
+				var got int64
+				db.Table(c.table).Where(c.cond).Count(&got)
+				if got != c.want {
+					t.Errorf("Trim() %s where %s = %d, want %d", c.table, c.cond, got, c.want)
+				}
+			}
+			var buckets int64
+			if db.Model(&gtfs.RouteFrequencyBucket{}).Where("route_id = ?", "R1").Count(&buckets); buckets == 0 {
+				t.Errorf("Trim() removed the frequency buckets of route R1")
+			}
+		})
+	}
+}
+
+// stringsToInterfaces converts strings (e.g. table names) to interfaces.
+func stringsToInterfaces(ss []string) []interface{} {
+	is := make([]interface{}, len(ss))
+	for i, s := range ss {
+		is[i] = s
+	}
+	return is
+}
+
+func TestPlanTrim(t *testing.T) {
+	db := newFixtureDB(t)
+
+	plan, err := gtfs.PlanTrim(db, "S-Bahn", gtfs.TrimOptions{})
+	if err != nil {
+		t.Fatalf("PlanTrim() error = %v", err)
+	}
+	expected := map[string]int64{}
+	for _, step := range plan.Steps {
+		expected[step.Table] = step.Expected
+	}
+	if plan.AgencyID != "1" || expected["agencies"] != 1 || expected["trips"] != 1 || expected["stop_times"] != 3 {
+		t.Errorf("PlanTrim() = %+v", plan)
+	}
+
+	// planning leaves the DB as it is
+	var trips int64
+	db.Model(&gtfs.Trip{}).Count(&trips)
+	if trips != 4 {
+		t.Errorf("PlanTrim() remaining trips = %d, want 4", trips)
+	}
+
+	// rejecting the plan leaves the DB as it is
+	errRejected := errors.New("rejected")
+	_, err = gtfs.Trim(db, "S-Bahn", gtfs.TrimOptions{OnPlan: func(*gtfs.TrimPlan) error {
+		return errRejected
+	}})
+	if !errors.Is(err, errRejected) {
+		t.Errorf("Trim() error = %v, want %v", err, errRejected)
+	}
+	db.Model(&gtfs.Trip{}).Count(&trips)
+	if trips != 4 {
+		t.Errorf("Trim() remaining trips = %d, want 4", trips)
+	}
+
+	// the plan matches the trim
+	r, err := gtfs.Trim(db, "S-Bahn", gtfs.TrimOptions{OnPlan: func(*gtfs.TrimPlan) error {
+		return nil
+	}})
+	if err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	for itemType, table := range map[gtfs.ItemType]string{gtfs.Agencies: "agencies", gtfs.Trips: "trips", gtfs.StopTimes: "stop_times", gtfs.Stops: "stops"} {
+		if got := (*r)[itemType].Affected; got != expected[table] {
+			t.Errorf("Trim() %s affected = %d, want %d as planned", itemType, got, expected[table])
+		}
+	}
+}
+
+func TestTrim_Logger(t *testing.T) {
+	db := newFixtureDB(t)
+	var buf bytes.Buffer
+	if _, err := gtfs.Trim(db, "S-Bahn", gtfs.TrimOptions{Logger: slog.NewTextHandler(&buf, nil)}); err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"msg=trimmed item_type=Trips rows=1 remaining=3", "msg=vacuumed"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Trim() logged %q, want %q", out, want)
+		}
+	}
+	if strings.Contains(out, "level=DEBUG") {
+		t.Errorf("Trim() logged %q, want no debug records", out)
+	}
+}
@@ -0,0 +1,64 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+)
+
+// truncateOrder defines the order in which to clear tables, such that items
+// get deleted before the items they refer to.
+var truncateOrder = []ItemType{
+	Frequencies,
+	StopTimes,
+	Trips,
+	Routes,
+	Agencies,
+	Stops,
+	Shapes,
+	CalendarDates,
+	Calendars,
+}
+
+// Truncate deletes all items of the given types (of all types, if none are
+// given) from the DB. Tables are cleared (within a single transaction) in an
+// order respecting the references between them. For SQLite DBs, the
+// auto-increment sequences of the cleared tables are reset.
+func Truncate(db *gorm.DB, types ...ItemType) error {
+
+	// determine what to clear
+	selected := map[ItemType]bool{}
+	for _, it := range types {
+		if _, ok := itemModels[it]; !ok {
+			return fmt.Errorf("unknown ItemType %d", it)
+		}
+		selected[it] = true
+	}
+	if len(types) == 0 {
+		for _, it := range truncateOrder {
+			selected[it] = true
+		}
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		resetSequences := tx.Dialector.Name() == "sqlite" && tx.Migrator().HasTable("sqlite_sequence")
+		for _, it := range truncateOrder {
+			if !selected[it] {
+				continue
+			}
+			stmt := &gorm.Statement{DB: tx}
+			if err := stmt.Parse(itemModels[it]); err != nil {
+				return fmt.Errorf("failed to parse model of %s: %w", it, err)
+			}
+			table := stmt.Schema.Table
+			if err := tx.Exec("DELETE FROM ?", gorm.Expr(tx.Statement.Quote(table))).Error; err != nil {
+				return fmt.Errorf("failed to truncate %s: %w", it, err)
+			}
+			if resetSequences {
+				if err := tx.Exec("DELETE FROM sqlite_sequence WHERE name = ?", table).Error; err != nil {
+					return fmt.Errorf("failed to reset sequence of %s: %w", it, err)
+				}
+			}
+		}
+		return nil
+	})
+}
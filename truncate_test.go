@@ -0,0 +1,48 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	db := newFixtureDB(t)
+
+	if err := gtfs.Truncate(db, gtfs.StopTimes, gtfs.Trips); err != nil {
+		t.Fatalf("Truncate() error = %v", err)
+	}
+	tests := []struct {
+		model interface{}
+		want  int64
+	}{
+		{&gtfs.Trip{}, 0},
+		{&gtfs.StopTime{}, 0},
+		{&gtfs.Route{}, 2},
+		{&gtfs.Stop{}, 7},
+	}
+	for _, tt := range tests {
+		var count int64
+		db.Model(tt.model).Count(&count)
+		if count != tt.want {
+			t.Errorf("Truncate() %T count = %d, want %d", tt.model, count, tt.want)
+		}
+	}
+
+	// truncating everything
+	if err := gtfs.Truncate(db); err != nil {
+		t.Fatalf("Truncate() error = %v", err)
+	}
+	fs, err := gtfs.Stats(db)
+	if err != nil {
+		t.Fatalf("Stats() error = %v", err)
+	}
+	for it, count := range fs.Counts {
+		if count != 0 {
+			t.Errorf("Truncate() %s count = %d, want 0", it, count)
+		}
+	}
+
+	if err = gtfs.Truncate(db, gtfs.ItemType(42)); err == nil {
+		t.Errorf("Truncate() expected error for unknown ItemType")
+	}
+}
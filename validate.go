@@ -0,0 +1,134 @@
+package gtfs
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// Severity enumerates the severities of validation issues.
+type Severity uint32
+
+const (
+
+	// Info the severity of issues that are merely noteworthy.
+	Info Severity = iota
+
+	// Warning the severity of issues that are likely defects.
+	Warning
+
+	// Error the severity of issues that are defects.
+	Error
+)
+
+var txSeverity = map[Severity]string{
+	Info:    "info",
+	Warning: "warning",
+	Error:   "error",
+}
+
+// String returns a human-readable representation of Severity.
+func (s Severity) String() string {
+	if t := txSeverity[s]; t != "" {
+		return t
+	}
+	return fmt.Sprintf("Unknown Severity (%d)", uint32(s))
+}
+
+// ParseSeverity returns the Severity with the given name (i.e. "info",
+// "warning" or "error").
+func ParseSeverity(s string) (Severity, error) {
+	for severity, name := range txSeverity {
+		if name == s {
+			return severity, nil
+		}
+	}
+	return Info, fmt.Errorf("unknown severity '%s'", s)
+}
+
+// Issue describes a single problem found while validating a feed.
+type Issue struct {
+	Rule     string
+	Severity Severity
+	ItemType ItemType
+	ItemID   string
+	Message  string
+}
+
+// String returns a human-readable representation of Issue.
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s %s: %s (%s)", i.Severity, i.ItemType, i.ItemID, i.Message, i.Rule)
+}
+
+// Rule checks a feed for a certain kind of problem.
+type Rule struct {
+	Name  string
+	Check func(db *gorm.DB) ([]Issue, error)
+}
+
+// DefaultRules are the rules applied by Validate if no rules are given.
+var DefaultRules = []Rule{
+	{Name: "duplicate_trips", Check: DuplicateTrips},
+	{Name: "overlapping_block_trips", Check: OverlappingBlockTrips},
+	{Name: "orphaned_records", Check: OrphanedRecords},
+	{Name: "implausible_speeds", Check: ImplausibleSpeeds},
+	{Name: "trips_off_shape", Check: TripsOffShape},
+}
+
+// Validate applies the given rules (or DefaultRules if none are given) to the
+// feed in db and returns all issues found.
+func Validate(db *gorm.DB, rules ...Rule) ([]Issue, error) {
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+	var issues []Issue
+	for _, r := range rules {
+		ruleIssues, err := r.Check(db)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply rule '%s': %w", r.Name, err)
+		}
+		for _, i := range ruleIssues {
+			i.Rule = r.Name
+			issues = append(issues, i)
+		}
+	}
+	return issues, nil
+}
+
+// ValidationGate decides whether the issues found by validating a feed fail
+// it, e.g. to gate the release of a feed in a CI pipeline.
+type ValidationGate struct {
+
+	// FailOn is the lowest severity failing a feed (e.g. Error to tolerate
+	// infos and warnings).
+	FailOn Severity
+
+	// MaxWarnings (if >= 0) is the number of warnings tolerated (regardless
+	// of FailOn), i.e. more warnings fail a feed, fewer don't.
+	MaxWarnings int
+}
+
+// Check returns an error (describing the issues failing the feed), if the
+// issues fail the feed.
+func (vg ValidationGate) Check(issues []Issue) error {
+	counts := map[Severity]int{}
+	for _, i := range issues {
+		counts[i.Severity]++
+	}
+	var reasons []string
+	for _, severity := range []Severity{Error, Warning, Info} {
+		n := counts[severity]
+		switch {
+		case severity == Warning && vg.MaxWarnings >= 0:
+			if n > vg.MaxWarnings {
+				reasons = append(reasons, fmt.Sprintf("%d warnings (at most %d tolerated)", n, vg.MaxWarnings))
+			}
+		case severity >= vg.FailOn && n > 0:
+			reasons = append(reasons, fmt.Sprintf("%d issues of severity %s", n, severity))
+		}
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+	return fmt.Errorf("found %s", strings.Join(reasons, " and "))
+}
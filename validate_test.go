@@ -0,0 +1,77 @@
+package gtfs_test
+
+import (
+	"github.com/heimdalr/gtfs"
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	db := newFixtureDB(t)
+
+	// the fixture feed passes the default rules
+	issues, err := gtfs.Validate(db)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(issues) != 0 {
+		t.Errorf("Validate() = %v, want none", issues)
+	}
+
+	// issues get the name of the rule that raised them
+	rule := gtfs.Rule{
+		Name: "always",
+		Check: func(_ *gorm.DB) ([]gtfs.Issue, error) {
+			return []gtfs.Issue{{Severity: gtfs.Info, ItemType: gtfs.Stops, ItemID: "S1"}}, nil
+		},
+	}
+	issues, err = gtfs.Validate(db, rule)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+	if len(issues) != 1 || issues[0].Rule != "always" {
+		t.Errorf("Validate() = %v, want a single issue of rule 'always'", issues)
+	}
+}
+
+func TestValidationGate_Check(t *testing.T) {
+	issues := []gtfs.Issue{
+		{Severity: gtfs.Info},
+		{Severity: gtfs.Warning},
+		{Severity: gtfs.Warning},
+	}
+	tests := []struct {
+		name    string
+		gate    gtfs.ValidationGate
+		issues  []gtfs.Issue
+		wantErr string
+	}{
+		{"none", gtfs.ValidationGate{FailOn: gtfs.Info, MaxWarnings: -1}, nil, ""},
+		{"info", gtfs.ValidationGate{FailOn: gtfs.Info, MaxWarnings: -1}, issues, "found 2 issues of severity warning and 1 issues of severity info"},
+		{"warning", gtfs.ValidationGate{FailOn: gtfs.Warning, MaxWarnings: -1}, issues, "found 2 issues of severity warning"},
+		{"error", gtfs.ValidationGate{FailOn: gtfs.Error, MaxWarnings: -1}, issues, ""},
+		{"error with max warnings", gtfs.ValidationGate{FailOn: gtfs.Error, MaxWarnings: 1}, issues, "found 2 warnings (at most 1 tolerated)"},
+		{"warning within max warnings", gtfs.ValidationGate{FailOn: gtfs.Warning, MaxWarnings: 2}, issues, ""},
+		{"errors", gtfs.ValidationGate{FailOn: gtfs.Error, MaxWarnings: 2}, append(issues, gtfs.Issue{Severity: gtfs.Error}), "found 1 issues of severity error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.gate.Check(tt.issues)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("Check() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	for _, s := range []gtfs.Severity{gtfs.Info, gtfs.Warning, gtfs.Error} {
+		got, err := gtfs.ParseSeverity(s.String())
+		if err != nil || got != s {
+			t.Errorf("ParseSeverity(%q) = %v, %v, want %v", s.String(), got, err, s)
+		}
+	}
+	if _, err := gtfs.ParseSeverity("fatal"); err == nil {
+		t.Errorf("ParseSeverity(\"fatal\") error = nil, want error")
+	}
+}
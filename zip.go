@@ -0,0 +1,124 @@
+package gtfs
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// IsZip returns true, if the path refers to a zip archive (by its extension).
+func IsZip(p string) bool {
+	return strings.EqualFold(path.Ext(p), ".zip")
+}
+
+// ImportZip imports all GTFS CSV files from the zip archive at zipPath into the
+// db (see ImportWithOptions). As many archives nest the files within a
+// directory (e.g. "gtfs/stops.txt"), the files are imported from the
+// shallowest directory of the archive holding all required files (i.e. all
+// files but frequencies.txt). If there is no such directory, an error is
+// returned (and nothing is imported). Otherwise, ImportZip returns the summary
+// of the import (see ImportWithOptions).
+func ImportZip(db *gorm.DB, zipPath string, opts ImportOptions) (*ImportSummary, error) {
+	fsys, dir, closeZip, err := openZip(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeZip()
+	return importFS(db, fsys, dir, zipPath, opts), nil
+}
+
+// locateFeed returns the shallowest directory (the first one by name, among
+// equally shallow ones) of the archive holding all required files.
+func locateFeed(zr *zip.Reader) (string, error) {
+
+	// the files found per directory
+	files := map[string]map[string]bool{}
+	for _, f := range zr.File {
+		name := path.Clean(f.Name)
+		if f.FileInfo().IsDir() || !fs.ValidPath(name) {
+			continue
+		}
+		dir := path.Dir(name)
+		if files[dir] == nil {
+			files[dir] = map[string]bool{}
+		}
+		files[dir][path.Base(name)] = true
+	}
+
+	// the directories holding all required files
+	var dirs []string
+	var missing []string
+	for dir, names := range files {
+		complete := true
+		for _, source := range importSources {
+			if !source.optional && !names[itemFiles[source.itemType]] {
+				complete = false
+				if dir == "." {
+					missing = append(missing, itemFiles[source.itemType])
+				}
+			}
+		}
+		if complete {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		if len(missing) == 0 {
+			return "", errors.New("no GTFS files")
+		}
+		return "", fmt.Errorf("missing %s: %w", strings.Join(missing, ", "), ErrRequiredFileMissing)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		di, dj := depth(dirs[i]), depth(dirs[j])
+		if di != dj {
+			return di < dj
+		}
+		return dirs[i] < dirs[j]
+	})
+	return dirs[0], nil
+}
+
+// depth returns the depth of a directory (as returned by path.Dir) of an
+// archive.
+func depth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// openFeed returns the file system holding the feed at gtfsBase (a directory
+// or a zip archive, see ImportZip) along with the directory of the feed
+// within it and a function closing it.
+func openFeed(gtfsBase string) (fs.FS, string, func(), error) {
+	if IsZip(gtfsBase) {
+		return openZip(gtfsBase)
+	}
+	if gtfsBase == "" {
+		gtfsBase = "."
+	}
+	return os.DirFS(gtfsBase), ".", func() {}, nil
+}
+
+// openZip returns the zip archive at zipPath as file system along with the
+// directory of the feed within it (see locateFeed) and a function closing it.
+func openZip(zipPath string) (fs.FS, string, func(), error) {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	dir, err := locateFeed(&zr.Reader)
+	if err != nil {
+		_ = zr.Close()
+		return nil, "", nil, fmt.Errorf("failed to locate feed within '%s': %w", zipPath, err)
+	}
+	return zr, dir, func() {
+		_ = zr.Close()
+	}, nil
+}
@@ -0,0 +1,117 @@
+package gtfs_test
+
+import (
+	"archive/zip"
+	"github.com/heimdalr/gtfs"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+// writeZip writes the fixture feed to a zip archive at zipPath (within each of
+// the given directories), along with extra files.
+func writeZip(t *testing.T, zipPath string, dirs []string, extra map[string]string) {
+	t.Helper()
+	file, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("failed to create zip: %v", err)
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+	zw := zip.NewWriter(file)
+	entries, err := os.ReadDir(fixtureFeed)
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	files := map[string]string{}
+	for _, dir := range dirs {
+		for _, e := range entries {
+			b, err := os.ReadFile(path.Join(fixtureFeed, e.Name()))
+			if err != nil {
+				t.Fatalf("failed to read fixture: %v", err)
+			}
+			files[path.Join(dir, e.Name())] = string(b)
+		}
+	}
+	for name, content := range extra {
+		files[name] = content
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("failed to write zip: %v", err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write zip: %v", err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatalf("failed to write zip: %v", err)
+	}
+}
+
+func TestImportZip(t *testing.T) {
+	tests := []struct {
+		name     string
+		dirs     []string
+		extra    map[string]string
+		wantErr  string
+		wantPath string
+	}{
+		{"root", []string{"."}, nil, "", "feed.zip/stops.txt"},
+		{"nested", []string{"gtfs"}, nil, "", "feed.zip/gtfs/stops.txt"},
+		{"shallowest", []string{"b/deep", "a"}, map[string]string{"stops.txt": "stop_id\n"}, "", "feed.zip/a/stops.txt"},
+		{"incomplete", nil, map[string]string{"stops.txt": "stop_id\n"}, "missing agency.txt", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			zipPath := path.Join(dir, "feed.zip")
+			writeZip(t, zipPath, tt.dirs, tt.extra)
+
+			db := newTestDB(t)
+			var stopsPath string
+			_, err := gtfs.ImportZip(db, zipPath, gtfs.ImportOptions{
+				OnProgress: func(e gtfs.ImportEvent) {
+					if e.Result.Error != nil {
+						t.Fatalf("ImportZip() error = %v", e.Result.Error)
+					}
+					if e.Result.ItemType == gtfs.Stops {
+						stopsPath = e.Result.Path
+					}
+				},
+			})
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("ImportZip() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ImportZip() error = %v", err)
+			}
+			if want := path.Join(dir, tt.wantPath); stopsPath != want {
+				t.Errorf("ImportZip() stops from %s, want %s", stopsPath, want)
+			}
+			var stops int64
+			db.Model(&gtfs.Stop{}).Count(&stops)
+			if stops != 7 {
+				t.Errorf("ImportZip() stops = %d, want 7", stops)
+			}
+
+			// the feed info is found, too
+			if err = gtfs.RecordFeedMeta(db, zipPath, gtfs.FeedMeta{}); err != nil {
+				t.Fatalf("RecordFeedMeta() error = %v", err)
+			}
+			meta, err := gtfs.GetFeedMeta(db)
+			if err != nil {
+				t.Fatalf("GetFeedMeta() error = %v", err)
+			}
+			if meta.Version != "2022-01" {
+				t.Errorf("GetFeedMeta() version = %q, want 2022-01", meta.Version)
+			}
+		})
+	}
+}